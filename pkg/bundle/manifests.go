@@ -0,0 +1,210 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shortNames maps the short names kubectl knows to the plural the dumps of a bundle are named after
+var shortNames = map[string]string{
+	"cm":     "configmaps",
+	"cj":     "cronjobs",
+	"crd":    "customresourcedefinitions",
+	"crds":   "customresourcedefinitions",
+	"deploy": "deployments",
+	"ds":     "daemonsets",
+	"ep":     "endpoints",
+	"ev":     "events",
+	"hpa":    "horizontalpodautoscalers",
+	"ing":    "ingresses",
+	"netpol": "networkpolicies",
+	"no":     "nodes",
+	"ns":     "namespaces",
+	"pc":     "priorityclasses",
+	"pdb":    "poddisruptionbudgets",
+	"po":     "pods",
+	"pv":     "persistentvolumes",
+	"pvc":    "persistentvolumeclaims",
+	"rs":     "replicasets",
+	"sa":     "serviceaccounts",
+	"sc":     "storageclasses",
+	"sts":    "statefulsets",
+	"svc":    "services",
+	"vm":     "virtualmachines",
+	"vmi":    "virtualmachineinstances",
+	"vmim":   "virtualmachineinstancemigrations",
+	"lhv":    "volumes",
+	"lhe":    "engines",
+	"lhr":    "replicas",
+}
+
+// pluralsOf returns the names a dump of a resource type given as kubectl takes it could have
+func pluralsOf(resourceType string) []string {
+	if plural, ok := shortNames[resourceType]; ok {
+		return []string{plural}
+	}
+	plurals := []string{resourceType, resourceType + "s", resourceType + "es"}
+	if base, ok := strings.CutSuffix(resourceType, "y"); ok {
+		plurals = append(plurals, base+"ies")
+	}
+	return plurals
+}
+
+// ManifestCandidates returns the dumps of paths, slash separated and relative to the extracted bundle,
+// that could hold resources of resourceType in namespace. resourceType is what kubectl takes: a
+// plural, singular or short name, optionally with its group like virtualmachines.kubevirt.io. Dumps of
+// the namespace come before the cluster scoped ones and the core group before the others.
+func ManifestCandidates(paths []string, namespace, resourceType string) []string {
+	resourceType = strings.ToLower(resourceType)
+	name, group, _ := strings.Cut(resourceType, ".")
+	plurals := pluralsOf(name)
+
+	type candidate struct {
+		path string
+		rank int
+	}
+	var candidates []candidate
+	for _, p := range paths {
+		parts := strings.Split(p, "/")
+		// yamls is in the root of the bundle or in the directory the bundle zip was created with
+		i := 0
+		for i < len(parts) && i < 2 && parts[i] != "yamls" {
+			i++
+		}
+		if i >= len(parts) || parts[i] != "yamls" {
+			continue
+		}
+		parts = parts[i+1:]
+
+		rank := 0
+		switch {
+		case len(parts) >= 4 && parts[0] == "namespaced" && parts[1] == namespace:
+			parts = parts[2:]
+		case len(parts) >= 3 && parts[0] == "cluster":
+			parts, rank = parts[1:], 2
+		default:
+			continue
+		}
+		// group/version/plural.yaml, version/plural.yaml for the core group
+		file, ok := strings.CutSuffix(parts[len(parts)-1], ".yaml")
+		if !ok || !slices.Contains(plurals, file) {
+			continue
+		}
+		switch len(parts) {
+		case 2:
+			// pods.v1 names the version of the core group
+			if group != "" && group != parts[0] {
+				continue
+			}
+		case 3:
+			// virtualmachines.kubevirt.io or with the version, virtualmachines.v1.kubevirt.io
+			if group != "" && group != parts[0] && group != parts[1]+"."+parts[0] {
+				continue
+			}
+			rank++
+		default:
+			continue
+		}
+		candidates = append(candidates, candidate{path: p, rank: rank})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return candidates[i].path < candidates[j].path
+	})
+	found := make([]string, len(candidates))
+	for i, c := range candidates {
+		found[i] = c.path
+	}
+	return found
+}
+
+// FindObject returns the YAML of the object called name in a list dump of a bundle, as kubectl prints
+// a single object. found is false when the dump doesn't hold it.
+func FindObject(file, name string) (content string, found bool, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", false, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", false, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "items" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		// The line of the key after the items ends the last of them
+		end := 0
+		if i+2 < len(root.Content) {
+			end = root.Content[i+2].Line
+		}
+		items := root.Content[i+1].Content
+		for j, item := range items {
+			var object struct {
+				Metadata struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+			}
+			if item.Decode(&object) != nil || object.Metadata.Name != name {
+				continue
+			}
+			itemEnd := end
+			if j+1 < len(items) {
+				itemEnd = items[j+1].Line
+			}
+			return itemText(data, item, itemEnd), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// itemText cuts the text of a list item out of the dump, from its line up to the line end starts the
+// next part at (0 to the end of the dump) with the indentation of the list removed. Items not written
+// in block style are encoded again.
+func itemText(data []byte, item *yaml.Node, end int) string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if end == 0 || end > len(lines)+1 {
+		end = len(lines) + 1
+	}
+	indent := item.Column - 1
+	var b strings.Builder
+	for n := item.Line; n < end; n++ {
+		line := lines[n-1]
+		switch {
+		case strings.TrimSpace(line) == "":
+			b.WriteString("\n")
+		case n == item.Line && strings.HasPrefix(strings.TrimLeft(line[:min(indent, len(line))], " "), "-"):
+			b.WriteString(line[indent:])
+		case len(line) > indent && strings.TrimSpace(line[:indent]) == "":
+			b.WriteString(line[indent:])
+		default:
+			return encodeNode(item)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func encodeNode(node *yaml.Node) string {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return ""
+	}
+	enc.Close()
+	return buf.String()
+}
@@ -0,0 +1,99 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ManifestCandidates(t *testing.T) {
+	assert := require.New(t)
+	paths := []string{
+		"supportbundle_1/metadata.yaml",
+		"supportbundle_1/yamls/cluster/v1/nodes.yaml",
+		"supportbundle_1/yamls/cluster/v1/namespaces.yaml",
+		"supportbundle_1/yamls/namespaced/default/v1/configmaps.yaml",
+		"supportbundle_1/yamls/namespaced/default/v1/events.yaml",
+		"supportbundle_1/yamls/namespaced/default/events.k8s.io/v1/events.yaml",
+		"supportbundle_1/yamls/namespaced/default/kubevirt.io/v1/virtualmachines.yaml",
+		"supportbundle_1/yamls/namespaced/other/v1/configmaps.yaml",
+		"supportbundle_1/yamls/namespaced/default/networking.k8s.io/v1/ingresses.yaml",
+		"supportbundle_1/yamls/cluster/harvesterhci.io/v1beta1/settings.yaml",
+		"supportbundle_1/nodes/harvester-01/yamls/cluster/v1/nodes.yaml",
+	}
+	for resourceType, want := range map[string][]string{
+		"cm":                      {"supportbundle_1/yamls/namespaced/default/v1/configmaps.yaml"},
+		"configmap":               {"supportbundle_1/yamls/namespaced/default/v1/configmaps.yaml"},
+		"ConfigMaps":              {"supportbundle_1/yamls/namespaced/default/v1/configmaps.yaml"},
+		"node":                    {"supportbundle_1/yamls/cluster/v1/nodes.yaml"},
+		"ingress":                 {"supportbundle_1/yamls/namespaced/default/networking.k8s.io/v1/ingresses.yaml"},
+		"setting":                 {"supportbundle_1/yamls/cluster/harvesterhci.io/v1beta1/settings.yaml"},
+		"vm":                      {"supportbundle_1/yamls/namespaced/default/kubevirt.io/v1/virtualmachines.yaml"},
+		"vm.kubevirt.io":          {"supportbundle_1/yamls/namespaced/default/kubevirt.io/v1/virtualmachines.yaml"},
+		"vm.v1.kubevirt.io":       {"supportbundle_1/yamls/namespaced/default/kubevirt.io/v1/virtualmachines.yaml"},
+		"events.events.k8s.io":    {"supportbundle_1/yamls/namespaced/default/events.k8s.io/v1/events.yaml"},
+		"virtualmachines.foo.bar": {},
+		"secrets":                 {},
+		// the core group first, like kubectl picks it
+		"ev": {"supportbundle_1/yamls/namespaced/default/v1/events.yaml", "supportbundle_1/yamls/namespaced/default/events.k8s.io/v1/events.yaml"},
+	} {
+		assert.Equal(want, ManifestCandidates(paths, "default", resourceType), resourceType)
+	}
+	assert.Equal([]string{"supportbundle_1/yamls/namespaced/other/v1/configmaps.yaml"}, ManifestCandidates(paths, "other", "cm"))
+	assert.Equal([]string{"yamls/cluster/v1/nodes.yaml"}, ManifestCandidates([]string{"yamls/cluster/v1/nodes.yaml"}, "", "no"))
+}
+
+func Test_FindObject(t *testing.T) {
+	assert := require.New(t)
+	pods := "testdata/undated/supportbundle_7e2a_undated/yamls/namespaced/default/v1/pods.yaml"
+
+	content, found, err := FindObject(pods, "virt-launcher-vm1-abcde")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(`apiVersion: v1
+kind: Pod
+metadata:
+  creationTimestamp: "2025-02-03T17:45:02Z"
+  name: virt-launcher-vm1-abcde
+  namespace: default
+spec:
+  containers:
+  - name: compute
+    env:
+    - name: NOT_A_TIMESTAMP
+      value: "creationTimestamp: 2031-01-01T00:00:00Z"
+`, content)
+
+	content, found, err = FindObject(pods, "virt-launcher-vm2-fghij")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(`apiVersion: v1
+kind: Pod
+metadata:
+  creationTimestamp: '2025-01-28T08:00:00Z'
+  name: virt-launcher-vm2-fghij
+  namespace: default
+`, content, "expected the last item to end before the kind of the list")
+
+	_, found, err = FindObject(pods, "virt-launcher-vm3")
+	assert.NoError(err)
+	assert.False(found)
+
+	// Dumps indented differently or in flow style
+	dir := t.TempDir()
+	indented := filepath.Join(dir, "indented.yaml")
+	assert.NoError(os.WriteFile(indented, []byte("items:\n  - metadata:\n      name: a\n    data:\n      key: value\n  - {metadata: {name: b}}\nkind: List\n"), 0644))
+	content, found, err = FindObject(indented, "a")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("metadata:\n  name: a\ndata:\n  key: value\n", content)
+	content, found, err = FindObject(indented, "b")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("{metadata: {name: b}}\n", content)
+
+	_, _, err = FindObject(filepath.Join(dir, "missing.yaml"), "a")
+	assert.ErrorIs(err, os.ErrNotExist)
+}
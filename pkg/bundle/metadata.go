@@ -0,0 +1,220 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const metadataFile = "metadata.yaml"
+
+// Metadata describes the cluster a support bundle was collected from. Fields are left empty
+// when the bundle doesn't carry the information.
+type Metadata struct {
+	HarvesterVersion  string
+	KubernetesVersion string
+	NodeCount         int
+	CollectedAt       *time.Time
+	// CollectedAtEstimated is set when the bundle has no collection date and CollectedAt is the
+	// newest creationTimestamp of the resources in it instead
+	CollectedAtEstimated bool
+	// ClusterName is only written by support-bundle-kit versions that know the name of the cluster
+	ClusterName string
+	// ClusterID is the UUID of the namespace support-bundle-kit ran in, it is stable for a cluster
+	ClusterID string
+}
+
+// ReadMetadata reads the metadata of a support bundle extracted to dir. Current bundles
+// (support-bundle-kit) write lowercase keys to metadata.yaml and one zip per node, older ones
+// used camelCase keys with projectVersion and one directory per node. Missing or unparseable
+// files never cause an error, the corresponding fields are just left empty.
+func ReadMetadata(dir string) Metadata {
+	return readMetadata(os.DirFS(dir))
+}
+
+// ReadZipMetadata reads the metadata of a support bundle zip without extracting it, only the
+// files holding the metadata are decompressed, plus the resource dumps when the collection date is
+// estimated. It fails only when r isn't a zip file.
+func ReadZipMetadata(r io.ReaderAt, size int64) (Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return readMetadata(zr), nil
+}
+
+func readMetadata(fsys fs.FS) Metadata {
+	var meta Metadata
+	root, ok := findBundleRoot(fsys)
+	if !ok {
+		return meta
+	}
+
+	if fields, err := readFields(fsys, path.Join(root, metadataFile)); err == nil {
+		meta.ClusterID = fields["projectnamespaceuuid"]
+		meta.KubernetesVersion = fields["kubernetesversion"]
+		meta.HarvesterVersion = fields["projectversion"]
+		meta.ClusterName = fields["clustername"]
+		for _, key := range []string{"bundlecreatedat", "createdat"} {
+			if t, err := time.Parse(time.RFC3339, fields[key]); err == nil {
+				meta.CollectedAt = &t
+				break
+			}
+		}
+	}
+
+	if version := readServerVersion(fsys, root); version != "" {
+		meta.HarvesterVersion = version
+	}
+	meta.NodeCount = countNodes(fsys, root)
+	if meta.CollectedAt == nil {
+		if t, ok := newestCreationTimestamp(fsys, root); ok {
+			meta.CollectedAt = &t
+			meta.CollectedAtEstimated = true
+		}
+	}
+	return meta
+}
+
+// findBundleRoot returns the directory holding metadata.yaml, which is either the root itself or the
+// top level directory the bundle zip was created with
+func findBundleRoot(fsys fs.FS) (string, bool) {
+	if _, err := fs.Stat(fsys, metadataFile); err == nil {
+		return ".", true
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(fsys, path.Join(entry.Name(), metadataFile)); err == nil {
+			return entry.Name(), true
+		}
+	}
+	return "", false
+}
+
+// readFields reads a flat YAML document with its keys lowercased so both key styles are handled
+func readFields(fsys fs.FS, name string) (map[string]string, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch value := v.(type) {
+		case string:
+			fields[strings.ToLower(k)] = value
+		case time.Time:
+			fields[strings.ToLower(k)] = value.Format(time.RFC3339)
+		}
+	}
+	return fields, nil
+}
+
+// resourceList is the subset of a kubectl list dump needed to read settings and count nodes
+type resourceList struct {
+	Items []struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Value string `yaml:"value"`
+	} `yaml:"items"`
+}
+
+func readResourceList(fsys fs.FS, name string) (*resourceList, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var list resourceList
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// readServerVersion returns the Harvester version from the server-version setting
+func readServerVersion(fsys fs.FS, root string) string {
+	list, err := readResourceList(fsys, path.Join(root, "yamls", "cluster", "harvesterhci.io", "v1beta1", "settings.yaml"))
+	if err != nil {
+		return ""
+	}
+	for _, item := range list.Items {
+		if item.Metadata.Name == "server-version" {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+// countNodes counts the Node objects in the bundle, falling back to the per node log archives
+func countNodes(fsys fs.FS, root string) int {
+	if list, err := readResourceList(fsys, path.Join(root, "yamls", "cluster", "v1", "nodes.yaml")); err == nil {
+		count := 0
+		for _, item := range list.Items {
+			if item.Kind == "Node" {
+				count++
+			}
+		}
+		if count > 0 {
+			return count
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, path.Join(root, "nodes"))
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// newestCreationTimestamp returns the newest creationTimestamp of the resources dumped in the bundle,
+// the bundle was collected after it. The dumps are scanned line by line as parsing every one of them
+// would take long for large clusters.
+func newestCreationTimestamp(fsys fs.FS, root string) (time.Time, bool) {
+	var newest time.Time
+	_ = fs.WalkDir(fsys, path.Join(root, "yamls"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".yaml" {
+			return nil
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "creationTimestamp:")
+			if !ok {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, strings.Trim(strings.TrimSpace(value), `"'`))
+			if err == nil && t.After(newest) {
+				newest = t
+			}
+		}
+		return nil
+	})
+	return newest, !newest.IsZero()
+}
@@ -0,0 +1,95 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadMetadataCurrentLayout(t *testing.T) {
+	assert := require.New(t)
+	meta := ReadMetadata("testdata/current")
+	assert.Equal("v1.3.2", meta.HarvesterVersion)
+	assert.Equal("v1.28.12+rke2r1", meta.KubernetesVersion)
+	assert.Equal("f159fbe2-dae7-4606-b81c-f54e1a562c99", meta.ClusterID)
+	assert.Equal(3, meta.NodeCount, "expected nodes.yaml to take precedence over the node archives")
+	assert.NotNil(meta.CollectedAt)
+	assert.True(time.Date(2024, 11, 18, 4, 34, 27, 0, time.UTC).Equal(*meta.CollectedAt))
+	assert.False(meta.CollectedAtEstimated)
+	assert.Empty(meta.ClusterName)
+}
+
+func Test_ReadMetadataLegacyLayout(t *testing.T) {
+	assert := require.New(t)
+	meta := ReadMetadata("testdata/legacy")
+	assert.Equal("v1.0.3", meta.HarvesterVersion)
+	assert.Equal("v1.21.11+rke2r1", meta.KubernetesVersion)
+	assert.Equal(2, meta.NodeCount)
+	assert.NotNil(meta.CollectedAt)
+	assert.True(time.Date(2022, 6, 2, 8, 15, 0, 0, time.UTC).Equal(*meta.CollectedAt))
+}
+
+func Test_ReadMetadataEstimatedCollectedAt(t *testing.T) {
+	assert := require.New(t)
+	meta := ReadMetadata("testdata/undated")
+	assert.Equal("rack-b", meta.ClusterName)
+	assert.Equal("v1.29.9+rke2r1", meta.KubernetesVersion)
+	assert.Equal(1, meta.NodeCount)
+	assert.NotNil(meta.CollectedAt)
+	assert.True(meta.CollectedAtEstimated)
+	assert.True(time.Date(2025, 2, 3, 17, 45, 2, 0, time.UTC).Equal(*meta.CollectedAt), "expected the newest creationTimestamp, got %s", meta.CollectedAt)
+}
+
+func Test_ReadMetadataMissing(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal(Metadata{}, ReadMetadata("testdata/broken"))
+	assert.Equal(Metadata{}, ReadMetadata("testdata/does-not-exist"))
+	assert.Equal(Metadata{}, ReadMetadata(t.TempDir()))
+}
+
+// zipDir zips the files under dir the way support-bundle-kit does, with a top level directory
+func zipDir(t *testing.T, dir string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func Test_ReadZipMetadata(t *testing.T) {
+	assert := require.New(t)
+	for _, dir := range []string{"testdata/current", "testdata/legacy", "testdata/undated"} {
+		r := zipDir(t, dir)
+		meta, err := ReadZipMetadata(r, r.Size())
+		assert.NoError(err)
+		assert.Equal(ReadMetadata(dir), meta, dir)
+	}
+
+	_, err := ReadZipMetadata(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(err)
+}
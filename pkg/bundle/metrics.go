@@ -0,0 +1,450 @@
+package bundle
+
+import (
+	"bufio"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MetricsFormatAPI is the NodeMetrics and PodMetrics objects of metrics-server, dumped by
+	// support-bundle-kit with the other resources
+	MetricsFormatAPI = "metrics-api"
+	// MetricsFormatPrometheus is a Prometheus text snapshot of the rancher-monitoring recording rules
+	MetricsFormatPrometheus = "prometheus"
+)
+
+// metricsTopPods is how many pods a summary lists as the top consumers of CPU and of memory
+const metricsTopPods = 10
+
+// MetricsSummary is the resource consumption of a cluster when its bundle was collected
+type MetricsSummary struct {
+	Format string `json:"format"`
+	// SampledAt is when the newest metric was sampled, nil when the files don't tell
+	SampledAt     *time.Time    `json:"sampledAt,omitempty"`
+	Nodes         []NodeMetrics `json:"nodes"`
+	TopCPUPods    []PodMetrics  `json:"topCPUPods"`
+	TopMemoryPods []PodMetrics  `json:"topMemoryPods"`
+}
+
+// NodeMetrics is the consumption of a node
+type NodeMetrics struct {
+	Name string `json:"name"`
+	// CPUMillicores and MemoryBytes are only known from the metrics API
+	CPUMillicores int64 `json:"cpuMillicores,omitempty"`
+	MemoryBytes   int64 `json:"memoryBytes,omitempty"`
+	// CPUPercent and MemoryPercent are of the allocatable resources with the metrics API and of the
+	// capacity with Prometheus, nil when the bundle doesn't have them
+	CPUPercent    *float64 `json:"cpuPercent,omitempty"`
+	MemoryPercent *float64 `json:"memoryPercent,omitempty"`
+}
+
+// PodMetrics is the consumption of a pod, the sum of its containers
+type PodMetrics struct {
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	CPUMillicores int64  `json:"cpuMillicores"`
+	MemoryBytes   int64  `json:"memoryBytes"`
+}
+
+// ReadMetrics summarizes the node and pod metrics of a support bundle extracted to dir. The metrics
+// API dumps are preferred over a Prometheus snapshot in the prometheus directory of the bundle. It
+// returns nil when the bundle has neither, unparseable files are skipped like missing ones.
+func ReadMetrics(dir string) *MetricsSummary {
+	return readMetrics(os.DirFS(dir))
+}
+
+func readMetrics(fsys fs.FS) *MetricsSummary {
+	root, ok := findBundleRoot(fsys)
+	if !ok {
+		return nil
+	}
+	if summary := readMetricsAPI(fsys, root); summary != nil {
+		return summary
+	}
+	return readPrometheusSnapshot(fsys, root)
+}
+
+// metricsList is the subset of a NodeMetricsList or PodMetricsList dump needed for the summary
+type metricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Timestamp  string            `yaml:"timestamp"`
+		Usage      map[string]string `yaml:"usage"`
+		Containers []struct {
+			Usage map[string]string `yaml:"usage"`
+		} `yaml:"containers"`
+	} `yaml:"items"`
+}
+
+// allocatableList is the subset of a Node list dump holding the allocatable resources
+type allocatableList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Status struct {
+			Allocatable map[string]string `yaml:"allocatable"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+func readYAML(fsys fs.FS, name string, out interface{}) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// readMetricsAPI summarizes the metrics.k8s.io dumps of the bundle
+func readMetricsAPI(fsys fs.FS, root string) *MetricsSummary {
+	summary := &MetricsSummary{Format: MetricsFormatAPI}
+	found := false
+
+	var nodes metricsList
+	if err := readYAML(fsys, path.Join(root, "yamls", "cluster", "metrics.k8s.io", "v1beta1", "nodes.yaml"), &nodes); err == nil {
+		allocatable := make(map[string]map[string]string)
+		var list allocatableList
+		if err := readYAML(fsys, path.Join(root, "yamls", "cluster", "v1", "nodes.yaml"), &list); err == nil {
+			for _, item := range list.Items {
+				allocatable[item.Metadata.Name] = item.Status.Allocatable
+			}
+		}
+
+		for _, item := range nodes.Items {
+			found = true
+			summary.observe(item.Timestamp)
+			node := NodeMetrics{Name: item.Metadata.Name}
+			node.CPUMillicores, _ = parseCPU(item.Usage["cpu"])
+			node.MemoryBytes, _ = parseMemory(item.Usage["memory"])
+			if total, ok := parseCPU(allocatable[node.Name]["cpu"]); ok && total > 0 {
+				node.CPUPercent = percent(float64(node.CPUMillicores) / float64(total))
+			}
+			if total, ok := parseMemory(allocatable[node.Name]["memory"]); ok && total > 0 {
+				node.MemoryPercent = percent(float64(node.MemoryBytes) / float64(total))
+			}
+			summary.Nodes = append(summary.Nodes, node)
+		}
+	}
+
+	podFiles, _ := fs.Glob(fsys, path.Join(root, "yamls", "namespaced", "*", "metrics.k8s.io", "v1beta1", "pods.yaml"))
+	var pods []PodMetrics
+	for _, name := range podFiles {
+		var list metricsList
+		if err := readYAML(fsys, name, &list); err != nil {
+			continue
+		}
+		for _, item := range list.Items {
+			found = true
+			summary.observe(item.Timestamp)
+			pod := PodMetrics{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+			for _, c := range item.Containers {
+				cpu, _ := parseCPU(c.Usage["cpu"])
+				memory, _ := parseMemory(c.Usage["memory"])
+				pod.CPUMillicores += cpu
+				pod.MemoryBytes += memory
+			}
+			pods = append(pods, pod)
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	summary.finish(pods)
+	return summary
+}
+
+const (
+	promNodeCPU      = "instance:node_cpu_utilisation:rate5m"
+	promNodeMemory   = "instance:node_memory_utilisation:ratio"
+	promPodCPU       = "node_namespace_pod_container:container_cpu_usage_seconds_total:sum_irate"
+	promPodMemory    = "container_memory_working_set_bytes"
+	promSnapshotDir  = "prometheus"
+	promMaxLineBytes = 1024 * 1024
+)
+
+// readPrometheusSnapshot summarizes the Prometheus text snapshots in the prometheus directory of the
+// bundle, e.g. the output of /federate for the node and container recording rules
+func readPrometheusSnapshot(fsys fs.FS, root string) *MetricsSummary {
+	files, _ := fs.Glob(fsys, path.Join(root, promSnapshotDir, "*"))
+	summary := &MetricsSummary{Format: MetricsFormatPrometheus}
+	nodes := make(map[string]*NodeMetrics)
+	pods := make(map[string]*PodMetrics)
+	found := false
+
+	node := func(labels map[string]string) *NodeMetrics {
+		name := promNodeName(labels)
+		if nodes[name] == nil {
+			nodes[name] = &NodeMetrics{Name: name}
+		}
+		return nodes[name]
+	}
+	pod := func(labels map[string]string) *PodMetrics {
+		key := labels["namespace"] + "/" + labels["pod"]
+		if pods[key] == nil {
+			pods[key] = &PodMetrics{Namespace: labels["namespace"], Name: labels["pod"]}
+		}
+		return pods[key]
+	}
+
+	for _, name := range files {
+		f, err := fsys.Open(name)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), promMaxLineBytes)
+		for scanner.Scan() {
+			sample, ok := parsePromLine(scanner.Text())
+			if !ok {
+				continue
+			}
+			// Series of the pod sandbox and the pod cgroup would count the containers twice
+			container := sample.labels["container"]
+			podSample := sample.labels["pod"] != "" && container != "" && container != "POD"
+			switch {
+			case sample.name == promNodeCPU:
+				node(sample.labels).CPUPercent = percent(sample.value)
+			case sample.name == promNodeMemory:
+				node(sample.labels).MemoryPercent = percent(sample.value)
+			case sample.name == promPodCPU && podSample:
+				pod(sample.labels).CPUMillicores += int64(math.Round(sample.value * 1000))
+			case sample.name == promPodMemory && podSample:
+				pod(sample.labels).MemoryBytes += int64(sample.value)
+			default:
+				continue
+			}
+			found = true
+			if !sample.at.IsZero() && (summary.SampledAt == nil || sample.at.After(*summary.SampledAt)) {
+				at := sample.at
+				summary.SampledAt = &at
+			}
+		}
+		f.Close()
+	}
+
+	if !found {
+		return nil
+	}
+	for _, n := range nodes {
+		summary.Nodes = append(summary.Nodes, *n)
+	}
+	var podList []PodMetrics
+	for _, p := range pods {
+		podList = append(podList, *p)
+	}
+	summary.finish(podList)
+	return summary
+}
+
+// promNodeName returns the node of a node series, node-exporter only labels them with its address
+func promNodeName(labels map[string]string) string {
+	for _, key := range []string{"node", "nodename"} {
+		if labels[key] != "" {
+			return labels[key]
+		}
+	}
+	name, _, _ := strings.Cut(labels["instance"], ":")
+	return name
+}
+
+// observe moves SampledAt to an RFC 3339 timestamp of a metrics object when it is newer
+func (m *MetricsSummary) observe(timestamp string) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err == nil && (m.SampledAt == nil || t.After(*m.SampledAt)) {
+		m.SampledAt = &t
+	}
+}
+
+// finish sorts the nodes by name and keeps the top consumers of pods
+func (m *MetricsSummary) finish(pods []PodMetrics) {
+	if m.Nodes == nil {
+		m.Nodes = []NodeMetrics{}
+	}
+	sort.Slice(m.Nodes, func(i, j int) bool { return m.Nodes[i].Name < m.Nodes[j].Name })
+	m.TopCPUPods = topPods(pods, func(p PodMetrics) int64 { return p.CPUMillicores })
+	m.TopMemoryPods = topPods(pods, func(p PodMetrics) int64 { return p.MemoryBytes })
+}
+
+// topPods returns the metricsTopPods pods using the most of what usage returns, pods using none are left out
+func topPods(pods []PodMetrics, usage func(PodMetrics) int64) []PodMetrics {
+	top := []PodMetrics{}
+	for _, p := range pods {
+		if usage(p) > 0 {
+			top = append(top, p)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		a, b := top[i], top[j]
+		if usage(a) != usage(b) {
+			return usage(a) > usage(b)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	if len(top) > metricsTopPods {
+		top = top[:metricsTopPods]
+	}
+	return top
+}
+
+// percent turns a ratio into a percentage rounded to a tenth
+func percent(ratio float64) *float64 {
+	p := math.Round(ratio*1000) / 10
+	return &p
+}
+
+var (
+	cpuSuffixes    = map[string]float64{"n": 1e-6, "u": 1e-3, "m": 1, "": 1000}
+	memorySuffixes = map[string]float64{
+		"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+		"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18, "": 1,
+	}
+)
+
+// parseCPU returns the millicores of a CPU quantity, e.g. 250m, 2 or 1534012345n
+func parseCPU(quantity string) (int64, bool) {
+	return parseQuantity(quantity, cpuSuffixes)
+}
+
+// parseMemory returns the bytes of a memory quantity, e.g. 14680064Ki, 2Gi or 1.5G
+func parseMemory(quantity string) (int64, bool) {
+	return parseQuantity(quantity, memorySuffixes)
+}
+
+// parseQuantity parses a Kubernetes quantity whose suffix is one of suffixes, which map to the unit
+// the result is in
+func parseQuantity(quantity string, suffixes map[string]float64) (int64, bool) {
+	quantity = strings.TrimSpace(quantity)
+	if quantity == "" {
+		return 0, false
+	}
+	for _, n := range []int{2, 1, 0} {
+		if len(quantity) <= n {
+			continue
+		}
+		multiplier, ok := suffixes[quantity[len(quantity)-n:]]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(quantity[:len(quantity)-n], 64)
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		return int64(math.Round(value * multiplier)), true
+	}
+	return 0, false
+}
+
+// promSample is a line of a Prometheus text snapshot
+type promSample struct {
+	name   string
+	labels map[string]string
+	value  float64
+	// at is zero when the line has no timestamp
+	at time.Time
+}
+
+// parsePromLine parses a sample line of the Prometheus text format, comments, blank and malformed
+// lines and samples that aren't numbers are reported as not ok
+func parsePromLine(line string) (promSample, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return promSample{}, false
+	}
+
+	sample := promSample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return promSample{}, false
+	}
+	sample.name, line = line[:end], line[end:]
+
+	if line[0] == '{' {
+		rest, ok := parsePromLabels(line[1:], sample.labels)
+		if !ok {
+			return promSample{}, false
+		}
+		line = rest
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return promSample{}, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return promSample{}, false
+	}
+	sample.value = value
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return promSample{}, false
+		}
+		sample.at = time.UnixMilli(ms).UTC()
+	}
+	return sample, true
+}
+
+// parsePromLabels parses the labels after the opening brace into labels and returns what follows
+// the closing one
+func parsePromLabels(s string, labels map[string]string) (string, bool) {
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return "", false
+		}
+		if s[0] == '}' {
+			return s[1:], true
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return "", false
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			if c == '"' {
+				s = s[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return "", false
+		}
+		labels[key] = value.String()
+	}
+}
@@ -0,0 +1,100 @@
+package bundle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func Test_ReadMetricsAPI(t *testing.T) {
+	assert := require.New(t)
+	summary := ReadMetrics("testdata/metrics-api")
+	assert.NotNil(summary)
+	assert.Equal(MetricsFormatAPI, summary.Format)
+	assert.NotNil(summary.SampledAt)
+	assert.True(time.Date(2025, 3, 4, 9, 12, 21, 0, time.UTC).Equal(*summary.SampledAt))
+
+	assert.Equal([]NodeMetrics{
+		{Name: "harvester-01", CPUMillicores: 4000, MemoryBytes: 16 << 30, CPUPercent: ptr(25), MemoryPercent: ptr(25)},
+		{Name: "harvester-02", CPUMillicores: 13950, MemoryBytes: 58 << 30, CPUPercent: ptr(90), MemoryPercent: ptr(90.6)},
+		// no allocatable resources in nodes.yaml
+		{Name: "harvester-03", CPUMillicores: 2000, MemoryBytes: 8 << 30},
+	}, summary.Nodes)
+
+	db := PodMetrics{Namespace: "default", Name: "virt-launcher-db-01-7xk2p", CPUMillicores: 7891, MemoryBytes: 32<<30 + 2<<20}
+	web := PodMetrics{Namespace: "default", Name: "virt-launcher-web-01-9fj4d", CPUMillicores: 250, MemoryBytes: 4 << 30}
+	harvester := PodMetrics{Namespace: "harvester-system", Name: "harvester-6c8d9f7b5-qwz8l", CPUMillicores: 1200, MemoryBytes: 1536 << 20}
+	assert.Equal([]PodMetrics{db, harvester, web}, summary.TopCPUPods)
+	assert.Equal([]PodMetrics{db, web, harvester}, summary.TopMemoryPods, "expected idle pods to be left out")
+}
+
+func Test_ReadMetricsPrometheus(t *testing.T) {
+	assert := require.New(t)
+	summary := ReadMetrics("testdata/prometheus")
+	assert.NotNil(summary)
+	assert.Equal(MetricsFormatPrometheus, summary.Format)
+	assert.NotNil(summary.SampledAt)
+	assert.Equal(time.UnixMilli(1683727318000).UTC(), *summary.SampledAt)
+
+	// the node without a node label is named by the address of its node-exporter
+	assert.Equal([]NodeMetrics{
+		{Name: "10.52.0.12", CPUPercent: ptr(5.1), MemoryPercent: ptr(40.2)},
+		{Name: "harvester-a", CPUPercent: ptr(23.5), MemoryPercent: ptr(93.1)},
+	}, summary.Nodes)
+
+	bigMem := PodMetrics{Namespace: "default", Name: "virt-launcher-big-mem-5tq8z", CPUMillicores: 1500, MemoryBytes: 62e9}
+	exporter := PodMetrics{Namespace: "cattle-monitoring-system", Name: "rancher-monitoring-prometheus-node-exporter-x7k2m", CPUMillicores: 12, MemoryBytes: 24e6}
+	assert.Equal([]PodMetrics{bigMem, exporter}, summary.TopCPUPods)
+	assert.Equal([]PodMetrics{bigMem, exporter, {Namespace: "default", Name: "label-escapes", MemoryBytes: 1024}}, summary.TopMemoryPods,
+		"expected the pod and sandbox series not to be counted")
+}
+
+func Test_ReadMetricsMissing(t *testing.T) {
+	assert := require.New(t)
+	assert.Nil(ReadMetrics("testdata/current"))
+	assert.Nil(ReadMetrics("testdata/legacy"))
+	assert.Nil(ReadMetrics("testdata/does-not-exist"))
+}
+
+func Test_ParseQuantity(t *testing.T) {
+	assert := require.New(t)
+	for quantity, millicores := range map[string]int64{"250m": 250, "2": 2000, "1.5": 1500, "1534012345n": 1534, "12500u": 13, "0": 0} {
+		got, ok := parseCPU(quantity)
+		assert.True(ok, quantity)
+		assert.Equal(millicores, got, quantity)
+	}
+	for quantity, bytes := range map[string]int64{"14680064Ki": 14680064 << 10, "2Gi": 2 << 30, "1.5G": 1500000000, "128974848": 128974848, "1e3": 1000} {
+		got, ok := parseMemory(quantity)
+		assert.True(ok, quantity)
+		assert.Equal(bytes, got, quantity)
+	}
+	for _, quantity := range []string{"", "abc", "-1", "12Xi"} {
+		_, ok := parseMemory(quantity)
+		assert.False(ok, quantity)
+	}
+}
+
+func Test_ParsePromLine(t *testing.T) {
+	assert := require.New(t)
+	sample, ok := parsePromLine(`up{job="a\\b",note="say \"hi\"\n"} 1 1700000000000`)
+	assert.True(ok)
+	assert.Equal("up", sample.name)
+	assert.Equal(map[string]string{"job": `a\b`, "note": "say \"hi\"\n"}, sample.labels)
+	assert.Equal(1.0, sample.value)
+	assert.Equal(time.UnixMilli(1700000000000).UTC(), sample.at)
+
+	sample, ok = parsePromLine("process_open_fds 27")
+	assert.True(ok)
+	assert.Empty(sample.labels)
+	assert.True(sample.at.IsZero())
+
+	for _, line := range []string{"", "# HELP up", `up{job="a"`, `up{job=a} 1`, "up", "up NaN", "up 1 2 3", "up 1 soon"} {
+		_, ok := parsePromLine(line)
+		assert.False(ok, line)
+	}
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// ProgressFunc is called while uploading with the number of bytes sent so far and the total to send
+type ProgressFunc func(sent, total int64)
+
+// Client talks to a running sim-gui server over its HTTP API
+type Client struct {
+	server string
+	// token is sent as a bearer token when it isn't empty, servers with a tokens file require one
+	token string
+	http  *http.Client
+}
+
+// NewClient creates a Client for the server at the given base URL, e.g. http://localhost:8080
+func NewClient(server string) *Client {
+	return &Client{
+		server: strings.TrimSuffix(server, "/"),
+		http:   &http.Client{},
+	}
+}
+
+// SetToken sets the token sent with every request, empty sends none
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// ListWorkspaces returns all workspaces
+func (c *Client) ListWorkspaces() ([]model.Workspace, error) {
+	var workspaces []model.Workspace
+	if err := c.doJSON("GET", "/api/workspaces", nil, &workspaces); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// CreateWorkspace creates a new workspace with the given name
+func (c *Client) CreateWorkspace(name string) (*model.Workspace, error) {
+	var ws model.Workspace
+	if err := c.doJSON("POST", "/api/workspaces", map[string]string{"name": name}, &ws); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// DeleteWorkspace deletes a workspace along with all of its versions
+func (c *Client) DeleteWorkspace(name string) error {
+	return c.doJSON("DELETE", "/api/workspaces/"+url.PathEscape(name), nil, nil)
+}
+
+// UploadVersion uploads a support bundle or kubeconfig as a new version of the workspace.
+// Multiple paths are treated as the parts of a split support bundle.
+func (c *Client) UploadVersion(workspace string, paths []string, progress ProgressFunc) error {
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		total += info.Size()
+	}
+
+	// Stream the multipart body so large bundles are never held in memory
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeFiles(mw, paths, total, progress))
+	}()
+
+	req, err := c.newRequest("POST", "/api/workspaces/"+url.PathEscape(workspace)+"/versions", pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// StartSimulator starts the simulator of a workspace version
+func (c *Client) StartSimulator(workspace, versionID string) error {
+	return c.doJSON("POST", versionPath(workspace, versionID)+"/start", nil, nil)
+}
+
+// StopSimulator stops the simulator of a workspace version
+func (c *Client) StopSimulator(workspace, versionID string) error {
+	return c.doJSON("POST", versionPath(workspace, versionID)+"/stop", nil, nil)
+}
+
+// ResetSimulator restarts the running simulator of a workspace version to load its bundle again
+func (c *Client) ResetSimulator(workspace, versionID string) error {
+	return c.doJSON("POST", versionPath(workspace, versionID)+"/reset", nil, nil)
+}
+
+// GetKubeconfig returns the kubeconfig for a running simulator of a workspace version
+func (c *Client) GetKubeconfig(workspace, versionID string) ([]byte, error) {
+	resp, err := c.do("GET", versionPath(workspace, versionID)+"/kubeconfig", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// doJSON sends body encoded as JSON and decodes the response into out when out is not nil
+func (c *Client) doJSON(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	resp, err := c.do(method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// newRequest creates a request to path on the server carrying the token
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// do sends a request and returns the response if the server reported success
+func (c *Client) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkResponse turns a non 2xx response into an error carrying the message written by http.Error
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	message, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+// writeFiles writes every path as a "file" form part, reporting progress as the file contents are sent
+func writeFiles(mw *multipart.Writer, paths []string, total int64, progress ProgressFunc) error {
+	var sent int64
+	for _, path := range paths {
+		part, err := mw.CreateFormFile("file", filepath.Base(path))
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, &progressReader{
+			reader: f,
+			onRead: func(n int) {
+				sent += int64(n)
+				if progress != nil {
+					progress(sent, total)
+				}
+			},
+		})
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func versionPath(workspace, versionID string) string {
+	return fmt.Sprintf("/api/workspaces/%s/versions/%s", url.PathEscape(workspace), url.PathEscape(versionID))
+}
+
+// progressReader calls onRead with the size of every read from reader
+type progressReader struct {
+	reader io.Reader
+	onRead func(n int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.onRead(n)
+	}
+	return n, err
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Workspaces(t *testing.T) {
+	assert := require.New(t)
+	var created string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/workspaces":
+			json.NewEncoder(w).Encode([]model.Workspace{{Name: "demo"}})
+		case "POST /api/workspaces":
+			var req struct {
+				Name string `json:"name"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			created = req.Name
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(model.Workspace{Name: req.Name})
+		case "DELETE /api/workspaces/missing":
+			http.Error(w, "workspace not found", http.StatusNotFound)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL + "/")
+	workspaces, err := c.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(workspaces, 1)
+	assert.Equal("demo", workspaces[0].Name)
+
+	ws, err := c.CreateWorkspace("new")
+	assert.NoError(err)
+	assert.Equal("new", ws.Name)
+	assert.Equal("new", created)
+
+	err = c.DeleteWorkspace("missing")
+	assert.Error(err)
+	assert.Contains(err.Error(), "404")
+	assert.Contains(err.Error(), "workspace not found")
+}
+
+func Test_UploadVersionSplitBundle(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	parts := []string{filepath.Join(dir, "bundle.zip.aa"), filepath.Join(dir, "bundle.zip.ab")}
+	assert.NoError(os.WriteFile(parts[0], []byte("first"), 0644))
+	assert.NoError(os.WriteFile(parts[1], []byte("second"), 0644))
+
+	received := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/api/workspaces/demo/versions", r.URL.Path)
+		assert.NoError(r.ParseMultipartForm(1 << 20))
+		for _, fh := range r.MultipartForm.File["file"] {
+			f, err := fh.Open()
+			assert.NoError(err)
+			data, _ := io.ReadAll(f)
+			f.Close()
+			received[fh.Filename] = string(data)
+		}
+	}))
+	defer srv.Close()
+
+	var sent, total int64
+	err := NewClient(srv.URL).UploadVersion("demo", parts, func(s, t int64) {
+		sent, total = s, t
+	})
+	assert.NoError(err)
+	assert.Equal(map[string]string{"bundle.zip.aa": "first", "bundle.zip.ab": "second"}, received)
+	assert.Equal(int64(11), total)
+	assert.Equal(total, sent)
+}
+
+func Test_GetKubeconfig(t *testing.T) {
+	assert := require.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/workspaces/demo/versions/v1/kubeconfig":
+			w.Write([]byte("apiVersion: v1"))
+		default:
+			http.Error(w, "Simulator not running", http.StatusConflict)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	data, err := c.GetKubeconfig("demo", "v1")
+	assert.NoError(err)
+	assert.Equal("apiVersion: v1", string(data))
+
+	_, err = c.GetKubeconfig("demo", "v2")
+	assert.Error(err)
+	assert.Contains(err.Error(), "Simulator not running")
+}
+
+func Test_Token(t *testing.T) {
+	assert := require.New(t)
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "A valid token is required", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode([]model.Workspace{})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	_, err := c.ListWorkspaces()
+	assert.ErrorContains(err, "401")
+
+	// every request carries the token, uploads included
+	c.SetToken("s3cret")
+	_, err = c.ListWorkspaces()
+	assert.NoError(err)
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	assert.NoError(os.WriteFile(path, []byte("apiVersion: v1"), 0644))
+	assert.NoError(c.UploadVersion("demo", []string{path}, nil))
+	assert.Equal([]string{"", "Bearer s3cret", "Bearer s3cret"}, auth)
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Yu-Jack/sim-gui/pkg/client"
+	"github.com/bndr/gotabulate"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// tokenEnv holds the token of the client commands when --token isn't set
+const tokenEnv = "SIM_GUI_TOKEN"
+
+var (
+	// apiServer is the address of the sim-gui server used by the client commands
+	apiServer string
+	// apiToken is sent to servers requiring tokens
+	apiToken       string
+	kubeconfigPath string
+)
+
+// define client sub commands, these talk to a running sim-gui server instead of the docker daemon
+func init() {
+	for _, c := range []*cobra.Command{workspaceCmd, simCmd, kubeconfigCmd, versionCmd} {
+		c.PersistentFlags().StringVar(&apiServer, "server", "http://localhost:8080", "address of the sim-gui server")
+		c.PersistentFlags().StringVar(&apiToken, "token", "", "token for a sim-gui server requiring tokens (defaults to $"+tokenEnv+")")
+		rootCmd.AddCommand(c)
+	}
+	workspaceCmd.AddCommand(workspaceListCmd)
+	workspaceCmd.AddCommand(workspaceCreateCmd)
+	workspaceCmd.AddCommand(workspaceDeleteCmd)
+	versionCmd.AddCommand(versionUploadCmd)
+	simCmd.AddCommand(simStartCmd)
+	simCmd.AddCommand(simStopCmd)
+	simCmd.AddCommand(simResetCmd)
+	kubeconfigCmd.Flags().StringVarP(&kubeconfigPath, "output", "o", "", "file to write the kubeconfig to (defaults to stdout)")
+}
+
+// clientPreRun replaces the root docker client setup for commands which only talk to the sim-gui server
+func clientPreRun(cmd *cobra.Command, args []string) error {
+	if verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	return nil
+}
+
+// newAPIClient creates the client of the sim-gui server with the token of --token or the environment
+func newAPIClient() *client.Client {
+	c := client.NewClient(apiServer)
+	token := apiToken
+	if token == "" {
+		token = os.Getenv(tokenEnv)
+	}
+	c.SetToken(token)
+	return c
+}
+
+var workspaceCmd = &cobra.Command{
+	Use:               "workspace",
+	Short:             "manage workspaces on a sim-gui server",
+	PersistentPreRunE: clientPreRun,
+}
+
+var workspaceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list workspaces",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workspaces, err := newAPIClient().ListWorkspaces()
+		if err != nil {
+			return err
+		}
+
+		var results [][]interface{}
+		// gotabulate does no handle empty table and panics
+		if len(workspaces) == 0 {
+			results = append(results, []interface{}{"", "", "", ""})
+		}
+		for _, ws := range workspaces {
+			versions := make([]string, 0, len(ws.Versions))
+			for _, v := range ws.Versions {
+				versions = append(versions, v.ID)
+			}
+			results = append(results, []interface{}{ws.Name, ws.DisplayName, fmt.Sprintf("%v", versions), ws.CreatedAt.Format("2006-01-02 15:04:05")})
+		}
+		table := gotabulate.Create(results)
+		table.SetHeaders([]string{"name", "displayName", "versions", "created"})
+		table.SetEmptyString("None")
+		table.SetAlign("right")
+		table.SetMaxCellSize(40)
+		table.SetWrapStrings(true)
+		fmt.Println(table.Render("grid"))
+		return nil
+	},
+}
+
+var workspaceCreateCmd = &cobra.Command{
+	Use:   "create <workspace>",
+	Short: "create a workspace",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := newAPIClient().CreateWorkspace(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("workspace %s created\n", ws.Name)
+		return nil
+	},
+}
+
+var workspaceDeleteCmd = &cobra.Command{
+	Use:   "delete <workspace>",
+	Short: "delete a workspace and all of its versions",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newAPIClient().DeleteWorkspace(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("workspace %s deleted\n", args[0])
+		return nil
+	},
+}
+
+var versionUploadCmd = &cobra.Command{
+	Use:   "upload <workspace> <file...>",
+	Short: "upload a support bundle or kubeconfig as a new version",
+	Long: `upload a support bundle or kubeconfig as a new version of the workspace. When multiple files are
+given they are treated as the parts of a split support bundle and joined in name order by the server`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := newAPIClient().UploadVersion(args[0], args[1:], printProgress)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("uploaded %d file(s) to workspace %s\n", len(args)-1, args[0])
+		return nil
+	},
+}
+
+var simCmd = &cobra.Command{
+	Use:               "sim",
+	Short:             "start, stop and reset simulators on a sim-gui server",
+	PersistentPreRunE: clientPreRun,
+}
+
+var simStartCmd = &cobra.Command{
+	Use:   "start <workspace> <version>",
+	Short: "start the simulator of a version",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newAPIClient().StartSimulator(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("simulator %s-%s started\n", args[0], args[1])
+		return nil
+	},
+}
+
+var simStopCmd = &cobra.Command{
+	Use:   "stop <workspace> <version>",
+	Short: "stop the simulator of a version",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newAPIClient().StopSimulator(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("simulator %s-%s stopped\n", args[0], args[1])
+		return nil
+	},
+}
+
+var simResetCmd = &cobra.Command{
+	Use:   "reset <workspace> <version>",
+	Short: "reset the running simulator of a version to its support bundle",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newAPIClient().ResetSimulator(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("simulator %s-%s reset\n", args[0], args[1])
+		return nil
+	},
+}
+
+var kubeconfigCmd = &cobra.Command{
+	Use:               "kubeconfig <workspace> <version>",
+	Short:             "download the kubeconfig of a running simulator",
+	Args:              cobra.ExactArgs(2),
+	PersistentPreRunE: clientPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := newAPIClient().GetKubeconfig(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if kubeconfigPath == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(kubeconfigPath, data, 0600)
+	},
+}
+
+// printProgress renders a single line progress bar on stderr
+func printProgress(sent, total int64) {
+	const width = 40
+	percent := 100
+	if total > 0 {
+		percent = int(sent * 100 / total)
+	}
+	filled := percent * width / 100
+	bar := make([]byte, width)
+	for i := range bar {
+		if i < filled {
+			bar[i] = '='
+		} else {
+			bar[i] = ' '
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %3d%% %.1f/%.1f MB", bar, percent, float64(sent)/(1<<20), float64(total)/(1<<20))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
 	"github.com/Yu-Jack/sim-gui/pkg/kubeconfig"
 	"github.com/sirupsen/logrus"
 )
@@ -51,7 +52,7 @@ func (s *Simulator) CreateNewInstance() error {
 	}
 
 	//run newly create image
-	if err := s.DockerClient.RunContainer(s.Name, s.BundlePath); err != nil {
+	if err := s.DockerClient.RunContainer(s.Name, docker.Owner{}, s.BundlePath, "", nil, docker.Extras{}); err != nil {
 		return fmt.Errorf("error running new image: %w", err)
 	}
 
@@ -64,7 +65,11 @@ func (s *Simulator) CreateNewInstance() error {
 		return fmt.Errorf("expected to find only 1 running container but found %d", len(containers))
 	}
 
-	s.Port = int(containers[0].Ports[0].PublicPort)
+	port, err := docker.APIServerPublicPort(containers[0].Ports)
+	if err != nil {
+		return fmt.Errorf("error finding exposed port of %s: %w", s.Name, err)
+	}
+	s.Port = int(port)
 	logrus.WithField("name", s.Name).Infof("simulator instance exposed on port %d", s.Port)
 	return nil
 }
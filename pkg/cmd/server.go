@@ -1,20 +1,83 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
 	"github.com/Yu-Jack/sim-gui/pkg/server"
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
 	"github.com/spf13/cobra"
 )
 
 var (
-	serverAddr string
-	dataDir    string
-	dev        bool
+	serverAddr          string
+	dataDir             string
+	profiles            []string
+	dev                 bool
+	devServerURL        string
+	basePath            string
+	externalURL         string
+	trustForwarded      bool
+	updateCheckInterval time.Duration
+	disableUpdateCheck  bool
+	githubToken         string
+	kubectlPath         string
+	maxUploadSize       int64
+	uploadBufferSize    int
+	maxExtractSize      int64
+	maxExtractFiles     int
+	maxExtractRatio     int64
+	activityMaxEntries  int
+	retentionDays       int
+	trashDays           int
+	maxConcurrentStarts int
+	rateLimit           float64
+	rateLimitBurst      int
+	userHeader          string
+	importRoot          string
+	storeType           string
+	storeWriteDelay     time.Duration
+	bundleStore         string
+	s3Options           bundlestore.S3Options
 )
 
 func init() {
 	serverCmd.Flags().StringVar(&serverAddr, "addr", ":8080", "address to listen on")
 	serverCmd.Flags().StringVar(&dataDir, "data-dir", "./data", "directory to store data")
-	serverCmd.Flags().BoolVar(&dev, "dev", false, "enable dev mode (do not serve static files)")
+	serverCmd.Flags().StringArrayVar(&profiles, "profile", nil, "serve a data directory as a profile, name=path, repeat it for more profiles (replaces --data-dir, the first profile is the default)")
+	serverCmd.Flags().BoolVar(&dev, "dev", false, "enable dev mode (proxy the UI to the frontend dev server instead of serving static files)")
+	serverCmd.Flags().StringVar(&devServerURL, "dev-server-url", "http://localhost:5173", "frontend dev server to proxy the UI to in dev mode")
+	serverCmd.Flags().StringVar(&basePath, "base-path", "", "path prefix to serve the UI and API under behind a reverse proxy, e.g. /tools/sim-gui")
+	serverCmd.Flags().StringVar(&externalURL, "external-url", "", "URL clients reach the server at behind a reverse proxy, e.g. https://sim.example.com/tools/sim-gui, absolute links are built from it (defaults to the address of each request)")
+	serverCmd.Flags().BoolVar(&trustForwarded, "trust-forwarded-headers", false, "build absolute links from the X-Forwarded-Host and X-Forwarded-Proto headers of requests and limit clients by X-Forwarded-For, only enable it behind a reverse proxy that sets them")
+	serverCmd.Flags().DurationVar(&updateCheckInterval, "update-check-interval", time.Hour, "interval between update checks (0 checks only at startup)")
+	serverCmd.Flags().BoolVar(&disableUpdateCheck, "disable-update-check", false, "disable checking for updates")
+	serverCmd.Flags().StringVar(&githubToken, "github-token", "", "GitHub API token used for update checks (defaults to $GITHUB_TOKEN)")
+	serverCmd.Flags().StringVar(&kubectlPath, "kubectl-path", "", "kubectl binary used to query runtime clusters (defaults to kubectl in PATH)")
+	serverCmd.Flags().Int64Var(&maxUploadSize, "max-upload-size", 10<<30, "maximum size of a version upload request in bytes (0 disables the limit)")
+	serverCmd.Flags().IntVar(&uploadBufferSize, "upload-buffer-size", 1<<20, "memory in bytes used per upload to stream files to disk")
+	serverCmd.Flags().Int64Var(&maxExtractSize, "max-extract-size", 100<<30, "maximum bytes extracted from a bundle, its nested archives included (0 disables the limit)")
+	serverCmd.Flags().IntVar(&maxExtractFiles, "max-extract-files", 1000000, "maximum number of files extracted from a bundle (0 disables the limit)")
+	serverCmd.Flags().Int64Var(&maxExtractRatio, "max-extract-ratio", 1000, "maximum times a file of at least 1 MiB may be larger than compressed in a bundle (0 disables the limit)")
+	serverCmd.Flags().IntVar(&activityMaxEntries, "activity-max-entries", 500, "number of activity feed entries kept per workspace")
+	serverCmd.Flags().StringVar(&importRoot, "import-root", "", "directory versions can be imported from by path on the server (empty disables imports from a path)")
+	serverCmd.Flags().StringVar(&storeType, "store", "json", "where workspaces are kept: json (data.json in the data directory) or memory (lost when the server stops)")
+	serverCmd.Flags().DurationVar(&storeWriteDelay, "store-write-delay", time.Second, "how long updates of the json store are held back to be written together, the server writes them when it stops (0 writes each one)")
+	serverCmd.Flags().StringVar(&bundleStore, "bundle-store", "local", "where bundle archives are kept: local (the data directory) or s3")
+	serverCmd.Flags().StringVar(&s3Options.Endpoint, "s3-endpoint", "", "endpoint of the S3-compatible service of the s3 bundle store, e.g. http://minio:9000")
+	serverCmd.Flags().StringVar(&s3Options.Bucket, "s3-bucket", "", "bucket of the s3 bundle store")
+	serverCmd.Flags().StringVar(&s3Options.Region, "s3-region", "us-east-1", "region of the s3 bundle store bucket")
+	serverCmd.Flags().StringVar(&s3Options.AccessKey, "s3-access-key", "", "access key of the s3 bundle store (defaults to $AWS_ACCESS_KEY_ID)")
+	serverCmd.Flags().StringVar(&s3Options.SecretKey, "s3-secret-key", "", "secret key of the s3 bundle store (defaults to $AWS_SECRET_ACCESS_KEY)")
+	serverCmd.Flags().StringVar(&s3Options.Prefix, "s3-prefix", "", "prefix of the object keys of the s3 bundle store, e.g. sim-gui/")
+	serverCmd.Flags().StringVar(&s3Options.CacheDir, "bundle-cache-dir", "", "directory bundles fetched from the s3 bundle store are cached in (defaults to bundle-cache in the data directory)")
+	serverCmd.Flags().Int64Var(&s3Options.CacheSize, "bundle-cache-size", 20<<30, "bytes of bundles kept in the bundle cache (0 disables the limit)")
+	serverCmd.Flags().IntVar(&retentionDays, "retention-days", 0, "days versions are kept after their upload unless their workspace sets its own retention (0 keeps them forever)")
+	serverCmd.Flags().IntVar(&trashDays, "trash-days", 7, "days deleted workspaces and versions are kept in the trash before they are removed for good (0 keeps them until restored)")
+	serverCmd.Flags().IntVar(&maxConcurrentStarts, "max-concurrent-starts", 2, "simulators started at once, further starts wait until one is ready or failed (0 doesn't limit them)")
+	serverCmd.Flags().Float64Var(&rateLimit, "rate-limit", 5, "requests per second each client may make to the expensive endpoints, resource queries and uploads (0 doesn't limit them, local clients aren't limited without tokens)")
+	serverCmd.Flags().IntVar(&rateLimitBurst, "rate-limit-burst", 20, "requests a client may make at once to the expensive endpoints before rate-limit applies")
+	serverCmd.Flags().StringVar(&userHeader, "user-header", "", "header an authenticating proxy sets to the user, recorded as the creator of workspaces and versions (only behind a proxy that overwrites it)")
 	rootCmd.AddCommand(serverCmd)
 }
 
@@ -22,6 +85,53 @@ var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start the diagnostic UI server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return server.Run(serverAddr, dataDir, dev)
+		if githubToken == "" {
+			githubToken = os.Getenv("GITHUB_TOKEN")
+		}
+		if s3Options.AccessKey == "" {
+			s3Options.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if s3Options.SecretKey == "" {
+			s3Options.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		var serverProfiles []server.Profile
+		for _, value := range profiles {
+			profile, err := server.ParseProfile(value)
+			if err != nil {
+				return err
+			}
+			serverProfiles = append(serverProfiles, profile)
+		}
+		return server.Run(server.Options{
+			Addr:                  serverAddr,
+			DataDir:               dataDir,
+			Profiles:              serverProfiles,
+			Dev:                   dev,
+			DevServerURL:          devServerURL,
+			BasePath:              basePath,
+			ExternalURL:           externalURL,
+			TrustForwardedHeaders: trustForwarded,
+			UpdateCheckInterval:   updateCheckInterval,
+			DisableUpdateCheck:    disableUpdateCheck,
+			GitHubToken:           githubToken,
+			KubectlPath:           kubectlPath,
+			MaxUploadSize:         maxUploadSize,
+			UploadBufferSize:      uploadBufferSize,
+			MaxExtractSize:        maxExtractSize,
+			MaxExtractFiles:       maxExtractFiles,
+			MaxExtractRatio:       maxExtractRatio,
+			ActivityMaxEntries:    activityMaxEntries,
+			RetentionDays:         retentionDays,
+			TrashDays:             trashDays,
+			MaxConcurrentStarts:   maxConcurrentStarts,
+			RateLimit:             rateLimit,
+			RateLimitBurst:        rateLimitBurst,
+			UserHeader:            userHeader,
+			ImportRoot:            importRoot,
+			Store:                 storeType,
+			StoreWriteDelay:       storeWriteDelay,
+			BundleStore:           bundleStore,
+			S3:                    s3Options,
+		})
 	},
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "print build information",
+	// version should work without a reachable docker daemon, so skip the root docker client setup
+	PersistentPreRunE: clientPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := version.Get()
+		fmt.Printf("Version:        %s\n", info.Version)
+		fmt.Printf("Git Commit:     %s\n", info.GitCommit)
+		fmt.Printf("Build Date:     %s\n", info.BuildDate)
+		fmt.Printf("Go Version:     %s\n", info.GoVersion)
+		fmt.Printf("Platform:       %s\n", info.Platform)
+		fmt.Printf("Docker Version: %s\n", dockerServerVersion())
+		return nil
+	},
+}
+
+// dockerServerVersion returns the version of the docker daemon or a description of why it is unavailable
+func dockerServerVersion() string {
+	dockerClient, err := docker.NewClient(context.TODO())
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+	defer dockerClient.Close()
+
+	serverVersion, err := dockerClient.ServerVersion()
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+	return serverVersion
+}
@@ -2,6 +2,10 @@ package docker
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
 )
 
 // Cleaner handles cleanup operations for containers and images
@@ -16,22 +20,125 @@ func NewCleaner(docker *Client) *Cleaner {
 	}
 }
 
-// CleanInstance cleans containers and images for a specific instance
-func (c *Cleaner) CleanInstance(instanceName string) error {
-	// Stop container if running
-	if err := c.docker.StopContainer(instanceName); err != nil {
-		return fmt.Errorf("failed to stop container: %w", err)
+// CleanItem is a container or image cleaning an instance removes. Removed and Error are only set
+// once the plan was executed.
+type CleanItem struct {
+	ID string `json:"id"`
+	// Name is the name of a container or the tag of an image
+	Name string `json:"name"`
+	// Running containers are stopped before they are removed
+	Running bool `json:"running,omitempty"`
+	// Size of an image in bytes, layers it shares with other images are only freed with the last of them
+	Size    int64  `json:"size,omitempty"`
+	Removed bool   `json:"removed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CleanPlan is what cleaning an instance stops and removes
+type CleanPlan struct {
+	Instance   string      `json:"instance"`
+	Containers []CleanItem `json:"containers"`
+	Images     []CleanItem `json:"images"`
+	// ReclaimableBytes adds up the sizes of the images
+	ReclaimableBytes int64 `json:"reclaimableBytes"`
+}
+
+// Err joins the errors of the items that couldn't be removed, nil when all were
+func (p *CleanPlan) Err() error {
+	var errs []string
+	for _, items := range [][]CleanItem{p.Containers, p.Images} {
+		for _, item := range items {
+			if item.Error != "" {
+				errs = append(errs, item.Error)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// Plan lists the containers and images of an instance without changing them
+func (c *Cleaner) Plan(instanceName string) (*CleanPlan, error) {
+	plan := &CleanPlan{Instance: instanceName, Containers: []CleanItem{}, Images: []CleanItem{}}
+
+	containers, err := c.docker.FindContainer(instanceName)
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
 	}
+	// The name filter matches substrings, ws-v1 would match ws-v10 as well
+	for _, v := range containers {
+		for _, name := range v.Names {
+			if strings.TrimPrefix(name, "/") == instanceName {
+				plan.Containers = append(plan.Containers, CleanItem{ID: v.ID, Name: instanceName, Running: v.State == "running"})
+				break
+			}
+		}
+	}
+
+	images, err := c.docker.FindImages(instanceName)
+	if err != nil {
+		return nil, fmt.Errorf("error listing images of %s: %w", instanceName, err)
+	}
+	for _, v := range images {
+		name := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
+		if len(v.RepoTags) > 0 {
+			name = v.RepoTags[0]
+		}
+		plan.Images = append(plan.Images, CleanItem{ID: v.ID, Name: name, Size: v.Size})
+		plan.ReclaimableBytes += v.Size
+	}
+	return plan, nil
+}
 
-	// Remove all containers (including stopped ones)
-	if err := c.docker.RemoveContainer(instanceName); err != nil {
-		return fmt.Errorf("failed to remove containers: %w", err)
+// Execute stops and removes what the plan lists, recording the outcome on each item. Items that
+// fail don't stop the others from being removed.
+func (c *Cleaner) Execute(plan *CleanPlan) {
+	api := c.docker.APIClient
+	for i := range plan.Containers {
+		item := &plan.Containers[i]
+		var stopErr error
+		if item.Running {
+			stopErr = api.ContainerStop(c.docker.ctx, item.ID, container.StopOptions{Signal: "SIGKILL"})
+		}
+		// The removal is forced, a container that failed to stop is killed by it
+		if err := api.ContainerRemove(c.docker.ctx, item.ID, container.RemoveOptions{Force: true}); err != nil {
+			item.Error = fmt.Sprintf("error removing container %s: %v", item.ID, err)
+			if stopErr != nil {
+				item.Error = fmt.Sprintf("error stopping container %s: %v; %s", item.ID, stopErr, item.Error)
+			}
+			continue
+		}
+		item.Removed = true
 	}
 
-	// Remove images
-	if err := c.docker.RemoveImages(instanceName); err != nil {
-		return fmt.Errorf("failed to remove images: %w", err)
+	for i := range plan.Images {
+		item := &plan.Images[i]
+		if _, err := api.ImageRemove(c.docker.ctx, item.ID, image.RemoveOptions{Force: true, PruneChildren: true}); err != nil {
+			item.Error = fmt.Sprintf("error removing image %s: %v", item.ID, err)
+			continue
+		}
+		item.Removed = true
 	}
+}
 
-	return nil
+// CleanInstance cleans containers and images for a specific instance
+func (c *Cleaner) CleanInstance(instanceName string) error {
+	_, err := c.Clean(instanceName, false)
+	return err
+}
+
+// Clean stops and removes the containers and images of an instance, with dryRun it only returns
+// what would be removed
+func (c *Cleaner) Clean(instanceName string, dryRun bool) (*CleanPlan, error) {
+	plan, err := c.Plan(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return plan, nil
+	}
+	c.Execute(plan)
+	return plan, plan.Err()
 }
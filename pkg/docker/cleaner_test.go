@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The name filter of the container list also matches ws-v10
+var cleanerResponses = map[string]string{
+	"/containers/json": `[
+		{"Id": "other", "Names": ["/ws-v10"], "State": "running"},
+		{"Id": "abc", "Names": ["/ws-v1"], "State": "running"}
+	]`,
+	"/images/json": `[{"Id": "sha256:img", "RepoTags": ["sim-cli-managed:ws-v1"], "Size": 3000}]`,
+}
+
+func Test_CleanerDryRun(t *testing.T) {
+	assert := require.New(t)
+	c, requests := newRecordingClient(t, "", cleanerResponses)
+
+	plan, err := NewCleaner(c).Clean("ws-v1", true)
+	assert.NoError(err)
+	assert.Equal(&CleanPlan{
+		Instance:         "ws-v1",
+		Containers:       []CleanItem{{ID: "abc", Name: "ws-v1", Running: true}},
+		Images:           []CleanItem{{ID: "sha256:img", Name: "sim-cli-managed:ws-v1", Size: 3000}},
+		ReclaimableBytes: 3000,
+	}, plan)
+
+	for _, request := range requests() {
+		assert.True(strings.HasPrefix(request, "GET "), "a dry run doesn't change anything: %s", request)
+	}
+}
+
+func Test_CleanerExecute(t *testing.T) {
+	assert := require.New(t)
+	responses := map[string]string{
+		"/containers/abc/stop": "",
+		"/containers/abc":      "",
+	}
+	for path, body := range cleanerResponses {
+		responses[path] = body
+	}
+	c, requests := newRecordingClient(t, "", responses)
+
+	// the image removal fails, the container is removed anyway
+	plan, err := NewCleaner(c).Clean("ws-v1", false)
+	assert.Error(err)
+	assert.True(plan.Containers[0].Removed)
+	assert.Empty(plan.Containers[0].Error)
+	assert.False(plan.Images[0].Removed)
+	assert.Contains(plan.Images[0].Error, "error removing image sha256:img")
+	assert.Equal(plan.Images[0].Error, err.Error())
+
+	assert.Equal([]string{
+		"GET /containers/json",
+		"GET /images/json",
+		"POST /containers/abc/stop",
+		"DELETE /containers/abc",
+		"DELETE /images/sha256:img",
+	}, requests())
+}
+
+func Test_CleanerRemovesContainerThatFailedToStop(t *testing.T) {
+	assert := require.New(t)
+	// stopping isn't answered, the forced removal is
+	responses := map[string]string{
+		"/containers/abc":    "",
+		"/images/sha256:img": `[{"Deleted": "sha256:img"}]`,
+	}
+	for path, body := range cleanerResponses {
+		responses[path] = body
+	}
+	c, requests := newRecordingClient(t, "", responses)
+
+	plan, err := NewCleaner(c).Clean("ws-v1", false)
+	assert.NoError(err)
+	assert.True(plan.Containers[0].Removed)
+	assert.True(plan.Images[0].Removed)
+	assert.Contains(requests(), "POST /containers/abc/stop")
+	assert.Contains(requests(), "DELETE /containers/abc")
+}
@@ -71,3 +71,12 @@ func (c *Client) Close() {
 		c.buildWorker.Shutdown()
 	}
 }
+
+// ServerVersion returns the version of the docker daemon
+func (c *Client) ServerVersion() (string, error) {
+	version, err := c.APIClient.ServerVersion(c.ctx)
+	if err != nil {
+		return "", fmt.Errorf("error querying docker server version: %w", err)
+	}
+	return version.Version, nil
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+	units "github.com/docker/go-units"
+)
+
+// DiskSpace is the space of the filesystem holding the docker root directory
+type DiskSpace struct {
+	RootDir string
+	// Available is -1 when the daemon doesn't report it and its root directory isn't on this host
+	Available int64
+	// Reclaimable is what pruning unused images and the build cache would free
+	Reclaimable int64
+}
+
+// DaemonInfo returns the system information of the docker daemon
+func (c *Client) DaemonInfo() (system.Info, error) {
+	info, err := c.APIClient.Info(c.ctx)
+	if err != nil {
+		return system.Info{}, fmt.Errorf("error querying docker info: %w", err)
+	}
+	return info, nil
+}
+
+// DiskUsage returns the space used by the images, containers, volumes and build cache of the daemon
+func (c *Client) DiskUsage() (types.DiskUsage, error) {
+	usage, err := c.APIClient.DiskUsage(c.ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return types.DiskUsage{}, fmt.Errorf("error querying docker disk usage: %w", err)
+	}
+	return usage, nil
+}
+
+// ImageSize returns the size of a local image
+func (c *Client) ImageSize(ref string) (int64, error) {
+	inspect, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	return inspect.Size, nil
+}
+
+// DiskSpace returns the space left for images and containers. Only the devicemapper driver reports
+// it, for other drivers the root directory is looked at when the daemon runs on this host.
+func (c *Client) DiskSpace() (DiskSpace, error) {
+	info, err := c.DaemonInfo()
+	if err != nil {
+		return DiskSpace{}, err
+	}
+	space := DiskSpace{RootDir: info.DockerRootDir, Available: -1}
+
+	for _, status := range info.DriverStatus {
+		if status[0] != "Data Space Available" {
+			continue
+		}
+		if size, err := units.FromHumanSize(status[1]); err == nil {
+			space.Available = size
+		}
+	}
+	if space.Available < 0 && c.isLocal() && info.DockerRootDir != "" {
+		// Docker Desktop keeps the root directory in a VM, it doesn't exist here
+		if free, err := freeSpace(info.DockerRootDir); err == nil {
+			space.Available = free
+		}
+	}
+
+	usage, err := c.DiskUsage()
+	if err != nil {
+		return DiskSpace{}, err
+	}
+	space.Reclaimable = reclaimableSpace(usage)
+	return space, nil
+}
+
+// isLocal reports whether the daemon is reached over a socket of this host
+func (c *Client) isLocal() bool {
+	host := c.Endpoint.Host
+	return host == "" || strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://")
+}
+
+// reclaimableSpace adds up the images no container uses and the build cache not in use, the
+// same way docker system df does
+func reclaimableSpace(usage types.DiskUsage) int64 {
+	var size int64
+	for _, img := range usage.Images {
+		if img.Containers == 0 {
+			size += img.Size - img.SharedSize
+		}
+	}
+	for _, cache := range usage.BuildCache {
+		if !cache.InUse && !cache.Shared {
+			size += cache.Size
+		}
+	}
+	return size
+}
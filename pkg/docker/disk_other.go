@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package docker
+
+import "errors"
+
+// freeSpace isn't supported on this platform, the space is reported as unknown
+func freeSpace(path string) (int64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
@@ -0,0 +1,123 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/cli/cli/context/docker"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+)
+
+const systemDF = `{
+  "LayersSize": 5000,
+  "Images": [
+    {"Id": "sha256:aaa", "Size": 3000, "SharedSize": 1000, "Containers": 0},
+    {"Id": "sha256:bbb", "Size": 2000, "SharedSize": 1000, "Containers": 1}
+  ],
+  "Containers": [],
+  "Volumes": [],
+  "BuildCache": [
+    {"ID": "c1", "Size": 400, "InUse": false, "Shared": false},
+    {"ID": "c2", "Size": 800, "InUse": true, "Shared": false}
+  ]
+}`
+
+// newCannedClient returns a client of a daemon answering API paths with the given responses
+func newCannedClient(t *testing.T, endpoint string, responses map[string]string) *Client {
+	t.Helper()
+	c, _ := newRecordingClient(t, endpoint, responses)
+	return c
+}
+
+// newRecordingClient is newCannedClient that also returns the method and path of every request made so far
+func newRecordingClient(t *testing.T, endpoint string, responses map[string]string) (*Client, func() []string) {
+	t.Helper()
+	var lock sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Strip the /v1.xx API version prefix
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/v1.") {
+			path = path[strings.Index(path[1:], "/")+1:]
+		}
+		lock.Lock()
+		requests = append(requests, r.Method+" "+path)
+		lock.Unlock()
+		body, ok := responses[path]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	apiClient, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.45"))
+	require.NoError(t, err)
+	c := &Client{APIClient: apiClient, Endpoint: docker.Endpoint{EndpointMeta: docker.EndpointMeta{Host: endpoint}}, ctx: context.Background()}
+	return c, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, requests...)
+	}
+}
+
+func Test_DiskSpaceDeviceMapper(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "tcp://10.0.0.1:2376", map[string]string{
+		"/info":      `{"DockerRootDir": "/var/lib/docker", "Driver": "devicemapper", "DriverStatus": [["Pool Name", "docker-pool"], ["Data Space Available", "2.5GB"]]}`,
+		"/system/df": systemDF,
+	})
+
+	space, err := c.DiskSpace()
+	assert.NoError(err)
+	assert.Equal(DiskSpace{RootDir: "/var/lib/docker", Available: 2_500_000_000, Reclaimable: 2400}, space)
+}
+
+func Test_DiskSpaceLocalRootDir(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	c := newCannedClient(t, "unix:///var/run/docker.sock", map[string]string{
+		"/info":      `{"DockerRootDir": "` + dir + `", "Driver": "overlay2", "DriverStatus": [["Backing Filesystem", "extfs"]]}`,
+		"/system/df": systemDF,
+	})
+
+	space, err := c.DiskSpace()
+	assert.NoError(err)
+	assert.Equal(dir, space.RootDir)
+	assert.Greater(space.Available, int64(0))
+}
+
+func Test_DiskSpaceUnknown(t *testing.T) {
+	assert := require.New(t)
+	// overlay2 on a remote daemon doesn't report the space left
+	c := newCannedClient(t, "tcp://10.0.0.1:2376", map[string]string{
+		"/info":      `{"DockerRootDir": "/var/lib/docker", "Driver": "overlay2"}`,
+		"/system/df": systemDF,
+	})
+
+	space, err := c.DiskSpace()
+	assert.NoError(err)
+	assert.Equal(int64(-1), space.Available)
+	assert.Equal(int64(2400), space.Reclaimable)
+}
+
+func Test_ImageSize(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "", map[string]string{
+		"/images/rancher/support-bundle-kit:master-head/json": `{"Id": "sha256:ccc", "Size": 1234567}`,
+	})
+
+	size, err := c.ImageSize("rancher/support-bundle-kit:master-head")
+	assert.NoError(err)
+	assert.Equal(int64(1234567), size)
+
+	_, err = c.ImageSize("rancher/missing:latest")
+	assert.Error(err)
+}
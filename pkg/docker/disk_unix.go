@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package docker
+
+import "syscall"
+
+// freeSpace returns the space available to unprivileged users on the filesystem of path
+func freeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
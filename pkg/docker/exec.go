@@ -2,14 +2,122 @@ package docker
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/pkg/stdcopy"
 )
 
-func (c *Client) ExecContainer(containerName string, command []string, env []string) (string, string, error) {
+// ExecResult is the output of a command run in a container and the code it exited with
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExitError is the error of a command which exited with a non zero code
+type ExitError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command failed with exit code %d: %s", e.ExitCode, e.Stderr)
+}
+
+// ExecContainer runs a command in a container and buffers its output. The error is only set when
+// the command couldn't be run, a command which failed is reported by the exit code of the result.
+func (c *Client) ExecContainer(containerName string, command []string, env []string) (ExecResult, error) {
+	return c.ExecContainerContext(c.ctx, containerName, command, env)
+}
+
+// ExecContainerContext is ExecContainer abandoning the command when ctx is cancelled
+func (c *Client) ExecContainerContext(ctx context.Context, containerName string, command []string, env []string) (ExecResult, error) {
+	stream, err := c.ExecContainerStream(ctx, containerName, command, env)
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer stream.Close()
+
+	// A failed copy is reported by Wait, the pipe is closed with the same error
+	var stdout bytes.Buffer
+	_, _ = io.Copy(&stdout, stream)
+	stderr, err := stream.Wait()
+	result := ExecResult{Stdout: stdout.String(), Stderr: stderr}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode
+		return result, nil
+	}
+	return result, err
+}
+
+// ExecContainerOutput runs a command in a container and returns an ExitError when it fails, for
+// callers which only care whether the command succeeded
+func (c *Client) ExecContainerOutput(containerName string, command []string, env []string) (string, string, error) {
+	result, err := c.ExecContainer(containerName, command, env)
+	if err == nil && result.ExitCode != 0 {
+		err = &ExitError{ExitCode: result.ExitCode, Stderr: result.Stderr}
+	}
+	return result.Stdout, result.Stderr, err
+}
+
+// SimulatorVersion returns the version support-bundle-kit reports in the running container of instanceName
+func (c *Client) SimulatorVersion(instanceName string) (string, error) {
+	stdout, _, err := c.ExecContainerOutput(instanceName, []string{"support-bundle-kit", "version"}, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting support-bundle-kit version: %w", err)
+	}
+	return parseSimulatorVersion(stdout), nil
+}
+
+// parseSimulatorVersion returns the first line support-bundle-kit printed for its version
+func parseSimulatorVersion(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// ExecStream is the stdout of a command running in a container. Stderr is captured separately
+// and returned by Wait.
+type ExecStream struct {
+	reader *io.PipeReader
+	resp   types.HijackedResponse
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+}
+
+func (s *ExecStream) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+// Wait blocks until the command exited and returns its stderr, stdout has to be read or closed first.
+// A command which exited with a non zero code returns an ExitError.
+func (s *ExecStream) Wait() (string, error) {
+	<-s.done
+	return s.stderr.String(), s.err
+}
+
+// Close stops reading the output and releases the connection to the daemon
+func (s *ExecStream) Close() error {
+	err := s.reader.Close()
+	s.resp.Close()
+	return err
+}
+
+// ExecContainerStream starts a command in a container and returns its stdout as it is produced.
+// The multiplexed output of the hijacked connection is split in a goroutine, cancelling ctx
+// closes the connection.
+func (c *Client) ExecContainerStream(ctx context.Context, containerName string, command []string, env []string) (*ExecStream, error) {
 	execConfig := container.ExecOptions{
 		Cmd:          command,
 		Env:          env,
@@ -17,30 +125,59 @@ func (c *Client) ExecContainer(containerName string, command []string, env []str
 		AttachStderr: true,
 	}
 
-	execIDResp, err := c.APIClient.ContainerExecCreate(c.ctx, containerName, execConfig)
+	execIDResp, err := c.APIClient.ContainerExecCreate(ctx, containerName, execConfig)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create exec configuration: %w", err)
+		return nil, fmt.Errorf("failed to create exec configuration: %w", err)
 	}
 
-	resp, err := c.APIClient.ContainerExecAttach(c.ctx, execIDResp.ID, types.ExecStartCheck{})
+	resp, err := c.APIClient.ContainerExecAttach(ctx, execIDResp.ID, types.ExecStartCheck{})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to attach to exec process: %w", err)
+		return nil, fmt.Errorf("failed to attach to exec process: %w", err)
 	}
-	defer resp.Close()
 
-	var stdout, stderr bytes.Buffer
-	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
-		return "", "", fmt.Errorf("failed to copy output: %w", err)
+	pr, pw := io.Pipe()
+	stream := &ExecStream{
+		reader: pr,
+		resp:   resp,
+		done:   make(chan struct{}),
 	}
 
-	inspect, err := c.APIClient.ContainerExecInspect(c.ctx, execIDResp.ID)
+	go func() {
+		defer close(stream.done)
+		_, err := stdcopy.StdCopy(pw, &stream.stderr, resp.Reader)
+		switch {
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case err != nil:
+			err = fmt.Errorf("failed to copy output: %w", err)
+		default:
+			err = c.execExitError(ctx, execIDResp.ID, stream.stderr.String())
+		}
+		stream.err = err
+		pw.CloseWithError(err)
+	}()
+
+	// The hijacked connection ignores ctx once established
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Close()
+		case <-stream.done:
+		}
+	}()
+
+	return stream, nil
+}
+
+// execExitError returns an ExitError when the exec process exited with a non zero code
+func (c *Client) execExitError(ctx context.Context, execID, stderr string) error {
+	inspect, err := c.APIClient.ContainerExecInspect(ctx, execID)
 	if err != nil {
-		return stdout.String(), stderr.String(), fmt.Errorf("failed to inspect exec process: %w", err)
+		return fmt.Errorf("failed to inspect exec process: %w", err)
 	}
 
 	if inspect.ExitCode != 0 {
-		return stdout.String(), stderr.String(), fmt.Errorf("command failed with exit code %d: %s", inspect.ExitCode, stderr.String())
+		return &ExitError{ExitCode: inspect.ExitCode, Stderr: stderr}
 	}
-
-	return stdout.String(), stderr.String(), nil
+	return nil
 }
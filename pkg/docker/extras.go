@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// extrasKey labels a container with the hash of the extras it was created with, docker can't change
+// the labels or environment of a container so one created with others is created again
+const extrasKey = "sim-cli-managed.extras"
+
+// Extras are labels and environment variables added to the containers sim-gui creates, e.g. for other
+// tooling to find them by
+type Extras struct {
+	Labels map[string]string
+	Env    map[string]string
+}
+
+// reservedLabels are set on containers by sim-gui itself
+var reservedLabels = []string{simCliPrefix, bundleNameKey, extrasKey, portsKey, WorkspaceLabel, VersionLabel, ProfileLabel}
+
+// Validate fails for labels sim-gui sets itself and for keys that are empty or can't be passed on
+func (e Extras) Validate() error {
+	for key := range e.Labels {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("label keys can't be empty")
+		}
+		for _, reserved := range reservedLabels {
+			if key == reserved {
+				return fmt.Errorf("label %s is reserved", key)
+			}
+		}
+	}
+	for key := range e.Env {
+		if strings.TrimSpace(key) == "" || strings.ContainsAny(key, "= \t\n") {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	return nil
+}
+
+// MergeExtras merges the labels and environment of each extras in turn, a key set by a later one
+// overrides the earlier value
+func MergeExtras(layers ...Extras) Extras {
+	var merged Extras
+	for _, layer := range layers {
+		for key, value := range layer.Labels {
+			if merged.Labels == nil {
+				merged.Labels = make(map[string]string)
+			}
+			merged.Labels[key] = value
+		}
+		for key, value := range layer.Env {
+			if merged.Env == nil {
+				merged.Env = make(map[string]string)
+			}
+			merged.Env[key] = value
+		}
+	}
+	return merged
+}
+
+// Hash identifies the labels and environment, it is empty without any
+func (e Extras) Hash() string {
+	if len(e.Labels) == 0 && len(e.Env) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, m := range []map[string]string{e.Labels, e.Env} {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(h, "%q=%q\n", key, m[key])
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// labels returns the extra labels along with those sim-gui sets, which win
+func (e Extras) labels(own map[string]string) map[string]string {
+	labels := make(map[string]string, len(e.Labels)+len(own)+1)
+	for key, value := range e.Labels {
+		labels[key] = value
+	}
+	for key, value := range own {
+		labels[key] = value
+	}
+	if hash := e.Hash(); hash != "" {
+		labels[extrasKey] = hash
+	}
+	return labels
+}
+
+// env returns the environment as KEY=VALUE, sorted
+func (e Extras) env() []string {
+	env := make([]string, 0, len(e.Env))
+	for key, value := range e.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// ContainerHasExtras reports whether a container was created with extras, containers created before
+// extras could be set have none
+func ContainerHasExtras(ctr types.Container, extras Extras) bool {
+	return ctr.Labels[extrasKey] == extras.Hash()
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MergeExtras(t *testing.T) {
+	assert := require.New(t)
+	merged := MergeExtras(
+		Extras{Labels: map[string]string{"case": "01234", "team": "storage"}, Env: map[string]string{"TZ": "UTC"}},
+		Extras{},
+		Extras{Labels: map[string]string{"team": "network"}, Env: map[string]string{"DEBUG": "1"}},
+	)
+	assert.Equal(map[string]string{"case": "01234", "team": "network"}, merged.Labels, "expected later labels to override earlier ones")
+	assert.Equal([]string{"DEBUG=1", "TZ=UTC"}, merged.env())
+
+	assert.Nil(MergeExtras(Extras{}, Extras{}).Labels)
+}
+
+func Test_ExtrasValidate(t *testing.T) {
+	assert := require.New(t)
+	assert.NoError(Extras{Labels: map[string]string{"case": "01234"}, Env: map[string]string{"TZ": "UTC"}}.Validate())
+	assert.NoError(Extras{}.Validate())
+
+	for _, key := range []string{simCliPrefix, bundleNameKey, extrasKey, portsKey} {
+		assert.EqualError(Extras{Labels: map[string]string{key: "x"}}.Validate(), "label "+key+" is reserved")
+	}
+	assert.Error(Extras{Labels: map[string]string{" ": "x"}}.Validate())
+	assert.Error(Extras{Env: map[string]string{"A=B": "x"}}.Validate())
+	assert.Error(Extras{Env: map[string]string{"": "x"}}.Validate())
+}
+
+func Test_ExtrasLabels(t *testing.T) {
+	assert := require.New(t)
+	extras := Extras{Labels: map[string]string{"case": "01234", simCliPrefix: "other"}}
+	labels := extras.labels(map[string]string{simCliPrefix: "ws-v1"})
+	assert.Equal("ws-v1", labels[simCliPrefix], "expected the labels of sim-gui to win")
+	assert.Equal("01234", labels["case"])
+	assert.Equal(extras.Hash(), labels[extrasKey])
+
+	assert.Equal(map[string]string{simCliPrefix: "ws-v1"}, Extras{}.labels(map[string]string{simCliPrefix: "ws-v1"}))
+}
+
+func Test_ContainerHasExtras(t *testing.T) {
+	assert := require.New(t)
+	extras := Extras{Labels: map[string]string{"case": "01234"}, Env: map[string]string{"TZ": "UTC"}}
+	assert.Equal(extras.Hash(), MergeExtras(Extras{Env: map[string]string{"TZ": "UTC"}}, Extras{Labels: map[string]string{"case": "01234"}}).Hash())
+	assert.NotEqual(extras.Hash(), Extras{Labels: extras.Env, Env: extras.Labels}.Hash())
+
+	ctr := types.Container{Labels: extras.labels(nil)}
+	assert.True(ContainerHasExtras(ctr, extras))
+	assert.False(ContainerHasExtras(ctr, Extras{}))
+	assert.False(ContainerHasExtras(ctr, Extras{Labels: map[string]string{"case": "56789"}, Env: extras.Env}))
+
+	// containers created before extras could be set have none
+	assert.True(ContainerHasExtras(types.Container{Labels: map[string]string{simCliPrefix: "ws-v1"}}, Extras{}))
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
@@ -20,8 +21,35 @@ const (
 // support bundle in /bundle directory. This can subsequently be loaded into the simulator
 // This method submits the build request to a worker queue and waits for completion
 func (c *Client) CreateImage(instanceName string, bundlePath string, baseImage string) error {
+	return c.CreateImageWithLog(instanceName, Owner{}, bundlePath, baseImage, nil)
+}
+
+// CreateImageWithLog is CreateImage that also labels the image with its owner and writes the docker
+// build output to buildLog
+func (c *Client) CreateImageWithLog(instanceName string, owner Owner, bundlePath string, baseImage string, buildLog io.Writer) error {
 	// Submit build request to the worker and wait for result
-	return c.buildWorker.SubmitBuildRequest(instanceName, bundlePath, baseImage)
+	return c.buildWorker.SubmitBuildRequest(instanceName, owner, bundlePath, baseImage, buildLog)
+}
+
+// BuildPending reports whether an image build of instanceName is queued or running
+func (c *Client) BuildPending(instanceName string) bool {
+	return c.buildWorker != nil && c.buildWorker.Pending(instanceName)
+}
+
+// PendingBuilds returns the number of image builds queued or running
+func (c *Client) PendingBuilds() int {
+	if c.buildWorker == nil {
+		return 0
+	}
+	return c.buildWorker.PendingCount()
+}
+
+// ListManagedImages returns the simulator images built for every instance
+func (c *Client) ListManagedImages() ([]image.Summary, error) {
+	filters := filters.NewArgs(filters.KeyValuePair{Key: "reference", Value: simCliPrefix})
+	return c.APIClient.ImageList(c.ctx, image.ListOptions{
+		Filters: filters,
+	})
 }
 
 // FindImage attempts to find image for a given instanceName by filtering on labels added
@@ -58,18 +86,60 @@ func (c *Client) RemoveImages(instanceName string) error {
 	return nil
 }
 
-// PullImage pulls a docker image
-func (c *Client) PullImage(imageName string) error {
-	reader, err := c.APIClient.ImagePull(c.ctx, imageName, image.PullOptions{})
+// RemoveDanglingImages removes the untagged images labelled with instanceName, which a build that
+// was cut short leaves behind
+func (c *Client) RemoveDanglingImages(instanceName string) error {
+	filters := filters.NewArgs(
+		filters.Arg("dangling", "true"),
+		filters.Arg("label", fmt.Sprintf("%s=%s", bundleNameKey, instanceName)),
+	)
+	images, err := c.APIClient.ImageList(c.ctx, image.ListOptions{Filters: filters})
+	if err != nil {
+		return fmt.Errorf("error listing dangling images of %s: %v", instanceName, err)
+	}
+
+	for _, v := range images {
+		if _, err := c.APIClient.ImageRemove(c.ctx, v.ID, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return fmt.Errorf("error removing image %s: %v", v.ID, err)
+		}
+		logrus.Infof("removed dangling image %s of %s", v.ID, instanceName)
+	}
+	return nil
+}
+
+// ImageInfo identifies a local image
+type ImageInfo struct {
+	ID string
+	// Digest is the repository digest the image was pulled by, the ID for images built locally
+	Digest string
+	// CreatedAt is when the image was built
+	CreatedAt time.Time
+	Labels    map[string]string
+}
+
+// InspectImage returns the identity of a local image, e.g. the base image of simulators
+func (c *Client) InspectImage(ref string) (ImageInfo, error) {
+	inspect, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, ref)
 	if err != nil {
-		return err
+		return ImageInfo{}, fmt.Errorf("error inspecting image %s: %w", ref, err)
+	}
+	info := ImageInfo{ID: inspect.ID, Digest: inspect.ID, CreatedAt: parseDockerTime(inspect.Created)}
+	if len(inspect.RepoDigests) > 0 {
+		info.Digest = inspect.RepoDigests[0]
 	}
-	return readResponse(reader)
+	if inspect.Config != nil {
+		info.Labels = inspect.Config.Labels
+	}
+	return info, nil
 }
 
-// readResponse attempts to tidy up response messages
-func readResponse(resp io.ReadCloser) error {
+// readResponse attempts to tidy up response messages, the build or pull output is also written
+// to output when it isn't nil and every message is passed to progress when it isn't nil
+func readResponse(resp io.ReadCloser, output io.Writer, progress func(*jsonmessage.JSONMessage)) error {
 	defer resp.Close()
+	if output == nil {
+		output = io.Discard
+	}
 	reader := bufio.NewReader(resp)
 	for {
 		line, err := reader.ReadBytes('\n')
@@ -84,12 +154,22 @@ func readResponse(resp io.ReadCloser) error {
 
 		if msg.Error != nil {
 			logrus.Error(msg.Error)
+			fmt.Fprintf(output, "ERROR: %s\n", msg.Error.Message)
 			return msg.Error
 		}
 
 		if msg.Aux != nil {
 			continue
 		}
+		if progress != nil {
+			progress(msg)
+		}
+
+		if msg.Stream != "" {
+			io.WriteString(output, msg.Stream)
+		} else if msg.Status != "" {
+			fmt.Fprintf(output, "%s %s\n", msg.ID, msg.Status)
+		}
 
 		if msg.Stream != "" && msg.Stream != "\n" {
 			logrus.Info(msg.Stream)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/docker/docker/api/types"
@@ -13,8 +14,10 @@ import (
 // BuildRequest represents a single image build request
 type BuildRequest struct {
 	InstanceName string
+	Owner        Owner
 	BundlePath   string
 	BaseImage    string
+	BuildLog     io.Writer // Receives the docker build output, may be nil
 	ResultChan   chan BuildResult
 }
 
@@ -34,6 +37,8 @@ type ImageBuildWorker struct {
 	isShutdown  bool
 	mu          sync.RWMutex
 	workerCount int
+	// pending counts the queued and running builds per instance name, guarded by mu
+	pending map[string]int
 }
 
 // NewImageBuildWorker creates a new image build worker with 3 workers
@@ -45,6 +50,7 @@ func NewImageBuildWorker(client *Client) *ImageBuildWorker {
 		ctx:         ctx,
 		cancel:      cancel,
 		workerCount: 3, // 3 concurrent workers
+		pending:     make(map[string]int),
 	}
 }
 
@@ -82,7 +88,7 @@ func (w *ImageBuildWorker) processBuildRequest(req BuildRequest) {
 		"bundlePath":   req.BundlePath,
 	}).Info("Processing image build request")
 
-	err := w.buildImage(req.InstanceName, req.BundlePath, req.BaseImage)
+	err := w.buildImage(req.InstanceName, req.Owner, req.BundlePath, req.BaseImage, req.BuildLog)
 
 	// Send result back through the channel
 	req.ResultChan <- BuildResult{Error: err}
@@ -96,18 +102,20 @@ func (w *ImageBuildWorker) processBuildRequest(req BuildRequest) {
 }
 
 // buildImage performs the actual image build operation
-func (w *ImageBuildWorker) buildImage(instanceName string, bundlePath string, baseImage string) error {
+func (w *ImageBuildWorker) buildImage(instanceName string, owner Owner, bundlePath string, baseImage string, buildLog io.Writer) error {
 	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
 	contextTar, err := BuildContextTar(bundlePath, baseImage)
 	if err != nil {
 		return err
 	}
 
+	labels := map[string]string{
+		bundleNameKey: instanceName,
+	}
+	owner.addLabels(labels)
 	imageBuildResponse, err := w.client.APIClient.ImageBuild(w.client.ctx, bytes.NewReader(contextTar.Bytes()), types.ImageBuildOptions{
-		Tags: []string{imageName},
-		Labels: map[string]string{
-			bundleNameKey: instanceName,
-		},
+		Tags:   []string{imageName},
+		Labels: labels,
 		Remove: true, // Remove intermediate containers after build
 	})
 
@@ -115,24 +123,34 @@ func (w *ImageBuildWorker) buildImage(instanceName string, bundlePath string, ba
 		return err
 	}
 
-	return readResponse(imageBuildResponse.Body)
+	return readResponse(imageBuildResponse.Body, buildLog, nil)
 }
 
 // SubmitBuildRequest submits a build request and waits for the result
 // This method blocks until the build is complete
-func (w *ImageBuildWorker) SubmitBuildRequest(instanceName string, bundlePath string, baseImage string) error {
-	w.mu.RLock()
+func (w *ImageBuildWorker) SubmitBuildRequest(instanceName string, owner Owner, bundlePath string, baseImage string, buildLog io.Writer) error {
+	w.mu.Lock()
 	if w.isShutdown {
-		w.mu.RUnlock()
+		w.mu.Unlock()
 		return fmt.Errorf("worker is shutdown")
 	}
-	w.mu.RUnlock()
+	w.pending[instanceName]++
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		if w.pending[instanceName]--; w.pending[instanceName] <= 0 {
+			delete(w.pending, instanceName)
+		}
+		w.mu.Unlock()
+	}()
 
 	resultChan := make(chan BuildResult, 1)
 	req := BuildRequest{
 		InstanceName: instanceName,
+		Owner:        owner,
 		BundlePath:   bundlePath,
 		BaseImage:    baseImage,
+		BuildLog:     buildLog,
 		ResultChan:   resultChan,
 	}
 
@@ -151,6 +169,24 @@ func (w *ImageBuildWorker) SubmitBuildRequest(instanceName string, bundlePath st
 	return result.Error
 }
 
+// Pending reports whether a build of instanceName is queued or running
+func (w *ImageBuildWorker) Pending(instanceName string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.pending[instanceName] > 0
+}
+
+// PendingCount returns the number of builds queued or running
+func (w *ImageBuildWorker) PendingCount() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	count := 0
+	for _, n := range w.pending {
+		count += n
+	}
+	return count
+}
+
 // Shutdown gracefully shuts down the worker
 func (w *ImageBuildWorker) Shutdown() {
 	w.mu.Lock()
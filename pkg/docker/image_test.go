@@ -1,8 +1,12 @@
 package docker
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -19,3 +23,52 @@ func Test_ImageLifeCycle(t *testing.T) {
 	err = client.RemoveImages("dev")
 	assert.NoError(err)
 }
+
+func Test_ReadResponseWritesOutput(t *testing.T) {
+	assert := require.New(t)
+	resp := `{"stream":"Step 1/3 : FROM rancher/support-bundle-kit:master-head\n"}
+{"status":"Downloading","id":"abc123"}
+{"aux":{"ID":"sha256:abc"}}
+{"stream":"Step 3/3 : COPY bundle /bundle\n"}
+{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}
+`
+	var output bytes.Buffer
+	err := readResponse(io.NopCloser(strings.NewReader(resp)), &output, nil)
+	assert.EqualError(err, "no space left on device")
+	assert.Equal("Step 1/3 : FROM rancher/support-bundle-kit:master-head\nabc123 Downloading\nStep 3/3 : COPY bundle /bundle\nERROR: no space left on device\n", output.String())
+
+	// output is optional
+	assert.NoError(readResponse(io.NopCloser(strings.NewReader(`{"stream":"done\n"}`+"\n")), nil, nil))
+}
+
+func Test_InspectImage(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "", map[string]string{
+		"/images/rancher/support-bundle-kit:master-head/json": `{"Id": "sha256:base", "Created": "2024-11-01T08:00:00Z",
+			"RepoDigests": ["rancher/support-bundle-kit@sha256:d1g3st"], "Config": {"Labels": {"org.opencontainers.image.revision": "f00ba4"}}}`,
+		"/images/sim-cli-managed:ws-v1/json": `{"Id": "sha256:built", "Created": "2024-11-18T09:00:00Z"}`,
+	})
+
+	info, err := c.InspectImage("rancher/support-bundle-kit:master-head")
+	assert.NoError(err)
+	assert.Equal(ImageInfo{
+		ID:        "sha256:base",
+		Digest:    "rancher/support-bundle-kit@sha256:d1g3st",
+		CreatedAt: time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC),
+		Labels:    map[string]string{"org.opencontainers.image.revision": "f00ba4"},
+	}, info)
+
+	// built images have no repository digest
+	info, err = c.InspectImage("sim-cli-managed:ws-v1")
+	assert.NoError(err)
+	assert.Equal("sha256:built", info.Digest)
+
+	_, err = c.InspectImage("rancher/missing:latest")
+	assert.Error(err)
+}
+
+func Test_ParseSimulatorVersion(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal("v0.0.42-dev (f00ba4)", parseSimulatorVersion("\n  v0.0.42-dev (f00ba4)\nbuilt with go1.22\n"))
+	assert.Empty(parseSimulatorVersion(" \n"))
+}
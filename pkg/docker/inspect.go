@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// ErrContainerNotFound is returned by Inspect when there's no container of the instance
+var ErrContainerNotFound = errors.New("container not found")
+
+// ImageSummary is the part of docker image inspect reported with a container
+type ImageSummary struct {
+	ID           string `json:"Id"`
+	RepoTags     []string
+	RepoDigests  []string
+	Created      string
+	Size         int64
+	Architecture string
+	Os           string
+	Labels       map[string]string
+}
+
+// Inspection is the part of docker inspect of a container reported for debugging, the fields keep
+// the names docker inspect prints them with
+type Inspection struct {
+	ID              string `json:"Id"`
+	Name            string
+	Created         string
+	Image           string
+	RestartCount    int
+	Config          *container.Config
+	HostConfig      *container.HostConfig
+	State           *types.ContainerState
+	Mounts          []types.MountPoint
+	NetworkSettings *types.NetworkSettings
+	// ImageSummary is nil when the image of the container is gone
+	ImageSummary *ImageSummary `json:",omitempty"`
+}
+
+// Inspect returns the inspection of the container of instanceName, running or not, with its image
+func (c *Client) Inspect(instanceName string) (*Inspection, error) {
+	inspect, err := c.APIClient.ContainerInspect(c.ctx, instanceName)
+	if errdefs.IsNotFound(err) {
+		return nil, ErrContainerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container %s: %w", instanceName, err)
+	}
+
+	inspection := &Inspection{
+		Config:          inspect.Config,
+		Mounts:          inspect.Mounts,
+		NetworkSettings: inspect.NetworkSettings,
+	}
+	if inspect.ContainerJSONBase != nil {
+		inspection.ID = inspect.ID
+		inspection.Name = inspect.Name
+		inspection.Created = inspect.Created
+		inspection.Image = inspect.Image
+		inspection.RestartCount = inspect.RestartCount
+		inspection.HostConfig = inspect.HostConfig
+		inspection.State = inspect.State
+	}
+
+	if image, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, inspection.Image); err == nil {
+		inspection.ImageSummary = &ImageSummary{
+			ID:           image.ID,
+			RepoTags:     image.RepoTags,
+			RepoDigests:  image.RepoDigests,
+			Created:      image.Created,
+			Size:         image.Size,
+			Architecture: image.Architecture,
+			Os:           image.Os,
+		}
+		if image.Config != nil {
+			inspection.ImageSummary.Labels = image.Config.Labels
+		}
+	}
+	return inspection, nil
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxInstanceNameLength keeps instance names a single DNS label, docker resolves containers by name on
+// user-defined networks. Image tags may be up to 128 characters.
+const MaxInstanceNameLength = 63
+
+// instanceNameRegexp is what both a container name and an image tag accept
+var instanceNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// unsafeInstanceChars are the runs of characters replaced when shortening an instance name. Dots are
+// replaced too, they separate DNS labels.
+var unsafeInstanceChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ValidateInstanceName checks that name can be used as a container name and, after simCliPrefix, as an
+// image reference
+func ValidateInstanceName(name string) error {
+	if len(name) < 2 {
+		return fmt.Errorf("instance name %q is shorter than 2 characters", name)
+	}
+	if len(name) > MaxInstanceNameLength {
+		return fmt.Errorf("instance name %q is longer than %d characters", name, MaxInstanceNameLength)
+	}
+	if !instanceNameRegexp.MatchString(name) {
+		return fmt.Errorf("instance name %q must start with a letter or digit followed by letters, digits, '_', '.' or '-'", name)
+	}
+	return nil
+}
+
+// InstanceName returns the name of the simulator of a version. It is "<workspace>-<versionID>" when
+// that is valid, otherwise the workspace name is cut down to its safe characters and followed by a
+// hash of the full name, so that workspaces shortened alike still get different instances.
+func InstanceName(workspaceName, versionID string) string {
+	name := workspaceName + "-" + versionID
+	if ValidateInstanceName(name) == nil {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(workspaceName))
+	suffix := hex.EncodeToString(sum[:4]) + "-" + versionID
+	prefix := unsafeInstanceChars.ReplaceAllString(workspaceName, "-")
+	prefix = strings.TrimLeft(prefix, "_-")
+	if limit := MaxInstanceNameLength - len(suffix) - 1; len(prefix) > limit {
+		prefix = prefix[:max(limit, 0)]
+	}
+	prefix = strings.TrimRight(prefix, "_-")
+	if prefix == "" {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
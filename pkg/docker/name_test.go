@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidateInstanceName(t *testing.T) {
+	assert := require.New(t)
+	for _, name := range []string{"ws-v1", "Demo_1.2-v10", "0-v1", strings.Repeat("a", MaxInstanceNameLength)} {
+		assert.NoError(ValidateInstanceName(name), name)
+	}
+	for _, name := range []string{"", "a", "-ws-v1", ".ws-v1", "_ws-v1", "my ws-v1", "ws/x-v1", "ws:tag-v1", "wś-v1", strings.Repeat("a", MaxInstanceNameLength+1)} {
+		assert.Error(ValidateInstanceName(name), name)
+	}
+}
+
+func Test_InstanceName(t *testing.T) {
+	assert := require.New(t)
+
+	// Valid names are kept, containers of existing versions are found by them
+	assert.Equal("demo-v2", InstanceName("demo", "v2"))
+	assert.Equal("demo-v2-v1", InstanceName("demo-v2", "v1"))
+	assert.Equal("a.b-v1", InstanceName("a.b", "v1"))
+
+	long := "customer-escalation-2024-prod-cluster-east-with-a-very-long-suffix"
+	for _, workspace := range []string{long, "my workspace", ".hidden", "..", "ünïcode", "   ", "a..b c"} {
+		name := InstanceName(workspace, "v12")
+		assert.NoError(ValidateInstanceName(name), workspace)
+		assert.True(strings.HasSuffix(name, "-v12"), name)
+		assert.Equal(name, InstanceName(workspace, "v12"), "expected the same name every time")
+	}
+	assert.Regexp(`^my-workspace-[0-9a-f]{8}-v1$`, InstanceName("my workspace", "v1"))
+	assert.Regexp(`^[0-9a-f]{8}-v1$`, InstanceName("..", "v1"))
+	assert.Regexp(`^customer-escalation-2024-prod-cluster-east-with-a-[0-9a-f]{8}-v12$`, InstanceName(long, "v12"))
+
+	// Workspaces shortened alike still get different instances
+	assert.NotEqual(InstanceName("my workspace", "v1"), InstanceName("my/workspace", "v1"))
+	assert.NotEqual(InstanceName(long+"-1", "v1"), InstanceName(long+"-2", "v1"))
+}
@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+)
+
+// DefaultNetwork is the network simulators are attached to when no other one is asked for
+const DefaultNetwork = "bridge"
+
+// UnknownNetworkError is returned when a simulator is started on a network docker doesn't have
+type UnknownNetworkError struct {
+	Network   string
+	Available []string
+}
+
+func (e *UnknownNetworkError) Error() string {
+	return fmt.Sprintf("network %s doesn't exist, available networks: %s", e.Network, strings.Join(e.Available, ", "))
+}
+
+// NetworkNames returns the names of the docker networks, sorted
+func (c *Client) NetworkNames() ([]string, error) {
+	networks, err := c.APIClient.NetworkList(c.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing networks: %w", err)
+	}
+	names := make([]string, 0, len(networks))
+	for _, n := range networks {
+		names = append(names, n.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CheckNetwork returns an *UnknownNetworkError when docker has no network called name, an empty name
+// is the default bridge network
+func (c *Client) CheckNetwork(name string) error {
+	if name == "" {
+		return nil
+	}
+	names, err := c.NetworkNames()
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+	return &UnknownNetworkError{Network: name, Available: names}
+}
+
+// ContainerNetwork returns the network a container was attached to when it was created
+func ContainerNetwork(ctr types.Container) string {
+	if ctr.HostConfig.NetworkMode == "" || ctr.HostConfig.NetworkMode == "default" {
+		return DefaultNetwork
+	}
+	return ctr.HostConfig.NetworkMode
+}
+
+// containerNetworkIP returns the IP of a container on the network it was created with
+func containerNetworkIP(ctr types.Container) (string, bool) {
+	if ctr.NetworkSettings == nil {
+		return "", false
+	}
+	settings, ok := ctr.NetworkSettings.Networks[ContainerNetwork(ctr)]
+	if !ok || settings == nil || settings.IPAddress == "" {
+		return "", false
+	}
+	return settings.IPAddress, true
+}
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ContainerNetworkIP(t *testing.T) {
+	assert := require.New(t)
+	ctr := types.Container{NetworkSettings: &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{
+		"bridge":  {IPAddress: "172.17.0.4"},
+		"rancher": {IPAddress: "172.20.0.3"},
+	}}}
+	ctr.HostConfig.NetworkMode = "rancher"
+	assert.Equal("rancher", ContainerNetwork(ctr))
+	ip, ok := containerNetworkIP(ctr)
+	assert.True(ok)
+	assert.Equal("172.20.0.3", ip)
+
+	// containers created before networks could be chosen are on the default bridge
+	ctr.HostConfig.NetworkMode = "default"
+	assert.Equal(DefaultNetwork, ContainerNetwork(ctr))
+	ip, ok = containerNetworkIP(ctr)
+	assert.True(ok)
+	assert.Equal("172.17.0.4", ip)
+
+	ctr.HostConfig.NetworkMode = "host"
+	_, ok = containerNetworkIP(ctr)
+	assert.False(ok)
+	_, ok = containerNetworkIP(types.Container{})
+	assert.False(ok)
+}
+
+func Test_UnknownNetworkError(t *testing.T) {
+	assert := require.New(t)
+	err := &UnknownNetworkError{Network: "ranchr", Available: []string{"bridge", "host", "none", "rancher"}}
+	assert.EqualError(err, "network ranchr doesn't exist, available networks: bridge, host, none, rancher")
+}
@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"regexp"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+const (
+	// WorkspaceLabel and VersionLabel name the workspace and version a simulator container and its
+	// image were created for
+	WorkspaceLabel = "sim-gui/workspace"
+	VersionLabel   = "sim-gui/version"
+	// ProfileLabel names the profile of the workspace, servers with a single data directory set none
+	ProfileLabel = "sim-gui/profile"
+)
+
+// Owner is the workspace and version of a simulator. Simulators started by the CLI belong to none.
+type Owner struct {
+	// Profile is empty unless the server serves several data directories
+	Profile   string
+	Workspace string
+	Version   string
+}
+
+// addLabels sets the labels of the owner in labels, an empty owner sets none
+func (o Owner) addLabels(labels map[string]string) {
+	if o.Workspace == "" {
+		return
+	}
+	labels[WorkspaceLabel] = o.Workspace
+	labels[VersionLabel] = o.Version
+	if o.Profile != "" {
+		labels[ProfileLabel] = o.Profile
+	}
+}
+
+// legacyInstanceRegexp splits the instance names of containers created before the owner labels,
+// version IDs end the name so a workspace named demo-v2 is still told apart
+var legacyInstanceRegexp = regexp.MustCompile(`^(.+)-(v[0-9]+)$`)
+
+// ContainerOwner returns the owner of a container from its labels. Containers created without them are
+// parsed from their instance name "<workspace>-<version ID>", which fails for names docker.InstanceName
+// shortened.
+func ContainerOwner(ctr types.Container) (Owner, bool) {
+	if workspace := ctr.Labels[WorkspaceLabel]; workspace != "" {
+		return Owner{Profile: ctr.Labels[ProfileLabel], Workspace: workspace, Version: ctr.Labels[VersionLabel]}, true
+	}
+	return ParseInstanceName(InstanceOf(ctr))
+}
+
+// ParseInstanceName splits an instance name "<workspace>-<version ID>"
+func ParseInstanceName(name string) (Owner, bool) {
+	match := legacyInstanceRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return Owner{}, false
+	}
+	return Owner{Workspace: match[1], Version: match[2]}, true
+}
+
+// instanceFilters match the containers of instanceName. The name filter matches substrings, ws-v1
+// matches ws-v10 as well, the label is matched exactly.
+func instanceFilters(instanceName string) filters.Args {
+	return filters.NewArgs(
+		filters.KeyValuePair{Key: "name", Value: instanceName},
+		filters.KeyValuePair{Key: "label", Value: simCliPrefix + "=" + instanceName},
+	)
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseInstanceName(t *testing.T) {
+	assert := require.New(t)
+	for name, owner := range map[string]Owner{
+		"demo-v1":       {Workspace: "demo", Version: "v1"},
+		"demo-v2-v1":    {Workspace: "demo-v2", Version: "v1"},
+		"demo-v2-v12":   {Workspace: "demo-v2", Version: "v12"},
+		"my.ws-v3-v3":   {Workspace: "my.ws-v3", Version: "v3"},
+		"a-b-c-v2-v100": {Workspace: "a-b-c-v2", Version: "v100"},
+	} {
+		parsed, ok := ParseInstanceName(name)
+		assert.True(ok, name)
+		assert.Equal(owner, parsed, name)
+	}
+	for _, name := range []string{"", "demo", "v1", "-v1", "demo-v", "demo-1", "sim-gui-code-server"} {
+		_, ok := ParseInstanceName(name)
+		assert.False(ok, name)
+	}
+}
+
+func Test_ContainerOwner(t *testing.T) {
+	assert := require.New(t)
+
+	// The labels win over the name, which docker.InstanceName may have shortened
+	owner, ok := ContainerOwner(types.Container{Labels: map[string]string{
+		simCliPrefix:   "demo-0a1b2c3d-v1",
+		WorkspaceLabel: "demo v2",
+		VersionLabel:   "v1",
+	}})
+	assert.True(ok)
+	assert.Equal(Owner{Workspace: "demo v2", Version: "v1"}, owner)
+
+	owner, ok = ContainerOwner(types.Container{Labels: map[string]string{simCliPrefix: "demo-v2-v1"}})
+	assert.True(ok)
+	assert.Equal(Owner{Workspace: "demo-v2", Version: "v1"}, owner)
+
+	_, ok = ContainerOwner(types.Container{Labels: map[string]string{simCliPrefix: "issue-113"}})
+	assert.False(ok)
+}
+
+func Test_OwnerLabels(t *testing.T) {
+	assert := require.New(t)
+	labels := map[string]string{}
+	Owner{}.addLabels(labels)
+	assert.Empty(labels)
+	Owner{Workspace: "demo-v2", Version: "v1"}.addLabels(labels)
+	assert.Equal(map[string]string{WorkspaceLabel: "demo-v2", VersionLabel: "v1"}, labels)
+	Owner{Profile: "customers", Workspace: "demo-v2", Version: "v1"}.addLabels(labels)
+	assert.Equal(map[string]string{WorkspaceLabel: "demo-v2", VersionLabel: "v1", ProfileLabel: "customers"}, labels)
+	owner, ok := ContainerOwner(types.Container{Labels: labels})
+	assert.True(ok)
+	assert.Equal(Owner{Profile: "customers", Workspace: "demo-v2", Version: "v1"}, owner)
+
+	assert.Error(Extras{Labels: map[string]string{WorkspaceLabel: "other"}}.Validate())
+	assert.Error(Extras{Labels: map[string]string{VersionLabel: "v9"}}.Validate())
+	assert.Error(Extras{Labels: map[string]string{ProfileLabel: "other"}}.Validate())
+}
+
+func Test_InstanceFilters(t *testing.T) {
+	assert := require.New(t)
+	args := instanceFilters("demo-v2-v1")
+	assert.Equal([]string{"demo-v2-v1"}, args.Get("name"))
+	assert.Equal([]string{"sim-cli-managed=demo-v2-v1"}, args.Get("label"))
+}
+
+func Test_InstanceRow(t *testing.T) {
+	assert := require.New(t)
+	row := instanceRow(types.Container{Image: "sim-cli-managed:demo-v2-v1", Status: "Up", Labels: map[string]string{
+		simCliPrefix:  "demo-v2-v1",
+		bundleNameKey: "/data/bundle.zip",
+	}})
+	assert.Equal([]interface{}{"demo-v2-v1", "demo-v2", "v1", "/data/bundle.zip", "sim-cli-managed:demo-v2-v1", "Up", ""}, row)
+}
@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// apiServerPort is the port the simulated apiserver listens on inside the container
+const apiServerPort = 6443
+
+// portsKey labels a container with the additional ports it publishes, docker can't publish others on an
+// existing container so one created with others is created again
+const portsKey = "sim-cli-managed.ports"
+
+// PortMapping is where a port of a simulator container is reached
+type PortMapping struct {
+	ContainerPort uint16
+	Protocol      string
+	// Host is the host IP docker published the port on, or the IP of the container on another network
+	// than the default bridge network, where nothing is published
+	Host     string
+	HostPort uint16
+}
+
+// APIServerPublicPort returns the host port 6443/tcp of a container is published on.
+// Docker doesn't guarantee the order of Ports, so the mapping is looked up instead of taking the first one.
+func APIServerPublicPort(ports []types.Port) (uint16, error) {
+	var available []string
+	for _, p := range ports {
+		if p.PrivatePort == apiServerPort && p.Type == "tcp" && p.PublicPort != 0 {
+			return p.PublicPort, nil
+		}
+		available = append(available, formatPort(p))
+	}
+
+	if len(available) == 0 {
+		return 0, fmt.Errorf("no host port published for %d/tcp, container has no port mappings", apiServerPort)
+	}
+	return 0, fmt.Errorf("no host port published for %d/tcp, available mappings: %s", apiServerPort, strings.Join(available, ", "))
+}
+
+// formatPort renders a port mapping the way `docker ps` does, e.g. 0.0.0.0:32768->6443/tcp
+func formatPort(p types.Port) string {
+	if p.PublicPort == 0 {
+		return fmt.Sprintf("%d/%s", p.PrivatePort, p.Type)
+	}
+	return fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type)
+}
+
+// ValidatePorts fails for ports that can't be published in addition to the apiserver
+func ValidatePorts(ports []uint16) error {
+	seen := make(map[uint16]bool)
+	for _, port := range ports {
+		switch {
+		case port == 0:
+			return fmt.Errorf("port 0 can't be published")
+		case port == apiServerPort:
+			return fmt.Errorf("port %d is the apiserver, which is always published", port)
+		case seen[port]:
+			return fmt.Errorf("port %d is given twice", port)
+		}
+		seen[port] = true
+	}
+	return nil
+}
+
+// formatPorts renders ports for portsKey, sorted so the order they were asked for doesn't matter
+func formatPorts(ports []uint16) string {
+	sorted := append([]uint16(nil), ports...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	values := make([]string, len(sorted))
+	for i, port := range sorted {
+		values[i] = strconv.Itoa(int(port))
+	}
+	return strings.Join(values, ",")
+}
+
+// ContainerHasPorts reports whether a container publishes ports besides the apiserver, containers
+// created before ports could be published have none
+func ContainerHasPorts(ctr types.Container, ports []uint16) bool {
+	return ctr.Labels[portsKey] == formatPorts(ports)
+}
+
+// containerPorts returns the ports a container was created to publish besides the apiserver
+func containerPorts(ctr types.Container) []uint16 {
+	var ports []uint16
+	for _, value := range strings.Split(ctr.Labels[portsKey], ",") {
+		if port, err := strconv.ParseUint(value, 10, 16); err == nil {
+			ports = append(ports, uint16(port))
+		}
+	}
+	return ports
+}
+
+// ContainerPortMappings returns where the apiserver and the additional ports of a running container are
+// reached, sorted by container port. Ports docker publishes on both IPv4 and IPv6 are listed once.
+func ContainerPortMappings(ctr types.Container) []PortMapping {
+	mappings := []PortMapping{}
+	if ContainerNetwork(ctr) != DefaultNetwork {
+		ip, ok := containerNetworkIP(ctr)
+		if !ok {
+			return mappings
+		}
+		for _, port := range append([]uint16{apiServerPort}, containerPorts(ctr)...) {
+			mappings = append(mappings, PortMapping{ContainerPort: port, Protocol: "tcp", Host: ip, HostPort: port})
+		}
+	} else {
+		// Docker doesn't guarantee the order of Ports, the IPv4 binding is kept whichever comes first
+		seen := make(map[string]int)
+		for _, p := range ctr.Ports {
+			if p.PublicPort == 0 {
+				continue
+			}
+			mapping := PortMapping{ContainerPort: p.PrivatePort, Protocol: p.Type, Host: p.IP, HostPort: p.PublicPort}
+			key := fmt.Sprintf("%d/%s->%d", p.PrivatePort, p.Type, p.PublicPort)
+			if i, ok := seen[key]; ok {
+				if strings.Contains(mappings[i].Host, ":") {
+					mappings[i] = mapping
+				}
+				continue
+			}
+			seen[key] = len(mappings)
+			mappings = append(mappings, mapping)
+		}
+	}
+	sort.SliceStable(mappings, func(i, j int) bool {
+		if mappings[i].ContainerPort != mappings[j].ContainerPort {
+			return mappings[i].ContainerPort < mappings[j].ContainerPort
+		}
+		return mappings[i].Protocol < mappings[j].Protocol
+	})
+	return mappings
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_APIServerPublicPort(t *testing.T) {
+	assert := require.New(t)
+	ports := []types.Port{
+		{IP: "0.0.0.0", PrivatePort: 22, PublicPort: 32770, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 6443, PublicPort: 32771, Type: "udp"},
+		{PrivatePort: 8080, Type: "tcp"},
+		{IP: "0.0.0.0", PrivatePort: 6443, PublicPort: 32768, Type: "tcp"},
+		{IP: "::", PrivatePort: 6443, PublicPort: 32768, Type: "tcp"},
+	}
+
+	for i := 0; i < 20; i++ {
+		rand.Shuffle(len(ports), func(a, b int) { ports[a], ports[b] = ports[b], ports[a] })
+		port, err := APIServerPublicPort(ports)
+		assert.NoError(err)
+		assert.Equal(uint16(32768), port)
+	}
+}
+
+func Test_APIServerPublicPortMissing(t *testing.T) {
+	assert := require.New(t)
+
+	_, err := APIServerPublicPort([]types.Port{
+		{IP: "0.0.0.0", PrivatePort: 22, PublicPort: 32770, Type: "tcp"},
+		{PrivatePort: 6443, Type: "tcp"},
+	})
+	assert.EqualError(err, "no host port published for 6443/tcp, available mappings: 0.0.0.0:32770->22/tcp, 6443/tcp")
+
+	_, err = APIServerPublicPort(nil)
+	assert.EqualError(err, "no host port published for 6443/tcp, container has no port mappings")
+}
+
+func Test_ContainerPortMappings(t *testing.T) {
+	assert := require.New(t)
+	ctr := types.Container{
+		Labels: map[string]string{portsKey: formatPorts([]uint16{9090, 8080})},
+		Ports: []types.Port{
+			{IP: "::", PrivatePort: 9090, PublicPort: 32770, Type: "tcp"},
+			{IP: "0.0.0.0", PrivatePort: 9090, PublicPort: 32770, Type: "tcp"},
+			{IP: "0.0.0.0", PrivatePort: 6443, PublicPort: 32768, Type: "tcp"},
+			{IP: "0.0.0.0", PrivatePort: 8080, PublicPort: 32769, Type: "tcp"},
+			{IP: "::", PrivatePort: 8080, PublicPort: 32769, Type: "tcp"},
+			{PrivatePort: 2379, Type: "tcp"},
+		},
+	}
+	assert.Equal("8080,9090", ctr.Labels[portsKey])
+	assert.True(ContainerHasPorts(ctr, []uint16{9090, 8080}))
+	assert.False(ContainerHasPorts(ctr, []uint16{8080}))
+	assert.True(ContainerHasPorts(types.Container{}, nil), "containers created before ports could be published have none")
+
+	assert.Equal([]PortMapping{
+		{ContainerPort: 6443, Protocol: "tcp", Host: "0.0.0.0", HostPort: 32768},
+		{ContainerPort: 8080, Protocol: "tcp", Host: "0.0.0.0", HostPort: 32769},
+		{ContainerPort: 9090, Protocol: "tcp", Host: "0.0.0.0", HostPort: 32770},
+	}, ContainerPortMappings(ctr))
+
+	// nothing is published on other networks, the ports are reached by the IP of the container
+	ctr.HostConfig.NetworkMode = "rancher"
+	ctr.NetworkSettings = &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{"rancher": {IPAddress: "172.20.0.3"}}}
+	assert.Equal([]PortMapping{
+		{ContainerPort: 6443, Protocol: "tcp", Host: "172.20.0.3", HostPort: 6443},
+		{ContainerPort: 8080, Protocol: "tcp", Host: "172.20.0.3", HostPort: 8080},
+		{ContainerPort: 9090, Protocol: "tcp", Host: "172.20.0.3", HostPort: 9090},
+	}, ContainerPortMappings(ctr))
+}
+
+func Test_ValidatePorts(t *testing.T) {
+	assert := require.New(t)
+	assert.NoError(ValidatePorts(nil))
+	assert.NoError(ValidatePorts([]uint16{80, 8080}))
+	assert.EqualError(ValidatePorts([]uint16{0}), "port 0 can't be published")
+	assert.EqualError(ValidatePorts([]uint16{6443}), "port 6443 is the apiserver, which is always published")
+	assert.EqualError(ValidatePorts([]uint16{80, 80}), "port 80 is given twice")
+}
@@ -0,0 +1,276 @@
+package docker
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/sirupsen/logrus"
+)
+
+// States of a PullProgress
+const (
+	PullPulling = "pulling"
+	PullDone    = "done"
+	PullFailed  = "failed"
+)
+
+var (
+	// pullAttempts is how often a pull is tried before it fails
+	pullAttempts = 3
+	// pullRetryDelay is the wait before the second attempt of a pull, doubled for each further one
+	pullRetryDelay = 5 * time.Second
+)
+
+// layerStatuses are the statuses docker reports for the layers of a pull, true once the layer is
+// downloaded. Other statuses, e.g. the digest of the image, aren't about a layer.
+var layerStatuses = map[string]bool{
+	"Pulling fs layer":   false,
+	"Waiting":            false,
+	"Downloading":        false,
+	"Verifying Checksum": true,
+	"Download complete":  true,
+	"Extracting":         true,
+	"Pull complete":      true,
+	"Already exists":     true,
+}
+
+// LayerProgress is the download of a layer of a pulled image
+type LayerProgress struct {
+	ID string `json:"id"`
+	// Status is the last one docker reported, e.g. "Downloading" or "Pull complete"
+	Status     string `json:"status"`
+	Downloaded int64  `json:"downloaded"`
+	// Total is 0 until docker reports the size of the layer, and for layers that already exist
+	Total int64 `json:"total"`
+}
+
+// PullProgress is the progress of the pull of an image
+type PullProgress struct {
+	Image  string          `json:"image"`
+	State  string          `json:"state"`
+	Layers []LayerProgress `json:"layers"`
+	// Downloaded and Total sum the bytes of the layers whose size is known
+	Downloaded int64 `json:"downloaded"`
+	Total      int64 `json:"total"`
+	Percent    int   `json:"percent"`
+	// ETASeconds estimates the remaining download time from the rate of the current attempt, nil
+	// until something was downloaded
+	ETASeconds *int64 `json:"etaSeconds,omitempty"`
+	// Attempt is the number of the current or last attempt, starting at 1
+	Attempt    int        `json:"attempt"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// Error is why the last attempt failed, kept once the pull failed for good
+	Error string `json:"error,omitempty"`
+}
+
+// imagePull tracks a pull of an image through its attempts
+type imagePull struct {
+	lock     sync.Mutex
+	progress PullProgress
+	// attemptStartedAt is when the current attempt started, the rate of the ETA is measured from it
+	attemptStartedAt time.Time
+
+	// done is closed once the pull succeeded or failed for good, err is set before
+	done chan struct{}
+	err  error
+}
+
+func newImagePull(imageName string, now time.Time) *imagePull {
+	return &imagePull{
+		progress: PullProgress{Image: imageName, State: PullPulling, Layers: []LayerProgress{}, StartedAt: now},
+		done:     make(chan struct{}),
+	}
+}
+
+// startAttempt starts counting the layers of an attempt from scratch, those downloaded by a previous
+// attempt are reported as existing
+func (p *imagePull) startAttempt(attempt int, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.progress.Attempt = attempt
+	p.progress.Layers = []LayerProgress{}
+	p.attemptStartedAt = now
+}
+
+// update records a message of the pull output, messages not about a layer are ignored
+func (p *imagePull) update(msg *jsonmessage.JSONMessage) {
+	downloaded, ok := layerStatuses[msg.Status]
+	if !ok || msg.ID == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	layer := p.layer(msg.ID)
+	layer.Status = msg.Status
+	// Extracting reports the size of the layer too, it is used when its download went unreported
+	if msg.Progress != nil && msg.Progress.Total > 0 && (msg.Status == "Downloading" || layer.Total == 0) {
+		layer.Total = msg.Progress.Total
+	}
+	if msg.Status == "Downloading" && msg.Progress != nil {
+		layer.Downloaded = msg.Progress.Current
+	} else if downloaded {
+		layer.Downloaded = layer.Total
+	}
+}
+
+// layer returns the layer with id, added when it wasn't reported before
+func (p *imagePull) layer(id string) *LayerProgress {
+	for i := range p.progress.Layers {
+		if p.progress.Layers[i].ID == id {
+			return &p.progress.Layers[i]
+		}
+	}
+	p.progress.Layers = append(p.progress.Layers, LayerProgress{ID: id})
+	return &p.progress.Layers[len(p.progress.Layers)-1]
+}
+
+// finish records the outcome of the last attempt and releases the waiters
+func (p *imagePull) finish(err error, now time.Time) {
+	p.lock.Lock()
+	p.progress.State = PullDone
+	if err != nil {
+		p.progress.State = PullFailed
+		p.progress.Error = err.Error()
+	}
+	p.progress.FinishedAt = &now
+	p.err = err
+	p.lock.Unlock()
+	close(p.done)
+}
+
+// snapshot returns a copy of the progress with its totals and ETA as of now
+func (p *imagePull) snapshot(now time.Time) PullProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	progress := p.progress
+	progress.Layers = append([]LayerProgress{}, p.progress.Layers...)
+	for _, layer := range progress.Layers {
+		progress.Downloaded += layer.Downloaded
+		progress.Total += layer.Total
+	}
+	if progress.Total > 0 {
+		progress.Percent = int(progress.Downloaded * 100 / progress.Total)
+	}
+	elapsed := now.Sub(p.attemptStartedAt).Seconds()
+	if progress.State == PullPulling && progress.Downloaded > 0 && elapsed > 0 {
+		rate := float64(progress.Downloaded) / elapsed
+		eta := int64(math.Ceil(float64(progress.Total-progress.Downloaded) / rate))
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
+
+// imagePulls holds the last pull of every image. The clients of a process share the docker daemon, so
+// they share the pulls too.
+var imagePulls = struct {
+	lock  sync.Mutex
+	pulls map[string]*imagePull
+}{pulls: make(map[string]*imagePull)}
+
+// PullImage pulls a docker image, failed attempts are retried. A pull of the image that is already
+// running is waited for instead of starting another one.
+func (c *Client) PullImage(imageName string) error {
+	pull := c.startPull(imageName)
+	<-pull.done
+	return pull.err
+}
+
+// EnsureImage returns once an image is present locally, pulling it when it isn't. The pull goes on in
+// the background when ctx is done first.
+func (c *Client) EnsureImage(ctx context.Context, imageName string) error {
+	if _, err := c.InspectImage(imageName); err == nil {
+		return nil
+	}
+	pull := c.startPull(imageName)
+	select {
+	case <-pull.done:
+		return pull.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startPull starts pulling an image in the background, unless a pull of it is running already
+func (c *Client) startPull(imageName string) *imagePull {
+	imagePulls.lock.Lock()
+	defer imagePulls.lock.Unlock()
+	if pull, ok := imagePulls.pulls[imageName]; ok {
+		select {
+		case <-pull.done:
+		default:
+			return pull
+		}
+	}
+	pull := newImagePull(imageName, time.Now())
+	imagePulls.pulls[imageName] = pull
+	go c.pull(pull)
+	return pull
+}
+
+// pull runs the attempts of a pull until one succeeds or they are used up
+func (c *Client) pull(p *imagePull) {
+	delay := pullRetryDelay
+	var err error
+	for attempt := 1; attempt <= pullAttempts; attempt++ {
+		if attempt > 1 {
+			logrus.Warnf("pulling %s failed, retrying in %s: %v", p.progress.Image, delay, err)
+			select {
+			case <-time.After(delay):
+			case <-c.ctx.Done():
+				p.finish(c.ctx.Err(), time.Now())
+				return
+			}
+			delay *= 2
+		}
+		p.startAttempt(attempt, time.Now())
+		if err = c.pullOnce(p); err == nil {
+			break
+		}
+	}
+	p.finish(err, time.Now())
+}
+
+// pullOnce makes an attempt of a pull, recording the progress of its layers
+func (c *Client) pullOnce(p *imagePull) error {
+	reader, err := c.APIClient.ImagePull(c.ctx, p.progress.Image, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	return readResponse(reader, nil, p.update)
+}
+
+// PullStatus returns the last pull of every image pulled since the process started, by image
+func (c *Client) PullStatus() []PullProgress {
+	imagePulls.lock.Lock()
+	pulls := make([]*imagePull, 0, len(imagePulls.pulls))
+	for _, pull := range imagePulls.pulls {
+		pulls = append(pulls, pull)
+	}
+	imagePulls.lock.Unlock()
+
+	now := time.Now()
+	status := make([]PullProgress, 0, len(pulls))
+	for _, pull := range pulls {
+		status = append(status, pull.snapshot(now))
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Image < status[j].Image })
+	return status
+}
+
+// ImagePullProgress returns the last pull of an image, false when it wasn't pulled since the process started
+func (c *Client) ImagePullProgress(imageName string) (PullProgress, bool) {
+	imagePulls.lock.Lock()
+	pull, ok := imagePulls.pulls[imageName]
+	imagePulls.lock.Unlock()
+	if !ok {
+		return PullProgress{}, false
+	}
+	return pull.snapshot(time.Now()), true
+}
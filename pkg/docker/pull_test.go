@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordedPull is the output of pulling an image whose first layer exists locally, cut while its
+// third layer downloads
+const recordedPull = `{"status":"Pulling from rancher/support-bundle-kit","id":"master-head"}
+{"status":"Already exists","progressDetail":{},"id":"a1"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"b2"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"c3"}
+{"status":"Waiting","progressDetail":{},"id":"c3"}
+{"status":"Downloading","progressDetail":{"current":1000,"total":4000},"progress":"[============>          ]  1kB/4kB","id":"b2"}
+{"status":"Downloading","progressDetail":{"current":4000,"total":4000},"progress":"[=====================>]  4kB/4kB","id":"b2"}
+{"status":"Verifying Checksum","progressDetail":{},"id":"b2"}
+{"status":"Download complete","progressDetail":{},"id":"b2"}
+{"status":"Extracting","progressDetail":{"current":4000,"total":4000},"progress":"[=====================>]  4kB/4kB","id":"b2"}
+{"status":"Downloading","progressDetail":{"current":500,"total":6000},"progress":"[=>                    ]  500B/6kB","id":"c3"}
+`
+
+// recordedPullEnd finishes recordedPull
+const recordedPullEnd = `{"status":"Pull complete","progressDetail":{},"id":"b2"}
+{"status":"Downloading","progressDetail":{"current":6000,"total":6000},"progress":"[=====================>]  6kB/6kB","id":"c3"}
+{"status":"Download complete","progressDetail":{},"id":"c3"}
+{"status":"Pull complete","progressDetail":{},"id":"c3"}
+{"status":"Digest: sha256:d1g3st"}
+{"status":"Status: Downloaded newer image for rancher/support-bundle-kit:master-head"}
+`
+
+func Test_PullProgress(t *testing.T) {
+	assert := require.New(t)
+	now := time.Date(2024, 11, 18, 9, 0, 0, 0, time.UTC)
+	pull := newImagePull("rancher/support-bundle-kit:master-head", now)
+	pull.startAttempt(1, now)
+	assert.NoError(readResponse(io.NopCloser(strings.NewReader(recordedPull)), nil, pull.update))
+
+	eta := int64(11)
+	assert.Equal(PullProgress{
+		Image: "rancher/support-bundle-kit:master-head",
+		State: PullPulling,
+		Layers: []LayerProgress{
+			{ID: "a1", Status: "Already exists"},
+			{ID: "b2", Status: "Extracting", Downloaded: 4000, Total: 4000},
+			{ID: "c3", Status: "Downloading", Downloaded: 500, Total: 6000},
+		},
+		Downloaded: 4500,
+		Total:      10000,
+		Percent:    45,
+		ETASeconds: &eta, // 500 bytes a second
+		Attempt:    1,
+		StartedAt:  now,
+	}, pull.snapshot(now.Add(9*time.Second)))
+
+	assert.NoError(readResponse(io.NopCloser(strings.NewReader(recordedPullEnd)), nil, pull.update))
+	pull.finish(nil, now.Add(12*time.Second))
+	progress := pull.snapshot(now.Add(20 * time.Second))
+	assert.Equal(PullDone, progress.State)
+	assert.Equal(100, progress.Percent)
+	assert.Equal(int64(10000), progress.Downloaded)
+	assert.Nil(progress.ETASeconds)
+	assert.Equal(now.Add(12*time.Second), *progress.FinishedAt)
+}
+
+func Test_PullImageRetries(t *testing.T) {
+	assert := require.New(t)
+	delay := pullRetryDelay
+	pullRetryDelay = time.Millisecond
+	t.Cleanup(func() { pullRetryDelay = delay })
+
+	c, requests := newRecordingClient(t, "", map[string]string{
+		"/images/create": recordedPull + `{"errorDetail":{"message":"toomanyrequests: rate limit exceeded"},"error":"toomanyrequests: rate limit exceeded"}` + "\n",
+	})
+	assert.EqualError(c.PullImage("example/pull-retries:v1"), "toomanyrequests: rate limit exceeded")
+	assert.Equal([]string{"POST /images/create", "POST /images/create", "POST /images/create"}, requests())
+
+	progress, ok := c.ImagePullProgress("example/pull-retries:v1")
+	assert.True(ok)
+	assert.Equal(PullFailed, progress.State)
+	assert.Equal(3, progress.Attempt)
+	assert.Equal("toomanyrequests: rate limit exceeded", progress.Error)
+	assert.Contains(c.PullStatus(), progress)
+}
+
+func Test_EnsureImage(t *testing.T) {
+	assert := require.New(t)
+	c, requests := newRecordingClient(t, "", map[string]string{
+		"/images/example/ensure-present:v1/json": `{"Id": "sha256:present"}`,
+		"/images/create":                         recordedPull + recordedPullEnd,
+	})
+
+	// a present image isn't pulled
+	assert.NoError(c.EnsureImage(context.Background(), "example/ensure-present:v1"))
+	assert.Equal([]string{"GET /images/example/ensure-present:v1/json"}, requests())
+	_, ok := c.ImagePullProgress("example/ensure-present:v1")
+	assert.False(ok)
+
+	assert.NoError(c.EnsureImage(context.Background(), "example/ensure-missing:v1"))
+	assert.Contains(requests(), "POST /images/create")
+	progress, ok := c.ImagePullProgress("example/ensure-missing:v1")
+	assert.True(ok)
+	assert.Equal(PullDone, progress.State)
+	assert.Equal(100, progress.Percent)
+	assert.Equal(1, progress.Attempt)
+}
@@ -4,44 +4,71 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/bndr/gotabulate"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 )
 
-// RunContainer runs an instance of support-bundle-kit simulator in a docker container image
-func (c *Client) RunContainer(instanceName, bundlePath string) error {
-	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
-	resp, err := c.APIClient.ContainerCreate(c.ctx, &container.Config{
-		Image: imageName,
-		Cmd:   []string{"support-bundle-kit", "simulator", "reset", "--bundle-path", "/bundle"},
-		ExposedPorts: map[nat.Port]struct{}{
-			"6443/tcp": struct{}{},
-		},
-		Tty: false,
-		Labels: map[string]string{
-			bundleNameKey: bundlePath,
-			simCliPrefix:  instanceName,
-		},
-	}, &container.HostConfig{
+// RunContainer runs an instance of support-bundle-kit simulator in a docker container image. An empty
+// networkName attaches it to the default bridge network with the apiserver and ports published on host
+// ports, on any other network they are only reachable by the IP of the container there. A network
+// docker doesn't have is refused with an *UnknownNetworkError. The extras are added to the labels and
+// environment of the container, the owner is set in its labels.
+func (c *Client) RunContainer(instanceName string, owner Owner, bundlePath, networkName string, ports []uint16, extras Extras) error {
+	if err := c.CheckNetwork(networkName); err != nil {
+		return err
+	}
+	exposed := map[nat.Port]struct{}{
+		"6443/tcp": struct{}{},
+	}
+	for _, port := range ports {
+		exposed[nat.Port(fmt.Sprintf("%d/tcp", port))] = struct{}{}
+	}
+	hostConfig := &container.HostConfig{
 		AutoRemove:  false,
-		NetworkMode: "bridge",
-		PortBindings: map[nat.Port][]nat.PortBinding{
-			"6443/tcp": {
+		NetworkMode: container.NetworkMode(networkName),
+	}
+	if networkName == "" {
+		hostConfig.NetworkMode = DefaultNetwork
+		hostConfig.PortBindings = make(map[nat.Port][]nat.PortBinding, len(exposed))
+		for port := range exposed {
+			hostConfig.PortBindings[port] = []nat.PortBinding{
 				{
 					HostIP: "0.0.0.0",
 				},
-			},
-		},
-	},
-		nil, nil, instanceName)
+			}
+		}
+	}
+	own := map[string]string{
+		bundleNameKey: bundlePath,
+		simCliPrefix:  instanceName,
+	}
+	if len(ports) > 0 {
+		own[portsKey] = formatPorts(ports)
+	}
+	owner.addLabels(own)
+
+	imageName := fmt.Sprintf("%s:%s", simCliPrefix, instanceName)
+	resp, err := c.APIClient.ContainerCreate(c.ctx, &container.Config{
+		Image:        imageName,
+		Cmd:          []string{"support-bundle-kit", "simulator", "reset", "--bundle-path", "/bundle"},
+		ExposedPorts: exposed,
+		Tty:          false,
+		Env:          extras.env(),
+		Labels:       extras.labels(own),
+	}, hostConfig, nil, nil, instanceName)
 	if err != nil {
 		return fmt.Errorf("error creating container %s: %w", instanceName, err)
 	}
@@ -55,18 +82,20 @@ func (c *Client) RunContainer(instanceName, bundlePath string) error {
 
 // FindRunningContainer attempts to find instance of simulator associated with the instanceName
 func (c *Client) FindRunningContainer(instanceName string) ([]types.Container, error) {
-	filters := filters.NewArgs(filters.KeyValuePair{Key: "name", Value: instanceName})
-	return c.APIClient.ContainerList(c.ctx, container.ListOptions{
-		Filters: filters,
-	})
+	return c.FindRunningContainerContext(c.ctx, instanceName)
+}
 
+// FindRunningContainerContext is FindRunningContainer giving up when ctx is cancelled
+func (c *Client) FindRunningContainerContext(ctx context.Context, instanceName string) ([]types.Container, error) {
+	return c.APIClient.ContainerList(ctx, container.ListOptions{
+		Filters: instanceFilters(instanceName),
+	})
 }
 
 // FindContainer attempts to find instance of simulator associated with the instanceName (running or stopped)
 func (c *Client) FindContainer(instanceName string) ([]types.Container, error) {
-	filters := filters.NewArgs(filters.KeyValuePair{Key: "name", Value: instanceName})
 	return c.APIClient.ContainerList(c.ctx, container.ListOptions{
-		Filters: filters,
+		Filters: instanceFilters(instanceName),
 		All:     true,
 	})
 }
@@ -92,10 +121,16 @@ func (c *Client) StartContainer(containerID string) error {
 }
 
 // QueryExposedMapping attempts to find details of host/port needed for configuring the kubeconfig needed
-// to access the instance running in associated container
+// to access the instance running in associated container. Containers without a published apiserver
+// port, like those attached to another network than the default bridge, are reached by their IP there.
 func (c *Client) QueryExposedMapping(instanceName string) (string, string, error) {
+	return c.QueryExposedMappingContext(c.ctx, instanceName)
+}
+
+// QueryExposedMappingContext is QueryExposedMapping giving up when ctx is cancelled
+func (c *Client) QueryExposedMappingContext(ctx context.Context, instanceName string) (string, string, error) {
 	var endpoint, port string
-	containers, err := c.FindRunningContainer(instanceName)
+	containers, err := c.FindRunningContainerContext(ctx, instanceName)
 	if err != nil {
 		return endpoint, port, fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
 	}
@@ -104,7 +139,14 @@ func (c *Client) QueryExposedMapping(instanceName string) (string, string, error
 		return endpoint, port, fmt.Errorf("expected one container matching name %s, got %d", instanceName, len(containers))
 	}
 
-	port = fmt.Sprintf("%d", containers[0].Ports[0].PublicPort)
+	publicPort, err := APIServerPublicPort(containers[0].Ports)
+	if err != nil {
+		if ip, ok := containerNetworkIP(containers[0]); ok {
+			return ip, fmt.Sprintf("%d", apiServerPort), nil
+		}
+		return endpoint, port, fmt.Errorf("error finding exposed port of %s: %w", instanceName, err)
+	}
+	port = fmt.Sprintf("%d", publicPort)
 	netconfig, err := url.Parse(c.Endpoint.Host)
 	if err != nil {
 		return endpoint, port, fmt.Errorf("error parsing endpoint info: %w", err)
@@ -119,17 +161,31 @@ func (c *Client) QueryExposedMapping(instanceName string) (string, string, error
 
 // FindAllSimManagedInstances returns details of all sim-cli managed instances and presents them in a tabular form
 func (c *Client) FindAllSimManagedInstances() error {
+	containers, err := c.ListManagedContainers()
+	if err != nil {
+		return err
+	}
+
+	generateTable(containers)
+	return nil
+}
+
+// InstanceOf returns the instance name a managed container was created for
+func InstanceOf(ctr types.Container) string {
+	return ctr.Labels[simCliPrefix]
+}
+
+// ListManagedContainers returns the simulator containers of every instance, running or not
+func (c *Client) ListManagedContainers() ([]types.Container, error) {
 	filters := filters.NewArgs(filters.KeyValuePair{Key: "label", Value: simCliPrefix})
 	containers, err := c.APIClient.ContainerList(c.ctx, container.ListOptions{
 		Filters: filters,
 		All:     true,
 	})
 	if err != nil {
-		return fmt.Errorf("error listing containers: %w", err)
+		return nil, fmt.Errorf("error listing containers: %w", err)
 	}
-
-	generateTable(containers)
-	return nil
+	return containers, nil
 }
 
 // generateTable is a helper method to return results in a tabular form
@@ -139,19 +195,14 @@ func generateTable(containers []types.Container) {
 	// gotabulate does no handle empty table and panics
 	// so for now we send an empty row if there is nothing returned
 	if len(containers) == 0 {
-		results = append(results, []interface{}{"", "", "", "", ""})
+		results = append(results, []interface{}{"", "", "", "", "", "", ""})
 	}
 
 	for _, v := range containers {
-		name := v.Labels[simCliPrefix]
-		bundlePath := v.Labels[bundleNameKey]
-		image := v.Image
-		status := v.Status
-		port := fmt.Sprintf("%d", v.Ports[0].PublicPort)
-		results = append(results, []interface{}{name, bundlePath, image, status, port})
+		results = append(results, instanceRow(v))
 	}
 	table := gotabulate.Create(results)
-	table.SetHeaders([]string{"name", "bundlePath", "image", "status", "exposed port"})
+	table.SetHeaders([]string{"name", "workspace", "version", "bundlePath", "image", "status", "exposed port"})
 	table.SetEmptyString("None")
 	table.SetAlign("right")
 	table.SetMaxCellSize(40)
@@ -159,9 +210,49 @@ func generateTable(containers []types.Container) {
 	fmt.Println(table.Render("grid"))
 }
 
-// ReadFile will read a specific file from a running container and return the results
+// instanceRow is the row of a container in the table of generateTable, the workspace and version are
+// empty for containers of the CLI
+func instanceRow(v types.Container) []interface{} {
+	name := InstanceOf(v)
+	owner, _ := ContainerOwner(v)
+	bundlePath := v.Labels[bundleNameKey]
+	image := v.Image
+	status := v.Status
+	// stopped containers have no published ports, left empty so the table shows None
+	var port string
+	if publicPort, err := APIServerPublicPort(v.Ports); err == nil {
+		port = fmt.Sprintf("%d", publicPort)
+	}
+	return []interface{}{name, owner.Workspace, owner.Version, bundlePath, image, status, port}
+}
+
+// MaxReadFileBytes caps the files ReadFile returns, they are buffered in memory
+const MaxReadFileBytes = 16 << 20
+
+// FileTooLargeError is returned when a file read from a container is larger than the limit
+type FileTooLargeError struct {
+	Path  string
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %s is %d bytes, larger than the limit of %d bytes", e.Path, e.Size, e.Limit)
+}
+
+// ErrFileNotFound is returned by ReadFile when the file doesn't exist in the container, files the
+// container writes once it started are missing for a moment, see ReadFileRetry
+var ErrFileNotFound = errors.New("file not found in the container")
+
+// ReadFile will read a specific file from a running container and return the results, files larger
+// than MaxReadFileBytes return a *FileTooLargeError
 func (c *Client) ReadFile(name string, path string) ([]byte, error) {
-	containers, err := c.FindRunningContainer(name)
+	return c.ReadFileContext(c.ctx, name, path, MaxReadFileBytes)
+}
+
+// ReadFileContext is ReadFile giving up when ctx is cancelled, with a limit of maxBytes
+func (c *Client) ReadFileContext(ctx context.Context, name string, path string, maxBytes int64) ([]byte, error) {
+	containers, err := c.FindRunningContainerContext(ctx, name)
 	if err != nil {
 		return nil, fmt.Errorf("error listing containers matching name %s: %w", name, err)
 	}
@@ -169,14 +260,48 @@ func (c *Client) ReadFile(name string, path string) ([]byte, error) {
 	if len(containers) != 1 {
 		return nil, fmt.Errorf("expected one container matching name %s, got %d", name, len(containers))
 	}
-	contents, _, err := c.APIClient.CopyFromContainer(c.ctx, containers[0].ID, path)
+	contents, stat, err := c.APIClient.CopyFromContainer(ctx, containers[0].ID, path)
+	if errdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("error reading file %s: %w: %w", path, ErrFileNotFound, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading file %s: %w", path, err)
 	}
-	tr := tar.NewReader(contents)
+	defer contents.Close()
+	if stat.Size > maxBytes {
+		return nil, &FileTooLargeError{Path: path, Size: stat.Size, Limit: maxBytes}
+	}
+	return readTarFile(contents, path, maxBytes)
+}
+
+// ReadFileRetry calls read again while the file it reads doesn't exist, waiting backoff and twice as
+// long after each attempt, until wait passed. It then returns the ErrFileNotFound of the last attempt.
+func ReadFileRetry(ctx context.Context, wait, backoff time.Duration, read func() ([]byte, error)) ([]byte, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		content, err := read()
+		if !errors.Is(err, ErrFileNotFound) {
+			return content, err
+		}
+		pause := min(backoff, time.Until(deadline))
+		if pause <= 0 {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pause):
+		}
+		backoff *= 2
+	}
+}
+
+// readTarFile returns the content of the first file of a tar archive, up to maxBytes
+func readTarFile(r io.Reader, path string, maxBytes int64) ([]byte, error) {
+	tr := tar.NewReader(r)
 	buf := new(bytes.Buffer)
 	for {
-		_, err := tr.Next()
+		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
@@ -184,8 +309,18 @@ func (c *Client) ReadFile(name string, path string) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error reading from tar archive: %w", err)
 		}
+		if header.Size > maxBytes {
+			return nil, &FileTooLargeError{Path: path, Size: header.Size, Limit: maxBytes}
+		}
 
-		buf.ReadFrom(tr)
+		// The header may not tell the truth, the copy is capped as well
+		n, err := buf.ReadFrom(io.LimitReader(tr, maxBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("error reading from tar archive: %w", err)
+		}
+		if n > maxBytes {
+			return nil, &FileTooLargeError{Path: path, Size: n, Limit: maxBytes}
+		}
 		return buf.Bytes(), nil
 	}
 	return nil, nil
@@ -194,11 +329,7 @@ func (c *Client) ReadFile(name string, path string) ([]byte, error) {
 // RemoveContainer attempts to find and remove a container associated with given instanceName
 func (c *Client) RemoveContainer(instanceName string) error {
 	// Also check for stopped containers
-	filters := filters.NewArgs(filters.KeyValuePair{Key: "name", Value: instanceName})
-	allContainers, err := c.APIClient.ContainerList(c.ctx, container.ListOptions{
-		Filters: filters,
-		All:     true,
-	})
+	allContainers, err := c.FindContainer(instanceName)
 	if err != nil {
 		return fmt.Errorf("error listing all containers matching name %s: %w", instanceName, err)
 	}
@@ -221,6 +352,23 @@ func (c *Client) RemoveContainer(instanceName string) error {
 
 // WaitForLogMessage tails the container logs and waits for a specific message.
 func (c *Client) WaitForLogMessage(instanceName, message string) error {
+	found := false
+	err := c.FollowLogs(instanceName, func(line string) bool {
+		found = strings.Contains(line, message)
+		return !found
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("container %s stopped before logging %q", instanceName, message)
+	}
+	return nil
+}
+
+// FollowLogs calls fn with every log line the running container wrote since it was last started,
+// until fn returns false or the container stops. Logs of previous runs are skipped.
+func (c *Client) FollowLogs(instanceName string, fn func(line string) bool) error {
 	containers, err := c.FindRunningContainer(instanceName)
 	if err != nil {
 		return fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
@@ -229,25 +377,61 @@ func (c *Client) WaitForLogMessage(instanceName, message string) error {
 		return fmt.Errorf("container %s not found", instanceName)
 	}
 
-	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true}
+	inspect, err := c.APIClient.ContainerInspect(c.ctx, containers[0].ID)
+	if err != nil {
+		return fmt.Errorf("error inspecting container %s: %w", instanceName, err)
+	}
+
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true, Since: inspect.State.StartedAt}
 	out, err := c.APIClient.ContainerLogs(c.ctx, containers[0].ID, options)
 	if err != nil {
 		return fmt.Errorf("error getting container logs: %w", err)
 	}
 	defer out.Close()
 
-	scanner := bufio.NewScanner(out)
+	// The container has no TTY, so stdout and stderr are multiplexed
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, out)
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	scanner := bufio.NewScanner(pr)
 	for scanner.Scan() {
-		text := scanner.Text()
-		if strings.Contains(text, message) {
+		if !fn(scanner.Text()) {
 			return nil
 		}
 	}
 	return scanner.Err()
 }
 
-// RunCodeServer starts a code-server container
-func (c *Client) RunCodeServer(instanceName string) (string, string, error) {
+// ErrContainerNotRunning is returned by PublishedPort when the container doesn't exist or is stopped
+var ErrContainerNotRunning = errors.New("container is not running")
+
+// PublishedPort returns the host port a port of a running container is published on, e.g. 8080/tcp.
+// Containers published on a random port get another one when they are restarted.
+func (c *Client) PublishedPort(instanceName string, port string) (string, error) {
+	inspect, err := c.APIClient.ContainerInspect(c.ctx, instanceName)
+	if errdefs.IsNotFound(err) {
+		return "", ErrContainerNotRunning
+	}
+	if err != nil {
+		return "", fmt.Errorf("error inspecting container: %w", err)
+	}
+	if inspect.State == nil || !inspect.State.Running {
+		return "", ErrContainerNotRunning
+	}
+	bindings := inspect.NetworkSettings.Ports[nat.Port(port)]
+	if len(bindings) == 0 {
+		return "", fmt.Errorf("port %s of %s isn't published", port, instanceName)
+	}
+	return bindings[0].HostPort, nil
+}
+
+// RunCodeServer starts a code-server container and returns the host port it is published on and its
+// ID, the extras are added to its labels and environment when it is created
+func (c *Client) RunCodeServer(instanceName string, extras Extras) (string, string, error) {
 	// Check if container exists (running or stopped)
 	containers, err := c.FindContainer(instanceName)
 	if err != nil {
@@ -257,7 +441,10 @@ func (c *Client) RunCodeServer(instanceName string) (string, string, error) {
 	if len(containers) == 0 {
 		// Create container
 		imageName := "codercom/code-server:latest"
-		// We don't explicitly pull here, assuming Docker daemon handles it or it's present.
+		// The image is pulled in the background when the server starts, it may still be on its way
+		if err := c.EnsureImage(c.ctx, imageName); err != nil {
+			return "", "", fmt.Errorf("error pulling code-server image: %w", err)
+		}
 
 		resp, err := c.APIClient.ContainerCreate(c.ctx, &container.Config{
 			Image: imageName,
@@ -266,9 +453,10 @@ func (c *Client) RunCodeServer(instanceName string) (string, string, error) {
 				"8080/tcp": {},
 			},
 			Tty: false,
-			Labels: map[string]string{
+			Env: extras.env(),
+			Labels: extras.labels(map[string]string{
 				simCliPrefix: instanceName,
-			},
+			}),
 		}, &container.HostConfig{
 			AutoRemove: true,
 			PortBindings: map[nat.Port][]nat.PortBinding{
@@ -305,7 +493,7 @@ func (c *Client) RunCodeServer(instanceName string) (string, string, error) {
 
 	bindings := inspect.NetworkSettings.Ports["8080/tcp"]
 	if len(bindings) > 0 {
-		return fmt.Sprintf("http://localhost:%s", bindings[0].HostPort), inspect.ID, nil
+		return bindings[0].HostPort, inspect.ID, nil
 	}
 
 	return "", "", fmt.Errorf("failed to get exposed port for code-server")
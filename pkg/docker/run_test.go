@@ -1,9 +1,14 @@
 package docker
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -14,7 +19,7 @@ func Test_ContainerLifeCycle(t *testing.T) {
 	assert.NoError(err)
 	err = client.CreateImage("issue-113", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "rancher/support-bundle-kit:master-head")
 	assert.NoError(err)
-	err = client.RunContainer("issue-113", "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip")
+	err = client.RunContainer("issue-113", Owner{}, "testdata/supportbundle_f159fbe2-dae7-4606-b81c-f54e1a562c99_2024-11-18T04-34-27Z.zip", "", nil, Extras{})
 	assert.NoError(err)
 	contents, err := client.ReadFile("issue-7007", simKubeConfigPath)
 	assert.NoError(err)
@@ -29,3 +34,62 @@ func Test_ContainerLifeCycle(t *testing.T) {
 	assert.NoError(err)
 	assert.NoError(os.Remove(file.Name()), "expected no error while cleaning up temp file")
 }
+
+func Test_ReadTarFile(t *testing.T) {
+	assert := require.New(t)
+	archive := func(header int64, content string) *bytes.Buffer {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		assert.NoError(tw.WriteHeader(&tar.Header{Name: "admin.kubeconfig", Mode: 0600, Size: header}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(err)
+		tw.Flush()
+		return &buf
+	}
+
+	content, err := readTarFile(archive(9, "apiVersio"), "admin.kubeconfig", 16)
+	assert.NoError(err)
+	assert.Equal("apiVersio", string(content))
+
+	_, err = readTarFile(archive(17, "apiVersion: v1\nk"), "admin.kubeconfig", 16)
+	var tooLarge *FileTooLargeError
+	assert.ErrorAs(err, &tooLarge)
+	assert.Equal(int64(17), tooLarge.Size)
+
+	content, err = readTarFile(&bytes.Buffer{}, "admin.kubeconfig", 16)
+	assert.NoError(err)
+	assert.Nil(content)
+}
+
+func Test_ReadFileRetry(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	reads := 0
+	readAfter := func(missing int) func() ([]byte, error) {
+		reads = 0
+		return func() ([]byte, error) {
+			reads++
+			if reads <= missing {
+				return nil, fmt.Errorf("error reading file admin.kubeconfig: %w", ErrFileNotFound)
+			}
+			return []byte("apiVersion: v1"), nil
+		}
+	}
+
+	content, err := ReadFileRetry(ctx, time.Second, time.Millisecond, readAfter(2))
+	assert.NoError(err)
+	assert.Equal("apiVersion: v1", string(content))
+	assert.Equal(3, reads)
+
+	// Still missing once wait passed
+	_, err = ReadFileRetry(ctx, 20*time.Millisecond, time.Millisecond, readAfter(1000))
+	assert.ErrorIs(err, ErrFileNotFound)
+	assert.Greater(reads, 1)
+
+	// Other errors aren't retried
+	_, err = ReadFileRetry(ctx, time.Second, time.Millisecond, func() ([]byte, error) {
+		reads++
+		return nil, errors.New("container is restarting")
+	})
+	assert.EqualError(err, "container is restarting")
+}
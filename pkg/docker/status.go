@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ContainerStatus is the state of the container of a simulator
+type ContainerStatus struct {
+	ID string
+	// State is the docker state, e.g. created, running or exited
+	State     string
+	StartedAt time.Time
+	// FinishedAt and ExitCode are only set once the container exited
+	FinishedAt time.Time
+	ExitCode   int
+	Image      string
+	// Labels are the labels of the container, including the extras it was created with
+	Labels map[string]string
+	// ImageCreatedAt is zero when the image is gone
+	ImageCreatedAt time.Time
+	// ImageLabels are the labels of the image, including those of the support-bundle-kit base image
+	ImageLabels map[string]string
+	// APIServerPort is the host port of the apiserver, 0 when the container isn't running
+	APIServerPort uint16
+	// Ports are where the apiserver and the additional ports are reached while the container runs
+	Ports []PortMapping
+	// Logs holds the last lines of an exited container, both stdout and stderr
+	Logs []string
+}
+
+// ContainerStatus returns the status of the container of instanceName, nil when there's none.
+// logLines is the number of log lines included when the container exited.
+func (c *Client) ContainerStatus(instanceName string, logLines int) (*ContainerStatus, error) {
+	containers, err := c.FindContainer(instanceName)
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers matching name %s: %w", instanceName, err)
+	}
+	// The name filter matches substrings, ws-v1 would match ws-v10 as well
+	var found *types.Container
+	for i := range containers {
+		for _, name := range containers[i].Names {
+			if strings.TrimPrefix(name, "/") == instanceName {
+				found = &containers[i]
+			}
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	inspect, err := c.APIClient.ContainerInspect(c.ctx, found.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container %s: %w", instanceName, err)
+	}
+	status := &ContainerStatus{ID: found.ID, Image: found.Image}
+	if inspect.Config != nil {
+		status.Image = inspect.Config.Image
+		status.Labels = inspect.Config.Labels
+	}
+	if inspect.State != nil {
+		status.State = inspect.State.Status
+		status.StartedAt = parseDockerTime(inspect.State.StartedAt)
+		if inspect.State.Status == "exited" {
+			status.FinishedAt = parseDockerTime(inspect.State.FinishedAt)
+			status.ExitCode = inspect.State.ExitCode
+		}
+	}
+	if status.State == "running" {
+		// Not published yet right after the start, reported as 0
+		status.APIServerPort, _ = APIServerPublicPort(found.Ports)
+		status.Ports = ContainerPortMappings(*found)
+	}
+
+	if image, _, err := c.APIClient.ImageInspectWithRaw(c.ctx, inspect.Image); err == nil {
+		status.ImageCreatedAt = parseDockerTime(image.Created)
+		if image.Config != nil {
+			status.ImageLabels = image.Config.Labels
+		}
+	}
+
+	if status.State == "exited" && logLines > 0 {
+		logs, err := c.tailLogs(found.ID, logLines)
+		if err != nil {
+			return nil, err
+		}
+		status.Logs = logs
+	}
+	return status, nil
+}
+
+// tailLogs returns the last lines the container logged
+func (c *Client) tailLogs(containerID string, lines int) ([]string, error) {
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true, Tail: strconv.Itoa(lines)}
+	out, err := c.APIClient.ContainerLogs(c.ctx, containerID, options)
+	if err != nil {
+		return nil, fmt.Errorf("error getting container logs: %w", err)
+	}
+	defer out.Close()
+
+	// The container has no TTY, so stdout and stderr are multiplexed
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, out); err != nil {
+		return nil, fmt.Errorf("error reading container logs: %w", err)
+	}
+	text := strings.TrimRight(buf.String(), "\n")
+	if text == "" {
+		return []string{}, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// parseDockerTime parses a time reported by docker, which reports times never reached as 0001-01-01
+func parseDockerTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
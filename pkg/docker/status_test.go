@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// logFrame encodes output the way docker multiplexes stdout (1) and stderr (2) of containers without a TTY
+func logFrame(stream byte, output string) string {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(output)))
+	return string(header) + output
+}
+
+const simulatorImage = `{"Id": "sha256:img", "Created": "2024-11-18T09:00:00.5Z", "Config": {"Labels": {"sim-cli-managed": "ws-v1"}}}`
+
+func Test_ContainerStatusRunning(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "", map[string]string{
+		// the name filter also matches ws-v10
+		"/containers/json": `[
+			{"Id": "other", "Names": ["/ws-v10"], "State": "running"},
+			{"Id": "abc", "Names": ["/ws-v1"], "Image": "ws-v1", "State": "running",
+			 "Ports": [{"PrivatePort": 6443, "PublicPort": 32768, "Type": "tcp"}]}
+		]`,
+		"/containers/abc/json": `{"Id": "abc", "Image": "sha256:img", "Config": {"Image": "ws-v1:latest"},
+			"State": {"Status": "running", "StartedAt": "2024-11-18T10:00:00Z", "FinishedAt": "0001-01-01T00:00:00Z"}}`,
+		"/images/sha256:img/json": simulatorImage,
+	})
+
+	status, err := c.ContainerStatus("ws-v1", 10)
+	assert.NoError(err)
+	assert.Equal(&ContainerStatus{
+		ID:             "abc",
+		State:          "running",
+		StartedAt:      time.Date(2024, 11, 18, 10, 0, 0, 0, time.UTC),
+		Image:          "ws-v1:latest",
+		ImageCreatedAt: time.Date(2024, 11, 18, 9, 0, 0, 500_000_000, time.UTC),
+		ImageLabels:    map[string]string{"sim-cli-managed": "ws-v1"},
+		APIServerPort:  32768,
+		Ports:          []PortMapping{{ContainerPort: 6443, Protocol: "tcp", HostPort: 32768}},
+	}, status)
+}
+
+func Test_ContainerStatusExited(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "", map[string]string{
+		"/containers/json": `[{"Id": "abc", "Names": ["/ws-v1"], "Image": "ws-v1", "State": "exited"}]`,
+		"/containers/abc/json": `{"Id": "abc", "Image": "sha256:gone", "Config": {"Image": "ws-v1:latest"},
+			"State": {"Status": "exited", "ExitCode": 2, "StartedAt": "2024-11-18T10:00:00Z", "FinishedAt": "2024-11-18T10:01:00Z"}}`,
+		"/containers/abc/logs": logFrame(1, "loading bundle\n") + logFrame(2, "panic: no such file\n"),
+	})
+
+	status, err := c.ContainerStatus("ws-v1", 10)
+	assert.NoError(err)
+	assert.Equal("exited", status.State)
+	assert.Equal(2, status.ExitCode)
+	assert.Equal(time.Date(2024, 11, 18, 10, 1, 0, 0, time.UTC), status.FinishedAt)
+	assert.True(status.ImageCreatedAt.IsZero(), "the image was removed")
+	assert.Zero(status.APIServerPort)
+	assert.Equal([]string{"loading bundle", "panic: no such file"}, status.Logs)
+}
+
+func Test_ContainerStatusMissing(t *testing.T) {
+	assert := require.New(t)
+	c := newCannedClient(t, "", map[string]string{
+		"/containers/json": `[{"Id": "other", "Names": ["/ws-v10"], "State": "running"}]`,
+	})
+
+	status, err := c.ContainerStatus("ws-v1", 10)
+	assert.NoError(err)
+	assert.Nil(status)
+}
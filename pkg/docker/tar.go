@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -89,11 +91,21 @@ func (t *TarHandler) UnzipSupportBundle(bundleZipFile string) (err error) {
 		}
 	}
 
-	// Analyze structure to determine the root of the bundle
-	entries, err := os.ReadDir(extractDir)
+	root, err := bundleRoot(extractDir)
 	if err != nil {
 		return err
 	}
+	return os.Rename(root, filepath.Join(t.TmpDirName, defaultBundleDir))
+}
+
+// bundleRoot returns the directory holding the bundle contents of an extracted bundle. If there is
+// exactly one directory, it is assumed to be the root folder of the bundle, otherwise the contents
+// are the bundle contents (flat structure or multiple roots).
+func bundleRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
 
 	var validEntries []os.DirEntry
 	for _, e := range entries {
@@ -103,17 +115,10 @@ func (t *TarHandler) UnzipSupportBundle(bundleZipFile string) (err error) {
 		validEntries = append(validEntries, e)
 	}
 
-	targetBundlePath := filepath.Join(t.TmpDirName, defaultBundleDir)
-
-	// If there is exactly one directory, assume it is the root folder of the bundle
 	if len(validEntries) == 1 && validEntries[0].IsDir() {
-		src := filepath.Join(extractDir, validEntries[0].Name())
-		return os.Rename(src, targetBundlePath)
+		return filepath.Join(dir, validEntries[0].Name()), nil
 	}
-
-	// Otherwise, assume the zip contents are the bundle contents (flat structure or multiple roots)
-	// We rename the extraction directory itself to the target bundle path
-	return os.Rename(extractDir, targetBundlePath)
+	return dir, nil
 }
 
 // GenerateBundleTar attempts to parse FS/bundle to build a tar which can be passed
@@ -160,23 +165,38 @@ func ReadTar(buf *bytes.Buffer) error {
 
 // BuildContextTar is a wrapper function tht builds a tar ball with Dockerfile and contents of bundle
 // and this can be passed to image builder to ensure support bundle kit image is layered with
-// actual support bundle contents to allow for subsequent processing by simulator
+// actual support bundle contents to allow for subsequent processing by simulator.
+// bundlePath is either a bundle zip file or a directory the bundle was already extracted to.
 func BuildContextTar(bundlePath string, baseImage string) (*bytes.Buffer, error) {
-	t, err := NewTarHandler()
+	info, err := os.Stat(bundlePath)
 	if err != nil {
 		return nil, err
 	}
 
-	// prepare zip file and extract it into bundle folder
-	if err := t.UnzipSupportBundle(bundlePath); err != nil {
+	t, err := NewTarHandler()
+	if err != nil {
 		return nil, err
 	}
+	defer t.Cleanup()
 
 	// add Dockerfile to root of tar image
 	if err := t.AddDockerFile(baseImage); err != nil {
 		return nil, err
 	}
 
+	if info.IsDir() {
+		root, err := bundleRoot(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		return t.GenerateBundleTarFromDir(root)
+	}
+
+	// prepare zip file and extract it into bundle folder
+	if err := t.UnzipSupportBundle(bundlePath); err != nil {
+		return nil, err
+	}
+
 	buf, err := t.GenerateBundleTar()
 	if err != nil {
 		return nil, err
@@ -188,6 +208,67 @@ func BuildContextTar(bundlePath string, baseImage string) (*bytes.Buffer, error)
 	return buf, err
 }
 
+// GenerateBundleTarFromDir builds the context tar from the tmp directory with bundleDir added as
+// the bundle folder, without copying the bundle into the tmp directory first
+func (t *TarHandler) GenerateBundleTarFromDir(bundleDir string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	contextTar := tar.NewWriter(buf)
+	if err := contextTar.AddFS(os.DirFS(t.TmpDirName)); err != nil {
+		return nil, fmt.Errorf("error adding tmp directory %s to tar: %v", t.TmpDirName, err)
+	}
+	if err := addDirToTar(contextTar, bundleDir, defaultBundleDir); err != nil {
+		return nil, fmt.Errorf("error adding bundle directory %s to tar: %v", bundleDir, err)
+	}
+
+	if err := contextTar.Close(); err != nil {
+		return nil, fmt.Errorf("error closing tar file %v", err)
+	}
+	return buf, nil
+}
+
+// addDirToTar writes the directories and regular files under dir to tw, named under prefix
+func addDirToTar(tw *tar.Writer, dir, prefix string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
 func generateTemplate(baseImage string) (bytes.Buffer, error) {
 	contents := struct {
 		BaseImage string
@@ -3,6 +3,8 @@ package docker
 import (
 	"archive/tar"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,3 +29,34 @@ func Test_BuildContextTar(t *testing.T) {
 	}
 	assert.True(dockerFileFound, "expected to find dockerfile")
 }
+
+func Test_BuildContextTarFromDir(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	root := filepath.Join(dir, "supportbundle_f159fbe2")
+	assert.NoError(os.MkdirAll(filepath.Join(root, "logs"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(root, "metadata.yaml"), []byte("projectName: harvester"), 0644))
+	assert.NoError(os.WriteFile(filepath.Join(root, "logs", "harvester.log"), []byte("started"), 0644))
+	assert.NoError(os.Mkdir(filepath.Join(dir, "__MACOSX"), 0755))
+
+	buf, err := BuildContextTar(dir, "rancher/support-bundle-kit:master")
+	assert.NoError(err)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err, "expected no error while parsing file")
+		content, err := io.ReadAll(tr)
+		assert.NoError(err)
+		files[hdr.Name] = string(content)
+	}
+	assert.Contains(files, "Dockerfile")
+	assert.Contains(files, "bundle/")
+	assert.Equal("projectName: harvester", files["bundle/metadata.yaml"])
+	assert.Equal("started", files["bundle/logs/harvester.log"])
+	assert.Len(files, 5)
+}
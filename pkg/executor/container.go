@@ -1,6 +1,10 @@
 package executor
 
-import "github.com/Yu-Jack/sim-gui/pkg/docker"
+import (
+	"context"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+)
 
 type ContainerExecutor struct {
 	client        *docker.Client
@@ -15,5 +19,17 @@ func NewContainerExecutor(client *docker.Client, containerName string) *Containe
 }
 
 func (e *ContainerExecutor) Exec(command []string, env []string) (string, string, error) {
-	return e.client.ExecContainer(e.containerName, command, env)
+	return e.ExecContext(context.Background(), command, env)
+}
+
+func (e *ContainerExecutor) ExecContext(ctx context.Context, command []string, env []string) (string, string, error) {
+	result, err := e.client.ExecContainerContext(ctx, e.containerName, command, env)
+	if err == nil && result.ExitCode != 0 {
+		err = &ExitError{ExitCode: result.ExitCode, Stderr: result.Stderr}
+	}
+	return result.Stdout, result.Stderr, err
+}
+
+func (e *ContainerExecutor) ExecStream(ctx context.Context, command []string, env []string) (Stream, error) {
+	return e.client.ExecContainerStream(ctx, e.containerName, command, env)
 }
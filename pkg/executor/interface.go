@@ -1,5 +1,46 @@
 package executor
 
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+)
+
+// ExecResult is the output of a command and the code it exited with
+type ExecResult = docker.ExecResult
+
+// ExitError is returned by executors for commands which exited with a non zero code
+type ExitError = docker.ExitError
+
 type Executor interface {
+	// Exec runs a command and buffers its output, it is ExecContext without cancellation. A command
+	// which exited with a non zero code returns an ExitError.
 	Exec(command []string, env []string) (string, string, error)
+	// ExecContext runs a command and buffers its output, the command is stopped when ctx is cancelled
+	ExecContext(ctx context.Context, command []string, env []string) (string, string, error)
+	// ExecStream starts a command and returns its stdout while it is produced, use it for large outputs
+	ExecStream(ctx context.Context, command []string, env []string) (Stream, error)
+}
+
+// Stream is the stdout of a running command
+type Stream interface {
+	io.ReadCloser
+	// Wait blocks until the command exited and returns its stderr. Stdout has to be read to the
+	// end or closed first.
+	Wait() (string, error)
+}
+
+// Run runs a command with exec and returns its result. Unlike Exec, the error is only set when the
+// command couldn't be run, a command which failed is reported by the exit code of the result.
+func Run(exec Executor, command []string, env []string) (ExecResult, error) {
+	stdout, stderr, err := exec.Exec(command, env)
+	result := ExecResult{Stdout: stdout, Stderr: stderr}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode
+		return result, nil
+	}
+	return result, err
 }
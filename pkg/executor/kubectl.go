@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// kubectlBinary is the command name callers use, RuntimeExecutor replaces it with its configured path
+const kubectlBinary = "kubectl"
+
+// KubectlInfo describes the kubectl binary runtime clusters are queried with
+type KubectlInfo struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ResolveKubectl finds the kubectl binary, path takes precedence over looking up kubectl in PATH
+func ResolveKubectl(path string) (string, error) {
+	if path == "" {
+		path = kubectlBinary
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("kubectl not found: %w", err)
+	}
+	return resolved, nil
+}
+
+// CheckKubectl resolves the kubectl binary and reads its client version
+func CheckKubectl(path string) KubectlInfo {
+	resolved, err := ResolveKubectl(path)
+	if err != nil {
+		return KubectlInfo{Path: path, Error: err.Error()}
+	}
+
+	info := KubectlInfo{Path: resolved}
+	version, err := kubectlClientVersion(resolved)
+	if err != nil {
+		info.Error = err.Error()
+	} else {
+		info.Version = version
+	}
+	return info
+}
+
+func kubectlClientVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "version", "--client", "-o", "json").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get kubectl version: %w", err)
+	}
+
+	var version struct {
+		ClientVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"clientVersion"`
+	}
+	if err := json.Unmarshal(out, &version); err != nil {
+		return "", fmt.Errorf("failed to parse kubectl version: %w", err)
+	}
+	return version.ClientVersion.GitVersion, nil
+}
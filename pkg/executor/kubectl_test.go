@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeKubectl writes a script printing a client version the way kubectl does
+func writeFakeKubectl(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "kubectl")
+	script := `#!/bin/sh
+if [ "$1" = "version" ]; then
+  echo '{"clientVersion":{"gitVersion":"v1.29.3"}}'
+else
+  echo "fake $@"
+fi
+`
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func Test_CheckKubectl(t *testing.T) {
+	assert := require.New(t)
+	path := writeFakeKubectl(t)
+
+	info := CheckKubectl(path)
+	assert.Empty(info.Error)
+	assert.Equal(path, info.Path)
+	assert.Equal("v1.29.3", info.Version)
+
+	// falls back to PATH
+	t.Setenv("PATH", filepath.Dir(path))
+	info = CheckKubectl("")
+	assert.Equal(path, info.Path)
+	assert.Equal("v1.29.3", info.Version)
+
+	info = CheckKubectl(filepath.Join(t.TempDir(), "missing"))
+	assert.Contains(info.Error, "kubectl not found")
+}
+
+func Test_RuntimeExecutorUsesKubectlPath(t *testing.T) {
+	assert := require.New(t)
+	e := NewRuntimeExecutor("", writeFakeKubectl(t))
+
+	stdout, _, err := e.Exec([]string{"kubectl", "get", "nodes"}, nil)
+	assert.NoError(err)
+	assert.Equal("fake get nodes\n", stdout)
+}
@@ -2,34 +2,93 @@ package executor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 )
 
 type RuntimeExecutor struct {
 	kubeconfigPath string
+	// kubectlPath replaces kubectl commands, empty runs kubectl from PATH
+	kubectlPath string
 }
 
-func NewRuntimeExecutor(kubeconfigPath string) *RuntimeExecutor {
+func NewRuntimeExecutor(kubeconfigPath, kubectlPath string) *RuntimeExecutor {
 	return &RuntimeExecutor{
 		kubeconfigPath: kubeconfigPath,
+		kubectlPath:    kubectlPath,
 	}
 }
 
 func (e *RuntimeExecutor) Exec(command []string, env []string) (string, string, error) {
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Env = append(os.Environ(), env...)
-	cmd.Env = append(cmd.Env, fmt.Sprintf("KUBECONFIG=%s", e.kubeconfigPath))
+	return e.ExecContext(context.Background(), command, env)
+}
+
+func (e *RuntimeExecutor) ExecContext(ctx context.Context, command []string, env []string) (string, string, error) {
+	cmd := e.command(ctx, command, env)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err := commandError(ctx, cmd.Run(), stderr.String())
+	return stdout.String(), stderr.String(), err
+}
+
+func (e *RuntimeExecutor) ExecStream(ctx context.Context, command []string, env []string) (Stream, error) {
+	cmd := e.command(ctx, command, env)
+
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return stdout.String(), stderr.String(), fmt.Errorf("command failed: %w, stderr: %s", err, stderr.String())
+		return nil, err
 	}
+	stream := &runtimeStream{ReadCloser: stdout, cmd: cmd, ctx: ctx}
+	cmd.Stderr = &stream.stderr
 
-	return stdout.String(), stderr.String(), nil
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("command failed: %w", err)
+	}
+	return stream, nil
+}
+
+func (e *RuntimeExecutor) command(ctx context.Context, command []string, env []string) *exec.Cmd {
+	name := command[0]
+	if name == kubectlBinary && e.kubectlPath != "" {
+		name = e.kubectlPath
+	}
+	cmd := exec.CommandContext(ctx, name, command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("KUBECONFIG=%s", e.kubeconfigPath))
+	return cmd
+}
+
+// runtimeStream reads stdout of a local process through a pipe
+type runtimeStream struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	ctx    context.Context
+	stderr bytes.Buffer
+}
+
+func (s *runtimeStream) Wait() (string, error) {
+	// stderr is complete once the process was waited for
+	err := s.cmd.Wait()
+	return s.stderr.String(), commandError(s.ctx, err, s.stderr.String())
+}
+
+// commandError converts the error of a finished local process, a process which exited with a non
+// zero code returns an ExitError like commands run in a container
+func commandError(ctx context.Context, err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	// A process killed as ctx was cancelled has no exit code
+	if errors.As(err, &exitErr) && ctx.Err() == nil && exitErr.ExitCode() >= 0 {
+		return &ExitError{ExitCode: exitErr.ExitCode(), Stderr: stderr}
+	}
+	return fmt.Errorf("command failed: %w, stderr: %s", err, stderr)
 }
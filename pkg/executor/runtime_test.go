@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RuntimeExecStream(t *testing.T) {
+	assert := require.New(t)
+	e := NewRuntimeExecutor("/tmp/kubeconfig", "")
+
+	stream, err := e.ExecStream(context.Background(), []string{"sh", "-c", "echo $KUBECONFIG; echo oops >&2"}, nil)
+	assert.NoError(err)
+	stdout, err := io.ReadAll(stream)
+	assert.NoError(err)
+	assert.Equal("/tmp/kubeconfig\n", string(stdout))
+
+	stderr, err := stream.Wait()
+	assert.NoError(err)
+	assert.Equal("oops\n", stderr)
+
+	stream, err = e.ExecStream(context.Background(), []string{"sh", "-c", "echo failed >&2; exit 3"}, nil)
+	assert.NoError(err)
+	_, _ = io.ReadAll(stream)
+	stderr, err = stream.Wait()
+	var exitErr *ExitError
+	assert.ErrorAs(err, &exitErr)
+	assert.Equal(3, exitErr.ExitCode)
+	assert.Equal("failed\n", stderr)
+}
+
+func Test_RunReportsExitCode(t *testing.T) {
+	assert := require.New(t)
+	e := NewRuntimeExecutor("", "")
+
+	result, err := Run(e, []string{"sh", "-c", "echo partial; echo 'Error from server (NotFound)' >&2; exit 1"}, nil)
+	assert.NoError(err, "a failed command isn't an error of Run")
+	assert.Equal(ExecResult{Stdout: "partial\n", Stderr: "Error from server (NotFound)\n", ExitCode: 1}, result)
+
+	_, err = Run(e, []string{"/nonexistent/kubectl"}, nil)
+	assert.Error(err)
+}
+
+func Test_RuntimeExecContextCancel(t *testing.T) {
+	assert := require.New(t)
+	e := NewRuntimeExecutor("", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := e.ExecContext(ctx, []string{"sleep", "10"}, nil)
+	assert.Error(err)
+	var exitErr *ExitError
+	assert.False(errors.As(err, &exitErr), "a killed command has no exit code")
+	assert.Less(time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,212 @@
+package activity
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries is the number of entries kept per workspace unless configured otherwise
+const DefaultMaxEntries = 500
+
+// Entry is a single item of a workspace activity feed
+type Entry struct {
+	Type      string    `json:"type"` // Event type, e.g. version.uploaded
+	Actor     string    `json:"actor,omitempty"`
+	VersionID string    `json:"versionID,omitempty"`
+	Summary   string    `json:"summary"`
+	Time      time.Time `json:"time"`
+}
+
+// Log keeps the activity of every workspace in a JSON lines file per workspace. Only the newest
+// entries are kept, older ones are pruned when the file grows past twice the limit.
+type Log struct {
+	dir        string
+	maxEntries int
+
+	lock sync.Mutex
+	// entries caches the files per workspace, oldest first
+	entries map[string][]Entry
+	// lines is the number of lines in the file of each workspace, including pruned entries
+	lines map[string]int
+}
+
+func NewLog(dir string, maxEntries int) *Log {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Log{
+		dir:        dir,
+		maxEntries: maxEntries,
+		entries:    make(map[string][]Entry),
+		lines:      make(map[string]int),
+	}
+}
+
+// SetMaxEntries changes how many entries are kept per workspace, 0 keeps DefaultMaxEntries
+func (l *Log) SetMaxEntries(maxEntries int) {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.maxEntries = maxEntries
+	// Cached feeds are trimmed again on load
+	l.entries = make(map[string][]Entry)
+}
+
+// Record appends an entry to the feed of a workspace
+func (l *Log) Record(workspace string, entry Entry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, err := l.load(workspace)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+	if len(entries) > l.maxEntries {
+		entries = append([]Entry(nil), entries[len(entries)-l.maxEntries:]...)
+	}
+	l.entries[workspace] = entries
+
+	if l.lines[workspace] >= 2*l.maxEntries {
+		return l.rewrite(workspace, entries)
+	}
+	return l.append(workspace, entry)
+}
+
+// List returns the entries of a workspace newer than since, newest first. A zero since returns all of them.
+func (l *Log) List(workspace string, since time.Time) ([]Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries, err := l.load(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !since.IsZero() && !entries[i].Time.After(since) {
+			break
+		}
+		result = append(result, entries[i])
+	}
+	return result, nil
+}
+
+// Delete removes the feed of a workspace
+func (l *Log) Delete(workspace string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.entries, workspace)
+	delete(l.lines, workspace)
+	if err := os.Remove(l.path(workspace)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Log) path(workspace string) string {
+	return filepath.Join(l.dir, workspace+".jsonl")
+}
+
+// load reads the file of a workspace into the cache, lines which can't be decoded are skipped
+func (l *Log) load(workspace string) ([]Entry, error) {
+	if entries, ok := l.entries[workspace]; ok {
+		return entries, nil
+	}
+
+	f, err := os.Open(l.path(workspace))
+	if os.IsNotExist(err) {
+		l.entries[workspace] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) > l.maxEntries {
+		entries = entries[len(entries)-l.maxEntries:]
+	}
+
+	l.entries[workspace] = entries
+	l.lines[workspace] = lines
+	return entries, nil
+}
+
+func (l *Log) append(workspace string, entry Entry) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path(workspace), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	l.lines[workspace]++
+	return f.Close()
+}
+
+// rewrite replaces the file of a workspace with entries, dropping the pruned ones
+func (l *Log) rewrite(workspace string, entries []Entry) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+
+	tmp := l.path(workspace) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path(workspace)); err != nil {
+		return err
+	}
+	l.lines[workspace] = len(entries)
+	return nil
+}
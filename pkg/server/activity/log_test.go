@@ -0,0 +1,100 @@
+package activity
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func countLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func Test_LogNewestFirst(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	l := NewLog(dir, 10)
+	start := time.Date(2024, 11, 18, 4, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(l.Record("demo", Entry{
+			Type:      "version.uploaded",
+			VersionID: fmt.Sprintf("v%d", i),
+			Summary:   fmt.Sprintf("v%d uploaded", i),
+			Time:      start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	assert.NoError(l.Record("other", Entry{Type: "workspace.created", Time: start}))
+
+	entries, err := l.List("demo", time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 3)
+	assert.Equal("v3", entries[0].VersionID)
+	assert.Equal("v1", entries[2].VersionID)
+
+	entries, err = l.List("demo", start.Add(time.Minute))
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	assert.Equal("v2", entries[1].VersionID)
+
+	// a new log reads the feed back from disk
+	entries, err = NewLog(dir, 10).List("demo", time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 3)
+	assert.Equal("v3", entries[0].VersionID)
+
+	entries, err = l.List("missing", time.Time{})
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func Test_LogPrunesOldEntries(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	l := NewLog(dir, 5)
+
+	for i := 1; i <= 23; i++ {
+		assert.NoError(l.Record("demo", Entry{Type: "simulator.started", VersionID: fmt.Sprintf("v%d", i), Time: time.Now()}))
+		assert.LessOrEqual(countLines(t, filepath.Join(dir, "demo.jsonl")), 10)
+	}
+
+	entries, err := l.List("demo", time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 5)
+	assert.Equal("v23", entries[0].VersionID)
+	assert.Equal("v19", entries[4].VersionID)
+
+	entries, err = NewLog(dir, 5).List("demo", time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, 5)
+	assert.Equal("v23", entries[0].VersionID)
+}
+
+func Test_LogDelete(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	l := NewLog(dir, 5)
+	assert.NoError(l.Record("demo", Entry{Type: "workspace.created", Time: time.Now()}))
+
+	assert.NoError(l.Delete("demo"))
+	_, err := os.Stat(filepath.Join(dir, "demo.jsonl"))
+	assert.True(os.IsNotExist(err))
+	entries, err := l.List("demo", time.Time{})
+	assert.NoError(err)
+	assert.Empty(entries)
+
+	assert.NoError(l.Delete("missing"))
+}
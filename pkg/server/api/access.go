@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// accessWriteInterval debounces LastAccessedAt writes so browsing a version doesn't rewrite data.json on every request
+const accessWriteInterval = time.Minute
+
+// recordVersionStarted sets LastStartedAt of a version to now and announces the start
+func (s *Server) recordVersionStarted(workspaceName, versionID string) {
+	s.events.Publish(events.SimulatorStarted, workspaceName, versionID, nil)
+
+	now := time.Now()
+	err := updateVersion(s.store, workspaceName, versionID, func(v *model.Version) bool {
+		v.LastStartedAt = &now
+		return true
+	})
+	if err != nil {
+		fmt.Printf("Failed to record start of %s-%s: %v\n", workspaceName, versionID, err)
+	}
+}
+
+// touchVersion sets LastAccessedAt of a version to now, at most once per accessWriteInterval. The
+// debounce is kept per instance name, which forgetAccess drops once the version is deleted.
+func (s *Server) touchVersion(workspaceName string, version model.Version) {
+	key := version.InstanceName(workspaceName)
+	now := time.Now()
+
+	s.accessLock.Lock()
+	if last, ok := s.lastAccessWrite[key]; ok && now.Sub(last) < accessWriteInterval {
+		s.accessLock.Unlock()
+		return
+	}
+	s.lastAccessWrite[key] = now
+	s.accessLock.Unlock()
+
+	err := updateVersion(s.store, workspaceName, version.ID, func(v *model.Version) bool {
+		v.LastAccessedAt = &now
+		return true
+	})
+	if err != nil {
+		fmt.Printf("Failed to record access of %s: %v\n", key, err)
+	}
+}
+
+// forgetAccess drops the debounce of a deleted version, so lastAccessWrite doesn't keep every version
+// ever browsed
+func (s *Server) forgetAccess(instanceName string) {
+	s.accessLock.Lock()
+	defer s.accessLock.Unlock()
+	delete(s.lastAccessWrite, instanceName)
+}
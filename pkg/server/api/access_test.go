@@ -0,0 +1,52 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TouchVersionDebounced(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "demo", Versions: []model.Version{{ID: "v1"}, {ID: "v2", Instance: "customers-demo-v2"}}}))
+	s := &Server{store: st, events: events.NewBus(), lastAccessWrite: make(map[string]time.Time)}
+
+	s.touchVersion("demo", model.Version{ID: "v1"})
+	ws, err := st.GetWorkspace("demo")
+	assert.NoError(err)
+	first := ws.Versions[0].LastAccessedAt
+	assert.NotNil(first)
+
+	// a second access within the interval is not written
+	s.touchVersion("demo", model.Version{ID: "v1"})
+	ws, err = st.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal(first, ws.Versions[0].LastAccessedAt)
+
+	s.lastAccessWrite["demo-v1"] = time.Now().Add(-accessWriteInterval)
+	s.touchVersion("demo", model.Version{ID: "v1"})
+	ws, err = st.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.True(ws.Versions[0].LastAccessedAt.After(*first))
+
+	// deleting the version drops its debounce, by the instance name deletes know it by
+	s.forgetAccess("demo-v1")
+	assert.Empty(s.lastAccessWrite)
+	profiled := model.Version{ID: "v2", Instance: "customers-demo-v2"}
+	s.touchVersion("demo", profiled)
+	assert.Contains(s.lastAccessWrite, "customers-demo-v2")
+	s.forgetAccess(profiled.InstanceName("demo"))
+	assert.Empty(s.lastAccessWrite)
+
+	s.recordVersionStarted("demo", "v1")
+	ws, err = st.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.NotNil(ws.Versions[0].LastStartedAt)
+}
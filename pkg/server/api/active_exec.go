@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+)
+
+// trackedExecutor counts the commands running through an executor, so the retention sweep
+// doesn't delete a version while it is being queried
+type trackedExecutor struct {
+	executor.Executor
+	server   *Server
+	instance string
+}
+
+func (e *trackedExecutor) Exec(command []string, env []string) (string, string, error) {
+	defer e.server.beginExec(e.instance)()
+	return e.Executor.Exec(command, env)
+}
+
+func (e *trackedExecutor) ExecContext(ctx context.Context, command []string, env []string) (string, string, error) {
+	defer e.server.beginExec(e.instance)()
+	return e.Executor.ExecContext(ctx, command, env)
+}
+
+func (e *trackedExecutor) ExecStream(ctx context.Context, command []string, env []string) (executor.Stream, error) {
+	done := e.server.beginExec(e.instance)
+	stream, err := e.Executor.ExecStream(ctx, command, env)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &trackedStream{Stream: stream, done: sync.OnceFunc(done)}, nil
+}
+
+// trackedStream ends its exec once it is closed or waited for
+type trackedStream struct {
+	executor.Stream
+	done func()
+}
+
+func (s *trackedStream) Close() error {
+	defer s.done()
+	return s.Stream.Close()
+}
+
+func (s *trackedStream) Wait() (string, error) {
+	defer s.done()
+	return s.Stream.Wait()
+}
+
+// errInstanceLocked is returned when an instance is locked twice
+var errInstanceLocked = errors.New("the simulator is being reset")
+
+// beginExec marks a command as running on an instance until the returned function is called, a
+// command on a locked instance waits until it is unlocked
+func (s *Server) beginExec(instanceName string) func() {
+	s.execLock.Lock()
+	for s.locked[instanceName] {
+		s.unlockedCond().Wait()
+	}
+	if s.execs == nil {
+		s.execs = make(map[string]int)
+	}
+	s.execs[instanceName]++
+	s.execLock.Unlock()
+
+	return func() {
+		s.execLock.Lock()
+		defer s.execLock.Unlock()
+		if s.execs[instanceName]--; s.execs[instanceName] <= 0 {
+			delete(s.execs, instanceName)
+		}
+	}
+}
+
+// lockInstance keeps new commands off an instance until the returned function is called, e.g. while
+// its container restarts. It fails while commands run on the instance, they would fail halfway.
+func (s *Server) lockInstance(instanceName string) (func(), error) {
+	s.execLock.Lock()
+	defer s.execLock.Unlock()
+	if s.locked[instanceName] {
+		return nil, errInstanceLocked
+	}
+	if n := s.execs[instanceName]; n > 0 {
+		return nil, fmt.Errorf("%d commands are running on the simulator, retry once they finished", n)
+	}
+	if s.locked == nil {
+		s.locked = make(map[string]bool)
+	}
+	s.locked[instanceName] = true
+
+	return func() {
+		s.execLock.Lock()
+		defer s.execLock.Unlock()
+		delete(s.locked, instanceName)
+		s.unlockedCond().Broadcast()
+	}, nil
+}
+
+// unlockedCond returns the condition signalled when an instance is unlocked, execLock must be held
+func (s *Server) unlockedCond() *sync.Cond {
+	if s.unlocked == nil {
+		s.unlocked = sync.NewCond(&s.execLock)
+	}
+	return s.unlocked
+}
+
+// activeExecs returns the number of commands running on an instance
+func (s *Server) activeExecs(instanceName string) int {
+	s.execLock.Lock()
+	defer s.execLock.Unlock()
+	return s.execs[instanceName]
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/activity"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// ActivityEntry is an entry of the activity feed with its time in the time zone of the workspace
+type ActivityEntry struct {
+	activity.Entry
+	LocalTime string `json:"localTime,omitempty"`
+}
+
+// activityEntry turns an event into an activity feed entry, events which aren't worth showing
+// in the feed, like load progress, return false
+func activityEntry(e events.Event) (activity.Entry, bool) {
+	entry := activity.Entry{
+		Type:      string(e.Type),
+		Actor:     e.Actor,
+		VersionID: e.VersionID,
+		Time:      e.Time,
+	}
+
+	switch e.Type {
+	case events.WorkspaceCreated:
+		entry.Summary = "Workspace created"
+	case events.WorkspaceUpdated:
+		entry.Summary = "Workspace settings updated"
+	case events.VersionUploaded:
+		entry.Summary = fmt.Sprintf("%s uploaded", e.VersionID)
+		if v, ok := e.Payload.(*model.Version); ok && v.ImportedFrom != "" {
+			entry.Summary = fmt.Sprintf("%s imported from %s on the server", e.VersionID, v.ImportedFrom)
+		} else if ok && v.SupportBundleName != "" {
+			entry.Summary = fmt.Sprintf("%s uploaded from %s", e.VersionID, v.SupportBundleName)
+		}
+	case events.VersionDeleted:
+		entry.Summary = fmt.Sprintf("%s deleted", e.VersionID)
+	case events.VersionReplaced:
+		entry.Summary = fmt.Sprintf("Bundle of %s replaced", e.VersionID)
+		if v, ok := e.Payload.(*model.Version); ok && v.SupportBundleName != "" {
+			entry.Summary = fmt.Sprintf("Bundle of %s replaced with %s", e.VersionID, v.SupportBundleName)
+		}
+	case events.VersionRestored:
+		entry.Summary = fmt.Sprintf("%s restored from the trash", e.VersionID)
+	case events.VersionExpiring:
+		entry.Summary = fmt.Sprintf("%s will be deleted by the retention policy", e.VersionID)
+		if expiresAt, ok := e.Payload.(time.Time); ok {
+			entry.Summary = fmt.Sprintf("%s will be deleted by the retention policy on %s", e.VersionID, expiresAt.Format("2006-01-02"))
+		}
+	case events.SimulatorStarting:
+		entry.Summary = fmt.Sprintf("Building the simulator image of %s", e.VersionID)
+	case events.SimulatorImageBuilt:
+		entry.Summary = fmt.Sprintf("Simulator image of %s built", e.VersionID)
+	case events.SimulatorStarted:
+		entry.Summary = fmt.Sprintf("Simulator of %s started", e.VersionID)
+	case events.VersionReady:
+		entry.Summary = fmt.Sprintf("Simulator of %s finished loading the bundle", e.VersionID)
+	case events.SimulatorReset:
+		entry.Summary = fmt.Sprintf("Simulator of %s reset to its bundle", e.VersionID)
+	case events.SimulatorStopped:
+		entry.Summary = fmt.Sprintf("Simulator of %s stopped", e.VersionID)
+	default:
+		return entry, false
+	}
+	return entry, true
+}
+
+// recordActivity writes the events of sub to the activity log until the subscription is closed
+func (s *Server) recordActivity(sub *events.Subscription) {
+	for e := range sub.Events() {
+		if e.Workspace == "" {
+			continue
+		}
+		if e.Type == events.WorkspaceDeleted {
+			if err := s.activity.Delete(e.Workspace); err != nil {
+				fmt.Printf("Failed to delete activity of %s: %v\n", e.Workspace, err)
+			}
+			continue
+		}
+
+		entry, ok := activityEntry(e)
+		if !ok {
+			continue
+		}
+		if err := s.activity.Record(e.Workspace, entry); err != nil {
+			fmt.Printf("Failed to record activity of %s: %v\n", e.Workspace, err)
+		}
+	}
+}
+
+// SetActivityMaxEntries sets how many activity entries are kept per workspace
+func (s *Server) SetActivityMaxEntries(maxEntries int) {
+	s.activity.SetMaxEntries(maxEntries)
+}
+
+func (s *Server) handleGetActivity(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	params, err := parseListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid since %q, expected an RFC 3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	entries, err := s.activity.List(name, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The feed is always newest first, order is ignored
+	params.Desc = false
+	entries = paginate(w, entries, params, nil)
+
+	loc := workspaceLocation(ws)
+	presented := make([]ActivityEntry, len(entries))
+	for i, entry := range entries {
+		presented[i] = ActivityEntry{Entry: entry, LocalTime: localTime(entry.Time, loc)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presented)
+}
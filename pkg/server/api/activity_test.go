@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/activity"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ActivityEntry(t *testing.T) {
+	assert := require.New(t)
+
+	entry, ok := activityEntry(events.Event{
+		Type:      events.VersionUploaded,
+		VersionID: "v3",
+		Actor:     "alice",
+		Payload:   &model.Version{ID: "v3", SupportBundleName: "bundle.zip"},
+	})
+	assert.True(ok)
+	assert.Equal("version.uploaded", entry.Type)
+	assert.Equal("alice", entry.Actor)
+	assert.Equal("v3", entry.VersionID)
+	assert.Equal("v3 uploaded from bundle.zip", entry.Summary)
+
+	_, ok = activityEntry(events.Event{Type: events.SimulatorProgress, VersionID: "v3"})
+	assert.False(ok)
+}
+
+func Test_GetActivity(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now()}))
+	s := &Server{store: st, events: events.NewBus(), activity: activity.NewLog(filepath.Join(dataDir, "activity"), 10)}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+
+	// recordActivity returns once the subscription is closed and drained
+	sub := s.events.Subscribe()
+	s.events.Publish(events.WorkspaceCreated, "ws", "", nil)
+	s.events.Publish(events.SimulatorStarted, "ws", "v1", nil)
+	s.events.Publish(events.SimulatorProgress, "ws", "v1", nil)
+	s.events.Publish(events.SimulatorStopped, "ws", "v1", nil)
+	s.events.Publish(events.WorkspaceCreated, "other", "", nil)
+	sub.Close()
+	s.recordActivity(sub)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/ws/activity?limit=2", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("3", rec.Header().Get("X-Total-Count"))
+	var entries []activity.Entry
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&entries))
+	assert.Len(entries, 2)
+	assert.Equal("Simulator of v1 stopped", entries[0].Summary)
+	assert.Equal("Simulator of v1 started", entries[1].Summary)
+
+	since := entries[1].Time.Format(time.RFC3339Nano)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/ws/activity?since="+since, nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	entries = nil
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&entries))
+	assert.Len(entries, 1)
+	assert.Equal("simulator.stopped", entries[0].Type)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/ws/activity?since=yesterday", nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/missing/activity", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+
+	// the time is rendered in the time zone of the workspace next to the raw one
+	ws, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	ws.Preferences.TimeZone = "Asia/Taipei"
+	assert.NoError(st.UpdateWorkspace(*ws))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/ws/activity?limit=1", nil))
+	var presented []ActivityEntry
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&presented))
+	assert.Len(presented, 1)
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	assert.NoError(err)
+	assert.Equal(presented[0].Time.In(taipei).Format(localTimeLayout), presented[0].LocalTime)
+}
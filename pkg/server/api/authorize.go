@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+)
+
+// routeClass classifies a route for the roles of the tokens file
+type routeClass int
+
+const (
+	// classDefault is derived from the method and path by route.class
+	classDefault routeClass = iota
+	// classPublic is answered without a token, e.g. health checks
+	classPublic
+	// classRead only reads, allowed to every role
+	classRead
+	// classCreate creates a workspace owned by the caller, allowed to editors
+	classCreate
+	// classWrite changes the workspace of the path, allowed to editors owning it
+	classWrite
+	// classAdmin changes something outside a single workspace, allowed to admins only
+	classAdmin
+)
+
+// class returns how the route is classified: GET reads, other methods write to the workspace of the
+// path or, without one, outside of workspaces. Routes reading with a POST body and those creating a
+// workspace set Class.
+func (rt route) class() routeClass {
+	switch {
+	case rt.Class != classDefault:
+		return rt.Class
+	case rt.Method == http.MethodGet:
+		return classRead
+	case strings.HasPrefix(rt.Path, "/api/workspaces/{name}"):
+		return classWrite
+	default:
+		return classAdmin
+	}
+}
+
+// SetTokens requires a token of the tokens file with every request but public ones. The identity of
+// the token replaces the user header. It must be called before the routes are registered.
+func (s *Server) SetTokens(tokens *auth.Tokens) {
+	s.tokens = tokens
+}
+
+// requestToken returns the bearer token of a request. Browsers can't set headers on event streams and
+// downloads, so access_token in the query works too.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// authorize lets a request reach next when the role of its token allows the route, without tokens
+// every request does. Editors only change workspaces they own, see auth.Identity.Owns.
+func (s *Server) authorize(rt route, next http.HandlerFunc) http.HandlerFunc {
+	class := rt.class()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens == nil || class == classPublic {
+			next(w, r)
+			return
+		}
+
+		identity, ok := s.tokens.Lookup(requestToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sim-gui"`)
+			http.Error(w, "A valid token is required", http.StatusUnauthorized)
+			return
+		}
+		if err := s.checkAccess(identity, class, r.PathValue("name")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	}
+}
+
+// checkAccess returns why identity may not use a route of class on the workspace name
+func (s *Server) checkAccess(identity auth.Identity, class routeClass, name string) error {
+	if identity.Role == auth.RoleAdmin || class == classRead {
+		return nil
+	}
+	if identity.Role == auth.RoleViewer {
+		return errors.New("viewers can't make changes")
+	}
+
+	switch class {
+	case classCreate:
+		return nil
+	case classWrite:
+		ws, err := s.store.GetWorkspace(name)
+		if err != nil {
+			// Left to the handler to report as missing
+			return nil
+		}
+		if !identity.Owns(ws.Name, ws.CreatedBy) {
+			return errors.New("only the owner of the workspace and admins can change it")
+		}
+		return nil
+	default:
+		return errors.New("only admins can make this change")
+	}
+}
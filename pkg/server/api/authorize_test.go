@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	memstore "github.com/Yu-Jack/sim-gui/pkg/server/store/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Authorize(t *testing.T) {
+	assert := require.New(t)
+	st := memstore.NewMemoryStore()
+	for name, createdBy := range map[string]string{"eves": "eve", "alices": "alice", "legacy": "", "shared": "alice"} {
+		assert.NoError(st.CreateWorkspace(model.Workspace{Name: name, CreatedBy: createdBy, CreatedAt: time.Now()}))
+	}
+	path := filepath.Join(t.TempDir(), auth.FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [
+		{"token": "admin-token", "user": "alice", "role": "admin"},
+		{"token": "editor-token", "user": "eve", "role": "editor", "workspaces": ["shared"]},
+		{"token": "viewer-token", "user": "victor", "role": "viewer"}
+	]}`), 0600))
+	tokens, err := auth.Load(path)
+	assert.NoError(err)
+
+	s := &Server{store: st}
+	s.SetUserHeader("X-Forwarded-User")
+	s.SetTokens(tokens)
+	mux := http.NewServeMux()
+	user := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(s.requestUser(r))) }
+	for _, rt := range []route{
+		{Method: "GET", Path: "/api/healthz", Class: classPublic},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions"},
+		{Method: "POST", Path: "/api/workspaces", Class: classCreate},
+		{Method: "DELETE", Path: "/api/workspaces/{name}"},
+		{Method: "POST", Path: "/api/clean-all"},
+	} {
+		mux.HandleFunc(rt.Method+" "+rt.Path, s.authorize(rt, user))
+	}
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, c := range []struct {
+		method, path string
+		codes        map[string]int // by token, "" is none and "wrong" is unknown
+	}{
+		{"GET", "/api/healthz", map[string]int{"": 200, "wrong": 200, "viewer-token": 200}},
+		{"GET", "/api/workspaces/alices/versions", map[string]int{"": 401, "wrong": 401, "viewer-token": 200, "editor-token": 200, "admin-token": 200}},
+		{"POST", "/api/workspaces", map[string]int{"": 401, "viewer-token": 403, "editor-token": 200, "admin-token": 200}},
+		{"DELETE", "/api/workspaces/eves", map[string]int{"": 401, "viewer-token": 403, "editor-token": 200, "admin-token": 200}},
+		{"DELETE", "/api/workspaces/shared", map[string]int{"viewer-token": 403, "editor-token": 200, "admin-token": 200}},
+		{"DELETE", "/api/workspaces/alices", map[string]int{"viewer-token": 403, "editor-token": 403, "admin-token": 200}},
+		{"DELETE", "/api/workspaces/legacy", map[string]int{"editor-token": 403, "admin-token": 200}},
+		{"DELETE", "/api/workspaces/missing", map[string]int{"viewer-token": 403, "editor-token": 200}},
+		{"POST", "/api/clean-all", map[string]int{"": 401, "viewer-token": 403, "editor-token": 403, "admin-token": 200}},
+	} {
+		for token, code := range c.codes {
+			w := serve(c.method, c.path, token)
+			assert.Equal(code, w.Code, "%s %s with %q: %s", c.method, c.path, token, w.Body.String())
+			if code == http.StatusUnauthorized {
+				assert.Equal(`Bearer realm="sim-gui"`, w.Header().Get("WWW-Authenticate"))
+			}
+		}
+	}
+
+	// the user of the token is recorded, the user header is ignored
+	r := httptest.NewRequest("POST", "/api/workspaces?access_token=editor-token", nil)
+	r.Header.Set("X-Forwarded-User", "mallory")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("eve", w.Body.String())
+	assert.Empty(serve("GET", "/api/healthz", "").Body.String())
+
+	// without tokens every request passes
+	s.SetTokens(nil)
+	assert.Equal(http.StatusOK, serve("POST", "/api/clean-all", "").Code)
+}
+
+func Test_RouteClass(t *testing.T) {
+	assert := require.New(t)
+	classes := make(map[string]routeClass)
+	for _, rt := range (&Server{}).routes() {
+		classes[rt.Method+" "+rt.Path] = rt.class()
+		// docker inspect of a simulator is for admins debugging it
+		if rt.Method == http.MethodGet && rt.Path != "/api/healthz" && rt.Path != "/api/workspaces/{name}/versions/{versionID}/inspect" {
+			assert.Equal(classRead, rt.class(), "%s %s", rt.Method, rt.Path)
+		}
+	}
+
+	assert.Equal(classPublic, classes["GET /api/healthz"])
+	assert.Equal(classCreate, classes["POST /api/workspaces"])
+	assert.Equal(classRead, classes["POST /api/workspaces/{name}/versions/{versionID}/rbac-check"])
+	assert.Equal(classWrite, classes["DELETE /api/workspaces/{name}"])
+	assert.Equal(classWrite, classes["POST /api/workspaces/{name}/versions/{versionID}/start"])
+	assert.Equal(classAdmin, classes["POST /api/clean-all"])
+	assert.Equal(classAdmin, classes["POST /api/trash/{id}/restore"])
+	assert.Equal(classAdmin, classes["GET /api/workspaces/{name}/versions/{versionID}/inspect"])
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// maxDerivedNameLength keeps derived workspace names short enough for container and image names
+const maxDerivedNameLength = 48
+
+var errAutoImportBundle = errors.New("auto-import expects a support bundle zip, possibly split into several files")
+
+type autoImportResponse struct {
+	Workspace string `json:"workspace"`
+	VersionID string `json:"versionID"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+}
+
+// handleAutoImport creates a workspace named after the cluster and collection date of the uploaded
+// bundle together with its first version. With dryRun=true only the derived name is returned.
+func (s *Server) handleAutoImport(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	limits := s.uploadLimits
+	if !limitUploadSize(w, r, limits) {
+		return
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uploadDir, err := s.newStagingDir()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(uploadDir)
+
+	form, err := saveUploadParts(reader, uploadDir, limits)
+	if err != nil {
+		status, err := uploadError(err, limits)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if len(form.Files) == 0 {
+		http.Error(w, errNoFileUploaded.Error(), http.StatusBadRequest)
+		return
+	}
+	if form.Layout == layoutExtracted || isKubeconfigFile(form.Files) {
+		http.Error(w, errAutoImportBundle.Error(), http.StatusBadRequest)
+		return
+	}
+
+	files, err := joinSplitBundle(form.Files, uploadDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	meta, err := readZipMetadata(files[0].Path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v: %v", errAutoImportBundle, err), http.StatusBadRequest)
+		return
+	}
+
+	workspaces, err := s.store.ListWorkspaces()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	taken := make(map[string]bool, len(workspaces))
+	for _, ws := range workspaces {
+		taken[ws.Name] = true
+	}
+	base := deriveWorkspaceName(meta, time.Now())
+
+	if dryRun {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(autoImportResponse{Workspace: uniqueName(base, taken), VersionID: "v1", DryRun: true})
+		return
+	}
+
+	user := s.requestUser(r)
+	ws, err := s.createDerivedWorkspace(base, taken, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.PublishBy(user, events.WorkspaceCreated, ws.Name, "", ws)
+
+	versionID := getNextVersionID(&ws)
+	version, err := s.createVersion(ws.Name, versionID, writtenBy(user, func(dir string) (*model.Version, error) {
+		return processSupportBundleUpload(files, dir, versionID, limits.Extract)
+	}))
+	if err != nil {
+		// The workspace was only created for this bundle
+		s.beginDelete(ws.Name)
+		if delErr := s.store.DeleteWorkspace(ws.Name); delErr != nil {
+			fmt.Printf("Failed to delete workspace %s: %v\n", ws.Name, delErr)
+		} else {
+			os.RemoveAll(filepath.Join(s.dataDir, "workspaces", ws.Name))
+			s.events.Publish(events.WorkspaceDeleted, ws.Name, "", nil)
+		}
+		s.endDelete(ws.Name)
+		http.Error(w, err.Error(), extractLimitStatus(err, http.StatusInternalServerError))
+		return
+	}
+	s.events.PublishBy(user, events.VersionUploaded, ws.Name, versionID, version)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(autoImportResponse{Workspace: ws.Name, VersionID: versionID})
+}
+
+// createDerivedWorkspace creates a workspace of user named base, suffixed with a number when the name is taken
+func (s *Server) createDerivedWorkspace(base string, taken map[string]bool, user string) (model.Workspace, error) {
+	for {
+		name := uniqueName(base, taken)
+		ws := model.Workspace{
+			Name:        name,
+			DisplayName: name,
+			CreatedAt:   time.Now(),
+			CreatedBy:   user,
+			Versions:    []model.Version{},
+		}
+		err := s.createWorkspace(ws)
+		if err == nil {
+			return ws, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return ws, err
+		}
+		// Created since the workspaces were listed
+		taken[name] = true
+	}
+}
+
+// joinSplitBundle joins the parts of a split bundle into a single zip in dir
+func joinSplitBundle(files []uploadedFile, dir string) ([]uploadedFile, error) {
+	if len(files) == 1 {
+		return files, nil
+	}
+	sorted := append([]uploadedFile{}, files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	joined := uploadedFile{Name: "bundle.zip", Path: filepath.Join(dir, "bundle.zip")}
+	if err := joinUploadedFiles(sorted, joined.Path); err != nil {
+		return nil, err
+	}
+	return []uploadedFile{joined}, nil
+}
+
+// readZipMetadata reads the metadata of a bundle zip, only the metadata files are decompressed
+func readZipMetadata(path string) (bundle.Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return bundle.Metadata{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return bundle.Metadata{}, err
+	}
+	return bundle.ReadZipMetadata(f, info.Size())
+}
+
+// deriveWorkspaceName names a workspace after the cluster and collection date of a bundle. Most bundles
+// don't carry a cluster name, the cluster is then identified by the start of the UUID of its Harvester
+// namespace. Bundles without a collection date are named after the current day.
+func deriveWorkspaceName(meta bundle.Metadata, now time.Time) string {
+	cluster := "bundle"
+	if name := sanitizeName(meta.ClusterName); name != "" {
+		cluster = name
+	} else if id := sanitizeName(meta.ClusterID); id != "" {
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		cluster = "harvester-" + id
+	}
+	collected := now
+	if meta.CollectedAt != nil {
+		collected = *meta.CollectedAt
+	}
+	return sanitizeName(cluster + "-" + collected.UTC().Format("2006-01-02"))
+}
+
+// sanitizeName lowercases name and replaces everything but letters, digits and single dashes,
+// so the name can be used in container and image names
+func sanitizeName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	sanitized := b.String()
+	if len(sanitized) > maxDerivedNameLength {
+		sanitized = sanitized[:maxDerivedNameLength]
+	}
+	return strings.TrimRight(sanitized, "-")
+}
+
+// uniqueName returns base, or base suffixed with -2, -3... when it is taken
+func uniqueName(base string, taken map[string]bool) string {
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
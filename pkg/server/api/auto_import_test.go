@@ -0,0 +1,111 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DeriveWorkspaceName(t *testing.T) {
+	assert := require.New(t)
+	now := time.Date(2025, 1, 2, 23, 0, 0, 0, time.FixedZone("UTC-5", -5*3600))
+	collected := time.Date(2024, 11, 18, 4, 34, 27, 0, time.UTC)
+
+	assert.Equal("harvester-f159fbe2-2024-11-18", deriveWorkspaceName(bundle.Metadata{
+		ClusterID:   "F159FBE2-dae7-4606-b81c-f54e1a562c99",
+		CollectedAt: &collected,
+	}, now))
+	assert.Equal("rack-b-2024-11-18", deriveWorkspaceName(bundle.Metadata{
+		ClusterID:   "F159FBE2-dae7-4606-b81c-f54e1a562c99",
+		ClusterName: "Rack B",
+		CollectedAt: &collected,
+	}, now))
+	// without metadata the upload day is used, in UTC like the collection date
+	assert.Equal("bundle-2025-01-03", deriveWorkspaceName(bundle.Metadata{}, now))
+}
+
+func Test_SanitizeName(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal("prod-cluster-eu-1", sanitizeName("  Prod_Cluster (EU) #1 "))
+	assert.Equal("", sanitizeName("../"))
+	assert.Len(sanitizeName(string(bytes.Repeat([]byte("a"), 100))), maxDerivedNameLength)
+}
+
+func Test_UniqueName(t *testing.T) {
+	assert := require.New(t)
+	taken := map[string]bool{"demo": true, "demo-2": true}
+	assert.Equal("demo-3", uniqueName("demo", taken))
+	assert.Equal("other", uniqueName("other", taken))
+}
+
+// bundleUpload builds a multipart body with a bundle zip holding a metadata.yaml
+func bundleUpload(t *testing.T) (*bytes.Buffer, string) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	f, err := zw.Create("supportbundle_0b1c_2024-11-18T04-34-27Z/metadata.yaml")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("projectnamespaceuuid: f159fbe2-dae7-4606-b81c-f54e1a562c99\nbundlecreatedat: \"2024-11-18T04:34:27Z\"\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", "supportbundle.zip")
+	require.NoError(t, err)
+	_, err = part.Write(zipped.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	return body, mw.Boundary()
+}
+
+func Test_AutoImportDryRun(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, UploadLimits{MaxRequestSize: 1 << 20})
+	assert.NoError(s.store.CreateWorkspace(model.Workspace{Name: "harvester-f159fbe2-2024-11-18", CreatedAt: time.Now()}))
+
+	body, boundary := bundleUpload(t)
+	req := httptest.NewRequest("POST", "/api/workspaces/auto-import?dryRun=true", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp autoImportResponse
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(autoImportResponse{Workspace: "harvester-f159fbe2-2024-11-18-2", VersionID: "v1", DryRun: true}, resp)
+
+	// nothing was created
+	workspaces, err := s.store.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(workspaces, 2)
+	_, err = os.Stat(filepath.Join(s.dataDir, "workspaces", resp.Workspace))
+	assert.True(os.IsNotExist(err))
+	assertStagingEmpty(t, s)
+}
+
+func Test_AutoImportRejectsKubeconfig(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, UploadLimits{MaxRequestSize: 1 << 20})
+
+	body, boundary := uploadBody(1<<10, "cluster.yaml")
+	req := httptest.NewRequest("POST", "/api/workspaces/auto-import", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	workspaces, err := s.store.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(workspaces, 1)
+}
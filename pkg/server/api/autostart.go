@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// autostartRetryAfter is the Retry-After of a browse request that started a simulator, in seconds
+const autostartRetryAfter = "10"
+
+// AutostartResponse answers a browse request with autostart=true when no simulator was running
+type AutostartResponse struct {
+	VersionID string `json:"versionID"`
+	Message   string `json:"message"`
+	// QueuePosition is set while the start waits for other simulators to finish starting
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+// autostartCandidate returns the most recently created support bundle version of a workspace,
+// versions whose files are missing can't be started
+func autostartCandidate(ws *model.Workspace) (model.Version, bool) {
+	var candidate model.Version
+	found := false
+	for _, v := range ws.Versions {
+		if v.Type == model.VersionTypeRuntime || v.Broken {
+			continue
+		}
+		if !found || v.CreatedAt.After(candidate.CreatedAt) {
+			candidate, found = v, true
+		}
+	}
+	return candidate, found
+}
+
+// writeNoExecutor answers a browse request that found no running simulator or runtime cluster. With
+// autostart=true the most recent support bundle version is started in the background and the client is
+// told to retry, otherwise it is a 404 with err. Browsing only reads, so callers that may not change
+// the workspace get the 404 too.
+func (s *Server) writeNoExecutor(w http.ResponseWriter, r *http.Request, ws *model.Workspace, err error) {
+	if r.URL.Query().Get("autostart") != "true" || !s.mayAutostart(r, ws) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := autostartCandidate(ws)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%v, and there is no support bundle version to start", err), http.StatusNotFound)
+		return
+	}
+
+	instanceName := version.InstanceName(ws.Name)
+	message := fmt.Sprintf("Version %s is starting, retry once it is ready", version.ID)
+	if !s.autostart(ws.Name, version) {
+		message = fmt.Sprintf("Version %s is already starting, retry once it is ready", version.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", autostartRetryAfter)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(AutostartResponse{
+		VersionID:     version.ID,
+		Message:       message,
+		QueuePosition: s.starts.position(instanceName),
+	})
+}
+
+// mayAutostart reports whether the caller of a browse request may start a simulator of ws, which takes
+// the role allowed to change it. Without tokens every caller may.
+func (s *Server) mayAutostart(r *http.Request, ws *model.Workspace) bool {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok {
+		return true
+	}
+	return s.checkAccess(identity, classWrite, ws.Name) == nil
+}
+
+// autostart starts a version in the background unless it is already starting, and reports whether it
+// did. The start takes its turn in the start queue like one from the versions page.
+func (s *Server) autostart(workspaceName string, version model.Version) bool {
+	instanceName := version.InstanceName(workspaceName)
+
+	s.autostartLock.Lock()
+	defer s.autostartLock.Unlock()
+	if s.autostarting[instanceName] || s.starts.starting(instanceName) {
+		return false
+	}
+	if s.autostarting == nil {
+		s.autostarting = make(map[string]bool)
+	}
+	s.autostarting[instanceName] = true
+
+	start := s.backgroundStart
+	if start == nil {
+		// Started with the labels and environment of the workspace and the last start request
+		start = func(ctx context.Context, workspaceName string, version model.Version) (string, error) {
+			ws, err := s.store.GetWorkspace(workspaceName)
+			if err != nil {
+				return "", err
+			}
+			extras, err := versionExtras(ws, version)
+			if err != nil {
+				return "", err
+			}
+			return s.startSimulator(ctx, workspaceName, version, extras)
+		}
+	}
+	go func() {
+		defer func() {
+			s.autostartLock.Lock()
+			delete(s.autostarting, instanceName)
+			s.autostartLock.Unlock()
+		}()
+		// Outlives the request that asked for it
+		if _, err := start(context.Background(), workspaceName, version); err != nil {
+			fmt.Printf("Failed to autostart %s-%s: %v\n", workspaceName, version.ID, err)
+		}
+	}()
+	return true
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	memstore "github.com/Yu-Jack/sim-gui/pkg/server/store/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AutostartCandidate(t *testing.T) {
+	assert := require.New(t)
+	day := time.Date(2024, 11, 18, 0, 0, 0, 0, time.UTC)
+	ws := &model.Workspace{Name: "ws", Versions: []model.Version{
+		{ID: "v1", Type: model.VersionTypeSupportBundle, CreatedAt: day},
+		{ID: "v3", Type: model.VersionTypeSupportBundle, CreatedAt: day.Add(2 * time.Hour)},
+		{ID: "v2", Type: model.VersionTypeSupportBundle, CreatedAt: day.Add(time.Hour)},
+		{ID: "broken", Type: model.VersionTypeSupportBundle, CreatedAt: day.Add(3 * time.Hour), Broken: true},
+		{ID: "live", Type: model.VersionTypeRuntime, CreatedAt: day.Add(4 * time.Hour)},
+	}}
+	version, ok := autostartCandidate(ws)
+	assert.True(ok)
+	assert.Equal("v3", version.ID, "the most recently created bundle, not the last in the list")
+
+	_, ok = autostartCandidate(&model.Workspace{Versions: []model.Version{{ID: "live", Type: model.VersionTypeRuntime}}})
+	assert.False(ok)
+}
+
+func Test_WriteNoExecutor(t *testing.T) {
+	assert := require.New(t)
+	started := make(chan string, 2)
+	finish := make(chan struct{})
+	s := &Server{
+		starts: newStartQueue(1),
+		backgroundStart: func(ctx context.Context, workspaceName string, version model.Version) (string, error) {
+			started <- workspaceName + "-" + version.ID
+			<-finish
+			return "", nil
+		},
+	}
+	ws := &model.Workspace{Name: "ws", Versions: []model.Version{{ID: "v1", Type: model.VersionTypeSupportBundle}}}
+	notFound := errors.New("no running simulator or runtime cluster found")
+	browse := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.writeNoExecutor(w, httptest.NewRequest("GET", "/api/workspaces/ws/namespaces"+query, nil), ws, notFound)
+		return w
+	}
+
+	// without autostart nothing is started
+	w := browse("")
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Contains(w.Body.String(), "no running simulator")
+
+	w = browse("?autostart=true")
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.Equal("10", w.Header().Get("Retry-After"))
+	var response AutostartResponse
+	assert.NoError(json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal("v1", response.VersionID)
+	assert.Equal("ws-v1", <-started)
+
+	// retrying while it starts doesn't start it again
+	w = browse("?autostart=true")
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.Contains(w.Body.String(), "already starting")
+	close(finish)
+	require.Eventually(t, func() bool {
+		s.autostartLock.Lock()
+		defer s.autostartLock.Unlock()
+		return len(s.autostarting) == 0
+	}, time.Second, time.Millisecond)
+	assert.Empty(started)
+
+	// nor does a start from the versions page that waits for its turn
+	assert.NoError(s.starts.acquire(context.Background(), "ws-other"))
+	queued := make(chan error, 1)
+	go func() { queued <- s.starts.acquire(context.Background(), "ws-v1") }()
+	waitQueued(t, s.starts, 1)
+	w = browse("?autostart=true")
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.NoError(json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(1, response.QueuePosition)
+	assert.Empty(started)
+	s.starts.release("ws-other")
+	assert.NoError(<-queued)
+
+	// a workspace with only runtime versions has nothing to start
+	ws.Versions = []model.Version{{ID: "live", Type: model.VersionTypeRuntime}}
+	w = browse("?autostart=true")
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Contains(w.Body.String(), "no support bundle version to start")
+}
+
+func Test_WriteNoExecutorRoles(t *testing.T) {
+	assert := require.New(t)
+	st := memstore.NewMemoryStore()
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "alices", CreatedBy: "alice", CreatedAt: time.Now()}))
+	started := make(chan string, 1)
+	s := &Server{
+		store:  st,
+		starts: newStartQueue(1),
+		backgroundStart: func(ctx context.Context, workspaceName string, version model.Version) (string, error) {
+			started <- workspaceName + "-" + version.ID
+			return "", nil
+		},
+	}
+	ws := &model.Workspace{Name: "alices", CreatedBy: "alice", Versions: []model.Version{{ID: "v1", Type: model.VersionTypeSupportBundle}}}
+	browse := func(identity auth.Identity) int {
+		r := httptest.NewRequest("GET", "/api/workspaces/alices/namespaces?autostart=true", nil)
+		w := httptest.NewRecorder()
+		s.writeNoExecutor(w, r.WithContext(auth.WithIdentity(r.Context(), identity)), ws, errors.New("no running simulator"))
+		return w.Code
+	}
+
+	// browsing doesn't let those who may not change the workspace start its simulator
+	assert.Equal(http.StatusNotFound, browse(auth.Identity{User: "victor", Role: auth.RoleViewer}))
+	assert.Equal(http.StatusNotFound, browse(auth.Identity{User: "eve", Role: auth.RoleEditor}))
+	assert.Empty(started)
+
+	assert.Equal(http.StatusAccepted, browse(auth.Identity{User: "alice", Role: auth.RoleEditor}))
+	assert.Equal("alices-v1", <-started)
+	require.Eventually(t, func() bool {
+		s.autostartLock.Lock()
+		defer s.autostartLock.Unlock()
+		return len(s.autostarting) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(http.StatusAccepted, browse(auth.Identity{User: "root", Role: auth.RoleAdmin}))
+	assert.Equal("alices-v1", <-started)
+}
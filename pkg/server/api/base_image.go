@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// baseImagePuller is the part of the docker client pulling the base image of simulators
+type baseImagePuller interface {
+	EnsureImage(ctx context.Context, imageName string) error
+	ImagePullProgress(imageName string) (docker.PullProgress, bool)
+}
+
+// BaseImagePull is the pull of the base image a start waits for before the simulator image is built
+type BaseImagePull struct {
+	// Message describes the wait, e.g. "waiting for base image pull (42%)"
+	Message string `json:"message"`
+	docker.PullProgress
+}
+
+// waitForBaseImage pulls the base image when it is missing, e.g. on the first start after installing,
+// the status of the simulator reports the pull meanwhile. A failed pull is recorded as a build error.
+func (s *Server) waitForBaseImage(ctx context.Context, puller baseImagePuller, workspaceName, versionID, instanceName, baseImage string) error {
+	s.basePullLock.Lock()
+	if s.basePulls == nil {
+		s.basePulls = make(map[string]string)
+	}
+	s.basePulls[instanceName] = baseImage
+	s.basePullLock.Unlock()
+	defer func() {
+		s.basePullLock.Lock()
+		delete(s.basePulls, instanceName)
+		s.basePullLock.Unlock()
+	}()
+
+	if err := puller.EnsureImage(ctx, baseImage); err != nil {
+		err = fmt.Errorf("Failed to pull base image %s: %w", baseImage, err)
+		s.recordVersionError(workspaceName, versionID, model.ErrorStageBuild, err)
+		return err
+	}
+	return nil
+}
+
+// baseImagePull returns the pull the start of an instance waits for, nil when it waits for none
+func (s *Server) baseImagePull(puller baseImagePuller, instanceName string) *BaseImagePull {
+	s.basePullLock.Lock()
+	baseImage, ok := s.basePulls[instanceName]
+	s.basePullLock.Unlock()
+	if !ok {
+		return nil
+	}
+	// The last pull may have finished before, the image is present then
+	progress, ok := puller.ImagePullProgress(baseImage)
+	if !ok || progress.State != docker.PullPulling {
+		return nil
+	}
+	message := "waiting for base image pull"
+	if progress.Total > 0 {
+		message += fmt.Sprintf(" (%d%%)", progress.Percent)
+	}
+	return &BaseImagePull{Message: message, PullProgress: progress}
+}
+
+// handlePullStatus lists the image pulls since the server started with the progress of their layers
+func (s *Server) handlePullStatus(w http.ResponseWriter, r *http.Request) {
+	pulls := []docker.PullProgress{}
+	if s.docker != nil {
+		pulls = s.docker.PullStatus()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pulls)
+}
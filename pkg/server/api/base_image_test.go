@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPuller pulls images until release is closed, reporting progress meanwhile
+type blockingPuller struct {
+	progress docker.PullProgress
+	started  chan struct{}
+	release  chan struct{}
+	err      error
+}
+
+func (p *blockingPuller) EnsureImage(ctx context.Context, imageName string) error {
+	close(p.started)
+	<-p.release
+	return p.err
+}
+
+func (p *blockingPuller) ImagePullProgress(imageName string) (docker.PullProgress, bool) {
+	return p.progress, imageName == p.progress.Image
+}
+
+func Test_WaitForBaseImage(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	_, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+	puller := &blockingPuller{
+		progress: docker.PullProgress{Image: simulatorBaseImage, State: docker.PullPulling, Downloaded: 420, Total: 1000, Percent: 42, Attempt: 2},
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		err:      errors.New("toomanyrequests: rate limit exceeded"),
+	}
+	assert.Nil(s.baseImagePull(puller, "ws-v1"))
+
+	done := make(chan error)
+	go func() { done <- s.waitForBaseImage(context.Background(), puller, "ws", "v1", "ws-v1", simulatorBaseImage) }()
+	<-puller.started
+	pull := s.baseImagePull(puller, "ws-v1")
+	assert.NotNil(pull)
+	assert.Equal("waiting for base image pull (42%)", pull.Message)
+	assert.Equal(2, pull.Attempt)
+	assert.Nil(s.baseImagePull(puller, "ws-v2"), "other starts don't wait")
+
+	// the failure of the pull is kept on the version
+	close(puller.release)
+	assert.EqualError(<-done, "Failed to pull base image rancher/support-bundle-kit:master-head: toomanyrequests: rate limit exceeded")
+	assert.Nil(s.baseImagePull(puller, "ws-v1"))
+	stored, _ := findVersionIn(t, s, "ws", "v1")
+	assert.NotNil(stored.LastError)
+	assert.Equal(model.ErrorStageBuild, stored.LastError.Stage)
+
+	// a finished pull isn't waited for
+	puller.progress.State = docker.PullDone
+	s.basePulls["ws-v1"] = simulatorBaseImage
+	assert.Nil(s.baseImagePull(puller, "ws-v1"))
+}
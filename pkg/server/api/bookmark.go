@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+var errDuplicateBookmark = errors.New("bookmark already exists")
+
+// findBookmark returns the index of the bookmark pointing to the same resource, labels are ignored
+func findBookmark(bookmarks []model.Bookmark, b model.Bookmark) int {
+	for i, existing := range bookmarks {
+		if existing.Namespace == b.Namespace && existing.ResourceType == b.ResourceType && existing.Name == b.Name {
+			return i
+		}
+	}
+	return -1
+}
+
+// addBookmark appends b to a copy of bookmarks, the original may share its backing array with the store
+func addBookmark(bookmarks []model.Bookmark, b model.Bookmark) ([]model.Bookmark, error) {
+	if findBookmark(bookmarks, b) != -1 {
+		return nil, errDuplicateBookmark
+	}
+	return append(append(make([]model.Bookmark, 0, len(bookmarks)+1), bookmarks...), b), nil
+}
+
+// removeBookmark returns a copy of bookmarks without b and whether it was found
+func removeBookmark(bookmarks []model.Bookmark, b model.Bookmark) ([]model.Bookmark, bool) {
+	i := findBookmark(bookmarks, b)
+	if i == -1 {
+		return bookmarks, false
+	}
+	result := append(make([]model.Bookmark, 0, len(bookmarks)-1), bookmarks[:i]...)
+	return append(result, bookmarks[i+1:]...), true
+}
+
+func decodeBookmark(r *http.Request) (model.Bookmark, error) {
+	var b model.Bookmark
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		return b, err
+	}
+	b.Namespace = strings.TrimSpace(b.Namespace)
+	b.ResourceType = strings.TrimSpace(b.ResourceType)
+	b.Name = strings.TrimSpace(b.Name)
+	if b.ResourceType == "" || b.Name == "" {
+		return b, errors.New("resourceType and name are required")
+	}
+	return b, nil
+}
+
+func (s *Server) handleAddBookmark(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	bookmark, err := decodeBookmark(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ws.Bookmarks, err = addBookmark(ws.Bookmarks, bookmark)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+func (s *Server) handleDeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	bookmark, err := decodeBookmark(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var found bool
+	ws.Bookmarks, found = removeBookmark(ws.Bookmarks, bookmark)
+	if !found {
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// defaultNamespaceRequest is the body of a default namespace change
+type defaultNamespaceRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+func (s *Server) handleSetDefaultNamespace(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req defaultNamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// An empty namespace clears the default
+	ws.DefaultNamespace = strings.TrimSpace(req.Namespace)
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.WriteHeader(http.StatusOK)
+}
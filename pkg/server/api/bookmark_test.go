@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Bookmarks(t *testing.T) {
+	assert := require.New(t)
+	vm := model.Bookmark{Namespace: "default", ResourceType: "virtualmachine", Name: "vm1", Label: "broken vm"}
+	node := model.Bookmark{ResourceType: "node", Name: "node1"}
+
+	bookmarks, err := addBookmark(nil, vm)
+	assert.NoError(err)
+	bookmarks, err = addBookmark(bookmarks, node)
+	assert.NoError(err)
+	assert.Equal([]model.Bookmark{vm, node}, bookmarks)
+
+	// duplicates are matched regardless of the label
+	_, err = addBookmark(bookmarks, model.Bookmark{Namespace: "default", ResourceType: "virtualmachine", Name: "vm1"})
+	assert.ErrorIs(err, errDuplicateBookmark)
+
+	// removing doesn't modify the slice shared with the store
+	remaining, found := removeBookmark(bookmarks, model.Bookmark{Namespace: "default", ResourceType: "virtualmachine", Name: "vm1"})
+	assert.True(found)
+	assert.Equal([]model.Bookmark{node}, remaining)
+	assert.Equal([]model.Bookmark{vm, node}, bookmarks)
+
+	_, found = removeBookmark(remaining, vm)
+	assert.False(found)
+}
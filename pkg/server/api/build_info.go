@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/version"
+)
+
+// BuildInfo describes the running server and the docker daemon it talks to
+type BuildInfo struct {
+	version.Info
+	DockerVersion string `json:"dockerVersion"`
+	DockerError   string `json:"dockerError,omitempty"`
+}
+
+func (s *Server) handleGetBuildInfo(w http.ResponseWriter, r *http.Request) {
+	info := BuildInfo{
+		Info: version.Get(),
+	}
+
+	dockerVersion, err := s.docker.ServerVersion()
+	if err != nil {
+		info.DockerError = err.Error()
+	} else {
+		info.DockerVersion = dockerVersion
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+)
+
+const (
+	buildLogFile = "build.log"
+	// buildLogTailLines is how many lines of the build log a failed start includes in its error
+	buildLogTailLines = 20
+	// interruptedBuildMessage is recorded on versions whose image build was cut short by a server stop
+	interruptedBuildMessage = "the image build was interrupted when the server stopped, start the simulator again"
+)
+
+func (s *Server) buildLogPath(workspaceName, versionID string) string {
+	return filepath.Join(s.dataDir, "workspaces", workspaceName, versionID, buildLogFile)
+}
+
+// imageBuilder is the part of the docker client buildImage needs
+type imageBuilder interface {
+	InspectImage(ref string) (docker.ImageInfo, error)
+	CreateImageWithLog(instanceName string, owner docker.Owner, bundlePath string, baseImage string, buildLog io.Writer) error
+}
+
+// buildImage builds the simulator image of a version, overwriting its build log with the docker
+// output and recording the outcome on the version
+func (s *Server) buildImage(builder imageBuilder, workspaceName, versionID, instanceName, bundlePath, baseImage string) error {
+	var buildLog io.Writer
+	f, err := os.Create(s.buildLogPath(workspaceName, versionID))
+	if err != nil {
+		fmt.Printf("Failed to create build log for %s: %v\n", instanceName, err)
+	} else {
+		defer f.Close()
+		buildLog = f
+	}
+
+	// The base image floats on a tag, remember which build of it the simulator got
+	base, baseErr := builder.InspectImage(baseImage)
+	if baseErr != nil {
+		fmt.Printf("Failed to inspect base image of %s: %v\n", instanceName, baseErr)
+	}
+	// Left set when the server stops during the build, the next startup reports the build as interrupted
+	err = updateVersion(s.store, workspaceName, versionID, func(v *model.Version) bool {
+		v.Building = true
+		return true
+	})
+	if err != nil {
+		fmt.Printf("Failed to record build start of %s: %v\n", instanceName, err)
+	}
+	buildErr := builder.CreateImageWithLog(instanceName, docker.Owner{Profile: s.profile, Workspace: workspaceName, Version: versionID}, bundlePath, baseImage, buildLog)
+
+	summary := ""
+	if buildErr != nil {
+		summary = buildErr.Error()
+		if f != nil {
+			fmt.Fprintf(f, "\nBuild failed: %s\n", summary)
+		}
+	}
+	err = updateVersion(s.store, workspaceName, versionID, func(v *model.Version) bool {
+		// A successful build uses the current bundle
+		stale := v.ImageStale && buildErr != nil
+		changed := v.BuildError != summary || v.ImageStale != stale || v.Building || v.Interrupted != ""
+		v.BuildError = summary
+		v.ImageStale = stale
+		v.Building = false
+		v.Interrupted = ""
+		if buildErr == nil && baseErr == nil && v.BaseImageDigest != base.Digest {
+			created := base.CreatedAt
+			v.BaseImageDigest, v.BaseImageCreated = base.Digest, &created
+			changed = true
+		}
+		return changed
+	})
+	if err != nil {
+		fmt.Printf("Failed to record build result of %s: %v\n", instanceName, err)
+	}
+
+	if buildErr != nil {
+		s.recordVersionError(workspaceName, versionID, model.ErrorStageBuild, buildErr)
+	} else {
+		s.clearVersionError(workspaceName, versionID, model.ErrorStageBuild)
+	}
+	return buildErr
+}
+
+// interruptBuilds marks the versions still building when the server stopped as interrupted and returns
+// their instance names. Nothing builds before the server is started, so any Building version is left over.
+func interruptBuilds(st store.Storage) ([]string, error) {
+	workspaces, err := st.ListWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []string
+	for _, ws := range workspaces {
+		changed := false
+		for i := range ws.Versions {
+			v := &ws.Versions[i]
+			if !v.Building {
+				continue
+			}
+			v.Building = false
+			v.Ready = false
+			v.Interrupted = interruptedBuildMessage
+			changed = true
+			instances = append(instances, v.InstanceName(ws.Name))
+		}
+		if changed {
+			if err := st.UpdateWorkspace(ws); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return instances, nil
+}
+
+// recoverInterruptedBuilds marks the builds cut short by the previous run as interrupted and removes
+// the untagged images they left behind
+func (s *Server) recoverInterruptedBuilds() {
+	instances, err := interruptBuilds(s.store)
+	if err != nil {
+		fmt.Printf("Failed to recover interrupted image builds: %v\n", err)
+		return
+	}
+	for _, instanceName := range instances {
+		fmt.Printf("Image build of %s was interrupted\n", instanceName)
+		if err := s.docker.RemoveDanglingImages(instanceName); err != nil {
+			fmt.Printf("Failed to remove images of the interrupted build of %s: %v\n", instanceName, err)
+		}
+	}
+}
+
+// tailLines returns the last n lines of a file
+func tailLines(path string, n int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *Server) handleGetBuildLog(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	tail, err := parseTailLines(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := serveFileContent(w, r, s.buildLogPath(name, versionID), tail); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "No image has been built for this version yet", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
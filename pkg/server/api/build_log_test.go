@@ -0,0 +1,26 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TailLines(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), buildLogFile)
+	assert.NoError(os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+
+	tail, err := tailLines(path, 2)
+	assert.NoError(err)
+	assert.Equal("two\nthree", tail)
+
+	tail, err = tailLines(path, 10)
+	assert.NoError(err)
+	assert.Equal("one\ntwo\nthree", tail)
+
+	_, err = tailLines(filepath.Join(t.TempDir(), "missing.log"), 2)
+	assert.True(os.IsNotExist(err))
+}
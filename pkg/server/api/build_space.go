@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	units "github.com/docker/go-units"
+)
+
+const (
+	// bundleExtractionFactor is the space a build takes per byte of a zipped bundle: the build
+	// context holds the archive and the image layer its contents, logs and YAML compress about
+	// three times
+	bundleExtractionFactor = 4
+	// extractedBundleFactor is the same for an extracted bundle, copied to the build context and
+	// the image layer
+	extractedBundleFactor = 2
+	// tightSpaceFactor is how much more than the estimate has to be available not to warn
+	tightSpaceFactor = 1.5
+)
+
+// insufficientSpaceError is returned when a build can't fit on the docker filesystem
+type insufficientSpaceError struct {
+	required, available, reclaimable int64
+}
+
+func (e insufficientSpaceError) Error() string {
+	msg := fmt.Sprintf("not enough disk space to build the simulator image: about %s is needed but only %s is available to docker",
+		units.BytesSize(float64(e.required)), units.BytesSize(float64(e.available)))
+	if e.reclaimable > 0 {
+		msg += fmt.Sprintf(", pruning unused images and build cache could free %s", units.BytesSize(float64(e.reclaimable)))
+	}
+	return msg
+}
+
+// buildSpaceWarning checks the disk space of docker before building the simulator image of
+// bundlePath. It returns an error when the build won't fit and a warning when it barely does,
+// builds are not held back when docker doesn't report the space it has.
+func (s *Server) buildSpaceWarning(bundlePath, baseImage string) (string, error) {
+	space, err := s.docker.DiskSpace()
+	if err != nil {
+		fmt.Printf("Failed to get docker disk space: %v\n", err)
+		return "", nil
+	}
+	if space.Available < 0 {
+		return "", nil
+	}
+
+	// A base image that isn't pulled yet isn't counted, its size is unknown before the pull
+	baseImageSize, err := s.docker.ImageSize(baseImage)
+	if err != nil {
+		baseImageSize = 0
+	}
+	required, err := estimateBuildSpace(bundlePath, baseImageSize)
+	if err != nil {
+		return "", err
+	}
+	return checkBuildSpace(required, space.Available, space.Reclaimable)
+}
+
+// estimateBuildSpace estimates the disk space building the image of a bundle takes
+func estimateBuildSpace(bundlePath string, baseImageSize int64) (int64, error) {
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size()*bundleExtractionFactor + baseImageSize, nil
+	}
+
+	var size int64
+	err = filepath.WalkDir(bundlePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size*extractedBundleFactor + baseImageSize, nil
+}
+
+// checkBuildSpace compares the estimated space of a build with what docker has left
+func checkBuildSpace(required, available, reclaimable int64) (string, error) {
+	if available < required {
+		return "", insufficientSpaceError{required: required, available: available, reclaimable: reclaimable}
+	}
+	if float64(available) < float64(required)*tightSpaceFactor {
+		return fmt.Sprintf("Disk space is tight: the build needs about %s and %s is available to docker",
+			units.BytesSize(float64(required)), units.BytesSize(float64(available))), nil
+	}
+	return "", nil
+}
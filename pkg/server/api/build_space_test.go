@@ -0,0 +1,48 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EstimateBuildSpace(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+
+	zip := filepath.Join(dir, "bundle.zip")
+	assert.NoError(os.WriteFile(zip, make([]byte, 1000), 0644))
+	required, err := estimateBuildSpace(zip, 500)
+	assert.NoError(err)
+	assert.Equal(int64(1000*bundleExtractionFactor+500), required)
+
+	extracted := filepath.Join(dir, "extracted")
+	writeFile(t, filepath.Join(extracted, "logs", "harvester.log"))
+	writeFile(t, filepath.Join(extracted, "yamls", "nodes.yaml"))
+	required, err = estimateBuildSpace(extracted, 0)
+	assert.NoError(err)
+	assert.Equal(int64(2*len("data")*extractedBundleFactor), required)
+
+	_, err = estimateBuildSpace(filepath.Join(dir, "missing.zip"), 0)
+	assert.Error(err)
+}
+
+func Test_CheckBuildSpace(t *testing.T) {
+	assert := require.New(t)
+	const gib = 1 << 30
+
+	warning, err := checkBuildSpace(10*gib, 100*gib, 0)
+	assert.NoError(err)
+	assert.Empty(warning)
+
+	warning, err = checkBuildSpace(10*gib, 12*gib, 0)
+	assert.NoError(err)
+	assert.Equal("Disk space is tight: the build needs about 10GiB and 12GiB is available to docker", warning)
+
+	_, err = checkBuildSpace(60*gib, 8*gib, 3*gib)
+	var spaceErr insufficientSpaceError
+	assert.ErrorAs(err, &spaceErr)
+	assert.EqualError(err, "not enough disk space to build the simulator image: about 60GiB is needed but only 8GiB is available to docker, pruning unused images and build cache could free 3GiB")
+}
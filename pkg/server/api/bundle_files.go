@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// sourceBundleFile marks resource-history results read from the extracted bundle instead of the simulator
+const sourceBundleFile = "bundle-file"
+
+// bundleFiles are the files of the extracted bundle of a version whose simulator isn't running
+type bundleFiles struct {
+	extracted string
+	// paths are slash separated and relative to extracted
+	paths []string
+}
+
+// stoppedBundleFiles returns the files of the extracted bundle of a version, nil when the bundle was
+// removed or is being extracted again
+func (s *Server) stoppedBundleFiles(workspaceName string, v model.Version) *bundleFiles {
+	if v.Type == model.VersionTypeRuntime || v.ExtractedRemoved || s.extraction(v.InstanceName(workspaceName)) != nil {
+		return nil
+	}
+	extracted := s.extractedPath(workspaceName, v.ID)
+	paths, err := indexedPaths(extracted)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Failed to list the files of %s-%s: %v\n", workspaceName, v.ID, err)
+		}
+		return nil
+	}
+	return &bundleFiles{extracted: extracted, paths: paths}
+}
+
+// indexedPaths returns the paths of the file index of the extracted directory, versions extracted
+// before files were indexed are walked
+func indexedPaths(extracted string) ([]string, error) {
+	f, err := os.Open(filepath.Join(filepath.Dir(extracted), fileIndexName))
+	if os.IsNotExist(err) {
+		var paths []string
+		err := filepath.WalkDir(extracted, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(extracted, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+			return nil
+		})
+		return paths, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry FileIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to read file index: %w", err)
+		}
+		paths = append(paths, entry.Path)
+	}
+	return paths, scanner.Err()
+}
+
+// result looks a named resource up in the dumps of the bundle. A resource whose type was dumped but
+// that isn't in the dumps wasn't in the cluster, ok is false for the resources the bundle can't answer:
+// those got with a selector, without a name or of types the bundle didn't capture.
+func (b *bundleFiles) result(resource, selector, defaultNamespace string) (ResourceHistoryResult, bool) {
+	namespace, resourceType, name, ok := splitResource(resource, defaultNamespace)
+	if b == nil || !ok || selector != "" {
+		return ResourceHistoryResult{}, false
+	}
+	// The kubeconfig of the simulator has no namespace, kubectl uses default then
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	candidates := bundle.ManifestCandidates(b.paths, namespace, resourceType)
+	if len(candidates) == 0 {
+		return ResourceHistoryResult{}, false
+	}
+	for _, candidate := range candidates {
+		content, found, err := bundle.FindObject(filepath.Join(b.extracted, filepath.FromSlash(candidate)), name)
+		if err != nil {
+			fmt.Printf("Failed to look %s up in %s: %v\n", resource, candidate, err)
+			return ResourceHistoryResult{}, false
+		}
+		if found {
+			return ResourceHistoryResult{Status: utils.KubectlFound, Content: content, Source: sourceBundleFile}, true
+		}
+	}
+	return ResourceHistoryResult{
+		Status: utils.KubectlNotFound,
+		Error:  fmt.Sprintf("%s %q not found in the support bundle", resourceType, name),
+		Source: sourceBundleFile,
+	}, true
+}
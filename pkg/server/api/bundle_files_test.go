@@ -0,0 +1,69 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BundleFilesResult(t *testing.T) {
+	assert := require.New(t)
+	extracted := filepath.Join(t.TempDir(), "extracted")
+	yamls := filepath.Join(extracted, "supportbundle_1", "yamls")
+	for file, content := range map[string]string{
+		"cluster/v1/nodes.yaml":                       "apiVersion: v1\nitems:\n- kind: Node\n  metadata:\n    name: harvester-01\nkind: List\n",
+		"namespaced/default/v1/configmaps.yaml":       "apiVersion: v1\nitems:\n- kind: ConfigMap\n  metadata:\n    name: web\n    namespace: default\nkind: List\n",
+		"namespaced/cattle-system/v1/configmaps.yaml": "apiVersion: v1\nitems:\n- kind: ConfigMap\n  metadata:\n    name: rancher\n    namespace: cattle-system\nkind: List\n",
+	} {
+		path := filepath.Join(yamls, filepath.FromSlash(file))
+		assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(os.WriteFile(path, []byte(content), 0644))
+	}
+
+	// Walked without a file index, then read from it
+	paths, err := indexedPaths(extracted)
+	assert.NoError(err)
+	assert.Len(paths, 3)
+	_, err = buildFileIndex(extracted)
+	assert.NoError(err)
+	indexed, err := indexedPaths(extracted)
+	assert.NoError(err)
+	assert.ElementsMatch(paths, indexed)
+	files := &bundleFiles{extracted: extracted, paths: indexed}
+
+	result, ok := files.result("node/harvester-01", "", "")
+	assert.True(ok)
+	assert.Equal(utils.KubectlFound, result.Status)
+	assert.Equal(sourceBundleFile, result.Source)
+	assert.Equal("kind: Node\nmetadata:\n  name: harvester-01\n", result.Content)
+
+	result, ok = files.result("cm/web", "", "")
+	assert.True(ok, "expected the default namespace without one")
+	assert.Equal(utils.KubectlFound, result.Status)
+	result, ok = files.result("cattle-system/cm/rancher", "", "default")
+	assert.True(ok)
+	assert.Equal(utils.KubectlFound, result.Status)
+	result, ok = files.result("cm/rancher", "", "cattle-system")
+	assert.True(ok)
+	assert.Equal(utils.KubectlFound, result.Status)
+
+	result, ok = files.result("cm/rancher", "", "")
+	assert.True(ok)
+	assert.Equal(utils.KubectlNotFound, result.Status, "expected configmaps of other namespaces not to match")
+	assert.Equal(sourceBundleFile, result.Source)
+
+	// Not captured, got with a selector or without a name
+	for _, resource := range []string{"secrets/web", "kube-system/cm/coredns", "configmaps"} {
+		_, ok = files.result(resource, "", "")
+		assert.False(ok, resource)
+	}
+	_, ok = files.result("cm/web", "app=web", "")
+	assert.False(ok)
+
+	var none *bundleFiles
+	_, ok = none.result("node/harvester-01", "", "")
+	assert.False(ok)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// Statuses of a CleanVersionResult
+const (
+	cleanStatusCleaned = "cleaned"
+	cleanStatusFailed  = "failed"
+	// cleanStatusPlanned is the status of every version of a dry run
+	cleanStatusPlanned = "planned"
+)
+
+// instanceCleaner removes the containers and images of simulator instances, a *docker.Cleaner
+type instanceCleaner interface {
+	Clean(instanceName string, dryRun bool) (*docker.CleanPlan, error)
+}
+
+// CleanVersionResult is what cleaning the simulator of a single version removed, or on a dry run
+// what it would remove
+type CleanVersionResult struct {
+	Workspace string `json:"workspace"`
+	VersionID string `json:"versionID"`
+	Status    string `json:"status"` // "cleaned", "failed" or "planned"
+	docker.CleanPlan
+	// CodeServer is the removal of the copy of the version from code-server, unset on a dry run
+	CodeServer *CleanupResult `json:"codeServer,omitempty"`
+	// Error is why the version wasn't cleaned completely, the items that failed carry their own error
+	Error string `json:"error,omitempty"`
+}
+
+// CleanReport answers the clean endpoints
+type CleanReport struct {
+	DryRun   bool                 `json:"dryRun"`
+	Versions []CleanVersionResult `json:"versions"`
+	// ReclaimableBytes adds up the image sizes of every version
+	ReclaimableBytes int64 `json:"reclaimableBytes"`
+	// Failed counts the versions that weren't cleaned completely
+	Failed int `json:"failed"`
+}
+
+func (r *CleanReport) add(result CleanVersionResult) {
+	r.Versions = append(r.Versions, result)
+	r.ReclaimableBytes += result.ReclaimableBytes
+	if result.Status == cleanStatusFailed {
+		r.Failed++
+	}
+}
+
+// cleanVersion stops and removes the container and images of a version, removes its copy from
+// code-server and resets its ready state. With dryRun nothing is changed, the result lists what would
+// be removed.
+func (s *Server) cleanVersion(workspaceName, versionID string, dryRun bool) CleanVersionResult {
+	result := CleanVersionResult{Workspace: workspaceName, VersionID: versionID, Status: cleanStatusCleaned}
+	if dryRun {
+		result.Status = cleanStatusPlanned
+	}
+	runtime := s.cleanRuntime(instanceNameOf(s.store, workspaceName, versionID), dryRun)
+	if runtime.Plan != nil {
+		result.CleanPlan = *runtime.Plan
+	}
+	var err error
+	if runtime.Error != "" {
+		err = fmt.Errorf("%s", runtime.Error)
+	}
+	if err == nil && !dryRun {
+		// The copy is made from the extracted files again when the version is next opened in code-server,
+		// a failure leaves an orphaned directory and doesn't fail the clean
+		if ws, lookupErr := s.store.GetWorkspace(workspaceName); lookupErr == nil {
+			if version, ok := findVersion(ws, versionID); ok {
+				codeServer := s.cleanCodeServer(workspaceName, version)
+				result.CodeServer = &codeServer
+			}
+		}
+		err = s.ResetVersionReadyState(workspaceName, versionID)
+	}
+	if err != nil {
+		result.Status = cleanStatusFailed
+		result.Error = err.Error()
+	}
+	if !dryRun {
+		// The container or images left behind keep the version from starting cleanly
+		if runtime.Error != "" {
+			s.recordVersionError(workspaceName, versionID, model.ErrorStageCleanup, errors.New(runtime.Error))
+		} else {
+			s.clearVersionError(workspaceName, versionID, model.ErrorStageCleanup)
+		}
+	}
+	return result
+}
+
+// writeCleanReport writes a clean report. It is a 207 when only some of the versions were cleaned
+// and a 500 when none of them were.
+func writeCleanReport(w http.ResponseWriter, report CleanReport) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case report.Failed == 0:
+	case report.Failed < len(report.Versions):
+		w.WriteHeader(http.StatusMultiStatus)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(report)
+}
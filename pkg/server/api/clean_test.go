@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCleaner fails to remove the image of the instances in fail
+type failingCleaner struct {
+	fail    map[string]bool
+	cleaned []string
+}
+
+func (c *failingCleaner) Clean(instanceName string, dryRun bool) (*docker.CleanPlan, error) {
+	plan := &docker.CleanPlan{
+		Instance:         instanceName,
+		Containers:       []docker.CleanItem{},
+		Images:           []docker.CleanItem{{ID: "sha256:" + instanceName, Name: "sim-cli-managed:" + instanceName, Size: 100}},
+		ReclaimableBytes: 100,
+	}
+	if dryRun {
+		return plan, nil
+	}
+	if c.fail[instanceName] {
+		plan.Images[0].Error = "error removing image sha256:" + instanceName
+		return plan, fmt.Errorf("%s", plan.Images[0].Error)
+	}
+	plan.Images[0].Removed = true
+	c.cleaned = append(c.cleaned, instanceName)
+	return plan, nil
+}
+
+func newCleanServer(t *testing.T, fail ...string) (*Server, *failingCleaner) {
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	require.NoError(t, err)
+	for _, name := range []string{"ws1", "ws2"} {
+		require.NoError(t, st.CreateWorkspace(model.Workspace{Name: name, CreatedAt: time.Now(), Versions: []model.Version{
+			{ID: "v1", Ready: true},
+			{ID: "v2", Ready: true},
+		}}))
+	}
+	cleaner := &failingCleaner{fail: map[string]bool{}}
+	for _, instance := range fail {
+		cleaner.fail[instance] = true
+	}
+	return &Server{store: st, events: events.NewBus(), cleaner: cleaner, codeServer: &recordingExec{}}, cleaner
+}
+
+func cleanAll(t *testing.T, s *Server, query string) (int, CleanReport) {
+	rec := httptest.NewRecorder()
+	s.handleCleanAllImages(rec, httptest.NewRequest("POST", "/api/clean-all"+query, nil))
+	var report CleanReport
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+func Test_CleanAllImagesPartialFailure(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner := newCleanServer(t, "ws1-v2")
+
+	code, report := cleanAll(t, s, "")
+	assert.Equal(http.StatusMultiStatus, code)
+	assert.Equal(1, report.Failed)
+	assert.ElementsMatch([]string{"ws1-v1", "ws2-v1", "ws2-v2"}, cleaner.cleaned, "the other versions are cleaned anyway")
+
+	statuses := map[string]string{}
+	for _, result := range report.Versions {
+		statuses[result.Workspace+"/"+result.VersionID] = result.Status
+		if result.Status == "failed" {
+			assert.Equal("error removing image sha256:ws1-v2", result.Error)
+		}
+	}
+	assert.Equal(map[string]string{"ws1/v1": "cleaned", "ws1/v2": "failed", "ws2/v1": "cleaned", "ws2/v2": "cleaned"}, statuses)
+
+	// only the cleaned versions have to load their bundle again
+	ws, err := s.store.GetWorkspace("ws1")
+	assert.NoError(err)
+	assert.False(ws.Versions[0].Ready)
+	assert.True(ws.Versions[1].Ready)
+}
+
+func Test_CleanAllImagesStatus(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newCleanServer(t)
+	code, report := cleanAll(t, s, "")
+	assert.Equal(http.StatusOK, code)
+	assert.Zero(report.Failed)
+
+	s, _ = newCleanServer(t, "ws1-v1", "ws1-v2", "ws2-v1", "ws2-v2")
+	code, report = cleanAll(t, s, "")
+	assert.Equal(http.StatusInternalServerError, code)
+	assert.Equal(4, report.Failed)
+}
+
+func Test_CleanAllImagesDryRun(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner := newCleanServer(t, "ws1-v2")
+
+	code, report := cleanAll(t, s, "?dryRun=true")
+	assert.Equal(http.StatusOK, code)
+	assert.True(report.DryRun)
+	assert.Empty(cleaner.cleaned)
+	assert.Equal(int64(400), report.ReclaimableBytes)
+	for _, result := range report.Versions {
+		assert.Equal("planned", result.Status, result.VersionID)
+	}
+
+	ws, err := s.store.GetWorkspace("ws1")
+	assert.NoError(err)
+	assert.True(ws.Versions[0].Ready, "a dry run keeps the ready state")
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// Scopes of a CleanupResult, what a version leaves behind apart from its record
+const (
+	// cleanScopeRuntime is the simulator container and images of a version
+	cleanScopeRuntime = "runtime"
+	// cleanScopeFiles is the directory of a version, moved to the trash or removed
+	cleanScopeFiles = "files"
+	// cleanScopeCodeServer is the copy of a version in the project root of code-server
+	cleanScopeCodeServer = "codeServer"
+)
+
+// CleanupResult is what cleaning one scope of a version removed. Deleting a version or workspace and
+// cleaning an image each combine the scopes they need.
+type CleanupResult struct {
+	Scope   string   `json:"scope"` // "runtime", "files" or "codeServer"
+	Removed []string `json:"removed"`
+	// Plan lists the containers and images of the runtime scope, each with its own outcome
+	Plan  *docker.CleanPlan `json:"plan,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+// failedCleanups returns the results that carry an error
+func failedCleanups(results []CleanupResult) []CleanupResult {
+	var failed []CleanupResult
+	for _, result := range results {
+		if result.Error != "" {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// cleanRuntime cancels the queued start of an instance, then stops and removes its container and
+// images through the cleaner. Stop failures are reported like removal failures. With dryRun the plan
+// only lists what would be removed.
+func (s *Server) cleanRuntime(instanceName string, dryRun bool) CleanupResult {
+	result := CleanupResult{Scope: cleanScopeRuntime, Removed: []string{}}
+	if !dryRun {
+		s.starts.cancel(instanceName)
+	}
+	plan, err := s.cleaner.Clean(instanceName, dryRun)
+	if plan != nil {
+		result.Plan = plan
+		for _, items := range [][]docker.CleanItem{plan.Containers, plan.Images} {
+			for _, item := range items {
+				if item.Removed {
+					result.Removed = append(result.Removed, item.Name)
+				}
+			}
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if !dryRun {
+		s.clearProgress(instanceName)
+	}
+	return result
+}
+
+// cleanFiles moves the record and directory of a version into the trash, or removes them for good when
+// permanent is set. A failure leaves the version as it was.
+func (s *Server) cleanFiles(workspace string, version model.Version, permanent bool) CleanupResult {
+	result := CleanupResult{Scope: cleanScopeFiles, Removed: []string{}}
+	var err error
+	if permanent {
+		err = s.removeVersion(workspace, version.ID)
+	} else {
+		err = s.trashVersion(workspace, version)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	s.forgetAccess(version.InstanceName(workspace))
+	result.Removed = append(result.Removed, filepath.Join("workspaces", workspace, version.ID))
+	return result
+}
+
+// cleanCodeServer removes the project directory of a version from code-server. It fails while code-server
+// isn't running, the orphaned directory is then listed once it runs again.
+func (s *Server) cleanCodeServer(workspace string, version model.Version) CleanupResult {
+	result := CleanupResult{Scope: cleanScopeCodeServer, Removed: []string{}}
+	project := codeServerProjectOf(workspace, version)
+	if err := removeCodeServerProject(s.codeServer, project); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Removed = append(result.Removed, project)
+	return result
+}
+
+// logCleanupFailures logs the scopes a cleanup of instanceName failed to clean, the caller carries on
+func logCleanupFailures(instanceName string, results []CleanupResult) {
+	for _, result := range failedCleanups(results) {
+		fmt.Printf("Failed to clean %s of %s: %s\n", result.Scope, instanceName, result.Error)
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// newCleanupServer returns a files server with a runtime version v1 and a support bundle version v2,
+// its simulators and code-server are faked
+func newCleanupServer(t *testing.T, fail ...string) (*Server, *failingCleaner, *recordingExec) {
+	s, _ := newFilesServer(t)
+	_, err := s.createVersion("ws", "v1", writeKubeconfig)
+	require.NoError(t, err)
+	_, err = s.createVersion("ws", "v2", func(dir string) (*model.Version, error) {
+		return &model.Version{ID: "v2", Type: model.VersionTypeSupportBundle}, os.WriteFile(filepath.Join(dir, "bundle.txt"), []byte("logs"), 0644)
+	})
+	require.NoError(t, err)
+
+	cleaner := &failingCleaner{fail: map[string]bool{}}
+	for _, instance := range fail {
+		cleaner.fail[instance] = true
+	}
+	exec := &recordingExec{stderr: map[string]string{}}
+	s.cleaner = cleaner
+	s.codeServer = exec
+	s.events = events.NewBus()
+	return s, cleaner, exec
+}
+
+func getCleanupVersion(t *testing.T, s *Server, versionID string) model.Version {
+	ws, err := s.store.GetWorkspace("ws")
+	require.NoError(t, err)
+	version, ok := findVersion(ws, versionID)
+	require.True(t, ok)
+	return version
+}
+
+func Test_CleanRuntime(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner, _ := newCleanupServer(t, "ws-v3")
+
+	result := s.cleanRuntime("ws-v2", true)
+	assert.Equal(cleanScopeRuntime, result.Scope)
+	assert.Empty(result.Removed)
+	assert.Len(result.Plan.Images, 1)
+	assert.Empty(cleaner.cleaned, "a dry run removes nothing")
+
+	result = s.cleanRuntime("ws-v2", false)
+	assert.Equal([]string{"sim-cli-managed:ws-v2"}, result.Removed)
+	assert.Empty(result.Error)
+	assert.Equal([]string{"ws-v2"}, cleaner.cleaned)
+
+	result = s.cleanRuntime("ws-v3", false)
+	assert.Empty(result.Removed)
+	assert.Equal("error removing image sha256:ws-v3", result.Error)
+	assert.Equal("error removing image sha256:ws-v3", result.Plan.Images[0].Error)
+}
+
+func Test_CleanFiles(t *testing.T) {
+	assert := require.New(t)
+	s, _, _ := newCleanupServer(t)
+
+	result := s.cleanFiles("ws", getCleanupVersion(t, s, "v2"), false)
+	assert.Equal(CleanupResult{Scope: cleanScopeFiles, Removed: []string{filepath.Join("workspaces", "ws", "v2")}}, result)
+	assert.NoDirExists(filepath.Join(s.dataDir, "workspaces", "ws", "v2"))
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+
+	result = s.cleanFiles("ws", getCleanupVersion(t, s, "v1"), true)
+	assert.Empty(result.Error)
+	assert.NoDirExists(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	entries, err = s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1, "a permanent removal skips the trash")
+}
+
+func Test_CleanCodeServer(t *testing.T) {
+	assert := require.New(t)
+	s, _, exec := newCleanupServer(t)
+	s.trackCodeServerProject("ws", "v1", "ws-v1-copy")
+
+	result := s.cleanCodeServer("ws", getCleanupVersion(t, s, "v1"))
+	assert.Equal(CleanupResult{Scope: cleanScopeCodeServer, Removed: []string{"ws-v1-copy"}}, result)
+	assert.Equal([]string{"sim-cli-code-server: rm -rf /home/coder/project/ws-v1-copy"}, exec.commands)
+
+	exec.stderr["rm -rf /home/coder/project/ws-v2"] = "container is not running"
+	result = s.cleanCodeServer("ws", getCleanupVersion(t, s, "v2"))
+	assert.Empty(result.Removed)
+	assert.Contains(result.Error, "failed to remove code-server project ws-v2")
+}
+
+func Test_DeleteVersionCleansEveryScope(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner, exec := newCleanupServer(t, "ws-v2")
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /api/workspaces/{name}/versions/{versionID}", s.handleDeleteVersion)
+
+	// The runtime version has no simulator to remove
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/workspaces/ws/versions/v1?permanent=true", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	var results []CleanupResult
+	assert.NoError(json.NewDecoder(w.Body).Decode(&results))
+	assert.Len(results, 2)
+	assert.Equal(cleanScopeFiles, results[0].Scope)
+	assert.Equal(cleanScopeCodeServer, results[1].Scope)
+	assert.Empty(cleaner.cleaned)
+
+	// A simulator that failed to be removed is reported, the version is deleted anyway
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/workspaces/ws/versions/v2", nil))
+	assert.Equal(http.StatusMultiStatus, w.Code)
+	results = nil
+	assert.NoError(json.NewDecoder(w.Body).Decode(&results))
+	assert.Len(results, 3)
+	assert.Equal(cleanScopeRuntime, results[2].Scope)
+	assert.Equal("error removing image sha256:ws-v2", results[2].Error)
+	assert.Equal([]string{
+		"sim-cli-code-server: rm -rf /home/coder/project/ws-v1",
+		"sim-cli-code-server: rm -rf /home/coder/project/ws-v2",
+	}, exec.commands)
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Empty(ws.Versions)
+}
+
+func Test_CleanVersionRemovesCodeServerCopy(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner, exec := newCleanupServer(t)
+
+	result := s.cleanVersion("ws", "v2", true)
+	assert.Nil(result.CodeServer, "a dry run leaves code-server alone")
+	assert.Empty(exec.commands)
+
+	result = s.cleanVersion("ws", "v2", false)
+	assert.Equal(cleanStatusCleaned, result.Status)
+	assert.Equal([]string{"ws-v2"}, cleaner.cleaned)
+	assert.Equal(&CleanupResult{Scope: cleanScopeCodeServer, Removed: []string{"ws-v2"}}, result.CodeServer)
+
+	// code-server not running doesn't fail the clean
+	exec.stderr["rm -rf /home/coder/project/ws-v2"] = "container is not running"
+	result = s.cleanVersion("ws", "v2", false)
+	assert.Equal(cleanStatusCleaned, result.Status)
+	assert.NotEmpty(result.CodeServer.Error)
+}
+
+func Test_DeleteWorkspaceCleansEveryVersion(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner, exec := newCleanupServer(t)
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+
+	assert.NoError(s.deleteWorkspace(context.Background(), *ws, true, nil))
+	assert.Equal([]string{"ws-v2"}, cleaner.cleaned, "runtime versions have no simulator")
+	assert.ElementsMatch([]string{
+		"sim-cli-code-server: rm -rf /home/coder/project/ws-v1",
+		"sim-cli-code-server: rm -rf /home/coder/project/ws-v2",
+	}, exec.commands)
+	_, err = s.store.GetWorkspace("ws")
+	assert.Error(err)
+	assert.NoDirExists(filepath.Join(s.dataDir, "workspaces", "ws"))
+}
@@ -7,11 +7,19 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
 )
 
-func recursiveExtract(root string) error {
+// recursiveExtract extracts the archives under root until none are left, the nested archives of a
+// bundle included. Their files are counted together against limits before they are written, and
+// compressed tars fail once they expand beyond the ratio limit.
+func recursiveExtract(root string, limits utils.ExtractLimits) error {
+	counter := utils.ExtractCounter{Limits: limits}
 	for {
 		var archives []string
 		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
@@ -50,155 +58,208 @@ func recursiveExtract(root string) error {
 
 		for _, archive := range archives {
 			dir := filepath.Dir(archive)
-			name := filepath.Base(archive)
-			var cmd *exec.Cmd
-
-			if strings.HasSuffix(name, ".zip") {
-				cmd = exec.Command("unzip", "-q", "-o", archive, "-d", dir, "-x", "__MACOSX/*", "*/__MACOSX/*", "._*")
-			} else if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
-				cmd = exec.Command("tar", "--exclude=__MACOSX", "--exclude=._*", "-xzf", archive, "-C", dir)
-			} else if strings.HasSuffix(name, ".tar.xz") || strings.HasSuffix(name, ".txz") {
-				cmd = exec.Command("tar", "--exclude=__MACOSX", "--exclude=._*", "-xJf", archive, "-C", dir)
-			} else if strings.HasSuffix(name, ".tar") {
-				cmd = exec.Command("tar", "--exclude=__MACOSX", "--exclude=._*", "-xf", archive, "-C", dir)
+			rel, err := filepath.Rel(root, archive)
+			if err != nil {
+				return err
 			}
 
-			if cmd != nil {
-				if output, err := cmd.CombinedOutput(); err != nil {
-					return fmt.Errorf("failed to extract %s: %v, output: %s", archive, err, string(output))
+			// A zip is checked by its headers before anything of it is written
+			if strings.HasSuffix(archive, ".zip") {
+				err = utils.CheckZip(archive, counter)
+				if err == nil {
+					err = utils.UnzipNested(archive, dir, &counter)
 				}
+			} else {
+				err = utils.UntarNested(archive, dir, &counter)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", rel, err)
+			}
 
-				// Fix permissions after extraction to ensure we can walk/remove
-				// Some archives might contain read-only directories which causes filepath.Walk to fail
-				exec.Command("chmod", "-R", "755", dir).Run()
-
-				if err := os.Remove(archive); err != nil {
-					return fmt.Errorf("failed to remove %s: %v", archive, err)
-				}
+			if err := os.Remove(archive); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", archive, err)
 			}
 		}
 	}
 	return nil
 }
 
+// codeServerRequest is the optional body of a code-server start
+type codeServerRequest struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
 func (s *Server) handleStartCodeServer(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 	versionID := r.PathValue("versionID")
 
-	// Find bundle file
-	versionPath := filepath.Join(s.dataDir, "workspaces", name, versionID)
-	entries, err := os.ReadDir(versionPath)
+	ws, err := s.store.GetWorkspace(name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-
-	var bundlePath string
-	for _, entry := range entries {
-		if !entry.IsDir() && entry.Name() != "extracted" {
-			bundlePath = filepath.Join(versionPath, entry.Name())
-			break
-		}
-	}
-
-	if bundlePath == "" {
-		http.Error(w, "Bundle file not found", http.StatusNotFound)
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
 		return
 	}
 
-	instanceName := "sim-cli-code-server"
-
-	url, _, err := s.docker.RunCodeServer(instanceName)
+	// Bundles uploaded extracted are copied as they are, others are extracted from their archive
+	bundlePath, err := s.bundleSource(r.Context(), name, version)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to fetch support bundle: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Check if directory already exists in container
-	targetDir := fmt.Sprintf("/home/coder/project/%s-%s", name, versionID)
-	if _, _, err := s.docker.ExecContainer(instanceName, []string{"test", "-d", targetDir}, nil); err == nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"url": url,
-		})
+	if bundlePath == "" {
+		http.Error(w, "Bundle file not found", http.StatusNotFound)
 		return
 	}
-
-	// Prepare temp directory for extraction
-	tempRoot, err := os.MkdirTemp("", "sim-cli-extract")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := os.Stat(bundlePath); err != nil {
+		http.Error(w, fmt.Sprintf("Bundle file not found: %v", err), http.StatusNotFound)
 		return
 	}
-	defer os.RemoveAll(tempRoot)
 
-	extractDirName := fmt.Sprintf("%s-%s", name, versionID)
-	extractDirPath := filepath.Join(tempRoot, extractDirName)
-	if err := os.Mkdir(extractDirPath, 0755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// The container is shared by every version, the labels and environment only apply when it is created
+	var req codeServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	// Copy bundle to temp dir
-	srcFile, err := os.Open(bundlePath)
+	extras, err := containerExtras(ws, req.Labels, req.Env)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer srcFile.Close()
 
-	destBundlePath := filepath.Join(extractDirPath, filepath.Base(bundlePath))
-	destFile, err := os.Create(destBundlePath)
+	instanceName := codeServerInstance
+
+	port, _, err := s.docker.RunCodeServer(instanceName, extras)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// We close explicitly later, but defer just in case
-	defer destFile.Close()
+	// The cached port is stale when the container was started again
+	s.codeServerProxy.forget(instanceName, "")
+	urls := map[string]string{
+		"url":       s.serverURL(r).String() + codeServerProxyPath + instanceName + "/",
+		"directUrl": s.codeServerURL(r, port),
+	}
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Versions deleted while code-server wasn't running left their directories behind
+	if removed, err := s.purgeCodeServerProjects(s.docker, false); err != nil {
+		fmt.Printf("Failed to remove orphaned code-server projects: %v\n", err)
+	} else if len(removed) > 0 {
+		fmt.Printf("Removed orphaned code-server projects: %s\n", strings.Join(removed, ", "))
 	}
-	destFile.Close()
 
-	// Recursive extract
-	if err := recursiveExtract(extractDirPath); err != nil {
-		http.Error(w, fmt.Sprintf("Extraction failed: %v", err), http.StatusInternalServerError)
+	// Check if directory already exists in container
+	projectName := s.namespaced(fmt.Sprintf("%s-%s", name, versionID))
+	targetDir := path.Join(codeServerProjectRoot, projectName)
+	if _, _, err := s.docker.ExecContainerOutput(instanceName, []string{"test", "-d", targetDir}, nil); err == nil {
+		s.trackCodeServerProject(name, versionID, projectName)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(urls)
 		return
 	}
 
-	// Fix permissions on host before copying
-	// We use chmod -R 755 to ensure directories are accessible and files are readable
-	cmdChmod := exec.Command("chmod", "-R", "755", tempRoot)
-	if output, err := cmdChmod.CombinedOutput(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to chmod extracted files: %v, output: %s", err, string(output)), http.StatusInternalServerError)
-		return
+	if version.ExtractedOnly {
+		if err := s.copyToCodeServer(instanceName, bundlePath, targetDir); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// Prepare temp directory for extraction
+		tempRoot, err := os.MkdirTemp("", "sim-cli-extract")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(tempRoot)
+
+		extractDirPath := filepath.Join(tempRoot, projectName)
+		if err := os.Mkdir(extractDirPath, 0755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Copy bundle to temp dir
+		srcFile, err := os.Open(bundlePath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer srcFile.Close()
+
+		destBundlePath := filepath.Join(extractDirPath, filepath.Base(bundlePath))
+		destFile, err := os.Create(destBundlePath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// We close explicitly later, but defer just in case
+		defer destFile.Close()
+
+		if _, err := io.Copy(destFile, srcFile); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		destFile.Close()
+
+		// Recursive extract
+		if err := recursiveExtract(extractDirPath, s.uploadLimits.Extract); err != nil {
+			http.Error(w, fmt.Sprintf("Extraction failed: %v", err), extractLimitStatus(err, http.StatusInternalServerError))
+			return
+		}
+
+		// Fix permissions on host before copying
+		// We use chmod -R 755 to ensure directories are accessible and files are readable
+		cmdChmod := exec.Command("chmod", "-R", "755", tempRoot)
+		if output, err := cmdChmod.CombinedOutput(); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to chmod extracted files: %v, output: %s", err, string(output)), http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.copyToCodeServer(instanceName, extractDirPath, codeServerProjectRoot+"/"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
+	s.trackCodeServerProject(name, versionID, projectName)
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(urls)
+}
+
+// copyToCodeServer copies src on the host to dest in the code-server container, a dest that doesn't
+// exist yet receives the contents of src
+func (s *Server) copyToCodeServer(instanceName, src, dest string) error {
 	// Ensure parent directory exists in container
-	_, _, err = s.docker.ExecContainer(instanceName, []string{"mkdir", "-p", "/home/coder/project"}, nil)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create directory: %v", err), http.StatusInternalServerError)
-		return
+	if _, _, err := s.docker.ExecContainerOutput(instanceName, []string{"mkdir", "-p", codeServerProjectRoot}, nil); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
 	}
 
 	// Copy extracted directory to container
-	cmdCp := exec.Command("docker", "cp", extractDirPath, fmt.Sprintf("%s:/home/coder/project/", instanceName))
+	cmdCp := exec.Command("docker", "cp", src, fmt.Sprintf("%s:%s", instanceName, dest))
 	if output, err := cmdCp.CombinedOutput(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to copy files via docker cp: %v, output: %s", err, string(output)), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to copy files via docker cp: %v, output: %s", err, string(output))
 	}
 
 	// Fix permissions
-	_, _, err = s.docker.ExecContainer(instanceName, []string{"sudo", "chown", "coder:coder", "-R", "/home/coder/project"}, nil)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fix permissions: %v", err), http.StatusInternalServerError)
-		return
+	if _, _, err := s.docker.ExecContainerOutput(instanceName, []string{"sudo", "chown", "coder:coder", "-R", codeServerProjectRoot}, nil); err != nil {
+		return fmt.Errorf("failed to fix permissions: %v", err)
 	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"url": url,
+// trackCodeServerProject records the project directory of a version, so it is removed once the version is gone
+func (s *Server) trackCodeServerProject(workspaceName, versionID, projectName string) {
+	err := updateVersion(s.store, workspaceName, versionID, func(v *model.Version) bool {
+		changed := v.CodeServerProject != projectName
+		v.CodeServerProject = projectName
+		return changed
 	})
+	if err != nil {
+		fmt.Printf("Failed to track code-server project %s: %v\n", projectName, err)
+	}
 }
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+const (
+	// codeServerInstance is the code-server container shared by every version
+	codeServerInstance = "sim-cli-code-server"
+	// codeServerProjectRoot holds a directory per version opened in code-server
+	codeServerProjectRoot = "/home/coder/project"
+)
+
+// errCodeServerNotRunning is returned when the project directories are managed without a running code-server
+var errCodeServerNotRunning = errors.New("code-server isn't running")
+
+// containerExec is the part of the docker client the code-server project directories need
+type containerExec interface {
+	ExecContainerOutput(containerName string, command []string, env []string) (string, string, error)
+}
+
+// CodeServerProject is a directory in the project root of the code-server container
+type CodeServerProject struct {
+	Name      string `json:"name"`
+	Workspace string `json:"workspace,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	// Orphaned directories belong to no version, e.g. their version was deleted while code-server wasn't running
+	Orphaned bool `json:"orphaned"`
+}
+
+// codeServerProjectOf returns the project directory of a version, versions opened before directories
+// were tracked use the name they were copied with
+func codeServerProjectOf(workspaceName string, version model.Version) string {
+	if version.CodeServerProject != "" {
+		return version.CodeServerProject
+	}
+	return fmt.Sprintf("%s-%s", workspaceName, version.ID)
+}
+
+// listCodeServerProjects lists the directories in the project root of the code-server container
+func listCodeServerProjects(exec containerExec) ([]string, error) {
+	stdout, _, err := exec.ExecContainerOutput(codeServerInstance, []string{"ls", "-1", codeServerProjectRoot}, nil)
+	if err != nil {
+		// The root is created along with the first project
+		var exitErr *docker.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(exitErr.Stderr, "No such file or directory") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list code-server projects: %w", err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// removeCodeServerProject removes a directory from the project root of the code-server container
+func removeCodeServerProject(exec containerExec, name string) error {
+	// Names come from the store and ls, anything but a plain name would escape the project root
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid code-server project %q", name)
+	}
+	if _, _, err := exec.ExecContainerOutput(codeServerInstance, []string{"rm", "-rf", path.Join(codeServerProjectRoot, name)}, nil); err != nil {
+		return fmt.Errorf("failed to remove code-server project %s: %w", name, err)
+	}
+	return nil
+}
+
+// codeServerProjects lists the project directories with the versions they belong to. The container is
+// shared by the profiles of the server, the directories of other profiles are left out.
+func (s *Server) codeServerProjects(exec containerExec) ([]CodeServerProject, error) {
+	names, err := listCodeServerProjects(exec)
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := s.store.ListWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]CodeServerProject)
+	for _, ws := range workspaces {
+		for _, v := range ws.Versions {
+			name := codeServerProjectOf(ws.Name, v)
+			owners[name] = CodeServerProject{Name: name, Workspace: ws.Name, VersionID: v.ID}
+		}
+	}
+
+	projects := make([]CodeServerProject, 0, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, s.namespaced("")) {
+			continue
+		}
+		project, ok := owners[name]
+		if !ok {
+			project = CodeServerProject{Name: name, Orphaned: true}
+		}
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// purgeCodeServerProjects removes the orphaned project directories, or every directory with all. Versions
+// whose directory was removed are copied into code-server again when they are next opened. The removed
+// directories are returned along with the failures.
+func (s *Server) purgeCodeServerProjects(exec containerExec, all bool) ([]string, error) {
+	projects, err := s.codeServerProjects(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	var errs []error
+	for _, project := range projects {
+		if !project.Orphaned && !all {
+			continue
+		}
+		if err := removeCodeServerProject(exec, project.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, project.Name)
+
+		if project.Orphaned {
+			continue
+		}
+		err := updateVersion(s.store, project.Workspace, project.VersionID, func(v *model.Version) bool {
+			changed := v.CodeServerProject != ""
+			v.CodeServerProject = ""
+			return changed
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// codeServerRunning reports whether the code-server container runs, its directories can't be managed otherwise
+func (s *Server) codeServerRunning() (bool, error) {
+	containers, err := s.docker.FindRunningContainer(codeServerInstance)
+	if err != nil {
+		return false, err
+	}
+	return len(containers) > 0, nil
+}
+
+// sweepCodeServerProjects removes the orphaned project directories left by versions deleted while
+// code-server wasn't running, they are removed by a later sweep when it still isn't
+func (s *Server) sweepCodeServerProjects() {
+	running, err := s.codeServerRunning()
+	if err != nil {
+		fmt.Printf("Code-server project sweep failed to find the container: %v\n", err)
+		return
+	}
+	if !running {
+		return
+	}
+	removed, err := s.purgeCodeServerProjects(s.docker, false)
+	if len(removed) > 0 {
+		fmt.Printf("Removed orphaned code-server projects: %s\n", strings.Join(removed, ", "))
+	}
+	if err != nil {
+		fmt.Printf("Code-server project sweep failed: %v\n", err)
+	}
+}
+
+// handleListCodeServerProjects lists the project directories of the code-server container
+func (s *Server) handleListCodeServerProjects(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCodeServer(w) {
+		return
+	}
+	projects, err := s.codeServerProjects(s.docker)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+// handlePurgeCodeServerProjects removes the orphaned project directories of the code-server container,
+// or every directory with all=true
+func (s *Server) handlePurgeCodeServerProjects(w http.ResponseWriter, r *http.Request) {
+	if !s.requireCodeServer(w) {
+		return
+	}
+	removed, err := s.purgeCodeServerProjects(s.docker, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Removed %d code-server projects before failing: %v", len(removed), err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"removed": removed,
+	})
+}
+
+// requireCodeServer writes a conflict and returns false when the code-server container isn't running
+func (s *Server) requireCodeServer(w http.ResponseWriter) bool {
+	running, err := s.codeServerRunning()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !running {
+		http.Error(w, errCodeServerNotRunning.Error(), http.StatusConflict)
+		return false
+	}
+	return true
+}
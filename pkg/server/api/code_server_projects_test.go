@@ -0,0 +1,93 @@
+package api
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExec records the commands run in containers, failing the ones in stderr with their output
+type recordingExec struct {
+	lock     sync.Mutex
+	commands []string
+	stdout   map[string]string
+	stderr   map[string]string
+}
+
+func (e *recordingExec) ExecContainerOutput(containerName string, command []string, env []string) (string, string, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	key := strings.Join(command, " ")
+	e.commands = append(e.commands, containerName+": "+key)
+	if stderr, ok := e.stderr[key]; ok {
+		return "", stderr, &docker.ExitError{ExitCode: 1, Stderr: stderr}
+	}
+	return e.stdout[key], "", nil
+}
+
+func Test_PurgeCodeServerProjects(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	_, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+	s.trackCodeServerProject("ws", "v1", "ws-v1")
+
+	exec := &recordingExec{
+		stdout: map[string]string{"ls -1 /home/coder/project": "gone-v1\nws-v1\nws-v2\n"},
+		stderr: map[string]string{"rm -rf /home/coder/project/ws-v2": "rm: cannot remove '/home/coder/project/ws-v2': Permission denied"},
+	}
+	projects, err := s.codeServerProjects(exec)
+	assert.NoError(err)
+	assert.Equal([]CodeServerProject{
+		{Name: "gone-v1", Orphaned: true},
+		{Name: "ws-v1", Workspace: "ws", VersionID: "v1"},
+		{Name: "ws-v2", Orphaned: true},
+	}, projects)
+
+	exec.commands = nil
+	removed, err := s.purgeCodeServerProjects(exec, false)
+	assert.ErrorContains(err, "Permission denied", "failed removals are reported")
+	assert.Equal([]string{"gone-v1"}, removed)
+	assert.Equal([]string{
+		"sim-cli-code-server: ls -1 /home/coder/project",
+		"sim-cli-code-server: rm -rf /home/coder/project/gone-v1",
+		"sim-cli-code-server: rm -rf /home/coder/project/ws-v2",
+	}, exec.commands, "the project of v1 is kept")
+
+	// all removes the projects of versions too, they are copied again when next opened
+	exec.stdout["ls -1 /home/coder/project"] = "ws-v1\n"
+	removed, err = s.purgeCodeServerProjects(exec, true)
+	assert.NoError(err)
+	assert.Equal([]string{"ws-v1"}, removed)
+	version, _ := findVersionIn(t, s, "ws", "v1")
+	assert.Empty(version.CodeServerProject)
+}
+
+func Test_ListCodeServerProjectsErrors(t *testing.T) {
+	assert := require.New(t)
+
+	// the root doesn't exist before the first project is copied
+	exec := &recordingExec{stderr: map[string]string{"ls -1 /home/coder/project": "ls: cannot access '/home/coder/project': No such file or directory"}}
+	names, err := listCodeServerProjects(exec)
+	assert.NoError(err)
+	assert.Empty(names)
+
+	exec = &recordingExec{stderr: map[string]string{"ls -1 /home/coder/project": "Error response from daemon: container is not running"}}
+	_, err = listCodeServerProjects(exec)
+	assert.ErrorContains(err, "container is not running")
+
+	for _, name := range []string{"", ".", "..", "ws/../.."} {
+		assert.Error(removeCodeServerProject(exec, name), name)
+	}
+	assert.Len(exec.commands, 1, "invalid names aren't removed")
+}
+
+func Test_CodeServerProjectOf(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal("ws-v1", codeServerProjectOf("ws", model.Version{ID: "v1"}), "untracked projects use the name they were copied with")
+	assert.Equal("copied", codeServerProjectOf("ws", model.Version{ID: "v1", CodeServerProject: "copied"}))
+}
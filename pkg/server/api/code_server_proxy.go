@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+)
+
+const (
+	// codeServerProxyPath is where code-server is reverse proxied under the base path, followed by the
+	// instance name
+	codeServerProxyPath = "/code-server/"
+	// codeServerPort is the port code-server listens on in its container
+	codeServerPort = "8080/tcp"
+	// codeServerTokenCookie keeps the token of the first proxied request for the ones the page makes
+	codeServerTokenCookie = "sim-gui-code-server-token"
+)
+
+// codeServerProxyRoute authorizes the proxy for admins only. The container holds the projects of every
+// workspace, an editor reaching it would read and change the workspaces it doesn't own.
+var codeServerProxyRoute = route{Path: codeServerProxyPath + "{instance}/", Class: classAdmin}
+
+// publishedPortFinder is the part of the docker client telling where a container port is published
+type publishedPortFinder interface {
+	PublishedPort(instanceName string, port string) (string, error)
+}
+
+// codeServerProxy forwards HTTP and WebSocket requests to the port code-server is published on. The
+// port is cached per instance and looked up again once connecting to it fails, code-server is
+// published on a random port that changes when its container restarts.
+type codeServerProxy struct {
+	finder publishedPortFinder
+	// host is where docker publishes ports, the server runs next to the docker daemon
+	host      string
+	transport http.RoundTripper
+
+	lock  sync.Mutex
+	ports map[string]string
+}
+
+func newCodeServerProxy(finder publishedPortFinder) *codeServerProxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// The docker host is never reached through a proxy
+	transport.Proxy = nil
+	return &codeServerProxy{
+		finder:    finder,
+		host:      "127.0.0.1",
+		transport: transport,
+		ports:     make(map[string]string),
+	}
+}
+
+// port returns the published port of an instance, from the cache when it was looked up before
+func (p *codeServerProxy) port(instance string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if port, ok := p.ports[instance]; ok {
+		return port, nil
+	}
+	port, err := p.finder.PublishedPort(instance, codeServerPort)
+	if err != nil {
+		return "", err
+	}
+	p.ports[instance] = port
+	return port, nil
+}
+
+// forget drops the cached port of an instance, unless another request looked up a new one meanwhile.
+// An empty port drops any port, e.g. when the container was just started.
+func (p *codeServerProxy) forget(instance, port string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if port == "" || p.ports[instance] == port {
+		delete(p.ports, instance)
+	}
+}
+
+// codeServerTransport sends proxied requests to the docker host. A connection refused on the cached
+// port drops it, requests without a body are retried once on the port looked up again.
+type codeServerTransport struct {
+	proxy    *codeServerProxy
+	instance string
+}
+
+func (t *codeServerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	port := req.URL.Port()
+	resp, err := t.proxy.transport.RoundTrip(req)
+	var opErr *net.OpError
+	if err == nil || !errors.As(err, &opErr) || opErr.Op != "dial" {
+		return resp, err
+	}
+
+	t.proxy.forget(t.instance, port)
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil, err
+	}
+	fresh, lookupErr := t.proxy.port(t.instance)
+	if lookupErr != nil || fresh == port {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	retry.URL.Host = net.JoinHostPort(t.proxy.host, fresh)
+	return t.proxy.transport.RoundTrip(retry)
+}
+
+// serve forwards a request under prefix to the root of an instance. The host of the request is kept
+// and the external URL is forwarded, code-server compares its host to the origin of WebSocket requests.
+func (p *codeServerProxy) serve(w http.ResponseWriter, r *http.Request, instance, prefix string, external *url.URL) {
+	port, err := p.port(instance)
+	if errors.Is(err, docker.ErrContainerNotRunning) {
+		http.Error(w, "code-server isn't running, start it from a version first", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to find code-server: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = net.JoinHostPort(p.host, port)
+			pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, prefix)
+			pr.Out.URL.RawPath = strings.TrimPrefix(pr.In.URL.RawPath, prefix)
+			if query := pr.In.URL.Query(); query.Has("access_token") {
+				query.Del("access_token")
+				pr.Out.URL.RawQuery = query.Encode()
+			}
+
+			// The token of sim-gui isn't passed on
+			pr.Out.Header.Del("Authorization")
+			removeCookie(pr.Out.Header, codeServerTokenCookie)
+
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Host", external.Host)
+			pr.Out.Header.Set("X-Forwarded-Proto", external.Scheme)
+			pr.Out.Header.Set("X-Forwarded-Prefix", external.Path+codeServerProxyPath+instance)
+		},
+		Transport: &codeServerTransport{proxy: p, instance: instance},
+		// Downloads and the event streams of the editor are passed on as they arrive
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to reach code-server: %v", err), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// removeCookie removes a cookie from the Cookie headers of a request
+func removeCookie(header http.Header, name string) {
+	var kept []string
+	for _, line := range header.Values("Cookie") {
+		for _, cookie := range strings.Split(line, ";") {
+			cookie = strings.TrimSpace(cookie)
+			if cookieName, _, _ := strings.Cut(cookie, "="); cookie != "" && cookieName != name {
+				kept = append(kept, cookie)
+			}
+		}
+	}
+	header.Del("Cookie")
+	if len(kept) > 0 {
+		header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
+
+// codeServerToken lets the pages of code-server authenticate. Browsers only send the token with the
+// first request, in access_token, so it is kept in a cookie scoped to the proxy path for the requests
+// the page makes afterwards.
+func (s *Server) codeServerToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens != nil && requestToken(r) == "" {
+			if cookie, err := r.Cookie(codeServerTokenCookie); err == nil {
+				if token, err := url.QueryUnescape(cookie.Value); err == nil {
+					r.Header.Set("Authorization", "Bearer "+token)
+				}
+			}
+		}
+		next(w, r)
+	}
+}
+
+// registerCodeServerProxy registers the proxy of code-server, it isn't an API route and every method
+// is proxied
+func (s *Server) registerCodeServerProxy(mux *http.ServeMux) {
+	mux.HandleFunc(s.basePath+s.profilePath()+codeServerProxyRoute.Path, s.codeServerToken(s.authorize(codeServerProxyRoute, s.handleCodeServerProxy)))
+}
+
+// handleCodeServerProxy reverse proxies the requests under /code-server/{instance}/ to code-server,
+// so it is reached on the port of the server and can be embedded by the UI on the same origin
+func (s *Server) handleCodeServerProxy(w http.ResponseWriter, r *http.Request) {
+	instance := r.PathValue("instance")
+	if instance != codeServerInstance {
+		http.NotFound(w, r)
+		return
+	}
+	external := s.serverURL(r)
+	prefix := s.basePath + s.profilePath() + codeServerProxyPath + instance
+
+	// Authorized by access_token, the token is kept for the requests of the page
+	if token := r.URL.Query().Get("access_token"); s.tokens != nil && token != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     codeServerTokenCookie,
+			Value:    url.QueryEscape(token),
+			Path:     prefix + "/",
+			HttpOnly: true,
+			Secure:   external.Scheme == "https",
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	s.codeServerProxy.serve(w, r, instance, prefix, external)
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/stretchr/testify/require"
+)
+
+// stubPortFinder publishes code-server on port, counting the lookups
+type stubPortFinder struct {
+	lock    sync.Mutex
+	port    string
+	err     error
+	lookups int
+}
+
+func (f *stubPortFinder) PublishedPort(instanceName string, port string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.lookups++
+	return f.port, f.err
+}
+
+func (f *stubPortFinder) publish(port string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.port = port
+}
+
+// startUpstream starts a stub code-server and returns it with its port
+func startUpstream(t *testing.T, handler http.HandlerFunc) (*httptest.Server, string) {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+	parsed, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	return upstream, parsed.Port()
+}
+
+// startProxy serves the code-server proxy of a server under basePath
+func startProxy(t *testing.T, s *Server, finder publishedPortFinder) *httptest.Server {
+	s.codeServerProxy = newCodeServerProxy(finder)
+	mux := http.NewServeMux()
+	s.registerCodeServerProxy(mux)
+	proxy := httptest.NewServer(mux)
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+// proxiedRequest is what the stub code-server received
+type proxiedRequest struct {
+	Path    string      `json:"path"`
+	Query   string      `json:"query"`
+	Host    string      `json:"host"`
+	Headers http.Header `json:"headers"`
+}
+
+// query parses the query received, proxies may escape it again
+func (p proxiedRequest) query() url.Values {
+	query, _ := url.ParseQuery(p.Query)
+	return query
+}
+
+func echoRequest(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(proxiedRequest{Path: r.URL.EscapedPath(), Query: r.URL.RawQuery, Host: r.Host, Headers: r.Header})
+}
+
+// noRedirects doesn't follow redirects, they are checked themselves
+var noRedirects = &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+func getProxied(t *testing.T, r *http.Request) (*http.Response, proxiedRequest) {
+	resp, err := noRedirects.Do(r)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var received proxiedRequest
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&received))
+	}
+	return resp, received
+}
+
+func Test_CodeServerProxy(t *testing.T) {
+	assert := require.New(t)
+	_, port := startUpstream(t, echoRequest)
+	finder := &stubPortFinder{port: port}
+	proxy := startProxy(t, &Server{basePath: "/gui", trustForwarded: true}, finder)
+	proxyHost := strings.TrimPrefix(proxy.URL, "http://")
+
+	r, _ := http.NewRequest("GET", proxy.URL+"/gui/code-server/sim-cli-code-server/static/out/a%2Fb.js?folder=/home/coder/project/ws-v1", nil)
+	r.Header.Set("Cookie", "vscode-tkn=abc")
+	resp, received := getProxied(t, r)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("/static/out/a%2Fb.js", received.Path, "the prefix is removed, escapes are kept")
+	assert.Equal(url.Values{"folder": {"/home/coder/project/ws-v1"}}, received.query())
+	assert.Equal(proxyHost, received.Host)
+	assert.Equal(proxyHost, received.Headers.Get("X-Forwarded-Host"))
+	assert.Equal("http", received.Headers.Get("X-Forwarded-Proto"))
+	assert.Equal("/gui/code-server/sim-cli-code-server", received.Headers.Get("X-Forwarded-Prefix"))
+	assert.Equal("vscode-tkn=abc", received.Headers.Get("Cookie"))
+
+	// Behind a trusted proxy code-server is told the host the browser reached
+	r, _ = http.NewRequest("GET", proxy.URL+"/gui/code-server/sim-cli-code-server/", nil)
+	r.Header.Set("X-Forwarded-Host", "sim.example.com")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	resp, received = getProxied(t, r)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("/", received.Path)
+	assert.Equal("sim.example.com", received.Headers.Get("X-Forwarded-Host"))
+	assert.Equal("https", received.Headers.Get("X-Forwarded-Proto"))
+	assert.Equal(1, finder.lookups, "the port is cached")
+
+	r, _ = http.NewRequest("GET", proxy.URL+"/gui/code-server/sim-cli-code-server", nil)
+	resp, _ = getProxied(t, r)
+	assert.Equal("/gui/code-server/sim-cli-code-server/", resp.Header.Get("Location"), "redirected to the root")
+
+	r, _ = http.NewRequest("GET", proxy.URL+"/gui/code-server/ws-v1/", nil)
+	resp, _ = getProxied(t, r)
+	assert.Equal(http.StatusNotFound, resp.StatusCode, "only code-server is proxied")
+
+	// Stopped
+	finder.err = docker.ErrContainerNotRunning
+	s := &Server{trustForwarded: true}
+	stopped := startProxy(t, s, finder)
+	r, _ = http.NewRequest("GET", stopped.URL+"/code-server/sim-cli-code-server/", nil)
+	resp, _ = getProxied(t, r)
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func Test_CodeServerProxyWebSocket(t *testing.T) {
+	assert := require.New(t)
+	_, port := startUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.URL.Path != "/stable/socket" {
+			http.Error(w, "not an upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		// Echoes the frames until the client closes the connection
+		io.Copy(conn, rw)
+	})
+	proxy := startProxy(t, &Server{trustForwarded: true}, &stubPortFinder{port: port})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL, "http://"))
+	assert.NoError(err)
+	defer conn.Close()
+	r, _ := http.NewRequest("GET", proxy.URL+"/code-server/sim-cli-code-server/stable/socket", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	assert.NoError(r.Write(conn))
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, r)
+	assert.NoError(err)
+	assert.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal("websocket", resp.Header.Get("Upgrade"))
+
+	for _, frame := range []string{"ping", "pong"} {
+		_, err = conn.Write([]byte(frame))
+		assert.NoError(err)
+		echoed := make([]byte, len(frame))
+		_, err = io.ReadFull(reader, echoed)
+		assert.NoError(err)
+		assert.Equal(frame, string(echoed))
+	}
+}
+
+func Test_CodeServerProxyStreamsDownloads(t *testing.T) {
+	assert := require.New(t)
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<20)
+	release := make(chan struct{})
+	_, port := startUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="bundle.tar"`)
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("rest"))
+	})
+	_, largePort := startUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(large)
+	})
+	finder := &stubPortFinder{port: port}
+	proxy := startProxy(t, &Server{trustForwarded: true}, finder)
+
+	// The first bytes arrive before the download is complete
+	resp, err := http.Get(proxy.URL + "/code-server/sim-cli-code-server/download")
+	assert.NoError(err)
+	first := make([]byte, 5)
+	_, err = io.ReadFull(resp.Body, first)
+	close(release)
+	assert.NoError(err)
+	assert.Equal("first", string(first))
+	rest, err := io.ReadAll(resp.Body)
+	assert.NoError(err)
+	assert.Equal("rest", string(rest))
+	resp.Body.Close()
+
+	finder.publish(largePort)
+	s := &Server{trustForwarded: true}
+	proxy = startProxy(t, s, finder)
+	resp, err = http.Get(proxy.URL + "/code-server/sim-cli-code-server/download")
+	assert.NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(err)
+	assert.True(bytes.Equal(large, body), "got %d of %d bytes", len(body), len(large))
+}
+
+func Test_CodeServerProxyContainerRestarted(t *testing.T) {
+	assert := require.New(t)
+	first, port := startUpstream(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("first")) })
+	finder := &stubPortFinder{port: port}
+	proxy := startProxy(t, &Server{trustForwarded: true}, finder)
+	get := func(method string, body io.Reader) (int, string) {
+		r, _ := http.NewRequest(method, proxy.URL+"/code-server/sim-cli-code-server/", body)
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(err)
+		defer resp.Body.Close()
+		read, err := io.ReadAll(resp.Body)
+		assert.NoError(err)
+		return resp.StatusCode, string(read)
+	}
+
+	code, body := get("GET", nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("first", body)
+
+	// Restarted on another port, the cached one refuses the connection and is looked up again
+	first.Close()
+	second, port := startUpstream(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("second")) })
+	finder.publish(port)
+	code, body = get("GET", nil)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("second", body)
+	assert.Equal(2, finder.lookups)
+
+	// Requests with a body can't be sent again, the next one reaches the new port
+	second.Close()
+	_, port = startUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		read, _ := io.ReadAll(r.Body)
+		w.Write(read)
+	})
+	finder.publish(port)
+	code, _ = get("POST", strings.NewReader("third"))
+	assert.Equal(http.StatusBadGateway, code)
+	code, body = get("POST", strings.NewReader("third"))
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("third", body)
+}
+
+func Test_CodeServerProxyTokens(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), auth.FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [
+		{"token": "admin-token", "user": "alice", "role": "admin"},
+		{"token": "editor-token", "user": "eve", "role": "editor"},
+		{"token": "viewer-token", "user": "victor", "role": "viewer"}
+	]}`), 0600))
+	tokens, err := auth.Load(path)
+	assert.NoError(err)
+
+	_, port := startUpstream(t, echoRequest)
+	s := &Server{trustForwarded: true}
+	s.SetTokens(tokens)
+	proxy := startProxy(t, s, &stubPortFinder{port: port})
+	request := func(query, authorization, cookie string) (*http.Response, proxiedRequest) {
+		r, _ := http.NewRequest("GET", proxy.URL+"/code-server/sim-cli-code-server/"+query, nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", "Bearer "+authorization)
+		}
+		if cookie != "" {
+			r.Header.Set("Cookie", cookie)
+		}
+		return getProxied(t, r)
+	}
+
+	resp, _ := request("", "", "")
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = request("", "viewer-token", "")
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	// the container holds the projects of workspaces the editor doesn't own
+	resp, _ = request("", "editor-token", "")
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+
+	// The token of the first request is kept in a cookie, neither is passed to code-server
+	resp, received := request("?access_token=admin-token&folder=/home/coder/project", "", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(url.Values{"folder": {"/home/coder/project"}}, received.query())
+	cookies := resp.Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal(codeServerTokenCookie, cookies[0].Name)
+	assert.Equal("/code-server/sim-cli-code-server/", cookies[0].Path)
+	assert.True(cookies[0].HttpOnly)
+
+	resp, received = request("", "", "vscode-tkn=abc; "+codeServerTokenCookie+"="+cookies[0].Value)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("vscode-tkn=abc", received.Headers.Get("Cookie"))
+	assert.Empty(received.Headers.Get("Authorization"))
+
+	resp, received = request("", "admin-token", "")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Empty(received.Headers.Get("Authorization"))
+	assert.Empty(resp.Cookies(), "only access_token is kept")
+
+	resp, _ = request("", "", codeServerTokenCookie+"=wrong")
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+}
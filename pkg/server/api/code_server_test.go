@@ -0,0 +1,69 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestTar writes an uncompressed tar to path with the files, each holding its own name
+func writeTestTar(t *testing.T, path string, files ...string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, name := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func Test_RecursiveExtractLimits(t *testing.T) {
+	assert := require.New(t)
+
+	// The files of nested archives are counted together
+	root := t.TempDir()
+	assert.NoError(os.MkdirAll(filepath.Join(root, "nodes"), 0755))
+	writeTestTar(t, filepath.Join(root, "nodes", "node1.tar"), "node1/a.log", "node1/b.log")
+	writeTestTar(t, filepath.Join(root, "nodes", "node2.tar"), "node2/a.log", "node2/b.log")
+	err := recursiveExtract(root, utils.ExtractLimits{MaxFiles: 3})
+	var limitErr *utils.ExtractLimitError
+	assert.True(errors.As(err, &limitErr), "expected the file limit exceeded, got %v", err)
+	assert.Contains(limitErr.Reason, "3 files")
+
+	// A zip is refused by its headers before anything is extracted
+	root = t.TempDir()
+	f, err := os.Create(filepath.Join(root, "bundle.zip"))
+	assert.NoError(err)
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("big.log")
+	assert.NoError(err)
+	_, err = w.Write(make([]byte, 2000))
+	assert.NoError(err)
+	assert.NoError(zw.Close())
+	assert.NoError(f.Close())
+	err = recursiveExtract(root, utils.ExtractLimits{MaxBytes: 1000})
+	assert.True(errors.As(err, &limitErr), "expected the byte limit exceeded, got %v", err)
+	assert.Equal("big.log", limitErr.Name)
+	assert.Contains(err.Error(), "bundle.zip")
+	_, err = os.Stat(filepath.Join(root, "big.log"))
+	assert.True(os.IsNotExist(err), "expected nothing extracted")
+
+	// Within the limits every archive is extracted and removed
+	root = t.TempDir()
+	writeTestTar(t, filepath.Join(root, "node1.tar"), "node1/a.log", "node1/b.log")
+	assert.NoError(recursiveExtract(root, utils.ExtractLimits{MaxBytes: 100, MaxFiles: 2, MaxRatio: 1}))
+	data, err := os.ReadFile(filepath.Join(root, "node1", "b.log"))
+	assert.NoError(err)
+	assert.Equal("node1/b.log", string(data))
+	_, err = os.Stat(filepath.Join(root, "node1.tar"))
+	assert.True(os.IsNotExist(err))
+}
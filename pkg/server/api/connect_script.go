@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// connectScriptParams are what a connect script is generated from
+type connectScriptParams struct {
+	// ServerURL is the address the script reaches the API at, without a trailing slash
+	ServerURL string
+	Workspace string
+	VersionID string
+	// Runtime versions connect to a live cluster, there is no simulator to be running
+	Runtime bool
+	// Token is sent with the kubeconfig download when the server requires tokens, the one of the
+	// request the script was generated for
+	Token string
+}
+
+// connectScriptTemplate downloads the kubeconfig into a temporary file and opens a shell using it. The
+// file is removed when the shell exits. Values are quoted by quote, the script only needs curl.
+var connectScriptTemplate = template.Must(template.New("connect").Funcs(template.FuncMap{"quote": shellQuote}).Parse(`#!/bin/sh
+# Opens a shell connected to {{if .Runtime}}the cluster of runtime version{{else}}the simulator of version{{end}} {{.VersionID}} of workspace {{.Workspace}}.
+# Generated by sim-gui, exit the shell to disconnect.
+{{- if .Token}}
+# It holds your sim-gui token, keep it private.
+{{- end}}
+set -eu
+
+server={{quote .ServerURL}}
+instance={{quote .Instance}}
+kubeconfig_url="$server"{{quote .KubeconfigPath}}
+{{- if .Token}}
+token={{quote .Token}}
+{{- end}}
+
+command -v curl >/dev/null 2>&1 || { echo "curl is required to download the kubeconfig" >&2; exit 1; }
+
+kubeconfig=$(mktemp "${TMPDIR:-/tmp}/$instance.XXXXXX")
+trap 'rm -f "$kubeconfig"' EXIT
+
+{{if .Token -}}
+# The header is read from stdin, the token doesn't show in the arguments of curl
+status=$(printf 'Authorization: Bearer %s\n' "$token" | curl -sS -H @- -o "$kubeconfig" -w '%{http_code}' "$kubeconfig_url") || {
+{{- else -}}
+status=$(curl -sS -o "$kubeconfig" -w '%{http_code}' "$kubeconfig_url") || {
+{{- end}}
+	echo "Failed to reach sim-gui at $server" >&2
+	exit 1
+}
+case "$status" in
+200) ;;
+{{- if not .Runtime}}
+409)
+	echo "The simulator $instance isn't running, start it in sim-gui first" >&2
+	exit 1
+	;;
+{{- end}}
+{{- if .Token}}
+401|403)
+	echo "The token isn't allowed to download the kubeconfig of $instance, generate the script again" >&2
+	exit 1
+	;;
+{{- end}}
+404)
+	echo "The version of $instance no longer exists" >&2
+	exit 1
+	;;
+*)
+	echo "Failed to download the kubeconfig of $instance (HTTP $status): $(cat "$kubeconfig")" >&2
+	exit 1
+	;;
+esac
+
+echo "Connected to $instance, exit the shell to disconnect" >&2
+KUBECONFIG="$kubeconfig" PS1="($instance) ${PS1:-\$ }" "${SHELL:-/bin/sh}" -i
+`))
+
+// connectScript generates the shell script connecting to a version
+func connectScript(params connectScriptParams) (string, error) {
+	data := struct {
+		connectScriptParams
+		Instance       string
+		KubeconfigPath string
+	}{
+		connectScriptParams: params,
+		Instance:            fmt.Sprintf("%s-%s", params.Workspace, params.VersionID),
+		KubeconfigPath:      fmt.Sprintf("/api/workspaces/%s/versions/%s/kubeconfig", url.PathEscape(params.Workspace), url.PathEscape(params.VersionID)),
+	}
+	var script bytes.Buffer
+	if err := connectScriptTemplate.Execute(&script, data); err != nil {
+		return "", err
+	}
+	return script.String(), nil
+}
+
+// shellQuote quotes a value for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// handleGetConnectScript returns a shell script that downloads the kubeconfig of a version and opens
+// a shell using it. The server URL defaults to the external one, see serverURL, server overrides it.
+// When the server requires tokens the token of the request is embedded, the download needs one too.
+func (s *Server) handleGetConnectScript(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	serverURL := s.serverURL(r).String()
+	if override := r.URL.Query().Get("server"); override != "" {
+		parsed, err := url.Parse(override)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			http.Error(w, "server must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+		serverURL = override
+	}
+
+	params := connectScriptParams{
+		ServerURL: strings.TrimSuffix(serverURL, "/"),
+		Workspace: name,
+		VersionID: versionID,
+		Runtime:   version.Type == model.VersionTypeRuntime,
+	}
+	if s.tokens != nil {
+		params.Token = requestToken(r)
+	}
+	script, err := connectScript(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-shellscript")
+	// The script may hold a token
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"connect-%s-%s.sh\"", name, versionID))
+	w.Write([]byte(script))
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden rewrites the golden files with the generated output: go test -run Test_ConnectScript -update
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func Test_ConnectScript(t *testing.T) {
+	assert := require.New(t)
+	cases := map[string]connectScriptParams{
+		"simulator.sh": {ServerURL: "http://localhost:8080", Workspace: "ws", VersionID: "v1"},
+		"runtime.sh":   {ServerURL: "http://localhost:8080", Workspace: "ws", VersionID: "live", Runtime: true},
+		// behind a proxy with a path prefix, names are quoted and escaped
+		"proxied.sh": {ServerURL: "https://sim.example.com/gui", Workspace: "case 1234's", VersionID: "v#2"},
+		// the download sends the token of a server requiring tokens
+		"token.sh": {ServerURL: "http://localhost:8080", Workspace: "ws", VersionID: "v1", Token: "s3cr'et"},
+	}
+	for file, params := range cases {
+		script, err := connectScript(params)
+		assert.NoError(err, file)
+
+		golden := filepath.Join("testdata", "connect-script", file)
+		if *updateGolden {
+			assert.NoError(os.MkdirAll(filepath.Dir(golden), 0755))
+			assert.NoError(os.WriteFile(golden, []byte(script), 0644))
+		}
+		expected, err := os.ReadFile(golden)
+		assert.NoError(err, file)
+		assert.Equal(string(expected), script, file)
+
+		// the script is valid POSIX shell
+		if sh, err := exec.LookPath("sh"); err == nil {
+			out, err := exec.Command(sh, "-n", golden).CombinedOutput()
+			assert.NoError(err, "%s: %s", file, out)
+		}
+	}
+}
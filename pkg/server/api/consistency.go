@@ -0,0 +1,488 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+type ConsistencyIssueType string
+
+const (
+	// IssueMissingFiles is a version in the store whose bundle, extracted data or kubeconfig is gone
+	IssueMissingFiles ConsistencyIssueType = "missing-files"
+	// IssueOrphanDirectory is a version directory under the data directory that is not in the store
+	IssueOrphanDirectory ConsistencyIssueType = "orphan-directory"
+	// IssueStaleReady is a version marked ready without a simulator container or image
+	IssueStaleReady ConsistencyIssueType = "stale-ready"
+	// IssueCaseCollision is a workspace whose name only differs in case from another one, both are kept
+	// in the same directory on case-insensitive filesystems
+	IssueCaseCollision ConsistencyIssueType = "case-collision"
+	// IssueInterrupted is a version whose image build was cut short by a server stop, starting it builds the image again
+	IssueInterrupted ConsistencyIssueType = "interrupted"
+	// IssueDeleteInterrupted is a workspace whose delete was cut short by a server stop, the repair moves
+	// it to the trash
+	IssueDeleteInterrupted ConsistencyIssueType = "delete-interrupted"
+)
+
+type RepairStrategy string
+
+const (
+	// RepairMark marks versions with missing files as broken and leaves orphan directories alone
+	RepairMark RepairStrategy = "mark"
+	// RepairImport marks versions with missing files as broken and imports orphan directories as versions
+	RepairImport RepairStrategy = "import"
+	// RepairDelete removes versions with missing files and orphan directories
+	RepairDelete RepairStrategy = "delete"
+)
+
+type ConsistencyIssue struct {
+	Type      ConsistencyIssueType `json:"type"`
+	Workspace string               `json:"workspace"`
+	VersionID string               `json:"versionID"`
+	Message   string               `json:"message"`
+}
+
+type RepairResult struct {
+	ConsistencyIssue
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// checkConsistency compares the store against the data directory and the bundle store without changing
+// any of them. instanceExists reports whether a simulator container or image exists for an instance name.
+func checkConsistency(st store.Storage, bundles bundlestore.Store, dataDir string, instanceExists func(instanceName string) (bool, error)) ([]ConsistencyIssue, error) {
+	workspaces, err := st.ListWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := caseCollisions(workspaces)
+	// Directories are matched by the key of the workspace name, a case-insensitive filesystem returns
+	// the case the directory was created with rather than the one of the name
+	known := make(map[string]bool)
+	for _, ws := range workspaces {
+		if ws.State == model.WorkspaceStateDeleting {
+			issues = append(issues, ConsistencyIssue{
+				Type:      IssueDeleteInterrupted,
+				Workspace: ws.Name,
+				Message:   "marked as being deleted but no delete is running",
+			})
+		}
+		for _, v := range ws.Versions {
+			known[filepath.Join(store.NameKey(ws.Name), v.ID)] = true
+
+			missing, err := missingVersionFiles(bundles, dataDir, ws.Name, v)
+			if err != nil {
+				return nil, err
+			}
+			if len(missing) > 0 {
+				issues = append(issues, ConsistencyIssue{
+					Type:      IssueMissingFiles,
+					Workspace: ws.Name,
+					VersionID: v.ID,
+					Message:   fmt.Sprintf("missing %v", missing),
+				})
+				continue
+			}
+
+			if v.Interrupted != "" {
+				issues = append(issues, ConsistencyIssue{
+					Type:      IssueInterrupted,
+					Workspace: ws.Name,
+					VersionID: v.ID,
+					Message:   v.Interrupted,
+				})
+			}
+
+			if v.Ready && v.Type != model.VersionTypeRuntime && instanceExists != nil {
+				instanceName := v.InstanceName(ws.Name)
+				exists, err := instanceExists(instanceName)
+				if err != nil {
+					log.Printf("Failed to check simulator of %s: %v", instanceName, err)
+					continue
+				}
+				if !exists {
+					issues = append(issues, ConsistencyIssue{
+						Type:      IssueStaleReady,
+						Workspace: ws.Name,
+						VersionID: v.ID,
+						Message:   "marked ready but no simulator container or image exists",
+					})
+				}
+			}
+		}
+	}
+
+	workspaceDirs, err := os.ReadDir(filepath.Join(dataDir, "workspaces"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, wsDir := range workspaceDirs {
+		if !wsDir.IsDir() {
+			continue
+		}
+		versionDirs, err := os.ReadDir(filepath.Join(dataDir, "workspaces", wsDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, vDir := range versionDirs {
+			if !vDir.IsDir() || known[filepath.Join(store.NameKey(wsDir.Name()), vDir.Name())] {
+				continue
+			}
+			issues = append(issues, ConsistencyIssue{
+				Type:      IssueOrphanDirectory,
+				Workspace: wsDir.Name(),
+				VersionID: vDir.Name(),
+				Message:   fmt.Sprintf("directory %s is not referenced by any version", filepath.Join("workspaces", wsDir.Name(), vDir.Name())),
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// caseCollisions returns an issue for each workspace whose name only differs in case from another one,
+// which older releases allowed to be created
+func caseCollisions(workspaces []model.Workspace) []ConsistencyIssue {
+	byKey := make(map[string][]string)
+	for _, ws := range workspaces {
+		key := store.NameKey(ws.Name)
+		byKey[key] = append(byKey[key], ws.Name)
+	}
+
+	issues := []ConsistencyIssue{}
+	for _, ws := range workspaces {
+		names := byKey[store.NameKey(ws.Name)]
+		if len(names) < 2 {
+			continue
+		}
+		others := make([]string, 0, len(names)-1)
+		for _, name := range names {
+			if name != ws.Name {
+				others = append(others, name)
+			}
+		}
+		sort.Strings(others)
+		issues = append(issues, ConsistencyIssue{
+			Type:      IssueCaseCollision,
+			Workspace: ws.Name,
+			Message: fmt.Sprintf("name only differs in case from %s, they share a directory and deletions are refused until one is renamed in data.json",
+				strings.Join(others, ", ")),
+		})
+	}
+	return issues
+}
+
+// refuseCaseCollision writes a conflict and returns true when the workspace shares its directory with
+// another one on case-insensitive filesystems, removing files of one could remove those of the other
+func (s *Server) refuseCaseCollision(w http.ResponseWriter, name string) bool {
+	workspaces, err := s.store.ListWorkspaces()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	for _, issue := range caseCollisions(workspaces) {
+		if issue.Workspace == name {
+			http.Error(w, fmt.Sprintf("Workspace %s: %s", name, issue.Message), http.StatusConflict)
+			return true
+		}
+	}
+	return false
+}
+
+// missingVersionFiles returns the paths of a version which should exist but don't, the bundle
+// archive is looked up in the bundle store
+func missingVersionFiles(bundles bundlestore.Store, dataDir, workspace string, v model.Version) ([]string, error) {
+	var missing []string
+	var paths []string
+	if v.Type == model.VersionTypeRuntime {
+		paths = append(paths, v.KubeconfigPath)
+	} else {
+		if v.BundlePath != "" {
+			exists, err := bundles.Exists(context.Background(), bundleKey(v.BundlePath))
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				missing = append(missing, v.BundlePath)
+			}
+		}
+		// Removed on purpose to free disk, extracted again on the next start
+		if !v.ExtractedRemoved {
+			paths = append(paths, filepath.Join("workspaces", workspace, v.ID, "extracted"))
+		}
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(utils.ResolveDataPath(dataDir, path)); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	return missing, nil
+}
+
+// importOrphanVersion adds an orphan version directory to the store, creating the workspace if needed.
+// An archive found in the directory is put into the bundle store. The instance name is namespaced by profile.
+func importOrphanVersion(st store.Storage, bundles bundlestore.Store, dataDir, profile, workspace, versionID string) error {
+	versionDir := filepath.Join("workspaces", workspace, versionID)
+	entries, err := os.ReadDir(filepath.Join(dataDir, versionDir))
+	if err != nil {
+		return err
+	}
+
+	var version *model.Version
+	hasExtracted := false
+	var bundleName string
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && entry.Name() == "extracted":
+			hasExtracted = true
+		case !entry.IsDir() && isKubeconfigName(entry.Name()):
+			version = &model.Version{
+				Type:              model.VersionTypeRuntime,
+				KubeconfigPath:    filepath.Join(versionDir, entry.Name()),
+				SupportBundleName: entry.Name(),
+				Ready:             true,
+			}
+		case !entry.IsDir() && entry.Name() != buildLogFile && entry.Name() != metricsSummaryName && bundleName == "":
+			bundleName = entry.Name()
+		}
+	}
+	if version == nil && hasExtracted {
+		meta := bundle.ReadMetadata(filepath.Join(dataDir, versionDir, "extracted"))
+		version = &model.Version{
+			Type:                 model.VersionTypeSupportBundle,
+			SupportBundleName:    bundleName,
+			HarvesterVersion:     meta.HarvesterVersion,
+			KubernetesVersion:    meta.KubernetesVersion,
+			NodeCount:            meta.NodeCount,
+			ClusterName:          meta.ClusterName,
+			CollectedAt:          meta.CollectedAt,
+			CollectedAtEstimated: meta.CollectedAtEstimated,
+		}
+		// Without an archive next to it the bundle was uploaded extracted
+		if bundleName != "" {
+			version.BundlePath = filepath.Join(versionDir, bundleName)
+		} else {
+			version.ExtractedOnly = true
+			version.SupportBundleName = "extracted"
+		}
+	}
+	if version == nil {
+		return fmt.Errorf("no kubeconfig or extracted support bundle found in %s", versionDir)
+	}
+	version.ID = versionID
+	version.Instance = profileInstanceName(profile, workspace, versionID)
+	version.Name = versionID
+	version.CreatedAt = time.Now()
+
+	if version.BundlePath != "" {
+		if err := bundles.Put(context.Background(), bundleKey(version.BundlePath), filepath.Join(dataDir, version.BundlePath)); err != nil {
+			return fmt.Errorf("failed to store bundle: %w", err)
+		}
+	}
+
+	ws, err := st.GetWorkspace(workspace)
+	if os.IsNotExist(err) {
+		return st.CreateWorkspace(model.Workspace{
+			Name:        workspace,
+			DisplayName: workspace,
+			CreatedAt:   time.Now(),
+			Versions:    []model.Version{*version},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if HasVersionInWorkspace(ws, versionID) {
+		return nil
+	}
+	ws.Versions = append(ws.Versions, *version)
+	return st.UpdateWorkspace(*ws)
+}
+
+// updateVersion applies fn to a version and persists the workspace if fn reports a change
+func updateVersion(st store.Storage, workspace, versionID string, fn func(v *model.Version) bool) error {
+	ws, err := st.GetWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+	for i := range ws.Versions {
+		if ws.Versions[i].ID == versionID {
+			if fn(&ws.Versions[i]) {
+				return st.UpdateWorkspace(*ws)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// CheckConsistency reports mismatches between the store, the data directory and docker
+func (s *Server) CheckConsistency() ([]ConsistencyIssue, error) {
+	return checkConsistency(s.store, s.bundleStore(), s.dataDir, s.instanceExists)
+}
+
+// RepairConsistency fixes the issues found by CheckConsistency according to strategy.
+// Running it again after a successful repair changes nothing.
+func (s *Server) RepairConsistency(strategy RepairStrategy) ([]RepairResult, error) {
+	issues, err := s.CheckConsistency()
+	if err != nil {
+		return nil, err
+	}
+
+	colliding := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.Type == IssueCaseCollision {
+			colliding[store.NameKey(issue.Workspace)] = true
+		}
+	}
+
+	results := []RepairResult{}
+	for _, issue := range issues {
+		result := RepairResult{ConsistencyIssue: issue}
+		switch {
+		// Files of colliding workspaces may belong to either of them, nothing is changed until one is renamed
+		case colliding[store.NameKey(issue.Workspace)]:
+			result.Action = "none"
+			err = nil
+		case issue.Type == IssueDeleteInterrupted:
+			result.Action = "moved to the trash"
+			err = s.finishDelete(issue.Workspace)
+		case issue.Type == IssueStaleReady:
+			result.Action = "reset ready state"
+			err = s.ResetVersionReadyState(issue.Workspace, issue.VersionID)
+		case issue.Type == IssueMissingFiles && strategy == RepairDelete:
+			result.Action = "deleted version"
+			err = s.deleteBrokenVersion(issue.Workspace, issue.VersionID)
+		case issue.Type == IssueMissingFiles:
+			result.Action = "marked broken"
+			err = updateVersion(s.store, issue.Workspace, issue.VersionID, func(v *model.Version) bool {
+				changed := !v.Broken || v.Ready
+				v.Broken = true
+				v.Ready = false
+				return changed
+			})
+		case issue.Type == IssueOrphanDirectory && strategy == RepairImport:
+			result.Action = "imported as version"
+			err = importOrphanVersion(s.store, s.bundleStore(), s.dataDir, s.profile, issue.Workspace, issue.VersionID)
+		case issue.Type == IssueOrphanDirectory && strategy == RepairDelete:
+			result.Action = "deleted directory"
+			err = os.RemoveAll(filepath.Join(s.dataDir, "workspaces", issue.Workspace, issue.VersionID))
+		default:
+			result.Action = "none"
+			err = nil
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// deleteBrokenVersion removes a version whose files are missing along with anything left of it
+func (s *Server) deleteBrokenVersion(workspace, versionID string) error {
+	ws, err := s.store.GetWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		return nil
+	}
+	if files := s.cleanFiles(workspace, version, true); files.Error != "" {
+		return fmt.Errorf("%s", files.Error)
+	}
+	// A code-server copy left behind is listed as orphaned, only the simulator fails the repair
+	logCleanupFailures(version.InstanceName(workspace), []CleanupResult{s.cleanCodeServer(workspace, version)})
+	if version.Type != model.VersionTypeRuntime {
+		if runtime := s.cleanRuntime(version.InstanceName(workspace), false); runtime.Error != "" {
+			return fmt.Errorf("%s", runtime.Error)
+		}
+	}
+	return nil
+}
+
+// instanceExists reports whether a container or image exists for a simulator instance
+func (s *Server) instanceExists(instanceName string) (bool, error) {
+	containers, err := s.docker.FindContainer(instanceName)
+	if err != nil {
+		return false, err
+	}
+	if len(containers) > 0 {
+		return true, nil
+	}
+	images, err := s.docker.FindImages(instanceName)
+	if err != nil {
+		return false, err
+	}
+	return len(images) > 0, nil
+}
+
+// logConsistencyReport runs the consistency check and logs a summary of the issues found
+func (s *Server) logConsistencyReport() {
+	issues, err := s.CheckConsistency()
+	if err != nil {
+		log.Printf("Consistency check failed: %v", err)
+		return
+	}
+	if len(issues) == 0 {
+		return
+	}
+
+	counts := make(map[ConsistencyIssueType]int)
+	for _, issue := range issues {
+		counts[issue.Type]++
+		log.Printf("Consistency issue (%s) %s/%s: %s", issue.Type, issue.Workspace, issue.VersionID, issue.Message)
+	}
+	log.Printf("Consistency check found %d issues (%d missing files, %d orphan directories, %d stale ready, %d case collisions, %d interrupted builds, %d interrupted deletes), see GET /api/consistency",
+		len(issues), counts[IssueMissingFiles], counts[IssueOrphanDirectory], counts[IssueStaleReady], counts[IssueCaseCollision], counts[IssueInterrupted], counts[IssueDeleteInterrupted])
+}
+
+func (s *Server) handleGetConsistency(w http.ResponseWriter, r *http.Request) {
+	issues, err := s.CheckConsistency()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(issues)
+}
+
+func (s *Server) handleRepairConsistency(w http.ResponseWriter, r *http.Request) {
+	strategy := RepairStrategy(r.URL.Query().Get("strategy"))
+	if strategy == "" {
+		strategy = RepairMark
+	}
+	if strategy != RepairMark && strategy != RepairImport && strategy != RepairDelete {
+		http.Error(w, fmt.Sprintf("Unknown repair strategy %q, expected mark, import or delete", strategy), http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.RepairConsistency(strategy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
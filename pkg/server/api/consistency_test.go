@@ -0,0 +1,140 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+}
+
+func Test_CheckConsistency(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	bundles := bundlestore.NewLocal(dataDir)
+
+	writeFile(t, filepath.Join(dataDir, "workspaces/demo/v1/bundle.zip"))
+	writeFile(t, filepath.Join(dataDir, "workspaces/demo/v1/extracted/file"))
+	writeFile(t, filepath.Join(dataDir, "workspaces/demo/v3/admin.kubeconfig"))
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name: "demo",
+		Versions: []model.Version{
+			{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/demo/v1/bundle.zip", Ready: true},
+			{ID: "v2", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/demo/v2/bundle.zip"},
+		},
+	}))
+
+	before, err := os.ReadFile(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+
+	issues, err := checkConsistency(st, bundles, dataDir, func(instanceName string) (bool, error) {
+		return false, nil
+	})
+	assert.NoError(err)
+	assert.Len(issues, 3)
+	assert.ElementsMatch([]ConsistencyIssueType{IssueStaleReady, IssueMissingFiles, IssueOrphanDirectory},
+		[]ConsistencyIssueType{issues[0].Type, issues[1].Type, issues[2].Type})
+
+	// the report must not change anything
+	after, err := os.ReadFile(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	assert.Equal(before, after)
+
+	assert.NoError(importOrphanVersion(st, bundles, dataDir, "", "demo", "v3"))
+	assert.NoError(importOrphanVersion(st, bundles, dataDir, "", "demo", "v3"))
+	ws, err := st.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Len(ws.Versions, 3)
+	assert.Equal(model.VersionTypeRuntime, ws.Versions[2].Type)
+	assert.Equal(filepath.Join("workspaces", "demo", "v3", "admin.kubeconfig"), ws.Versions[2].KubeconfigPath)
+
+	issues, err = checkConsistency(st, bundles, dataDir, nil)
+	assert.NoError(err)
+	assert.Len(issues, 1)
+	assert.Equal(IssueMissingFiles, issues[0].Type)
+	assert.Equal("v2", issues[0].VersionID)
+}
+
+func Test_CaseCollisions(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	// data.json of an older release, which told names apart by case. Both workspaces have a v1, on a
+	// case-insensitive filesystem that is a single directory listed under either name.
+	data := `{"Demo":{"name":"Demo","versions":[{"id":"v1","type":"runtime","kubeconfigPath":"workspaces/Demo/v1/admin.kubeconfig"}]},` +
+		`"demo":{"name":"demo","versions":[{"id":"v2","type":"runtime","kubeconfigPath":"workspaces/demo/v2/admin.kubeconfig"}]},` +
+		`"other":{"name":"other"}}`
+	assert.NoError(os.WriteFile(filepath.Join(dataDir, "data.json"), []byte(data), 0644))
+	writeFile(t, filepath.Join(dataDir, "workspaces/Demo/v1/admin.kubeconfig"))
+	writeFile(t, filepath.Join(dataDir, "workspaces/demo/v1/admin.kubeconfig"))
+	writeFile(t, filepath.Join(dataDir, "workspaces/demo/v2/admin.kubeconfig"))
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	defer st.Close()
+
+	issues, err := checkConsistency(st, bundlestore.NewLocal(dataDir), dataDir, nil)
+	assert.NoError(err)
+	assert.Len(issues, 2, "expected no orphan directory for the version listed under the other case")
+	for _, issue := range issues {
+		assert.Equal(IssueCaseCollision, issue.Type)
+	}
+	assert.ElementsMatch([]string{"Demo", "demo"}, []string{issues[0].Workspace, issues[1].Workspace})
+	assert.Contains(issues[0].Message, "differs in case from")
+}
+
+func Test_InterruptBuilds(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	bundles := bundlestore.NewLocal(dataDir)
+
+	// data.json as left by a server that stopped while v1 was built and v2 waited for a build worker
+	for _, id := range []string{"v1", "v2", "v3"} {
+		writeFile(t, filepath.Join(dataDir, "workspaces/demo", id, "bundle.zip"))
+		writeFile(t, filepath.Join(dataDir, "workspaces/demo", id, "extracted/file"))
+	}
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name: "demo",
+		Versions: []model.Version{
+			{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/demo/v1/bundle.zip", Building: true},
+			{ID: "v2", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/demo/v2/bundle.zip", Building: true, Ready: true},
+			{ID: "v3", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/demo/v3/bundle.zip"},
+		},
+	}))
+
+	instances, err := interruptBuilds(st)
+	assert.NoError(err)
+	assert.Equal([]string{"demo-v1", "demo-v2"}, instances)
+	ws, err := st.GetWorkspace("demo")
+	assert.NoError(err)
+	for _, v := range ws.Versions[:2] {
+		assert.False(v.Building)
+		assert.False(v.Ready)
+		assert.Equal(interruptedBuildMessage, v.Interrupted)
+	}
+	assert.Empty(ws.Versions[2].Interrupted)
+
+	issues, err := checkConsistency(st, bundles, dataDir, nil)
+	assert.NoError(err)
+	assert.Len(issues, 2)
+	for i, issue := range issues {
+		assert.Equal(IssueInterrupted, issue.Type)
+		assert.Equal(ws.Versions[i].ID, issue.VersionID)
+		assert.Equal(interruptedBuildMessage, issue.Message)
+	}
+
+	// a second startup finds nothing to recover
+	instances, err = interruptBuilds(st)
+	assert.NoError(err)
+	assert.Empty(instances)
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/activity"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/updater"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
+
+const (
+	// dashboardCacheTTL is how long the sections read from docker and the disk are reused, the landing
+	// page polls the dashboard
+	dashboardCacheTTL = 5 * time.Second
+	// dashboardActivityEntries is how many of the newest activity entries of all workspaces are listed
+	dashboardActivityEntries = 10
+)
+
+// errDockerUnavailable is reported by the docker sections of servers built without a docker client
+var errDockerUnavailable = errors.New("docker is not available")
+
+// Dashboard is the overview shown on the landing page. Each section is read on its own, a section
+// whose source failed is null and the failure is in Errors under the name of the section.
+type Dashboard struct {
+	Totals         *DashboardTotals      `json:"totals"`
+	Simulators     *DashboardSimulators  `json:"simulators"`
+	Disk           *DashboardDisk        `json:"disk"`
+	Builds         *DashboardBuilds      `json:"builds"`
+	RecentActivity []DashboardActivity   `json:"recentActivity"`
+	Update         *updater.UpdateStatus `json:"update"`
+	Errors         map[string]string     `json:"errors,omitempty"`
+}
+
+// DashboardTotals counts what the store holds
+type DashboardTotals struct {
+	Workspaces     int `json:"workspaces"`
+	Versions       int `json:"versions"`
+	SupportBundles int `json:"supportBundles"`
+	Runtimes       int `json:"runtimes"`
+}
+
+// DashboardSimulators counts the simulator containers of the versions in the store
+type DashboardSimulators struct {
+	Running int `json:"running"`
+	Stopped int `json:"stopped"`
+}
+
+// DashboardDisk is the space taken by the data directory and the simulator images
+type DashboardDisk struct {
+	DataBytes  *int64 `json:"dataBytes"`
+	ImageBytes *int64 `json:"imageBytes"`
+}
+
+// DashboardBuilds counts the image builds and the simulator starts waiting for a slot
+type DashboardBuilds struct {
+	Pending       int `json:"pending"`
+	WaitingStarts int `json:"waitingStarts"`
+}
+
+// DashboardActivity is an activity entry along with its workspace
+type DashboardActivity struct {
+	Workspace string `json:"workspace"`
+	activity.Entry
+}
+
+// dashboardSources are what the dashboard is read from, replaced by fakes in tests
+type dashboardSources struct {
+	workspaces    func() ([]model.Workspace, error)
+	containers    func() ([]types.Container, error)
+	images        func() ([]image.Summary, error)
+	dataBytes     func() (int64, error)
+	pendingBuilds func() (int, error)
+	waitingStarts func() int
+	activity      func(workspace string) ([]activity.Entry, error)
+	update        func() updater.UpdateStatus
+}
+
+// dashboard builds the Dashboard, the sections read from docker and the disk are cached for
+// dashboardCacheTTL
+type dashboard struct {
+	sources dashboardSources
+	now     func() time.Time
+	// profile of the server, the containers of other profiles aren't counted
+	profile string
+
+	lock sync.Mutex
+	// cached holds the docker and disk sections with their errors, read at cachedAt
+	cached   Dashboard
+	cachedAt time.Time
+}
+
+// dashboardSources returns the sources of the dashboard of the server
+func (s *Server) dashboardSources() dashboardSources {
+	sources := dashboardSources{
+		workspaces: s.store.ListWorkspaces,
+		containers: func() ([]types.Container, error) { return nil, errDockerUnavailable },
+		images:     func() ([]image.Summary, error) { return nil, errDockerUnavailable },
+		dataBytes: func() (int64, error) {
+			return dirSize(filepath.Join(s.dataDir, "workspaces"))
+		},
+		pendingBuilds: func() (int, error) { return 0, errDockerUnavailable },
+		waitingStarts: s.starts.waitingCount,
+		activity: func(workspace string) ([]activity.Entry, error) {
+			if s.activity == nil {
+				return nil, nil
+			}
+			return s.activity.List(workspace, time.Time{})
+		},
+		update: func() updater.UpdateStatus {
+			if s.updater == nil {
+				return disabledUpdateStatus
+			}
+			return s.updater.GetStatus()
+		},
+	}
+	if s.docker != nil {
+		sources.containers = s.docker.ListManagedContainers
+		sources.images = s.docker.ListManagedImages
+		sources.pendingBuilds = func() (int, error) { return s.docker.PendingBuilds(), nil }
+	}
+	return sources
+}
+
+// dashboardState returns the dashboard of the server, created on first use
+func (s *Server) dashboardState() *dashboard {
+	s.dashboardLock.Lock()
+	defer s.dashboardLock.Unlock()
+	if s.dashboard == nil {
+		s.dashboard = &dashboard{sources: s.dashboardSources(), now: time.Now, profile: s.profile}
+	}
+	return s.dashboard
+}
+
+func (s *Server) handleGetDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.dashboardState().get())
+}
+
+// get reads every section of the dashboard
+func (d *dashboard) get() Dashboard {
+	result := Dashboard{RecentActivity: []DashboardActivity{}, Errors: map[string]string{}}
+
+	workspaces, listErr := d.sources.workspaces()
+	if listErr != nil {
+		result.Errors["totals"] = listErr.Error()
+		result.Errors["recentActivity"] = listErr.Error()
+	} else {
+		result.Totals = countTotals(workspaces)
+		recent, err := d.recentActivity(workspaces)
+		if err != nil {
+			result.Errors["recentActivity"] = err.Error()
+		} else {
+			result.RecentActivity = recent
+		}
+	}
+
+	cached := d.expensive(workspaces, listErr)
+	result.Simulators = cached.Simulators
+	result.Disk = cached.Disk
+	result.Builds = cached.Builds
+	for section, msg := range cached.Errors {
+		result.Errors[section] = msg
+	}
+	if result.Builds != nil {
+		// Starts are queued in memory, the count is always fresh
+		builds := *result.Builds
+		builds.WaitingStarts = d.sources.waitingStarts()
+		result.Builds = &builds
+	}
+
+	update := d.sources.update()
+	result.Update = &update
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+	return result
+}
+
+// expensive returns the sections read from docker and the disk, read again once the cached ones are
+// older than dashboardCacheTTL. Simulators can't be told apart from other containers without the
+// workspaces, listErr is why they couldn't be listed.
+func (d *dashboard) expensive(workspaces []model.Workspace, listErr error) Dashboard {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.now()
+	if !d.cachedAt.IsZero() && now.Sub(d.cachedAt) < dashboardCacheTTL {
+		return d.cached
+	}
+
+	result := Dashboard{Errors: map[string]string{}}
+	if listErr != nil {
+		result.Errors["simulators"] = listErr.Error()
+	} else {
+		containers, err := d.sources.containers()
+		if err != nil {
+			result.Errors["simulators"] = err.Error()
+		} else {
+			result.Simulators = countSimulators(d.profile, workspaces, containers)
+		}
+	}
+
+	result.Disk = &DashboardDisk{}
+	if size, err := d.sources.dataBytes(); err != nil {
+		result.Errors["disk.dataBytes"] = err.Error()
+	} else {
+		result.Disk.DataBytes = &size
+	}
+	if images, err := d.sources.images(); err != nil {
+		result.Errors["disk.imageBytes"] = err.Error()
+	} else {
+		var size int64
+		for _, img := range images {
+			size += img.Size
+		}
+		result.Disk.ImageBytes = &size
+	}
+
+	if pending, err := d.sources.pendingBuilds(); err != nil {
+		result.Errors["builds"] = err.Error()
+	} else {
+		result.Builds = &DashboardBuilds{Pending: pending}
+	}
+
+	// A failed listing of the workspaces isn't cached, the next request tries again
+	if listErr == nil {
+		d.cached, d.cachedAt = result, now
+	}
+	return result
+}
+
+// countTotals counts the workspaces and versions of the store
+func countTotals(workspaces []model.Workspace) *DashboardTotals {
+	totals := &DashboardTotals{Workspaces: len(workspaces)}
+	for _, ws := range workspaces {
+		totals.Versions += len(ws.Versions)
+		for _, v := range ws.Versions {
+			if v.Type == model.VersionTypeRuntime {
+				totals.Runtimes++
+			} else {
+				totals.SupportBundles++
+			}
+		}
+	}
+	return totals
+}
+
+// countSimulators counts the containers of versions in the store of profile, code-server and containers
+// of deleted versions or other profiles are left out
+func countSimulators(profile string, workspaces []model.Workspace, containers []types.Container) *DashboardSimulators {
+	owners := make(map[docker.Owner]bool)
+	for _, ws := range workspaces {
+		for _, v := range ws.Versions {
+			if v.Type != model.VersionTypeRuntime {
+				owners[docker.Owner{Profile: profile, Workspace: ws.Name, Version: v.ID}] = true
+			}
+		}
+	}
+
+	simulators := &DashboardSimulators{}
+	for _, ctr := range containers {
+		if owner, ok := docker.ContainerOwner(ctr); !ok || !owners[owner] {
+			continue
+		}
+		if ctr.State == "running" {
+			simulators.Running++
+		} else {
+			simulators.Stopped++
+		}
+	}
+	return simulators
+}
+
+// recentActivity returns the newest activity entries of all workspaces, newest first
+func (d *dashboard) recentActivity(workspaces []model.Workspace) ([]DashboardActivity, error) {
+	recent := []DashboardActivity{}
+	for _, ws := range workspaces {
+		entries, err := d.sources.activity(ws.Name)
+		if err != nil {
+			return nil, err
+		}
+		// The feed is newest first, older entries than those kept can't make the list
+		for i := 0; i < len(entries) && i < dashboardActivityEntries; i++ {
+			recent = append(recent, DashboardActivity{Workspace: ws.Name, Entry: entries[i]})
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].Time.After(recent[j].Time)
+	})
+	if len(recent) > dashboardActivityEntries {
+		recent = recent[:dashboardActivityEntries]
+	}
+	return recent, nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/activity"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/updater"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDashboardSources returns sources with two workspaces and counts the docker and disk reads
+func fakeDashboardSources(reads *int) dashboardSources {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return dashboardSources{
+		workspaces: func() ([]model.Workspace, error) {
+			return []model.Workspace{
+				{Name: "demo", Versions: []model.Version{{ID: "v1"}, {ID: "v2"}, {ID: "v3", Type: model.VersionTypeRuntime}}},
+				{Name: "long name", Versions: []model.Version{{ID: "v1", Instance: "long-name-0a1b2c3d-v1"}}},
+				{Name: "demo-v2", Versions: []model.Version{{ID: "v1"}}},
+			}, nil
+		},
+		containers: func() ([]types.Container, error) {
+			*reads++
+			return []types.Container{
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "demo-v1"}},
+				{State: "exited", Labels: map[string]string{"sim-cli-managed": "demo-v2"}},
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "long-name-0a1b2c3d-v1", docker.WorkspaceLabel: "long name", docker.VersionLabel: "v1"}},
+				// Created before the owner labels, parsed from the name
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "demo-v2-v1"}},
+				// Labelled for the deleted demo-v2/v2, even though the name parses as demo-v2/v2
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "demo-v2-v2", docker.WorkspaceLabel: "demo-v2", docker.VersionLabel: "v2"}},
+				// code-server and a deleted version
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "code-server"}},
+				{State: "running", Labels: map[string]string{"sim-cli-managed": "gone-v1"}},
+			}, nil
+		},
+		images: func() ([]image.Summary, error) {
+			return []image.Summary{{Size: 100}, {Size: 50}}, nil
+		},
+		dataBytes:     func() (int64, error) { return 2048, nil },
+		pendingBuilds: func() (int, error) { return 1, nil },
+		waitingStarts: func() int { return 2 },
+		activity: func(workspace string) ([]activity.Entry, error) {
+			if workspace == "demo" {
+				return []activity.Entry{
+					{Type: "version.uploaded", Time: now.Add(-time.Minute)},
+					{Type: "workspace.created", Time: now.Add(-time.Hour)},
+				}, nil
+			}
+			if workspace == "long name" {
+				return []activity.Entry{{Type: "workspace.created", Time: now}}, nil
+			}
+			return nil, nil
+		},
+		update: func() updater.UpdateStatus { return updater.UpdateStatus{UpdateAvailable: true} },
+	}
+}
+
+func Test_Dashboard(t *testing.T) {
+	assert := require.New(t)
+	reads := 0
+	now := time.Now()
+	d := &dashboard{sources: fakeDashboardSources(&reads), now: func() time.Time { return now }}
+
+	result := d.get()
+	assert.Nil(result.Errors)
+	assert.Equal(&DashboardTotals{Workspaces: 3, Versions: 5, SupportBundles: 4, Runtimes: 1}, result.Totals)
+	assert.Equal(&DashboardSimulators{Running: 3, Stopped: 1}, result.Simulators)
+	assert.EqualValues(2048, *result.Disk.DataBytes)
+	assert.EqualValues(150, *result.Disk.ImageBytes)
+	assert.Equal(&DashboardBuilds{Pending: 1, WaitingStarts: 2}, result.Builds)
+	assert.True(result.Update.UpdateAvailable)
+	assert.Len(result.RecentActivity, 3)
+	assert.Equal("long name", result.RecentActivity[0].Workspace)
+	assert.Equal("version.uploaded", result.RecentActivity[1].Type)
+
+	// Docker is read again once the cache expired
+	d.get()
+	assert.Equal(1, reads)
+	now = now.Add(dashboardCacheTTL)
+	d.get()
+	assert.Equal(2, reads)
+}
+
+func Test_DashboardFailsSoft(t *testing.T) {
+	assert := require.New(t)
+	reads := 0
+	sources := fakeDashboardSources(&reads)
+	sources.containers = func() ([]types.Container, error) { return nil, errDockerUnavailable }
+	sources.images = func() ([]image.Summary, error) { return nil, errDockerUnavailable }
+	sources.pendingBuilds = func() (int, error) { return 0, errDockerUnavailable }
+	d := &dashboard{sources: sources, now: time.Now}
+
+	result := d.get()
+	assert.NotNil(result.Totals)
+	assert.Nil(result.Simulators)
+	assert.Nil(result.Builds)
+	assert.EqualValues(2048, *result.Disk.DataBytes)
+	assert.Nil(result.Disk.ImageBytes)
+	assert.Equal(map[string]string{
+		"simulators":      "docker is not available",
+		"disk.imageBytes": "docker is not available",
+		"builds":          "docker is not available",
+	}, result.Errors)
+
+	// Without the workspaces only the store sections and simulators are missing, nothing is cached
+	sources = fakeDashboardSources(&reads)
+	sources.workspaces = func() ([]model.Workspace, error) { return nil, errors.New("store unavailable") }
+	d = &dashboard{sources: sources, now: time.Now}
+	result = d.get()
+	assert.Nil(result.Totals)
+	assert.Nil(result.Simulators)
+	assert.Empty(result.RecentActivity)
+	assert.NotNil(result.Builds)
+	assert.Equal("store unavailable", result.Errors["totals"])
+	assert.Equal("store unavailable", result.Errors["simulators"])
+	assert.True(d.cachedAt.IsZero())
+
+	// The response keeps its shape with null sections
+	body, err := json.Marshal(result)
+	assert.NoError(err)
+	var fields map[string]any
+	assert.NoError(json.Unmarshal(body, &fields))
+	for _, field := range []string{"totals", "simulators", "disk", "builds", "recentActivity", "update", "errors"} {
+		assert.Contains(fields, field)
+	}
+}
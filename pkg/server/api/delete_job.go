@@ -0,0 +1,55 @@
+package api
+
+import (
+	"maps"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// jobKindDeleteWorkspace jobs delete a workspace. A failed one left the workspace marked as being
+// deleted, the consistency repair finishes it. A cancelled one kept the workspace, the simulators
+// removed until then are built again on start.
+const jobKindDeleteWorkspace = "workspace-delete"
+
+// DeleteResult is the result of a workspace delete job, its progress counts the versions cleaned
+type DeleteResult struct {
+	// Leftovers are the failed cleanups per instance name, they don't keep the workspace from being deleted
+	Leftovers map[string][]CleanupResult `json:"leftovers,omitempty"`
+}
+
+// startDeleteJob deletes a workspace in the background. The job outlives the request, only the cancel
+// endpoint stops it. beginDelete must be held, the job ends it.
+func (s *Server) startDeleteJob(ws model.Workspace, permanent bool) jobs.Job {
+	return s.jobManager().Submit(jobKindDeleteWorkspace, ws.Name, "", func(h *jobs.Handle) error {
+		// Requests that got through before the mark are done before the simulators are removed, they may
+		// have added versions since the handler looked the workspace up
+		h.SetPhase("waiting for requests")
+		s.drainRequests(ws.Name)
+		current, err := s.store.GetWorkspace(ws.Name)
+		if err == nil {
+			h.Update(func(job *jobs.Job) {
+				job.Phase = "removing simulators"
+				job.Progress = &jobs.Progress{Total: len(current.Versions)}
+			})
+			// Only changed in h.Update, the versions are cleaned in parallel
+			leftovers := make(map[string][]CleanupResult)
+			err = s.deleteWorkspace(h.Context(), *current, permanent, func(instanceName string, results []CleanupResult) {
+				h.Update(func(job *jobs.Job) {
+					job.Progress.Done++
+					if failed := failedCleanups(results); len(failed) > 0 {
+						leftovers[instanceName] = failed
+						job.Result = DeleteResult{Leftovers: maps.Clone(leftovers)}
+					}
+				})
+			})
+		}
+		// Ended before the job reports it finished, the workspace can be created again by then
+		s.endDelete(ws.Name)
+		if err == nil {
+			s.events.Publish(events.WorkspaceDeleted, ws.Name, "", nil)
+		}
+		return err
+	})
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// slowCleaner removes simulators once gate lets it, recording how many it removes at once
+type slowCleaner struct {
+	gate    chan struct{}
+	lock    sync.Mutex
+	running int
+	most    int
+	cleaned []string
+}
+
+func (c *slowCleaner) Clean(instanceName string, dryRun bool) (*docker.CleanPlan, error) {
+	c.lock.Lock()
+	c.running++
+	c.most = max(c.most, c.running)
+	c.lock.Unlock()
+
+	<-c.gate
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.running--
+	c.cleaned = append(c.cleaned, instanceName)
+	return &docker.CleanPlan{Instance: instanceName, Containers: []docker.CleanItem{}, Images: []docker.CleanItem{}}, nil
+}
+
+// waitDeleteJobs waits for the delete jobs started so far to finish
+func waitDeleteJobs(t *testing.T, s *Server) {
+	require.Eventually(t, func() bool {
+		for _, job := range s.jobManager().List(jobs.Filter{Kind: jobKindDeleteWorkspace}) {
+			if job.Status == jobs.StatusRunning {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// newDeleteJobServer returns a server with a workspace of ten ready versions whose simulators are
+// removed by a slowCleaner
+func newDeleteJobServer(t *testing.T) (*Server, *http.ServeMux, *slowCleaner) {
+	s, mux := newWorkspaceServer(t)
+	ws := model.Workspace{Name: "ws", CreatedAt: time.Now()}
+	for i := 1; i <= 10; i++ {
+		ws.Versions = append(ws.Versions, model.Version{ID: fmt.Sprintf("v%d", i), Type: model.VersionTypeSupportBundle, Ready: true})
+	}
+	require.NoError(t, s.createWorkspace(ws))
+	cleaner := &slowCleaner{gate: make(chan struct{})}
+	s.cleaner = cleaner
+	s.codeServer = &recordingExec{}
+	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.handleCancelJob)
+	return s, mux, cleaner
+}
+
+func serveJob(t *testing.T, mux *http.ServeMux, method, path string) (int, jobs.Job) {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	var job jobs.Job
+	if rec.Code < 300 {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	}
+	return rec.Code, job
+}
+
+func Test_DeleteWorkspaceInParallel(t *testing.T) {
+	assert := require.New(t)
+	s, mux, cleaner := newDeleteJobServer(t)
+	sub := s.events.Subscribe()
+	defer sub.Close()
+
+	code, job := serveJob(t, mux, "DELETE", "/api/workspaces/ws?permanent=true")
+	assert.Equal(http.StatusAccepted, code)
+	assert.Equal(jobs.StatusRunning, job.Status)
+	assert.Equal(jobKindDeleteWorkspace, job.Kind)
+	assert.Equal("ws", job.Workspace)
+
+	// The delete answered before removing anything and goes on in the background
+	assert.Eventually(func() bool {
+		cleaner.lock.Lock()
+		defer cleaner.lock.Unlock()
+		return cleaner.running == deleteParallelism
+	}, 5*time.Second, 10*time.Millisecond)
+	code, running := serveJob(t, mux, "GET", "/api/jobs/"+job.ID)
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(jobs.StatusRunning, running.Status)
+	assert.Equal("removing simulators", running.Phase)
+	assert.Equal(&jobs.Progress{Done: 0, Total: 10}, running.Progress)
+
+	close(cleaner.gate)
+	waitDeleteJobs(t, s)
+	_, done := serveJob(t, mux, "GET", "/api/jobs/"+job.ID)
+	assert.Equal(jobs.StatusSucceeded, done.Status)
+	assert.Equal(&jobs.Progress{Done: 10, Total: 10}, done.Progress)
+	assert.NotNil(done.FinishedAt)
+	assert.Len(cleaner.cleaned, 10)
+	assert.Equal(deleteParallelism, cleaner.most)
+	_, err := s.store.GetWorkspace("ws")
+	assert.Error(err)
+	assert.Empty(s.deleting)
+
+	// Every cleaned version is announced, then the delete and the finished job
+	var progress []int
+	for e := range sub.Events() {
+		if e.Type == events.WorkspaceDeleted {
+			break
+		}
+		assert.Equal(events.JobUpdated, e.Type)
+		if job := e.Payload.(jobs.Job); job.Progress != nil {
+			progress = append(progress, job.Progress.Done)
+		}
+	}
+	assert.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, progress)
+	e := <-sub.Events()
+	assert.Equal(events.JobUpdated, e.Type)
+	assert.Equal(jobs.StatusSucceeded, e.Payload.(jobs.Job).Status)
+
+	// Listed with the jobs of the workspace
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/jobs?workspace=ws&kind="+jobKindDeleteWorkspace, nil))
+	var list []jobs.Job
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&list))
+	assert.Len(list, 1)
+	assert.Equal(job.ID, list[0].ID)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/jobs?workspace=other", nil))
+	assert.Equal("[]\n", rec.Body.String())
+
+	code, _ = serveJob(t, mux, "POST", "/api/jobs/"+job.ID+"/cancel")
+	assert.Equal(http.StatusConflict, code)
+	code, _ = serveJob(t, mux, "GET", "/api/jobs/unknown")
+	assert.Equal(http.StatusNotFound, code)
+}
+
+func Test_CancelDeleteJob(t *testing.T) {
+	assert := require.New(t)
+	s, mux, cleaner := newDeleteJobServer(t)
+
+	_, job := serveJob(t, mux, "DELETE", "/api/workspaces/ws")
+	assert.Eventually(func() bool {
+		cleaner.lock.Lock()
+		defer cleaner.lock.Unlock()
+		return cleaner.running == deleteParallelism
+	}, 5*time.Second, 10*time.Millisecond)
+
+	code, cancelled := serveJob(t, mux, "POST", "/api/jobs/"+job.ID+"/cancel")
+	assert.Equal(http.StatusAccepted, code)
+	assert.Equal(job.ID, cancelled.ID)
+
+	// The versions being cleaned are finished, the others are left alone
+	close(cleaner.gate)
+	waitDeleteJobs(t, s)
+	_, done := serveJob(t, mux, "GET", "/api/jobs/"+job.ID)
+	assert.Equal(jobs.StatusCancelled, done.Status)
+	assert.Equal(deleteParallelism, done.Progress.Done)
+	assert.Len(cleaner.cleaned, deleteParallelism)
+
+	// The workspace is kept, the versions that lost their simulator are no longer ready
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Empty(ws.State)
+	ready := 0
+	for _, v := range ws.Versions {
+		if v.Ready {
+			ready++
+		}
+	}
+	assert.Equal(10-deleteParallelism, ready)
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Empty(entries)
+	assert.Empty(s.deleting)
+}
+
+func Test_FailedDeleteJobLeavesMark(t *testing.T) {
+	assert := require.New(t)
+	s, mux, cleaner := newDeleteJobServer(t)
+	close(cleaner.gate)
+	// The trash can't be created
+	assert.NoError(os.WriteFile(filepath.Join(s.dataDir, trashDir), nil, 0644))
+
+	_, job := serveJob(t, mux, "DELETE", "/api/workspaces/ws")
+	waitDeleteJobs(t, s)
+	_, done := serveJob(t, mux, "GET", "/api/jobs/"+job.ID)
+	assert.Equal(jobs.StatusFailed, done.Status)
+	assert.Contains(done.Error, "Failed to move workspace to the trash")
+
+	// Left for the consistency repair to finish
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal(model.WorkspaceStateDeleting, ws.State)
+	issues, err := s.CheckConsistency()
+	assert.NoError(err)
+	assert.Contains(issues, ConsistencyIssue{Type: IssueDeleteInterrupted, Workspace: "ws", Message: "marked as being deleted but no delete is running"})
+}
@@ -0,0 +1,8 @@
+package api
+
+import "net/http"
+
+// handleEvents streams state changes, see the events package for the event types and payloads
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.events.ServeHTTP(w, r)
+}
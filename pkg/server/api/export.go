@@ -0,0 +1,82 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatJSON        = "json"
+	formatYAMLArchive = "yaml-archive"
+	formatCSV         = "csv"
+)
+
+// archiveFile is a single entry of a YAML archive download
+type archiveFile struct {
+	Name    string
+	Content string
+}
+
+// parseExportFormat reads the format query parameter, defaulting to json
+func parseExportFormat(r *http.Request, supported ...string) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" || format == formatJSON {
+		return formatJSON, nil
+	}
+	for _, f := range supported {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported format %q, expected json or %s", format, strings.Join(supported, ", "))
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFilename joins the parts into a file name safe for Content-Disposition headers and zip entries
+func exportFilename(ext string, parts ...string) string {
+	cleaned := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.Trim(unsafeFilenameChars.ReplaceAllString(part, "_"), "_"); part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+	return strings.Join(cleaned, "-") + ext
+}
+
+// writeYAMLArchive streams files as a zip attachment, entries are compressed while being written to w
+func writeYAMLArchive(w http.ResponseWriter, filename string, files []archiveFile) error {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		fw, err := zw.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write([]byte(file.Content)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeCSV writes a header and rows as a CSV attachment
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
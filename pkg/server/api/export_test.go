@@ -0,0 +1,70 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteYAMLArchive(t *testing.T) {
+	assert := require.New(t)
+	rec := httptest.NewRecorder()
+	err := writeYAMLArchive(rec, exportFilename(".zip", "demo", "default/pods/virt-launcher"), []archiveFile{
+		{Name: exportFilename(".yaml", "demo", "v1", "default/pods/virt-launcher"), Content: "kind: Pod\n"},
+		{Name: exportFilename(".yaml", "demo", "v2", "default/pods/virt-launcher"), Content: "kind: Pod\nstatus: {}\n"},
+	})
+	assert.NoError(err)
+	assert.Equal("application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(`attachment; filename="demo-default_pods_virt-launcher.zip"`, rec.Header().Get("Content-Disposition"))
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(err)
+	assert.Len(zr.File, 2)
+	assert.Equal("demo-v1-default_pods_virt-launcher.yaml", zr.File[0].Name)
+	assert.Equal("demo-v2-default_pods_virt-launcher.yaml", zr.File[1].Name)
+
+	f, err := zr.File[1].Open()
+	assert.NoError(err)
+	content, err := io.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal("kind: Pod\nstatus: {}\n", string(content))
+}
+
+func Test_WriteVMPodsCSV(t *testing.T) {
+	assert := require.New(t)
+	rec := httptest.NewRecorder()
+	rows := vmPodsCSVRows(VirtualMachinePodsResult{
+		Pods:       []PodInfo{{Name: "virt-launcher-vm1-abcde", CreationTime: "2024-11-18T04:00:00Z"}},
+		Migrations: []MigrationInfo{{Name: "vm1-mig", CreationTime: "2024-11-18T05:00:00Z", SourcePod: "virt-launcher-vm1-abcde", TargetPod: "virt-launcher-vm1-fghij"}},
+	})
+	assert.NoError(writeCSV(rec, exportFilename(".csv", "demo", "v1", "default", "vm1", "pods"), vmPodsCSVHeader, rows))
+	assert.Equal("text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(`attachment; filename="demo-v1-default-vm1-pods.csv"`, rec.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	assert.NoError(err)
+	assert.Equal([][]string{
+		{"kind", "name", "creationTime", "sourcePod", "targetPod", "creationTimeLocal"},
+		{"pod", "virt-launcher-vm1-abcde", "2024-11-18T04:00:00Z", "", "", ""},
+		{"migration", "vm1-mig", "2024-11-18T05:00:00Z", "virt-launcher-vm1-abcde", "virt-launcher-vm1-fghij", ""},
+	}, records)
+}
+
+func Test_ParseExportFormat(t *testing.T) {
+	assert := require.New(t)
+	format, err := parseExportFormat(httptest.NewRequest("POST", "/api/workspaces/demo/resource-history", nil), formatYAMLArchive)
+	assert.NoError(err)
+	assert.Equal(formatJSON, format)
+
+	format, err = parseExportFormat(httptest.NewRequest("POST", "/api/workspaces/demo/resource-history?format=yaml-archive", nil), formatYAMLArchive)
+	assert.NoError(err)
+	assert.Equal(formatYAMLArchive, format)
+
+	_, err = parseExportFormat(httptest.NewRequest("POST", "/api/workspaces/demo/resource-history?format=csv", nil), formatYAMLArchive)
+	assert.Error(err)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SetExternalURL sets the URL clients reach the server at, e.g. https://sim.example.com/tools/sim-gui
+// behind a reverse proxy. Absolute links are built from it instead of the request, the base path isn't
+// added to it. Empty builds them from the request.
+func (s *Server) SetExternalURL(raw string) error {
+	if raw == "" {
+		s.externalURL = nil
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid external URL %q: it must be an http or https URL", raw)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid external URL %q: it can't have a query or a fragment", raw)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	parsed.RawPath = ""
+	s.externalURL = parsed
+	return nil
+}
+
+// SetTrustForwardedHeaders sets whether the X-Forwarded-Host and X-Forwarded-Proto headers of requests
+// are used to build absolute links, and X-Forwarded-For to tell clients apart. They aren't trusted by
+// default, only trust them behind a proxy that sets them, otherwise clients choose the host of the
+// links they get.
+func (s *Server) SetTrustForwardedHeaders(trust bool) {
+	s.trustForwarded = trust
+}
+
+// forwardedValue returns the first value of a forwarded header, proxies in a chain append theirs
+func forwardedValue(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
+
+// serverURL returns the address clients reach the API at without a trailing slash: the external URL
+// when one is set, otherwise the address of the request under the base path, behind a trusted proxy
+// the one the proxy was reached at. The path prefix of the profile of the server is added to either.
+func (s *Server) serverURL(r *http.Request) *url.URL {
+	if s.externalURL != nil {
+		external := *s.externalURL
+		external.Path += s.profilePath()
+		return &external
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if s.trustForwarded {
+		if proto := forwardedValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := forwardedValue(r, "X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return &url.URL{Scheme: scheme, Host: host, Path: s.basePath + s.profilePath()}
+}
+
+// codeServerURL returns the address of code-server published on port of the docker host. Clients
+// reach the docker host by the host name they reach the server at, code-server serves plain http.
+func (s *Server) codeServerURL(r *http.Request, port string) string {
+	host := s.serverURL(r).Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	return (&url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}).String()
+}
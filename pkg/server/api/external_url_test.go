@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ServerURL(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		external   string
+		untrusted  bool
+		basePath   string
+		headers    map[string]string
+		serverURL  string
+		codeServer string
+	}{
+		{
+			name:       "direct",
+			serverURL:  "http://localhost:8080",
+			codeServer: "http://localhost:32768",
+		},
+		{
+			name:       "direct under a base path",
+			basePath:   "/gui",
+			serverURL:  "http://localhost:8080/gui",
+			codeServer: "http://localhost:32768",
+		},
+		{
+			name:       "proxied",
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "sim.example.com"},
+			serverURL:  "https://sim.example.com",
+			codeServer: "http://sim.example.com:32768",
+		},
+		{
+			name:       "proxy chain",
+			headers:    map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "sim.example.com, proxy.internal:8443"},
+			serverURL:  "https://sim.example.com",
+			codeServer: "http://sim.example.com:32768",
+		},
+		{
+			name:       "unknown schemes are ignored",
+			headers:    map[string]string{"X-Forwarded-Proto": "gopher", "X-Forwarded-Host": "sim.example.com:8443"},
+			serverURL:  "http://sim.example.com:8443",
+			codeServer: "http://sim.example.com:32768",
+		},
+		{
+			name:       "untrusted headers",
+			untrusted:  true,
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"},
+			serverURL:  "http://localhost:8080",
+			codeServer: "http://localhost:32768",
+		},
+		{
+			name:       "external URL",
+			external:   "https://tools.example.com/sim-gui/",
+			basePath:   "/gui",
+			headers:    map[string]string{"X-Forwarded-Host": "sim.example.com"},
+			serverURL:  "https://tools.example.com/sim-gui",
+			codeServer: "http://tools.example.com:32768",
+		},
+		{
+			name:       "external IPv6 address",
+			external:   "http://[fd00::1]:8080",
+			serverURL:  "http://[fd00::1]:8080",
+			codeServer: "http://[fd00::1]:32768",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			s := &Server{basePath: tc.basePath, trustForwarded: !tc.untrusted}
+			assert.NoError(s.SetExternalURL(tc.external))
+			r := httptest.NewRequest("GET", "http://localhost:8080/api/workspaces", nil)
+			for header, value := range tc.headers {
+				r.Header.Set(header, value)
+			}
+			assert.Equal(tc.serverURL, s.serverURL(r).String())
+			assert.Equal(tc.codeServer, s.codeServerURL(r, "32768"))
+		})
+	}
+}
+
+func Test_SetExternalURL(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+	for _, invalid := range []string{"sim.example.com", "ftp://sim.example.com", "https://", "https://sim.example.com/?a=b", "https://sim.example.com/#top", "://"} {
+		assert.Error(s.SetExternalURL(invalid), invalid)
+	}
+	assert.NoError(s.SetExternalURL("https://sim.example.com"))
+	assert.NoError(s.SetExternalURL(""))
+	assert.Nil(s.externalURL)
+}
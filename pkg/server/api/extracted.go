@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+var (
+	// errNoArchive is returned when the extracted directory is all there is of a version
+	errNoArchive = errors.New("the version has no bundle archive to extract its files from again")
+	// errExtracting is returned when the extracted directory is being extracted again
+	errExtracting = errors.New("the bundle of the version is being extracted")
+	// errBuildPending is returned when an image build of the version is queued or running
+	errBuildPending = errors.New("an image of the version is being built")
+)
+
+// ExtractionProgress is the progress of extracting the bundle of a version again
+type ExtractionProgress struct {
+	Files      int `json:"files"`
+	TotalFiles int `json:"totalFiles"`
+}
+
+// extractedPath returns the directory a version's bundle is extracted to
+func (s *Server) extractedPath(workspaceName, versionID string) string {
+	return filepath.Join(s.dataDir, "workspaces", workspaceName, versionID, "extracted")
+}
+
+// extraction returns the progress of extracting the bundle of an instance, nil when it isn't extracted
+func (s *Server) extraction(instanceName string) *ExtractionProgress {
+	s.extractLock.Lock()
+	defer s.extractLock.Unlock()
+	progress, ok := s.extractions[instanceName]
+	if !ok {
+		return nil
+	}
+	copied := *progress
+	return &copied
+}
+
+// removeExtracted deletes the extracted directory of a version to free disk, the version keeps its
+// archive and is extracted again by its next start. The caller checks no build uses the directory.
+func (s *Server) removeExtracted(workspaceName string, version model.Version) error {
+	if version.Type == model.VersionTypeRuntime {
+		return errRuntimeVersion
+	}
+	if version.ExtractedOnly || version.BundlePath == "" {
+		return errNoArchive
+	}
+
+	instanceName := version.InstanceName(workspaceName)
+	s.extractLock.Lock()
+	defer s.extractLock.Unlock()
+	if _, ok := s.extractions[instanceName]; ok {
+		return errExtracting
+	}
+
+	// Flagged first, a version whose removal failed halfway is extracted again rather than used
+	err := updateVersion(s.store, workspaceName, version.ID, func(v *model.Version) bool {
+		changed := !v.ExtractedRemoved
+		v.ExtractedRemoved = true
+		return changed
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.extractedPath(workspaceName, version.ID))
+}
+
+// reextract extracts the bundle archive at bundlePath of a version whose extracted directory was
+// removed. The files are extracted into the staging directory and moved into place once complete.
+func (s *Server) reextract(workspaceName string, version model.Version, bundlePath string) error {
+	instanceName := version.InstanceName(workspaceName)
+	s.extractLock.Lock()
+	if _, ok := s.extractions[instanceName]; ok {
+		s.extractLock.Unlock()
+		return errExtracting
+	}
+	if s.extractions == nil {
+		s.extractions = make(map[string]*ExtractionProgress)
+	}
+	progress := &ExtractionProgress{}
+	s.extractions[instanceName] = progress
+	s.extractLock.Unlock()
+	defer func() {
+		s.extractLock.Lock()
+		delete(s.extractions, instanceName)
+		s.extractLock.Unlock()
+	}()
+
+	staging, err := s.newStagingDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	err = utils.UnzipWithProgress(bundlePath, staging, s.uploadLimits.Extract, func(done, total int) {
+		s.extractLock.Lock()
+		progress.Files, progress.TotalFiles = done, total
+		s.extractLock.Unlock()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extract: %w", err)
+	}
+
+	extractedPath := s.extractedPath(workspaceName, version.ID)
+	// Left over by an interrupted removal
+	if err := os.RemoveAll(extractedPath); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, extractedPath); err != nil {
+		return err
+	}
+	if err := indexExtracted(extractedPath, version.ID); err != nil {
+		return err
+	}
+	return updateVersion(s.store, workspaceName, version.ID, func(v *model.Version) bool {
+		changed := v.ExtractedRemoved
+		v.ExtractedRemoved = false
+		return changed
+	})
+}
+
+// cleanExtracted removes the extracted directory of a version unless an image is being built from it
+func (s *Server) cleanExtracted(workspaceName string, version model.Version) error {
+	instanceName := version.InstanceName(workspaceName)
+	if s.docker.BuildPending(instanceName) {
+		return errBuildPending
+	}
+	return s.removeExtracted(workspaceName, version)
+}
+
+// cleanExtractedStatus is the HTTP status of a cleanExtracted error
+func cleanExtractedStatus(err error) int {
+	switch {
+	case errors.Is(err, errRuntimeVersion), errors.Is(err, errNoArchive):
+		return http.StatusBadRequest
+	case errors.Is(err, errExtracting), errors.Is(err, errBuildPending):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) handleCleanExtracted(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if s.refuseCaseCollision(w, name) {
+		return
+	}
+
+	if err := s.cleanExtracted(name, version); err != nil {
+		http.Error(w, err.Error(), cleanExtractedStatus(err))
+		return
+	}
+	if ws, err := s.store.GetWorkspace(name); err == nil {
+		s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// writeExtractedBundle writes a bundle archive with its extracted copy, like an upload
+func writeExtractedBundle(dir string) (*model.Version, error) {
+	path := filepath.Join(dir, "bundle.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"bundle/metadata.yaml", "bundle/logs/harvester.log"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(name)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := utils.Unzip(path, filepath.Join(dir, "extracted"), utils.ExtractLimits{}); err != nil {
+		return nil, err
+	}
+	return &model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: path}, nil
+}
+
+func Test_RemoveExtractedAndReextract(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	version, err := s.createVersion("ws", "v1", writeExtractedBundle)
+	assert.NoError(err)
+	extracted := filepath.Join(s.dataDir, "workspaces", "ws", "v1", "extracted")
+	assert.FileExists(filepath.Join(extracted, "bundle", "logs", "harvester.log"))
+
+	assert.NoError(s.removeExtracted("ws", *version))
+	assert.NoDirExists(extracted)
+	stored, _ := findVersionIn(t, s, "ws", "v1")
+	assert.True(stored.ExtractedRemoved)
+	assert.FileExists(filepath.Join(s.dataDir, stored.BundlePath), "the archive is kept")
+
+	// the consistency check doesn't report the directory as missing
+	issues, err := checkConsistency(s.store, s.bundleStore(), s.dataDir, func(string) (bool, error) { return true, nil })
+	assert.NoError(err)
+	assert.Empty(issues)
+
+	// the next start extracts the archive again before building
+	bundlePath, err := s.bundleSource(context.Background(), "ws", stored)
+	assert.NoError(err)
+	assert.NoError(s.reextract("ws", stored, bundlePath))
+	content, err := os.ReadFile(filepath.Join(extracted, "bundle", "logs", "harvester.log"))
+	assert.NoError(err)
+	assert.Equal("bundle/logs/harvester.log", string(content))
+	assert.Len(readFileIndex(t, extracted), 2, "the extracted files are indexed again")
+	stored, _ = findVersionIn(t, s, "ws", "v1")
+	assert.False(stored.ExtractedRemoved)
+	assert.Nil(s.extraction("ws-v1"))
+	assertStagingEmpty(t, s)
+}
+
+func Test_RemoveExtractedRefusals(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+
+	err := s.removeExtracted("ws", model.Version{ID: "v1", Type: model.VersionTypeRuntime})
+	assert.ErrorIs(err, errRuntimeVersion)
+	err = s.removeExtracted("ws", model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, ExtractedOnly: true})
+	assert.ErrorIs(err, errNoArchive, "the extracted directory is all there is")
+
+	s.extractions = map[string]*ExtractionProgress{"ws-v1": {Files: 1, TotalFiles: 4}}
+	err = s.removeExtracted("ws", model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: "workspaces/ws/v1/bundle.zip"})
+	assert.ErrorIs(err, errExtracting)
+	assert.Equal(&ExtractionProgress{Files: 1, TotalFiles: 4}, s.extraction("ws-v1"))
+}
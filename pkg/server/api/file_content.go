@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// tailChunkSize is how much of a file is read at once when looking for the start of its last lines
+const tailChunkSize = 64 << 10
+
+// serveFileContent answers with the content of the file at path through http.ServeContent, which
+// honors Range, If-Range and If-Modified-Since against the modification time of the file and sniffs
+// the Content-Type unless the handler set it. With tailLines above 0 only the last lines are served,
+// ranges are then offsets within them.
+func serveFileContent(w http.ResponseWriter, r *http.Request, path string, tailLines int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var content io.ReadSeeker = f
+	if tailLines > 0 {
+		start, err := tailOffset(f, info.Size(), tailLines)
+		if err != nil {
+			return err
+		}
+		content = io.NewSectionReader(f, start, info.Size()-start)
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	// The name is left empty, the type isn't guessed from the extension but sniffed from the content
+	http.ServeContent(w, r, "", info.ModTime(), content)
+	return nil
+}
+
+// tailOffset returns the offset of the first of the last lines of a file of size bytes, a final line
+// without newline counts as a line
+func tailOffset(f io.ReaderAt, size int64, lines int) (int64, error) {
+	end := size
+	// The newline ending the file doesn't start another line
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			return 0, err
+		}
+		if last[0] == '\n' {
+			end--
+		}
+	}
+
+	buf := make([]byte, tailChunkSize)
+	for end > 0 {
+		start := max(end-tailChunkSize, 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+			lines--
+			if lines == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// parseTailLines reads the tail query parameter, 0 without one
+func parseTailLines(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("tail")
+	if v == "" {
+		return 0, nil
+	}
+	lines, err := strconv.Atoi(v)
+	if err != nil || lines <= 0 {
+		return 0, fmt.Errorf("invalid tail %q, expected a positive number of lines", v)
+	}
+	return lines, nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ServeFileContent(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), buildLogFile)
+	assert.NoError(os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(os.Chtimes(path, modified, modified))
+
+	serve := func(tail int, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/build-log", nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		assert.NoError(serveFileContent(w, r, path, tail))
+		return w
+	}
+
+	w := serve(0)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("one\ntwo\nthree\n", w.Body.String())
+	assert.Equal("bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(modified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	w = serve(0, "Range", "bytes=4-6")
+	assert.Equal(http.StatusPartialContent, w.Code)
+	assert.Equal("two", w.Body.String())
+	assert.Equal("bytes 4-6/14", w.Header().Get("Content-Range"))
+
+	// Ranges of the tail are offsets within the last lines
+	w = serve(2, "Range", "bytes=0-2")
+	assert.Equal(http.StatusPartialContent, w.Code)
+	assert.Equal("two", w.Body.String())
+	assert.Equal("bytes 0-2/10", w.Header().Get("Content-Range"))
+
+	w = serve(0, "If-Modified-Since", modified.Format(http.TimeFormat))
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Empty(w.Body.String())
+	w = serve(0, "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	assert.Equal(http.StatusOK, w.Code)
+
+	// A range of a file changed since is answered with all of it
+	w = serve(0, "Range", "bytes=0-2", "If-Range", modified.Add(-time.Hour).Format(http.TimeFormat))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("one\ntwo\nthree\n", w.Body.String())
+
+	err := serveFileContent(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), path+".missing", 0)
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_TailOffset(t *testing.T) {
+	assert := require.New(t)
+	for _, c := range []struct {
+		content string
+		lines   int
+		tail    string
+	}{
+		{"one\ntwo\nthree\n", 1, "three\n"},
+		{"one\ntwo\nthree\n", 2, "two\nthree\n"},
+		{"one\ntwo\nthree", 2, "two\nthree"},
+		{"one\ntwo\nthree\n", 10, "one\ntwo\nthree\n"},
+		{"", 3, ""},
+		// Lines spanning the chunks read at once
+		{strings.Repeat("x", tailChunkSize) + "\n" + strings.Repeat("y", tailChunkSize+1) + "\n", 1, strings.Repeat("y", tailChunkSize+1) + "\n"},
+	} {
+		offset, err := tailOffset(strings.NewReader(c.content), int64(len(c.content)), c.lines)
+		assert.NoError(err)
+		assert.Equal(c.tail, c.content[offset:], "%d lines of %q", c.lines, c.content)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// fileIndexName is the index of the files of a version, next to its extracted directory
+const fileIndexName = "files.jsonl"
+
+// fileIndexHashLimit is the size up to which files have their SHA-1 in the index
+const fileIndexHashLimit = 1 << 20
+
+// FileIndexEntry is a line of the file index of a version
+type FileIndexEntry struct {
+	// Path is slash separated and relative to the extracted directory
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA1    string    `json:"sha1,omitempty"`
+}
+
+// FileIndexStats describes a built file index
+type FileIndexStats struct {
+	Files int `json:"files"`
+	// BuildSeconds is how long walking and hashing the extracted directory took
+	BuildSeconds float64 `json:"buildSeconds"`
+}
+
+// buildFileIndex writes the index of the files under extracted, one JSON entry per line, next to it.
+// The index is replaced once complete, a failed build keeps the previous one.
+func buildFileIndex(extracted string) (FileIndexStats, error) {
+	start := time.Now()
+	indexPath := filepath.Join(filepath.Dir(extracted), fileIndexName)
+	tmp := indexPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return FileIndexStats{}, err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	files := 0
+	err = filepath.WalkDir(extracted, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(extracted, path)
+		if err != nil {
+			return err
+		}
+
+		entry := FileIndexEntry{Path: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime().UTC()}
+		if info.Size() <= fileIndexHashLimit {
+			if entry.SHA1, err = fileSHA1(path); err != nil {
+				return err
+			}
+		}
+		files++
+		return enc.Encode(entry)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, indexPath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return FileIndexStats{}, fmt.Errorf("failed to index files: %w", err)
+	}
+	return FileIndexStats{Files: files, BuildSeconds: time.Since(start).Seconds()}, nil
+}
+
+func fileSHA1(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// indexExtracted builds the file index and the metrics summary of a version once its bundle was extracted.
+// A failed summary is only logged, it is summarized again when asked for.
+func indexExtracted(extracted, versionID string) error {
+	stats, err := buildFileIndex(extracted)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d files of %s in %.1fs\n", stats.Files, versionID, stats.BuildSeconds)
+
+	if _, err := writeMetricsSummary(extracted); err != nil {
+		fmt.Printf("Failed to summarize metrics of %s: %v\n", versionID, err)
+	}
+	return nil
+}
+
+// handleReindexVersion rebuilds the file index of a version, e.g. after files were changed in its
+// extracted directory
+func (s *Server) handleReindexVersion(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if version.Type == model.VersionTypeRuntime {
+		http.Error(w, errRuntimeVersion.Error(), http.StatusBadRequest)
+		return
+	}
+	// The directory is replaced as a whole once extracted, which indexes it again
+	if version.ExtractedRemoved || s.extraction(version.InstanceName(name)) != nil {
+		http.Error(w, "The extracted bundle of the version was removed, starting the simulator extracts and indexes it again", http.StatusConflict)
+		return
+	}
+
+	stats, err := buildFileIndex(s.extractedPath(name, versionID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
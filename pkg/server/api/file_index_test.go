@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// readFileIndex reads the file index next to extracted
+func readFileIndex(t *testing.T, extracted string) []FileIndexEntry {
+	f, err := os.Open(filepath.Join(filepath.Dir(extracted), fileIndexName))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []FileIndexEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry FileIndexEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func Test_BuildFileIndex(t *testing.T) {
+	assert := require.New(t)
+	extracted := filepath.Join(t.TempDir(), "extracted")
+	assert.NoError(os.MkdirAll(filepath.Join(extracted, "bundle", "logs"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(extracted, "bundle", "metadata.yaml"), []byte("projectName: harvester"), 0644))
+	assert.NoError(os.WriteFile(filepath.Join(extracted, "bundle", "logs", "huge.log"), []byte(strings.Repeat("x", fileIndexHashLimit+1)), 0644))
+
+	stats, err := buildFileIndex(extracted)
+	assert.NoError(err)
+	assert.Equal(2, stats.Files)
+
+	entries := readFileIndex(t, extracted)
+	assert.Len(entries, 2)
+	assert.Equal("bundle/logs/huge.log", entries[0].Path)
+	assert.EqualValues(fileIndexHashLimit+1, entries[0].Size)
+	assert.Empty(entries[0].SHA1, "large files aren't hashed")
+	assert.Equal("bundle/metadata.yaml", entries[1].Path)
+	assert.EqualValues(len("projectName: harvester"), entries[1].Size)
+	assert.Equal("ee5bba0bd931cd1f961e0f79210ff53710dfb3cd", entries[1].SHA1)
+	assert.False(entries[1].ModTime.IsZero())
+}
+
+func Test_ReindexVersion(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, defaultUploadLimits)
+	_, err := s.createVersion("ws", "v1", writeExtractedBundle)
+	assert.NoError(err)
+	extracted := s.extractedPath("ws", "v1")
+	// writeExtractedBundle extracts without indexing
+	assert.NoFileExists(filepath.Join(filepath.Dir(extracted), fileIndexName))
+
+	assert.NoError(os.WriteFile(filepath.Join(extracted, "bundle", "notes.txt"), []byte("checked"), 0644))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/v1/reindex", nil))
+	assert.Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var stats FileIndexStats
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Equal(3, stats.Files)
+	paths := []string{}
+	for _, entry := range readFileIndex(t, extracted) {
+		paths = append(paths, entry.Path)
+	}
+	assert.Equal([]string{"bundle/logs/harvester.log", "bundle/metadata.yaml", "bundle/notes.txt"}, paths)
+
+	// nothing to index until the next start extracts the bundle again
+	version, _ := findVersionIn(t, s, "ws", "v1")
+	assert.NoError(s.removeExtracted("ws", version))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/v1/reindex", nil))
+	assert.Equal(http.StatusConflict, rec.Code)
+
+	_, err = s.createVersion("ws", "v2", func(dir string) (*model.Version, error) {
+		version, err := writeKubeconfig(dir)
+		if err == nil {
+			version.ID = "v2"
+		}
+		return version, err
+	})
+	assert.NoError(err)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/v2/reindex", nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
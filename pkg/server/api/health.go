@@ -0,0 +1,22 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+)
+
+// Health reports whether the server is up and the state of its dependencies
+type Health struct {
+	Status  string               `json:"status"`
+	Kubectl executor.KubectlInfo `json:"kubectl"`
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Health{
+		Status:  "ok",
+		Kubectl: s.kubectl,
+	})
+}
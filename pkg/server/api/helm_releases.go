@@ -0,0 +1,307 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// maxHelmReleaseSize bounds a decoded release, which holds the chart templates and values. Larger
+// payloads are reported instead of decoded.
+const maxHelmReleaseSize = 16 << 20
+
+// HelmRelease is the latest revision of a helm release, read from its release secret
+type HelmRelease struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	Revision     int    `json:"revision"`
+	Status       string `json:"status,omitempty"`
+	Chart        string `json:"chart,omitempty"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	AppVersion   string `json:"appVersion,omitempty"`
+	LastDeployed string `json:"lastDeployed,omitempty"`
+	// Secret is the release secret the revision was read from
+	Secret string `json:"secret"`
+	// Error is why the payload couldn't be decoded, the release is listed with what the secret labels tell
+	Error string `json:"error,omitempty"`
+}
+
+// HelmReleasesResult are the helm releases of a single version
+type HelmReleasesResult struct {
+	VersionID string        `json:"versionID"`
+	Releases  []HelmRelease `json:"releases,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Status    string        `json:"status"` // "found", "stopped", "error", "missing"
+}
+
+type helmSecretList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string            `yaml:"name"`
+			Namespace string            `yaml:"namespace"`
+			Labels    map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Data map[string]string `yaml:"data"`
+	} `yaml:"items"`
+}
+
+// helmReleasePayload is the part of a helm release the inventory reports
+type helmReleasePayload struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status       string `json:"status"`
+		LastDeployed string `json:"last_deployed"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+func (s *Server) handleGetHelmReleases(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	results := s.helmReleases(r.Context(), ws, []string{versionID})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results[0])
+}
+
+// handleGetWorkspaceHelmReleases returns the helm releases of every version, or of the comma separated
+// versions query parameter. With diff=true only the releases that differ between the versions are kept.
+func (s *Server) handleGetWorkspaceHelmReleases(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var versionIDs []string
+	if v := r.URL.Query().Get("versions"); v != "" {
+		versionIDs = strings.Split(v, ",")
+	}
+
+	results := s.helmReleases(r.Context(), ws, versionIDs)
+	if r.URL.Query().Get("diff") == "true" {
+		results = diffHelmReleases(results)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// helmReleases lists the helm releases of every version of the workspace, or only of versionIDs when
+// given. Requested versions that don't exist are reported as missing.
+func (s *Server) helmReleases(ctx context.Context, ws *model.Workspace, versionIDs []string) []HelmReleasesResult {
+	results := []HelmReleasesResult{}
+
+	for _, v := range orderedVersions(ws.Versions) {
+		if len(versionIDs) > 0 && !slices.Contains(versionIDs, v.ID) {
+			continue
+		}
+
+		if v.Type != model.VersionTypeRuntime {
+			instanceName := v.InstanceName(ws.Name)
+			containers, err := s.docker.FindRunningContainer(instanceName)
+			if err != nil || len(containers) == 0 {
+				results = append(results, HelmReleasesResult{
+					VersionID: v.ID,
+					Status:    "stopped",
+					Error:     "Container not running",
+				})
+				continue
+			}
+		}
+
+		exec, err := s.GetExecutor(ws.Name, v.ID)
+		if err != nil {
+			results = append(results, HelmReleasesResult{VersionID: v.ID, Status: "error", Error: err.Error()})
+			continue
+		}
+
+		releases, err := collectHelmReleases(ctx, exec)
+		if err != nil {
+			results = append(results, HelmReleasesResult{VersionID: v.ID, Status: "error", Error: err.Error()})
+			continue
+		}
+		results = append(results, HelmReleasesResult{VersionID: v.ID, Status: "found", Releases: releases})
+	}
+
+	for _, id := range versionIDs {
+		if !HasVersionInWorkspace(ws, id) {
+			results = append(results, HelmReleasesResult{
+				VersionID: id,
+				Status:    "missing",
+				Error:     "Version no longer exists",
+			})
+		}
+	}
+
+	return results
+}
+
+// collectHelmReleases decodes the release secrets of every namespace and keeps the latest revision of
+// each release. Secrets that can't be decoded are listed with their error.
+func collectHelmReleases(ctx context.Context, exec executor.Executor) ([]HelmRelease, error) {
+	var secrets helmSecretList
+	if err := decodeKubectlList(ctx, exec, &secrets, "get", "secrets", "-A", "--field-selector", "type=helm.sh/release.v1", "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get helm release secrets: %w", err)
+	}
+
+	latest := map[string]HelmRelease{}
+	for _, item := range secrets.Items {
+		// Helm labels its secrets with the release and revision, they identify releases that don't decode
+		release := HelmRelease{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Labels["name"],
+			Status:    item.Metadata.Labels["status"],
+			Secret:    item.Metadata.Name,
+		}
+		release.Revision, _ = strconv.Atoi(item.Metadata.Labels["version"])
+
+		payload, err := decodeHelmRelease(item.Data["release"])
+		if err != nil {
+			release.Error = err.Error()
+		} else {
+			release.Name = payload.Name
+			release.Revision = payload.Version
+			release.Status = payload.Info.Status
+			release.Chart = payload.Chart.Metadata.Name
+			release.ChartVersion = payload.Chart.Metadata.Version
+			release.AppVersion = payload.Chart.Metadata.AppVersion
+			release.LastDeployed = payload.Info.LastDeployed
+		}
+		if release.Name == "" {
+			release.Name = item.Metadata.Name
+		}
+
+		key := release.Namespace + "/" + release.Name
+		if current, ok := latest[key]; !ok || release.Revision > current.Revision {
+			latest[key] = release
+		}
+	}
+
+	releases := make([]HelmRelease, 0, len(latest))
+	for _, release := range latest {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		a, b := releases[i], releases[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return releases, nil
+}
+
+// decodeHelmRelease decodes the release field of a secret: the secret encoding wraps helm's own base64
+// of the gzipped release JSON
+func decodeHelmRelease(data string) (*helmReleasePayload, error) {
+	if data == "" {
+		return nil, fmt.Errorf("the secret has no release")
+	}
+	// The base64 layers grow the compressed release by 16/9, which is smaller than the decompressed one
+	if len(data)/16*9 > maxHelmReleaseSize {
+		return nil, fmt.Errorf("the release is larger than %d bytes", maxHelmReleaseSize)
+	}
+	encoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secret data: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	var reader io.Reader = bytes.NewReader(raw)
+	// Helm only compresses releases since v3.0.0-beta, earlier ones are plain JSON
+	if bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	content, err := io.ReadAll(io.LimitReader(reader, maxHelmReleaseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress release: %w", err)
+	}
+	if len(content) > maxHelmReleaseSize {
+		return nil, fmt.Errorf("the release is larger than %d bytes", maxHelmReleaseSize)
+	}
+
+	var payload helmReleasePayload
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	return &payload, nil
+}
+
+// diffHelmReleases keeps the releases whose chart, versions or status differ between the versions the
+// releases were found in, including releases some of them don't have and releases that didn't decode
+func diffHelmReleases(results []HelmReleasesResult) []HelmReleasesResult {
+	type releaseState struct {
+		chart, chartVersion, appVersion, status string
+	}
+	found := 0
+	states := map[string]map[releaseState]int{}
+	for _, result := range results {
+		if result.Status != "found" {
+			continue
+		}
+		found++
+		for _, release := range result.Releases {
+			key := release.Namespace + "/" + release.Name
+			if states[key] == nil {
+				states[key] = map[releaseState]int{}
+			}
+			states[key][releaseState{release.Chart, release.ChartVersion, release.AppVersion, release.Status}]++
+		}
+	}
+
+	diffed := make([]HelmReleasesResult, 0, len(results))
+	for _, result := range results {
+		if result.Status == "found" {
+			releases := []HelmRelease{}
+			for _, release := range result.Releases {
+				key := release.Namespace + "/" + release.Name
+				if release.Error != "" || len(states[key]) > 1 || states[key][releaseState{release.Chart, release.ChartVersion, release.AppVersion, release.Status}] < found {
+					releases = append(releases, release)
+				}
+			}
+			result.Releases = releases
+		}
+		diffed = append(diffed, result)
+	}
+	return diffed
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Release secrets captured from a Harvester cluster, the harvester release was upgraded once and
+// rancher-logging's secret was truncated
+const helmReleaseSecrets = `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: sh.helm.release.v1.harvester.v2
+    namespace: harvester-system
+    labels:
+      name: harvester
+      owner: helm
+      status: superseded
+      version: "2"
+  type: helm.sh/release.v1
+  data:
+    release: SDRzSUFBQUFBQUFDQTVWUVFVN0RNQkQ4U21TdUpDUk9pOVJJdlJSeFJBZ0JiU2xGYU90c2dpRjJMTnNKaXFyK0hUdEJMVVhsd01tYTJWblA3R3lKQklFa0M4Z2I2QmFOUlUzT0E4SmxVVHR5U3dxdWpYM05VVlYxaDduWDBaaU93dmd5ak5PSGVKSWxOQnVOb25GQ1YzNnRnbitJYzZ6UURySUJHcWE1c3J5V25ucFVwWVljQTFZTDVYVmVZaXpZeHZpcGFSUnFnN2xiMzdrQmMrRnRuMWVnaFJ3czlPRGthYTFiL1BaSW9qU0tUM2g3Q2hTZkg1UXRIVGoxa3h2V3ZiOUZGeElzK216UEI5ODlmVEYwSWxEYXFBTlI5WjVEU3ZLMG5LbTVtSGVNVnUzbXZTNGQvbVRkaE43Y0tRMkw4Y2N0bjEydmxqT3pTU3U3V296anEzSTZKYnNYZndsVVRXKzVKZHI5enhsNGtPejZSbXBaOFBMM0xQVWpBWklYcmc1dkhvYmhXcDRGOTNXakdXYkJ2cWlMUDZPdjVYR0oxQ0YvcjFIQWpzc09UZWNlUVhaZlIwd3JHbVFDQUFBPQ==
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: sh.helm.release.v1.harvester.v3
+    namespace: harvester-system
+    labels:
+      name: harvester
+      owner: helm
+      status: deployed
+      version: "3"
+  type: helm.sh/release.v1
+  data:
+    release: SDRzSUFBQUFBQUFDQTNWUTBVN0NNQlQ5bGFXK3VybDJRMlVKTHhnZmpURWlJR0xNcGV0bWRlMmF0c3dzWlA5dXV4a1FnMC9OT2ZmY25uUFBEa2tRREdVQmVnZmRNR09aUnVjQjRyS29IYmxEQmRmR3Z1Vk1WWFhMY3E4ak1VbkQrREtNazFrOHpqREowalFhWWJMeWF4V2NGSTlEVEdZNHpXS1M0VGk2dmtwN2NjNHFaZ2ZaQUEzVlhGbGVTMDg5cVZKRHpnSmFDK1YxWG1JczJLM3gwNzFINTJqcW90cytyV0FXY3JEUWc1T0hOVXliSHdjY0pSRSs0ZXdwVUh4K1VEWms0TlJ2YmxqMy9wYTVpR0NaVC9aeThOM1RGME5hd2FTTldoQlY3em1rUk0vTHFacUxlVXRKMVd3KzZ0TGhMOXFPeWQyRDByQVlmZDd6NmUxcU9UV2JwTEtyeFNpK0tTY1QxTDM2UzZEYTlwWTdwTjMvbklJSHVPc2JxV1hCeTcrenhJOEVTRjY0T3J4NUdJWnJlUlk4MWx0TldSYnNpN3I0Ti9wYUhwZVlPT1R2TlFyb2NkbWhhZDBqVVBjTkhZb3gybUlDQUFBPQ==
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: sh.helm.release.v1.rancher-monitoring.v1
+    namespace: cattle-monitoring-system
+    labels:
+      name: rancher-monitoring
+      owner: helm
+      status: failed
+      version: "1"
+  type: helm.sh/release.v1
+  data:
+    release: SDRzSUFBQUFBQUFDQTMxUXkwN0RNQkQ4bGNnY0lhbWRCMUlqY1NuaWlCQUNDcFFpdEhXYzFPRFlsdTBVUlZYL0hUdEJVSjdIbVozWm5aMHRrdEF5VkViSWdLUnJadUpXU2U2VTRiSkJSeEhpc2xaK3VrVTFOOVk5VlV3TDFiTXFHRktjNWpFK2puRjJqYWNsU2NzOFR3cVNMb0pOd0svaWFVelNhMUtVR1M0eFRqREdnN2hpZ3JsUk5rSkxEZGVPS3htb0c5MFlxRmhFVmF1RExraXNBOWZaTUsyQkMyL2RlWkt1d2JnaGE4c2NWT0JnQVAvL3QySEd2aDhpT0V0d2toMTJPaStTakNUa2x6Q0JBczNubjY1Tk9uSjZqOFBKY2VIdElaUmpQalU0RnNJK2ZJYjVvQ2RqU1MyVEx1bWhGY1BOTVRxNnY1dnBlVHZ2YVNvMnEyZlZlUHhLKzJsNmZxa04zQll2RjN4MnRyaWIyVlVtM09LMndLZk55UW5hUFlhdlFIVER5UzB5ZmorbkVBRFpEVFVwV2ZQbSt5d0xveFlrcjVrTkphSTRqcGZ5SUxwU25hR3NqSDYyTi9uemg2WDgyaXp4S0R4dU5kRGhld3JPQ2JhM0s3YTk5ZXZRN2czeHFXRUNqd0lBQUE9PQ==
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: sh.helm.release.v1.rancher-logging.v4
+    namespace: cattle-logging-system
+    labels:
+      name: rancher-logging
+      owner: helm
+      status: deployed
+      version: "4"
+  type: helm.sh/release.v1
+  data:
+    release: SDRzSUFHZGhjbUpoWjJVPQ==
+kind: List
+`
+
+func Test_CollectHelmReleases(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"secrets": {stdout: helmReleaseSecrets},
+	}}
+
+	releases, err := collectHelmReleases(context.Background(), exec)
+	assert.NoError(err)
+	assert.Len(releases, 3)
+	assert.Equal(HelmRelease{
+		Namespace:    "cattle-monitoring-system",
+		Name:         "rancher-monitoring",
+		Revision:     1,
+		Status:       "failed",
+		Chart:        "rancher-monitoring",
+		ChartVersion: "103.0.3+up45.31.1",
+		AppVersion:   "0.65.1",
+		LastDeployed: "2024-09-12T15:30:00.000Z",
+		Secret:       "sh.helm.release.v1.rancher-monitoring.v1",
+	}, releases[1])
+
+	// the latest revision of an upgraded release
+	assert.Equal("harvester", releases[2].Name)
+	assert.Equal(3, releases[2].Revision)
+	assert.Equal("1.3.1", releases[2].ChartVersion)
+	assert.Equal("deployed", releases[2].Status)
+
+	// a corrupt payload is listed with what the secret labels tell
+	broken := releases[0]
+	assert.Equal("rancher-logging", broken.Name)
+	assert.Equal(4, broken.Revision)
+	assert.Equal("deployed", broken.Status)
+	assert.Empty(broken.ChartVersion)
+	assert.Contains(broken.Error, "failed to decompress release")
+}
+
+func Test_DecodeHelmReleaseLimits(t *testing.T) {
+	assert := require.New(t)
+
+	// compresses to a few KB but is over the limit once decompressed
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(make([]byte, maxHelmReleaseSize+1))
+	assert.NoError(err)
+	assert.NoError(gz.Close())
+	data := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString(compressed.Bytes())))
+	_, err = decodeHelmRelease(data)
+	assert.ErrorContains(err, "larger than")
+
+	_, err = decodeHelmRelease("")
+	assert.Error(err)
+	_, err = decodeHelmRelease("not base64!")
+	assert.ErrorContains(err, "failed to decode secret data")
+
+	// releases of helm before v3.0.0-beta aren't compressed
+	plain := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte(`{"name":"old","version":7,"chart":{"metadata":{"version":"0.1.0"}}}`))))
+	payload, err := decodeHelmRelease(plain)
+	assert.NoError(err)
+	assert.Equal(7, payload.Version)
+	assert.Equal("0.1.0", payload.Chart.Metadata.Version)
+}
+
+func Test_DiffHelmReleases(t *testing.T) {
+	assert := require.New(t)
+	harvester := func(version string) HelmRelease {
+		return HelmRelease{Namespace: "harvester-system", Name: "harvester", Chart: "harvester", ChartVersion: version, Status: "deployed"}
+	}
+	monitoring := HelmRelease{Namespace: "cattle-monitoring-system", Name: "rancher-monitoring", Chart: "rancher-monitoring", ChartVersion: "103.0.3", Status: "deployed"}
+	logging := HelmRelease{Namespace: "cattle-logging-system", Name: "rancher-logging", Chart: "rancher-logging", ChartVersion: "103.0.0", Status: "deployed"}
+	broken := HelmRelease{Namespace: "kube-system", Name: "rke2-canal", Error: "failed to parse release"}
+
+	results := diffHelmReleases([]HelmReleasesResult{
+		{VersionID: "v1", Status: "found", Releases: []HelmRelease{monitoring, harvester("1.3.0"), broken}},
+		{VersionID: "v2", Status: "found", Releases: []HelmRelease{logging, monitoring, harvester("1.3.1")}},
+		{VersionID: "v3", Status: "stopped", Error: "Container not running"},
+	})
+	assert.Equal([]HelmRelease{harvester("1.3.0"), broken}, results[0].Releases)
+	assert.Equal([]HelmRelease{logging, harvester("1.3.1")}, results[1].Releases, "releases only some versions have differ")
+	assert.Equal("stopped", results[2].Status)
+	assert.Nil(results[2].Releases)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// importPathError is a path refused by resolveImportPath, with the status code it is reported with
+type importPathError struct {
+	status int
+	err    error
+}
+
+func (e *importPathError) Error() string { return e.err.Error() }
+
+// SetImportRoot confines the files imported with from-path to dir, empty refuses imports from a path
+func (s *Server) SetImportRoot(dir string) error {
+	if dir == "" {
+		s.importRoot = ""
+		return nil
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	// Symlinks are resolved on both sides so they can't be used to escape the root
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return fmt.Errorf("invalid import root: %w", err)
+	}
+	s.importRoot = root
+	return nil
+}
+
+// withinImportRoot reports whether a path with symlinks resolved is under the import root, never
+// without one
+func (s *Server) withinImportRoot(resolved string) bool {
+	if s.importRoot == "" {
+		return false
+	}
+	rel, err := filepath.Rel(s.importRoot, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveImportPath checks that path is an absolute path to a readable regular file under the import root
+// and returns it with symlinks resolved. Without an import root every path is refused, the server would
+// otherwise serve and remove any file it can read for its clients.
+func (s *Server) resolveImportPath(path string) (string, error) {
+	if s.importRoot == "" {
+		return "", &importPathError{http.StatusForbidden, errors.New("imports from a path on the server are disabled, start the server with --import-root to allow them")}
+	}
+	if !filepath.IsAbs(path) {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %q must be absolute", path)}
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %s is not readable: %w", path, err)}
+	}
+
+	if !s.withinImportRoot(resolved) {
+		return "", &importPathError{http.StatusForbidden, fmt.Errorf("path %s is outside of the import root %s", path, s.importRoot)}
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %s is not readable: %w", path, err)}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %s is not readable: %w", path, err)}
+	}
+	if info.IsDir() {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %s is a directory, expected a support bundle zip or kubeconfig", path)}
+	}
+	if !info.Mode().IsRegular() {
+		return "", &importPathError{http.StatusBadRequest, fmt.Errorf("path %s is not a regular file", path)}
+	}
+	return resolved, nil
+}
+
+// importPathRequest is the body of an import from a path on the server
+type importPathRequest struct {
+	Path string `json:"path"`
+	Move bool   `json:"move"`
+}
+
+func (s *Server) handleImportFromPath(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req importPathRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	source, err := s.resolveImportPath(req.Path)
+	if err != nil {
+		var pathErr *importPathError
+		if errors.As(err, &pathErr) {
+			http.Error(w, err.Error(), pathErr.status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	versionID := getNextVersionID(ws)
+	user := s.requestUser(r)
+	version, err := s.createVersion(name, versionID, func(dir string) (*model.Version, error) {
+		files := []uploadedFile{{Name: filepath.Base(req.Path), Path: filepath.Join(dir, ".upload-0")}}
+		if err := linkOrCopy(source, files[0].Path); err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", req.Path, err)
+		}
+
+		var version *model.Version
+		var err error
+		if isKubeconfigFile(files) {
+			version, err = processKubeconfigUpload(files, dir, versionID)
+		} else {
+			version, err = processSupportBundleUpload(files, dir, versionID, s.uploadLimits.Extract)
+		}
+		if err != nil {
+			return nil, err
+		}
+		version.ImportedFrom = req.Path
+		version.CreatedBy = user
+		return version, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), extractLimitStatus(err, http.StatusInternalServerError))
+		return
+	}
+	fmt.Printf("Imported %s into %s/%s\n", req.Path, name, versionID)
+
+	// The source is only removed once the version exists, a failed import leaves it in place. It is
+	// checked again, the import root may have been changed meanwhile.
+	if req.Move && s.withinImportRoot(source) {
+		if err := os.Remove(source); err != nil {
+			fmt.Printf("Failed to remove imported file %s: %v\n", source, err)
+		}
+	}
+	s.events.PublishBy(user, events.VersionUploaded, name, versionID, version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+// linkOrCopy hard links src to dest, or copies it when they are on different filesystems
+func linkOrCopy(src, dest string) error {
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
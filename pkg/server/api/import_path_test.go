@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ResolveImportPath(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	root := filepath.Join(dir, "bundles")
+	assert.NoError(os.MkdirAll(filepath.Join(root, "nested"), 0755))
+	bundlePath := filepath.Join(root, "bundle.zip")
+	assert.NoError(os.WriteFile(bundlePath, []byte("zip"), 0644))
+	outside := filepath.Join(dir, "outside.zip")
+	assert.NoError(os.WriteFile(outside, []byte("zip"), 0644))
+	assert.NoError(os.Symlink(outside, filepath.Join(root, "escape.zip")))
+
+	s := &Server{}
+	assert.NoError(s.SetImportRoot(root))
+
+	resolved, err := s.resolveImportPath(bundlePath)
+	assert.NoError(err)
+	expected, err := filepath.EvalSymlinks(bundlePath)
+	assert.NoError(err)
+	assert.Equal(expected, resolved)
+
+	for path, status := range map[string]int{
+		"bundle.zip":                             http.StatusBadRequest,
+		filepath.Join(root, "missing.zip"):       http.StatusBadRequest,
+		filepath.Join(root, "nested"):            http.StatusBadRequest,
+		outside:                                  http.StatusForbidden,
+		filepath.Join(root, "escape.zip"):        http.StatusForbidden,
+		filepath.Join(root, "..", "outside.zip"): http.StatusForbidden,
+	} {
+		_, err := s.resolveImportPath(path)
+		var pathErr *importPathError
+		assert.True(errors.As(err, &pathErr), path)
+		assert.Equal(status, pathErr.status, path)
+	}
+
+	// without a root every path is refused
+	assert.NoError(s.SetImportRoot(""))
+	_, err = s.resolveImportPath(bundlePath)
+	var pathErr *importPathError
+	assert.True(errors.As(err, &pathErr))
+	assert.Equal(http.StatusForbidden, pathErr.status)
+}
+
+func Test_ImportFromPathMove(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, defaultUploadLimits)
+	root := t.TempDir()
+	assert.NoError(s.SetImportRoot(root))
+	source := filepath.Join(root, "cluster.yaml")
+	assert.NoError(os.WriteFile(source, []byte("apiVersion: v1"), 0644))
+
+	body, err := json.Marshal(map[string]interface{}{"path": source, "move": true})
+	assert.NoError(err)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/from-path", bytes.NewReader(body)))
+	assert.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var version model.Version
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&version))
+	assert.Equal("v1", version.ID)
+	assert.Equal(source, version.ImportedFrom)
+	content, err := os.ReadFile(filepath.Join(s.dataDir, version.KubeconfigPath))
+	assert.NoError(err)
+	assert.Equal("apiVersion: v1", string(content))
+
+	_, err = os.Stat(source)
+	assert.True(os.IsNotExist(err), "the source is removed with move")
+}
+
+func Test_ImportFromPathKeepsSourceOnFailure(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, defaultUploadLimits)
+	root := t.TempDir()
+	assert.NoError(s.SetImportRoot(root))
+	// not a zip, the extraction fails
+	source := filepath.Join(root, "bundle.zip")
+	assert.NoError(os.WriteFile(source, []byte("not a zip"), 0644))
+
+	body, err := json.Marshal(map[string]interface{}{"path": source, "move": true})
+	assert.NoError(err)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/from-path", bytes.NewReader(body)))
+	assert.Equal(http.StatusInternalServerError, rec.Code)
+
+	assert.FileExists(source)
+	_, err = os.Stat(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	assert.True(os.IsNotExist(err))
+}
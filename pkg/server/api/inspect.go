@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// redactedValue replaces the values of environment variables looking like secrets
+const redactedValue = "[REDACTED]"
+
+// secretEnvPattern matches the names of environment variables whose values are redacted from
+// inspections, and from the container environment of workspaces and versions shown to non-admins.
+// None is set today, the extras of a workspace could carry credentials.
+var secretEnvPattern = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|pwd|credential|auth|api_?key|access_?key|private_?key|session|cookie)`)
+
+// containerInspector is the part of the docker client inspecting containers
+type containerInspector interface {
+	Inspect(instanceName string) (*docker.Inspection, error)
+}
+
+// redactEnv returns a copy of the KEY=value environment with the values of secret looking keys redacted
+func redactEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	redacted := make([]string, len(env))
+	for i, variable := range env {
+		key, _, found := strings.Cut(variable, "=")
+		if found && secretEnvPattern.MatchString(key) {
+			variable = key + "=" + redactedValue
+		}
+		redacted[i] = variable
+	}
+	return redacted
+}
+
+// redactEnvMap returns a copy of an environment by name with the values of secret looking names redacted
+func redactEnvMap(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(env))
+	for key, value := range env {
+		if secretEnvPattern.MatchString(key) {
+			value = redactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// seesSecrets returns whether the caller of a request gets container environments unredacted, only
+// admins do like with inspections. Without tokens every caller does.
+func (s *Server) seesSecrets(r *http.Request) bool {
+	if s.tokens == nil {
+		return true
+	}
+	identity, ok := auth.FromContext(r.Context())
+	return ok && identity.Role == auth.RoleAdmin
+}
+
+// redactWorkspace returns a copy of a workspace with the container environment of it and its
+// versions redacted, see redactEnvMap
+func redactWorkspace(ws model.Workspace) model.Workspace {
+	ws.ContainerEnv = redactEnvMap(ws.ContainerEnv)
+	ws.Versions = redactVersions(ws.Versions)
+	return ws
+}
+
+// redactVersions returns a copy of versions with their start environment redacted
+func redactVersions(versions []model.Version) []model.Version {
+	if versions == nil {
+		return nil
+	}
+	redacted := make([]model.Version, len(versions))
+	for i, v := range versions {
+		redacted[i] = redactVersion(v)
+	}
+	return redacted
+}
+
+// redactVersion returns a copy of a version with its start environment redacted
+func redactVersion(v model.Version) model.Version {
+	v.StartEnv = redactEnvMap(v.StartEnv)
+	return v
+}
+
+// redactStatus returns a copy of the status of a simulator with its start environment redacted
+func redactStatus(status SimulatorStatus) SimulatorStatus {
+	if status.StartParameters != nil {
+		params := *status.StartParameters
+		params.Env = redactEnvMap(params.Env)
+		status.StartParameters = &params
+	}
+	return status
+}
+
+// inspectContainer inspects the container of an instance, the environment is redacted without
+// changing the inspection of the client
+func inspectContainer(inspector containerInspector, instanceName string) (*docker.Inspection, error) {
+	inspection, err := inspector.Inspect(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	sanitized := *inspection
+	if inspection.Config != nil {
+		config := *inspection.Config
+		config.Env = redactEnv(config.Env)
+		sanitized.Config = &config
+	}
+	return &sanitized, nil
+}
+
+// handleInspectSimulator returns what docker inspect reports of the simulator container of a version
+// and its image, for maintainers debugging a simulator
+func (s *Server) handleInspectSimulator(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if version.Type == model.VersionTypeRuntime {
+		http.Error(w, "Runtime versions have no simulator container", http.StatusBadRequest)
+		return
+	}
+
+	inspection, err := inspectContainer(s.docker, version.InstanceName(name))
+	if errors.Is(err, docker.ErrContainerNotFound) {
+		http.Error(w, "The simulator has no container, start it first", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inspection)
+}
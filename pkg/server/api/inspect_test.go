@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	memstore "github.com/Yu-Jack/sim-gui/pkg/server/store/memory"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInspector inspects the containers of inspections
+type fakeInspector struct {
+	inspections map[string]*docker.Inspection
+}
+
+func (f *fakeInspector) Inspect(instanceName string) (*docker.Inspection, error) {
+	inspection, ok := f.inspections[instanceName]
+	if !ok {
+		return nil, docker.ErrContainerNotFound
+	}
+	return inspection, nil
+}
+
+func Test_InspectContainerRedactsEnv(t *testing.T) {
+	assert := require.New(t)
+	env := []string{
+		"PATH=/usr/local/bin:/usr/bin",
+		"HTTPS_PROXY=http://proxy:3128",
+		"S3_SECRET_KEY=s3cr3t",
+		"GITHUB_TOKEN=ghp_abc",
+		"db_password=hunter2",
+		"AWS_ACCESS_KEY_ID=AKIA",
+		"EMPTY_TOKEN=",
+		"NO_VALUE",
+	}
+	inspection := &docker.Inspection{ID: "abc", Name: "/ws-v1", Config: &container.Config{Image: "sim", Env: env}}
+	inspector := &fakeInspector{inspections: map[string]*docker.Inspection{"ws-v1": inspection}}
+
+	inspected, err := inspectContainer(inspector, "ws-v1")
+	assert.NoError(err)
+	assert.Equal("abc", inspected.ID)
+	assert.Equal("sim", inspected.Config.Image)
+	assert.Equal([]string{
+		"PATH=/usr/local/bin:/usr/bin",
+		"HTTPS_PROXY=http://proxy:3128",
+		"S3_SECRET_KEY=[REDACTED]",
+		"GITHUB_TOKEN=[REDACTED]",
+		"db_password=[REDACTED]",
+		"AWS_ACCESS_KEY_ID=[REDACTED]",
+		"EMPTY_TOKEN=[REDACTED]",
+		"NO_VALUE",
+	}, inspected.Config.Env)
+	assert.Equal("GITHUB_TOKEN=ghp_abc", inspection.Config.Env[3], "the inspection of the client is left as it is")
+
+	// Without a config
+	inspector.inspections["ws-v2"] = &docker.Inspection{ID: "def"}
+	inspected, err = inspectContainer(inspector, "ws-v2")
+	assert.NoError(err)
+	assert.Nil(inspected.Config)
+
+	_, err = inspectContainer(inspector, "ws-v3")
+	assert.ErrorIs(err, docker.ErrContainerNotFound)
+}
+
+func Test_RedactEnvForNonAdmins(t *testing.T) {
+	assert := require.New(t)
+	st := memstore.NewMemoryStore()
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name:         "ws",
+		CreatedAt:    time.Now(),
+		ContainerEnv: map[string]string{"HTTPS_PROXY": "http://proxy:3128", "GITHUB_TOKEN": "ghp_abc"},
+		Versions:     []model.Version{{ID: "v1", StartEnv: map[string]string{"DEBUG": "1", "DB_PASSWORD": "hunter2"}}},
+	}))
+	path := filepath.Join(t.TempDir(), auth.FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [
+		{"token": "admin-token", "user": "alice", "role": "admin"},
+		{"token": "viewer-token", "user": "victor", "role": "viewer"}
+	]}`), 0600))
+	tokens, err := auth.Load(path)
+	assert.NoError(err)
+
+	s := &Server{store: st}
+	mux := http.NewServeMux()
+	for _, rt := range []route{
+		{Method: "GET", Path: "/api/workspaces/{name}", handler: s.handleGetWorkspace},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions", handler: s.handleListVersions},
+	} {
+		mux.HandleFunc(rt.Method+" "+rt.Path, s.authorize(rt, rt.handler))
+	}
+	getWorkspace := func(token string) model.Workspace {
+		r := httptest.NewRequest("GET", "/api/workspaces/ws", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+		var ws model.Workspace
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &ws))
+		return ws
+	}
+	getVersions := func(token string) []model.Version {
+		r := httptest.NewRequest("GET", "/api/workspaces/ws/versions", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		assert.Equal(http.StatusOK, w.Code, w.Body.String())
+		var versions []model.Version
+		assert.NoError(json.Unmarshal(w.Body.Bytes(), &versions))
+		return versions
+	}
+
+	// Without tokens and for admins the environment is left as it is
+	for _, token := range []string{"", "admin-token"} {
+		if token != "" {
+			s.SetTokens(tokens)
+		}
+		ws := getWorkspace(token)
+		assert.Equal("ghp_abc", ws.ContainerEnv["GITHUB_TOKEN"])
+		assert.Equal("hunter2", ws.Versions[0].StartEnv["DB_PASSWORD"])
+		assert.Equal("hunter2", getVersions(token)[0].StartEnv["DB_PASSWORD"])
+	}
+
+	ws := getWorkspace("viewer-token")
+	assert.Equal(map[string]string{"HTTPS_PROXY": "http://proxy:3128", "GITHUB_TOKEN": "[REDACTED]"}, ws.ContainerEnv)
+	assert.Equal(map[string]string{"DEBUG": "1", "DB_PASSWORD": "[REDACTED]"}, ws.Versions[0].StartEnv)
+	assert.Equal(map[string]string{"DEBUG": "1", "DB_PASSWORD": "[REDACTED]"}, getVersions("viewer-token")[0].StartEnv)
+	stored, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal("ghp_abc", stored.ContainerEnv["GITHUB_TOKEN"], "the stored workspace is left as it is")
+
+	status := redactStatus(SimulatorStatus{StartParameters: &StartParameters{Env: map[string]string{"API_KEY": "k"}}})
+	assert.Equal(map[string]string{"API_KEY": "[REDACTED]"}, status.StartParameters.Env)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
+)
+
+// jobManager returns the jobs, servers built without NewServer keep them in memory only
+func (s *Server) jobManager() *jobs.Manager {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+	if s.jobs == nil {
+		// In memory never fails
+		s.jobs, _ = jobs.NewManager("", jobs.DefaultRetention)
+		s.jobs.OnChange(s.publishJob)
+	}
+	return s.jobs
+}
+
+// publishJob announces a started, changed or finished job
+func (s *Server) publishJob(job jobs.Job) {
+	s.events.Publish(events.JobUpdated, job.Workspace, job.VersionID, job)
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	list := s.jobManager().List(jobs.Filter{
+		Workspace: r.URL.Query().Get("workspace"),
+		Kind:      r.URL.Query().Get("kind"),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobManager().Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobManager().Cancel(r.PathValue("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	// The job stops at the next point it can, it tells when it did
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
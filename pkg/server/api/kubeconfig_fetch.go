@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/kubeconfig"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/docker/docker/api/types"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// simKubeconfigPath is where the simulator writes the kubeconfig of its apiserver
+	simKubeconfigPath = "/root/.sim/admin.kubeconfig"
+	// kubeconfigStepTimeout bounds each docker call fetching the kubeconfig of a simulator, a wedged
+	// container otherwise holds the request until the client gives up
+	kubeconfigStepTimeout = 10 * time.Second
+	// maxKubeconfigBytes caps the kubeconfig read from a container, the simulator's is a few KB
+	maxKubeconfigBytes = 1 << 20
+	// kubeconfigInitWait is how long the kubeconfig of a simulator that just started is waited for, it
+	// is written a few seconds after the container started
+	kubeconfigInitWait = 5 * time.Second
+	// kubeconfigRetryBackoff is the first pause between the reads of a kubeconfig not written yet
+	kubeconfigRetryBackoff = 100 * time.Millisecond
+	// kubeconfigRetryAfter is the Retry-After of a kubeconfig request to a simulator still initializing, in seconds
+	kubeconfigRetryAfter = "3"
+)
+
+var (
+	// errSimulatorNotRunning is returned when the kubeconfig of a simulator without a running container is fetched
+	errSimulatorNotRunning = errors.New("simulator not running")
+	// errSimulatorInitializing is returned when the simulator didn't write its kubeconfig within kubeconfigInitWait
+	errSimulatorInitializing = errors.New("simulator is still initializing, try again shortly")
+)
+
+// KubeconfigPendingResponse answers a kubeconfig request to a simulator that didn't write it yet
+type KubeconfigPendingResponse struct {
+	Message string `json:"message"`
+	// Ready tells whether the simulator loaded its bundle, the kubeconfig is written before that
+	Ready bool `json:"ready"`
+}
+
+// kubeconfigSource is the part of the docker client fetching the kubeconfig of a simulator needs
+type kubeconfigSource interface {
+	FindRunningContainerContext(ctx context.Context, instanceName string) ([]types.Container, error)
+	ReadFileContext(ctx context.Context, instanceName, path string, maxBytes int64) ([]byte, error)
+	QueryExposedMappingContext(ctx context.Context, instanceName string) (string, string, error)
+}
+
+// kubeconfigTimeoutError is returned when a step fetching the kubeconfig of a simulator times out
+type kubeconfigTimeoutError struct {
+	Step    string
+	Timeout time.Duration
+}
+
+func (e *kubeconfigTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Step, e.Timeout)
+}
+
+// kubeconfigStep runs fn with a context that expires after timeout, an error caused by the expiry is
+// returned as a *kubeconfigTimeoutError. The request being cancelled is returned as is.
+func kubeconfigStep(ctx context.Context, step string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(stepCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		return &kubeconfigTimeoutError{Step: step, Timeout: timeout}
+	}
+	return err
+}
+
+// kubeconfigFetchStatus returns the status code a fetchSimulatorKubeconfig error is reported with
+func kubeconfigFetchStatus(err error) int {
+	var timeoutErr *kubeconfigTimeoutError
+	switch {
+	case errors.Is(err, errSimulatorNotRunning), errors.Is(err, errSimulatorInitializing):
+		return http.StatusConflict
+	case errors.As(err, &timeoutErr):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// fetchSimulatorKubeconfig reads the kubeconfig of a running simulator and points it at the exposed
+// apiserver. Reading it is retried once while the container still runs, the copy fails when it races
+// a restart of the container. A kubeconfig not written yet is waited for up to initWait, then
+// errSimulatorInitializing is returned.
+func fetchSimulatorKubeconfig(ctx context.Context, src kubeconfigSource, instanceName string, timeout, initWait time.Duration) (*api.Config, error) {
+	running := func() (bool, error) {
+		var containers []types.Container
+		err := kubeconfigStep(ctx, "listing the containers", timeout, func(ctx context.Context) (err error) {
+			containers, err = src.FindRunningContainerContext(ctx, instanceName)
+			return err
+		})
+		return len(containers) > 0, err
+	}
+	read := func() ([]byte, error) {
+		return docker.ReadFileRetry(ctx, initWait, kubeconfigRetryBackoff, func() (content []byte, err error) {
+			err = kubeconfigStep(ctx, "reading the kubeconfig", timeout, func(ctx context.Context) error {
+				content, err = src.ReadFileContext(ctx, instanceName, simKubeconfigPath, maxKubeconfigBytes)
+				return err
+			})
+			return content, err
+		})
+	}
+
+	ok, err := running()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errSimulatorNotRunning
+	}
+
+	content, err := read()
+	var timeoutErr *kubeconfigTimeoutError
+	var tooLarge *docker.FileTooLargeError
+	if errors.Is(err, docker.ErrFileNotFound) {
+		return nil, errSimulatorInitializing
+	}
+	if err != nil && !errors.As(err, &timeoutErr) && !errors.As(err, &tooLarge) && ctx.Err() == nil {
+		fmt.Printf("Failed to read the kubeconfig of %s, retrying: %v\n", instanceName, err)
+		if ok, runErr := running(); runErr != nil {
+			return nil, runErr
+		} else if !ok {
+			return nil, errSimulatorNotRunning
+		}
+		content, err = read()
+	}
+	if errors.Is(err, docker.ErrFileNotFound) {
+		return nil, errSimulatorInitializing
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+
+	var endpoint, port string
+	err = kubeconfigStep(ctx, "querying the exposed mapping", timeout, func(ctx context.Context) (err error) {
+		endpoint, port, err = src.QueryExposedMappingContext(ctx, instanceName)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exposed mapping: %w", err)
+	}
+
+	return kubeconfig.ConfigureKubeConfig(content, instanceName, endpoint, port)
+}
+
+// versionKubeconfig returns the kubeconfig of a version named after its instance, the one of a runtime
+// version is read from the data directory. Simulators that aren't running return errSimulatorNotRunning.
+func (s *Server) versionKubeconfig(ctx context.Context, workspace string, version model.Version) (*api.Config, error) {
+	instanceName := version.InstanceName(workspace)
+	if version.Type == model.VersionTypeRuntime {
+		content, err := os.ReadFile(s.dataPath(version.KubeconfigPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+		}
+		return kubeconfig.ConfigureRuntimeKubeConfig(content, instanceName)
+	}
+	return fetchSimulatorKubeconfig(ctx, s.docker, instanceName, kubeconfigStepTimeout, kubeconfigInitWait)
+}
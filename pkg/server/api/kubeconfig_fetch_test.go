@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testSimKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: default
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: default
+  context:
+    cluster: default
+    user: default
+current-context: default
+users:
+- name: default
+  user:
+    token: secret
+`
+
+// fakeKubeconfigSource plays the docker client, a step set in slow blocks until its context is done
+type fakeKubeconfigSource struct {
+	running bool
+	slow    string
+	// readErrs are returned by the reads in turn before the kubeconfig is
+	readErrs []error
+	reads    int
+}
+
+func (f *fakeKubeconfigSource) wait(ctx context.Context, step string) error {
+	if f.slow != step {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *fakeKubeconfigSource) FindRunningContainerContext(ctx context.Context, instanceName string) ([]types.Container, error) {
+	if err := f.wait(ctx, "list"); err != nil {
+		return nil, err
+	}
+	if !f.running {
+		return nil, nil
+	}
+	return []types.Container{{ID: "c1"}}, nil
+}
+
+func (f *fakeKubeconfigSource) ReadFileContext(ctx context.Context, instanceName, path string, maxBytes int64) ([]byte, error) {
+	f.reads++
+	if err := f.wait(ctx, "read"); err != nil {
+		return nil, err
+	}
+	if f.reads <= len(f.readErrs) {
+		return nil, f.readErrs[f.reads-1]
+	}
+	return []byte(testSimKubeconfig), nil
+}
+
+func (f *fakeKubeconfigSource) QueryExposedMappingContext(ctx context.Context, instanceName string) (string, string, error) {
+	if err := f.wait(ctx, "mapping"); err != nil {
+		return "", "", err
+	}
+	return "localhost", "32768", nil
+}
+
+func Test_FetchSimulatorKubeconfig(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+
+	src := &fakeKubeconfigSource{running: true}
+	config, err := fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, time.Second)
+	assert.NoError(err)
+	assert.Equal("https://localhost:32768", config.Clusters["ws-v1"].Server)
+
+	_, err = fetchSimulatorKubeconfig(ctx, &fakeKubeconfigSource{}, "ws-v1", time.Second, time.Second)
+	assert.ErrorIs(err, errSimulatorNotRunning)
+	assert.Equal(http.StatusConflict, kubeconfigFetchStatus(err))
+
+	// a copy racing a restart of the container is retried once
+	src = &fakeKubeconfigSource{running: true, readErrs: []error{errors.New("container c1 is not running")}}
+	_, err = fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, time.Second)
+	assert.NoError(err)
+	assert.Equal(2, src.reads)
+
+	src = &fakeKubeconfigSource{running: true, readErrs: []error{errors.New("restarting"), errors.New("restarting")}}
+	_, err = fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, time.Second)
+	assert.ErrorContains(err, "failed to read kubeconfig: restarting")
+	assert.Equal(http.StatusInternalServerError, kubeconfigFetchStatus(err))
+
+	// a kubeconfig not written yet is waited for
+	notWritten := fmt.Errorf("error reading file %s: %w", simKubeconfigPath, docker.ErrFileNotFound)
+	src = &fakeKubeconfigSource{running: true, readErrs: []error{notWritten, notWritten}}
+	config, err = fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, time.Second)
+	assert.NoError(err)
+	assert.Equal("https://localhost:32768", config.Clusters["ws-v1"].Server)
+	assert.Equal(3, src.reads)
+
+	src = &fakeKubeconfigSource{running: true, readErrs: []error{notWritten, notWritten, notWritten, notWritten, notWritten, notWritten}}
+	_, err = fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, 10*time.Millisecond)
+	assert.ErrorIs(err, errSimulatorInitializing)
+	assert.Equal(http.StatusConflict, kubeconfigFetchStatus(err))
+
+	// an oversized file isn't read again
+	src = &fakeKubeconfigSource{running: true, readErrs: []error{&docker.FileTooLargeError{Path: simKubeconfigPath, Size: 5 << 30, Limit: maxKubeconfigBytes}}}
+	_, err = fetchSimulatorKubeconfig(ctx, src, "ws-v1", time.Second, time.Second)
+	var tooLarge *docker.FileTooLargeError
+	assert.ErrorAs(err, &tooLarge)
+	assert.Equal(1, src.reads)
+}
+
+func Test_FetchSimulatorKubeconfigTimeouts(t *testing.T) {
+	assert := require.New(t)
+	for step, name := range map[string]string{
+		"list":    "listing the containers",
+		"read":    "reading the kubeconfig",
+		"mapping": "querying the exposed mapping",
+	} {
+		src := &fakeKubeconfigSource{running: true, slow: step}
+		_, err := fetchSimulatorKubeconfig(context.Background(), src, "ws-v1", 10*time.Millisecond, time.Second)
+		var timeoutErr *kubeconfigTimeoutError
+		assert.ErrorAs(err, &timeoutErr, step)
+		assert.Equal(name, timeoutErr.Step)
+		assert.Equal(http.StatusGatewayTimeout, kubeconfigFetchStatus(err))
+		if step == "read" {
+			assert.Equal(1, src.reads, "expected a timed out read not to be retried")
+		}
+	}
+
+	// a request cancelled by the client isn't a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := fetchSimulatorKubeconfig(ctx, &fakeKubeconfigSource{running: true, slow: "list"}, "ws-v1", time.Second, time.Second)
+	assert.ErrorIs(err, context.Canceled)
+}
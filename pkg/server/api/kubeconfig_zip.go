@@ -0,0 +1,95 @@
+package api
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// kubeconfigManifest lists the instances whose kubeconfig couldn't be added to the zip
+const kubeconfigManifest = "manifest.txt"
+
+// writeKubeconfigZip streams a zip with the kubeconfig of each running version, fetched one at a time
+// so only one is held in memory. Versions that aren't running are left out and those that failed are
+// listed in manifest.txt. Nothing is written and false is returned when no version is running.
+func writeKubeconfigZip(w http.ResponseWriter, workspace string, versions []model.Version, fetch func(v model.Version) (*api.Config, error)) (bool, error) {
+	var zw *zip.Writer
+	var failed []string
+	for _, v := range versions {
+		instanceName := v.InstanceName(workspace)
+		config, err := fetch(v)
+		if errors.Is(err, errSimulatorNotRunning) {
+			continue
+		}
+		if zw == nil {
+			// The zip is only started once there is something to put in it, the status can't change after
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-kubeconfigs.zip\"", workspace))
+			zw = zip.NewWriter(w)
+		}
+
+		var data []byte
+		if err == nil {
+			data, err = clientcmd.Write(*config)
+		}
+		if err != nil {
+			fmt.Printf("Failed to add the kubeconfig of %s to the zip: %v\n", instanceName, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", instanceName, err))
+			continue
+		}
+		f, err := zw.Create(instanceName + ".kubeconfig")
+		if err != nil {
+			return true, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return true, err
+		}
+	}
+	if zw == nil {
+		return false, nil
+	}
+
+	if len(failed) > 0 {
+		f, err := zw.Create(kubeconfigManifest)
+		if err != nil {
+			return true, err
+		}
+		fmt.Fprintln(f, "Kubeconfigs of these instances couldn't be added:")
+		for _, line := range failed {
+			fmt.Fprintln(f, line)
+		}
+	}
+	return true, zw.Close()
+}
+
+func (s *Server) handleExportWorkspaceKubeconfigsZip(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	// merge=true returns the same single merged file as GET /api/workspaces/{name}/kubeconfig
+	if r.URL.Query().Get("merge") == "true" {
+		s.handleExportWorkspaceKubeconfig(w, r)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	started, err := writeKubeconfigZip(w, name, orderedVersions(ws.Versions), func(v model.Version) (*api.Config, error) {
+		return s.versionKubeconfig(r.Context(), name, v)
+	})
+	if !started {
+		http.Error(w, "No running versions found", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		// The response already started, the client is left with a truncated zip
+		fmt.Printf("Failed to write the kubeconfigs zip of %s: %v\n", name, err)
+	}
+}
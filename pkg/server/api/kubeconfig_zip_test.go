@@ -0,0 +1,66 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_WriteKubeconfigZip(t *testing.T) {
+	assert := require.New(t)
+	versions := []model.Version{{ID: "v1"}, {ID: "v2"}, {ID: "v3"}, {ID: "v4", Type: model.VersionTypeRuntime}}
+	fetch := func(v model.Version) (*api.Config, error) {
+		switch v.ID {
+		case "v2":
+			return nil, errSimulatorNotRunning
+		case "v3":
+			return nil, &kubeconfigTimeoutError{Step: "reading the kubeconfig", Timeout: kubeconfigStepTimeout}
+		}
+		config := api.NewConfig()
+		config.Clusters["ws-"+v.ID] = &api.Cluster{Server: "https://localhost:32768"}
+		return config, nil
+	}
+
+	rec := httptest.NewRecorder()
+	started, err := writeKubeconfigZip(rec, "ws", versions, fetch)
+	assert.NoError(err)
+	assert.True(started)
+	assert.Equal("application/zip", rec.Header().Get("Content-Type"))
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(err)
+		content, err := io.ReadAll(r)
+		assert.NoError(err)
+		files[f.Name] = string(content)
+	}
+	assert.Len(files, 3, "expected the stopped v2 to be left out")
+	assert.Contains(files["ws-v1.kubeconfig"], "server: https://localhost:32768")
+	assert.Contains(files["ws-v4.kubeconfig"], "ws-v4")
+	assert.Contains(files[kubeconfigManifest], "ws-v3: reading the kubeconfig timed out after 10s")
+
+	// nothing running leaves the response to the caller
+	rec = httptest.NewRecorder()
+	started, err = writeKubeconfigZip(rec, "ws", versions[1:2], fetch)
+	assert.NoError(err)
+	assert.False(started)
+	assert.Zero(rec.Body.Len())
+	assert.Empty(rec.Header().Get("Content-Type"))
+
+	// without failures there is no manifest
+	rec = httptest.NewRecorder()
+	_, err = writeKubeconfigZip(rec, "ws", versions[:1], fetch)
+	assert.NoError(err)
+	zr, err = zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(err)
+	assert.Len(zr.File, 1)
+}
@@ -3,8 +3,9 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
-
+	"slices"
 	"strings"
 
 	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
@@ -16,19 +17,31 @@ type LiveMigrationCheckResult struct {
 	NodeSelector              map[string]string         `json:"nodeSelector,omitempty"`
 	NodeResults               []NodeCompatibilityResult `json:"nodeResults"`
 	NodeToNodeCompatibilities []NodeToNodeCompatibility `json:"nodeToNodeCompatibilities"`
-	Error                     string                    `json:"error,omitempty"`
+	// WhatIf are the hypothetical node changes the results were evaluated with
+	WhatIf []NodeChange `json:"whatIf,omitempty"`
+	Error  string       `json:"error,omitempty"`
 }
 
 type NodeToNodeCompatibility struct {
 	SourceNode    string         `json:"sourceNode"`
 	TargetNode    string         `json:"targetNode"`
 	MissingLabels []MissingLabel `json:"missingLabels"`
+	// Changed marks the pairs the what-if changes made differ from the cluster, a pair they made
+	// compatible is listed without missing labels
+	Changed               bool           `json:"changed,omitempty"`
+	BaselineMissingLabels []MissingLabel `json:"baselineMissingLabels,omitempty"`
 }
 
 type NodeCompatibilityResult struct {
 	NodeName      string         `json:"nodeName"`
 	Matches       bool           `json:"matches"`
 	MissingLabels []MissingLabel `json:"missingLabels"`
+	// UntoleratedTaints are the NoSchedule and NoExecute taints of the node the pod doesn't tolerate
+	UntoleratedTaints []Taint `json:"untoleratedTaints,omitempty"`
+	// Changed marks the nodes whose verdict the what-if changes flipped, BaselineMatches is the verdict
+	// of the cluster as it is. Both are only set with what-if changes.
+	Changed         bool  `json:"changed,omitempty"`
+	BaselineMatches *bool `json:"baselineMatches,omitempty"`
 }
 
 type MissingLabel struct {
@@ -36,9 +49,39 @@ type MissingLabel struct {
 	Value string `json:"value"`
 }
 
+// Taint of a node, the effects keeping pods off it are NoSchedule and NoExecute
+type Taint struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value,omitempty" yaml:"value"`
+	Effect string `json:"effect" yaml:"effect"`
+}
+
+// Toleration of a pod, an empty key with the Exists operator tolerates every taint
+type Toleration struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"` // "Exists" or "Equal", the default
+	Value    string `yaml:"value"`
+	Effect   string `yaml:"effect"` // empty tolerates every effect
+}
+
+// tolerates reports whether the toleration matches a taint, like the scheduler does
+func (t Toleration) tolerates(taint Taint) bool {
+	if t.Effect != "" && t.Effect != taint.Effect {
+		return false
+	}
+	if t.Key == "" {
+		return t.Operator == "Exists"
+	}
+	if t.Key != taint.Key {
+		return false
+	}
+	return t.Operator == "Exists" || t.Value == taint.Value
+}
+
 type PodSpec struct {
 	Spec struct {
 		NodeSelector map[string]string `yaml:"nodeSelector"`
+		Tolerations  []Toleration      `yaml:"tolerations"`
 	} `yaml:"spec"`
 	Metadata struct {
 		Name      string `yaml:"name"`
@@ -52,16 +95,63 @@ type NodeList struct {
 			Name   string            `yaml:"name"`
 			Labels map[string]string `yaml:"labels"`
 		} `yaml:"metadata"`
+		Spec struct {
+			Taints []Taint `yaml:"taints"`
+		} `yaml:"spec"`
 	} `yaml:"items"`
 }
 
+// NodeChange is a hypothetical change of a node a live migration check is evaluated with, nothing is
+// changed in the cluster. Labels are removed before they are added, and so are taints.
+type NodeChange struct {
+	Node         string            `json:"node"`
+	AddLabels    map[string]string `json:"addLabels,omitempty"`
+	RemoveLabels []string          `json:"removeLabels,omitempty"`
+	AddTaints    []Taint           `json:"addTaints,omitempty"`
+	// RemoveTaints are matched by key and effect, an empty effect removes the taints of the key with any effect
+	RemoveTaints []Taint `json:"removeTaints,omitempty"`
+}
+
+// taintEffects are the effects a taint can have
+var taintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+// validate checks the taints of a change, the nodes are checked once they are listed
+func (c NodeChange) validate() error {
+	if c.Node == "" {
+		return fmt.Errorf("what-if changes need a node")
+	}
+	for _, taint := range c.AddTaints {
+		if taint.Key == "" || !slices.Contains(taintEffects, taint.Effect) {
+			return fmt.Errorf("taint %q of node %s needs a key and one of the effects %s", taint.Key, c.Node, strings.Join(taintEffects, ", "))
+		}
+	}
+	for _, taint := range c.RemoveTaints {
+		if taint.Key == "" || (taint.Effect != "" && !slices.Contains(taintEffects, taint.Effect)) {
+			return fmt.Errorf("taint %q of node %s to remove needs a key and an effect among %s, or none", taint.Key, c.Node, strings.Join(taintEffects, ", "))
+		}
+	}
+	return nil
+}
+
+// migrationNode is what a live migration check evaluates of a node
+type migrationNode struct {
+	Name   string
+	Labels map[string]string
+	Taints []Taint
+}
+
+// liveMigrationRequest is the body of a live migration check
+type liveMigrationRequest struct {
+	VersionID string `json:"versionID"`
+	Namespace string `json:"namespace"`
+	PodName   string `json:"podName"`
+	// WhatIf are hypothetical node changes, the verdicts they flip are marked as changed
+	WhatIf []NodeChange `json:"whatIf,omitempty"`
+}
+
 func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	var req struct {
-		VersionID string `json:"versionID"`
-		Namespace string `json:"namespace"`
-		PodName   string `json:"podName"`
-	}
+	var req liveMigrationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -71,6 +161,12 @@ func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request
 		http.Error(w, "versionID, namespace and podName are required", http.StatusBadRequest)
 		return
 	}
+	for _, change := range req.WhatIf {
+		if err := change.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	ws, err := s.store.GetWorkspace(name)
 	if err != nil {
@@ -95,19 +191,14 @@ func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get pod spec
-	podYAML, stderr, err := utils.ExecKubectl(exec, "get", "pod", req.PodName, "-n", req.Namespace, "-o", "yaml")
-	if err != nil {
-		result := LiveMigrationCheckResult{
-			Error: fmt.Sprintf("Failed to get pod: %v", err),
+	out, err := utils.ExecKubectl(exec, "get", "pod", req.PodName, "-n", req.Namespace, "-o", "yaml")
+	if status, err := utils.KubectlStatus(out, err); err != nil {
+		message := fmt.Sprintf("Failed to get pod: %v", err)
+		if status == utils.KubectlNotFound {
+			message = fmt.Sprintf("Pod not found: %v", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
-		return
-	}
-
-	if stderr != "" {
 		result := LiveMigrationCheckResult{
-			Error: fmt.Sprintf("Pod not found: %s", stderr),
+			Error: message,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
@@ -115,7 +206,7 @@ func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request
 	}
 
 	var pod PodSpec
-	if err := yaml.Unmarshal([]byte(podYAML), &pod); err != nil {
+	if err := yaml.Unmarshal([]byte(out.Stdout), &pod); err != nil {
 		result := LiveMigrationCheckResult{
 			Error: fmt.Sprintf("Failed to parse pod spec: %v", err),
 		}
@@ -124,8 +215,9 @@ func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Get all nodes
-	nodesYAML, stderr, err := utils.ExecKubectl(exec, "get", "nodes", "-o", "yaml")
+	// Get all nodes, node objects carry many labels and annotations so decode while streamed
+	var nodeList NodeList
+	stderr, err := utils.DecodeKubectlYAML(r.Context(), exec, &nodeList, "get", "nodes", "-o", "yaml")
 	if err != nil {
 		result := LiveMigrationCheckResult{
 			Error: fmt.Sprintf("Failed to get nodes: %v", err),
@@ -144,63 +236,179 @@ func (s *Server) handleCheckLiveMigration(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var nodeList NodeList
-	if err := yaml.Unmarshal([]byte(nodesYAML), &nodeList); err != nil {
-		result := LiveMigrationCheckResult{
-			Error: fmt.Sprintf("Failed to parse nodes: %v", err),
+	nodes := make([]migrationNode, 0, len(nodeList.Items))
+	for _, item := range nodeList.Items {
+		nodes = append(nodes, migrationNode{Name: item.Metadata.Name, Labels: item.Metadata.Labels, Taints: item.Spec.Taints})
+	}
+	result := LiveMigrationCheckResult{
+		PodName:      pod.Metadata.Name,
+		NodeSelector: pod.Spec.NodeSelector,
+	}
+	result.NodeResults, result.NodeToNodeCompatibilities = evaluateLiveMigration(pod.Spec.NodeSelector, pod.Spec.Tolerations, nodes)
+
+	if len(req.WhatIf) > 0 {
+		changed, err := applyNodeChanges(nodes, req.WhatIf)
+		if err != nil {
+			result = LiveMigrationCheckResult{Error: err.Error()}
+		} else {
+			nodeResults, nodeToNodeResults := evaluateLiveMigration(pod.Spec.NodeSelector, pod.Spec.Tolerations, changed)
+			result.NodeResults = markNodeChanges(result.NodeResults, nodeResults)
+			result.NodeToNodeCompatibilities = markNodeToNodeChanges(result.NodeToNodeCompatibilities, nodeToNodeResults)
+			result.WhatIf = req.WhatIf
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
-		return
 	}
 
-	// Check compatibility for each node
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// evaluateLiveMigration checks on which nodes the pod can run, and which nodes lack the
+// *.node.kubevirt.io/* labels of another node a virtual machine would be migrated from
+func evaluateLiveMigration(nodeSelector map[string]string, tolerations []Toleration, nodes []migrationNode) ([]NodeCompatibilityResult, []NodeToNodeCompatibility) {
 	var nodeResults []NodeCompatibilityResult
-	for _, node := range nodeList.Items {
-		compatibility := checkNodeCompatibility(pod.Spec.NodeSelector, node.Metadata.Labels)
+	for _, node := range nodes {
+		compatibility := checkNodeCompatibility(nodeSelector, node.Labels)
+		untolerated := untoleratedTaints(tolerations, node.Taints)
 		nodeResults = append(nodeResults, NodeCompatibilityResult{
-			NodeName:      node.Metadata.Name,
-			Matches:       compatibility.Matches,
-			MissingLabels: compatibility.MissingLabels,
+			NodeName:          node.Name,
+			Matches:           compatibility.Matches && len(untolerated) == 0,
+			MissingLabels:     compatibility.MissingLabels,
+			UntoleratedTaints: untolerated,
 		})
 	}
 
-	// Check node-to-node compatibility for *.node.kubevirt.io/* labels
 	var nodeToNodeResults []NodeToNodeCompatibility
-	for _, sourceNode := range nodeList.Items {
-		for _, targetNode := range nodeList.Items {
-			if sourceNode.Metadata.Name == targetNode.Metadata.Name {
+	for _, sourceNode := range nodes {
+		for _, targetNode := range nodes {
+			if sourceNode.Name == targetNode.Name {
 				continue
 			}
 
 			var missing []MissingLabel
-			for k, v := range sourceNode.Metadata.Labels {
+			for k, v := range sourceNode.Labels {
 				if strings.Contains(k, "node.kubevirt.io") {
-					if targetVal, ok := targetNode.Metadata.Labels[k]; !ok || targetVal != v {
+					if targetVal, ok := targetNode.Labels[k]; !ok || targetVal != v {
 						missing = append(missing, MissingLabel{Key: k, Value: v})
 					}
 				}
 			}
 
 			if len(missing) > 0 {
+				sortMissingLabels(missing)
 				nodeToNodeResults = append(nodeToNodeResults, NodeToNodeCompatibility{
-					SourceNode:    sourceNode.Metadata.Name,
-					TargetNode:    targetNode.Metadata.Name,
+					SourceNode:    sourceNode.Name,
+					TargetNode:    targetNode.Name,
 					MissingLabels: missing,
 				})
 			}
 		}
 	}
+	return nodeResults, nodeToNodeResults
+}
 
-	result := LiveMigrationCheckResult{
-		PodName:                   pod.Metadata.Name,
-		NodeSelector:              pod.Spec.NodeSelector,
-		NodeResults:               nodeResults,
-		NodeToNodeCompatibilities: nodeToNodeResults,
+// untoleratedTaints returns the taints keeping the pod off a node, PreferNoSchedule only discourages it
+func untoleratedTaints(tolerations []Toleration, taints []Taint) []Taint {
+	var untolerated []Taint
+	for _, taint := range taints {
+		if taint.Effect == "PreferNoSchedule" {
+			continue
+		}
+		if !slices.ContainsFunc(tolerations, func(t Toleration) bool { return t.tolerates(taint) }) {
+			untolerated = append(untolerated, taint)
+		}
 	}
+	return untolerated
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+// applyNodeChanges returns copies of the nodes with the what-if changes applied, the nodes are left as
+// they are. A change of a node that isn't listed is an error.
+func applyNodeChanges(nodes []migrationNode, changes []NodeChange) ([]migrationNode, error) {
+	changed := make([]migrationNode, len(nodes))
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		changed[i] = migrationNode{Name: node.Name, Labels: maps.Clone(node.Labels), Taints: slices.Clone(node.Taints)}
+		if changed[i].Labels == nil {
+			changed[i].Labels = make(map[string]string)
+		}
+		index[node.Name] = i
+	}
+
+	for _, change := range changes {
+		i, ok := index[change.Node]
+		if !ok {
+			return nil, fmt.Errorf("what-if node %s not found", change.Node)
+		}
+		node := &changed[i]
+		for _, key := range change.RemoveLabels {
+			delete(node.Labels, key)
+		}
+		maps.Copy(node.Labels, change.AddLabels)
+		for _, removed := range change.RemoveTaints {
+			node.Taints = slices.DeleteFunc(node.Taints, func(taint Taint) bool {
+				return taint.Key == removed.Key && (removed.Effect == "" || taint.Effect == removed.Effect)
+			})
+		}
+		for _, added := range change.AddTaints {
+			// A taint is unique by key and effect, adding it again replaces its value
+			node.Taints = slices.DeleteFunc(node.Taints, func(taint Taint) bool {
+				return taint.Key == added.Key && taint.Effect == added.Effect
+			})
+			node.Taints = append(node.Taints, added)
+		}
+	}
+	return changed, nil
+}
+
+// markNodeChanges marks the what-if verdicts that differ from the baseline ones
+func markNodeChanges(baseline, whatIf []NodeCompatibilityResult) []NodeCompatibilityResult {
+	for i := range whatIf {
+		for _, base := range baseline {
+			if base.NodeName == whatIf[i].NodeName {
+				matches := base.Matches
+				whatIf[i].BaselineMatches = &matches
+				whatIf[i].Changed = base.Matches != whatIf[i].Matches
+			}
+		}
+	}
+	return whatIf
+}
+
+// markNodeToNodeChanges marks the what-if node pairs whose missing labels differ from the baseline
+// ones, pairs the changes made compatible are added without missing labels
+func markNodeToNodeChanges(baseline, whatIf []NodeToNodeCompatibility) []NodeToNodeCompatibility {
+	type pair struct{ source, target string }
+	before := make(map[pair][]MissingLabel, len(baseline))
+	for _, base := range baseline {
+		before[pair{base.SourceNode, base.TargetNode}] = base.MissingLabels
+	}
+
+	var marked []NodeToNodeCompatibility
+	for _, result := range whatIf {
+		key := pair{result.SourceNode, result.TargetNode}
+		if missing, ok := before[key]; !ok || !slices.Equal(missing, result.MissingLabels) {
+			result.Changed = true
+			result.BaselineMissingLabels = missing
+		}
+		delete(before, key)
+		marked = append(marked, result)
+	}
+	for _, base := range baseline {
+		if missing, ok := before[pair{base.SourceNode, base.TargetNode}]; ok {
+			marked = append(marked, NodeToNodeCompatibility{
+				SourceNode:            base.SourceNode,
+				TargetNode:            base.TargetNode,
+				MissingLabels:         []MissingLabel{},
+				Changed:               true,
+				BaselineMissingLabels: missing,
+			})
+		}
+	}
+	return marked
+}
+
+// sortMissingLabels orders missing labels by key, they are collected from maps
+func sortMissingLabels(missing []MissingLabel) {
+	slices.SortFunc(missing, func(a, b MissingLabel) int { return strings.Compare(a.Key, b.Key) })
 }
 
 type CompatibilityCheck struct {
@@ -221,6 +429,7 @@ func checkNodeCompatibility(nodeSelector map[string]string, nodeLabels map[strin
 		}
 	}
 
+	sortMissingLabels(missingLabels)
 	return CompatibilityCheck{
 		Matches:       len(missingLabels) == 0,
 		MissingLabels: missingLabels,
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const hostModelLabel = "host-model-cpu.node.kubevirt.io/Skylake"
+
+// migrationNodes are two nodes of which node-b lacks the CPU model of node-a, node-c is tainted
+func migrationNodes() []migrationNode {
+	return []migrationNode{
+		{Name: "node-a", Labels: map[string]string{"zone": "a", hostModelLabel: "true"}},
+		{Name: "node-b", Labels: map[string]string{"zone": "b"}},
+		{Name: "node-c", Labels: map[string]string{"zone": "a", hostModelLabel: "true"}, Taints: []Taint{{Key: "maintenance", Effect: "NoSchedule"}}},
+	}
+}
+
+func Test_UntoleratedTaints(t *testing.T) {
+	noSchedule := Taint{Key: "maintenance", Value: "true", Effect: "NoSchedule"}
+	for _, tc := range []struct {
+		name        string
+		tolerations []Toleration
+		taints      []Taint
+		untolerated []Taint
+	}{
+		{name: "no taints"},
+		{name: "not tolerated", taints: []Taint{noSchedule}, untolerated: []Taint{noSchedule}},
+		{name: "prefer no schedule allows the pod", taints: []Taint{{Key: "busy", Effect: "PreferNoSchedule"}}},
+		{name: "equal value", tolerations: []Toleration{{Key: "maintenance", Value: "true"}}, taints: []Taint{noSchedule}},
+		{name: "other value", tolerations: []Toleration{{Key: "maintenance", Value: "false"}}, taints: []Taint{noSchedule}, untolerated: []Taint{noSchedule}},
+		{name: "exists", tolerations: []Toleration{{Key: "maintenance", Operator: "Exists"}}, taints: []Taint{noSchedule}},
+		{name: "other effect", tolerations: []Toleration{{Key: "maintenance", Operator: "Exists", Effect: "NoExecute"}}, taints: []Taint{noSchedule}, untolerated: []Taint{noSchedule}},
+		{name: "everything", tolerations: []Toleration{{Operator: "Exists"}}, taints: []Taint{noSchedule, {Key: "gpu", Effect: "NoExecute"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.untolerated, untoleratedTaints(tc.tolerations, tc.taints))
+		})
+	}
+}
+
+func Test_WhatIfNodeChanges(t *testing.T) {
+	selector := map[string]string{"zone": "a"}
+	for _, tc := range []struct {
+		name    string
+		changes []NodeChange
+		// matches and changed are the verdict per node with the changes
+		matches map[string]bool
+		changed []string
+		// pairs are the node pairs lacking labels with the changes, changedPairs those that differ
+		pairs        int
+		changedPairs []string
+		err          string
+	}{
+		{
+			name:    "no changes",
+			changes: []NodeChange{{Node: "node-b"}},
+			matches: map[string]bool{"node-a": true, "node-b": false, "node-c": false},
+			pairs:   2,
+		},
+		{
+			name:         "add the missing labels",
+			changes:      []NodeChange{{Node: "node-b", AddLabels: map[string]string{"zone": "a", hostModelLabel: "true"}}},
+			matches:      map[string]bool{"node-a": true, "node-b": true, "node-c": false},
+			changed:      []string{"node-b"},
+			changedPairs: []string{"node-a>node-b", "node-c>node-b"},
+		},
+		{
+			name:         "remove a label",
+			changes:      []NodeChange{{Node: "node-a", RemoveLabels: []string{"zone", hostModelLabel}}},
+			matches:      map[string]bool{"node-a": false, "node-b": false, "node-c": false},
+			changed:      []string{"node-a"},
+			pairs:        2,
+			changedPairs: []string{"node-a>node-b", "node-c>node-a"},
+		},
+		{
+			name:    "remove a taint",
+			changes: []NodeChange{{Node: "node-c", RemoveTaints: []Taint{{Key: "maintenance"}}}},
+			matches: map[string]bool{"node-a": true, "node-b": false, "node-c": true},
+			changed: []string{"node-c"},
+			pairs:   2,
+		},
+		{
+			name:    "add a taint",
+			changes: []NodeChange{{Node: "node-a", AddTaints: []Taint{{Key: "gpu", Effect: "NoExecute"}}}},
+			matches: map[string]bool{"node-a": false, "node-b": false, "node-c": false},
+			changed: []string{"node-a"},
+			pairs:   2,
+		},
+		{
+			name:    "remove a taint of another effect",
+			changes: []NodeChange{{Node: "node-c", RemoveTaints: []Taint{{Key: "maintenance", Effect: "NoExecute"}}}},
+			matches: map[string]bool{"node-a": true, "node-b": false, "node-c": false},
+			pairs:   2,
+		},
+		{
+			name:    "unknown node",
+			changes: []NodeChange{{Node: "node-x", AddLabels: map[string]string{"zone": "a"}}},
+			err:     "what-if node node-x not found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			nodes := migrationNodes()
+			baseNodes, basePairs := evaluateLiveMigration(selector, nil, nodes)
+
+			changedNodes, err := applyNodeChanges(nodes, tc.changes)
+			if tc.err != "" {
+				assert.EqualError(err, tc.err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(migrationNodes(), nodes, "the fetched nodes are left as they are")
+
+			nodeResults, pairResults := evaluateLiveMigration(selector, nil, changedNodes)
+			nodeResults = markNodeChanges(baseNodes, nodeResults)
+			pairResults = markNodeToNodeChanges(basePairs, pairResults)
+
+			var changed []string
+			for _, result := range nodeResults {
+				assert.Equal(tc.matches[result.NodeName], result.Matches, result.NodeName)
+				assert.NotNil(result.BaselineMatches)
+				assert.Equal(result.Matches != *result.BaselineMatches, result.Changed)
+				if result.Changed {
+					changed = append(changed, result.NodeName)
+				}
+			}
+			assert.Equal(tc.changed, changed)
+
+			pairs := 0
+			var changedPairs []string
+			for _, result := range pairResults {
+				if len(result.MissingLabels) > 0 {
+					pairs++
+				}
+				if result.Changed {
+					changedPairs = append(changedPairs, result.SourceNode+">"+result.TargetNode)
+				}
+			}
+			assert.Equal(tc.pairs, pairs)
+			assert.ElementsMatch(tc.changedPairs, changedPairs)
+		})
+	}
+}
+
+func Test_NodeChangeValidation(t *testing.T) {
+	for _, tc := range []struct {
+		change NodeChange
+		valid  bool
+	}{
+		{change: NodeChange{Node: "node-a", AddTaints: []Taint{{Key: "gpu", Effect: "NoSchedule"}}}, valid: true},
+		{change: NodeChange{Node: "node-a", RemoveTaints: []Taint{{Key: "gpu"}}}, valid: true},
+		{change: NodeChange{AddLabels: map[string]string{"zone": "a"}}},
+		{change: NodeChange{Node: "node-a", AddTaints: []Taint{{Key: "gpu"}}}},
+		{change: NodeChange{Node: "node-a", AddTaints: []Taint{{Effect: "NoSchedule"}}}},
+		{change: NodeChange{Node: "node-a", RemoveTaints: []Taint{{Key: "gpu", Effect: "Never"}}}},
+	} {
+		if tc.valid {
+			require.NoError(t, tc.change.validate())
+		} else {
+			require.Error(t, tc.change.validate())
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// metricsSummaryName is the metrics summary of a version, next to its extracted directory. It holds
+// null when the bundle has no metrics.
+const metricsSummaryName = "metrics-summary.json"
+
+// MetricsSummaryResult is the metrics summary of a single version
+type MetricsSummaryResult struct {
+	VersionID string                 `json:"versionID"`
+	Summary   *bundle.MetricsSummary `json:"summary,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Status    string                 `json:"status"` // "found", "none", "unavailable", "error", "missing"
+}
+
+// MetricsComparison compares the metrics summaries of versions, e.g. before and after an incident
+type MetricsComparison struct {
+	Results []MetricsSummaryResult `json:"results"`
+	// Nodes are those of any of the versions, with their metrics keyed by the versions that have them
+	Nodes []NodeMetricsComparison `json:"nodes"`
+}
+
+// NodeMetricsComparison is a node across versions
+type NodeMetricsComparison struct {
+	Name     string                        `json:"name"`
+	Versions map[string]bundle.NodeMetrics `json:"versions"`
+}
+
+// writeMetricsSummary summarizes the metrics of the bundle extracted to extracted into a file next to it
+func writeMetricsSummary(extracted string) (*bundle.MetricsSummary, error) {
+	summary := bundle.ReadMetrics(extracted)
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(extracted), metricsSummaryName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write metrics summary: %w", err)
+	}
+	return summary, nil
+}
+
+// readMetricsSummary reads the metrics summary written at extraction, versions extracted before
+// summaries were written are summarized now
+func readMetricsSummary(extracted string) (*bundle.MetricsSummary, error) {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(extracted), metricsSummaryName))
+	if os.IsNotExist(err) {
+		// A missing directory has no metrics either, but that would hide that the files are gone
+		if _, err := os.Stat(extracted); err != nil {
+			return nil, err
+		}
+		return writeMetricsSummary(extracted)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var summary *bundle.MetricsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to read metrics summary: %w", err)
+	}
+	return summary, nil
+}
+
+// metricsSummary returns the metrics summary of a version
+func (s *Server) metricsSummary(workspaceName string, version model.Version) MetricsSummaryResult {
+	result := MetricsSummaryResult{VersionID: version.ID}
+	if version.Type == model.VersionTypeRuntime {
+		result.Status, result.Error = "unavailable", "Runtime versions have no bundle to read metrics from"
+		return result
+	}
+
+	extracted := s.extractedPath(workspaceName, version.ID)
+	_, statErr := os.Stat(filepath.Join(filepath.Dir(extracted), metricsSummaryName))
+	// The summary is written once the bundle is extracted again
+	if os.IsNotExist(statErr) && (version.ExtractedRemoved || s.extraction(version.InstanceName(workspaceName)) != nil) {
+		result.Status, result.Error = "unavailable", "The extracted bundle of the version was removed, starting the simulator extracts it again"
+		return result
+	}
+
+	summary, err := readMetricsSummary(extracted)
+	if err != nil {
+		result.Status, result.Error = "error", err.Error()
+		return result
+	}
+	if summary == nil {
+		result.Status, result.Error = "none", "The bundle has no node or pod metrics"
+		return result
+	}
+	result.Status, result.Summary = "found", summary
+	return result
+}
+
+// handleGetMetricsSummary returns the metrics summary of a version. With the comma separated compare
+// query parameter it compares the version with those, versions that don't exist are reported as missing.
+func (s *Server) handleGetMetricsSummary(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	compare := r.URL.Query().Get("compare")
+	if compare == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.metricsSummary(name, version))
+		return
+	}
+
+	versionIDs := []string{versionID}
+	for _, id := range strings.Split(compare, ",") {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(versionIDs, id) {
+			versionIDs = append(versionIDs, id)
+		}
+	}
+	results := []MetricsSummaryResult{}
+	for _, id := range versionIDs {
+		v, ok := findVersion(ws, id)
+		if !ok {
+			results = append(results, MetricsSummaryResult{VersionID: id, Status: "missing", Error: "Version no longer exists"})
+			continue
+		}
+		results = append(results, s.metricsSummary(name, v))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compareMetrics(results))
+}
+
+// compareMetrics lines up the nodes of the summaries found, sorted by name
+func compareMetrics(results []MetricsSummaryResult) MetricsComparison {
+	byName := make(map[string]*NodeMetricsComparison)
+	for _, result := range results {
+		if result.Summary == nil {
+			continue
+		}
+		for _, node := range result.Summary.Nodes {
+			if byName[node.Name] == nil {
+				byName[node.Name] = &NodeMetricsComparison{Name: node.Name, Versions: make(map[string]bundle.NodeMetrics)}
+			}
+			byName[node.Name].Versions[result.VersionID] = node
+		}
+	}
+
+	comparison := MetricsComparison{Results: results, Nodes: []NodeMetricsComparison{}}
+	for _, node := range byName {
+		comparison.Nodes = append(comparison.Nodes, *node)
+	}
+	sort.Slice(comparison.Nodes, func(i, j int) bool { return comparison.Nodes[i].Name < comparison.Nodes[j].Name })
+	return comparison
+}
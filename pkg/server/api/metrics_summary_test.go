@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+const nodeMetrics = `apiVersion: v1
+items:
+- apiVersion: metrics.k8s.io/v1beta1
+  kind: NodeMetrics
+  metadata:
+    name: harvester-01
+  timestamp: "2025-03-04T09:12:18Z"
+  usage:
+    cpu: 1500m
+    memory: 4Gi
+kind: List
+`
+
+func Test_MetricsSummary(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name:      "ws",
+		CreatedAt: time.Now(),
+		Versions: []model.Version{
+			{ID: "v1", Type: model.VersionTypeSupportBundle},
+			{ID: "v2", Type: model.VersionTypeSupportBundle},
+			{ID: "v3", Type: model.VersionTypeSupportBundle, ExtractedRemoved: true},
+			{ID: "v4", Type: model.VersionTypeRuntime},
+		},
+	}))
+	s := &Server{store: st, dataDir: dataDir}
+	for _, id := range []string{"v1", "v2"} {
+		writeFile(t, filepath.Join(s.extractedPath("ws", id), "supportbundle_1", "metadata.yaml"))
+	}
+	assert.NoError(os.MkdirAll(filepath.Join(s.extractedPath("ws", "v1"), "supportbundle_1/yamls/cluster/metrics.k8s.io/v1beta1"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(s.extractedPath("ws", "v1"), "supportbundle_1/yamls/cluster/metrics.k8s.io/v1beta1/nodes.yaml"), []byte(nodeMetrics), 0644))
+
+	// v2 was extracted since summaries are written, the versions before are summarized when asked for
+	assert.NoError(indexExtracted(s.extractedPath("ws", "v2"), "v2"))
+	data, err := os.ReadFile(filepath.Join(dataDir, "workspaces/ws/v2", metricsSummaryName))
+	assert.NoError(err)
+	assert.Equal("null", string(data))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/workspaces/{name}/versions/{versionID}/metrics-summary", s.handleGetMetricsSummary)
+	get := func(path string, out interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		assert.Equal(http.StatusOK, rec.Code, rec.Body.String())
+		assert.NoError(json.Unmarshal(rec.Body.Bytes(), out))
+	}
+
+	var result MetricsSummaryResult
+	get("/api/workspaces/ws/versions/v1/metrics-summary", &result)
+	assert.Equal("found", result.Status)
+	assert.Equal(bundle.MetricsFormatAPI, result.Summary.Format)
+	assert.Equal([]bundle.NodeMetrics{{Name: "harvester-01", CPUMillicores: 1500, MemoryBytes: 4 << 30}}, result.Summary.Nodes)
+	assert.FileExists(filepath.Join(dataDir, "workspaces/ws/v1", metricsSummaryName))
+
+	for id, status := range map[string]string{"v2": "none", "v3": "unavailable", "v4": "unavailable"} {
+		result = MetricsSummaryResult{}
+		get("/api/workspaces/ws/versions/"+id+"/metrics-summary", &result)
+		assert.Equal(status, result.Status, id)
+		assert.NotEmpty(result.Error, id)
+		assert.Nil(result.Summary, id)
+	}
+
+	var comparison MetricsComparison
+	get("/api/workspaces/ws/versions/v2/metrics-summary?compare=v1,v9,v2", &comparison)
+	assert.Len(comparison.Results, 3)
+	for i, want := range [][2]string{{"v2", "none"}, {"v1", "found"}, {"v9", "missing"}} {
+		assert.Equal(want[0], comparison.Results[i].VersionID)
+		assert.Equal(want[1], comparison.Results[i].Status)
+	}
+	assert.Len(comparison.Nodes, 1)
+	assert.Equal("harvester-01", comparison.Nodes[0].Name)
+	assert.Len(comparison.Nodes[0].Versions, 1)
+	assert.Equal(int64(1500), comparison.Nodes[0].Versions["v1"].CPUMillicores)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/workspaces/ws/versions/v9/metrics-summary", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,494 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/updater"
+	"github.com/Yu-Jack/sim-gui/pkg/version"
+)
+
+// route is an endpoint of the API along with what its OpenAPI description says about it. RegisterRoutes
+// registers the handlers and GET /api/openapi.json is generated from the same table, so an endpoint
+// can't be served without being described.
+type route struct {
+	Method  string
+	Path    string
+	Summary string
+	handler http.HandlerFunc
+	// Query lists the query parameters the handler reads, path parameters are taken from Path
+	Query []string
+	// Request is the type of the JSON body, Upload marks a multipart upload instead
+	Request any
+	Upload  bool
+	// Status is the status code of a success, 0 is 200
+	Status int
+	// Response is the type of the JSON body of a success, Produces the content type of a body that isn't
+	// JSON and Empty marks a success without body. Each route sets exactly one of them.
+	Response any
+	Produces string
+	Empty    bool
+	// Class is what the route needs from the role of a token, derived from Method and Path when unset
+	Class routeClass
+	// Limited marks the expensive routes, queries of simulators and uploads, which are rate limited per
+	// client, see rateLimit
+	Limited bool
+}
+
+// oneOf is a Response that is one of several types, e.g. depending on a query parameter
+type oneOf []any
+
+// listQuery are the query parameters of lists paged by parseListParams
+var listQuery = []string{"limit", "offset", "sort", "order"}
+
+// routes returns every endpoint of the API
+func (s *Server) routes() []route {
+	return []route{
+		{Method: "GET", Path: "/api/workspaces", Summary: "List workspaces, those of every profile when a server with profiles is asked for none", handler: s.handleListWorkspaces,
+			Query: append([]string{"createdBy", "mine"}, listQuery...), Response: []model.Workspace{}},
+		{Method: "POST", Path: "/api/workspaces", Summary: "Create a workspace", handler: s.handleCreateWorkspace,
+			Request: workspaceNameRequest{}, Status: http.StatusCreated, Response: model.Workspace{}, Class: classCreate},
+		{Method: "POST", Path: "/api/workspaces/auto-import", Summary: "Create a workspace named after an uploaded support bundle", handler: s.handleAutoImport,
+			Query: []string{"dryRun"}, Upload: true, Status: http.StatusCreated, Response: autoImportResponse{}, Class: classCreate, Limited: true},
+		{Method: "GET", Path: "/api/jobs", Summary: "List the running and recently finished background jobs", handler: s.handleListJobs,
+			Query: []string{"workspace", "kind"}, Response: []jobs.Job{}},
+		{Method: "GET", Path: "/api/jobs/{id}", Summary: "Get a background job", handler: s.handleGetJob,
+			Response: jobs.Job{}},
+		{Method: "POST", Path: "/api/jobs/{id}/cancel", Summary: "Cancel a background job, jobs that can't stop midway finish as usual", handler: s.handleCancelJob,
+			Status: http.StatusAccepted, Response: jobs.Job{}},
+		{Method: "GET", Path: "/api/trash", Summary: "List deleted workspaces and versions", handler: s.handleListTrash,
+			Response: []TrashEntry{}},
+		{Method: "POST", Path: "/api/trash/{id}/restore", Summary: "Restore a trash entry", handler: s.handleRestoreTrash,
+			Response: TrashRestoreResult{}},
+		{Method: "GET", Path: "/api/workspaces/{name}", Summary: "Get a workspace, fields=summary trims it", handler: s.handleGetWorkspace,
+			Query: []string{"fields"}, Response: oneOf{model.Workspace{}, WorkspaceSummary{}}},
+		{Method: "DELETE", Path: "/api/workspaces/{name}", Summary: "Delete a workspace in the background, to the trash unless permanent=true", handler: s.handleDeleteWorkspace,
+			Query: []string{"permanent"}, Status: http.StatusAccepted, Response: jobs.Job{}},
+		{Method: "PUT", Path: "/api/workspaces/{name}", Summary: "Rename a workspace", handler: s.handleRenameWorkspace,
+			Request: workspaceNameRequest{}, Empty: true},
+		{Method: "PATCH", Path: "/api/workspaces/{name}", Summary: "Change the preferences of a workspace", handler: s.handlePatchWorkspace,
+			Request: patchWorkspaceRequest{}, Response: model.Workspace{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/kubeconfig", Summary: "Get a kubeconfig merging every running version", handler: s.handleExportWorkspaceKubeconfig,
+			Produces: "application/x-yaml"},
+		{Method: "GET", Path: "/api/workspaces/{name}/kubeconfigs.zip", Summary: "Download the kubeconfig of each running version as a zip", handler: s.handleExportWorkspaceKubeconfigsZip,
+			Query: []string{"merge"}, Produces: "application/zip"},
+		{Method: "GET", Path: "/api/workspaces/{name}/activity", Summary: "List the activity of a workspace", handler: s.handleGetActivity,
+			Query: []string{"since", "limit", "offset"}, Response: []ActivityEntry{}},
+		{Method: "PUT", Path: "/api/workspaces/{name}/default-namespace", Summary: "Set the namespace browsing starts in", handler: s.handleSetDefaultNamespace,
+			Request: defaultNamespaceRequest{}, Empty: true},
+		{Method: "PUT", Path: "/api/workspaces/{name}/retention", Summary: "Set how long versions of a workspace are kept", handler: s.handleSetRetention,
+			Request: retentionRequest{}, Empty: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/bookmarks", Summary: "Bookmark a resource", handler: s.handleAddBookmark,
+			Request: model.Bookmark{}, Status: http.StatusCreated, Response: model.Bookmark{}},
+		{Method: "DELETE", Path: "/api/workspaces/{name}/bookmarks", Summary: "Remove a bookmark", handler: s.handleDeleteBookmark,
+			Request: model.Bookmark{}, Empty: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/saved-queries", Summary: "List saved queries", handler: s.handleListSavedQueries,
+			Response: []model.SavedQuery{}},
+		{Method: "POST", Path: "/api/workspaces/{name}/saved-queries", Summary: "Save a query", handler: s.handleCreateSavedQuery,
+			Request: savedQueryRequest{}, Status: http.StatusCreated, Response: model.SavedQuery{}},
+		{Method: "PUT", Path: "/api/workspaces/{name}/saved-queries/{id}", Summary: "Update a saved query", handler: s.handleUpdateSavedQuery,
+			Request: savedQueryRequest{}, Response: model.SavedQuery{}},
+		{Method: "DELETE", Path: "/api/workspaces/{name}/saved-queries/{id}", Summary: "Delete a saved query", handler: s.handleDeleteSavedQuery,
+			Empty: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/saved-queries/{id}/run", Summary: "Run a saved query", handler: s.handleRunSavedQuery,
+			Response: []ResourceHistoryResult{}, Class: classRead, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/clean-all", Summary: "Remove the images of every version of a workspace", handler: s.handleCleanAllWorkspaceImages,
+			Query: []string{"dryRun"}, Response: CleanReport{}},
+		{Method: "POST", Path: "/api/clean-all", Summary: "Remove the images of every version", handler: s.handleCleanAllImages,
+			Query: []string{"dryRun"}, Response: CleanReport{}},
+		{Method: "POST", Path: "/api/workspaces/{name}/resource-history", Summary: "Get a resource across versions, resources gets several keyed by resource and version", handler: s.handleGetResourceHistory,
+			Query: []string{"format"}, Request: resourceHistoryRequest{}, Response: oneOf{[]ResourceHistoryResult{}, map[string]map[string]ResourceHistoryResult{}}, Class: classRead, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/namespaces", Summary: "List namespaces", handler: s.handleGetNamespaces,
+			Query: []string{"version", "autostart"}, Response: []string{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/resource-types", Summary: "List resource types", handler: s.handleGetResourceTypes,
+			Query: []string{"version", "autostart"}, Response: []string{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/resources", Summary: "List resources", handler: s.handleGetResources,
+			Query: append([]string{"namespace", "resourceType", "keyword", "version", "mode", "include", "autostart"}, listQuery...), Response: oneOf{[]string{}, ResourceList{}}, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/vm-pods", Summary: "Get the pods of a virtual machine", handler: s.handleGetVMPods,
+			Query: []string{"format"}, Request: vmPodsRequest{}, Response: VirtualMachinePodsResult{}, Class: classRead, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/live-migration-check", Summary: "Check whether a virtual machine can be live migrated", handler: s.handleCheckLiveMigration,
+			Request: liveMigrationRequest{}, Response: LiveMigrationCheckResult{}, Class: classRead, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/settings-summary", Summary: "Compare the settings of versions", handler: s.handleGetWorkspaceSettingsSummary,
+			Query: []string{"versions"}, Response: []SettingsSummaryResult{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/helm-releases", Summary: "Compare the helm releases of versions", handler: s.handleGetWorkspaceHelmReleases,
+			Query: []string{"versions", "diff"}, Response: []HelmReleasesResult{}, Limited: true},
+
+		{Method: "GET", Path: "/api/workspaces/{name}/versions", Summary: "List versions", handler: s.handleListVersions,
+			Query: listQuery, Response: []model.Version{}},
+		{Method: "PUT", Path: "/api/workspaces/{name}/versions/order", Summary: "Reorder versions", handler: s.handleSetVersionOrder,
+			Request: versionOrderRequest{}, Response: []model.Version{}},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions", Summary: "Upload a version", handler: s.handleUploadVersion,
+			Upload: true, Empty: true, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/from-path", Summary: "Import a version from a path on the server", handler: s.handleImportFromPath,
+			Request: importPathRequest{}, Response: model.Version{}, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/start", Summary: "Start a simulator", handler: s.handleStartSimulator,
+			Request: StartSimulatorRequest{}, Response: StartSimulatorResponse{}},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/stop", Summary: "Stop a simulator", handler: s.handleStopSimulator,
+			Empty: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/reset", Summary: "Reset a simulator to its bundle", handler: s.handleResetSimulator,
+			Empty: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/status", Summary: "Get the status of a simulator", handler: s.handleGetSimulatorStatus,
+			Response: SimulatorStatus{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/inspect", Summary: "Get docker inspect of a simulator container and its image, secret looking environment variables redacted", handler: s.handleInspectSimulator,
+			Response: docker.Inspection{}, Class: classAdmin},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/ports", Summary: "List the ports a simulator publishes", handler: s.handleGetSimulatorPorts,
+			Response: SimulatorPorts{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/kubeconfig", Summary: "Get the kubeconfig of a version", handler: s.handleGetKubeconfig,
+			Produces: "application/x-yaml"},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/connect-script", Summary: "Get a script connecting kubectl to a version", handler: s.handleGetConnectScript,
+			Query: []string{"server"}, Produces: "text/x-shellscript"},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/build-log", Summary: "Get the build log of a simulator image, tail keeps its last lines", handler: s.handleGetBuildLog,
+			Query: []string{"tail"}, Produces: "text/plain"},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/settings-summary", Summary: "Get the settings of a version", handler: s.handleGetSettingsSummary,
+			Response: SettingsSummaryResult{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/helm-releases", Summary: "Get the helm releases of a version", handler: s.handleGetHelmReleases,
+			Response: HelmReleasesResult{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/metrics-summary", Summary: "Get the node and pod metrics of a bundle, compared with other versions with compare", handler: s.handleGetMetricsSummary,
+			Query: []string{"compare"}, Response: oneOf{MetricsSummaryResult{}, MetricsComparison{}}, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/rbac-check", Summary: "Check whether a service account may do something", handler: s.handleRBACCheck,
+			Request: rbacCheckRequest{}, Response: RBACCheckResult{}, Class: classRead, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/vm-storage", Summary: "Get the storage of a virtual machine", handler: s.handleGetVMStorage,
+			Request: vmStorageRequest{}, Response: VMStorageResult{}, Class: classRead, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/vm-network", Summary: "Get the network of a virtual machine", handler: s.handleGetVMNetwork,
+			Query: []string{"namespace", "vmName"}, Response: VMNetworkResult{}, Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/vm-backups", Summary: "List the backups of virtual machines", handler: s.handleGetVMBackups,
+			Query: []string{"namespace"}, Response: VMBackupsResult{}, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/snapshot", Summary: "Capture a snapshot of a running simulator", handler: s.handleCaptureSnapshot,
+			Request: SnapshotRequest{}, Status: http.StatusCreated, Response: SnapshotInfo{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/snapshot", Summary: "Get a snapshot, the latest without id", handler: s.handleGetSnapshot,
+			Query: []string{"id"}, Response: Snapshot{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/snapshots", Summary: "List snapshots", handler: s.handleListSnapshots,
+			Response: []SnapshotInfo{}},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}", Summary: "Get a version with the state of its simulator", handler: s.handleGetVersion,
+			Query: []string{"include"}, Response: VersionDetail{}},
+		{Method: "PATCH", Path: "/api/workspaces/{name}/versions/{versionID}", Summary: "Change the start parameters of a version", handler: s.handlePatchVersion,
+			Request: patchVersionRequest{}, Response: model.Version{}},
+		{Method: "DELETE", Path: "/api/workspaces/{name}/versions/{versionID}", Summary: "Delete a version, to the trash unless permanent=true", handler: s.handleDeleteVersion,
+			Query: []string{"permanent"}, Response: []CleanupResult{}},
+		{Method: "PUT", Path: "/api/workspaces/{name}/versions/{versionID}/pin", Summary: "Pin a version so retention keeps it", handler: s.handlePinVersion,
+			Request: pinRequest{}, Empty: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/clean-image", Summary: "Remove the images of a version", handler: s.handleCleanVersionImage,
+			Query: []string{"dryRun", "extracted"}, Response: CleanReport{}},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/clean-extracted", Summary: "Remove the extracted support bundle of a version", handler: s.handleCleanExtracted,
+			Empty: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/reindex", Summary: "Rebuild the file index of a version", handler: s.handleReindexVersion,
+			Response: FileIndexStats{}},
+
+		{Method: "PUT", Path: "/api/workspaces/{name}/versions/{versionID}/bundle", Summary: "Replace the support bundle of a version", handler: s.handleReplaceBundle,
+			Upload: true, Response: model.Version{}, Limited: true},
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/code-server", Summary: "Start code-server on a version", handler: s.handleStartCodeServer,
+			Request: codeServerRequest{}, Response: map[string]string{}},
+		{Method: "GET", Path: "/api/code-server/projects", Summary: "List code-server projects", handler: s.handleListCodeServerProjects,
+			Response: []CodeServerProject{}},
+		{Method: "DELETE", Path: "/api/code-server/projects", Summary: "Remove code-server projects, only stale ones unless all=true", handler: s.handlePurgeCodeServerProjects,
+			Query: []string{"all"}, Response: map[string][]string{}},
+
+		{Method: "GET", Path: "/api/search", Summary: "Search the versions of every workspace by the metadata of their bundle", handler: s.handleSearch,
+			Query: []string{"q", "harvesterVersion", "clusterName", "collectedAfter", "collectedBefore", "limit"}, Response: store.SearchResult{}, Limited: true},
+
+		{Method: "GET", Path: "/api/dashboard", Summary: "Get the overview of workspaces, simulators, disk, builds and updates", handler: s.handleGetDashboard,
+			Response: Dashboard{}},
+
+		{Method: "GET", Path: "/api/consistency", Summary: "Check the data directory against the store", handler: s.handleGetConsistency,
+			Response: []ConsistencyIssue{}},
+		{Method: "POST", Path: "/api/consistency/repair", Summary: "Repair the consistency issues", handler: s.handleRepairConsistency,
+			Query: []string{"strategy"}, Response: []RepairResult{}},
+
+		{Method: "GET", Path: "/api/profiles", Summary: "List the profiles of the server, none when it serves a single data directory", handler: s.handleListProfiles,
+			Response: []ProfileSummary{}},
+		{Method: "GET", Path: "/api/pull-status", Summary: "List the image pulls since the server started with the progress of their layers", handler: s.handlePullStatus,
+			Response: []docker.PullProgress{}},
+		{Method: "GET", Path: "/api/version", Summary: "Get the build of the server", handler: s.handleGetBuildInfo,
+			Response: BuildInfo{}},
+		{Method: "GET", Path: "/api/healthz", Summary: "Check the health of the server", handler: s.handleHealthz,
+			Response: Health{}, Class: classPublic},
+		{Method: "GET", Path: "/api/openapi.json", Summary: "Get this description of the API", handler: s.handleGetOpenAPI,
+			Response: map[string]any{}},
+
+		// State changes, the polling endpoints above keep working for clients that don't subscribe
+		{Method: "GET", Path: "/api/events", Summary: "Subscribe to state changes", handler: s.handleEvents,
+			Produces: "text/event-stream"},
+
+		{Method: "GET", Path: "/api/update-status", Summary: "Get the last update check", handler: s.handleGetUpdateStatus,
+			Response: updater.UpdateStatus{}},
+		{Method: "POST", Path: "/api/update-status/check", Summary: "Check for updates now", handler: s.handleCheckForUpdates,
+			Response: updater.UpdateStatus{}},
+	}
+}
+
+// validate checks that a route says everything the description needs
+func (rt route) validate() error {
+	if rt.Method == "" || rt.Path == "" || rt.handler == nil {
+		return fmt.Errorf("%s %s: method, path and handler are required", rt.Method, rt.Path)
+	}
+	if strings.TrimSpace(rt.Summary) == "" {
+		return fmt.Errorf("%s %s: summary is required", rt.Method, rt.Path)
+	}
+	if rt.Request != nil && rt.Upload {
+		return fmt.Errorf("%s %s: a request is either JSON or an upload", rt.Method, rt.Path)
+	}
+	set := 0
+	for _, ok := range []bool{rt.Response != nil, rt.Produces != "", rt.Empty} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("%s %s: exactly one of response, produces and empty must be set", rt.Method, rt.Path)
+	}
+	return nil
+}
+
+// pathParamPattern matches the wildcards of a ServeMux pattern, which OpenAPI writes the same way
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openAPIDocument describes routes as an OpenAPI 3 document, the types of requests and responses are
+// described by their JSON encoding
+func openAPIDocument(routes []route) map[string]any {
+	schemas := newSchemaBuilder()
+	paths := make(map[string]map[string]any)
+	for _, rt := range routes {
+		op := map[string]any{"summary": rt.Summary}
+
+		var params []map[string]any
+		for _, m := range pathParamPattern.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, q := range rt.Query {
+			params = append(params, map[string]any{"name": q, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.Request != nil {
+			op["requestBody"] = map[string]any{"content": map[string]any{
+				"application/json": map[string]any{"schema": schemas.of(rt.Request)},
+			}}
+		} else if rt.Upload {
+			op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+				"multipart/form-data": map[string]any{"schema": map[string]any{"type": "object"}},
+			}}
+		}
+
+		status := rt.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case rt.Response != nil:
+			response["content"] = map[string]any{"application/json": map[string]any{"schema": schemas.of(rt.Response)}}
+		case rt.Produces != "":
+			response["content"] = map[string]any{rt.Produces: map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+		responses := map[string]any{
+			fmt.Sprint(status): response,
+			"default":          map[string]any{"description": "The error as plain text"},
+		}
+		if rt.Limited {
+			responses["429"] = map[string]any{
+				"description": "Too many requests, Retry-After is the number of seconds to wait",
+				"headers":     map[string]any{"Retry-After": map[string]any{"schema": map[string]any{"type": "integer"}}},
+			}
+		}
+		op["responses"] = responses
+
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = make(map[string]any)
+		}
+		paths[rt.Path][strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": "sim-gui", "version": version.Version},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas.schemas},
+	}
+}
+
+// schemaBuilder turns Go types into JSON schemas, named structs are described once under
+// components/schemas and referenced from everywhere else
+type schemaBuilder struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemas: make(map[string]any), names: make(map[reflect.Type]string)}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// of returns the schema of the type of v, a oneOf lists the schemas of its types
+func (b *schemaBuilder) of(v any) map[string]any {
+	if alternatives, ok := v.(oneOf); ok {
+		var schemas []map[string]any
+		for _, a := range alternatives {
+			schemas = append(schemas, b.of(a))
+		}
+		return map[string]any{"oneOf": schemas}
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Encoded however the type likes
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + b.name(t)}
+	default:
+		// Interfaces hold anything
+		return map[string]any{}
+	}
+}
+
+// name returns the component name of a named struct, describing it the first time. Types of other
+// packages are prefixed with the package when their name is taken.
+func (b *schemaBuilder) name(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		pkg := []rune(path.Base(t.PkgPath()))
+		pkg[0] = unicode.ToUpper(pkg[0])
+		name = string(pkg) + name
+	}
+	b.names[t] = name
+	// Reserved before describing the fields so recursive types reference it
+	b.schemas[name] = map[string]any{}
+	b.schemas[name] = b.structSchema(t)
+	return name
+}
+
+// structSchema describes the fields of a struct the way encoding/json encodes them, fields without
+// omitempty are always present
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	b.addFields(t, properties, &required)
+	sort.Strings(required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// Fields of embedded structs are encoded as if they were fields of t
+			b.addFields(ft, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.Contains(","+opts+",", ",string,") {
+			properties[name] = map[string]any{"type": "string"}
+		} else {
+			properties[name] = b.schema(f.Type)
+		}
+		if !strings.Contains(","+opts+",", ",omitempty,") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func (s *Server) handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc := openAPIDocument(s.routes())
+	if s.basePath != "" {
+		doc["servers"] = []map[string]any{{"url": s.basePath}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// swaggerUIPage renders GET /api/openapi.json with Swagger UI, loaded from a CDN since it is only
+// served in dev mode. The document is referenced relative to the page to keep the base path.
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>sim-gui API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"})</script>
+</body>
+</html>
+`
+
+// RegisterDocs serves Swagger UI for the API at /api/docs, meant for dev mode
+func (s *Server) RegisterDocs(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+s.basePath+"/api/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, swaggerUIPage)
+	})
+}
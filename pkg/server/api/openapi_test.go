@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test_RoutesAreDescribed fails when a route is added without what its description needs
+func Test_RoutesAreDescribed(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+	routes := s.routes()
+	seen := make(map[string]bool)
+	for _, rt := range routes {
+		assert.NoError(rt.validate())
+		pattern := rt.Method + " " + rt.Path
+		assert.False(seen[pattern], "expected %s once", pattern)
+		seen[pattern] = true
+	}
+
+	data, err := json.Marshal(openAPIDocument(routes))
+	assert.NoError(err)
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.NoError(json.Unmarshal(data, &doc))
+	for _, rt := range routes {
+		assert.Contains(doc.Paths[rt.Path], strings.ToLower(rt.Method), "expected %s %s in the description", rt.Method, rt.Path)
+	}
+
+	// Every reference points at a described schema
+	for _, ref := range strings.Split(string(data), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		assert.Contains(doc.Components.Schemas, name)
+	}
+}
+
+func Test_RouteValidate(t *testing.T) {
+	assert := require.New(t)
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	valid := route{Method: "GET", Path: "/api/x", Summary: "Get x", handler: handler, Empty: true}
+	assert.NoError(valid.validate())
+
+	for _, rt := range []route{
+		{Method: "GET", Path: "/api/x", handler: handler, Empty: true},
+		{Method: "GET", Path: "/api/x", Summary: "Get x", handler: handler},
+		{Method: "GET", Path: "/api/x", Summary: "Get x", handler: handler, Response: Health{}, Empty: true},
+		{Method: "GET", Path: "/api/x", Summary: "Get x", handler: handler, Produces: "text/plain", Empty: true},
+		{Method: "POST", Path: "/api/x", Summary: "Post x", handler: handler, Request: pinRequest{}, Upload: true, Empty: true},
+		{Method: "GET", Path: "/api/x", Summary: "Get x", Empty: true},
+	} {
+		assert.Error(rt.validate(), "expected %+v to be refused", rt)
+	}
+}
+
+func Test_SchemaBuilder(t *testing.T) {
+	assert := require.New(t)
+	type inner struct {
+		Count int `json:"count"`
+	}
+	type sample struct {
+		inner
+		Name     string            `json:"name"`
+		Note     string            `json:"note,omitempty"`
+		Size     int64             `json:"size,string"`
+		At       time.Time         `json:"at"`
+		Started  *time.Time        `json:"started"`
+		Tags     []string          `json:"tags"`
+		Labels   map[string]string `json:"labels"`
+		Data     []byte            `json:"data"`
+		Children []sample          `json:"children"`
+		Skipped  string            `json:"-"`
+		hidden   string
+	}
+	b := newSchemaBuilder()
+	assert.Equal(map[string]any{"$ref": "#/components/schemas/sample"}, b.of(sample{}))
+
+	schema := b.schemas["sample"].(map[string]any)
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(map[string]any{"type": "integer"}, properties["count"], "expected the embedded fields inlined")
+	assert.Equal(map[string]any{"type": "string"}, properties["size"])
+	assert.Equal(map[string]any{"type": "string", "format": "date-time"}, properties["at"])
+	assert.Equal(map[string]any{"type": "string", "format": "date-time"}, properties["started"])
+	assert.Equal(map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, properties["tags"])
+	assert.Equal(map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}, properties["labels"])
+	assert.Equal(map[string]any{"type": "string", "format": "byte"}, properties["data"])
+	assert.Equal(map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/sample"}}, properties["children"])
+	assert.NotContains(properties, "Skipped")
+	assert.NotContains(properties, "hidden")
+	assert.Equal([]string{"at", "children", "count", "data", "labels", "name", "size", "tags"}, schema["required"])
+
+	alternatives := b.of(oneOf{[]string{}, inner{}})
+	assert.Len(alternatives["oneOf"], 2)
+}
+
+func Test_HandleGetOpenAPI(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+	var doc map[string]any
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal("3.0.3", doc["openapi"])
+
+	// The docs page is only registered in dev mode
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+	s.RegisterDocs(mux)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `url: "openapi.json"`)
+
+	// Under a base path the routes are prefixed and the document tells where
+	s = &Server{}
+	s.SetBasePath("/tools/sim-gui")
+	mux = http.NewServeMux()
+	s.RegisterRoutes(mux)
+	s.RegisterDocs(mux)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+	for _, path := range []string{"/tools/sim-gui/api/openapi.json", "/tools/sim-gui/api/docs"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(http.StatusOK, w.Code, path)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/tools/sim-gui/api/openapi.json", nil))
+	assert.NoError(json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal([]any{map[string]any{"url": "/tools/sim-gui"}}, doc["servers"])
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageLimit is used when only offset is given
+	defaultPageLimit = 50
+	// maxPageLimit caps every list response, including requests without pagination parameters
+	maxPageLimit = 1000
+)
+
+// listParams holds the pagination and sorting query parameters of a list request
+type listParams struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Desc   bool
+}
+
+// parseListParams reads limit, offset, sort and order from the query. Without limit and offset the
+// whole list up to maxPageLimit is returned so existing clients keep working.
+func parseListParams(r *http.Request, sortFields ...string) (listParams, error) {
+	query := r.URL.Query()
+	params := listParams{Limit: maxPageLimit}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return params, fmt.Errorf("invalid offset %q", v)
+		}
+		params.Offset = offset
+		params.Limit = defaultPageLimit
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return params, fmt.Errorf("invalid limit %q", v)
+		}
+		params.Limit = min(limit, maxPageLimit)
+	}
+
+	if v := query.Get("sort"); v != "" {
+		found := false
+		for _, field := range sortFields {
+			if v == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return params, fmt.Errorf("invalid sort %q, expected one of %s", v, strings.Join(sortFields, ", "))
+		}
+		params.Sort = v
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		return params, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+
+	return params, nil
+}
+
+// paginate sorts items with less when given, applies the page and sets the X-Total-Count header
+// to the number of items before paging so the UI can render pagers
+func paginate[T any](w http.ResponseWriter, items []T, params listParams, less func(a, b T) bool) []T {
+	// Sort a copy, items may share its backing array with the store
+	items = append(make([]T, 0, len(items)), items...)
+	if less != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			if params.Desc {
+				return less(items[j], items[i])
+			}
+			return less(items[i], items[j])
+		})
+	} else if params.Desc {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+
+	start := min(params.Offset, len(items))
+	end := min(start+params.Limit, len(items))
+	return items[start:end]
+}
+
+// timeBefore orders optional timestamps, treating unset ones as the oldest
+func timeBefore(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Before(*b)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Paginate(t *testing.T) {
+	assert := require.New(t)
+	items := []string{"c", "a", "e", "b", "d"}
+	less := func(a, b string) bool { return a < b }
+
+	params, err := parseListParams(httptest.NewRequest("GET", "/api/workspaces?limit=2&offset=1&sort=name&order=desc", nil), "name")
+	assert.NoError(err)
+	rec := httptest.NewRecorder()
+	assert.Equal([]string{"d", "c"}, paginate(rec, items, params, less))
+	assert.Equal("5", rec.Header().Get("X-Total-Count"))
+
+	// without parameters everything is returned
+	params, err = parseListParams(httptest.NewRequest("GET", "/api/workspaces", nil), "name")
+	assert.NoError(err)
+	assert.Equal(maxPageLimit, params.Limit)
+	assert.Len(paginate(httptest.NewRecorder(), items, params, nil), 5)
+
+	// offset past the end returns an empty page
+	params, err = parseListParams(httptest.NewRequest("GET", "/api/workspaces?offset=10", nil), "name")
+	assert.NoError(err)
+	assert.Equal(defaultPageLimit, params.Limit)
+	assert.Empty(paginate(httptest.NewRecorder(), items, params, nil))
+
+	params, err = parseListParams(httptest.NewRequest("GET", "/api/workspaces?limit=100000", nil))
+	assert.NoError(err)
+	assert.Equal(maxPageLimit, params.Limit)
+
+	for _, query := range []string{"limit=0", "offset=-1", "sort=size", "order=up"} {
+		_, err = parseListParams(httptest.NewRequest("GET", "/api/workspaces?"+query, nil), "name")
+		assert.Error(err, query)
+	}
+}
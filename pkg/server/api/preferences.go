@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	// Zones are resolved on hosts without a zoneinfo database, e.g. minimal containers
+	_ "time/tzdata"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the workspace preferences
+const (
+	outputYAML = "yaml"
+	outputJSON = "json"
+)
+
+// localTimeLayout is how timestamps are rendered in the time zone of a workspace
+const localTimeLayout = "2006-01-02 15:04:05 -07:00"
+
+// validatePreferences rejects time zones which aren't IANA names and unknown output formats
+func validatePreferences(p model.Preferences) error {
+	// Local depends on the server the workspace happens to run on
+	if _, err := time.LoadLocation(p.TimeZone); err != nil || p.TimeZone == "Local" {
+		return fmt.Errorf("invalid time zone %q, expected an IANA name such as Asia/Taipei", p.TimeZone)
+	}
+	switch p.OutputFormat {
+	case "", outputYAML, outputJSON:
+	default:
+		return fmt.Errorf("invalid output format %q, expected %s or %s", p.OutputFormat, outputYAML, outputJSON)
+	}
+	return nil
+}
+
+// workspaceLocation returns the time zone timestamps of a workspace are rendered in, nil when it has none
+func workspaceLocation(ws *model.Workspace) *time.Location {
+	if ws.Preferences.TimeZone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(ws.Preferences.TimeZone)
+	if err != nil {
+		// Validated when set, the zone database of the server may still lack it
+		fmt.Printf("Failed to load time zone %s of %s: %v\n", ws.Preferences.TimeZone, ws.Name, err)
+		return nil
+	}
+	return loc
+}
+
+// localTime renders t in loc, empty without a time zone
+func localTime(t time.Time, loc *time.Location) string {
+	if loc == nil || t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format(localTimeLayout)
+}
+
+// localTimestamp renders an RFC 3339 timestamp printed by kubectl in loc, empty without a time zone
+// or when the value isn't a timestamp
+func localTimestamp(value string, loc *time.Location) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ""
+	}
+	return localTime(t, loc)
+}
+
+// presentResources prints the content of found resources in the output format of the workspace,
+// kubectl prints them as YAML
+func presentResources(ws *model.Workspace, results []ResourceHistoryResult) []ResourceHistoryResult {
+	if ws.Preferences.OutputFormat != outputJSON {
+		return results
+	}
+	presented := make([]ResourceHistoryResult, len(results))
+	for i, result := range results {
+		if result.Status == "found" {
+			content, err := yamlToJSON(result.Content)
+			if err != nil {
+				fmt.Printf("Failed to convert %s of %s to JSON: %v\n", result.VersionID, ws.Name, err)
+			} else {
+				result.Content = content
+			}
+		}
+		presented[i] = result
+	}
+	return presented
+}
+
+// yamlToJSON converts a resource printed as YAML to indented JSON. Keys are sorted, unlike -o json.
+func yamlToJSON(content string) (string, error) {
+	var obj interface{}
+	if err := yaml.Unmarshal([]byte(content), &obj); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// handlePatchWorkspace updates the preferences of a workspace present in the body and returns the workspace
+// patchWorkspaceRequest holds the fields a workspace patch changes, those left out are kept
+type patchWorkspaceRequest struct {
+	TimeZone         *string `json:"timeZone"`
+	OutputFormat     *string `json:"outputFormat"`
+	DefaultNamespace *string `json:"defaultNamespace"`
+	// Replace the labels and environment of the containers, an empty object clears them
+	ContainerLabels *map[string]string `json:"containerLabels"`
+	ContainerEnv    *map[string]string `json:"containerEnv"`
+}
+
+func (s *Server) handlePatchWorkspace(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req patchWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	preferences := ws.Preferences
+	if req.TimeZone != nil {
+		preferences.TimeZone = strings.TrimSpace(*req.TimeZone)
+	}
+	if req.OutputFormat != nil {
+		preferences.OutputFormat = strings.ToLower(strings.TrimSpace(*req.OutputFormat))
+	}
+	if err := validatePreferences(preferences); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.Preferences = preferences
+	// An empty namespace clears the default
+	if req.DefaultNamespace != nil {
+		ws.DefaultNamespace = strings.TrimSpace(*req.DefaultNamespace)
+	}
+	if req.ContainerLabels != nil {
+		ws.ContainerLabels = *req.ContainerLabels
+	}
+	if req.ContainerEnv != nil {
+		ws.ContainerEnv = *req.ContainerEnv
+	}
+	if _, err := containerExtras(ws, nil, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ws)
+}
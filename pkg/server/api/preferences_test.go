@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PatchWorkspacePreferences(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, defaultUploadLimits)
+	patch := func(body string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("PATCH", "/api/workspaces/ws", bytes.NewBufferString(body)))
+		return rec.Code
+	}
+
+	assert.Equal(http.StatusOK, patch(`{"timeZone": "Asia/Taipei", "outputFormat": "JSON", "defaultNamespace": "harvester-system"}`))
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal("Asia/Taipei", ws.Preferences.TimeZone)
+	assert.Equal("json", ws.Preferences.OutputFormat)
+	assert.Equal("harvester-system", ws.DefaultNamespace)
+
+	// fields missing from the body are kept
+	assert.Equal(http.StatusOK, patch(`{"outputFormat": ""}`))
+	ws, err = s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal("Asia/Taipei", ws.Preferences.TimeZone)
+	assert.Empty(ws.Preferences.OutputFormat)
+	assert.Equal("harvester-system", ws.DefaultNamespace)
+
+	for _, body := range []string{`{"timeZone": "Asia/Taipai"}`, `{"timeZone": "Local"}`, `{"outputFormat": "xml"}`} {
+		assert.Equal(http.StatusBadRequest, patch(body), body)
+	}
+	ws, err = s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal("Asia/Taipei", ws.Preferences.TimeZone, "rejected preferences aren't stored")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("PATCH", "/api/workspaces/missing", bytes.NewBufferString(`{}`)))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
+
+func Test_LocalTimestamp(t *testing.T) {
+	assert := require.New(t)
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	assert.NoError(err)
+
+	assert.Equal("2024-11-18 18:00:00 +08:00", localTimestamp("2024-11-18T10:00:00Z", taipei))
+	assert.Empty(localTimestamp("2024-11-18T10:00:00Z", nil), "no time zone renders nothing")
+	assert.Empty(localTimestamp("", taipei))
+	assert.Empty(localTimestamp("yesterday", taipei))
+}
+
+func Test_PresentResources(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{Name: "ws"}
+	results := []ResourceHistoryResult{
+		{VersionID: "v1", Status: "found", Content: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: p1\n"},
+		{VersionID: "v2", Status: "not_found", Error: `pods "p1" not found`},
+	}
+
+	assert.Equal(results, presentResources(ws, results), "YAML is kept by default")
+
+	ws.Preferences.OutputFormat = outputJSON
+	presented := presentResources(ws, results)
+	assert.Equal(`{
+    "apiVersion": "v1",
+    "kind": "Pod",
+    "metadata": {
+        "name": "p1"
+    }
+}
+`, presented[0].Content)
+	assert.Equal(results[1], presented[1])
+	assert.Contains(results[0].Content, "kind: Pod", "the results themselves are unchanged")
+}
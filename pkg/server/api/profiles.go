@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+const (
+	// ProfileHeader selects the profile a request operates on, the profile query parameter does too
+	// for links and event streams
+	ProfileHeader = "X-Profile"
+	// ProfilesPath prefixes the routes of each profile under the base path, followed by its name
+	ProfilesPath = "/profiles/"
+	profileParam = "profile"
+)
+
+// profileNameRegexp keeps profile names free of '-', so the profile an instance name starts with is
+// unambiguous
+var profileNameRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// ValidateProfileName checks that a profile name can prefix instance names and paths
+func ValidateProfileName(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use lowercase letters and digits", name)
+	}
+	return nil
+}
+
+// profileInstanceName returns the instance name of a version in a profile, see docker.InstanceName.
+// Profiles share the docker daemon, so their simulators are named after the profile too.
+func profileInstanceName(profile, workspaceName, versionID string) string {
+	if profile == "" {
+		return docker.InstanceName(workspaceName, versionID)
+	}
+	return docker.InstanceName(profile+"-"+workspaceName, versionID)
+}
+
+// instanceName returns the instance name of a new version of the server
+func (s *Server) instanceName(workspaceName, versionID string) string {
+	return profileInstanceName(s.profile, workspaceName, versionID)
+}
+
+// namespaced prefixes a name shared with other profiles, like a code-server project, with the profile
+func (s *Server) namespaced(name string) string {
+	if s.profile == "" {
+		return name
+	}
+	return s.profile + "-" + name
+}
+
+// profilePath returns the path prefix of the routes of the profile, empty without profiles
+func (s *Server) profilePath() string {
+	if s.profile == "" {
+		return ""
+	}
+	return ProfilesPath + s.profile
+}
+
+// ProfileSummary is a profile served by the server
+type ProfileSummary struct {
+	Name string `json:"name"`
+	// Default is the profile of requests selecting none
+	Default bool `json:"default"`
+}
+
+// ProfileWorkspace is a workspace listed across profiles along with its profile
+type ProfileWorkspace struct {
+	Profile string `json:"profile"`
+	model.Workspace
+}
+
+// Profiles serves a server per data directory from one mux. Each profile has its own store, data
+// directory and tokens, requests select one by the path prefix of ProfilesPath, ProfileHeader or the
+// profile query parameter. Requests selecting none go to the first profile added, except the workspace
+// list which lists the workspaces of every profile.
+type Profiles struct {
+	names   []string
+	servers map[string]*Server
+	// limiter is shared by the servers, see SetRateLimit
+	limiter *rateLimiter
+}
+
+// NewProfiles creates an empty set of profiles
+func NewProfiles() *Profiles {
+	return &Profiles{servers: make(map[string]*Server)}
+}
+
+// Add serves s as the profile name, the first profile added is the default one. It must be called
+// before the routes of s are registered or served, its instance names depend on the profile.
+func (p *Profiles) Add(name string, s *Server) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if _, ok := p.servers[name]; ok {
+		return fmt.Errorf("profile %q is configured twice", name)
+	}
+	s.profile = name
+	s.profiles = p
+	p.names = append(p.names, name)
+	p.servers[name] = s
+	return nil
+}
+
+// summaries returns the profiles in the order they were added
+func (p *Profiles) summaries() []ProfileSummary {
+	summaries := make([]ProfileSummary, 0, len(p.names))
+	for i, name := range p.names {
+		summaries = append(summaries, ProfileSummary{Name: name, Default: i == 0})
+	}
+	return summaries
+}
+
+// requestedProfile returns the profile named by the header or query of a request, empty when it names none
+func requestedProfile(r *http.Request) string {
+	if name := r.Header.Get(ProfileHeader); name != "" {
+		return name
+	}
+	return r.URL.Query().Get(profileParam)
+}
+
+// RegisterRoutes registers the routes of every profile under its path prefix and once more at the
+// base path, where requests are dispatched by the profile they select. At least one profile must be
+// added and every server must have the same base path.
+func (p *Profiles) RegisterRoutes(mux *http.ServeMux) {
+	for _, name := range p.names {
+		p.servers[name].RegisterRoutes(mux)
+	}
+
+	// The route tables of the servers are the same, a route is at the same index in each
+	tables := make(map[string][]route, len(p.names))
+	for _, name := range p.names {
+		tables[name] = p.servers[name].routes()
+	}
+	defaultServer := p.servers[p.names[0]]
+	for i, rt := range tables[p.names[0]] {
+		handlers := make(map[string]http.HandlerFunc, len(p.names))
+		for _, name := range p.names {
+			handlers[name] = p.servers[name].routeHandler(tables[name][i])
+		}
+		handler := p.dispatch(handlers)
+		if rt.Method == http.MethodGet && rt.Path == "/api/workspaces" {
+			handler = p.aggregateWorkspaces(handler)
+		}
+		mux.HandleFunc(rt.Method+" "+defaultServer.basePath+rt.Path, handler)
+	}
+}
+
+// dispatch passes a request to the handler of the profile it selects, the default one without any
+func (p *Profiles) dispatch(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := requestedProfile(r)
+		if name == "" {
+			name = p.names[0]
+		}
+		handler, ok := handlers[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Profile %q not found", name), http.StatusNotFound)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// aggregateWorkspaces lists the workspaces of every profile the request may read when it selects
+// none, next lists those of the profile it selects
+func (p *Profiles) aggregateWorkspaces(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requestedProfile(r) != "" {
+			next(w, r)
+			return
+		}
+		params, err := parseListParams(r, workspaceSortFields...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		items := []ProfileWorkspace{}
+		readable := 0
+		for _, name := range p.names {
+			s := p.servers[name]
+			profileRequest, ok := s.readRequest(r)
+			if !ok {
+				continue
+			}
+			readable++
+			workspaces, err := s.listWorkspaces(profileRequest)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to list the workspaces of profile %s: %v", name, err), http.StatusInternalServerError)
+				return
+			}
+			for _, ws := range workspaces {
+				items = append(items, ProfileWorkspace{Profile: name, Workspace: ws})
+			}
+		}
+		if readable == 0 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sim-gui"`)
+			http.Error(w, "A valid token is required", http.StatusUnauthorized)
+			return
+		}
+
+		less := workspaceLess(params)
+		items = paginate(w, items, params, func(a, b ProfileWorkspace) bool { return less(a.Workspace, b.Workspace) })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}
+}
+
+// readRequest returns r along with the identity of its token, false when the server requires a token
+// and r has none of its tokens
+func (s *Server) readRequest(r *http.Request) (*http.Request, bool) {
+	if s.tokens == nil {
+		return r, true
+	}
+	identity, ok := s.tokens.Lookup(requestToken(r))
+	if !ok {
+		return nil, false
+	}
+	return r.WithContext(auth.WithIdentity(r.Context(), identity)), true
+}
+
+// handleListProfiles lists the profiles of the server, none when it serves a single data directory
+func (s *Server) handleListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles := []ProfileSummary{}
+	if s.profiles != nil {
+		profiles = s.profiles.summaries()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
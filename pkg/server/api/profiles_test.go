@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+// newProfilesServer serves the profiles customers, the default one, and internal from their own data
+// directories
+func newProfilesServer(t *testing.T) (map[string]*Server, *http.ServeMux) {
+	profiles := NewProfiles()
+	servers := make(map[string]*Server)
+	for _, name := range []string{"customers", "internal"} {
+		dataDir := t.TempDir()
+		st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+		require.NoError(t, err)
+		s := &Server{store: st, events: events.NewBus(), dataDir: dataDir}
+		require.NoError(t, profiles.Add(name, s))
+		servers[name] = s
+	}
+	mux := http.NewServeMux()
+	profiles.RegisterRoutes(mux)
+	return servers, mux
+}
+
+// serveProfile serves a request selecting profile by the header, an empty profile selects none
+func serveProfile(mux *http.ServeMux, method, path, profile, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if profile != "" {
+		r.Header.Set(ProfileHeader, profile)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	return rec
+}
+
+func workspaceNames(t *testing.T, s *Server) []string {
+	workspaces, err := s.store.ListWorkspaces()
+	require.NoError(t, err)
+	names := []string{}
+	for _, ws := range workspaces {
+		names = append(names, ws.Name)
+	}
+	return names
+}
+
+func Test_ProfilesIsolation(t *testing.T) {
+	assert := require.New(t)
+	servers, mux := newProfilesServer(t)
+
+	// by header, by path prefix and by default
+	assert.Equal(http.StatusCreated, serveProfile(mux, "POST", "/api/workspaces", "internal", `{"name": "lab"}`).Code)
+	assert.Equal(http.StatusCreated, serveProfile(mux, "POST", "/profiles/internal/api/workspaces", "", `{"name": "demo"}`).Code)
+	assert.Equal(http.StatusCreated, serveProfile(mux, "POST", "/api/workspaces", "", `{"name": "demo"}`).Code)
+	assert.Equal([]string{"demo"}, workspaceNames(t, servers["customers"]))
+	assert.ElementsMatch([]string{"demo", "lab"}, workspaceNames(t, servers["internal"]))
+	assert.Equal(http.StatusNotFound, serveProfile(mux, "GET", "/api/workspaces/lab", "customers", "").Code)
+	assert.Equal(http.StatusOK, serveProfile(mux, "GET", "/api/workspaces/lab?profile=internal", "", "").Code)
+	assert.Equal(http.StatusNotFound, serveProfile(mux, "GET", "/api/workspaces", "unknown", "").Code)
+
+	// the same workspace in both profiles gets its own directory and simulator
+	customers, err := servers["customers"].createVersion("demo", "v1", writeKubeconfig)
+	assert.NoError(err)
+	internal, err := servers["internal"].createVersion("demo", "v1", writeKubeconfig)
+	assert.NoError(err)
+	assert.Equal("customers-demo-v1", customers.Instance)
+	assert.Equal("internal-demo-v1", internal.Instance)
+	assert.FileExists(filepath.Join(servers["customers"].dataDir, customers.KubeconfigPath))
+	assert.FileExists(filepath.Join(servers["internal"].dataDir, internal.KubeconfigPath))
+	assert.NotEqual(servers["customers"].dataDir, servers["internal"].dataDir)
+
+	// the code-server container is shared, each profile only sees its own projects
+	exec := &recordingExec{stdout: map[string]string{"ls -1 /home/coder/project": "customers-demo-v1\ninternal-demo-v1\n"}}
+	servers["internal"].trackCodeServerProject("demo", "v1", "internal-demo-v1")
+	projects, err := servers["internal"].codeServerProjects(exec)
+	assert.NoError(err)
+	assert.Equal([]CodeServerProject{{Name: "internal-demo-v1", Workspace: "demo", VersionID: "v1"}}, projects)
+	removed, err := servers["customers"].purgeCodeServerProjects(exec, false)
+	assert.NoError(err)
+	assert.Equal([]string{"customers-demo-v1"}, removed)
+}
+
+func Test_ProfilesListWorkspaces(t *testing.T) {
+	assert := require.New(t)
+	servers, mux := newProfilesServer(t)
+	for name, workspaces := range map[string][]string{"customers": {"acme", "zeta"}, "internal": {"lab", "acme"}} {
+		for _, ws := range workspaces {
+			assert.NoError(servers[name].store.CreateWorkspace(model.Workspace{Name: ws}))
+		}
+	}
+	list := func(path, profile string) ([]ProfileWorkspace, string) {
+		rec := serveProfile(mux, "GET", path, profile, "")
+		assert.Equal(http.StatusOK, rec.Code)
+		var items []ProfileWorkspace
+		assert.NoError(json.NewDecoder(rec.Body).Decode(&items))
+		return items, rec.Header().Get("X-Total-Count")
+	}
+	names := func(items []ProfileWorkspace) []string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Profile+"/"+item.Name)
+		}
+		return names
+	}
+
+	items, total := list("/api/workspaces", "")
+	assert.Equal([]string{"customers/acme", "internal/acme", "internal/lab", "customers/zeta"}, names(items))
+	assert.Equal("4", total)
+	items, total = list("/api/workspaces?limit=2&offset=1&order=desc", "")
+	assert.Equal([]string{"internal/lab", "customers/acme"}, names(items))
+	assert.Equal("4", total)
+
+	// a selected profile lists its workspaces as before
+	items, _ = list("/api/workspaces", "internal")
+	assert.Equal([]string{"/acme", "/lab"}, names(items))
+	items, _ = list("/profiles/customers/api/workspaces", "")
+	assert.Equal([]string{"/acme", "/zeta"}, names(items))
+
+	rec := serveProfile(mux, "GET", "/api/profiles", "", "")
+	assert.JSONEq(`[{"name": "customers", "default": true}, {"name": "internal", "default": false}]`, rec.Body.String())
+}
+
+func Test_ProfilesTokens(t *testing.T) {
+	assert := require.New(t)
+	servers, mux := newProfilesServer(t)
+	assert.NoError(servers["customers"].store.CreateWorkspace(model.Workspace{Name: "acme"}))
+	assert.NoError(servers["internal"].store.CreateWorkspace(model.Workspace{Name: "lab"}))
+	path := filepath.Join(t.TempDir(), auth.FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [{"token": "internal-token", "user": "ivy", "role": "viewer"}]}`), 0600))
+	tokens, err := auth.Load(path)
+	assert.NoError(err)
+	servers["internal"].SetTokens(tokens)
+	list := func(path, token string) (int, []string) {
+		r := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		var items []ProfileWorkspace
+		json.NewDecoder(rec.Body).Decode(&items)
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Profile+"/"+item.Name)
+		}
+		return rec.Code, names
+	}
+
+	// the workspaces of a profile are only listed with one of its tokens
+	code, names := list("/api/workspaces", "")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]string{"customers/acme"}, names)
+	code, names = list("/api/workspaces", "internal-token")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal([]string{"customers/acme", "internal/lab"}, names)
+	code, _ = list("/api/workspaces?profile=internal", "")
+	assert.Equal(http.StatusUnauthorized, code)
+}
+
+func Test_ProfileNames(t *testing.T) {
+	assert := require.New(t)
+	for _, name := range []string{"customers", "lab2"} {
+		assert.NoError(ValidateProfileName(name))
+	}
+	for _, name := range []string{"", "Customers", "a-b", "a/b", "a.b"} {
+		assert.Error(ValidateProfileName(name), name)
+	}
+	profiles := NewProfiles()
+	assert.NoError(profiles.Add("lab", &Server{}))
+	assert.Error(profiles.Add("lab", &Server{}))
+
+	// a server without profiles names and links its simulators as before
+	single := &Server{}
+	assert.Equal("demo-v1", single.instanceName("demo", "v1"))
+	assert.Equal("http://example.com", single.serverURL(httptest.NewRequest("GET", "http://example.com/api/workspaces", nil)).String())
+	profile := &Server{}
+	assert.NoError(profiles.Add("customers", profile))
+	assert.Equal("customers-demo-v1", profile.instanceName("demo", "v1"))
+	assert.Equal("http://example.com/profiles/customers", profile.serverURL(httptest.NewRequest("GET", "http://example.com/api/workspaces", nil)).String())
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/simulator"
+)
+
+// progressPublishInterval limits how often load progress is sent to event subscribers
+const progressPublishInterval = time.Second
+
+// startProgress replaces the tracker of an instance, so progress restarts with the container
+func (s *Server) startProgress(instanceName string) *simulator.Tracker {
+	tracker := simulator.NewTracker(time.Now())
+	s.progressLock.Lock()
+	s.progress[instanceName] = tracker
+	s.progressLock.Unlock()
+	return tracker
+}
+
+func (s *Server) clearProgress(instanceName string) {
+	s.progressLock.Lock()
+	delete(s.progress, instanceName)
+	s.progressLock.Unlock()
+}
+
+// simulatorProgress returns the load progress of an instance, nil when it isn't monitored
+func (s *Server) simulatorProgress(instanceName string) *simulator.Progress {
+	s.progressLock.Lock()
+	tracker, ok := s.progress[instanceName]
+	s.progressLock.Unlock()
+	if !ok {
+		return nil
+	}
+	progress := tracker.Progress()
+	return &progress
+}
+
+// publishProgress sends the progress of tracker unless a newer monitor replaced it
+func (s *Server) publishProgress(workspaceName, versionID, instanceName string, tracker *simulator.Tracker) {
+	s.progressLock.Lock()
+	current := s.progress[instanceName] == tracker
+	s.progressLock.Unlock()
+	if current {
+		s.events.Publish(events.SimulatorProgress, workspaceName, versionID, tracker.Progress())
+	}
+}
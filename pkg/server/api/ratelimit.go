@@ -0,0 +1,171 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+)
+
+// RateLimit is how many requests per second a client may make to the Limited routes, with bursts of
+// up to Burst requests. A zero Rate doesn't limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// rateLimitSweepInterval is how often buckets that refilled are dropped, a full bucket is the same as
+// none
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the requests a client may still make, refilled at the rate of the limit
+type tokenBucket struct {
+	tokens float64
+	filled time.Time
+}
+
+// rateLimiter keeps a token bucket per client
+type rateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter(limit RateLimit, now func() time.Time) *rateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &rateLimiter{limit: limit, now: now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from the bucket of key, when it is empty it returns how long until the next one
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), filled: now}
+		l.buckets[key] = bucket
+	}
+	l.refill(bucket, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// refill adds the tokens earned since the bucket was last filled
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.filled); elapsed > 0 {
+		bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+elapsed.Seconds()*l.limit.Rate)
+	}
+	bucket.filled = now
+}
+
+// sweep drops the buckets that are full again, so clients seen once aren't kept forever
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// SetRateLimit limits the requests each client makes to the expensive routes, queries of simulators
+// and uploads. Clients are told apart by their token, or by their address without tokens. A zero
+// Rate doesn't limit. The servers of profiles share the limiter of the first one that sets a limit, a
+// client gets the same requests across profiles. It must be called after the server is added to its
+// profiles and before the routes are registered.
+func (s *Server) SetRateLimit(limit RateLimit) {
+	if limit.Rate <= 0 {
+		s.limiter = nil
+		return
+	}
+	if s.profiles == nil {
+		s.limiter = newRateLimiter(limit, time.Now)
+		return
+	}
+	if s.profiles.limiter == nil {
+		s.profiles.limiter = newRateLimiter(limit, time.Now)
+	}
+	s.limiter = s.profiles.limiter
+}
+
+// rateLimit answers 429 to clients that made too many requests to a Limited route. Without tokens
+// local clients aren't limited, they are the user of the browser.
+func (s *Server) rateLimit(rt route, next http.HandlerFunc) http.HandlerFunc {
+	if !rt.Limited {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next(w, r)
+			return
+		}
+		key, ok := s.rateLimitKey(r)
+		if !ok {
+			next(w, r)
+			return
+		}
+		if allowed, wait := s.limiter.allow(key); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitKey returns who a request is counted for, false for requests that aren't limited
+func (s *Server) rateLimitKey(r *http.Request) (string, bool) {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return "user:" + identity.User, true
+	}
+	host := s.clientAddr(r)
+	if s.tokens == nil {
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return "", false
+		}
+	}
+	return "addr:" + host, true
+}
+
+// clientAddr returns the address of the client of a request. Behind a trusted proxy it is the last
+// address of X-Forwarded-For, the one the proxy added, those before it are sent by the client.
+func (s *Server) clientAddr(r *http.Request) string {
+	if s.trustForwarded {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if addr := strings.TrimSpace(last); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
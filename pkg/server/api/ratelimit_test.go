@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RateLimiter(t *testing.T) {
+	assert := require.New(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(RateLimit{Rate: 2, Burst: 3}, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := l.allow("a")
+		assert.True(allowed)
+	}
+	allowed, wait := l.allow("a")
+	assert.False(allowed)
+	assert.Equal(500*time.Millisecond, wait)
+	// Other clients have their own bucket
+	allowed, _ = l.allow("b")
+	assert.True(allowed)
+
+	// Tokens come back at the rate, up to the burst
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = l.allow("a")
+	assert.True(allowed)
+	allowed, _ = l.allow("a")
+	assert.False(allowed)
+
+	// Buckets that filled up again are dropped
+	now = now.Add(time.Hour)
+	l.allow("c")
+	assert.Len(l.buckets, 1)
+}
+
+func Test_RateLimit(t *testing.T) {
+	assert := require.New(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &Server{}
+	s.SetRateLimit(RateLimit{Rate: 1, Burst: 2})
+	s.limiter.now = func() time.Time { return now }
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	for _, rt := range []route{
+		{Method: "GET", Path: "/api/workspaces/{name}/resources", Limited: true},
+		{Method: "GET", Path: "/api/workspaces/{name}/versions/{versionID}/status"},
+	} {
+		mux.HandleFunc(rt.Method+" "+rt.Path, s.authorize(rt, s.rateLimit(rt, ok)))
+	}
+	serve := func(path, addr, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = addr
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	resources := "/api/workspaces/demo/resources"
+	status := "/api/workspaces/demo/versions/v1/status"
+	for i := 0; i < 2; i++ {
+		assert.Equal(http.StatusOK, serve(resources, "10.0.0.1:5000", "").Code)
+	}
+	w := serve(resources, "10.0.0.1:5001", "")
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal("1", w.Header().Get("Retry-After"))
+	// Routes that aren't Limited, other clients and local clients without tokens are let through
+	assert.Equal(http.StatusOK, serve(status, "10.0.0.1:5000", "").Code)
+	assert.Equal(http.StatusOK, serve(resources, "10.0.0.2:5000", "").Code)
+	for i := 0; i < 5; i++ {
+		assert.Equal(http.StatusOK, serve(resources, "127.0.0.1:5000", "").Code)
+		assert.Equal(http.StatusOK, serve(resources, "[::1]:5000", "").Code)
+	}
+	now = now.Add(time.Second)
+	assert.Equal(http.StatusOK, serve(resources, "10.0.0.1:5000", "").Code)
+
+	// With tokens clients are told apart by their user, local ones included
+	path := filepath.Join(t.TempDir(), auth.FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [
+		{"token": "alice-token", "user": "alice", "role": "viewer"},
+		{"token": "eve-token", "user": "eve", "role": "viewer"}
+	]}`), 0600))
+	tokens, err := auth.Load(path)
+	assert.NoError(err)
+	s.SetTokens(tokens)
+	assert.Equal(http.StatusOK, serve(resources, "127.0.0.1:5000", "alice-token").Code)
+	assert.Equal(http.StatusOK, serve(resources, "127.0.0.2:5000", "alice-token").Code)
+	assert.Equal(http.StatusTooManyRequests, serve(resources, "127.0.0.3:5000", "alice-token").Code)
+	assert.Equal(http.StatusOK, serve(resources, "127.0.0.1:5000", "eve-token").Code)
+
+	// A zero rate doesn't limit
+	s.SetRateLimit(RateLimit{})
+	assert.Equal(http.StatusOK, serve(resources, "127.0.0.1:5000", "alice-token").Code)
+}
+
+func Test_RateLimitBehindProxy(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+	s.SetRateLimit(RateLimit{Rate: 1, Burst: 1})
+	s.SetTrustForwardedHeaders(true)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.limiter.now = func() time.Time { return now }
+
+	rt := route{Method: "GET", Path: "/api/workspaces/{name}/resources", Limited: true}
+	handler := s.rateLimit(rt, func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(forwardedFor string) int {
+		r := httptest.NewRequest("GET", "/api/workspaces/demo/resources", nil)
+		r.RemoteAddr = "127.0.0.1:5000"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	// Clients of a local proxy are told apart by the address it forwards, and aren't exempt
+	assert.Equal(http.StatusOK, serve("10.0.0.1"))
+	assert.Equal(http.StatusTooManyRequests, serve("10.0.0.1"))
+	assert.Equal(http.StatusOK, serve("10.0.0.2"))
+	// Addresses sent by the client before the one of the proxy are ignored
+	assert.Equal(http.StatusTooManyRequests, serve("192.168.0.9, 10.0.0.2"))
+	// The proxy itself is still local
+	assert.Equal(http.StatusOK, serve(""))
+	assert.Equal(http.StatusOK, serve(""))
+}
+
+func Test_RateLimitProfiles(t *testing.T) {
+	assert := require.New(t)
+	profiles := NewProfiles()
+	first, second := &Server{}, &Server{}
+	assert.NoError(profiles.Add("first", first))
+	assert.NoError(profiles.Add("second", second))
+	first.SetRateLimit(RateLimit{Rate: 1, Burst: 1})
+	second.SetRateLimit(RateLimit{Rate: 1, Burst: 1})
+
+	// A client gets its requests across profiles, not per profile
+	assert.Same(first.limiter, second.limiter)
+	allowed, _ := first.limiter.allow("addr:10.0.0.1")
+	assert.True(allowed)
+	allowed, _ = second.limiter.allow("addr:10.0.0.1")
+	assert.False(allowed)
+}
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// rbacRequest is the access being checked, like the arguments of kubectl auth can-i
+type rbacRequest struct {
+	Verb         string
+	APIGroup     string
+	Resource     string
+	Subresource  string
+	ResourceName string
+	// Namespace is empty for cluster scoped resources or requests across all namespaces
+	Namespace string
+}
+
+// RBACGrant is a binding giving a subject the access through the rules of a role
+type RBACGrant struct {
+	BindingKind      string `json:"bindingKind"`
+	Binding          string `json:"binding"`
+	BindingNamespace string `json:"bindingNamespace,omitempty"`
+	RoleKind         string `json:"roleKind"`
+	Role             string `json:"role"`
+}
+
+// RBACSubject is a user, group or service account allowed to perform the request
+type RBACSubject struct {
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Grants    []RBACGrant `json:"grants"`
+}
+
+type RBACCheckResult struct {
+	// Subjects allowed to perform the request, when no service account was given
+	Subjects []RBACSubject `json:"subjects,omitempty"`
+	// ServiceAccount is the checked service account as namespace/name
+	ServiceAccount string      `json:"serviceAccount,omitempty"`
+	Allowed        bool        `json:"allowed"`
+	Grants         []RBACGrant `json:"grants,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+type policyRule struct {
+	Verbs           []string `yaml:"verbs"`
+	APIGroups       []string `yaml:"apiGroups"`
+	Resources       []string `yaml:"resources"`
+	ResourceNames   []string `yaml:"resourceNames"`
+	NonResourceURLs []string `yaml:"nonResourceURLs"`
+}
+
+type labelSelector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `yaml:"key"`
+		Operator string   `yaml:"operator"`
+		Values   []string `yaml:"values"`
+	} `yaml:"matchExpressions"`
+}
+
+type rbacSubject struct {
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// rbacObject is a Role, ClusterRole, RoleBinding or ClusterRoleBinding
+type rbacObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Rules           []policyRule `yaml:"rules"`
+	AggregationRule *struct {
+		ClusterRoleSelectors []labelSelector `yaml:"clusterRoleSelectors"`
+	} `yaml:"aggregationRule"`
+	RoleRef struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"roleRef"`
+	Subjects []rbacSubject `yaml:"subjects"`
+}
+
+type rbacObjectList struct {
+	Items []rbacObject `yaml:"items"`
+}
+
+// rbacCheckRequest is the body of an RBAC check
+type rbacCheckRequest struct {
+	Verb           string `json:"verb"`
+	Resource       string `json:"resource"` // e.g. pods, pods/log or deployments.apps
+	ResourceName   string `json:"resourceName"`
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"serviceAccount"` // name in namespace, namespace/name or system:serviceaccount:namespace:name
+}
+
+func (s *Server) handleRBACCheck(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	var req rbacCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Verb == "" || req.Resource == "" {
+		http.Error(w, "verb and resource are required", http.StatusBadRequest)
+		return
+	}
+	request := parseRBACResource(req.Resource)
+	request.Verb = req.Verb
+	request.ResourceName = req.ResourceName
+	request.Namespace = req.Namespace
+
+	var saNamespace, saName string
+	if req.ServiceAccount != "" {
+		var ok bool
+		saNamespace, saName, ok = parseServiceAccount(req.ServiceAccount, req.Namespace)
+		if !ok {
+			http.Error(w, "serviceAccount needs a namespace, as namespace/name or through namespace", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	writeResult := func(result RBACCheckResult) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+
+	exec, err := s.GetExecutor(name, versionID)
+	if err != nil {
+		writeResult(RBACCheckResult{Error: fmt.Sprintf("Failed to get executor: %v", err)})
+		return
+	}
+
+	var objects rbacObjectList
+	stderr, err := utils.DecodeKubectlYAML(r.Context(), exec, &objects, "get", "clusterroles,clusterrolebindings,roles,rolebindings", "-A", "-o", "yaml")
+	if err != nil {
+		writeResult(RBACCheckResult{Error: fmt.Sprintf("Failed to get RBAC objects: %v %s", err, strings.TrimSpace(stderr))})
+		return
+	}
+
+	policy := newRBACPolicy(objects.Items)
+	if saName != "" {
+		grants := policy.grantsFor(request, serviceAccountMatcher(saNamespace, saName))
+		writeResult(RBACCheckResult{
+			ServiceAccount: saNamespace + "/" + saName,
+			Allowed:        len(grants) > 0,
+			Grants:         grants,
+		})
+		return
+	}
+	subjects := policy.subjectsFor(request)
+	writeResult(RBACCheckResult{Subjects: subjects, Allowed: len(subjects) > 0})
+}
+
+// parseRBACResource splits resource[.group][/subresource], like the resource argument of kubectl auth can-i
+func parseRBACResource(resource string) rbacRequest {
+	var req rbacRequest
+	resource, req.Subresource, _ = strings.Cut(resource, "/")
+	req.Resource, req.APIGroup, _ = strings.Cut(resource, ".")
+	return req
+}
+
+// parseServiceAccount accepts system:serviceaccount:namespace:name, namespace/name, or a name in defaultNamespace
+func parseServiceAccount(sa, defaultNamespace string) (string, string, bool) {
+	if rest, ok := strings.CutPrefix(sa, "system:serviceaccount:"); ok {
+		namespace, name, ok := strings.Cut(rest, ":")
+		return namespace, name, ok && namespace != "" && name != ""
+	}
+	if namespace, name, ok := strings.Cut(sa, "/"); ok {
+		return namespace, name, namespace != "" && name != ""
+	}
+	return defaultNamespace, sa, defaultNamespace != ""
+}
+
+// rbacPolicy evaluates requests against the roles and bindings of a cluster the way the RBAC
+// authorizer of the API server does, which only ever allows
+type rbacPolicy struct {
+	roles        map[string]rbacObject // By namespace/name, cluster roles have no namespace
+	clusterRoles []rbacObject
+	bindings     []rbacObject
+}
+
+func newRBACPolicy(objects []rbacObject) *rbacPolicy {
+	p := &rbacPolicy{roles: map[string]rbacObject{}}
+	for _, obj := range objects {
+		switch obj.Kind {
+		case "Role":
+			p.roles[obj.Metadata.Namespace+"/"+obj.Metadata.Name] = obj
+		case "ClusterRole":
+			p.roles["/"+obj.Metadata.Name] = obj
+			p.clusterRoles = append(p.clusterRoles, obj)
+		case "RoleBinding", "ClusterRoleBinding":
+			p.bindings = append(p.bindings, obj)
+		}
+	}
+	sort.Slice(p.bindings, func(i, j int) bool {
+		a, b := p.bindings[i], p.bindings[j]
+		if a.Kind != b.Kind {
+			// Cluster wide grants first
+			return a.Kind == "ClusterRoleBinding"
+		}
+		if a.Metadata.Namespace != b.Metadata.Namespace {
+			return a.Metadata.Namespace < b.Metadata.Namespace
+		}
+		return a.Metadata.Name < b.Metadata.Name
+	})
+	return p
+}
+
+// roleRules returns the rules of a role, an aggregated cluster role also gets the rules of the
+// cluster roles its selectors match, as the aggregation controller may not run in the simulator
+func (p *rbacPolicy) roleRules(namespace, kind, name string) []policyRule {
+	key := "/" + name
+	if kind == "Role" {
+		key = namespace + "/" + name
+	}
+	return p.aggregatedRules(key, map[string]bool{})
+}
+
+func (p *rbacPolicy) aggregatedRules(key string, visited map[string]bool) []policyRule {
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+	role, ok := p.roles[key]
+	if !ok {
+		return nil
+	}
+	rules := role.Rules
+	if role.Kind != "ClusterRole" || role.AggregationRule == nil {
+		return rules
+	}
+	for _, other := range p.clusterRoles {
+		for _, selector := range role.AggregationRule.ClusterRoleSelectors {
+			if selector.matches(other.Metadata.Labels) {
+				rules = append(slices.Clip(rules), p.aggregatedRules("/"+other.Metadata.Name, visited)...)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+// bindingGrant is a binding allowing a request
+type bindingGrant struct {
+	grant   RBACGrant
+	binding rbacObject
+}
+
+// grants returns the bindings allowing the request
+func (p *rbacPolicy) grants(req rbacRequest) []bindingGrant {
+	var result []bindingGrant
+	for _, binding := range p.bindings {
+		// Role bindings only grant access inside their namespace
+		if binding.Kind == "RoleBinding" && binding.Metadata.Namespace != req.Namespace {
+			continue
+		}
+		rules := p.roleRules(binding.Metadata.Namespace, binding.RoleRef.Kind, binding.RoleRef.Name)
+		if !slices.ContainsFunc(rules, func(rule policyRule) bool { return rule.allows(req) }) {
+			continue
+		}
+		result = append(result, bindingGrant{
+			grant: RBACGrant{
+				BindingKind:      binding.Kind,
+				Binding:          binding.Metadata.Name,
+				BindingNamespace: binding.Metadata.Namespace,
+				RoleKind:         binding.RoleRef.Kind,
+				Role:             binding.RoleRef.Name,
+			},
+			binding: binding,
+		})
+	}
+	return result
+}
+
+// subjectsFor returns the subjects allowed to perform the request with the bindings allowing it
+func (p *rbacPolicy) subjectsFor(req rbacRequest) []RBACSubject {
+	var subjects []RBACSubject
+	index := map[string]int{}
+	for _, g := range p.grants(req) {
+		for _, subject := range g.binding.Subjects {
+			namespace := subject.Namespace
+			if subject.Kind == "ServiceAccount" && namespace == "" {
+				namespace = g.binding.Metadata.Namespace
+			}
+			key := subject.Kind + "/" + namespace + "/" + subject.Name
+			i, ok := index[key]
+			if !ok {
+				i = len(subjects)
+				index[key] = i
+				subjects = append(subjects, RBACSubject{Kind: subject.Kind, Name: subject.Name, Namespace: namespace})
+			}
+			if !slices.Contains(subjects[i].Grants, g.grant) {
+				subjects[i].Grants = append(subjects[i].Grants, g.grant)
+			}
+		}
+	}
+	return subjects
+}
+
+// grantsFor returns the bindings allowing the request to a subject matched by match
+func (p *rbacPolicy) grantsFor(req rbacRequest, match func(subject rbacSubject, bindingNamespace string) bool) []RBACGrant {
+	var grants []RBACGrant
+	for _, g := range p.grants(req) {
+		for _, subject := range g.binding.Subjects {
+			if match(subject, g.binding.Metadata.Namespace) {
+				grants = append(grants, g.grant)
+				break
+			}
+		}
+	}
+	return grants
+}
+
+// serviceAccountMatcher matches the subjects a service account authenticates as: itself, its
+// user name and the groups of all service accounts, those of its namespace and authenticated users
+func serviceAccountMatcher(namespace, name string) func(subject rbacSubject, bindingNamespace string) bool {
+	return func(subject rbacSubject, bindingNamespace string) bool {
+		switch subject.Kind {
+		case "ServiceAccount":
+			subjectNamespace := subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = bindingNamespace
+			}
+			return subject.Name == name && subjectNamespace == namespace
+		case "User":
+			return subject.Name == "system:serviceaccount:"+namespace+":"+name
+		case "Group":
+			return subject.Name == "system:serviceaccounts" || subject.Name == "system:serviceaccounts:"+namespace ||
+				subject.Name == "system:authenticated"
+		}
+		return false
+	}
+}
+
+// allows reports whether the rule covers the request
+func (r policyRule) allows(req rbacRequest) bool {
+	return matchesAny(r.Verbs, req.Verb) &&
+		matchesAny(r.APIGroups, req.APIGroup) &&
+		r.matchesResource(req) &&
+		(len(r.ResourceNames) == 0 || (req.ResourceName != "" && slices.Contains(r.ResourceNames, req.ResourceName)))
+}
+
+func (r policyRule) matchesResource(req rbacRequest) bool {
+	combined := req.Resource
+	if req.Subresource != "" {
+		combined += "/" + req.Subresource
+	}
+	for _, resource := range r.Resources {
+		switch {
+		case resource == "*", resource == combined:
+			return true
+		case req.Subresource != "" && resource == "*/"+req.Subresource:
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether values holds value or the * wildcard
+func matchesAny(values []string, value string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, value)
+}
+
+// matches evaluates the selector against labels, an empty selector matches everything
+func (s labelSelector) matches(labels map[string]string) bool {
+	for key, value := range s.MatchLabels {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	for _, expr := range s.MatchExpressions {
+		value, ok := labels[expr.Key]
+		switch expr.Operator {
+		case "In":
+			if !ok || !slices.Contains(expr.Values, value) {
+				return false
+			}
+		case "NotIn":
+			if ok && slices.Contains(expr.Values, value) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const rbacFixture = `apiVersion: v1
+kind: List
+items:
+- kind: ClusterRole
+  metadata:
+    name: cluster-admin
+  rules:
+  - apiGroups: ["*"]
+    resources: ["*"]
+    verbs: ["*"]
+- kind: ClusterRole
+  metadata:
+    name: monitoring
+  aggregationRule:
+    clusterRoleSelectors:
+    - matchLabels:
+        example.com/aggregate-to-monitoring: "true"
+  rules: []
+- kind: ClusterRole
+  metadata:
+    name: monitoring-pods
+    labels:
+      example.com/aggregate-to-monitoring: "true"
+  rules:
+  - apiGroups: [""]
+    resources: ["pods", "pods/log"]
+    verbs: ["get", "list"]
+- kind: ClusterRole
+  metadata:
+    name: backup-secret-reader
+  rules:
+  - apiGroups: [""]
+    resources: ["secrets"]
+    resourceNames: ["backup-credentials"]
+    verbs: ["get"]
+- kind: Role
+  metadata:
+    name: vm-operator
+    namespace: default
+  rules:
+  - apiGroups: ["kubevirt.io"]
+    resources: ["virtualmachines", "*/status"]
+    verbs: ["get", "update"]
+- kind: ClusterRoleBinding
+  metadata:
+    name: admins
+  roleRef:
+    kind: ClusterRole
+    name: cluster-admin
+  subjects:
+  - kind: Group
+    name: system:masters
+- kind: ClusterRoleBinding
+  metadata:
+    name: monitoring
+  roleRef:
+    kind: ClusterRole
+    name: monitoring
+  subjects:
+  - kind: ServiceAccount
+    name: prometheus
+    namespace: cattle-monitoring-system
+- kind: RoleBinding
+  metadata:
+    name: backup
+    namespace: longhorn-system
+  roleRef:
+    kind: ClusterRole
+    name: backup-secret-reader
+  subjects:
+  - kind: ServiceAccount
+    name: longhorn-service-account
+- kind: RoleBinding
+  metadata:
+    name: vm-operator
+    namespace: default
+  roleRef:
+    kind: Role
+    name: vm-operator
+  subjects:
+  - kind: Group
+    name: system:serviceaccounts:default
+`
+
+func newFixturePolicy(t *testing.T) *rbacPolicy {
+	var list rbacObjectList
+	require.NoError(t, yaml.Unmarshal([]byte(rbacFixture), &list))
+	return newRBACPolicy(list.Items)
+}
+
+func rbacReq(verb, resource, name, namespace string) rbacRequest {
+	req := parseRBACResource(resource)
+	req.Verb, req.ResourceName, req.Namespace = verb, name, namespace
+	return req
+}
+
+func Test_RBACSubjectsFor(t *testing.T) {
+	assert := require.New(t)
+	policy := newFixturePolicy(t)
+
+	// the rules of monitoring come from the cluster roles it aggregates
+	subjects := policy.subjectsFor(rbacReq("list", "pods", "", "default"))
+	assert.Len(subjects, 2)
+	assert.Equal("system:masters", subjects[0].Name)
+	assert.Equal(RBACSubject{
+		Kind: "ServiceAccount", Name: "prometheus", Namespace: "cattle-monitoring-system",
+		Grants: []RBACGrant{{BindingKind: "ClusterRoleBinding", Binding: "monitoring", RoleKind: "ClusterRole", Role: "monitoring"}},
+	}, subjects[1])
+
+	subjects = policy.subjectsFor(rbacReq("get", "pods/log", "", "default"))
+	assert.Len(subjects, 2)
+	subjects = policy.subjectsFor(rbacReq("delete", "pods", "", "default"))
+	assert.Len(subjects, 1)
+
+	// the service account of a role binding subject defaults to the namespace of the binding
+	subjects = policy.subjectsFor(rbacReq("get", "secrets", "backup-credentials", "longhorn-system"))
+	assert.Len(subjects, 2)
+	assert.Equal("longhorn-system", subjects[1].Namespace)
+	assert.Equal("longhorn-service-account", subjects[1].Name)
+}
+
+func Test_RBACGrantsForServiceAccount(t *testing.T) {
+	assert := require.New(t)
+	policy := newFixturePolicy(t)
+	longhorn := serviceAccountMatcher("longhorn-system", "longhorn-service-account")
+
+	// resourceNames only allow the named objects
+	assert.Len(policy.grantsFor(rbacReq("get", "secrets", "backup-credentials", "longhorn-system"), longhorn), 1)
+	assert.Empty(policy.grantsFor(rbacReq("get", "secrets", "other", "longhorn-system"), longhorn))
+	assert.Empty(policy.grantsFor(rbacReq("list", "secrets", "", "longhorn-system"), longhorn))
+	// role bindings don't grant anything outside their namespace
+	assert.Empty(policy.grantsFor(rbacReq("get", "secrets", "backup-credentials", "default"), longhorn))
+	assert.Empty(policy.grantsFor(rbacReq("get", "secrets", "backup-credentials", ""), longhorn))
+
+	// bound through the group of all service accounts of the namespace, */status matches any status subresource
+	builder := serviceAccountMatcher("default", "builder")
+	grants := policy.grantsFor(rbacReq("update", "virtualmachines.kubevirt.io/status", "", "default"), builder)
+	assert.Equal([]RBACGrant{{BindingKind: "RoleBinding", Binding: "vm-operator", BindingNamespace: "default", RoleKind: "Role", Role: "vm-operator"}}, grants)
+	assert.Empty(policy.grantsFor(rbacReq("update", "virtualmachines/status", "", "default"), builder), "wrong API group")
+	assert.Empty(policy.grantsFor(rbacReq("delete", "virtualmachines.kubevirt.io", "", "default"), builder))
+	assert.Empty(policy.grantsFor(rbacReq("get", "virtualmachines.kubevirt.io", "", "default"), serviceAccountMatcher("other", "builder")))
+}
+
+func Test_ParseServiceAccount(t *testing.T) {
+	assert := require.New(t)
+
+	namespace, name, ok := parseServiceAccount("system:serviceaccount:kube-system:coredns", "")
+	assert.True(ok)
+	assert.Equal("kube-system/coredns", namespace+"/"+name)
+	namespace, name, ok = parseServiceAccount("kube-system/coredns", "default")
+	assert.True(ok)
+	assert.Equal("kube-system/coredns", namespace+"/"+name)
+	namespace, name, ok = parseServiceAccount("coredns", "kube-system")
+	assert.True(ok)
+	assert.Equal("kube-system/coredns", namespace+"/"+name)
+	_, _, ok = parseServiceAccount("coredns", "")
+	assert.False(ok)
+}
+
+func Test_LabelSelectorMatches(t *testing.T) {
+	assert := require.New(t)
+	var selector labelSelector
+	assert.NoError(yaml.Unmarshal([]byte(`
+matchExpressions:
+- key: tier
+  operator: In
+  values: [frontend, backend]
+- key: legacy
+  operator: DoesNotExist
+`), &selector))
+	assert.True(selector.matches(map[string]string{"tier": "backend"}))
+	assert.False(selector.matches(map[string]string{"tier": "db"}))
+	assert.False(selector.matches(map[string]string{"tier": "backend", "legacy": "true"}))
+	assert.True(labelSelector{}.matches(nil))
+}
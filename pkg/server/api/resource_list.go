@@ -0,0 +1,117 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/docker/docker/api/types"
+)
+
+// Reasons a version is skipped by a query across versions
+const (
+	skipNotRunning = "not_running"
+	// skipNotReady versions run a simulator still loading its bundle, its apiserver doesn't answer yet
+	skipNotReady  = "not_ready"
+	skipExecError = "exec_error"
+)
+
+// VersionSkip tells why a version didn't answer a query across versions
+type VersionSkip struct {
+	VersionID string `json:"versionID"`
+	Reason    string `json:"reason"` // "not_running", "not_ready" or "exec_error"
+	Error     string `json:"error,omitempty"`
+}
+
+// ResourceList is the answer of GET /resources with include=versions, the names are merged from the
+// versions queried. A resource missing from the names can still exist in a skipped version.
+type ResourceList struct {
+	Names           []string      `json:"names"`
+	VersionsQueried []string      `json:"versionsQueried"`
+	VersionsSkipped []VersionSkip `json:"versionsSkipped"`
+}
+
+// runningContainerFinder is the part of the docker client telling whether a simulator runs
+type runningContainerFinder interface {
+	FindRunningContainer(instanceName string) ([]types.Container, error)
+}
+
+// resourceNames are the resource names of a type collected from the versions of a workspace
+type resourceNames struct {
+	names map[string]bool
+	// available counts the versions that answered or run a simulator still loading
+	available int
+	// snapshotAt is the oldest snapshot stopped simulators answered from
+	snapshotAt *time.Time
+	queried    []string
+	skipped    []VersionSkip
+}
+
+// collectResourceNames lists the resources of a type in every version, or in versionID only. Stopped
+// simulators answer from their snapshot, simulators still loading their bundle are skipped rather than
+// queried until kubectl times out.
+func (s *Server) collectResourceNames(finder runningContainerFinder, execFor func(versionID string) (executor.Executor, error), ws *model.Workspace, namespace, resourceType, versionID string) resourceNames {
+	collected := resourceNames{names: make(map[string]bool), queried: []string{}, skipped: []VersionSkip{}}
+	skip := func(v model.Version, reason string, err error) {
+		skipped := VersionSkip{VersionID: v.ID, Reason: reason}
+		if err != nil {
+			skipped.Error = err.Error()
+		}
+		collected.skipped = append(collected.skipped, skipped)
+	}
+
+	for _, v := range ws.Versions {
+		if versionID != "" && v.ID != versionID {
+			continue
+		}
+
+		if v.Type != model.VersionTypeRuntime {
+			containers, err := finder.FindRunningContainer(v.InstanceName(ws.Name))
+			if err != nil || len(containers) == 0 {
+				snap, ok := s.latestSnapshot(ws.Name, v.ID)
+				if !ok {
+					skip(v, skipNotRunning, nil)
+					continue
+				}
+				collected.available++
+				collected.queried = append(collected.queried, v.ID)
+				for _, res := range snap.names(namespace, resourceType) {
+					collected.names[res] = true
+				}
+				if collected.snapshotAt == nil || snap.CapturedAt.Before(*collected.snapshotAt) {
+					collected.snapshotAt = &snap.CapturedAt
+				}
+				continue
+			}
+			// Counted as available, it answers once loaded so nothing else is started meanwhile
+			if !v.Ready {
+				collected.available++
+				skip(v, skipNotReady, nil)
+				continue
+			}
+		}
+
+		exec, err := execFor(v.ID)
+		if err != nil {
+			skip(v, skipExecError, err)
+			continue
+		}
+		collected.available++
+
+		out, err := utils.ExecKubectl(exec, "get", resourceType, "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
+		if _, err = utils.KubectlStatus(out, err); err != nil {
+			skip(v, skipExecError, err)
+			continue
+		}
+		collected.queried = append(collected.queried, v.ID)
+
+		for _, res := range strings.Split(strings.TrimSpace(out.Stdout), " ") {
+			if res != "" {
+				collected.names[res] = true
+			}
+		}
+	}
+	return collected
+}
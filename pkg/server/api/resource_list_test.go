@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+// runningFinder reports the simulators of running as running
+type runningFinder struct {
+	running map[string]bool
+}
+
+func (f *runningFinder) FindRunningContainer(instanceName string) ([]types.Container, error) {
+	if !f.running[instanceName] {
+		return nil, nil
+	}
+	return []types.Container{{ID: instanceName}}, nil
+}
+
+func Test_CollectResourceNamesAcrossVersions(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newWorkspaceServer(t)
+	ws := model.Workspace{Name: "ws", Versions: []model.Version{
+		{ID: "v1", Type: model.VersionTypeSupportBundle, Ready: true},
+		// Running, its apiserver is still loading the bundle
+		{ID: "v2", Type: model.VersionTypeSupportBundle},
+		// Stopped without a snapshot
+		{ID: "v3", Type: model.VersionTypeSupportBundle},
+		// Stopped with a snapshot
+		{ID: "v4", Type: model.VersionTypeSupportBundle, Ready: true},
+		{ID: "v5", Type: model.VersionTypeSupportBundle, Ready: true},
+	}}
+	captured := time.Date(2024, 11, 18, 4, 0, 0, 0, time.UTC)
+	assert.NoError(s.saveSnapshot("ws", "v4", &Snapshot{CapturedAt: captured, Namespace: "default", Resources: map[string]ResourceHistoryResult{
+		"default/pods/p4": {Status: utils.KubectlFound},
+	}}))
+
+	finder := &runningFinder{running: map[string]bool{"ws-v1": true, "ws-v2": true, "ws-v5": true}}
+	args := "get pods -n default -o jsonpath={.items[*].metadata.name}"
+	execs := map[string]executor.Executor{
+		"v1": &commandExecutor{outputs: map[string]kubectlOutput{args: {stdout: "p1 shared"}}},
+		"v5": &commandExecutor{outputs: map[string]kubectlOutput{args: {stderr: "Unable to connect to the server"}}},
+	}
+	var asked []string
+	execFor := func(versionID string) (executor.Executor, error) {
+		asked = append(asked, versionID)
+		exec, ok := execs[versionID]
+		if !ok {
+			return nil, errors.New("no executor")
+		}
+		return exec, nil
+	}
+
+	collected := s.collectResourceNames(finder, execFor, &ws, "default", "pods", "")
+	assert.Equal(map[string]bool{"p1": true, "shared": true, "p4": true}, collected.names)
+	assert.Equal([]string{"v1", "v4"}, collected.queried)
+	assert.Equal([]VersionSkip{
+		{VersionID: "v2", Reason: skipNotReady},
+		{VersionID: "v3", Reason: skipNotRunning},
+		{VersionID: "v5", Reason: skipExecError, Error: "command failed with exit code 1: Unable to connect to the server"},
+	}, collected.skipped)
+	// The loading simulator isn't queried, it keeps autostart from starting another one
+	assert.Equal([]string{"v1", "v5"}, asked)
+	assert.Equal(4, collected.available)
+	assert.Equal(captured, *collected.snapshotAt)
+
+	// A single version
+	collected = s.collectResourceNames(finder, execFor, &ws, "default", "pods", "v2")
+	assert.Empty(collected.names)
+	assert.Empty(collected.queried)
+	assert.Equal([]VersionSkip{{VersionID: "v2", Reason: skipNotReady}}, collected.skipped)
+}
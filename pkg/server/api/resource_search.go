@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxYAMLLine bounds a single line of kubectl output, annotations like last-applied-configuration can be long
+	maxYAMLLine = 16 << 20
+	// snippetLength is the number of characters of the matching line returned around the keyword
+	snippetLength = 160
+)
+
+// contentMatch is a resource whose YAML contains the searched keyword
+type contentMatch struct {
+	Name      string `json:"name"`
+	VersionID string `json:"versionID"`
+	Snippet   string `json:"snippet"` // Part of the first matching line
+}
+
+// searchResourceContent dumps the resources of a single type as YAML and returns those containing keyword,
+// ignoring case. The output is scanned while kubectl writes it, only one object is held in memory at a time.
+func searchResourceContent(ctx context.Context, exec executor.Executor, namespace, resourceType, keyword, versionID string) ([]contentMatch, error) {
+	stream, err := utils.ExecKubectlStream(ctx, exec, "get", resourceType, "-n", namespace, "-o", "yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	needle := strings.ToLower(keyword)
+	var matches []contentMatch
+	scanErr := scanYAMLObjects(stream, func(obj []byte) error {
+		snippet, ok := findSnippet(obj, needle)
+		if !ok {
+			return nil
+		}
+		var meta struct {
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal(obj, &meta); err != nil || meta.Metadata.Name == "" {
+			return nil
+		}
+		matches = append(matches, contentMatch{Name: meta.Metadata.Name, VersionID: versionID, Snippet: snippet})
+		return nil
+	})
+	// Drain the rest so the command can exit
+	_, _ = io.Copy(io.Discard, stream)
+	if stderr, err := stream.Wait(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return matches, nil
+}
+
+// scanYAMLObjects calls fn with every object of kubectl YAML output, which is either a List whose items
+// start with "- " at the first column, or documents separated by "---" holding one object each
+func scanYAMLObjects(r io.Reader, fn func(obj []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxYAMLLine)
+
+	var top, item bytes.Buffer
+	inItems, hasItems := false, false
+	flushItem := func() error {
+		if item.Len() == 0 {
+			return nil
+		}
+		defer item.Reset()
+		return fn(item.Bytes())
+	}
+	flushDocument := func() error {
+		if err := flushItem(); err != nil {
+			return err
+		}
+		defer top.Reset()
+		isList := hasItems
+		inItems, hasItems = false, false
+		if isList || top.Len() == 0 {
+			return nil
+		}
+		return fn(top.Bytes())
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "---":
+			if err := flushDocument(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "items:"):
+			// "items: []" for an empty List
+			inItems, hasItems = line == "items:", true
+		case inItems && strings.HasPrefix(line, "- "):
+			if err := flushItem(); err != nil {
+				return err
+			}
+			item.WriteString(line[2:])
+			item.WriteByte('\n')
+		case inItems && strings.HasPrefix(line, "  "):
+			item.WriteString(line[2:])
+			item.WriteByte('\n')
+		case inItems && line == "":
+			// Blank line of a block scalar
+			item.WriteByte('\n')
+		default:
+			// Back to the fields of the List itself
+			if inItems {
+				if err := flushItem(); err != nil {
+					return err
+				}
+				inItems = false
+			}
+			top.WriteString(line)
+			top.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flushDocument()
+}
+
+// findSnippet returns the part of the first line of obj containing needle, which is lowercase
+func findSnippet(obj []byte, needle string) (string, bool) {
+	for _, line := range strings.Split(string(obj), "\n") {
+		idx := strings.Index(strings.ToLower(line), needle)
+		if idx == -1 {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		idx = strings.Index(strings.ToLower(line), needle)
+		if len(line) <= snippetLength {
+			return line, true
+		}
+		start := idx - (snippetLength-len(needle))/2
+		if start < 0 {
+			start = 0
+		}
+		end := start + snippetLength
+		if end > len(line) {
+			end = len(line)
+			start = end - snippetLength
+		}
+		snippet := strings.ToValidUTF8(line[start:end], "")
+		if start > 0 {
+			snippet = "..." + snippet
+		}
+		if end < len(line) {
+			snippet += "..."
+		}
+		return snippet, true
+	}
+	return "", false
+}
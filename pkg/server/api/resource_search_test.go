@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutor streams output as the stdout of every command
+type fakeExecutor struct {
+	output   string
+	commands [][]string
+}
+
+func (f *fakeExecutor) Exec(command []string, env []string) (string, string, error) {
+	return f.ExecContext(context.Background(), command, env)
+}
+
+func (f *fakeExecutor) ExecContext(ctx context.Context, command []string, env []string) (string, string, error) {
+	f.commands = append(f.commands, command)
+	return f.output, "", nil
+}
+
+func (f *fakeExecutor) ExecStream(ctx context.Context, command []string, env []string) (executor.Stream, error) {
+	f.commands = append(f.commands, command)
+	return &fakeStream{Reader: strings.NewReader(f.output)}, nil
+}
+
+type fakeStream struct {
+	io.Reader
+}
+
+func (s *fakeStream) Close() error          { return nil }
+func (s *fakeStream) Wait() (string, error) { return "", nil }
+
+const podList = `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: virt-launcher-vm1
+    namespace: default
+    uid: 3f1c2e0a-0d6e-4d0a-9f7a-1b2c3d4e5f60
+  spec:
+    containers:
+    - name: compute
+      command: |
+        start
+
+        --ip 10.52.0.17
+  status:
+    podIP: 10.52.0.17
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: virt-launcher-vm2
+    namespace: default
+  status:
+    podIP: 10.52.0.23
+kind: List
+metadata:
+  resourceVersion: ""
+`
+
+func Test_ScanYAMLObjects(t *testing.T) {
+	assert := require.New(t)
+
+	var objects []string
+	collect := func(obj []byte) error {
+		objects = append(objects, string(obj))
+		return nil
+	}
+	assert.NoError(scanYAMLObjects(strings.NewReader(podList), collect))
+	assert.Len(objects, 2)
+	assert.True(strings.HasPrefix(objects[0], "apiVersion: v1\nkind: Pod\nmetadata:\n  name: virt-launcher-vm1\n"))
+	assert.Contains(objects[0], "start\n\n      --ip 10.52.0.17", "blank lines of block scalars stay in the item")
+	assert.NotContains(objects[1], "kind: List")
+
+	// documents holding one object each
+	objects = nil
+	multiDoc := "apiVersion: v1\nkind: Node\nmetadata:\n  name: node-1\n---\napiVersion: v1\nkind: Node\nmetadata:\n  name: node-2\n"
+	assert.NoError(scanYAMLObjects(strings.NewReader(multiDoc), collect))
+	assert.Len(objects, 2)
+	assert.Contains(objects[1], "name: node-2")
+
+	objects = nil
+	assert.NoError(scanYAMLObjects(strings.NewReader("apiVersion: v1\nitems: []\nkind: List\n"), collect))
+	assert.Empty(objects)
+
+	stop := errors.New("stop")
+	assert.ErrorIs(scanYAMLObjects(strings.NewReader(podList), func([]byte) error { return stop }), stop)
+}
+
+func Test_SearchResourceContent(t *testing.T) {
+	assert := require.New(t)
+	exec := &fakeExecutor{output: podList}
+
+	matches, err := searchResourceContent(context.Background(), exec, "default", "pods", "10.52.0.17", "v2")
+	assert.NoError(err)
+	assert.Equal([]contentMatch{{Name: "virt-launcher-vm1", VersionID: "v2", Snippet: "--ip 10.52.0.17"}}, matches)
+	assert.Equal([]string{"kubectl", "get", "pods", "-n", "default", "-o", "yaml"}, exec.commands[0])
+
+	// case is ignored, UIDs are often pasted uppercase
+	matches, err = searchResourceContent(context.Background(), exec, "default", "pods", "3F1C2E0A", "v2")
+	assert.NoError(err)
+	assert.Len(matches, 1)
+	assert.Equal("uid: 3f1c2e0a-0d6e-4d0a-9f7a-1b2c3d4e5f60", matches[0].Snippet)
+
+	matches, err = searchResourceContent(context.Background(), exec, "default", "pods", "10.52.0", "v2")
+	assert.NoError(err)
+	assert.Len(matches, 2)
+
+	matches, err = searchResourceContent(context.Background(), exec, "default", "pods", "not-there", "v2")
+	assert.NoError(err)
+	assert.Empty(matches)
+}
+
+func Test_FindSnippet(t *testing.T) {
+	assert := require.New(t)
+	long := strings.Repeat("a", 200) + "needle" + strings.Repeat("b", 200)
+
+	snippet, ok := findSnippet([]byte("first: x\n  key: "+long+"\n"), "needle")
+	assert.True(ok)
+	assert.True(strings.HasPrefix(snippet, "..."))
+	assert.True(strings.HasSuffix(snippet, "..."))
+	assert.Contains(snippet, "needle")
+	assert.Len(snippet, snippetLength+6)
+}
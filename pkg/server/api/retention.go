@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+const (
+	// retentionSweepInterval is how often expired versions are looked for
+	retentionSweepInterval = time.Hour
+	// expiryWarning is how long before its deletion a VersionExpiring event is sent for a version
+	expiryWarning = 3 * 24 * time.Hour
+)
+
+// SetRetentionDays sets how long versions of workspaces without their own retention are kept, 0 keeps them forever
+func (s *Server) SetRetentionDays(days int) {
+	s.retentionLock.Lock()
+	defer s.retentionLock.Unlock()
+	s.retentionDays = days
+}
+
+// startRetention deletes expired versions, purges expired trash entries and removes orphaned code-server
+// projects now and then every retentionSweepInterval
+func (s *Server) startRetention() {
+	go func() {
+		ticker := time.NewTicker(retentionSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sweepRetention(time.Now())
+			s.sweepTrash(time.Now())
+			s.sweepCodeServerProjects()
+			<-ticker.C
+		}
+	}()
+}
+
+// retentionOf returns how long versions of ws are kept, 0 when they are kept forever
+func retentionOf(ws model.Workspace, defaultDays int) time.Duration {
+	days := ws.RetentionDays
+	if days == 0 {
+		days = defaultDays
+	}
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// expiryOf returns when v is deleted under retention, false when it is kept forever
+func expiryOf(v model.Version, retention time.Duration) (time.Time, bool) {
+	if retention == 0 || v.Pinned {
+		return time.Time{}, false
+	}
+	return v.CreatedAt.Add(retention), true
+}
+
+// sweepRetention deletes the expired versions and announces the ones expiring within expiryWarning.
+// Versions with commands running are skipped and deleted by a later sweep.
+func (s *Server) sweepRetention(now time.Time) {
+	s.retentionLock.Lock()
+	defer s.retentionLock.Unlock()
+
+	workspaces, err := s.store.ListWorkspaces()
+	if err != nil {
+		fmt.Printf("Retention sweep failed to list workspaces: %v\n", err)
+		return
+	}
+
+	for _, ws := range workspaces {
+		retention := retentionOf(ws, s.retentionDays)
+		for _, v := range ws.Versions {
+			instanceName := v.InstanceName(ws.Name)
+			expiresAt, ok := expiryOf(v, retention)
+			if !ok {
+				delete(s.expiryWarned, instanceName)
+				continue
+			}
+
+			if now.Before(expiresAt) {
+				if !s.expiryWarned[instanceName] && !now.Before(expiresAt.Add(-expiryWarning)) {
+					s.events.Publish(events.VersionExpiring, ws.Name, v.ID, expiresAt)
+					s.expiryWarned[instanceName] = true
+				}
+				continue
+			}
+
+			if n := s.activeExecs(instanceName); n > 0 {
+				fmt.Printf("Retention: %s expired but has %d commands running, retrying next sweep\n", instanceName, n)
+				continue
+			}
+			// Expired versions aren't kept in the trash, retention is meant to free the space
+			results, err := s.deleteVersion(ws.Name, v, true)
+			if err != nil {
+				fmt.Printf("Retention: failed to delete %s: %v\n", instanceName, err)
+				continue
+			}
+			logCleanupFailures(instanceName, results)
+			delete(s.expiryWarned, instanceName)
+			fmt.Printf("Retention: deleted %s uploaded at %s\n", instanceName, v.CreatedAt.Format(time.RFC3339))
+		}
+	}
+}
+
+// retentionRequest is the body of a retention change
+type retentionRequest struct {
+	RetentionDays int `json:"retentionDays"`
+}
+
+func (s *Server) handleSetRetention(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req retentionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ws.RetentionDays = req.RetentionDays
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// pinRequest is the body of a pin change
+type pinRequest struct {
+	Pinned bool `json:"pinned"`
+}
+
+func (s *Server) handlePinVersion(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	var req pinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if _, ok := findVersion(ws, versionID); !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	err = updateVersion(s.store, name, versionID, func(v *model.Version) bool {
+		changed := v.Pinned != req.Pinned
+		v.Pinned = req.Pinned
+		return changed
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ws, err := s.store.GetWorkspace(name); err == nil {
+		s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
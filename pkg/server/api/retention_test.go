@@ -0,0 +1,118 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RetentionOf(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal(30*24*time.Hour, retentionOf(model.Workspace{}, 30))
+	assert.Equal(7*24*time.Hour, retentionOf(model.Workspace{RetentionDays: 7}, 30))
+	assert.Zero(retentionOf(model.Workspace{RetentionDays: -1}, 30))
+	assert.Zero(retentionOf(model.Workspace{}, 0))
+}
+
+func Test_ExpiryOf(t *testing.T) {
+	assert := require.New(t)
+	created := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	expiresAt, ok := expiryOf(model.Version{CreatedAt: created}, 30*24*time.Hour)
+	assert.True(ok)
+	assert.Equal(created.AddDate(0, 0, 30), expiresAt)
+
+	_, ok = expiryOf(model.Version{CreatedAt: created, Pinned: true}, 30*24*time.Hour)
+	assert.False(ok)
+	_, ok = expiryOf(model.Version{CreatedAt: created}, 0)
+	assert.False(ok)
+}
+
+func Test_SweepRetention(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	now := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name:      "ws",
+		CreatedAt: now,
+		Versions: []model.Version{
+			{ID: "v1", CreatedAt: now.AddDate(0, 0, -40)},               // expired, queried right now
+			{ID: "v2", CreatedAt: now.AddDate(0, 0, -28)},               // expires in 2 days
+			{ID: "v3", CreatedAt: now.AddDate(0, 0, -40), Pinned: true}, // kept
+			{ID: "v4", CreatedAt: now.AddDate(0, 0, -10)},               // far from expiring
+		},
+	}))
+
+	s := &Server{store: st, events: events.NewBus(), expiryWarned: make(map[string]bool)}
+	s.SetRetentionDays(30)
+	sub := s.events.Subscribe()
+	defer sub.Close()
+
+	done := s.beginExec("ws-v1")
+	s.sweepRetention(now)
+	// the second sweep doesn't warn again
+	s.sweepRetention(now.Add(time.Hour))
+	done()
+
+	ws, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Len(ws.Versions, 4, "the version being queried must not be deleted")
+
+	e := <-sub.Events()
+	assert.Equal(events.VersionExpiring, e.Type)
+	assert.Equal("v2", e.VersionID)
+	assert.Equal(now.AddDate(0, 0, 2), e.Payload)
+	assert.Empty(sub.Events())
+}
+
+func Test_BeginExec(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+
+	first := s.beginExec("ws-v1")
+	second := s.beginExec("ws-v1")
+	assert.Equal(2, s.activeExecs("ws-v1"))
+	assert.Zero(s.activeExecs("ws-v2"))
+
+	first()
+	assert.Equal(1, s.activeExecs("ws-v1"))
+	second()
+	assert.Zero(s.activeExecs("ws-v1"))
+}
+
+func Test_LockInstance(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{}
+
+	// commands running on the instance would fail halfway
+	done := s.beginExec("ws-v1")
+	_, err := s.lockInstance("ws-v1")
+	assert.ErrorContains(err, "1 commands are running")
+	done()
+
+	unlock, err := s.lockInstance("ws-v1")
+	assert.NoError(err)
+	_, err = s.lockInstance("ws-v1")
+	assert.ErrorIs(err, errInstanceLocked)
+
+	// other instances are not held up
+	s.beginExec("ws-v2")()
+
+	// a command waits until the instance is unlocked
+	began := make(chan func())
+	go func() { began <- s.beginExec("ws-v1") }()
+	select {
+	case <-began:
+		t.Fatal("expected the command to wait for the unlock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	(<-began)()
+	assert.Zero(s.activeExecs("ws-v1"))
+}
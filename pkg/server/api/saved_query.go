@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+var errSavedQueryNotFound = errors.New("saved query not found")
+
+// savedQueryRequest holds the editable fields of a saved query
+type savedQueryRequest struct {
+	Name       string   `json:"name"`
+	Resource   string   `json:"resource"`
+	Selector   string   `json:"selector"`
+	VersionIDs []string `json:"versionIDs"`
+	Diff       bool     `json:"diff"`
+}
+
+func decodeSavedQuery(r *http.Request) (savedQueryRequest, error) {
+	var req savedQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Resource = strings.TrimSpace(req.Resource)
+	if req.Resource == "" {
+		return req, errors.New("resource is required")
+	}
+	if req.Name == "" {
+		req.Name = req.Resource
+	}
+	return req, nil
+}
+
+func (req savedQueryRequest) apply(q *model.SavedQuery) {
+	q.Name = req.Name
+	q.Resource = req.Resource
+	q.Selector = strings.TrimSpace(req.Selector)
+	q.VersionIDs = req.VersionIDs
+	q.Diff = req.Diff
+}
+
+func getNextSavedQueryID(ws *model.Workspace) string {
+	maxID := 0
+	for _, q := range ws.SavedQueries {
+		var n int
+		if _, err := fmt.Sscanf(q.ID, "q%d", &n); err == nil && n > maxID {
+			maxID = n
+		}
+	}
+	return fmt.Sprintf("q%d", maxID+1)
+}
+
+func findSavedQuery(ws *model.Workspace, id string) int {
+	for i, q := range ws.SavedQueries {
+		if q.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateSavedQuery applies fn to a copy of the saved queries and persists the workspace,
+// the original slice is shared with the store
+func (s *Server) updateSavedQuery(ws *model.Workspace, id string, fn func(q *model.SavedQuery)) (*model.SavedQuery, error) {
+	i := findSavedQuery(ws, id)
+	if i == -1 {
+		return nil, errSavedQueryNotFound
+	}
+	ws.SavedQueries = append(make([]model.SavedQuery, 0, len(ws.SavedQueries)), ws.SavedQueries...)
+	fn(&ws.SavedQueries[i])
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		return nil, err
+	}
+	return &ws.SavedQueries[i], nil
+}
+
+func (s *Server) handleListSavedQueries(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	queries := ws.SavedQueries
+	if queries == nil {
+		queries = []model.SavedQuery{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queries)
+}
+
+func (s *Server) handleCreateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	req, err := decodeSavedQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	query := model.SavedQuery{
+		ID:        getNextSavedQueryID(ws),
+		CreatedAt: time.Now(),
+	}
+	req.apply(&query)
+
+	ws.SavedQueries = append(append(make([]model.SavedQuery, 0, len(ws.SavedQueries)+1), ws.SavedQueries...), query)
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(query)
+}
+
+func (s *Server) handleUpdateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	id := r.PathValue("id")
+	req, err := decodeSavedQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	query, err := s.updateSavedQuery(ws, id, req.apply)
+	if err != nil {
+		if errors.Is(err, errSavedQueryNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(query)
+}
+
+func (s *Server) handleDeleteSavedQuery(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	id := r.PathValue("id")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	i := findSavedQuery(ws, id)
+	if i == -1 {
+		http.Error(w, errSavedQueryNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	queries := append(make([]model.SavedQuery, 0, len(ws.SavedQueries)-1), ws.SavedQueries[:i]...)
+	ws.SavedQueries = append(queries, ws.SavedQueries[i+1:]...)
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRunSavedQuery runs a saved query like resource-history and stamps its last run time
+func (s *Server) handleRunSavedQuery(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	id := r.PathValue("id")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	i := findSavedQuery(ws, id)
+	if i == -1 {
+		http.Error(w, errSavedQueryNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	query := ws.SavedQueries[i]
+	results := presentResources(ws, s.resourceHistory(ws, query.Resource, query.Selector, query.VersionIDs))
+
+	// Running a query across many versions takes a while, the workspace may have changed meanwhile
+	ws, err = s.store.GetWorkspace(name)
+	if err == nil {
+		now := time.Now()
+		_, err = s.updateSavedQuery(ws, id, func(q *model.SavedQuery) { q.LastRunAt = &now })
+	}
+	if err != nil {
+		fmt.Printf("Failed to record run of saved query %s in %s: %v\n", id, name, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
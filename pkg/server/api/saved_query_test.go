@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SavedQueryIDs(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{}
+	assert.Equal("q1", getNextSavedQueryID(ws))
+
+	ws.SavedQueries = []model.SavedQuery{{ID: "q1"}, {ID: "q3"}}
+	assert.Equal("q4", getNextSavedQueryID(ws))
+	assert.Equal(1, findSavedQuery(ws, "q3"))
+	assert.Equal(-1, findSavedQuery(ws, "q2"))
+}
+
+func Test_DecodeSavedQuery(t *testing.T) {
+	assert := require.New(t)
+
+	body := `{"resource": " settings.harvesterhci.io/overcommit-config ", "versionIDs": ["v1", "v3"], "diff": true}`
+	req, err := decodeSavedQuery(httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	assert.NoError(err)
+
+	var query model.SavedQuery
+	req.apply(&query)
+	assert.Equal("settings.harvesterhci.io/overcommit-config", query.Resource)
+	assert.Equal(query.Resource, query.Name)
+	assert.Equal([]string{"v1", "v3"}, query.VersionIDs)
+	assert.True(query.Diff)
+
+	_, err = decodeSavedQuery(httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "empty"}`)))
+	assert.Error(err)
+}
+
+func Test_ResourceHistoryArgs(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal([]string{"get", "pod", "p1", "-n", "ns", "-o", "yaml"}, resourceHistoryArgs("ns/pod/p1", "", "other"))
+	assert.Equal([]string{"get", "settings.harvesterhci.io/overcommit-config", "-o", "yaml"}, resourceHistoryArgs("settings.harvesterhci.io/overcommit-config", "", ""))
+	assert.Equal([]string{"get", "pods", "-o", "yaml", "-n", "default", "-l", "app=web"}, resourceHistoryArgs("pods", "app=web", "default"))
+}
+
+// commandExecutor answers kubectl commands by their arguments and fails like kubectl on stderr output
+type commandExecutor struct {
+	fakeExecutor
+	outputs map[string]kubectlOutput
+}
+
+func (c *commandExecutor) Exec(command []string, env []string) (string, string, error) {
+	c.commands = append(c.commands, command)
+	out := c.outputs[strings.Join(command[1:], " ")]
+	// kubectl exits with 1 once it printed an error, even when it printed the resources it found
+	if out.stderr != "" {
+		return out.stdout, out.stderr, &executor.ExitError{ExitCode: 1, Stderr: out.stderr}
+	}
+	return out.stdout, out.stderr, nil
+}
+
+const vmList = `apiVersion: v1
+items:
+- apiVersion: kubevirt.io/v1
+  kind: VirtualMachine
+  metadata:
+    name: vm1
+    namespace: default
+  spec:
+    running: true
+- apiVersion: kubevirt.io/v1
+  kind: VirtualMachine
+  metadata:
+    name: vm2
+    namespace: default
+  spec:
+    running: false
+kind: List
+`
+
+func Test_GetResources(t *testing.T) {
+	assert := require.New(t)
+	pvc := "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: vm1-disk-0\n"
+	exec := &commandExecutor{outputs: map[string]kubectlOutput{
+		"get vm vm1 vm3 vm2 -n default -o yaml": {
+			stdout: vmList,
+			stderr: `Error from server (NotFound): virtualmachines.kubevirt.io "vm3" not found`,
+		},
+		"get pvc vm1-disk-0 -n default -o yaml": {stdout: pvc},
+		"get vmi vm1 -n default -o yaml":        {stderr: `Error from server (NotFound): virtualmachineinstances.kubevirt.io "vm1" not found`},
+		"get secret s1 -n default -o yaml":      {stderr: `Error from server (Forbidden): secrets "s1" is forbidden`},
+	}}
+
+	results := getResources(exec, []string{"default/vm/vm1", "vm/vm3", "default/pvc/vm1-disk-0", "default/vm/vm2", "default/vmi/vm1", "default/secret/s1"}, "", "default")
+	assert.Len(exec.commands, 4, "the VMs are got together")
+
+	assert.Equal("found", results["default/vm/vm1"].Status)
+	assert.Equal(`apiVersion: kubevirt.io/v1
+kind: VirtualMachine
+metadata:
+  name: vm1
+  namespace: default
+spec:
+  running: true
+`, results["default/vm/vm1"].Content)
+	assert.Equal("found", results["default/vm/vm2"].Status)
+	assert.Contains(results["default/vm/vm2"].Content, "running: false")
+
+	assert.Equal(ResourceHistoryResult{Status: "not_found", Error: `Error from server (NotFound): virtualmachines.kubevirt.io "vm3" not found`}, results["vm/vm3"])
+	assert.Equal(ResourceHistoryResult{Status: "found", Content: pvc}, results["default/pvc/vm1-disk-0"])
+	assert.Equal(ResourceHistoryResult{Status: "not_found", Error: `Error from server (NotFound): virtualmachineinstances.kubevirt.io "vm1" not found`}, results["default/vmi/vm1"])
+	assert.Equal("error", results["default/secret/s1"].Status, "a failure of one type doesn't hide the others")
+	assert.Contains(results["default/secret/s1"].Error, "Forbidden")
+
+	// names can't be combined with a selector
+	exec = &commandExecutor{}
+	getResources(exec, []string{"default/vm/vm1", "default/vm/vm2"}, "app=web", "")
+	assert.Len(exec.commands, 2)
+}
+
+func Test_UniqueResources(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal([]string{"vm/a", "pvc/b"}, uniqueResources([]string{"vm/a", "", "pvc/b", "vm/a"}))
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+)
+
+const (
+	// defaultSearchLimit and maxSearchLimit cap the versions of a search, the search box lists the first ones
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// handleSearch searches the versions of every workspace by the metadata of their bundle
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.store.SearchVersions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseSearchQuery reads q, harvesterVersion, clusterName, collectedAfter, collectedBefore and limit,
+// at least one of the first five is required
+func parseSearchQuery(r *http.Request) (store.SearchQuery, error) {
+	values := r.URL.Query()
+	query := store.SearchQuery{
+		Text:             strings.TrimSpace(values.Get("q")),
+		HarvesterVersion: strings.TrimSpace(values.Get("harvesterVersion")),
+		ClusterName:      strings.TrimSpace(values.Get("clusterName")),
+		Limit:            defaultSearchLimit,
+	}
+
+	for param, bound := range map[string]**time.Time{"collectedAfter": &query.CollectedAfter, "collectedBefore": &query.CollectedBefore} {
+		v := values.Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("invalid %s %q, expected an RFC 3339 time", param, v)
+		}
+		*bound = &t
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return query, fmt.Errorf("invalid limit %q", v)
+		}
+		query.Limit = min(limit, maxSearchLimit)
+	}
+
+	if query.Text == "" && query.HarvesterVersion == "" && query.ClusterName == "" &&
+		query.CollectedAfter == nil && query.CollectedBefore == nil {
+		return query, fmt.Errorf("q or a filter is required")
+	}
+	return query, nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	memstore "github.com/Yu-Jack/sim-gui/pkg/server/store/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Search(t *testing.T) {
+	assert := require.New(t)
+	st := memstore.NewMemoryStore()
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "demo", CreatedAt: time.Now(), Versions: []model.Version{
+		{ID: "v1", ClusterName: "cluster-foo", HarvesterVersion: "v1.3.1"},
+		{ID: "v2", ClusterName: "cluster-foo", HarvesterVersion: "v1.4.0"},
+	}}))
+	s := &Server{store: st}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	search := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/search?"+query, nil))
+		return w
+	}
+
+	w := search("q=cluster-foo&harvesterVersion=v1.3")
+	assert.Equal(http.StatusOK, w.Code, w.Body.String())
+	var result store.SearchResult
+	assert.NoError(json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(1, result.Total)
+	assert.Equal("demo", result.Workspaces[0].Name)
+	assert.Equal("v1", result.Workspaces[0].Versions[0].ID)
+
+	for _, query := range []string{"", "q=+", "q=demo&limit=0", "collectedAfter=yesterday"} {
+		assert.Equal(http.StatusBadRequest, search(query).Code, query)
+	}
+}
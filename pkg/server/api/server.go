@@ -4,9 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/activity"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
 	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/simulator"
 	"github.com/Yu-Jack/sim-gui/pkg/updater"
 )
 
@@ -14,62 +26,229 @@ type Server struct {
 	store   store.Storage
 	dataDir string
 	docker  *docker.Client
-	cleaner *docker.Cleaner
+	cleaner instanceCleaner
 	updater *updater.Updater
+	events  *events.Bus
+	// activity keeps a feed of the events of every workspace
+	activity *activity.Log
+	// kubectl is checked once at startup and used by runtime executors
+	kubectl      executor.KubectlInfo
+	uploadLimits UploadLimits
+	// bundles keeps the original archives of versions, by default next to their extracted files
+	bundles bundlestore.Store
+	// importRoot confines the paths imported with from-path, empty refuses them
+	importRoot string
+	// userHeader is the header a trusted proxy sets to the authenticated user, empty records no users
+	userHeader string
+	// basePath prefixes the API routes behind a reverse proxy, empty serves them at the root
+	basePath string
+	// profile is the name of the data directory among those of Profiles, empty when the server has one
+	profile string
+	// profiles are the profiles served along with this one, nil without profiles
+	profiles *Profiles
+	// externalURL is the URL clients reach the server at, nil builds links from the requests
+	externalURL *url.URL
+	// trustForwarded builds links from the X-Forwarded-Host and X-Forwarded-Proto headers of requests
+	trustForwarded bool
+	// tokens are required with requests when set, see authorize
+	tokens *auth.Tokens
+	// limiter rate limits the Limited routes per client, nil doesn't limit them
+	limiter *rateLimiter
+	// codeServer runs the commands managing the project directories of code-server, the docker client
+	codeServer containerExec
+	// codeServerProxy forwards the requests under /code-server/ to the code-server container
+	codeServerProxy *codeServerProxy
+
+	accessLock sync.Mutex
+	// lastAccessWrite is when LastAccessedAt was last persisted per instance name
+	lastAccessWrite map[string]time.Time
+
+	progressLock sync.Mutex
+	// progress tracks the load progress of simulators per instance name
+	progress map[string]*simulator.Tracker
+
+	execLock sync.Mutex
+	// execs counts the commands running through executors per instance name
+	execs map[string]int
+	// locked holds the instances whose commands wait until a reset is done, see lockInstance
+	locked map[string]bool
+	// unlocked is signalled when an instance is unlocked, created on first use
+	unlocked *sync.Cond
+
+	retentionLock sync.Mutex
+	// retentionDays applies to workspaces without their own retention, 0 keeps versions forever
+	retentionDays int
+	// expiryWarned holds the instances whose expiry was announced, so it is announced once
+	expiryWarned map[string]bool
+
+	workspaceLock sync.Mutex
+	// deleting holds the name keys of the workspaces being deleted, created on first use
+	deleting map[string]bool
+	// workspaceRequests are the requests in flight per name key of their workspace, see refuseDeleting
+	workspaceRequests map[string]map[*workspaceRequest]bool
+
+	jobsLock sync.Mutex
+	// jobs runs the background work like workspace deletes, created in memory on first use
+	jobs *jobs.Manager
+
+	trashLock sync.Mutex
+	// trashDays is how long deleted workspaces and versions are kept in the trash, 0 keeps them until restored
+	trashDays int
+
+	// starts bounds the simulators starting at once
+	starts *startQueue
+
+	basePullLock sync.Mutex
+	// basePulls holds the base image the start of an instance waits for per instance name
+	basePulls map[string]string
+
+	extractLock sync.Mutex
+	// extractions tracks the bundles being extracted again per instance name
+	extractions map[string]*ExtractionProgress
+
+	simVersionLock sync.Mutex
+	// simVersions caches the support-bundle-kit version of the running container per instance name
+	simVersions map[string]simulatorVersion
+
+	autostartLock sync.Mutex
+	// autostarting holds the instance names started by browse requests until their start returns
+	autostarting map[string]bool
+	// backgroundStart starts the simulators of browse requests, nil uses startSimulator
+	backgroundStart func(ctx context.Context, workspaceName string, version model.Version) (string, error)
+
+	dashboardLock sync.Mutex
+	// dashboard caches the sections of GET /api/dashboard read from docker and the disk, created on first use
+	dashboard *dashboard
 }
 
-func NewServer(store store.Storage, dataDir string, upd *updater.Updater) (*Server, error) {
+// pullImages pulls the images every server needs once per process
+var pullImages sync.Once
+
+func NewServer(store store.Storage, dataDir, kubectlPath string, upd *updater.Updater) (*Server, error) {
 	cli, err := docker.NewClient(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	// Pull code-server image
-	if err := cli.PullImage("codercom/code-server:latest"); err != nil {
-		fmt.Printf("Failed to pull code-server image: %v\n", err)
-	}
+	// The servers of profiles share the docker daemon, the images are pulled by the first one. The pulls
+	// run in the background, starts wait for the base image and GET /api/pull-status reports them.
+	pullImages.Do(func() {
+		go func() {
+			if err := cli.PullImage(simulatorBaseImage); err != nil {
+				fmt.Printf("Failed to pull support-bundle-kit image: %v\n", err)
+			}
 
-	if err := cli.PullImage("rancher/support-bundle-kit:master-head"); err != nil {
-		fmt.Printf("Failed to pull support-bundle-kit image: %v\n", err)
-	}
+			if err := cli.PullImage("codercom/code-server:latest"); err != nil {
+				fmt.Printf("Failed to pull code-server image: %v\n", err)
+			}
+		}()
+	})
 
 	cleaner := docker.NewCleaner(cli)
 
-	return &Server{
+	// Only runtime versions need kubectl on the host, so a missing binary is reported but not fatal
+	kubectl := executor.CheckKubectl(kubectlPath)
+	if kubectl.Error != "" {
+		fmt.Printf("kubectl check failed, runtime versions won't be queryable: %s\n", kubectl.Error)
+	} else {
+		fmt.Printf("Using kubectl %s (%s)\n", kubectl.Path, kubectl.Version)
+	}
+
+	s := &Server{
 		store:   store,
 		dataDir: dataDir,
 		docker:  cli,
 		cleaner: cleaner,
 		updater: upd,
-	}, nil
+		events:  events.NewBus(),
+
+		codeServer:      cli,
+		codeServerProxy: newCodeServerProxy(cli),
+
+		activity: activity.NewLog(filepath.Join(dataDir, "activity"), activity.DefaultMaxEntries),
+		kubectl:  kubectl,
+
+		uploadLimits: defaultUploadLimits,
+		bundles:      bundlestore.NewLocal(dataDir),
+
+		lastAccessWrite: make(map[string]time.Time),
+		progress:        make(map[string]*simulator.Tracker),
+		execs:           make(map[string]int),
+		expiryWarned:    make(map[string]bool),
+		starts:          newStartQueue(defaultMaxStarts),
+		simVersions:     make(map[string]simulatorVersion),
+	}
+
+	// Jobs that were running when the server stopped are reported as interrupted
+	s.jobs, err = jobs.NewManager(filepath.Join(dataDir, "jobs.json"), jobs.DefaultRetention)
+	if err != nil {
+		return nil, err
+	}
+	s.jobs.OnChange(s.publishJob)
+
+	go s.recordActivity(s.events.Subscribe())
+
+	return s, nil
+}
+
+// Start repairs what the previous run left behind and starts the retention sweeps. It must be called
+// once, after the server is added to its profiles and configured, the instances it acts on are named
+// after its profile.
+func (s *Server) Start() {
+	s.clearStaging()
+	s.recoverInterruptedBuilds()
+	s.logConsistencyReport()
+	s.startRetention()
+}
+
+// SetUploadLimits replaces the default upload limits, a zero BufferSize keeps the default one and
+// zero extraction limits don't limit. It must be called before the routes are served.
+func (s *Server) SetUploadLimits(limits UploadLimits) {
+	if limits.BufferSize <= 0 {
+		limits.BufferSize = defaultUploadLimits.BufferSize
+	}
+	s.uploadLimits = limits
 }
 
+// SetMaxConcurrentStarts sets how many simulators start at once, 0 doesn't limit them. It must be
+// called before the routes are served.
+func (s *Server) SetMaxConcurrentStarts(max int) {
+	if s.starts == nil {
+		s.starts = newStartQueue(max)
+		return
+	}
+	s.starts.setMax(max)
+}
+
+// SetBundleStore replaces the local bundle store, e.g. with an S3 bucket
+func (s *Server) SetBundleStore(bundles bundlestore.Store) {
+	s.bundles = bundles
+}
+
+// bundleStore returns the bundle store, servers built without NewServer keep bundles in the data directory
+func (s *Server) bundleStore() bundlestore.Store {
+	if s.bundles == nil {
+		return bundlestore.NewLocal(s.dataDir)
+	}
+	return s.bundles
+}
+
+// RegisterRoutes registers the handler of every route, GET /api/openapi.json describes them
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /api/workspaces", s.handleListWorkspaces)
-	mux.HandleFunc("POST /api/workspaces", s.handleCreateWorkspace)
-	mux.HandleFunc("GET /api/workspaces/{name}", s.handleGetWorkspace)
-	mux.HandleFunc("DELETE /api/workspaces/{name}", s.handleDeleteWorkspace)
-	mux.HandleFunc("PUT /api/workspaces/{name}", s.handleRenameWorkspace)
-	mux.HandleFunc("GET /api/workspaces/{name}/kubeconfig", s.handleExportWorkspaceKubeconfig)
-	mux.HandleFunc("POST /api/workspaces/{name}/clean-all", s.handleCleanAllWorkspaceImages)
-	mux.HandleFunc("POST /api/clean-all", s.handleCleanAllImages)
-	mux.HandleFunc("POST /api/workspaces/{name}/resource-history", s.handleGetResourceHistory)
-	mux.HandleFunc("GET /api/workspaces/{name}/namespaces", s.handleGetNamespaces)
-	mux.HandleFunc("GET /api/workspaces/{name}/resource-types", s.handleGetResourceTypes)
-	mux.HandleFunc("GET /api/workspaces/{name}/resources", s.handleGetResources)
-	mux.HandleFunc("POST /api/workspaces/{name}/vm-pods", s.handleGetVMPods)
-	mux.HandleFunc("POST /api/workspaces/{name}/live-migration-check", s.handleCheckLiveMigration)
-
-	mux.HandleFunc("POST /api/workspaces/{name}/versions", s.handleUploadVersion)
-	mux.HandleFunc("POST /api/workspaces/{name}/versions/{versionID}/start", s.handleStartSimulator)
-	mux.HandleFunc("POST /api/workspaces/{name}/versions/{versionID}/stop", s.handleStopSimulator)
-	mux.HandleFunc("GET /api/workspaces/{name}/versions/{versionID}/status", s.handleGetSimulatorStatus)
-	mux.HandleFunc("GET /api/workspaces/{name}/versions/{versionID}/kubeconfig", s.handleGetKubeconfig)
-	mux.HandleFunc("DELETE /api/workspaces/{name}/versions/{versionID}", s.handleDeleteVersion)
-	mux.HandleFunc("POST /api/workspaces/{name}/versions/{versionID}/clean-image", s.handleCleanVersionImage)
-
-	mux.HandleFunc("POST /api/workspaces/{name}/versions/{versionID}/code-server", s.handleStartCodeServer)
-
-	// Update check endpoint
-	mux.HandleFunc("GET /api/update-status", s.handleGetUpdateStatus)
+	for _, rt := range s.routes() {
+		mux.HandleFunc(rt.Method+" "+s.basePath+s.profilePath()+rt.Path, s.routeHandler(rt))
+	}
+	s.registerCodeServerProxy(mux)
+}
+
+// routeHandler returns the handler of a route behind the middleware every route goes through
+func (s *Server) routeHandler(rt route) http.HandlerFunc {
+	return s.authorize(rt, s.rateLimit(rt, s.refuseDeleting(rt, rt.handler)))
+}
+
+// SetBasePath serves the API under a path prefix, e.g. /tools/sim-gui when a reverse proxy mounts the
+// server there. The prefix starts with a slash and has no trailing one. It must be called before the
+// routes are registered.
+func (s *Server) SetBasePath(basePath string) {
+	s.basePath = basePath
 }
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// SettingSummary is a settings.harvesterhci.io object
+type SettingSummary struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	// Customized is set when the value was changed from the default
+	Customized bool `json:"customized"`
+}
+
+// AddonSummary is an addons.harvesterhci.io object
+type AddonSummary struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Version   string `json:"version"` // Chart version
+	Status    string `json:"status,omitempty"`
+}
+
+// SettingsSummary answers what was configured on a Harvester cluster. Bundles of other clusters
+// have no settings or addons, their summary is empty.
+type SettingsSummary struct {
+	Settings        []SettingSummary `json:"settings"`
+	Addons          []AddonSummary   `json:"addons"`
+	KubeVirtVersion string           `json:"kubevirtVersion,omitempty"`
+	LonghornVersion string           `json:"longhornVersion,omitempty"`
+}
+
+// SettingsSummaryResult is the settings summary of a single version
+type SettingsSummaryResult struct {
+	VersionID string           `json:"versionID"`
+	Summary   *SettingsSummary `json:"summary,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Status    string           `json:"status"` // "found", "stopped", "error", "missing"
+}
+
+type settingList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Value   string `yaml:"value"`
+		Default string `yaml:"default"`
+	} `yaml:"items"`
+}
+
+type addonList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Enabled bool   `yaml:"enabled"`
+			Version string `yaml:"version"`
+		} `yaml:"spec"`
+		Status struct {
+			Status string `yaml:"status"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type deploymentList struct {
+	Items []struct {
+		Spec struct {
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Image string `yaml:"image"`
+					} `yaml:"containers"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	} `yaml:"items"`
+}
+
+func (s *Server) handleGetSettingsSummary(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	results := s.settingsSummaries(r.Context(), ws, []string{versionID})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results[0])
+}
+
+// handleGetWorkspaceSettingsSummary returns the settings summary of every version, or of the
+// comma separated versions query parameter, to compare them
+func (s *Server) handleGetWorkspaceSettingsSummary(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var versionIDs []string
+	if v := r.URL.Query().Get("versions"); v != "" {
+		versionIDs = strings.Split(v, ",")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.settingsSummaries(r.Context(), ws, versionIDs))
+}
+
+// settingsSummaries collects the settings summary of every version of the workspace, or only of
+// versionIDs when given. Requested versions that don't exist are reported as missing.
+func (s *Server) settingsSummaries(ctx context.Context, ws *model.Workspace, versionIDs []string) []SettingsSummaryResult {
+	results := []SettingsSummaryResult{}
+
+	for _, v := range orderedVersions(ws.Versions) {
+		if len(versionIDs) > 0 && !slices.Contains(versionIDs, v.ID) {
+			continue
+		}
+
+		if v.Type != model.VersionTypeRuntime {
+			instanceName := v.InstanceName(ws.Name)
+			containers, err := s.docker.FindRunningContainer(instanceName)
+			if err != nil || len(containers) == 0 {
+				results = append(results, SettingsSummaryResult{
+					VersionID: v.ID,
+					Status:    "stopped",
+					Error:     "Container not running",
+				})
+				continue
+			}
+		}
+
+		exec, err := s.GetExecutor(ws.Name, v.ID)
+		if err != nil {
+			results = append(results, SettingsSummaryResult{VersionID: v.ID, Status: "error", Error: err.Error()})
+			continue
+		}
+
+		summary, err := collectSettingsSummary(ctx, exec)
+		if err != nil {
+			results = append(results, SettingsSummaryResult{VersionID: v.ID, Status: "error", Error: err.Error()})
+			continue
+		}
+		results = append(results, SettingsSummaryResult{VersionID: v.ID, Status: "found", Summary: summary})
+	}
+
+	for _, id := range versionIDs {
+		if !HasVersionInWorkspace(ws, id) {
+			results = append(results, SettingsSummaryResult{
+				VersionID: id,
+				Status:    "missing",
+				Error:     "Version no longer exists",
+			})
+		}
+	}
+
+	return results
+}
+
+// collectSettingsSummary reads the Harvester settings, the addons and the deployments of a cluster
+func collectSettingsSummary(ctx context.Context, exec executor.Executor) (*SettingsSummary, error) {
+	summary := &SettingsSummary{Settings: []SettingSummary{}, Addons: []AddonSummary{}}
+
+	var settings settingList
+	if err := decodeKubectlList(ctx, exec, &settings, "get", "settings.harvesterhci.io", "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	for _, item := range settings.Items {
+		summary.Settings = append(summary.Settings, SettingSummary{
+			Name:       item.Metadata.Name,
+			Value:      item.Value,
+			Default:    item.Default,
+			Customized: isCustomized(item.Value, item.Default),
+		})
+	}
+	sort.Slice(summary.Settings, func(i, j int) bool {
+		return summary.Settings[i].Name < summary.Settings[j].Name
+	})
+
+	var addons addonList
+	if err := decodeKubectlList(ctx, exec, &addons, "get", "addons.harvesterhci.io", "-A", "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get addons: %w", err)
+	}
+	for _, item := range addons.Items {
+		summary.Addons = append(summary.Addons, AddonSummary{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Name,
+			Enabled:   item.Spec.Enabled,
+			Version:   item.Spec.Version,
+			Status:    item.Status.Status,
+		})
+	}
+	sort.Slice(summary.Addons, func(i, j int) bool {
+		a, b := summary.Addons[i], summary.Addons[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	var deployments deploymentList
+	if err := decodeKubectlList(ctx, exec, &deployments, "get", "deployments", "-A", "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get deployments: %w", err)
+	}
+	for _, item := range deployments.Items {
+		for _, c := range item.Spec.Template.Spec.Containers {
+			switch imageName(c.Image) {
+			case "virt-operator":
+				summary.KubeVirtVersion = imageTag(c.Image)
+			case "longhorn-manager":
+				// Deployed by longhorn-driver-deployer, the manager itself is a DaemonSet
+				summary.LonghornVersion = imageTag(c.Image)
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// decodeKubectlList decodes a kubectl list into out, a resource type the cluster doesn't know
+// leaves out empty
+func decodeKubectlList(ctx context.Context, exec executor.Executor, out interface{}, args ...string) error {
+	stderr, err := utils.DecodeKubectlYAML(ctx, exec, out, args...)
+	if strings.Contains(stderr, "the server doesn't have a resource type") {
+		return nil
+	}
+	if err != nil {
+		if stderr = strings.TrimSpace(stderr); stderr != "" {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		return err
+	}
+	return nil
+}
+
+// isCustomized reports whether a setting value differs from its default, settings without a
+// value use the default
+func isCustomized(value, def string) bool {
+	value = strings.TrimSpace(value)
+	return value != "" && value != strings.TrimSpace(def)
+}
+
+// imageName returns the last path element of an image reference without tag or digest
+func imageName(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, "/"); i != -1 {
+		image = image[i+1:]
+	}
+	name, _, _ := strings.Cut(image, ":")
+	return name
+}
+
+// imageTag returns the tag of an image reference, empty when it has none
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, "/"); i != -1 {
+		image = image[i+1:]
+	}
+	_, tag, _ := strings.Cut(image, ":")
+	return tag
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/stretchr/testify/require"
+)
+
+// kubectlOutput is what a command prints to stdout and stderr
+type kubectlOutput struct {
+	stdout string
+	stderr string
+}
+
+// scriptedExecutor answers kubectl commands by the resource type they get
+type scriptedExecutor struct {
+	fakeExecutor
+	outputs map[string]kubectlOutput
+}
+
+func (s *scriptedExecutor) ExecStream(ctx context.Context, command []string, env []string) (executor.Stream, error) {
+	out := s.outputs[command[2]]
+	return &scriptedStream{Reader: strings.NewReader(out.stdout), stderr: out.stderr}, nil
+}
+
+type scriptedStream struct {
+	*strings.Reader
+	stderr string
+}
+
+func (s *scriptedStream) Close() error { return nil }
+
+func (s *scriptedStream) Wait() (string, error) {
+	if s.stderr != "" {
+		return s.stderr, errors.New("exit status 1")
+	}
+	return "", nil
+}
+
+const harvesterSettings = `apiVersion: v1
+items:
+- apiVersion: harvesterhci.io/v1beta1
+  kind: Setting
+  metadata:
+    name: overcommit-config
+  default: '{"cpu":1600,"memory":150,"storage":200}'
+  value: '{"cpu":1600,"memory":150,"storage":200}'
+- apiVersion: harvesterhci.io/v1beta1
+  kind: Setting
+  metadata:
+    name: backup-target
+  default: ""
+  value: '{"type":"nfs","endpoint":"nfs://10.0.0.5:/backups"}'
+- apiVersion: harvesterhci.io/v1beta1
+  kind: Setting
+  metadata:
+    name: server-version
+  default: v1.3.1
+kind: List
+`
+
+const harvesterAddons = `apiVersion: v1
+items:
+- apiVersion: harvesterhci.io/v1beta1
+  kind: Addon
+  metadata:
+    name: vm-import-controller
+    namespace: harvester-system
+  spec:
+    chart: harvester-vm-import-controller
+    enabled: false
+    version: 1.3.1
+- apiVersion: harvesterhci.io/v1beta1
+  kind: Addon
+  metadata:
+    name: rancher-monitoring
+    namespace: cattle-monitoring-system
+  spec:
+    chart: rancher-monitoring
+    enabled: true
+    version: 103.0.3+up45.31.1
+  status:
+    status: AddonDeploySuccessful
+kind: List
+`
+
+const harvesterDeployments = `apiVersion: v1
+items:
+- metadata:
+    name: virt-operator
+    namespace: harvester-system
+  spec:
+    template:
+      spec:
+        containers:
+        - image: registry.suse.com/suse/sles/15.5/virt-operator:1.1.1-150500.8.15.1
+- metadata:
+    name: longhorn-driver-deployer
+    namespace: longhorn-system
+  spec:
+    template:
+      spec:
+        containers:
+        - image: longhornio/longhorn-manager:v1.6.2
+kind: List
+`
+
+func Test_CollectSettingsSummary(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"settings.harvesterhci.io": {stdout: harvesterSettings},
+		"addons.harvesterhci.io":   {stdout: harvesterAddons},
+		"deployments":              {stdout: harvesterDeployments},
+	}}
+
+	summary, err := collectSettingsSummary(context.Background(), exec)
+	assert.NoError(err)
+	assert.Equal([]SettingSummary{
+		{Name: "backup-target", Value: `{"type":"nfs","endpoint":"nfs://10.0.0.5:/backups"}`, Customized: true},
+		{Name: "overcommit-config", Value: `{"cpu":1600,"memory":150,"storage":200}`, Default: `{"cpu":1600,"memory":150,"storage":200}`},
+		{Name: "server-version", Default: "v1.3.1"},
+	}, summary.Settings)
+	assert.Equal([]AddonSummary{
+		{Namespace: "cattle-monitoring-system", Name: "rancher-monitoring", Enabled: true, Version: "103.0.3+up45.31.1", Status: "AddonDeploySuccessful"},
+		{Namespace: "harvester-system", Name: "vm-import-controller", Version: "1.3.1"},
+	}, summary.Addons)
+	assert.Equal("1.1.1-150500.8.15.1", summary.KubeVirtVersion)
+	assert.Equal("v1.6.2", summary.LonghornVersion)
+}
+
+func Test_CollectSettingsSummaryOfOtherClusters(t *testing.T) {
+	assert := require.New(t)
+	missing := kubectlOutput{stderr: `error: the server doesn't have a resource type "settings"`}
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"settings.harvesterhci.io": missing,
+		"addons.harvesterhci.io":   missing,
+		"deployments":              {stdout: "apiVersion: v1\nitems: []\nkind: List\n"},
+	}}
+
+	summary, err := collectSettingsSummary(context.Background(), exec)
+	assert.NoError(err)
+	assert.Equal(&SettingsSummary{Settings: []SettingSummary{}, Addons: []AddonSummary{}}, summary)
+
+	// other failures are reported
+	exec.outputs["deployments"] = kubectlOutput{stderr: "Unable to connect to the server"}
+	_, err = collectSettingsSummary(context.Background(), exec)
+	assert.ErrorContains(err, "Unable to connect to the server")
+}
+
+func Test_ImageNameAndTag(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal("virt-operator", imageName("registry.suse.com/suse/sles/15.5/virt-operator:1.1.1"))
+	assert.Equal("1.1.1", imageTag("registry.suse.com/suse/sles/15.5/virt-operator:1.1.1"))
+	assert.Equal("longhorn-manager", imageName("localhost:5000/longhorn-manager"))
+	assert.Equal("", imageTag("localhost:5000/longhorn-manager"))
+	assert.Equal("v1.6.2", imageTag("longhornio/longhorn-manager:v1.6.2@sha256:abc"))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// simulatorBaseImage is the support-bundle-kit image simulators are built from. The tag floats, the
+// digest of the build a version was built from is recorded on the version.
+const simulatorBaseImage = "rancher/support-bundle-kit:master-head"
+
+// SimulatorBuild identifies the support-bundle-kit build a simulator runs
+type SimulatorBuild struct {
+	// Version is reported by support-bundle-kit in the running container
+	Version string `json:"version,omitempty"`
+	// VersionError is why the version couldn't be read, e.g. a build without the version command
+	VersionError     string     `json:"versionError,omitempty"`
+	BaseImage        string     `json:"baseImage"`
+	BaseImageDigest  string     `json:"baseImageDigest,omitempty"`
+	BaseImageCreated *time.Time `json:"baseImageCreated,omitempty"`
+	// Labels of the simulator image, those of the base image are inherited
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// simulatorVersion is the support-bundle-kit version read from a container
+type simulatorVersion struct {
+	containerID string
+	version     string
+}
+
+// simulatorBuild assembles the build of a simulator from the version, its container and the version
+// support-bundle-kit reported, nil when there is no container
+func simulatorBuild(version model.Version, container *docker.ContainerStatus, reported string, reportErr error) *SimulatorBuild {
+	if container == nil {
+		return nil
+	}
+	build := &SimulatorBuild{
+		Version:          reported,
+		BaseImage:        simulatorBaseImage,
+		BaseImageDigest:  version.BaseImageDigest,
+		BaseImageCreated: version.BaseImageCreated,
+		Labels:           container.ImageLabels,
+	}
+	if reportErr != nil {
+		build.VersionError = reportErr.Error()
+	}
+	return build
+}
+
+// reportedSimulatorVersion returns the version support-bundle-kit reports in a running container. It
+// is read once per container, the binary doesn't change until the container is recreated.
+func (s *Server) reportedSimulatorVersion(instanceName string, container *docker.ContainerStatus) (string, error) {
+	if container == nil || container.State != "running" {
+		return "", nil
+	}
+	s.simVersionLock.Lock()
+	cached, ok := s.simVersions[instanceName]
+	s.simVersionLock.Unlock()
+	if ok && cached.containerID == container.ID {
+		return cached.version, nil
+	}
+
+	version, err := s.docker.SimulatorVersion(instanceName)
+	if err != nil {
+		fmt.Printf("Failed to read support-bundle-kit version of %s: %v\n", instanceName, err)
+		return "", err
+	}
+	s.simVersionLock.Lock()
+	s.simVersions[instanceName] = simulatorVersion{containerID: container.ID, version: version}
+	s.simVersionLock.Unlock()
+	return version, nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// PortMapping is where a port of a simulator is reached
+type PortMapping struct {
+	ContainerPort uint16 `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+	// Host is the host IP the port is published on, the IP of the container on other networks than
+	// the default bridge network
+	Host     string `json:"host"`
+	HostPort uint16 `json:"hostPort"`
+}
+
+// SimulatorPorts lists the ports of a simulator
+type SimulatorPorts struct {
+	// Ports are the container ports published besides the apiserver on the next start
+	Ports []uint16 `json:"ports"`
+	// Mappings are where the apiserver and the ports are reached, empty unless the simulator runs
+	Mappings []PortMapping `json:"mappings"`
+}
+
+// portMappings converts the port mappings docker reports, nil stays nil
+func portMappings(mappings []docker.PortMapping) []PortMapping {
+	if mappings == nil {
+		return nil
+	}
+	converted := make([]PortMapping, len(mappings))
+	for i, m := range mappings {
+		converted[i] = PortMapping{ContainerPort: m.ContainerPort, Protocol: m.Protocol, Host: m.Host, HostPort: m.HostPort}
+	}
+	return converted
+}
+
+// setSimulatorPorts remembers the ports the simulator of a version publishes besides the apiserver
+func (s *Server) setSimulatorPorts(workspaceName string, version *model.Version, ports []uint16) error {
+	if err := docker.ValidatePorts(ports); err != nil {
+		return &startError{http.StatusBadRequest, err}
+	}
+	ports = slices.Clone(ports)
+	slices.Sort(ports)
+	err := updateVersion(s.store, workspaceName, version.ID, func(v *model.Version) bool {
+		v.Ports = ports
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save the ports: %w", err)
+	}
+	version.Ports = ports
+	return nil
+}
+
+func (s *Server) handleGetSimulatorPorts(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if version.Type == model.VersionTypeRuntime {
+		http.Error(w, errRuntimeVersion.Error(), http.StatusBadRequest)
+		return
+	}
+
+	container, err := s.docker.ContainerStatus(version.InstanceName(name), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := SimulatorPorts{Ports: version.Ports, Mappings: []PortMapping{}}
+	if result.Ports == nil {
+		result.Ports = []uint16{}
+	}
+	if container != nil && container.Ports != nil {
+		result.Mappings = portMappings(container.Ports)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetSimulatorPorts(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	version := model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, Ports: []uint16{8080}}
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now(), Versions: []model.Version{version}}))
+	s := &Server{store: st}
+
+	// the apiserver is always published, refused ports leave the remembered ones alone
+	err = s.setSimulatorPorts("ws", &version, []uint16{9090, 6443})
+	var startErr *startError
+	assert.True(errors.As(err, &startErr))
+	assert.Equal(http.StatusBadRequest, startErr.status)
+	assert.Equal([]uint16{8080}, version.Ports)
+
+	requested := []uint16{9090, 80}
+	assert.NoError(s.setSimulatorPorts("ws", &version, requested))
+	assert.Equal([]uint16{80, 9090}, version.Ports)
+	assert.Equal([]uint16{9090, 80}, requested, "the request must not be reordered")
+	ws, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal([]uint16{80, 9090}, ws.Versions[0].Ports)
+
+	// an empty list publishes only the apiserver again
+	assert.NoError(s.setSimulatorPorts("ws", &version, []uint16{}))
+	ws, err = st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Empty(ws.Versions[0].Ports)
+}
+
+func Test_PortMappings(t *testing.T) {
+	assert := require.New(t)
+	assert.Nil(portMappings(nil))
+	assert.Equal([]PortMapping{{ContainerPort: 6443, Protocol: "tcp", Host: "0.0.0.0", HostPort: 32768}},
+		portMappings([]docker.PortMapping{{ContainerPort: 6443, Protocol: "tcp", Host: "0.0.0.0", HostPort: 32768}}))
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/docker/docker/api/types"
+)
+
+// simulatorResetter is the part of the docker client resetSimulator needs
+type simulatorResetter interface {
+	containerStarter
+	FindRunningContainer(instanceName string) ([]types.Container, error)
+	StopContainer(instanceName string) error
+}
+
+// resetSimulator puts a running simulator back to the state of its support bundle, dropping whatever was
+// changed through its apiserver. The simulator loads the bundle when its container starts, so the container
+// is stopped and started again, with the same image and without building it. Commands on the simulator wait
+// until the container runs again, the reset is refused while commands run. Like startSimulator it holds a
+// start slot, released by the ready monitor the caller starts.
+func (s *Server) resetSimulator(ctx context.Context, resetter simulatorResetter, name string, version model.Version) error {
+	if version.Type == model.VersionTypeRuntime {
+		return &startError{http.StatusBadRequest, errRuntimeVersion}
+	}
+
+	instanceName := version.InstanceName(name)
+	if s.starts.starting(instanceName) {
+		return &startError{http.StatusConflict, errors.New("the simulator is still starting")}
+	}
+	containers, err := resetter.FindRunningContainer(instanceName)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return &startError{http.StatusConflict, errSimulatorNotRunning}
+	}
+
+	unlock, err := s.lockInstance(instanceName)
+	if err != nil {
+		return &startError{http.StatusConflict, err}
+	}
+	defer unlock()
+
+	if err := s.starts.acquire(ctx, instanceName); err != nil {
+		return &startError{http.StatusConflict, err}
+	}
+	if err := resetter.StopContainer(instanceName); err != nil {
+		s.starts.release(instanceName)
+		return fmt.Errorf("Failed to stop container: %w", err)
+	}
+	if err := s.restartSimulator(resetter, name, version.ID, instanceName, containers[0].ID); err != nil {
+		s.starts.release(instanceName)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleResetSimulator(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.resetSimulator(r.Context(), s.docker, name, version); err != nil {
+		status := http.StatusInternalServerError
+		var startErr *startError
+		if errors.As(err, &startErr) {
+			status = startErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	instanceName := version.InstanceName(name)
+	s.monitorReadyState(name, versionID, instanceName)
+	s.events.PublishBy(s.requestUser(r), events.SimulatorReset, name, versionID, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
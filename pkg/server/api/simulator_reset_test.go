@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResetter plays a running simulator container whose stop can fail
+type fakeResetter struct {
+	fakeStarter
+	running  bool
+	stopped  int
+	stopFail error
+}
+
+func (f *fakeResetter) FindRunningContainer(instanceName string) ([]types.Container, error) {
+	if !f.running {
+		return nil, nil
+	}
+	return []types.Container{{ID: "abc", State: "running"}}, nil
+}
+
+func (f *fakeResetter) StopContainer(instanceName string) error {
+	if f.stopFail != nil {
+		return f.stopFail
+	}
+	f.stopped++
+	f.running = false
+	f.started = false
+	return nil
+}
+
+func Test_ResetSimulator(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	version := model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, Ready: true}
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now(), Versions: []model.Version{version}}))
+	s := &Server{store: st, events: events.NewBus(), starts: newStartQueue(1)}
+	sub := s.events.Subscribe()
+	defer sub.Close()
+	resetter := &fakeResetter{fakeStarter: fakeStarter{t: t, st: st, port: 32768}}
+	status := func(err error) int {
+		var startErr *startError
+		assert.True(errors.As(err, &startErr), "expected a start error, got %v", err)
+		return startErr.status
+	}
+
+	// runtime versions have no simulator, a stopped one has nothing to reset
+	err = s.resetSimulator(context.Background(), resetter, "ws", model.Version{ID: "v2", Type: model.VersionTypeRuntime})
+	assert.Equal(http.StatusBadRequest, status(err))
+	err = s.resetSimulator(context.Background(), resetter, "ws", version)
+	assert.Equal(http.StatusConflict, status(err))
+	assert.ErrorIs(err, errSimulatorNotRunning)
+
+	// refused while a command runs on the simulator
+	resetter.running = true
+	done := s.beginExec("ws-v1")
+	assert.Equal(http.StatusConflict, status(s.resetSimulator(context.Background(), resetter, "ws", version)))
+	done()
+	assert.Zero(resetter.stopped)
+
+	// the container is restarted, the version loads the bundle again on a new port
+	assert.NoError(s.resetSimulator(context.Background(), resetter, "ws", version))
+	assert.Equal(1, resetter.stopped)
+	ws, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.False(ws.Versions[0].Ready)
+	e := <-sub.Events()
+	assert.Equal(events.SimulatorKubeconfigChanged, e.Type)
+	assert.Equal("localhost:32769", e.Payload)
+	// the start slot is held until the ready monitor releases it, and commands can run again
+	assert.True(s.starts.starting("ws-v1"))
+	s.beginExec("ws-v1")()
+
+	// a reset while it loads the bundle is refused
+	resetter.running = true
+	assert.Equal(http.StatusConflict, status(s.resetSimulator(context.Background(), resetter, "ws", version)))
+	s.starts.release("ws-v1")
+
+	// a failed stop gives the slot back
+	resetter.stopFail = errors.New("no such container")
+	assert.ErrorContains(s.resetSimulator(context.Background(), resetter, "ws", version), "no such container")
+	assert.False(s.starts.starting("ws-v1"))
+	assert.Empty(sub.Events())
+}
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// snapshotsDir is the directory of a version holding its snapshots, one <id>.json each
+const snapshotsDir = "snapshots"
+
+// snapshotHeader marks responses answered from a snapshot with the time it was captured at
+const snapshotHeader = "X-Snapshot-Captured-At"
+
+// Snapshot holds what a simulator answered to the queries of the resource endpoints while it ran, so
+// they can be answered once it is stopped
+type Snapshot struct {
+	ID         int       `json:"id"`
+	CapturedAt time.Time `json:"capturedAt"`
+	// Namespace is the default namespace of the workspace when the snapshot was captured, resources
+	// given without a namespace were got from it
+	Namespace     string   `json:"namespace,omitempty"`
+	Namespaces    []string `json:"namespaces"`
+	ResourceTypes []string `json:"resourceTypes"`
+	// Resources are keyed by snapshotKey of the resource-history query that got them
+	Resources map[string]ResourceHistoryResult `json:"resources"`
+}
+
+// SnapshotInfo describes a snapshot without its content
+type SnapshotInfo struct {
+	ID         int       `json:"id"`
+	CapturedAt time.Time `json:"capturedAt"`
+	Resources  int       `json:"resources"`
+}
+
+// SnapshotRequest is the optional body of a capture, listing resources to capture besides the nodes,
+// bookmarks and saved queries of the workspace
+type SnapshotRequest struct {
+	Resources []string `json:"resources"`
+}
+
+// snapshotQuery is a resource-history query captured in a snapshot
+type snapshotQuery struct {
+	resource string
+	selector string
+}
+
+// snapshotKey keys the result of a query in a snapshot, selector queries of the same resource differ
+func snapshotKey(resource, selector string) string {
+	if selector == "" {
+		return resource
+	}
+	return resource + " -l " + selector
+}
+
+// snapshotQueries are the resources a snapshot of a version of ws captures: the nodes, the bookmarked
+// resources, the saved queries run against the version and extra, each once
+func snapshotQueries(ws *model.Workspace, versionID string, extra []string) []snapshotQuery {
+	queries := []snapshotQuery{{resource: "nodes"}}
+	for _, b := range ws.Bookmarks {
+		resource := b.ResourceType + "/" + b.Name
+		if b.Namespace != "" {
+			resource = b.Namespace + "/" + resource
+		}
+		queries = append(queries, snapshotQuery{resource: resource})
+	}
+	for _, q := range ws.SavedQueries {
+		if len(q.VersionIDs) == 0 || slices.Contains(q.VersionIDs, versionID) {
+			queries = append(queries, snapshotQuery{resource: q.Resource, selector: q.Selector})
+		}
+	}
+	for _, resource := range extra {
+		if resource = strings.TrimSpace(resource); resource != "" {
+			queries = append(queries, snapshotQuery{resource: resource})
+		}
+	}
+
+	seen := make(map[string]bool, len(queries))
+	unique := queries[:0]
+	for _, q := range queries {
+		if key := snapshotKey(q.resource, q.selector); !seen[key] {
+			seen[key] = true
+			unique = append(unique, q)
+		}
+	}
+	return unique
+}
+
+// snapshotDir is the directory holding the snapshots of a version
+func (s *Server) snapshotDir(workspaceName, versionID string) string {
+	return filepath.Join(s.dataDir, "workspaces", workspaceName, versionID, snapshotsDir)
+}
+
+// saveSnapshot stores snap as the next snapshot of a version, setting its ID
+func (s *Server) saveSnapshot(workspaceName, versionID string, snap *Snapshot) error {
+	dir := s.snapshotDir(workspaceName, versionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	ids, err := snapshotIDs(dir)
+	if err != nil {
+		return err
+	}
+	snap.ID = 1
+	if len(ids) > 0 {
+		snap.ID = ids[len(ids)-1] + 1
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", snap.ID)), data, 0644)
+}
+
+// snapshotIDs returns the IDs of the snapshots in dir in ascending order, none when dir doesn't exist
+func snapshotIDs(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err == nil && !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// loadSnapshot reads a snapshot of a version, id 0 reads the latest one
+func (s *Server) loadSnapshot(workspaceName, versionID string, id int) (*Snapshot, error) {
+	dir := s.snapshotDir(workspaceName, versionID)
+	if id == 0 {
+		ids, err := snapshotIDs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, os.ErrNotExist
+		}
+		id = ids[len(ids)-1]
+	}
+	data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.json", id)))
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %d: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// listSnapshots describes the snapshots of a version, oldest first
+func (s *Server) listSnapshots(workspaceName, versionID string) ([]SnapshotInfo, error) {
+	ids, err := snapshotIDs(s.snapshotDir(workspaceName, versionID))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SnapshotInfo, 0, len(ids))
+	for _, id := range ids {
+		snap, err := s.loadSnapshot(workspaceName, versionID, id)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, SnapshotInfo{ID: snap.ID, CapturedAt: snap.CapturedAt, Resources: len(snap.Resources)})
+	}
+	return infos, nil
+}
+
+// stoppedSnapshot returns the latest snapshot of a support bundle version whose simulator isn't running
+func (s *Server) stoppedSnapshot(workspaceName string, v model.Version) (*Snapshot, bool) {
+	if v.Type == model.VersionTypeRuntime {
+		return nil, false
+	}
+	containers, err := s.docker.FindRunningContainer(v.InstanceName(workspaceName))
+	if err == nil && len(containers) > 0 {
+		return nil, false
+	}
+	return s.latestSnapshot(workspaceName, v.ID)
+}
+
+// latestSnapshot returns the latest snapshot of a version, when it has one
+func (s *Server) latestSnapshot(workspaceName, versionID string) (*Snapshot, bool) {
+	snap, err := s.loadSnapshot(workspaceName, versionID, 0)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Failed to load snapshot of %s-%s: %v\n", workspaceName, versionID, err)
+		}
+		return nil, false
+	}
+	return snap, true
+}
+
+// latestStoppedSnapshot returns the snapshot of the most recent version that has one, for requests
+// answered by any running version when none runs
+func (s *Server) latestStoppedSnapshot(ws *model.Workspace) (*Snapshot, bool) {
+	versions := orderedVersions(ws.Versions)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if snap, ok := s.stoppedSnapshot(ws.Name, versions[i]); ok {
+			return snap, true
+		}
+	}
+	return nil, false
+}
+
+// result returns the captured result of a resource-history query. A resource without a namespace was
+// got from the default namespace at the time, it only matches while that is still the default.
+func (snap *Snapshot) result(resource, selector, defaultNamespace string) (ResourceHistoryResult, bool) {
+	if strings.Count(resource, "/") < 2 && snap.Namespace != defaultNamespace {
+		return ResourceHistoryResult{}, false
+	}
+	result, ok := snap.Resources[snapshotKey(resource, selector)]
+	if !ok {
+		return ResourceHistoryResult{}, false
+	}
+	capturedAt := snap.CapturedAt
+	result.SnapshotAt = &capturedAt
+	return result, true
+}
+
+// names returns the names of the captured resources of a type in a namespace, from the named resources
+// and from the lists captured of the type
+func (snap *Snapshot) names(namespace, resourceType string) []string {
+	var names []string
+	for key, result := range snap.Resources {
+		if strings.Contains(key, " -l ") || result.Status != utils.KubectlFound {
+			continue
+		}
+		if ns, rt, name, ok := splitResource(key, snap.Namespace); ok {
+			if ns == namespace && rt == resourceType {
+				names = append(names, name)
+			}
+			continue
+		}
+		if key != resourceType || snap.Namespace != namespace {
+			continue
+		}
+		// A single item is printed as is, a list has items
+		items, err := splitKubectlList(result.Content)
+		if err != nil {
+			continue
+		}
+		for name := range items {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeSnapshotAnswer writes a response answered from a snapshot, marked with the time it was captured at
+func writeSnapshotAnswer(w http.ResponseWriter, snap *Snapshot, answer any) {
+	w.Header().Set(snapshotHeader, snap.CapturedAt.Format(time.RFC3339))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
+
+// handleCaptureSnapshot captures the namespaces, resource types and the resources of snapshotQueries
+// from a running simulator into a new snapshot of the version
+func (s *Server) handleCaptureSnapshot(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	var req SnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if version.Type == model.VersionTypeRuntime {
+		http.Error(w, errRuntimeVersion.Error(), http.StatusBadRequest)
+		return
+	}
+	containers, err := s.docker.FindRunningContainer(version.InstanceName(name))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(containers) == 0 {
+		http.Error(w, "Simulator not running, start it to capture a snapshot", http.StatusConflict)
+		return
+	}
+
+	exec, err := s.GetExecutor(name, versionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	snap := &Snapshot{CapturedAt: time.Now(), Namespace: ws.DefaultNamespace, Resources: map[string]ResourceHistoryResult{}}
+	out, err := utils.ExecKubectl(exec, "get", "namespaces", "-o", "jsonpath={.items[*].metadata.name}")
+	if _, err = utils.KubectlStatus(out, err); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get namespaces: %v", err), http.StatusInternalServerError)
+		return
+	}
+	snap.Namespaces = strings.Split(strings.TrimSpace(out.Stdout), " ")
+	out, err = utils.ExecKubectl(exec, "api-resources", "--verbs=list", "-o", "name")
+	if _, err = utils.KubectlStatus(out, err); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get resource types: %v", err), http.StatusInternalServerError)
+		return
+	}
+	snap.ResourceTypes = strings.Split(strings.TrimSpace(out.Stdout), "\n")
+
+	// Queries with the same selector are got together, like resource-history does
+	bySelector := make(map[string][]string)
+	for _, q := range snapshotQueries(ws, versionID, req.Resources) {
+		bySelector[q.selector] = append(bySelector[q.selector], q.resource)
+	}
+	for selector, resources := range bySelector {
+		for resource, result := range getResources(exec, resources, selector, ws.DefaultNamespace) {
+			snap.Resources[snapshotKey(resource, selector)] = result
+		}
+	}
+
+	if err := s.saveSnapshot(name, versionID, snap); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SnapshotInfo{ID: snap.ID, CapturedAt: snap.CapturedAt, Resources: len(snap.Resources)})
+}
+
+// handleListSnapshots lists the snapshots of a version, oldest first
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	infos, err := s.listSnapshots(name, versionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// handleGetSnapshot returns a snapshot of a version, the latest one unless ?id= is given
+func (s *Server) handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	id := 0
+	if value := r.URL.Query().Get("id"); value != "" {
+		var err error
+		if id, err = strconv.Atoi(value); err != nil || id <= 0 {
+			http.Error(w, fmt.Sprintf("invalid id %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	snap, err := s.loadSnapshot(name, versionID, id)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SnapshotQueries(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{
+		Bookmarks: []model.Bookmark{
+			{Namespace: "default", ResourceType: "vm", Name: "web"},
+			{ResourceType: "nodes", Name: "node-1"},
+		},
+		SavedQueries: []model.SavedQuery{
+			{ID: "q1", Resource: "pods", Selector: "app=web"},
+			{ID: "q2", Resource: "vm/db", VersionIDs: []string{"v2"}},
+			{ID: "q3", Resource: "default/vm/web"},
+		},
+	}
+
+	queries := snapshotQueries(ws, "v1", []string{" settings ", "", "nodes"})
+	var keys []string
+	for _, q := range queries {
+		keys = append(keys, snapshotKey(q.resource, q.selector))
+	}
+	assert.Equal([]string{"nodes", "default/vm/web", "nodes/node-1", "pods -l app=web", "settings"}, keys,
+		"expected each query once, without saved queries of other versions")
+}
+
+func Test_SaveAndLoadSnapshots(t *testing.T) {
+	assert := require.New(t)
+	s := &Server{dataDir: t.TempDir()}
+
+	_, err := s.loadSnapshot("ws", "v1", 0)
+	assert.ErrorIs(err, os.ErrNotExist)
+	infos, err := s.listSnapshots("ws", "v1")
+	assert.NoError(err)
+	assert.Empty(infos)
+
+	first := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	for i, captured := range []time.Time{first, first.Add(time.Hour)} {
+		snap := &Snapshot{CapturedAt: captured, Namespaces: []string{"default"}, Resources: map[string]ResourceHistoryResult{
+			"nodes": {Status: utils.KubectlFound, Content: "kind: List\n"},
+		}}
+		assert.NoError(s.saveSnapshot("ws", "v1", snap))
+		assert.Equal(i+1, snap.ID)
+	}
+	// files that aren't snapshots are ignored
+	assert.NoError(os.WriteFile(filepath.Join(s.snapshotDir("ws", "v1"), "notes.txt"), nil, 0644))
+
+	latest, err := s.loadSnapshot("ws", "v1", 0)
+	assert.NoError(err)
+	assert.Equal(2, latest.ID)
+	assert.True(first.Add(time.Hour).Equal(latest.CapturedAt))
+
+	older, err := s.loadSnapshot("ws", "v1", 1)
+	assert.NoError(err)
+	assert.True(first.Equal(older.CapturedAt))
+
+	infos, err = s.listSnapshots("ws", "v1")
+	assert.NoError(err)
+	assert.Len(infos, 2)
+	assert.Equal(1, infos[0].ID)
+	assert.Equal(1, infos[1].Resources)
+}
+
+func Test_SnapshotResult(t *testing.T) {
+	assert := require.New(t)
+	captured := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	snap := &Snapshot{CapturedAt: captured, Namespace: "harvester-system", Resources: map[string]ResourceHistoryResult{
+		"default/vm/web":  {Status: utils.KubectlFound, Content: "name: web\n"},
+		"pods -l app=web": {Status: utils.KubectlFound, Content: "kind: List\n"},
+		"settings/foo":    {Status: utils.KubectlNotFound, Error: `settings "foo" not found`},
+	}}
+
+	result, ok := snap.result("default/vm/web", "", "")
+	assert.True(ok)
+	assert.Equal("name: web\n", result.Content)
+	assert.NotNil(result.SnapshotAt)
+	assert.True(captured.Equal(*result.SnapshotAt))
+	assert.Nil(snap.Resources["default/vm/web"].SnapshotAt, "expected the snapshot to be left unchanged")
+
+	_, ok = snap.result("pods", "", "harvester-system")
+	assert.False(ok, "expected selector queries to be kept apart")
+	result, ok = snap.result("pods", "app=web", "harvester-system")
+	assert.True(ok)
+	assert.Equal(utils.KubectlFound, result.Status)
+
+	result, ok = snap.result("settings/foo", "", "harvester-system")
+	assert.True(ok)
+	assert.Equal(utils.KubectlNotFound, result.Status)
+	// got from another namespace than the current default
+	_, ok = snap.result("settings/foo", "", "default")
+	assert.False(ok)
+}
+
+func Test_SnapshotNames(t *testing.T) {
+	assert := require.New(t)
+	snap := &Snapshot{Namespace: "default", Resources: map[string]ResourceHistoryResult{
+		"default/vm/web":         {Status: utils.KubectlFound},
+		"vm/db":                  {Status: utils.KubectlFound},
+		"default/vm/gone":        {Status: utils.KubectlNotFound},
+		"other/vm/cache":         {Status: utils.KubectlFound},
+		"vm -l app=web":          {Status: utils.KubectlFound},
+		"pods":                   {Status: utils.KubectlFound, Content: "apiVersion: v1\nitems:\n- metadata:\n    name: web-0\n- metadata:\n    name: web-1\nkind: List\n"},
+		"harvester-system/pods/": {Status: utils.KubectlFound},
+	}}
+
+	names := snap.names("default", "vm")
+	sort.Strings(names)
+	assert.Equal([]string{"db", "web"}, names)
+
+	names = snap.names("default", "pods")
+	sort.Strings(names)
+	assert.Equal([]string{"web-0", "web-1"}, names)
+	assert.Empty(snap.names("harvester-system", "pods"))
+}
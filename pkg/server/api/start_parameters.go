@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// StartParameters are the container settings a version remembers from its start requests. A
+// container created with others is created again, so a simulator recreated after its image was
+// cleaned is the same as before.
+type StartParameters struct {
+	// Network is the docker network, empty for the default bridge network
+	Network string `json:"network"`
+	// Ports are the container ports published besides the apiserver
+	Ports []uint16 `json:"ports"`
+	// Labels and Env are added to those of the workspace
+	Labels map[string]string `json:"labels"`
+	Env    map[string]string `json:"env"`
+}
+
+// startParameters returns the start parameters remembered by a version, nil for runtime versions
+func startParameters(version model.Version) *StartParameters {
+	if version.Type == model.VersionTypeRuntime {
+		return nil
+	}
+	params := &StartParameters{
+		Network: version.Network,
+		Ports:   version.Ports,
+		Labels:  version.StartLabels,
+		Env:     version.StartEnv,
+	}
+	if params.Ports == nil {
+		params.Ports = []uint16{}
+	}
+	if params.Labels == nil {
+		params.Labels = map[string]string{}
+	}
+	if params.Env == nil {
+		params.Env = map[string]string{}
+	}
+	return params
+}
+
+// versionExtras returns the labels and environment the simulator of a version is created with, those
+// of the workspace overridden by those remembered from the last start request
+func versionExtras(ws *model.Workspace, version model.Version) (docker.Extras, error) {
+	return containerExtras(ws, version.StartLabels, version.StartEnv)
+}
+
+// setSimulatorExtras remembers the labels and environment of a start request, nil keeps the
+// remembered ones and an empty map clears them
+func (s *Server) setSimulatorExtras(ws *model.Workspace, version *model.Version, labels, env map[string]string) error {
+	if labels == nil {
+		labels = version.StartLabels
+	}
+	if env == nil {
+		env = version.StartEnv
+	}
+	if _, err := containerExtras(ws, labels, env); err != nil {
+		return &startError{http.StatusBadRequest, err}
+	}
+	// Empty maps are stored as none, the store leaves them out
+	if len(labels) == 0 {
+		labels = nil
+	}
+	if len(env) == 0 {
+		env = nil
+	}
+	err := updateVersion(s.store, ws.Name, version.ID, func(v *model.Version) bool {
+		v.StartLabels = labels
+		v.StartEnv = env
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save the labels and environment: %w", err)
+	}
+	version.StartLabels = labels
+	version.StartEnv = env
+	return nil
+}
+
+// patchVersionRequest changes the start parameters of a version without starting it. Fields left out
+// are kept, empty ones clear the parameter.
+type patchVersionRequest struct {
+	Network *string            `json:"network,omitempty"`
+	Ports   *[]uint16          `json:"ports,omitempty"`
+	Labels  *map[string]string `json:"labels,omitempty"`
+	Env     *map[string]string `json:"env,omitempty"`
+}
+
+func (s *Server) handlePatchVersion(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	var req patchVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if version.Type == model.VersionTypeRuntime {
+		http.Error(w, errRuntimeVersion.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.patchStartParameters(ws, &version, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var startErr *startError
+		if errors.As(err, &startErr) {
+			status = startErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if ws, err := s.store.GetWorkspace(name); err == nil {
+		s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+// patchStartParameters applies the parameters set in req to version, the labels and environment are
+// checked before anything is saved
+func (s *Server) patchStartParameters(ws *model.Workspace, version *model.Version, req patchVersionRequest) error {
+	// {} decodes to an empty map, which clears, and nil keeps the remembered ones
+	var labels, env map[string]string
+	if req.Labels != nil {
+		labels = *req.Labels
+	}
+	if req.Env != nil {
+		env = *req.Env
+	}
+	if _, err := containerExtras(ws, mergeStartMaps(labels, version.StartLabels), mergeStartMaps(env, version.StartEnv)); err != nil {
+		return &startError{http.StatusBadRequest, err}
+	}
+
+	if req.Network != nil && *req.Network != version.Network {
+		if err := s.setSimulatorNetwork(ws.Name, version, strings.TrimSpace(*req.Network)); err != nil {
+			return err
+		}
+	}
+	if req.Ports != nil {
+		if err := s.setSimulatorPorts(ws.Name, version, *req.Ports); err != nil {
+			return err
+		}
+	}
+	if labels != nil || env != nil {
+		return s.setSimulatorExtras(ws, version, labels, env)
+	}
+	return nil
+}
+
+// mergeStartMaps returns requested unless it is nil, which keeps remembered
+func mergeStartMaps(requested, remembered map[string]string) map[string]string {
+	if requested == nil {
+		return remembered
+	}
+	return requested
+}
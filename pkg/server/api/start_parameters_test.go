@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StartParametersPersist(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	st, err := jsonstore.NewJSONStore(path)
+	assert.NoError(err)
+	ws := model.Workspace{Name: "ws", CreatedAt: time.Now(), ContainerLabels: map[string]string{"team": "support"},
+		Versions: []model.Version{{ID: "v1", Type: model.VersionTypeSupportBundle}}}
+	assert.NoError(st.CreateWorkspace(ws))
+	s := &Server{store: st}
+
+	// The first start remembers its labels and environment
+	version := ws.Versions[0]
+	assert.NoError(s.setSimulatorExtras(&ws, &version, map[string]string{"case": "1234"}, map[string]string{"DEBUG": "1"}))
+	first, err := versionExtras(&ws, version)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "support", "case": "1234"}, first.Labels)
+	created := types.Container{Labels: map[string]string{"sim-cli-managed.extras": first.Hash()}}
+
+	// After clean-image the container is created again from the store, a start without labels or
+	// environment gets the same ones
+	assert.NoError(st.Close())
+	st, err = jsonstore.NewJSONStore(path)
+	assert.NoError(err)
+	defer st.Close()
+	stored, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	again, err := versionExtras(stored, stored.Versions[0])
+	assert.NoError(err)
+	assert.Equal(first, again)
+	assert.True(docker.ContainerHasExtras(created, again))
+
+	// Starting with other labels is a mismatch, the container is created again, the environment is kept
+	version = stored.Versions[0]
+	s = &Server{store: st}
+	assert.NoError(s.setSimulatorExtras(stored, &version, map[string]string{"case": "5678"}, nil))
+	changed, err := versionExtras(stored, version)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"DEBUG": "1"}, changed.Env)
+	assert.False(docker.ContainerHasExtras(created, changed))
+
+	// Reserved labels are refused and nothing is remembered
+	assert.Error(s.setSimulatorExtras(stored, &version, map[string]string{"sim-cli-managed": "x"}, nil))
+	assert.Equal(map[string]string{"case": "5678"}, version.StartLabels)
+}
+
+func Test_PatchVersion(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now(), Versions: []model.Version{
+		{ID: "v1", Type: model.VersionTypeSupportBundle, Ports: []uint16{8080},
+			StartLabels: map[string]string{"case": "1234"}, StartEnv: map[string]string{"DEBUG": "1"}},
+		{ID: "v2", Type: model.VersionTypeRuntime},
+	}}))
+	s := &Server{store: st, events: events.NewBus()}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	patch := func(versionID, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PATCH", "/api/workspaces/ws/versions/"+versionID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	stored := func() model.Version {
+		ws, err := st.GetWorkspace("ws")
+		assert.NoError(err)
+		return ws.Versions[0]
+	}
+
+	// Invalid labels leave every parameter alone
+	w := patch("v1", `{"ports": [9090], "labels": {"sim-cli-managed": "x"}}`)
+	assert.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Equal([]uint16{8080}, stored().Ports)
+
+	// Empty values clear, left out ones are kept
+	w = patch("v1", `{"ports": [], "labels": {}}`)
+	assert.Equal(http.StatusOK, w.Code, w.Body.String())
+	var version model.Version
+	assert.NoError(json.NewDecoder(w.Body).Decode(&version))
+	assert.Empty(version.Ports)
+	assert.Nil(version.StartLabels)
+	assert.Equal(map[string]string{"DEBUG": "1"}, version.StartEnv)
+	assert.Equal(version.StartEnv, stored().StartEnv)
+	assert.Nil(stored().StartLabels)
+
+	assert.Equal(&StartParameters{Ports: []uint16{}, Labels: map[string]string{}, Env: map[string]string{"DEBUG": "1"}},
+		startParameters(stored()))
+
+	assert.Equal(http.StatusBadRequest, patch("v2", `{"env": {}}`).Code)
+	assert.Equal(http.StatusNotFound, patch("v3", `{"env": {}}`).Code)
+}
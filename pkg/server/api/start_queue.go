@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaultMaxStarts is how many simulators start at once unless SetMaxConcurrentStarts changes it
+const defaultMaxStarts = 2
+
+// errStartCancelled is returned to a queued start whose simulator was stopped before its turn
+var errStartCancelled = errors.New("the start was cancelled while it was queued")
+
+// errStartQueued is returned to a start of a simulator whose start already waits for a slot, both
+// would build it and share a single slot
+var errStartQueued = errors.New("the simulator is already queued to start")
+
+// startQueue bounds the simulators starting at once. A start holds its slot from the image build until
+// the simulator is ready or failed, as extraction, the build and loading the bundle all compete for the
+// same disk and CPU. Starts beyond the limit wait in order of arrival. A nil queue doesn't limit starts,
+// like servers built without NewServer.
+type startQueue struct {
+	mu sync.Mutex
+	// max is the number of slots, 0 doesn't limit the starts
+	max int
+	// active holds the instance names holding a slot
+	active map[string]bool
+	// waiting are the queued starts, the first one gets the next free slot
+	waiting []*queuedStart
+}
+
+type queuedStart struct {
+	instanceName string
+	// ready is closed with err set once the start got its slot or was cancelled
+	ready chan struct{}
+	err   error
+}
+
+func newStartQueue(max int) *startQueue {
+	return &startQueue{max: max, active: make(map[string]bool)}
+}
+
+// setMax changes the number of slots, queued starts are let through when it grew
+func (q *startQueue) setMax(max int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.max = max
+	q.grant()
+}
+
+// acquire waits for a slot for instanceName. It returns errStartCancelled when the start was cancelled
+// and the error of ctx when the request went away, neither holds a slot. An instance already holding
+// one keeps it, one already waiting for one gets errStartQueued.
+func (q *startQueue) acquire(ctx context.Context, instanceName string) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	if q.active[instanceName] {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.queued(instanceName) {
+		q.mu.Unlock()
+		return errStartQueued
+	}
+	start := &queuedStart{instanceName: instanceName, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, start)
+	q.grant()
+	q.mu.Unlock()
+
+	select {
+	case <-start.ready:
+		return start.err
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-start.ready:
+		// Granted meanwhile, the slot goes to the next start
+		if start.err == nil {
+			delete(q.active, instanceName)
+			q.grant()
+		}
+	default:
+		q.remove(start)
+	}
+	return ctx.Err()
+}
+
+// release frees the slot of instanceName once its simulator is ready or failed, an instance without
+// a slot is ignored
+func (q *startQueue) release(instanceName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.active[instanceName] {
+		return
+	}
+	delete(q.active, instanceName)
+	q.grant()
+}
+
+// cancel removes the queued starts of instanceName, they return errStartCancelled
+func (q *startQueue) cancel(instanceName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, start := range append([]*queuedStart{}, q.waiting...) {
+		if start.instanceName == instanceName {
+			q.remove(start)
+			start.err = errStartCancelled
+			close(start.ready)
+		}
+	}
+}
+
+// position returns the place of instanceName in the queue starting at 1, 0 when it isn't queued
+func (q *startQueue) position(instanceName string) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, start := range q.waiting {
+		if start.instanceName == instanceName {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// starting reports whether instanceName holds a slot or waits for one
+func (q *startQueue) starting(instanceName string) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.active[instanceName] || q.queued(instanceName)
+}
+
+// waitingCount returns the number of starts waiting for a slot
+func (q *startQueue) waitingCount() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiting)
+}
+
+// queued reports whether instanceName waits for a slot, q.mu is held
+func (q *startQueue) queued(instanceName string) bool {
+	for _, start := range q.waiting {
+		if start.instanceName == instanceName {
+			return true
+		}
+	}
+	return false
+}
+
+// grant hands the free slots to the waiting starts in order, q.mu is held
+func (q *startQueue) grant() {
+	for len(q.waiting) > 0 && (q.max <= 0 || len(q.active) < q.max) {
+		start := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.active[start.instanceName] = true
+		close(start.ready)
+	}
+}
+
+// remove takes start out of the queue, q.mu is held
+func (q *startQueue) remove(start *queuedStart) {
+	for i, s := range q.waiting {
+		if s == start {
+			q.waiting = append(q.waiting[:i:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
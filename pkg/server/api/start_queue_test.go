@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSimulators stands in for docker, it counts the simulators between their start and ready
+type fakeSimulators struct {
+	mu       sync.Mutex
+	starting int
+	peak     int
+	started  []string
+}
+
+func (f *fakeSimulators) start(instanceName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starting++
+	if f.starting > f.peak {
+		f.peak = f.starting
+	}
+	f.started = append(f.started, instanceName)
+}
+
+func (f *fakeSimulators) ready() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.starting--
+}
+
+// waitQueued waits until n starts are queued
+func waitQueued(t *testing.T, q *startQueue, n int) {
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.waiting) == n
+	}, time.Second, time.Millisecond)
+}
+
+func Test_StartQueueBoundsConcurrentStarts(t *testing.T) {
+	assert := require.New(t)
+	q := newStartQueue(3)
+	sims := &fakeSimulators{}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(instanceName string) {
+			defer wg.Done()
+			if err := q.acquire(context.Background(), instanceName); err != nil {
+				t.Error(err)
+				return
+			}
+			sims.start(instanceName)
+			// loading the bundle
+			time.Sleep(5 * time.Millisecond)
+			sims.ready()
+			q.release(instanceName)
+		}(fmt.Sprintf("ws-v%d", i))
+	}
+	wg.Wait()
+
+	assert.Equal(3, sims.peak)
+	assert.Len(sims.started, 8)
+	assert.Empty(q.active)
+	assert.Empty(q.waiting)
+}
+
+func Test_StartQueueOrderAndCancel(t *testing.T) {
+	assert := require.New(t)
+	q := newStartQueue(1)
+	assert.NoError(q.acquire(context.Background(), "ws-v1"))
+	assert.NoError(q.acquire(context.Background(), "ws-v1"), "a start holding a slot keeps it")
+
+	results := map[string]chan error{"ws-v2": make(chan error, 1), "ws-v3": make(chan error, 1), "ws-v4": make(chan error, 1)}
+	ctx, cancelRequest := context.WithCancel(context.Background())
+	for i, instanceName := range []string{"ws-v2", "ws-v3", "ws-v4"} {
+		reqCtx := context.Background()
+		if instanceName == "ws-v4" {
+			reqCtx = ctx
+		}
+		go func(instanceName string) {
+			results[instanceName] <- q.acquire(reqCtx, instanceName)
+		}(instanceName)
+		waitQueued(t, q, i+1)
+	}
+	// a second start of a queued simulator would share its slot
+	assert.ErrorIs(q.acquire(context.Background(), "ws-v3"), errStartQueued)
+	assert.Len(q.waiting, 3)
+	assert.Equal(0, q.position("ws-v1"))
+	assert.Equal(1, q.position("ws-v2"))
+	assert.Equal(3, q.position("ws-v4"))
+
+	// stopping a queued simulator removes its start
+	q.cancel("ws-v2")
+	assert.ErrorIs(<-results["ws-v2"], errStartCancelled)
+	assert.Equal(1, q.position("ws-v3"))
+
+	// so does the request going away
+	cancelRequest()
+	assert.ErrorIs(<-results["ws-v4"], context.Canceled)
+	assert.Equal(0, q.position("ws-v4"))
+
+	q.release("ws-v1")
+	assert.NoError(<-results["ws-v3"])
+	assert.Equal(map[string]bool{"ws-v3": true}, q.active)
+	q.release("ws-v2")
+	assert.Len(q.active, 1, "releasing an instance without a slot is ignored")
+}
+
+func Test_StartQueueSetMax(t *testing.T) {
+	assert := require.New(t)
+	q := newStartQueue(1)
+	assert.NoError(q.acquire(context.Background(), "ws-v1"))
+
+	result := make(chan error, 1)
+	go func() {
+		result <- q.acquire(context.Background(), "ws-v2")
+	}()
+	waitQueued(t, q, 1)
+
+	// unlimited lets the queued start through
+	q.setMax(0)
+	assert.NoError(<-result)
+	assert.Len(q.active, 2)
+
+	var nilQueue *startQueue
+	assert.NoError(nilQueue.acquire(context.Background(), "ws-v1"))
+	assert.Equal(0, nilQueue.position("ws-v1"))
+}
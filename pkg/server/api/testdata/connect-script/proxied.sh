@@ -0,0 +1,36 @@
+#!/bin/sh
+# Opens a shell connected to the simulator of version v#2 of workspace case 1234's.
+# Generated by sim-gui, exit the shell to disconnect.
+set -eu
+
+server='https://sim.example.com/gui'
+instance='case 1234'\''s-v#2'
+kubeconfig_url="$server"'/api/workspaces/case%201234%27s/versions/v%232/kubeconfig'
+
+command -v curl >/dev/null 2>&1 || { echo "curl is required to download the kubeconfig" >&2; exit 1; }
+
+kubeconfig=$(mktemp "${TMPDIR:-/tmp}/$instance.XXXXXX")
+trap 'rm -f "$kubeconfig"' EXIT
+
+status=$(curl -sS -o "$kubeconfig" -w '%{http_code}' "$kubeconfig_url") || {
+	echo "Failed to reach sim-gui at $server" >&2
+	exit 1
+}
+case "$status" in
+200) ;;
+409)
+	echo "The simulator $instance isn't running, start it in sim-gui first" >&2
+	exit 1
+	;;
+404)
+	echo "The version of $instance no longer exists" >&2
+	exit 1
+	;;
+*)
+	echo "Failed to download the kubeconfig of $instance (HTTP $status): $(cat "$kubeconfig")" >&2
+	exit 1
+	;;
+esac
+
+echo "Connected to $instance, exit the shell to disconnect" >&2
+KUBECONFIG="$kubeconfig" PS1="($instance) ${PS1:-\$ }" "${SHELL:-/bin/sh}" -i
@@ -0,0 +1,43 @@
+#!/bin/sh
+# Opens a shell connected to the simulator of version v1 of workspace ws.
+# Generated by sim-gui, exit the shell to disconnect.
+# It holds your sim-gui token, keep it private.
+set -eu
+
+server='http://localhost:8080'
+instance='ws-v1'
+kubeconfig_url="$server"'/api/workspaces/ws/versions/v1/kubeconfig'
+token='s3cr'\''et'
+
+command -v curl >/dev/null 2>&1 || { echo "curl is required to download the kubeconfig" >&2; exit 1; }
+
+kubeconfig=$(mktemp "${TMPDIR:-/tmp}/$instance.XXXXXX")
+trap 'rm -f "$kubeconfig"' EXIT
+
+# The header is read from stdin, the token doesn't show in the arguments of curl
+status=$(printf 'Authorization: Bearer %s\n' "$token" | curl -sS -H @- -o "$kubeconfig" -w '%{http_code}' "$kubeconfig_url") || {
+	echo "Failed to reach sim-gui at $server" >&2
+	exit 1
+}
+case "$status" in
+200) ;;
+409)
+	echo "The simulator $instance isn't running, start it in sim-gui first" >&2
+	exit 1
+	;;
+401|403)
+	echo "The token isn't allowed to download the kubeconfig of $instance, generate the script again" >&2
+	exit 1
+	;;
+404)
+	echo "The version of $instance no longer exists" >&2
+	exit 1
+	;;
+*)
+	echo "Failed to download the kubeconfig of $instance (HTTP $status): $(cat "$kubeconfig")" >&2
+	exit 1
+	;;
+esac
+
+echo "Connected to $instance, exit the shell to disconnect" >&2
+KUBECONFIG="$kubeconfig" PS1="($instance) ${PS1:-\$ }" "${SHELL:-/bin/sh}" -i
@@ -0,0 +1,549 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+)
+
+// trashDir holds deleted workspaces and versions until they are restored or purged, relative to the
+// data directory. Like stagingDir it is outside the workspaces so the consistency check doesn't
+// report it, and on the same filesystem so files are moved in and out with a rename.
+const trashDir = ".trash"
+
+// trashManifest is the file of a trash entry holding the deleted records
+const trashManifest = "entry.json"
+
+var errTrashEntryNotFound = errors.New("trash entry not found")
+
+// TrashEntry is a deleted workspace or version. The files are kept in trashDir/<ID>/workspace and the
+// bundles under trashDir/<ID>/bundles keys of the bundle store, so a new version reusing the ID of a
+// deleted one doesn't overwrite them.
+type TrashEntry struct {
+	ID        string `json:"id"`
+	Workspace string `json:"workspace"`
+	// VersionID is empty when the whole workspace was deleted
+	VersionID string    `json:"versionID,omitempty"`
+	DeletedAt time.Time `json:"deletedAt"`
+	// PurgeAt is when the entry is removed for good, nil when the trash is kept until restored
+	PurgeAt *time.Time `json:"purgeAt,omitempty"`
+	// WorkspaceRecord is the deleted workspace without its versions
+	WorkspaceRecord *model.Workspace `json:"workspaceRecord,omitempty"`
+	// Versions are the deleted versions as they were stored, their bundles moved into the trash
+	Versions []model.Version `json:"versions"`
+	// Replaced is set when the bundle of VersionID was replaced, the version itself wasn't deleted
+	Replaced bool `json:"replaced,omitempty"`
+}
+
+// RestoredVersion is a version put back from the trash, ID differs from OriginalID when the ID was
+// taken by a version created after the deletion
+type RestoredVersion struct {
+	ID         string `json:"id"`
+	OriginalID string `json:"originalID"`
+}
+
+type TrashRestoreResult struct {
+	Workspace string            `json:"workspace"`
+	Versions  []RestoredVersion `json:"versions"`
+}
+
+// SetTrashDays sets how long deleted workspaces and versions are kept in the trash, 0 keeps them until restored
+func (s *Server) SetTrashDays(days int) {
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+	s.trashDays = days
+}
+
+func (s *Server) trashPath(id string, elem ...string) string {
+	return filepath.Join(append([]string{s.dataDir, trashDir, id}, elem...)...)
+}
+
+// newTrashEntry creates the directory of an entry named after the deletion time and what was deleted
+func (s *Server) newTrashEntry(name string, now time.Time) (string, error) {
+	if err := os.MkdirAll(filepath.Join(s.dataDir, trashDir), 0755); err != nil {
+		return "", err
+	}
+	base := now.UTC().Format("20060102-150405") + "-" + name
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		err := os.Mkdir(s.trashPath(id), 0755)
+		if err == nil {
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+func (s *Server) readTrashEntry(id string) (*TrashEntry, error) {
+	// IDs come from the URL, they must name a directory right under trashDir
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return nil, errTrashEntryNotFound
+	}
+	data, err := os.ReadFile(s.trashPath(id, trashManifest))
+	if os.IsNotExist(err) {
+		return nil, errTrashEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to read trash entry %s: %w", id, err)
+	}
+	return &entry, nil
+}
+
+func (s *Server) writeTrashEntry(entry *TrashEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.trashPath(entry.ID, trashManifest), data, 0644)
+}
+
+// listTrash returns the entries of the trash, newest first. Entries without a readable manifest,
+// left by a deletion that was interrupted, are skipped.
+func (s *Server) listTrash() ([]TrashEntry, error) {
+	dirs, err := os.ReadDir(filepath.Join(s.dataDir, trashDir))
+	if os.IsNotExist(err) {
+		return []TrashEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []TrashEntry{}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := s.readTrashEntry(d.Name())
+		if err != nil {
+			fmt.Printf("Skipping trash entry %s: %v\n", d.Name(), err)
+			continue
+		}
+		if s.trashDays > 0 {
+			purgeAt := entry.DeletedAt.Add(time.Duration(s.trashDays) * 24 * time.Hour)
+			entry.PurgeAt = &purgeAt
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// bundleMove is a bundle moved to another key of the bundle store
+type bundleMove struct {
+	from, to string
+}
+
+// moveBundles renames bundles in the bundle store, those already moved are moved back when one fails.
+// Missing bundles are skipped, their versions are already broken.
+func (s *Server) moveBundles(ctx context.Context, moves []bundleMove) error {
+	for i, m := range moves {
+		err := s.bundleStore().Rename(ctx, m.from, m.to)
+		if err == nil || errors.Is(err, bundlestore.ErrNotFound) {
+			continue
+		}
+		s.undoBundleMoves(moves[:i])
+		return fmt.Errorf("failed to move bundle %s: %w", m.from, err)
+	}
+	return nil
+}
+
+func (s *Server) undoBundleMoves(moves []bundleMove) {
+	for _, m := range moves {
+		err := s.bundleStore().Rename(context.Background(), m.to, m.from)
+		if err != nil && !errors.Is(err, bundlestore.ErrNotFound) {
+			fmt.Printf("Failed to move bundle %s back to %s: %v\n", m.to, m.from, err)
+		}
+	}
+}
+
+// moveBundleTo points the bundle of v at dir, a key prefix, and returns the move of the bundle.
+// Bundles of old releases stored outside the data directory are left where they are.
+func moveBundleTo(v *model.Version, dir string) (bundleMove, bool) {
+	if v.BundlePath == "" || filepath.IsAbs(v.BundlePath) {
+		return bundleMove{}, false
+	}
+	from := bundleKey(v.BundlePath)
+	to := path.Join(dir, path.Base(from))
+	v.BundlePath = filepath.FromSlash(to)
+	return bundleMove{from: from, to: to}, true
+}
+
+// trashVersions writes the manifest of a new trash entry and moves the bundles of its versions into
+// it. The directory of the entry is removed again when a step fails.
+func (s *Server) trashVersions(ctx context.Context, entry *TrashEntry) ([]bundleMove, error) {
+	var moves []bundleMove
+	for i := range entry.Versions {
+		if m, ok := moveBundleTo(&entry.Versions[i], path.Join(trashDir, entry.ID, "bundles", entry.Versions[i].ID)); ok {
+			moves = append(moves, m)
+		}
+	}
+
+	// The manifest is written first so the bundles can be found whatever happens next
+	if err := s.writeTrashEntry(entry); err != nil {
+		os.RemoveAll(s.trashPath(entry.ID))
+		return nil, err
+	}
+	if err := s.moveBundles(ctx, moves); err != nil {
+		os.RemoveAll(s.trashPath(entry.ID))
+		return nil, err
+	}
+	return moves, nil
+}
+
+// trashVersion moves a version into the trash: its record, bundle and files are kept until it is
+// restored or purged. Like removeVersion, a failure leaves the version as it was.
+func (s *Server) trashVersion(workspace string, version model.Version) error {
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+
+	now := time.Now()
+	id, err := s.newTrashEntry(workspace+"-"+version.ID, now)
+	if err != nil {
+		return err
+	}
+	entry := &TrashEntry{
+		ID:        id,
+		Workspace: workspace,
+		VersionID: version.ID,
+		DeletedAt: now,
+		Versions:  []model.Version{version},
+	}
+	moves, err := s.trashVersions(context.Background(), entry)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		s.undoBundleMoves(moves)
+		os.RemoveAll(s.trashPath(id))
+		return err
+	}
+
+	versionPath := filepath.Join(s.dataDir, "workspaces", workspace, version.ID)
+	trashed := s.trashPath(id, "workspace", version.ID)
+	if err := os.MkdirAll(filepath.Dir(trashed), 0755); err != nil {
+		return fail(err)
+	}
+	moved := true
+	if err := os.Rename(versionPath, trashed); err != nil {
+		if !os.IsNotExist(err) {
+			return fail(fmt.Errorf("failed to move files to the trash: %w", err))
+		}
+		moved = false
+	}
+
+	if _, err := s.dropVersion(workspace, version.ID); err != nil {
+		if moved {
+			if restoreErr := os.Rename(trashed, versionPath); restoreErr != nil {
+				// Kept in the trash entry, it can be restored from there
+				fmt.Printf("Failed to move files of %s/%s back from the trash: %v\n", workspace, version.ID, restoreErr)
+				return err
+			}
+		}
+		return fail(err)
+	}
+	return nil
+}
+
+// trashWorkspace moves a workspace with all its versions into the trash
+func (s *Server) trashWorkspace(ws model.Workspace) error {
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+
+	now := time.Now()
+	id, err := s.newTrashEntry(ws.Name, now)
+	if err != nil {
+		return err
+	}
+	record := ws
+	record.Versions = nil
+	entry := &TrashEntry{
+		ID:              id,
+		Workspace:       ws.Name,
+		DeletedAt:       now,
+		WorkspaceRecord: &record,
+		Versions:        append([]model.Version{}, ws.Versions...),
+	}
+	moves, err := s.trashVersions(context.Background(), entry)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		s.undoBundleMoves(moves)
+		os.RemoveAll(s.trashPath(id))
+		return err
+	}
+
+	workspacePath := filepath.Join(s.dataDir, "workspaces", ws.Name)
+	trashed := s.trashPath(id, "workspace")
+	moved := true
+	if err := os.Rename(workspacePath, trashed); err != nil {
+		if !os.IsNotExist(err) {
+			return fail(fmt.Errorf("failed to move files to the trash: %w", err))
+		}
+		moved = false
+	}
+
+	if err := s.store.DeleteWorkspace(ws.Name); err != nil {
+		if moved {
+			if restoreErr := os.Rename(trashed, workspacePath); restoreErr != nil {
+				fmt.Printf("Failed to move files of %s back from the trash: %v\n", ws.Name, restoreErr)
+				return err
+			}
+		}
+		return fail(err)
+	}
+	return nil
+}
+
+// trashRestore is what restoreTrash put back
+type trashRestore struct {
+	workspace *model.Workspace
+	// created is set when the workspace was created again
+	created  bool
+	versions []model.Version
+	ids      []RestoredVersion
+}
+
+// restoreTrash puts the versions of a trash entry back into their workspace, which is created again
+// when it no longer exists. Versions whose ID was reused get the next free ID. The entry is left in
+// the trash when a step fails. The workspace is created like createWorkspace does and can't be restored
+// while it is being deleted.
+func (s *Server) restoreTrash(id string) (*trashRestore, error) {
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+	s.workspaceLock.Lock()
+	defer s.workspaceLock.Unlock()
+
+	entry, err := s.readTrashEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.deleting[store.NameKey(entry.Workspace)] {
+		return nil, errWorkspaceDeleting
+	}
+
+	ws, err := s.store.GetWorkspace(entry.Workspace)
+	create := err != nil
+	if create {
+		if entry.WorkspaceRecord != nil {
+			record := *entry.WorkspaceRecord
+			ws = &record
+		} else {
+			// The workspace of the version was deleted for good since
+			ws = &model.Workspace{Name: entry.Workspace, DisplayName: entry.Workspace, CreatedAt: time.Now()}
+		}
+	}
+	existing := &model.Workspace{Versions: append([]model.Version{}, ws.Versions...)}
+
+	type dirMove struct{ from, to string }
+	var (
+		dirs     []dirMove
+		bundles  []bundleMove
+		restored []model.Version
+		ids      []RestoredVersion
+	)
+	for _, v := range entry.Versions {
+		originalID := v.ID
+		v.ID = s.freeVersionID(entry.Workspace, existing, v.ID)
+		v.Instance = s.instanceName(entry.Workspace, v.ID)
+
+		oldDir := filepath.Join("workspaces", entry.Workspace, originalID)
+		newDir := filepath.Join("workspaces", entry.Workspace, v.ID)
+		v.Path = rebasePath(v.Path, oldDir, newDir)
+		v.KubeconfigPath = rebasePath(v.KubeconfigPath, oldDir, newDir)
+		if m, ok := moveBundleTo(&v, filepath.ToSlash(newDir)); ok {
+			bundles = append(bundles, m)
+		}
+		// Its simulator was removed with the deletion
+		v.Ready = false
+
+		dirs = append(dirs, dirMove{from: s.trashPath(id, "workspace", originalID), to: filepath.Join(s.dataDir, newDir)})
+		existing.Versions = append(existing.Versions, v)
+		restored = append(restored, v)
+		ids = append(ids, RestoredVersion{ID: v.ID, OriginalID: originalID})
+	}
+
+	// The directory of a workspace created again is reserved before the versions are moved into it
+	reserved := ""
+	if create {
+		if reserved, err = s.reserveWorkspaceDir(entry.Workspace); err != nil {
+			return nil, err
+		}
+	}
+
+	// Directories first, the local bundle store creates the directory of the bundles it moves
+	var moved []dirMove
+	undoDirs := func() {
+		for _, m := range moved {
+			if err := os.Rename(m.to, m.from); err != nil {
+				fmt.Printf("Failed to move %s back to the trash: %v\n", m.to, err)
+			}
+		}
+		if reserved != "" {
+			os.Remove(reserved)
+		}
+	}
+	for _, m := range dirs {
+		if err := os.MkdirAll(filepath.Dir(m.to), 0755); err != nil {
+			undoDirs()
+			return nil, err
+		}
+		if err := os.Rename(m.from, m.to); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			undoDirs()
+			return nil, fmt.Errorf("failed to move files out of the trash: %w", err)
+		}
+		moved = append(moved, m)
+	}
+	if err := s.moveBundles(context.Background(), bundles); err != nil {
+		undoDirs()
+		return nil, err
+	}
+
+	ws.Versions = existing.Versions
+	if create {
+		err = s.store.CreateWorkspace(*ws)
+	} else {
+		err = s.store.UpdateWorkspace(*ws)
+	}
+	if err != nil {
+		s.undoBundleMoves(bundles)
+		undoDirs()
+		return nil, err
+	}
+
+	if err := os.RemoveAll(s.trashPath(id)); err != nil {
+		fmt.Printf("Failed to remove trash entry %s: %v\n", id, err)
+	}
+	return &trashRestore{workspace: ws, created: create, versions: restored, ids: ids}, nil
+}
+
+// freeVersionID returns id when neither a version of ws nor a directory uses it, or else the next free ID
+func (s *Server) freeVersionID(workspace string, ws *model.Workspace, id string) string {
+	inUse := func(id string) bool {
+		if _, ok := findVersion(ws, id); ok {
+			return true
+		}
+		_, err := os.Stat(filepath.Join(s.dataDir, "workspaces", workspace, id))
+		return err == nil
+	}
+	if !inUse(id) {
+		return id
+	}
+	for n := versionNumber(getNextVersionID(ws)); ; n++ {
+		if id := fmt.Sprintf("v%d", n); !inUse(id) {
+			return id
+		}
+	}
+}
+
+// purgeTrashEntry removes an entry with its files and bundles for good
+func (s *Server) purgeTrashEntry(entry TrashEntry) error {
+	for _, v := range entry.Versions {
+		if v.BundlePath == "" || filepath.IsAbs(v.BundlePath) {
+			continue
+		}
+		if err := s.bundleStore().Delete(context.Background(), bundleKey(v.BundlePath)); err != nil {
+			return fmt.Errorf("failed to remove bundle of %s: %w", v.ID, err)
+		}
+	}
+	return os.RemoveAll(s.trashPath(entry.ID))
+}
+
+// sweepTrash purges the entries deleted more than trashDays ago
+func (s *Server) sweepTrash(now time.Time) {
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+
+	if s.trashDays <= 0 {
+		return
+	}
+	entries, err := s.listTrash()
+	if err != nil {
+		fmt.Printf("Trash sweep failed to list entries: %v\n", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.PurgeAt == nil || now.Before(*entry.PurgeAt) {
+			continue
+		}
+		if err := s.purgeTrashEntry(entry); err != nil {
+			fmt.Printf("Trash: failed to purge %s: %v\n", entry.ID, err)
+			continue
+		}
+		fmt.Printf("Trash: purged %s deleted at %s\n", entry.ID, entry.DeletedAt.Format(time.RFC3339))
+	}
+}
+
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	s.trashLock.Lock()
+	entries, err := s.listTrash()
+	s.trashLock.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) handleRestoreTrash(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	restored, err := s.restoreTrash(id)
+	if errors.Is(err, errTrashEntryNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errWorkspaceDeleting) || errors.Is(err, errWorkspaceFilesLeft) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, os.ErrExist) {
+		http.Error(w, "Another workspace has the name, names are compared regardless of case", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := restored.workspace.Name
+	user := s.requestUser(r)
+	if restored.created {
+		s.events.PublishBy(user, events.WorkspaceCreated, name, "", restored.workspace)
+	}
+	for i := range restored.versions {
+		s.events.PublishBy(user, events.VersionRestored, name, restored.versions[i].ID, &restored.versions[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TrashRestoreResult{Workspace: name, Versions: restored.ids})
+}
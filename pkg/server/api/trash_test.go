@@ -0,0 +1,171 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+// writeVersion returns a createVersion writer of a version with a bundle and a kubeconfig
+func writeVersion(id, bundle string) func(dir string) (*model.Version, error) {
+	return func(dir string) (*model.Version, error) {
+		bundlePath := filepath.Join(dir, "bundle.zip")
+		if err := os.WriteFile(bundlePath, []byte(bundle), 0644); err != nil {
+			return nil, err
+		}
+		kubeconfigPath := filepath.Join(dir, "admin.kubeconfig")
+		if err := os.WriteFile(kubeconfigPath, []byte("apiVersion: v1"), 0644); err != nil {
+			return nil, err
+		}
+		return &model.Version{ID: id, Type: model.VersionTypeSupportBundle, BundlePath: bundlePath, KubeconfigPath: kubeconfigPath, Ready: true}, nil
+	}
+}
+
+func Test_TrashVersionAndRestore(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	_, err := s.createVersion("ws", "v1", writeVersion("v1", "old"))
+	assert.NoError(err)
+
+	version, ok := findVersionIn(t, s, "ws", "v1")
+	assert.True(ok)
+	assert.NoError(s.trashVersion("ws", version))
+	_, ok = findVersionIn(t, s, "ws", "v1")
+	assert.False(ok)
+	assert.NoDirExists(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	entry := entries[0]
+	assert.Equal("ws", entry.Workspace)
+	assert.Equal("v1", entry.VersionID)
+	assert.Nil(entry.PurgeAt, "kept until restored without a trash window")
+	assert.FileExists(filepath.Join(s.dataDir, entry.Versions[0].BundlePath))
+	assert.FileExists(s.trashPath(entry.ID, "workspace", "v1", "admin.kubeconfig"))
+
+	// a new upload takes the ID of the deleted version
+	_, err = s.createVersion("ws", "v1", writeVersion("v1", "new"))
+	assert.NoError(err)
+
+	restored, err := s.restoreTrash(entry.ID)
+	assert.NoError(err)
+	assert.False(restored.created)
+	assert.Equal([]RestoredVersion{{ID: "v2", OriginalID: "v1"}}, restored.ids)
+
+	v2, ok := findVersionIn(t, s, "ws", "v2")
+	assert.True(ok)
+	assert.False(v2.Ready)
+	assert.Equal(filepath.Join("workspaces", "ws", "v2", "bundle.zip"), v2.BundlePath)
+	assert.Equal(filepath.Join("workspaces", "ws", "v2", "admin.kubeconfig"), v2.KubeconfigPath)
+	content, err := os.ReadFile(filepath.Join(s.dataDir, v2.BundlePath))
+	assert.NoError(err)
+	assert.Equal("old", string(content))
+	content, err = os.ReadFile(filepath.Join(s.dataDir, "workspaces", "ws", "v1", "bundle.zip"))
+	assert.NoError(err)
+	assert.Equal("new", string(content))
+	assert.FileExists(filepath.Join(s.dataDir, v2.KubeconfigPath))
+
+	entries, err = s.listTrash()
+	assert.NoError(err)
+	assert.Empty(entries)
+
+	_, err = s.restoreTrash(entry.ID)
+	assert.ErrorIs(err, errTrashEntryNotFound)
+	_, err = s.restoreTrash("../workspaces")
+	assert.ErrorIs(err, errTrashEntryNotFound)
+}
+
+func Test_TrashWorkspaceAndRestore(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	bundles := &memoryBundles{objects: map[string][]byte{}}
+	s.SetBundleStore(bundles)
+	_, err := s.createVersion("ws", "v1", writeVersion("v1", "zip"))
+	assert.NoError(err)
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	ws.DefaultNamespace = "harvester-system"
+
+	assert.NoError(s.trashWorkspace(*ws))
+	_, err = s.store.GetWorkspace("ws")
+	assert.Error(err)
+	assert.NoDirExists(filepath.Join(s.dataDir, "workspaces", "ws"))
+	assert.Len(bundles.objects, 1)
+	assert.NotContains(bundles.objects, "workspaces/ws/v1/bundle.zip", "a new workspace of the same name can't overwrite it")
+
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Empty(entries[0].VersionID)
+
+	restored, err := s.restoreTrash(entries[0].ID)
+	assert.NoError(err)
+	assert.True(restored.created)
+	assert.Equal([]RestoredVersion{{ID: "v1", OriginalID: "v1"}}, restored.ids)
+
+	ws, err = s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal("harvester-system", ws.DefaultNamespace)
+	assert.Len(ws.Versions, 1)
+	assert.Equal([]byte("zip"), bundles.objects["workspaces/ws/v1/bundle.zip"])
+	assert.FileExists(filepath.Join(s.dataDir, ws.Versions[0].KubeconfigPath))
+}
+
+func Test_TrashVersionLeavesVersionOnStoreFailure(t *testing.T) {
+	assert := require.New(t)
+	s, failing := newFilesServer(t)
+	bundles := &memoryBundles{objects: map[string][]byte{}}
+	s.SetBundleStore(bundles)
+	_, err := s.createVersion("ws", "v1", writeVersion("v1", "zip"))
+	assert.NoError(err)
+	version, _ := findVersionIn(t, s, "ws", "v1")
+
+	failing.failUpdate = true
+	assert.ErrorIs(s.trashVersion("ws", version), errDiskFull)
+
+	_, ok := findVersionIn(t, s, "ws", "v1")
+	assert.True(ok)
+	assert.FileExists(filepath.Join(s.dataDir, version.KubeconfigPath))
+	assert.Contains(bundles.objects, "workspaces/ws/v1/bundle.zip")
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func Test_SweepTrash(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	bundles := &memoryBundles{objects: map[string][]byte{}}
+	s.SetBundleStore(bundles)
+	s.SetTrashDays(1)
+	_, err := s.createVersion("ws", "v1", writeVersion("v1", "zip"))
+	assert.NoError(err)
+	version, _ := findVersionIn(t, s, "ws", "v1")
+	assert.NoError(s.trashVersion("ws", version))
+
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.NotNil(entries[0].PurgeAt)
+
+	s.sweepTrash(time.Now())
+	entries, err = s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+
+	s.sweepTrash(time.Now().Add(25 * time.Hour))
+	entries, err = s.listTrash()
+	assert.NoError(err)
+	assert.Empty(entries)
+	assert.Empty(bundles.objects)
+}
+
+func findVersionIn(t *testing.T, s *Server, workspace, versionID string) (model.Version, bool) {
+	ws, err := s.store.GetWorkspace(workspace)
+	require.NoError(t, err)
+	return findVersion(ws, versionID)
+}
@@ -7,14 +7,17 @@ import (
 	"github.com/Yu-Jack/sim-gui/pkg/updater"
 )
 
+// disabledUpdateStatus is reported when the update checker is not running
+var disabledUpdateStatus = updater.UpdateStatus{
+	UpdateAvailable: false,
+	Message:         "Update checking is disabled",
+}
+
 func (s *Server) handleGetUpdateStatus(w http.ResponseWriter, r *http.Request) {
 	// If updater is not initialized, return disabled status
 	if s.updater == nil {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(updater.UpdateStatus{
-			UpdateAvailable: false,
-			Message:         "Update checking is disabled",
-		})
+		json.NewEncoder(w).Encode(disabledUpdateStatus)
 		return
 	}
 
@@ -22,3 +25,16 @@ func (s *Server) handleGetUpdateStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
+
+func (s *Server) handleCheckForUpdates(w http.ResponseWriter, r *http.Request) {
+	if s.updater == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(disabledUpdateStatus)
+		return
+	}
+
+	// Check synchronously so the caller gets the fresh status
+	status := s.updater.CheckNow()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/Yu-Jack/sim-gui/pkg/bundle"
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
 	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
 )
@@ -17,39 +20,211 @@ import (
 func getNextVersionID(ws *model.Workspace) string {
 	maxVersion := 0
 	for _, v := range ws.Versions {
-		var vNum int
-		if _, err := fmt.Sscanf(v.ID, "v%d", &vNum); err == nil && vNum > maxVersion {
+		if vNum := versionNumber(v.ID); vNum > maxVersion {
 			maxVersion = vNum
 		}
 	}
 	return fmt.Sprintf("v%d", maxVersion+1)
 }
 
-func isKubeconfigFile(files []*multipart.FileHeader) bool {
-	if len(files) != 1 {
+// versionNumber returns the number of a version ID such as v3, or 0 if it isn't numbered
+func versionNumber(id string) int {
+	var vNum int
+	if _, err := fmt.Sscanf(id, "v%d", &vNum); err != nil {
+		return 0
+	}
+	return vNum
+}
+
+// UploadLimits bounds the resources used by a version upload
+type UploadLimits struct {
+	// MaxRequestSize is the largest upload request accepted in bytes, 0 disables the limit
+	MaxRequestSize int64
+	// BufferSize is the memory in bytes used per request to stream the uploaded files to disk
+	BufferSize int
+	// Extract bounds what extracting an uploaded bundle writes, and the archives nested in it for code-server
+	Extract utils.ExtractLimits
+}
+
+var defaultUploadLimits = UploadLimits{
+	MaxRequestSize: 10 << 30,
+	BufferSize:     1 << 20,
+	Extract:        utils.DefaultExtractLimits,
+}
+
+var errNoFileUploaded = errors.New("no file uploaded")
+
+// limitUploadSize refuses oversized uploads before reading them, the body is still capped in case
+// Content-Length is missing. It returns false once the response was written.
+func limitUploadSize(w http.ResponseWriter, r *http.Request, limits UploadLimits) bool {
+	if limits.MaxRequestSize <= 0 {
+		return true
+	}
+	if r.ContentLength > limits.MaxRequestSize {
+		http.Error(w, fmt.Sprintf("Upload exceeds the maximum size of %d bytes", limits.MaxRequestSize), http.StatusRequestEntityTooLarge)
 		return false
 	}
-	ext := strings.ToLower(filepath.Ext(files[0].Filename))
-	return ext == ".kubeconfig" || ext == ".yaml" || ext == ".yml"
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxRequestSize)
+	return true
 }
 
-func processKubeconfigUpload(files []*multipart.FileHeader, versionPath, versionID string) (*model.Version, error) {
-	fileHeader := files[0]
-	file, err := fileHeader.Open()
-	if err != nil {
-		return nil, err
+// uploadError returns the status code of an error from saveUploadParts
+func uploadError(err error, limits UploadLimits) (int, error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds the maximum size of %d bytes", limits.MaxRequestSize)
 	}
-	defer file.Close()
+	return extractLimitStatus(err, http.StatusBadRequest), err
+}
 
-	bundleName := filepath.Base(fileHeader.Filename)
-	bundlePath := filepath.Join(versionPath, bundleName)
-	destFile, err := os.Create(bundlePath)
+// extractLimitStatus returns 422 for a bundle refused by its extraction limits, status otherwise
+func extractLimitStatus(err error, status int) int {
+	var limitErr *utils.ExtractLimitError
+	if errors.As(err, &limitErr) {
+		return http.StatusUnprocessableEntity
+	}
+	return status
+}
+
+// layoutExtracted is the value of the layout form field for a tar of an already extracted bundle
+const layoutExtracted = "extracted"
+
+var errMixedUpload = fmt.Errorf("layout=%s expects the layout field followed by a single uncompressed .tar file", layoutExtracted)
+
+// uploadedFile is a file of an upload request which was already written to the version directory
+type uploadedFile struct {
+	Name string // Filename sent by the client
+	Path string
+}
+
+// uploadForm is an upload request saved by saveUploadParts
+type uploadForm struct {
+	Layout string // Empty or layoutExtracted
+	Files  []uploadedFile
+}
+
+// saveUploadParts streams the "file" parts of a multipart request into dir as they arrive, so memory
+// use doesn't grow with the size of the upload. With layout=extracted the file is a tar which is
+// unpacked into the extracted directory within the extraction limits instead, so the layout field must
+// come first. Other parts are discarded.
+func saveUploadParts(reader *multipart.Reader, dir string, limits UploadLimits) (uploadForm, error) {
+	buf := make([]byte, limits.BufferSize)
+	var form uploadForm
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return form, err
+		}
+
+		name := part.FileName()
+		if part.FormName() == "layout" && name == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 64))
+			part.Close()
+			if err != nil {
+				return form, err
+			}
+			form.Layout = strings.TrimSpace(string(value))
+			if form.Layout != "" && form.Layout != layoutExtracted {
+				return form, fmt.Errorf("invalid layout %q, expected %s", form.Layout, layoutExtracted)
+			}
+			if form.Layout == layoutExtracted && len(form.Files) > 0 {
+				return form, errMixedUpload
+			}
+			continue
+		}
+		if part.FormName() != "file" || name == "" {
+			part.Close()
+			continue
+		}
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			part.Close()
+			return form, fmt.Errorf("invalid file name %q", name)
+		}
+
+		if form.Layout == layoutExtracted {
+			if len(form.Files) > 0 || strings.ToLower(filepath.Ext(name)) != ".tar" {
+				part.Close()
+				return form, errMixedUpload
+			}
+			path := filepath.Join(dir, "extracted")
+			err = utils.Untar(part, path, limits.Extract)
+			part.Close()
+			if err != nil {
+				return form, fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			form.Files = append(form.Files, uploadedFile{Name: name, Path: path})
+			continue
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf(".upload-%d", len(form.Files)))
+		err = writePart(part, path, buf)
+		part.Close()
+		if err != nil {
+			return form, err
+		}
+		form.Files = append(form.Files, uploadedFile{Name: name, Path: path})
+	}
+}
+
+func writePart(part io.Reader, path string, buf []byte) error {
+	destFile, err := os.Create(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, file); err != nil {
+	// Hide ReadFrom of *os.File, it would copy with its own buffer instead of buf
+	if _, err := io.CopyBuffer(struct{ io.Writer }{destFile}, part, buf); err != nil {
+		return err
+	}
+	return destFile.Close()
+}
+
+// writeUpload returns the write function of createVersion and replaceVersion saving the parts of an
+// upload request, status is set to the HTTP status of the failure
+func writeUpload(reader *multipart.Reader, limits UploadLimits, versionID string, status *int) func(dir string) (*model.Version, error) {
+	return func(dir string) (*model.Version, error) {
+		form, err := saveUploadParts(reader, dir, limits)
+		if err != nil {
+			*status, err = uploadError(err, limits)
+			return nil, err
+		}
+		if len(form.Files) == 0 {
+			*status = http.StatusBadRequest
+			return nil, errNoFileUploaded
+		}
+
+		if form.Layout == layoutExtracted {
+			return processExtractedUpload(form.Files, versionID)
+		}
+		if isKubeconfigFile(form.Files) {
+			return processKubeconfigUpload(form.Files, dir, versionID)
+		}
+		version, err := processSupportBundleUpload(form.Files, dir, versionID, limits.Extract)
+		*status = extractLimitStatus(err, *status)
+		return version, err
+	}
+}
+
+func isKubeconfigFile(files []uploadedFile) bool {
+	if len(files) != 1 {
+		return false
+	}
+	return isKubeconfigName(files[0].Name)
+}
+
+func isKubeconfigName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".kubeconfig" || ext == ".yaml" || ext == ".yml"
+}
+
+func processKubeconfigUpload(files []uploadedFile, versionPath, versionID string) (*model.Version, error) {
+	bundleName := files[0].Name
+	bundlePath := filepath.Join(versionPath, bundleName)
+	if err := os.Rename(files[0].Path, bundlePath); err != nil {
 		return nil, err
 	}
 
@@ -64,56 +239,28 @@ func processKubeconfigUpload(files []*multipart.FileHeader, versionPath, version
 	}, nil
 }
 
-func processSupportBundleUpload(files []*multipart.FileHeader, versionPath, versionID string) (*model.Version, error) {
+func processSupportBundleUpload(files []uploadedFile, versionPath, versionID string, limits utils.ExtractLimits) (*model.Version, error) {
 	var bundlePath string
 	var bundleName string
 
 	if len(files) == 1 {
 		// Single file
-		fileHeader := files[0]
-		file, err := fileHeader.Open()
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		bundleName = filepath.Base(fileHeader.Filename)
+		bundleName = files[0].Name
 		bundlePath = filepath.Join(versionPath, bundleName)
-		destFile, err := os.Create(bundlePath)
-		if err != nil {
-			return nil, err
-		}
-		defer destFile.Close()
-
-		if _, err := io.Copy(destFile, file); err != nil {
+		if err := os.Rename(files[0].Path, bundlePath); err != nil {
 			return nil, err
 		}
 	} else {
 		// Multiple files (split bundle)
 		sort.Slice(files, func(i, j int) bool {
-			return files[i].Filename < files[j].Filename
+			return files[i].Name < files[j].Name
 		})
 
 		bundleName = "bundle.zip"
 		bundlePath = filepath.Join(versionPath, bundleName)
-
-		destFile, err := os.Create(bundlePath)
-		if err != nil {
+		if err := joinUploadedFiles(files, bundlePath); err != nil {
 			return nil, err
 		}
-		defer destFile.Close()
-
-		for _, fileHeader := range files {
-			f, err := fileHeader.Open()
-			if err != nil {
-				return nil, err
-			}
-			if _, err := io.Copy(destFile, f); err != nil {
-				f.Close()
-				return nil, err
-			}
-			f.Close()
-		}
 	}
 
 	// Extract
@@ -122,16 +269,81 @@ func processSupportBundleUpload(files []*multipart.FileHeader, versionPath, vers
 		return nil, err
 	}
 
-	if err := utils.Unzip(bundlePath, extractPath); err != nil {
-		return nil, fmt.Errorf("failed to extract: %v", err)
+	if err := utils.Unzip(bundlePath, extractPath, limits); err != nil {
+		return nil, fmt.Errorf("failed to extract: %w", err)
+	}
+	if err := indexExtracted(extractPath, versionID); err != nil {
+		return nil, err
 	}
 
+	meta := bundle.ReadMetadata(extractPath)
+
 	return &model.Version{
-		ID:                versionID,
-		Name:              versionID,
-		Type:              model.VersionTypeSupportBundle,
-		CreatedAt:         time.Now(),
-		SupportBundleName: bundleName,
-		BundlePath:        bundlePath,
+		ID:                   versionID,
+		Name:                 versionID,
+		Type:                 model.VersionTypeSupportBundle,
+		CreatedAt:            time.Now(),
+		SupportBundleName:    bundleName,
+		BundlePath:           bundlePath,
+		HarvesterVersion:     meta.HarvesterVersion,
+		KubernetesVersion:    meta.KubernetesVersion,
+		NodeCount:            meta.NodeCount,
+		ClusterName:          meta.ClusterName,
+		CollectedAt:          meta.CollectedAt,
+		CollectedAtEstimated: meta.CollectedAtEstimated,
+	}, nil
+}
+
+// joinUploadedFiles concatenates files into path and removes them
+func joinUploadedFiles(files []uploadedFile, path string) error {
+	destFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	for _, file := range files {
+		f, err := os.Open(file.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(destFile, f); err != nil {
+			f.Close()
+			return err
+		}
+		f.Close()
+		if err := os.Remove(file.Path); err != nil {
+			return err
+		}
+	}
+	return destFile.Close()
+}
+
+// processExtractedUpload creates the version of a bundle uploaded with layout=extracted, whose
+// files were already unpacked by saveUploadParts
+func processExtractedUpload(files []uploadedFile, versionID string) (*model.Version, error) {
+	extractPath := files[0].Path
+	if err := os.MkdirAll(extractPath, 0755); err != nil {
+		return nil, err
+	}
+	if err := indexExtracted(extractPath, versionID); err != nil {
+		return nil, err
+	}
+
+	meta := bundle.ReadMetadata(extractPath)
+
+	return &model.Version{
+		ID:                   versionID,
+		Name:                 versionID,
+		Type:                 model.VersionTypeSupportBundle,
+		CreatedAt:            time.Now(),
+		SupportBundleName:    files[0].Name,
+		ExtractedOnly:        true,
+		HarvesterVersion:     meta.HarvesterVersion,
+		KubernetesVersion:    meta.KubernetesVersion,
+		NodeCount:            meta.NodeCount,
+		ClusterName:          meta.ClusterName,
+		CollectedAt:          meta.CollectedAt,
+		CollectedAtEstimated: meta.CollectedAtEstimated,
 	}, nil
 }
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadBody streams a multipart upload of size bytes per file without holding it in memory
+func uploadBody(size int64, names ...string) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		chunk := bytes.Repeat([]byte("x"), 32<<10)
+		mw.WriteField("comment", "ignored")
+		for _, name := range names {
+			part, err := mw.CreateFormFile("file", name)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			for written := int64(0); written < size; written += int64(len(chunk)) {
+				n := int64(len(chunk))
+				if size-written < n {
+					n = size - written
+				}
+				if _, err := part.Write(chunk[:n]); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr, mw.Boundary()
+}
+
+func Test_SaveUploadParts(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	body, boundary := uploadBody(100<<10, "bundle.zip.002", "bundle.zip.001")
+
+	form, err := saveUploadParts(multipart.NewReader(body, boundary), dir, UploadLimits{BufferSize: 4 << 10})
+	assert.NoError(err)
+	files := form.Files
+	assert.Len(files, 2)
+	assert.Equal("bundle.zip.002", files[0].Name)
+	assert.Equal("bundle.zip.001", files[1].Name)
+	for _, f := range files {
+		info, err := os.Stat(f.Path)
+		assert.NoError(err)
+		assert.Equal(int64(100<<10), info.Size())
+	}
+
+	joined := filepath.Join(dir, "bundle.zip")
+	assert.NoError(joinUploadedFiles(files, joined))
+	info, err := os.Stat(joined)
+	assert.NoError(err)
+	assert.Equal(int64(200<<10), info.Size())
+	_, err = os.Stat(files[0].Path)
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_ConcurrentUploadsBoundedMemory(t *testing.T) {
+	assert := require.New(t)
+	const uploads = 3
+	const size = 32 << 20
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	errs := make([]error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, boundary := uploadBody(size, "bundle.zip")
+			_, errs[i] = saveUploadParts(multipart.NewReader(body, boundary), t.TempDir(), UploadLimits{BufferSize: 64 << 10})
+		}(i)
+	}
+	wg.Wait()
+	runtime.ReadMemStats(&after)
+
+	for _, err := range errs {
+		assert.NoError(err)
+	}
+	// buffering the uploads would allocate at least their size
+	allocated := after.TotalAlloc - before.TotalAlloc
+	assert.Less(allocated, uint64(uploads*size/10), "allocated %d bytes for %d bytes of uploads", allocated, uploads*size)
+}
+
+func Benchmark_SaveUploadParts(b *testing.B) {
+	const size = 8 << 20
+	b.ReportAllocs()
+	b.SetBytes(size)
+	for i := 0; i < b.N; i++ {
+		body, boundary := uploadBody(size, "bundle.zip")
+		if _, err := saveUploadParts(multipart.NewReader(body, boundary), b.TempDir(), UploadLimits{BufferSize: 64 << 10}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func uploadServer(t *testing.T, limits UploadLimits) (*Server, *http.ServeMux) {
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	require.NoError(t, err)
+	require.NoError(t, st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now()}))
+
+	s := &Server{store: st, dataDir: dataDir, events: events.NewBus()}
+	s.SetUploadLimits(limits)
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	return s, mux
+}
+
+func Test_UploadVersionRejectsOversizedRequest(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, UploadLimits{MaxRequestSize: 1 << 20})
+	versionPath := filepath.Join(s.dataDir, "workspaces", "ws", "v1")
+
+	// refused from Content-Length alone, the body is never read
+	req := httptest.NewRequest("POST", "/api/workspaces/ws/versions", nil)
+	req.ContentLength = 2 << 20
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	_, err := os.Stat(versionPath)
+	assert.True(os.IsNotExist(err))
+
+	// without Content-Length the limit is hit while streaming, the partial version is removed
+	body, boundary := uploadBody(2<<20, "bundle.zip")
+	req = httptest.NewRequest("POST", "/api/workspaces/ws/versions", body)
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	_, err = os.Stat(versionPath)
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_UploadVersionKubeconfig(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, UploadLimits{MaxRequestSize: 1 << 20})
+
+	body, boundary := uploadBody(1<<10, "cluster.yaml")
+	req := httptest.NewRequest("POST", "/api/workspaces/ws/versions", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Len(ws.Versions, 1)
+	assert.Equal(model.VersionTypeRuntime, ws.Versions[0].Type)
+	assert.Equal(filepath.Join("workspaces", "ws", "v1", "cluster.yaml"), ws.Versions[0].KubeconfigPath)
+
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Equal("cluster.yaml", entries[0].Name())
+}
+
+func tarBytes(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// extractedUpload builds a multipart body, fields are written in order and files named *.tar hold content as is
+func extractedUpload(t *testing.T, fields [][2]string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, field := range fields {
+		if field[0] == "layout" {
+			require.NoError(t, mw.WriteField("layout", field[1]))
+			continue
+		}
+		part, err := mw.CreateFormFile("file", field[0])
+		require.NoError(t, err)
+		_, err = part.Write([]byte(field[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	return body, mw.Boundary()
+}
+
+func Test_SaveUploadPartsExtracted(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	bundleTar := tarBytes(t, map[string]string{
+		"supportbundle/metadata.yaml":      "projectName: harvester",
+		"supportbundle/logs/harvester.log": "started",
+	})
+	body, boundary := extractedUpload(t, [][2]string{{"layout", "extracted"}, {"bundle.tar", string(bundleTar)}})
+
+	form, err := saveUploadParts(multipart.NewReader(body, boundary), dir, UploadLimits{BufferSize: 4 << 10})
+	assert.NoError(err)
+	assert.Equal(layoutExtracted, form.Layout)
+	assert.Len(form.Files, 1)
+	assert.Equal(filepath.Join(dir, "extracted"), form.Files[0].Path)
+	content, err := os.ReadFile(filepath.Join(dir, "extracted", "supportbundle", "logs", "harvester.log"))
+	assert.NoError(err)
+	assert.Equal("started", string(content))
+
+	version, err := processExtractedUpload(form.Files, "v1")
+	assert.NoError(err)
+	assert.True(version.ExtractedOnly)
+	assert.Empty(version.BundlePath)
+	assert.Equal("bundle.tar", version.SupportBundleName)
+	assert.Len(readFileIndex(t, form.Files[0].Path), 2, "the files are indexed")
+}
+
+func Test_SaveUploadPartsRejectsMixedUploads(t *testing.T) {
+	assert := require.New(t)
+	bundleTar := string(tarBytes(t, map[string]string{"metadata.yaml": "projectName: harvester"}))
+
+	for _, fields := range [][][2]string{
+		{{"layout", "extracted"}, {"bundle.zip", "zip"}},
+		{{"layout", "extracted"}, {"bundle.tar", bundleTar}, {"other.tar", bundleTar}},
+		{{"bundle.tar", bundleTar}, {"layout", "extracted"}},
+	} {
+		body, boundary := extractedUpload(t, fields)
+		_, err := saveUploadParts(multipart.NewReader(body, boundary), t.TempDir(), UploadLimits{BufferSize: 4 << 10})
+		assert.ErrorIs(err, errMixedUpload, fields)
+	}
+
+	body, boundary := extractedUpload(t, [][2]string{{"layout", "flat"}, {"bundle.tar", bundleTar}})
+	_, err := saveUploadParts(multipart.NewReader(body, boundary), t.TempDir(), UploadLimits{BufferSize: 4 << 10})
+	assert.EqualError(err, `invalid layout "flat", expected extracted`)
+
+	// entries escaping the extracted directory are refused
+	body, boundary = extractedUpload(t, [][2]string{{"layout", "extracted"}, {"bundle.tar", string(tarBytes(t, map[string]string{"../escape": "x"}))}})
+	_, err = saveUploadParts(multipart.NewReader(body, boundary), t.TempDir(), UploadLimits{BufferSize: 4 << 10})
+	assert.ErrorContains(err, "illegal file path")
+}
+
+func Test_UploadVersionMixedExtracted(t *testing.T) {
+	assert := require.New(t)
+	s, mux := uploadServer(t, UploadLimits{MaxRequestSize: 1 << 20})
+
+	body, boundary := extractedUpload(t, [][2]string{{"layout", "extracted"}, {"bundle.zip", "zip"}})
+	req := httptest.NewRequest("POST", "/api/workspaces/ws/versions", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+	assert.Contains(rec.Body.String(), "single uncompressed .tar file")
+	_, err := os.Stat(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	assert.True(os.IsNotExist(err))
+}
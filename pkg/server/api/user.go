@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// SetUserHeader sets the request header a trusted proxy puts the authenticated user in. Workspaces
+// and versions record the user that created them and the activity feed the user of each change, an
+// empty name records no users. It must be called before the routes are served.
+func (s *Server) SetUserHeader(name string) {
+	if name != "" {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	s.userHeader = name
+}
+
+// requestUser returns the user a request was made by, the one its token was issued to when tokens are
+// required and empty without a user header otherwise
+func (s *Server) requestUser(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return identity.User
+	}
+	if s.tokens != nil || s.userHeader == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(s.userHeader))
+}
+
+// workspacesCreatedBy keeps the workspaces created by user
+func workspacesCreatedBy(workspaces []model.Workspace, user string) []model.Workspace {
+	filtered := make([]model.Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		if ws.CreatedBy == user {
+			filtered = append(filtered, ws)
+		}
+	}
+	return filtered
+}
+
+// writtenBy records user as the creator of the versions write creates
+func writtenBy(user string, write func(dir string) (*model.Version, error)) func(dir string) (*model.Version, error) {
+	return func(dir string) (*model.Version, error) {
+		version, err := write(dir)
+		if err != nil {
+			return nil, err
+		}
+		version.CreatedBy = user
+		return version, nil
+	}
+}
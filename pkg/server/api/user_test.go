@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RequestUser(t *testing.T) {
+	assert := require.New(t)
+	req := httptest.NewRequest("GET", "/api/workspaces", nil)
+	req.Header.Set("X-Forwarded-User", " alice ")
+
+	s := &Server{}
+	assert.Empty(s.requestUser(req), "without a user header the request header isn't trusted")
+
+	s.SetUserHeader("x-forwarded-user")
+	assert.Equal("alice", s.requestUser(req))
+	assert.Empty(s.requestUser(httptest.NewRequest("GET", "/api/workspaces", nil)))
+}
+
+func Test_WrittenBy(t *testing.T) {
+	assert := require.New(t)
+	version, err := writtenBy("alice", func(dir string) (*model.Version, error) {
+		return &model.Version{ID: "v1"}, nil
+	})(t.TempDir())
+	assert.NoError(err)
+	assert.Equal("alice", version.CreatedBy)
+}
+
+func Test_CreatedByWorkspaces(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	assert.NoError(err)
+	s := &Server{store: st, events: events.NewBus(), dataDir: dataDir}
+	s.SetUserHeader("X-Forwarded-User")
+	sub := s.events.Subscribe()
+	defer sub.Close()
+
+	for _, c := range []struct{ name, user string }{{"ws1", "alice"}, {"ws2", "bob"}, {"ws3", ""}} {
+		req := httptest.NewRequest("POST", "/api/workspaces", strings.NewReader(`{"name":"`+c.name+`"}`))
+		if c.user != "" {
+			req.Header.Set("X-Forwarded-User", c.user)
+		}
+		rec := httptest.NewRecorder()
+		s.handleCreateWorkspace(rec, req)
+		assert.Equal(http.StatusCreated, rec.Code, c.name)
+
+		var ws model.Workspace
+		assert.NoError(json.NewDecoder(rec.Body).Decode(&ws), c.name)
+		assert.Equal(c.user, ws.CreatedBy, c.name)
+		assert.Equal(c.user, (<-sub.Events()).Actor, c.name)
+	}
+
+	list := func(query string) []string {
+		rec := httptest.NewRecorder()
+		s.handleListWorkspaces(rec, httptest.NewRequest("GET", "/api/workspaces"+query, nil))
+		assert.Equal(http.StatusOK, rec.Code, query)
+		var workspaces []model.Workspace
+		assert.NoError(json.NewDecoder(rec.Body).Decode(&workspaces), query)
+		names := []string{}
+		for _, ws := range workspaces {
+			names = append(names, ws.Name)
+		}
+		return names
+	}
+	assert.Equal([]string{"ws1", "ws2", "ws3"}, list(""))
+	assert.Equal([]string{"ws2"}, list("?createdBy=bob"))
+	assert.Equal([]string{"ws3"}, list("?createdBy="))
+	assert.Empty(list("?createdBy=carol"))
+}
@@ -1,14 +1,23 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
 	"github.com/Yu-Jack/sim-gui/pkg/kubeconfig"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/jobs"
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/simulator"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
@@ -21,46 +30,122 @@ func (s *Server) handleUploadVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form
-	if err := r.ParseMultipartForm(100 << 20); err != nil { // 100 MB max memory
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	limits := s.uploadLimits
+	if !limitUploadSize(w, r, limits) {
 		return
 	}
 
-	files := r.MultipartForm.File["file"]
-	if len(files) == 0 {
-		http.Error(w, "No file uploaded", http.StatusBadRequest)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	versionID := getNextVersionID(ws)
-	versionPath := filepath.Join(s.dataDir, "workspaces", name, versionID)
+	status := http.StatusInternalServerError
+	version, err := s.createVersion(name, versionID, writtenBy(s.requestUser(r), writeUpload(reader, limits, versionID, &status)))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	s.events.PublishBy(version.CreatedBy, events.VersionUploaded, name, versionID, version)
 
-	if err := os.MkdirAll(versionPath, 0755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleReplaceBundle replaces the bundle of a version with an upload in the same format as
+// handleUploadVersion, the previous bundle is kept in the trash. Saved queries, bookmarks and the
+// activity of the version stay with it as its ID doesn't change.
+func (s *Server) handleReplaceBundle(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if s.refuseCaseCollision(w, name) {
 		return
 	}
 
-	var version *model.Version
+	instanceName := version.InstanceName(name)
+	if version.Type != model.VersionTypeRuntime {
+		containers, err := s.docker.FindRunningContainer(instanceName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to check container status: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if len(containers) > 0 {
+			http.Error(w, "Cannot replace the bundle while simulator is running. Please stop the simulator first.", http.StatusConflict)
+			return
+		}
+		if s.docker.BuildPending(instanceName) {
+			http.Error(w, errBuildPending.Error(), http.StatusConflict)
+			return
+		}
+		if s.extraction(instanceName) != nil {
+			http.Error(w, errExtracting.Error(), http.StatusConflict)
+			return
+		}
+	}
 
-	if isKubeconfigFile(files) {
-		version, err = processKubeconfigUpload(files, versionPath, versionID)
-	} else {
-		version, err = processSupportBundleUpload(files, versionPath, versionID)
+	limits := s.uploadLimits
+	if !limitUploadSize(w, r, limits) {
+		return
 	}
 
+	reader, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ws.Versions = append(ws.Versions, *version)
-	if err := s.store.UpdateWorkspace(*ws); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	status := http.StatusInternalServerError
+	replaced, err := s.replaceVersion(name, version, writeUpload(reader, limits, versionID, &status))
+	if err != nil {
+		if errors.Is(err, errReplaceType) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
+	s.clearProgress(instanceName)
 
-	w.WriteHeader(http.StatusOK)
+	// code-server still shows the previous files, they are copied again when it is next opened
+	if err := removeCodeServerProject(s.docker, codeServerProjectOf(name, version)); err != nil {
+		fmt.Printf("Failed to remove code-server project of %s: %v\n", instanceName, err)
+	}
+	s.events.PublishBy(s.requestUser(r), events.VersionReplaced, name, versionID, replaced)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replaced)
+}
+
+// StartSimulatorRequest is the optional body of a start request
+type StartSimulatorRequest struct {
+	// Network is the docker network to start the simulator on, remembered for the next starts of the
+	// version. An empty name is the default bridge network, leaving it out keeps the remembered one.
+	Network *string `json:"network,omitempty"`
+	// Labels and Env are added to the container along with those of the workspace, overriding them,
+	// and remembered for the next starts. Leaving one out keeps the remembered ones, an empty one clears
+	// them. A stopped container created with others is created again.
+	Labels map[string]string `json:"labels,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+	// Ports are container ports published besides the apiserver, remembered for the next starts like
+	// the network. A stopped container publishing others is created again, a running one is refused.
+	Ports *[]uint16 `json:"ports,omitempty"`
+}
+
+// StartSimulatorResponse is returned when a simulator image was built and started
+type StartSimulatorResponse struct {
+	// Warning is set when the build barely fit in the disk space of docker
+	Warning string `json:"warning,omitempty"`
 }
 
 func (s *Server) handleStartSimulator(w http.ResponseWriter, r *http.Request) {
@@ -73,74 +158,319 @@ func (s *Server) handleStartSimulator(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var version *model.Version
-	for _, v := range ws.Versions {
-		if v.ID == versionID {
-			version = &v
-			break
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	var req StartSimulatorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Network != nil && *req.Network != version.Network && version.Type != model.VersionTypeRuntime {
+		if err := s.setSimulatorNetwork(name, &version, strings.TrimSpace(*req.Network)); err != nil {
+			status := http.StatusInternalServerError
+			var startErr *startError
+			if errors.As(err, &startErr) {
+				status = startErr.status
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	if req.Ports != nil && !slices.Equal(*req.Ports, version.Ports) && version.Type != model.VersionTypeRuntime {
+		if err := s.setSimulatorPorts(name, &version, *req.Ports); err != nil {
+			status := http.StatusInternalServerError
+			var startErr *startError
+			if errors.As(err, &startErr) {
+				status = startErr.status
+			}
+			http.Error(w, err.Error(), status)
+			return
 		}
 	}
 
-	if version == nil {
-		http.Error(w, "Version not found", http.StatusNotFound)
+	if (req.Labels != nil || req.Env != nil) && version.Type != model.VersionTypeRuntime {
+		if err := s.setSimulatorExtras(ws, &version, req.Labels, req.Env); err != nil {
+			status := http.StatusInternalServerError
+			var startErr *startError
+			if errors.As(err, &startErr) {
+				status = startErr.status
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+
+	extras, err := versionExtras(ws, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if version.Type == model.VersionTypeRuntime {
-		w.WriteHeader(http.StatusOK)
+	warning, err := s.startSimulator(r.Context(), name, version, extras)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var startErr *startError
+		if errors.As(err, &startErr) {
+			status = startErr.status
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	instanceName := fmt.Sprintf("%s-%s", name, versionID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StartSimulatorResponse{Warning: warning})
+}
+
+// startError is a failed start with the status code it is reported with
+type startError struct {
+	status int
+	err    error
+}
+
+func (e *startError) Error() string { return e.err.Error() }
+
+func (e *startError) Unwrap() error { return e.err }
+
+// checkSimulatorNetwork fails with a 400 startError listing the networks docker has when it has no
+// network called name
+func (s *Server) checkSimulatorNetwork(name string) error {
+	if err := s.docker.CheckNetwork(name); err != nil {
+		return networkStartError(err)
+	}
+	return nil
+}
+
+// networkStartError reports a start on a network docker doesn't have as a bad request
+func networkStartError(err error) error {
+	var unknown *docker.UnknownNetworkError
+	if errors.As(err, &unknown) {
+		return &startError{http.StatusBadRequest, err}
+	}
+	return err
+}
+
+// setSimulatorNetwork remembers the network the simulator of a version is started on
+func (s *Server) setSimulatorNetwork(workspaceName string, version *model.Version, network string) error {
+	if err := s.checkSimulatorNetwork(network); err != nil {
+		return err
+	}
+	err := updateVersion(s.store, workspaceName, version.ID, func(v *model.Version) bool {
+		v.Network = network
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save the network: %w", err)
+	}
+	version.Network = network
+	return nil
+}
+
+// containerExtras merges the labels and environment of a start request into those of the workspace,
+// failing for reserved labels
+func containerExtras(ws *model.Workspace, labels, env map[string]string) (docker.Extras, error) {
+	extras := docker.MergeExtras(
+		docker.Extras{Labels: ws.ContainerLabels, Env: ws.ContainerEnv},
+		docker.Extras{Labels: labels, Env: env},
+	)
+	return extras, extras.Validate()
+}
+
+// simulatorNetwork is the docker network the simulator of a version is started on
+func simulatorNetwork(version model.Version) string {
+	if version.Network == "" {
+		return docker.DefaultNetwork
+	}
+	return version.Network
+}
+
+// startSimulator starts the simulator of a version, building its image first when there is none. It
+// waits for a start slot while other simulators start and returns once the container runs, loading the
+// bundle is monitored in the background. The warning is set when the build barely fit in the disk space.
+func (s *Server) startSimulator(ctx context.Context, name string, version model.Version, extras docker.Extras) (string, error) {
+	versionID := version.ID
+	if version.Type == model.VersionTypeRuntime {
+		return "", &startError{http.StatusBadRequest, errRuntimeVersion}
+	}
+
+	instanceName := version.InstanceName(name)
+
+	// recreated is set when a container is removed to be created again, the image may be kept
+	recreated := false
+
+	// The bundle was replaced since the image was built, the container and image of the previous one are discarded
+	if version.ImageStale {
+		if err := s.docker.RemoveContainer(instanceName); err != nil {
+			return "", fmt.Errorf("Failed to remove the container of the replaced bundle: %w", err)
+		}
+		if err := s.docker.RemoveImages(instanceName); err != nil {
+			return "", fmt.Errorf("Failed to remove the image of the replaced bundle: %w", err)
+		}
+		recreated = true
+	}
 
 	// Check if exists (running or stopped)
 	containers, err := s.docker.FindContainer(instanceName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", err
 	}
 
-	if len(containers) > 0 {
-		container := containers[0]
-		if container.State == "running" {
-			// Already running
-			if !version.Ready {
-				s.monitorReadyState(name, versionID, instanceName)
-			}
-			w.WriteHeader(http.StatusOK)
-			return
+	// Docker can't move a container to another network, it is created again on the one asked for
+	if len(containers) > 0 && docker.ContainerNetwork(containers[0]) != simulatorNetwork(version) {
+		if containers[0].State == "running" {
+			return "", &startError{http.StatusConflict, fmt.Errorf("the simulator runs on network %s, stop it to start it on %s",
+				docker.ContainerNetwork(containers[0]), simulatorNetwork(version))}
 		}
-		// Stopped, try to start
-		if err := s.docker.StartContainer(container.ID); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to start existing container: %v", err), http.StatusInternalServerError)
-			return
+		if err := s.docker.RemoveContainer(instanceName); err != nil {
+			return "", fmt.Errorf("Failed to remove the container on network %s: %w", docker.ContainerNetwork(containers[0]), err)
 		}
+		containers, recreated = nil, true
+	}
+	// Neither can it change the labels or environment of a container
+	if len(containers) > 0 && !docker.ContainerHasExtras(containers[0], extras) {
+		if containers[0].State == "running" {
+			return "", &startError{http.StatusConflict, errors.New("the simulator runs with other labels or environment, stop it to start it with these")}
+		}
+		if err := s.docker.RemoveContainer(instanceName); err != nil {
+			return "", fmt.Errorf("Failed to remove the container with other labels or environment: %w", err)
+		}
+		containers, recreated = nil, true
+	}
+	// Nor can it publish other ports
+	if len(containers) > 0 && !docker.ContainerHasPorts(containers[0], version.Ports) {
+		if containers[0].State == "running" {
+			return "", &startError{http.StatusConflict, errors.New("the simulator publishes other ports, stop it to start it with these")}
+		}
+		if err := s.docker.RemoveContainer(instanceName); err != nil {
+			return "", fmt.Errorf("Failed to remove the container publishing other ports: %w", err)
+		}
+		containers, recreated = nil, true
+	}
+
+	// The new container loads the bundle again, Ready is from the removed one
+	if recreated {
+		if err := s.ResetVersionReadyState(name, versionID); err != nil {
+			return "", fmt.Errorf("failed to reset ready state: %w", err)
+		}
+		version.Ready = false
+	}
+
+	if len(containers) > 0 && containers[0].State == "running" {
+		// Already running
 		if !version.Ready {
 			s.monitorReadyState(name, versionID, instanceName)
 		}
-		w.WriteHeader(http.StatusOK)
-		return
+		s.recordVersionStarted(name, versionID)
+		return "", nil
+	}
+
+	// Queued behind the simulators already starting, the slot is released by the ready monitor
+	if err := s.starts.acquire(ctx, instanceName); err != nil {
+		return "", &startError{http.StatusConflict, err}
+	}
+	monitored := false
+	defer func() {
+		if !monitored {
+			s.starts.release(instanceName)
+		}
+	}()
+
+	if len(containers) > 0 {
+		// Stopped, try to start. Ready is from the previous run, the simulator loads the bundle again.
+		if err := s.restartSimulator(s.docker, name, versionID, instanceName, containers[0].ID); err != nil {
+			return "", err
+		}
+		s.monitorReadyState(name, versionID, instanceName)
+		monitored = true
+		s.recordVersionStarted(name, versionID)
+		return "", nil
+	}
+
+	// The remembered network may have been removed since, the build would be wasted
+	if err := s.checkSimulatorNetwork(version.Network); err != nil {
+		return "", err
+	}
+
+	// Refuse to build from a bundle that isn't there, docker would fail with a confusing error
+	bundlePath, err := s.bundleSource(ctx, name, version)
+	if err != nil {
+		return "", fmt.Errorf("Failed to fetch support bundle: %w", err)
+	}
+	if err := checkSimulatorBundle(version, bundlePath); err != nil {
+		return "", &startError{http.StatusUnprocessableEntity, err}
+	}
+
+	// The extracted directory was removed to free disk, it comes back before the image is built
+	if version.ExtractedRemoved {
+		if err := s.reextract(name, version, bundlePath); err != nil {
+			return "", &startError{cleanExtractedStatus(err), fmt.Errorf("Failed to extract support bundle: %w", err)}
+		}
+	}
+
+	// Only the container has to be created again when its image is still there
+	imageBuilt := false
+	if recreated {
+		images, err := s.docker.FindImages(instanceName)
+		if err != nil {
+			return "", err
+		}
+		imageBuilt = len(images) > 0
+	}
+
+	// The base image is missing until its first pull finished, e.g. right after installing
+	baseImage := simulatorBaseImage
+	if !imageBuilt {
+		if err := s.waitForBaseImage(ctx, s.docker, name, versionID, instanceName, baseImage); err != nil {
+			return "", err
+		}
+	}
+
+	// Docker fails halfway through the build with an opaque error when the disk fills up
+	warning := ""
+	if !imageBuilt {
+		warning, err = s.buildSpaceWarning(bundlePath, baseImage)
+		if err != nil {
+			var spaceErr insufficientSpaceError
+			if errors.As(err, &spaceErr) {
+				return "", &startError{http.StatusUnprocessableEntity, err}
+			}
+			return "", err
+		}
 	}
 
 	// Create Image
-	baseImage := "rancher/support-bundle-kit:master-head"
-	if err := s.docker.CreateImage(instanceName, version.BundlePath, baseImage); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create image: %v", err), http.StatusInternalServerError)
-		return
+	s.events.Publish(events.SimulatorStarting, name, versionID, nil)
+	if !imageBuilt {
+		if err := s.buildImage(s.docker, name, versionID, instanceName, bundlePath, baseImage); err != nil {
+			msg := fmt.Sprintf("Failed to create image: %v", err)
+			// The docker output usually explains the failure, e.g. running out of disk space
+			if tail, err := tailLines(s.buildLogPath(name, versionID), buildLogTailLines); err == nil && tail != "" {
+				msg += "\n\nLast lines of the build log:\n" + tail
+			}
+			return "", errors.New(msg)
+		}
+		s.events.Publish(events.SimulatorImageBuilt, name, versionID, nil)
 	}
 
 	// Run Container
-	if err := s.docker.RunContainer(instanceName, version.BundlePath); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to run container: %v", err), http.StatusInternalServerError)
-		return
+	if err := s.docker.RunContainer(instanceName, docker.Owner{Profile: s.profile, Workspace: name, Version: versionID}, bundlePath, version.Network, version.Ports, extras); err != nil {
+		s.recordVersionError(name, versionID, model.ErrorStageStart, err)
+		return "", networkStartError(fmt.Errorf("Failed to run container: %w", err))
 	}
+	s.clearVersionError(name, versionID, model.ErrorStageStart)
 
 	// Monitor ready state
 	if !version.Ready {
 		s.monitorReadyState(name, versionID, instanceName)
+		monitored = true
 	}
 
-	w.WriteHeader(http.StatusOK)
+	s.recordVersionStarted(name, versionID)
+	return warning, nil
 }
 
 func (s *Server) handleStopSimulator(w http.ResponseWriter, r *http.Request) {
@@ -157,12 +487,15 @@ func (s *Server) handleStopSimulator(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	instanceName := fmt.Sprintf("%s-%s", name, versionID)
+	instanceName := instanceNameOf(s.store, name, versionID)
+	s.starts.cancel(instanceName)
 
 	if err := s.docker.StopContainer(instanceName); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to stop container: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.clearProgress(instanceName)
+	s.events.PublishBy(s.requestUser(r), events.SimulatorStopped, name, versionID, nil)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -170,18 +503,20 @@ func (s *Server) handleStopSimulator(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleCleanVersionImage(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 	versionID := r.PathValue("versionID")
+	// dryRun=true only lists what would be removed, the extracted bundle is kept either way
+	report := CleanReport{DryRun: r.URL.Query().Get("dryRun") == "true", Versions: []CleanVersionResult{}}
 
 	ws, err := s.store.GetWorkspace(name)
 	if err == nil {
 		for _, v := range ws.Versions {
 			if v.ID == versionID && v.Type == model.VersionTypeRuntime {
-				w.WriteHeader(http.StatusOK)
+				writeCleanReport(w, report)
 				return
 			}
 		}
 	}
 
-	instanceName := fmt.Sprintf("%s-%s", name, versionID)
+	instanceName := instanceNameOf(s.store, name, versionID)
 
 	// Check if container is running
 	containers, err := s.docker.FindRunningContainer(instanceName)
@@ -195,19 +530,105 @@ func (s *Server) handleCleanVersionImage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Use cleaner to clean and reset ready state
-	if err := s.cleaner.CleanInstance(instanceName); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to clean version: %v", err), http.StatusInternalServerError)
+	report.add(s.cleanVersion(name, versionID, report.DryRun))
+	if report.DryRun || report.Failed > 0 {
+		writeCleanReport(w, report)
 		return
 	}
 
-	// Reset ready state after successful clean
-	if err := s.ResetVersionReadyState(name, versionID); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to reset ready state: %v", err), http.StatusInternalServerError)
-		return
+	// extracted=true also frees the disk taken by the extracted bundle
+	if r.URL.Query().Get("extracted") == "true" && ws != nil {
+		version, ok := findVersion(ws, versionID)
+		if !ok {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		if err := s.cleanExtracted(name, version); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to remove extracted files: %v", err), cleanExtractedStatus(err))
+			return
+		}
+		if ws, err := s.store.GetWorkspace(name); err == nil {
+			s.events.Publish(events.WorkspaceUpdated, name, "", ws)
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeCleanReport(w, report)
+}
+
+// simulatorLogLines is the number of log lines the status of an exited simulator includes
+const simulatorLogLines = 30
+
+// SimulatorStatus is the status of the simulator of a version
+type SimulatorStatus struct {
+	Running bool `json:"running"`
+	Ready   bool `json:"ready"`
+	// QueuePosition is the place of a start waiting for other simulators to finish starting, starting at 1
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Extraction is set while the bundle of a version whose extracted directory was removed is extracted again
+	Extraction *ExtractionProgress `json:"extraction,omitempty"`
+	// BaseImagePull is set while the start waits for the base image to be pulled
+	BaseImagePull *BaseImagePull `json:"baseImagePull,omitempty"`
+	// Progress is the load progress parsed from the logs since the container started
+	Progress      *simulator.Progress `json:"progress,omitempty"`
+	LastStartedAt *time.Time          `json:"lastStartedAt,omitempty"`
+	// Container is nil when the simulator was never started or its container was removed
+	Container *SimulatorContainer `json:"container,omitempty"`
+	// Build identifies the support-bundle-kit build of the simulator, nil without a container
+	Build *SimulatorBuild `json:"build,omitempty"`
+	// StartParameters are what the next start creates the container with unless the request changes
+	// them, nil for runtime versions
+	StartParameters *StartParameters `json:"startParameters,omitempty"`
+	// LastError is why the last build, start or cleanup of the simulator failed, nil once it succeeded
+	LastError *model.LastError `json:"lastError,omitempty"`
+}
+
+// SimulatorContainer is the docker container of a simulator
+type SimulatorContainer struct {
+	ID             string     `json:"id"`
+	State          string     `json:"state"` // Docker state, e.g. "created", "running", "exited"
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	Image          string     `json:"image"`
+	ImageCreatedAt *time.Time `json:"imageCreatedAt,omitempty"`
+	// Labels of the container, including the extra labels it was started with
+	Labels map[string]string `json:"labels,omitempty"`
+	// APIServerPort is the host port kubeconfigs point at while the container runs
+	APIServerPort uint16 `json:"apiServerPort,omitempty"`
+	// Ports are where the apiserver and the additional ports are reached while the container runs
+	Ports []PortMapping `json:"ports,omitempty"`
+	// FinishedAt, ExitCode and Logs, the last lines of its output, are set once it exited
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ExitCode   *int       `json:"exitCode,omitempty"`
+	Logs       []string   `json:"logs,omitempty"`
+}
+
+// simulatorContainer converts the docker status of a container, nil stays nil
+func simulatorContainer(status *docker.ContainerStatus) *SimulatorContainer {
+	if status == nil {
+		return nil
+	}
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	c := &SimulatorContainer{
+		ID:             status.ID,
+		State:          status.State,
+		StartedAt:      optional(status.StartedAt),
+		Image:          status.Image,
+		ImageCreatedAt: optional(status.ImageCreatedAt),
+		Labels:         status.Labels,
+		APIServerPort:  status.APIServerPort,
+		Ports:          portMappings(status.Ports),
+	}
+	if status.State == "exited" {
+		exitCode := status.ExitCode
+		c.FinishedAt = optional(status.FinishedAt)
+		c.ExitCode = &exitCode
+		c.Logs = status.Logs
+	}
+	return c
 }
 
 func (s *Server) handleGetSimulatorStatus(w http.ResponseWriter, r *http.Request) {
@@ -220,53 +641,52 @@ func (s *Server) handleGetSimulatorStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var targetVersion *model.Version
-	for _, v := range ws.Versions {
-		if v.ID == versionID {
-			targetVersion = &v
-			break
-		}
+	version, _ := findVersion(ws, versionID)
+	status, err := s.simulatorStatus(name, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !s.seesSecrets(r) {
+		status = redactStatus(status)
 	}
 
-	if targetVersion != nil && targetVersion.Type == model.VersionTypeRuntime {
-		status := struct {
-			Running bool `json:"running"`
-			Ready   bool `json:"ready"`
-		}{
-			Running: true,
-			Ready:   true,
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(status)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// simulatorStatus returns the live state of the simulator of a version, runtime versions are always
+// running and ready
+func (s *Server) simulatorStatus(name string, version model.Version) (SimulatorStatus, error) {
+	if version.Type == model.VersionTypeRuntime {
+		return SimulatorStatus{Running: true, Ready: true, LastStartedAt: version.LastStartedAt}, nil
 	}
 
-	instanceName := fmt.Sprintf("%s-%s", name, versionID)
+	instanceName := version.InstanceName(name)
 
-	containers, err := s.docker.FindRunningContainer(instanceName)
+	container, err := s.docker.ContainerStatus(instanceName, simulatorLogLines)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return SimulatorStatus{}, err
 	}
 
-	var ready bool
-	for _, v := range ws.Versions {
-		if v.ID == versionID {
-			ready = v.Ready
-			break
-		}
-	}
+	status := SimulatorStatus{
+		Running:       container != nil && container.State == "running",
+		Ready:         version.Ready,
+		LastStartedAt: version.LastStartedAt,
+		Container:     simulatorContainer(container),
+		Extraction:    s.extraction(instanceName),
+		QueuePosition: s.starts.position(instanceName),
+		BaseImagePull: s.baseImagePull(s.docker, instanceName),
 
-	status := struct {
-		Running bool `json:"running"`
-		Ready   bool `json:"ready"`
-	}{
-		Running: len(containers) > 0,
-		Ready:   ready,
+		StartParameters: startParameters(version),
+		LastError:       version.LastError,
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	if status.Running {
+		status.Progress = s.simulatorProgress(instanceName)
+	}
+	reported, reportErr := s.reportedSimulatorVersion(instanceName, container)
+	status.Build = simulatorBuild(version, container, reported, reportErr)
+	return status, nil
 }
 
 func (s *Server) handleGetKubeconfig(w http.ResponseWriter, r *http.Request) {
@@ -279,21 +699,16 @@ func (s *Server) handleGetKubeconfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var targetVersion *model.Version
-	for _, v := range ws.Versions {
-		if v.ID == versionID {
-			targetVersion = &v
-			break
-		}
-	}
-
-	if targetVersion == nil {
+	targetVersion, ok := findVersion(ws, versionID)
+	if !ok {
 		http.Error(w, "Version not found", http.StatusNotFound)
 		return
 	}
 
+	s.touchVersion(name, targetVersion)
+
 	if targetVersion.Type == model.VersionTypeRuntime {
-		content, err := os.ReadFile(targetVersion.KubeconfigPath)
+		content, err := os.ReadFile(s.dataPath(targetVersion.KubeconfigPath))
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to read kubeconfig: %v", err), http.StatusInternalServerError)
 			return
@@ -304,37 +719,17 @@ func (s *Server) handleGetKubeconfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	instanceName := fmt.Sprintf("%s-%s", name, versionID)
-
-	// Check if running
-	containers, err := s.docker.FindRunningContainer(instanceName)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if len(containers) == 0 {
-		http.Error(w, "Simulator not running", http.StatusConflict)
-		return
-	}
-
-	// Read kubeconfig
-	content, err := s.docker.ReadFile(instanceName, "/root/.sim/admin.kubeconfig")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to read kubeconfig: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Update endpoint
-	endpoint, port, err := s.docker.QueryExposedMapping(instanceName)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to query exposed mapping: %v", err), http.StatusInternalServerError)
+	instanceName := targetVersion.InstanceName(name)
+	config, err := fetchSimulatorKubeconfig(r.Context(), s.docker, instanceName, kubeconfigStepTimeout, kubeconfigInitWait)
+	if errors.Is(err, errSimulatorInitializing) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", kubeconfigRetryAfter)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(KubeconfigPendingResponse{Message: err.Error(), Ready: targetVersion.Ready})
 		return
 	}
-
-	config, err := kubeconfig.ConfigureKubeConfig(content, instanceName, endpoint, port)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), kubeconfigFetchStatus(err))
 		return
 	}
 
@@ -359,72 +754,102 @@ func (s *Server) handleDeleteVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var versionIndex = -1
-	for i, v := range ws.Versions {
-		if v.ID == versionID {
-			versionIndex = i
-			break
-		}
-	}
-
-	if versionIndex == -1 {
+	version, ok := findVersion(ws, versionID)
+	if !ok {
 		http.Error(w, "Version not found", http.StatusNotFound)
 		return
 	}
-
-	// Remove files
-	versionPath := filepath.Join(s.dataDir, "workspaces", name, versionID)
-	if err := os.RemoveAll(versionPath); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to remove files: %v", err), http.StatusInternalServerError)
+	if s.refuseCaseCollision(w, name) {
 		return
 	}
 
-	// Cleanup code-server directory
-	codeServerContainer := "sim-cli-code-server"
-	targetDir := fmt.Sprintf("/home/coder/project/%s-%s", name, versionID)
-	if _, _, err := s.docker.ExecContainer(codeServerContainer, []string{"rm", "-rf", targetDir}, nil); err != nil {
-		fmt.Printf("Failed to cleanup code-server directory: %v\n", err)
+	permanent := r.URL.Query().Get("permanent") == "true"
+	results, err := s.deleteVersion(name, version, permanent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if ws.Versions[versionIndex].Type != model.VersionTypeRuntime {
-		// Remove container and image if exists
-		instanceName := fmt.Sprintf("%s-%s", name, versionID)
-
-		// Remove container first
-		if err := s.docker.RemoveContainer(instanceName); err != nil {
-			// Log error but continue to cleanup images and files
-			fmt.Printf("Failed to remove container %s: %v\n", instanceName, err)
-		}
-
-		// Remove images
-		_ = s.docker.RemoveImages(instanceName)
+	// The version is gone either way, a 207 tells the leftovers of its simulator or code-server apart
+	w.Header().Set("Content-Type", "application/json")
+	if len(failedCleanups(results)) > 0 {
+		w.WriteHeader(http.StatusMultiStatus)
 	}
+	json.NewEncoder(w).Encode(results)
+}
 
-	// Update workspace
-	ws.Versions = append(ws.Versions[:versionIndex], ws.Versions[versionIndex+1:]...)
-
-	if err := s.store.UpdateWorkspace(*ws); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// deleteVersion moves a version with its files into the trash, or removes it for good when permanent
+// is set, then removes its code-server copy and simulator container and images, which are cheap to
+// rebuild. Only a failure to remove the files is returned, leaving everything in place; the results
+// report the other scopes.
+func (s *Server) deleteVersion(name string, version model.Version, permanent bool) ([]CleanupResult, error) {
+	files := s.cleanFiles(name, version, permanent)
+	if files.Error != "" {
+		return nil, fmt.Errorf("%s", files.Error)
+	}
+	results := []CleanupResult{files, s.cleanCodeServer(name, version)}
+	if version.Type != model.VersionTypeRuntime {
+		results = append(results, s.cleanRuntime(version.InstanceName(name), false))
 	}
 
-	w.WriteHeader(http.StatusOK)
+	s.events.Publish(events.VersionDeleted, name, version.ID, nil)
+	return results, nil
 }
 
 func (s *Server) markVersionReady(workspaceName, versionID string) {
 	if err := s.MarkVersionReady(workspaceName, versionID); err != nil {
-		fmt.Printf("Failed to mark version ready: %v\n", err)
+		fmt.Printf("Failed to mark %s-%s ready: %v\n", workspaceName, versionID, err)
+		return
 	}
+	s.events.Publish(events.VersionReady, workspaceName, versionID, nil)
 }
 
+// logFollower is the part of the docker client monitorReadyState needs
+type logFollower interface {
+	FollowLogs(instanceName string, fn func(line string) bool) error
+}
+
+// jobKindSimulatorReady jobs follow a started simulator until it loaded its bundle
+const jobKindSimulatorReady = "simulator-ready"
+
+// monitorReadyState follows the simulator logs in a job to track its load progress until it is ready
 func (s *Server) monitorReadyState(workspaceName, versionID, instanceName string) {
-	go func() {
-		if err := s.docker.WaitForLogMessage(instanceName, "All resources loaded successfully"); err == nil {
-			s.markVersionReady(workspaceName, versionID)
-		} else {
-			fmt.Printf("Monitor ready state failed: %v\n", err)
+	tracker := s.startProgress(instanceName)
+
+	s.jobManager().Submit(jobKindSimulatorReady, workspaceName, versionID, func(h *jobs.Handle) error {
+		// The simulator is ready or failed either way, the next queued start can go
+		defer s.starts.release(instanceName)
+		h.SetPhase("loading the bundle")
+		return s.followReadyState(s.docker, tracker, workspaceName, versionID, instanceName)
+	})
+}
+
+// followReadyState feeds the simulator logs to tracker until the bundle is loaded and marks the version
+// ready, or records and returns why it didn't get there
+func (s *Server) followReadyState(follower logFollower, tracker *simulator.Tracker, workspaceName, versionID, instanceName string) error {
+	var lastPublish time.Time
+	err := follower.FollowLogs(instanceName, func(line string) bool {
+		if !tracker.Feed(line) {
+			return true
 		}
-	}()
+		progress := tracker.Progress()
+		if progress.Ready || time.Since(lastPublish) >= progressPublishInterval {
+			s.publishProgress(workspaceName, versionID, instanceName, tracker)
+			lastPublish = time.Now()
+		}
+		return !progress.Ready
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to follow the simulator logs: %w", err)
+	} else if !tracker.Progress().Ready {
+		err = fmt.Errorf("container %s stopped before it was ready", instanceName)
+	}
+	if err != nil {
+		s.recordVersionError(workspaceName, versionID, model.ErrorStageReady, err)
+		return err
+	}
+	s.markVersionReady(workspaceName, versionID)
+	return nil
 }
 
 func (s *Server) handleExportWorkspaceKubeconfig(w http.ResponseWriter, r *http.Request) {
@@ -443,47 +868,15 @@ func (s *Server) handleExportWorkspaceKubeconfig(w http.ResponseWriter, r *http.
 
 	var kubeconfigs []*api.Config
 
-	// Collect kubeconfigs from all running versions
+	// Collect kubeconfigs from all running versions, versions that are not running are skipped
 	for _, version := range ws.Versions {
-		instanceName := fmt.Sprintf("%s-%s", name, version.ID)
-
-		if version.Type == model.VersionTypeRuntime {
-			content, err := os.ReadFile(version.KubeconfigPath)
-			if err != nil {
-				continue
-			}
-			config, err := kubeconfig.ConfigureRuntimeKubeConfig(content, instanceName)
-			if err != nil {
-				continue
-			}
-			kubeconfigs = append(kubeconfigs, config)
-			continue
-		}
-
-		// Check if running
-		containers, err := s.docker.FindRunningContainer(instanceName)
-		if err != nil || len(containers) == 0 {
-			// Skip versions that are not running
-			continue
-		}
-
-		// Read kubeconfig
-		content, err := s.docker.ReadFile(instanceName, "/root/.sim/admin.kubeconfig")
-		if err != nil {
-			continue
-		}
-
-		// Update endpoint
-		endpoint, port, err := s.docker.QueryExposedMapping(instanceName)
-		if err != nil {
-			continue
-		}
-
-		config, err := kubeconfig.ConfigureKubeConfig(content, instanceName, endpoint, port)
+		config, err := s.versionKubeconfig(r.Context(), name, version)
 		if err != nil {
+			if !errors.Is(err, errSimulatorNotRunning) {
+				fmt.Printf("Failed to fetch the kubeconfig of %s-%s: %v\n", name, version.ID, err)
+			}
 			continue
 		}
-
 		kubeconfigs = append(kubeconfigs, config)
 	}
 
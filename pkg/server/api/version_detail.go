@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// versionIncludeUsage adds the disk usage of a version to its detail, it walks the version directory
+// and lists the images of the simulator so it is only computed when asked for
+const versionIncludeUsage = "usage"
+
+// VersionDetail is the record of a single version along with the live state of its simulator
+type VersionDetail struct {
+	model.Version
+	Status SimulatorStatus `json:"status"`
+	// Usage is only set with include=usage
+	Usage *VersionUsage `json:"usage,omitempty"`
+}
+
+// VersionUsage is the disk space a version takes
+type VersionUsage struct {
+	// DirBytes adds up the files under the version directory, the bundle, extracted data and snapshots
+	DirBytes int64 `json:"dirBytes"`
+	// ImageBytes adds up the simulator images built for the version, layers shared with other images
+	// are counted as well
+	ImageBytes int64 `json:"imageBytes"`
+}
+
+// WorkspaceSummary is a workspace with only what lists and cards show, returned by fields=summary
+type WorkspaceSummary struct {
+	Name        string           `json:"name"`
+	DisplayName string           `json:"displayName"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	CreatedBy   string           `json:"createdBy,omitempty"`
+	Versions    []VersionSummary `json:"versions"`
+	// State flags a workspace being deleted
+	State model.WorkspaceState `json:"state,omitempty"`
+}
+
+// VersionSummary identifies a version, GET /api/workspaces/{name}/versions/{versionID} returns the rest
+type VersionSummary struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Type          model.VersionType `json:"type"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	Ready         bool              `json:"ready"`
+	Broken        bool              `json:"broken,omitempty"`
+	LastStartedAt *time.Time        `json:"lastStartedAt,omitempty"`
+	LastError     *model.LastError  `json:"lastError,omitempty"`
+}
+
+// summarizeWorkspace trims a workspace down to its summary, versions keep their order
+func summarizeWorkspace(ws model.Workspace) WorkspaceSummary {
+	summary := WorkspaceSummary{
+		Name:        ws.Name,
+		DisplayName: ws.DisplayName,
+		CreatedAt:   ws.CreatedAt,
+		CreatedBy:   ws.CreatedBy,
+		Versions:    make([]VersionSummary, 0, len(ws.Versions)),
+		State:       ws.State,
+	}
+	for _, v := range ws.Versions {
+		summary.Versions = append(summary.Versions, VersionSummary{
+			ID:            v.ID,
+			Name:          v.Name,
+			Type:          v.Type,
+			CreatedAt:     v.CreatedAt,
+			Ready:         v.Ready,
+			Broken:        v.Broken,
+			LastStartedAt: v.LastStartedAt,
+			LastError:     v.LastError,
+		})
+	}
+	return summary
+}
+
+// parseIncludes reads the comma separated include parameter, every section must be one of allowed
+func parseIncludes(r *http.Request, allowed ...string) (map[string]bool, error) {
+	includes := make(map[string]bool)
+	for _, section := range strings.Split(r.URL.Query().Get("include"), ",") {
+		section = strings.TrimSpace(section)
+		if section == "" {
+			continue
+		}
+		found := false
+		for _, a := range allowed {
+			if section == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid include %q, expected one of %s", section, strings.Join(allowed, ", "))
+		}
+		includes[section] = true
+	}
+	return includes, nil
+}
+
+// dirSize adds up the sizes of the regular files under dir, a missing dir is empty
+func dirSize(dir string) (int64, error) {
+	size, _, err := dirUsage(dir)
+	return size, err
+}
+
+// dirUsage adds up the sizes of the regular files under dir and counts them, a missing dir is empty
+func dirUsage(dir string) (size int64, files int, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// versionUsage returns the disk space of a version, runtime versions have no images
+func (s *Server) versionUsage(name string, version model.Version) (*VersionUsage, error) {
+	dirBytes, err := dirSize(filepath.Join(s.dataDir, "workspaces", name, version.ID))
+	if err != nil {
+		return nil, err
+	}
+	usage := &VersionUsage{DirBytes: dirBytes}
+	if version.Type == model.VersionTypeRuntime {
+		return usage, nil
+	}
+
+	images, err := s.docker.FindImages(version.InstanceName(name))
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %w", err)
+	}
+	for _, img := range images {
+		usage.ImageBytes += img.Size
+	}
+	return usage, nil
+}
+
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	includes, err := parseIncludes(r, versionIncludeUsage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	version, ok := findVersion(ws, versionID)
+	if !ok {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	detail := VersionDetail{Version: version}
+	detail.Status, err = s.simulatorStatus(name, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !s.seesSecrets(r) {
+		detail.Version = redactVersion(detail.Version)
+		detail.Status = redactStatus(detail.Status)
+	}
+	if includes[versionIncludeUsage] {
+		detail.Usage, err = s.versionUsage(name, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SummarizeWorkspace(t *testing.T) {
+	assert := require.New(t)
+	created := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	summary := summarizeWorkspace(model.Workspace{
+		Name:      "demo",
+		CreatedAt: created,
+		Bookmarks: []model.Bookmark{{ResourceType: "nodes", Name: "node-1"}},
+		Versions: []model.Version{
+			{ID: "v2", Name: "second.zip", Type: model.VersionTypeSupportBundle, Ready: true, HarvesterVersion: "v1.4.0"},
+			{ID: "v1", Name: "first.zip", Type: model.VersionTypeRuntime, Broken: true},
+		},
+	})
+	assert.Equal("demo", summary.Name)
+	assert.True(created.Equal(summary.CreatedAt))
+	assert.Equal([]VersionSummary{
+		{ID: "v2", Name: "second.zip", Type: model.VersionTypeSupportBundle, Ready: true},
+		{ID: "v1", Name: "first.zip", Type: model.VersionTypeRuntime, Broken: true},
+	}, summary.Versions)
+
+	assert.NotNil(summarizeWorkspace(model.Workspace{}).Versions, "expected an empty list rather than null")
+}
+
+func Test_ParseIncludes(t *testing.T) {
+	assert := require.New(t)
+	includes, err := parseIncludes(httptest.NewRequest("GET", "/?include=usage,%20usage,", nil), versionIncludeUsage)
+	assert.NoError(err)
+	assert.Equal(map[string]bool{"usage": true}, includes)
+
+	includes, err = parseIncludes(httptest.NewRequest("GET", "/", nil), versionIncludeUsage)
+	assert.NoError(err)
+	assert.Empty(includes)
+
+	_, err = parseIncludes(httptest.NewRequest("GET", "/?include=usage,analysis", nil), versionIncludeUsage)
+	assert.EqualError(err, `invalid include "analysis", expected one of usage`)
+}
+
+func Test_DirSize(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, "bundle.zip"), make([]byte, 100), 0644))
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "extracted", "yamls"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "extracted", "yamls", "nodes.yaml"), make([]byte, 28), 0644))
+
+	size, err := dirSize(dir)
+	assert.NoError(err)
+	assert.Equal(int64(128), size)
+
+	size, err = dirSize(filepath.Join(dir, "missing"))
+	assert.NoError(err)
+	assert.Zero(size)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// recordVersionError logs a failure of an operation on a version nobody waits for and keeps it as the
+// LastError of the version, so the UI can show it instead of waiting for the simulator
+func (s *Server) recordVersionError(workspace, versionID string, stage model.ErrorStage, err error) {
+	fmt.Printf("The %s stage of %s-%s failed: %v\n", stage, workspace, versionID, err)
+	lastError := &model.LastError{Message: err.Error(), Stage: stage, Time: time.Now().UTC()}
+	updateErr := updateVersion(s.store, workspace, versionID, func(v *model.Version) bool {
+		v.LastError = lastError
+		return true
+	})
+	if updateErr != nil {
+		fmt.Printf("Failed to record the error of %s-%s: %v\n", workspace, versionID, updateErr)
+	}
+}
+
+// clearVersionError clears the LastError of a version when it happened in one of stages, which just
+// succeeded
+func (s *Server) clearVersionError(workspace, versionID string, stages ...model.ErrorStage) {
+	err := updateVersion(s.store, workspace, versionID, func(v *model.Version) bool {
+		if v.LastError == nil || !slices.Contains(stages, v.LastError.Stage) {
+			return false
+		}
+		v.LastError = nil
+		return true
+	})
+	if err != nil {
+		fmt.Printf("Failed to clear the error of %s-%s: %v\n", workspace, versionID, err)
+	}
+}
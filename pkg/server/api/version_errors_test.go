@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/Yu-Jack/sim-gui/pkg/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBuilder builds images that fail with fail
+type fakeBuilder struct {
+	fail error
+}
+
+func (b *fakeBuilder) InspectImage(ref string) (docker.ImageInfo, error) {
+	return docker.ImageInfo{Digest: "sha256:base"}, nil
+}
+
+func (b *fakeBuilder) CreateImageWithLog(instanceName string, owner docker.Owner, bundlePath string, baseImage string, buildLog io.Writer) error {
+	return b.fail
+}
+
+// fakeFollower plays the log lines of a simulator, failing with fail once they are read
+type fakeFollower struct {
+	lines []string
+	fail  error
+}
+
+func (f *fakeFollower) FollowLogs(instanceName string, fn func(line string) bool) error {
+	for _, line := range f.lines {
+		if !fn(line) {
+			return nil
+		}
+	}
+	return f.fail
+}
+
+func lastErrorOf(t *testing.T, s *Server, versionID string) *model.LastError {
+	return getCleanupVersion(t, s, versionID).LastError
+}
+
+func Test_BuildErrorRecorded(t *testing.T) {
+	assert := require.New(t)
+	s, _, _ := newCleanupServer(t)
+	builder := &fakeBuilder{fail: errors.New("no space left on device")}
+
+	before := time.Now().UTC()
+	assert.Error(s.buildImage(builder, "ws", "v2", "ws-v2", "bundle.zip", simulatorBaseImage))
+	lastError := lastErrorOf(t, s, "v2")
+	assert.NotNil(lastError)
+	assert.Equal(model.ErrorStageBuild, lastError.Stage)
+	assert.Equal("no space left on device", lastError.Message)
+	assert.False(lastError.Time.Before(before))
+
+	// Kept in the workspace summary as well
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal(lastError, summarizeWorkspace(*ws).Versions[1].LastError)
+
+	builder.fail = nil
+	assert.NoError(s.buildImage(builder, "ws", "v2", "ws-v2", "bundle.zip", simulatorBaseImage))
+	assert.Nil(lastErrorOf(t, s, "v2"))
+}
+
+func Test_StartErrorRecorded(t *testing.T) {
+	assert := require.New(t)
+	s, _, _ := newCleanupServer(t)
+	st := s.store.(*failingStore).Storage.(*jsonstore.JSONStore)
+	starter := &fakeStarter{t: t, st: st, port: 32768, fail: errors.New("port is already allocated")}
+
+	assert.Error(s.restartSimulator(starter, "ws", "v2", "ws-v2", "abc"))
+	assert.Equal(&model.LastError{Message: "port is already allocated", Stage: model.ErrorStageStart, Time: lastErrorOf(t, s, "v2").Time}, lastErrorOf(t, s, "v2"))
+
+	starter.fail = nil
+	assert.NoError(s.restartSimulator(starter, "ws", "v2", "ws-v2", "abc"))
+	assert.Nil(lastErrorOf(t, s, "v2"))
+
+	// A start doesn't clear the error of another stage
+	s.recordVersionError("ws", "v2", model.ErrorStageCleanup, errors.New("image in use"))
+	starter.started = false
+	assert.NoError(s.restartSimulator(starter, "ws", "v2", "ws-v2", "abc"))
+	assert.Equal(model.ErrorStageCleanup, lastErrorOf(t, s, "v2").Stage)
+}
+
+func Test_ReadyErrorRecorded(t *testing.T) {
+	assert := require.New(t)
+	s, _, _ := newCleanupServer(t)
+	follow := func(follower *fakeFollower) error {
+		return s.followReadyState(follower, simulator.NewTracker(time.Now()), "ws", "v2", "ws-v2")
+	}
+
+	// Returned as well, so the job following the simulator fails
+	assert.EqualError(follow(&fakeFollower{fail: errors.New("container not found")}), "failed to follow the simulator logs: container not found")
+	assert.Equal(model.ErrorStageReady, lastErrorOf(t, s, "v2").Stage)
+	assert.Equal("failed to follow the simulator logs: container not found", lastErrorOf(t, s, "v2").Message)
+
+	assert.Error(follow(&fakeFollower{lines: []string{"loaded 2 objects of type nodes"}}))
+	assert.Equal("container ws-v2 stopped before it was ready", lastErrorOf(t, s, "v2").Message)
+	assert.False(getCleanupVersion(t, s, "v2").Ready)
+
+	// Loading the bundle clears the errors of getting there
+	assert.NoError(follow(&fakeFollower{lines: []string{"loaded 2 objects of type nodes", simulator.ReadyMessage}, fail: errors.New("not reached")}))
+	assert.True(getCleanupVersion(t, s, "v2").Ready)
+	assert.Nil(lastErrorOf(t, s, "v2"))
+}
+
+func Test_CleanupErrorRecorded(t *testing.T) {
+	assert := require.New(t)
+	s, cleaner, _ := newCleanupServer(t, "ws-v2")
+
+	assert.Equal(cleanStatusFailed, s.cleanVersion("ws", "v2", false).Status)
+	assert.Equal(&model.LastError{Message: "error removing image sha256:ws-v2", Stage: model.ErrorStageCleanup, Time: lastErrorOf(t, s, "v2").Time}, lastErrorOf(t, s, "v2"))
+
+	// A dry run doesn't tell whether the cleanup would succeed
+	cleaner.fail = map[string]bool{}
+	s.cleanVersion("ws", "v2", true)
+	assert.NotNil(lastErrorOf(t, s, "v2"))
+
+	assert.Equal(cleanStatusCleaned, s.cleanVersion("ws", "v2", false).Status)
+	assert.Nil(lastErrorOf(t, s, "v2"))
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// stagingDir holds the files of versions being created or deleted, relative to the data directory.
+// It is on the same filesystem as the workspaces so files are moved in and out with a rename,
+// and outside of them so the consistency check doesn't report it.
+const stagingDir = "staging"
+
+// errReplaceType is returned when a bundle is replaced with a kubeconfig or the other way around
+var errReplaceType = errors.New("the upload doesn't match the type of the version")
+
+// newStagingDir creates an empty directory under stagingDir
+func (s *Server) newStagingDir() (string, error) {
+	root := filepath.Join(s.dataDir, stagingDir)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(root, "version-")
+}
+
+// clearStaging removes what was left in stagingDir by a previous run
+func (s *Server) clearStaging() {
+	if err := os.RemoveAll(filepath.Join(s.dataDir, stagingDir)); err != nil {
+		fmt.Printf("Failed to clear staging directory: %v\n", err)
+	}
+}
+
+// createVersion adds a version whose files are written by write into an empty directory. The files are
+// moved into place first and the version is registered in the store last, whatever was created is removed
+// again when a step fails, so a failed creation leaves neither files nor a version behind.
+func (s *Server) createVersion(workspace, versionID string, write func(dir string) (*model.Version, error)) (*model.Version, error) {
+	staging, err := s.newStagingDir()
+	if err != nil {
+		return nil, err
+	}
+	// Nothing is left to remove once the directory was moved into place
+	defer os.RemoveAll(staging)
+
+	version, err := write(staging)
+	if err != nil {
+		return nil, err
+	}
+
+	versionPath := filepath.Join(s.dataDir, "workspaces", workspace, versionID)
+	if err := os.MkdirAll(filepath.Dir(versionPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, versionPath); err != nil {
+		return nil, err
+	}
+
+	version.Instance = s.instanceName(workspace, versionID)
+
+	// Store paths relative to the data directory so it can be relocated
+	version.BundlePath = s.relativeDataPath(rebasePath(version.BundlePath, staging, versionPath))
+	version.KubeconfigPath = s.relativeDataPath(rebasePath(version.KubeconfigPath, staging, versionPath))
+
+	// Remote stores upload the archive and remove it from the version directory
+	if version.BundlePath != "" {
+		if err := s.bundleStore().Put(context.Background(), bundleKey(version.BundlePath), s.dataPath(version.BundlePath)); err != nil {
+			if rmErr := os.RemoveAll(versionPath); rmErr != nil {
+				fmt.Printf("Failed to remove files of %s/%s: %v\n", workspace, versionID, rmErr)
+			}
+			return nil, fmt.Errorf("failed to store bundle: %w", err)
+		}
+	}
+
+	ws, err := s.store.GetWorkspace(workspace)
+	if err == nil {
+		// Copied as the workspace shares its versions with the store
+		ws.Versions = append(append([]model.Version{}, ws.Versions...), *version)
+		err = s.store.UpdateWorkspace(*ws)
+	}
+	if err != nil {
+		if version.BundlePath != "" {
+			if delErr := s.bundleStore().Delete(context.Background(), bundleKey(version.BundlePath)); delErr != nil {
+				fmt.Printf("Failed to remove bundle of %s/%s: %v\n", workspace, versionID, delErr)
+			}
+		}
+		if rmErr := os.RemoveAll(versionPath); rmErr != nil {
+			fmt.Printf("Failed to remove files of %s/%s: %v\n", workspace, versionID, rmErr)
+		}
+		return nil, err
+	}
+	return version, nil
+}
+
+// removeVersion deletes a version from the store and its files from disk. The files are moved out of the
+// workspace first and moved back when the store can't be updated, so the version is either deleted
+// completely or left as it was.
+func (s *Server) removeVersion(workspace, versionID string) error {
+	trash, err := s.newStagingDir()
+	if err != nil {
+		return err
+	}
+
+	versionPath := filepath.Join(s.dataDir, "workspaces", workspace, versionID)
+	trashed := filepath.Join(trash, versionID)
+	moved := true
+	if err := os.Rename(versionPath, trashed); err != nil {
+		if !os.IsNotExist(err) {
+			os.RemoveAll(trash)
+			return fmt.Errorf("failed to remove files: %w", err)
+		}
+		moved = false
+	}
+
+	removed, err := s.dropVersion(workspace, versionID)
+	if err != nil {
+		if moved {
+			if restoreErr := os.Rename(trashed, versionPath); restoreErr != nil {
+				// Kept in the staging directory, it is only cleared on the next start
+				fmt.Printf("Failed to restore files of %s/%s from %s: %v\n", workspace, versionID, trashed, restoreErr)
+				return err
+			}
+		}
+		os.RemoveAll(trash)
+		return err
+	}
+
+	if err := os.RemoveAll(trash); err != nil {
+		fmt.Printf("Failed to remove files of %s/%s: %v\n", workspace, versionID, err)
+	}
+	// The version is gone either way, an object left behind only takes space in the bucket
+	if removed.BundlePath != "" {
+		if err := s.bundleStore().Delete(context.Background(), bundleKey(removed.BundlePath)); err != nil {
+			fmt.Printf("Failed to remove bundle of %s/%s: %v\n", workspace, versionID, err)
+		}
+	}
+	return nil
+}
+
+// replaceVersion replaces the files of a version with those written by write into an empty directory.
+// The previous files and bundle are moved into a trash entry before the new ones are moved into place,
+// and moved back when a step fails, so a failed replacement leaves the version as it was. The version
+// keeps its ID, name, creation time and pin, the rest is taken from the new files.
+func (s *Server) replaceVersion(workspace string, old model.Version, write func(dir string) (*model.Version, error)) (*model.Version, error) {
+	staging, err := s.newStagingDir()
+	if err != nil {
+		return nil, err
+	}
+	// Nothing is left to remove once the directory was moved into place
+	defer os.RemoveAll(staging)
+
+	version, err := write(staging)
+	if err != nil {
+		return nil, err
+	}
+	// Saved queries and bookmarks of the version expect the same kind of cluster
+	if version.Type != old.Type {
+		return nil, fmt.Errorf("%w: the version is a %s, the upload a %s", errReplaceType, old.Type, version.Type)
+	}
+
+	s.trashLock.Lock()
+	defer s.trashLock.Unlock()
+
+	now := time.Now()
+	id, err := s.newTrashEntry(workspace+"-"+old.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	entry := &TrashEntry{
+		ID:        id,
+		Workspace: workspace,
+		VersionID: old.ID,
+		DeletedAt: now,
+		Versions:  []model.Version{old},
+		Replaced:  true,
+	}
+	moves, err := s.trashVersions(context.Background(), entry)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) error {
+		s.undoBundleMoves(moves)
+		os.RemoveAll(s.trashPath(id))
+		return err
+	}
+
+	versionPath := filepath.Join(s.dataDir, "workspaces", workspace, old.ID)
+	trashed := s.trashPath(id, "workspace", old.ID)
+	if err := os.MkdirAll(filepath.Dir(trashed), 0755); err != nil {
+		return nil, fail(err)
+	}
+	moved := true
+	if err := os.Rename(versionPath, trashed); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fail(fmt.Errorf("failed to move files to the trash: %w", err))
+		}
+		moved = false
+	}
+	// Puts the previous files back in place of the new ones
+	restore := func(err error) error {
+		if rmErr := os.RemoveAll(versionPath); rmErr != nil {
+			fmt.Printf("Failed to remove new files of %s/%s: %v\n", workspace, old.ID, rmErr)
+		}
+		if moved {
+			if restoreErr := os.Rename(trashed, versionPath); restoreErr != nil {
+				// Kept in the trash entry, it can be restored from there
+				fmt.Printf("Failed to move files of %s/%s back from the trash: %v\n", workspace, old.ID, restoreErr)
+				return err
+			}
+		}
+		return fail(err)
+	}
+
+	if err := os.Rename(staging, versionPath); err != nil {
+		return nil, restore(err)
+	}
+	version.BundlePath = s.relativeDataPath(rebasePath(version.BundlePath, staging, versionPath))
+	version.KubeconfigPath = s.relativeDataPath(rebasePath(version.KubeconfigPath, staging, versionPath))
+	if version.BundlePath != "" {
+		if err := s.bundleStore().Put(context.Background(), bundleKey(version.BundlePath), s.dataPath(version.BundlePath)); err != nil {
+			return nil, restore(fmt.Errorf("failed to store bundle: %w", err))
+		}
+	}
+
+	version.ID = old.ID
+	version.Instance = old.Instance
+	version.Name = old.Name
+	version.CreatedAt = old.CreatedAt
+	version.CreatedBy = old.CreatedBy
+	version.Pinned = old.Pinned
+	version.LastStartedAt = old.LastStartedAt
+	version.LastAccessedAt = old.LastAccessedAt
+	version.ReplacedAt = &now
+	// A container or image left by the previous bundle is discarded by the next start
+	version.ImageStale = version.Type != model.VersionTypeRuntime
+
+	ws, err := s.store.GetWorkspace(workspace)
+	if err == nil {
+		// Copied as the workspace shares its versions with the store
+		versions := make([]model.Version, 0, len(ws.Versions))
+		found := false
+		for _, v := range ws.Versions {
+			if v.ID == old.ID {
+				v, found = *version, true
+			}
+			versions = append(versions, v)
+		}
+		ws.Versions = versions
+		if found {
+			err = s.store.UpdateWorkspace(*ws)
+		} else {
+			err = fmt.Errorf("version %s was deleted", old.ID)
+		}
+	}
+	if err != nil {
+		if version.BundlePath != "" {
+			if delErr := s.bundleStore().Delete(context.Background(), bundleKey(version.BundlePath)); delErr != nil {
+				fmt.Printf("Failed to remove bundle of %s/%s: %v\n", workspace, old.ID, delErr)
+			}
+		}
+		return nil, restore(err)
+	}
+	return version, nil
+}
+
+// dropVersion removes a version from its workspace in the store and returns it
+func (s *Server) dropVersion(workspace, versionID string) (model.Version, error) {
+	ws, err := s.store.GetWorkspace(workspace)
+	if err != nil {
+		return model.Version{}, err
+	}
+	var removed model.Version
+	versions := make([]model.Version, 0, len(ws.Versions))
+	for _, v := range ws.Versions {
+		if v.ID != versionID {
+			versions = append(versions, v)
+		} else {
+			removed = v
+		}
+	}
+	ws.Versions = versions
+	return removed, s.store.UpdateWorkspace(*ws)
+}
+
+// rebasePath moves path from under the from directory to under the to directory
+func rebasePath(path, from, to string) string {
+	rel, err := filepath.Rel(from, path)
+	if path == "" || err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.Join(to, rel)
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+var errDiskFull = errors.New("no space left on device")
+
+// failingStore fails UpdateWorkspace once failUpdate is set, like a full disk when writing data.json
+type failingStore struct {
+	store.Storage
+	failUpdate bool
+}
+
+func (f *failingStore) UpdateWorkspace(ws model.Workspace) error {
+	if f.failUpdate {
+		return errDiskFull
+	}
+	return f.Storage.UpdateWorkspace(ws)
+}
+
+func newFilesServer(t *testing.T) (*Server, *failingStore) {
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	require.NoError(t, err)
+	require.NoError(t, st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: time.Now()}))
+	failing := &failingStore{Storage: st}
+	return &Server{store: failing, dataDir: dataDir}, failing
+}
+
+func writeKubeconfig(dir string) (*model.Version, error) {
+	path := filepath.Join(dir, "cluster.yaml")
+	if err := os.WriteFile(path, []byte("apiVersion: v1"), 0644); err != nil {
+		return nil, err
+	}
+	return &model.Version{ID: "v1", Type: model.VersionTypeRuntime, KubeconfigPath: path}, nil
+}
+
+func assertStagingEmpty(t *testing.T, s *Server) {
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, stagingDir))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func Test_CreateVersion(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+
+	version, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+	assert.Equal(filepath.Join("workspaces", "ws", "v1", "cluster.yaml"), version.KubeconfigPath)
+	assert.FileExists(filepath.Join(s.dataDir, version.KubeconfigPath))
+	assertStagingEmpty(t, s)
+
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Len(ws.Versions, 1)
+	assert.Equal(version.KubeconfigPath, ws.Versions[0].KubeconfigPath)
+	assert.Equal("ws-v1", ws.Versions[0].InstanceName("ws"))
+
+	assert.NoError(s.store.CreateWorkspace(model.Workspace{Name: "my workspace", CreatedAt: time.Now()}))
+	version, err = s.createVersion("my workspace", "v1", writeKubeconfig)
+	assert.NoError(err)
+	assert.Regexp(`^my-workspace-[0-9a-f]{8}-v1$`, version.Instance)
+
+	// Versions created before instance names were stored keep theirs
+	assert.Equal("my workspace-v1", model.Version{ID: "v1"}.InstanceName("my workspace"))
+}
+
+func Test_CreateVersionRollsBackOnStoreFailure(t *testing.T) {
+	assert := require.New(t)
+	s, failing := newFilesServer(t)
+	failing.failUpdate = true
+
+	_, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.ErrorIs(err, errDiskFull)
+	_, err = os.Stat(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	assert.True(os.IsNotExist(err))
+	assertStagingEmpty(t, s)
+
+	// the next upload gets the same version ID without colliding
+	failing.failUpdate = false
+	_, err = s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+}
+
+func Test_CreateVersionRollsBackOnWriteFailure(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+
+	_, err := s.createVersion("ws", "v1", func(dir string) (*model.Version, error) {
+		if _, err := writeKubeconfig(dir); err != nil {
+			return nil, err
+		}
+		return nil, errDiskFull
+	})
+	assert.ErrorIs(err, errDiskFull)
+	_, err = os.Stat(filepath.Join(s.dataDir, "workspaces", "ws", "v1"))
+	assert.True(os.IsNotExist(err))
+	assertStagingEmpty(t, s)
+
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Empty(ws.Versions)
+}
+
+func Test_RemoveVersion(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	version, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+
+	assert.NoError(s.removeVersion("ws", "v1"))
+	_, err = os.Stat(filepath.Join(s.dataDir, version.KubeconfigPath))
+	assert.True(os.IsNotExist(err))
+	assertStagingEmpty(t, s)
+
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Empty(ws.Versions)
+}
+
+func Test_RemoveVersionRestoresFilesOnStoreFailure(t *testing.T) {
+	assert := require.New(t)
+	s, failing := newFilesServer(t)
+	version, err := s.createVersion("ws", "v1", writeKubeconfig)
+	assert.NoError(err)
+	failing.failUpdate = true
+
+	assert.ErrorIs(s.removeVersion("ws", "v1"), errDiskFull)
+	assert.FileExists(filepath.Join(s.dataDir, version.KubeconfigPath))
+	assertStagingEmpty(t, s)
+
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Len(ws.Versions, 1)
+}
+
+// memoryBundles is a remote bundle store keeping the uploaded bundles in memory
+type memoryBundles struct {
+	objects map[string][]byte
+}
+
+func (m *memoryBundles) Put(ctx context.Context, key, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m.objects[key] = data
+	return os.Remove(path)
+}
+
+func (m *memoryBundles) Fetch(ctx context.Context, key string) (string, error) {
+	return "", bundlestore.ErrNotFound
+}
+
+func (m *memoryBundles) Exists(ctx context.Context, key string) (bool, error) {
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *memoryBundles) Delete(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memoryBundles) Rename(ctx context.Context, from, to string) error {
+	data, ok := m.objects[from]
+	if !ok {
+		return bundlestore.ErrNotFound
+	}
+	delete(m.objects, from)
+	m.objects[to] = data
+	return nil
+}
+
+func writeBundle(dir string) (*model.Version, error) {
+	path := filepath.Join(dir, "bundle.zip")
+	if err := os.WriteFile(path, []byte("zip"), 0644); err != nil {
+		return nil, err
+	}
+	return &model.Version{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: path}, nil
+}
+
+func Test_CreateVersionStoresBundle(t *testing.T) {
+	assert := require.New(t)
+	s, failing := newFilesServer(t)
+	bundles := &memoryBundles{objects: map[string][]byte{}}
+	s.SetBundleStore(bundles)
+
+	version, err := s.createVersion("ws", "v1", writeBundle)
+	assert.NoError(err)
+	assert.Equal("workspaces/ws/v1/bundle.zip", bundleKey(version.BundlePath))
+	assert.Equal([]byte("zip"), bundles.objects["workspaces/ws/v1/bundle.zip"])
+	assert.NoFileExists(filepath.Join(s.dataDir, version.BundlePath))
+
+	assert.NoError(s.removeVersion("ws", "v1"))
+	assert.Empty(bundles.objects)
+
+	// a version that can't be saved leaves no object behind
+	failing.failUpdate = true
+	_, err = s.createVersion("ws", "v1", writeBundle)
+	assert.ErrorIs(err, errDiskFull)
+	assert.Empty(bundles.objects)
+}
+
+// writeBundleWith writes a bundle archive holding content
+func writeBundleWith(content string) func(dir string) (*model.Version, error) {
+	return func(dir string) (*model.Version, error) {
+		path := filepath.Join(dir, "bundle.zip")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		return &model.Version{ID: "v1", Name: "v1", Type: model.VersionTypeSupportBundle, BundlePath: path}, nil
+	}
+}
+
+func Test_ReplaceVersion(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	bundles := &memoryBundles{objects: map[string][]byte{}}
+	s.SetBundleStore(bundles)
+	old, err := s.createVersion("ws", "v1", writeBundleWith("first"))
+	assert.NoError(err)
+	old.Name, old.Pinned, old.Ready, old.BuildError = "upgrade failure", true, true, "no space left"
+	old.CreatedAt = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	old.CreatedBy = "alice"
+	assert.NoError(updateVersion(s.store, "ws", "v1", func(v *model.Version) bool {
+		*v = *old
+		return true
+	}))
+
+	replaced, err := s.replaceVersion("ws", *old, writeBundleWith("corrected"))
+	assert.NoError(err)
+	assert.Equal([]byte("corrected"), bundles.objects["workspaces/ws/v1/bundle.zip"])
+	assert.Equal(bundleKey(old.BundlePath), bundleKey(replaced.BundlePath))
+	stored, _ := findVersionIn(t, s, "ws", "v1")
+	assert.Equal("upgrade failure", stored.Name, "the name and pin are kept")
+	assert.True(stored.Pinned)
+	assert.Equal(old.CreatedAt, stored.CreatedAt)
+	assert.Equal("alice", stored.CreatedBy, "the uploader of the version is kept")
+	assert.False(stored.Ready, "the new bundle isn't loaded yet")
+	assert.Empty(stored.BuildError)
+	assert.True(stored.ImageStale)
+	assert.NotNil(stored.ReplacedAt)
+	assertStagingEmpty(t, s)
+
+	// the previous bundle is kept in the trash and restored as a new version
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.True(entries[0].Replaced)
+	assert.Equal("v1", entries[0].VersionID)
+	assert.Equal([]byte("first"), bundles.objects[bundleKey(entries[0].Versions[0].BundlePath)])
+	restored, err := s.restoreTrash(entries[0].ID)
+	assert.NoError(err)
+	assert.Equal("v2", restored.versions[0].ID)
+	assert.Equal("upgrade failure", restored.versions[0].Name)
+}
+
+func Test_ReplaceVersionRestoresOnFailure(t *testing.T) {
+	assert := require.New(t)
+	s, failing := newFilesServer(t)
+	old, err := s.createVersion("ws", "v1", writeBundleWith("first"))
+	assert.NoError(err)
+	failing.failUpdate = true
+
+	_, err = s.replaceVersion("ws", *old, writeBundleWith("corrected"))
+	assert.ErrorIs(err, errDiskFull)
+	content, err := os.ReadFile(filepath.Join(s.dataDir, old.BundlePath))
+	assert.NoError(err)
+	assert.Equal("first", string(content), "the previous bundle is left in place")
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Empty(entries)
+	assertStagingEmpty(t, s)
+
+	failing.failUpdate = false
+	_, err = s.replaceVersion("ws", *old, writeKubeconfig)
+	assert.ErrorIs(err, errReplaceType)
+	stored, _ := findVersionIn(t, s, "ws", "v1")
+	assert.Equal(*old, stored)
+	assert.FileExists(filepath.Join(s.dataDir, old.BundlePath))
+}
@@ -1,18 +1,21 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 
 	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
 )
 
-// CleanVersionResult represents the result of cleaning a single version
-type CleanVersionResult struct {
-	VersionID string
-	Error     error
-}
-
 // ResetVersionReadyState resets the ready state for a version
 func (s *Server) ResetVersionReadyState(workspaceName, versionID string) error {
 	ws, err := s.store.GetWorkspace(workspaceName)
@@ -38,7 +41,7 @@ func (s *Server) ResetVersionReadyState(workspaceName, versionID string) error {
 	return nil
 }
 
-// MarkVersionReady marks a version as ready
+// MarkVersionReady marks a version as ready and clears the errors of getting it there
 func (s *Server) MarkVersionReady(workspaceName, versionID string) error {
 	ws, err := s.store.GetWorkspace(workspaceName)
 	if err != nil {
@@ -50,7 +53,11 @@ func (s *Server) MarkVersionReady(workspaceName, versionID string) error {
 		if v.ID == versionID && !v.Ready {
 			ws.Versions[i].Ready = true
 			updated = true
-			break
+		}
+		// The simulator was built, started and loaded the bundle, earlier failures of these are over
+		if v.ID == versionID && v.LastError != nil && v.LastError.Stage != model.ErrorStageCleanup {
+			ws.Versions[i].LastError = nil
+			updated = true
 		}
 	}
 
@@ -63,15 +70,33 @@ func (s *Server) MarkVersionReady(workspaceName, versionID string) error {
 	return nil
 }
 
-// FormatCleanResults formats clean results into error messages
-func FormatCleanResults(results []CleanVersionResult) []string {
-	var errors []string
-	for _, result := range results {
-		if result.Error != nil {
-			errors = append(errors, fmt.Sprintf("Version %s: %v", result.VersionID, result.Error))
-		}
+// containerStarter is the part of the docker client restartSimulator needs
+type containerStarter interface {
+	StartContainer(containerID string) error
+	QueryExposedMapping(instanceName string) (string, string, error)
+}
+
+// restartSimulator starts the stopped container of a simulator. The version is no longer ready as the
+// apiserver boots again, and docker publishes the apiserver on a new host port, so kubeconfigs fetched
+// before are announced as stale with a SimulatorKubeconfigChanged event.
+func (s *Server) restartSimulator(starter containerStarter, workspaceName, versionID, instanceName, containerID string) error {
+	if err := s.ResetVersionReadyState(workspaceName, versionID); err != nil {
+		return fmt.Errorf("failed to reset ready state: %w", err)
+	}
+	if err := starter.StartContainer(containerID); err != nil {
+		s.recordVersionError(workspaceName, versionID, model.ErrorStageStart, err)
+		return fmt.Errorf("failed to start existing container: %w", err)
+	}
+	s.clearVersionError(workspaceName, versionID, model.ErrorStageStart)
+
+	// The container runs, a kubeconfig request queries the mapping again if this fails
+	endpoint, port, err := starter.QueryExposedMapping(instanceName)
+	if err != nil {
+		fmt.Printf("Failed to query exposed mapping of %s: %v\n", instanceName, err)
+		return nil
 	}
-	return errors
+	s.events.Publish(events.SimulatorKubeconfigChanged, workspaceName, versionID, net.JoinHostPort(endpoint, port))
+	return nil
 }
 
 // HasVersionInWorkspace checks if a version exists in a workspace
@@ -84,29 +109,101 @@ func HasVersionInWorkspace(ws *model.Workspace, versionID string) bool {
 	return false
 }
 
+// errRuntimeVersion is returned when simulator operations are requested for a runtime version
+var errRuntimeVersion = errors.New("runtime versions are backed by a live cluster and have no simulator to start")
+
+// findVersion returns a copy of a version of the workspace
+func findVersion(ws *model.Workspace, versionID string) (model.Version, bool) {
+	for i := range ws.Versions {
+		if ws.Versions[i].ID == versionID {
+			return ws.Versions[i], true
+		}
+	}
+	return model.Version{}, false
+}
+
+// instanceNameOf returns the instance name of a version, the one of a version created before instance
+// names were stored when the version isn't found
+func instanceNameOf(st store.Storage, workspaceName, versionID string) string {
+	version := model.Version{ID: versionID}
+	if ws, err := st.GetWorkspace(workspaceName); err == nil {
+		if v, ok := findVersion(ws, versionID); ok {
+			version = v
+		}
+	}
+	return version.InstanceName(workspaceName)
+}
+
+// checkSimulatorBundle verifies the support bundle a simulator image is built from, bundlePath is
+// the bundleSource of v
+func checkSimulatorBundle(v model.Version, bundlePath string) error {
+	if v.BundlePath == "" && !v.ExtractedOnly {
+		return fmt.Errorf("version %s has no support bundle to build a simulator from", v.ID)
+	}
+	if _, err := os.Stat(bundlePath); err != nil {
+		return fmt.Errorf("support bundle %s of version %s is missing: %w", bundlePath, v.ID, err)
+	}
+	return nil
+}
+
+// bundleSource returns what the simulator image of v is built from, a local copy of the bundle zip file
+// fetched from the bundle store or the extracted directory of a version uploaded with layout=extracted
+func (s *Server) bundleSource(ctx context.Context, workspaceName string, v model.Version) (string, error) {
+	if v.ExtractedOnly {
+		return filepath.Join(s.dataDir, "workspaces", workspaceName, v.ID, "extracted"), nil
+	}
+	if v.BundlePath == "" {
+		return "", nil
+	}
+	path, err := s.bundleStore().Fetch(ctx, bundleKey(v.BundlePath))
+	if errors.Is(err, bundlestore.ErrNotFound) {
+		// Left to checkSimulatorBundle to report as missing
+		return s.dataPath(v.BundlePath), nil
+	}
+	return path, err
+}
+
+// bundleKey is the key the bundle stored at path, relative to the data directory, is kept under
+func bundleKey(path string) string {
+	return filepath.ToSlash(path)
+}
+
 func (s *Server) GetExecutor(workspaceName, versionID string) (executor.Executor, error) {
 	ws, err := s.store.GetWorkspace(workspaceName)
 	if err != nil {
 		return nil, err
 	}
 
-	var targetVersion *model.Version
-	for _, v := range ws.Versions {
-		if v.ID == versionID {
-			targetVersion = &v
-			break
-		}
-	}
-
-	if targetVersion == nil {
+	targetVersion, ok := findVersion(ws, versionID)
+	if !ok {
 		return nil, fmt.Errorf("version %s not found in workspace %s", versionID, workspaceName)
 	}
 
+	// Every kubectl-backed query goes through here
+	s.touchVersion(workspaceName, targetVersion)
+
+	instanceName := targetVersion.InstanceName(workspaceName)
 	if targetVersion.Type == model.VersionTypeRuntime {
-		return executor.NewRuntimeExecutor(targetVersion.KubeconfigPath), nil
+		exec := executor.NewRuntimeExecutor(s.dataPath(targetVersion.KubeconfigPath), s.kubectl.Path)
+		return &trackedExecutor{Executor: exec, server: s, instance: instanceName}, nil
 	}
 
 	// Default to support bundle
-	instanceName := fmt.Sprintf("%s-%s", workspaceName, versionID)
-	return executor.NewContainerExecutor(s.docker, instanceName), nil
+	exec := executor.NewContainerExecutor(s.docker, instanceName)
+	return &trackedExecutor{Executor: exec, server: s, instance: instanceName}, nil
+}
+
+// dataPath resolves a path stored in the model to a path under the current data directory
+func (s *Server) dataPath(path string) string {
+	return utils.ResolveDataPath(s.dataDir, path)
+}
+
+// relativeDataPath converts a path under the data directory into the relative form stored in the model,
+// so the data directory can be moved without breaking existing versions
+func (s *Server) relativeDataPath(path string) string {
+	rel, err := filepath.Rel(s.dataDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
 }
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FindVersionReturnsCopy(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{Versions: []model.Version{{ID: "v1"}, {ID: "v2", Type: model.VersionTypeRuntime}}}
+
+	v, ok := findVersion(ws, "v2")
+	assert.True(ok)
+	assert.Equal(model.VersionTypeRuntime, v.Type)
+
+	// changing the result must not change the workspace, which shares its versions with the store
+	v.Ready = true
+	assert.False(ws.Versions[1].Ready)
+
+	_, ok = findVersion(ws, "v3")
+	assert.False(ok)
+}
+
+func Test_CheckSimulatorBundle(t *testing.T) {
+	assert := require.New(t)
+	dataDir := t.TempDir()
+
+	// runtime versions (kubeconfig uploads) have no bundle to build from
+	runtime := model.Version{ID: "v1", Type: model.VersionTypeRuntime, KubeconfigPath: "workspaces/ws/v1/kubeconfig.yaml"}
+	err := checkSimulatorBundle(runtime, "")
+	assert.ErrorContains(err, "has no support bundle")
+
+	// the bundle was removed from disk after the upload
+	bundle := model.Version{ID: "v2", BundlePath: "workspaces/ws/v2/bundle.zip"}
+	bundlePath := filepath.Join(dataDir, bundle.BundlePath)
+	err = checkSimulatorBundle(bundle, bundlePath)
+	assert.ErrorContains(err, bundlePath)
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	assert.NoError(os.MkdirAll(filepath.Dir(bundlePath), 0755))
+	assert.NoError(os.WriteFile(bundlePath, []byte("zip"), 0644))
+	assert.NoError(checkSimulatorBundle(bundle, bundlePath))
+
+	// bundles uploaded extracted are built from their extracted directory
+	extracted := model.Version{ID: "v3", ExtractedOnly: true}
+	s := &Server{dataDir: dataDir}
+	extractedPath, err := s.bundleSource(context.Background(), "ws", extracted)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dataDir, "workspaces", "ws", "v3", "extracted"), extractedPath)
+	assert.ErrorIs(checkSimulatorBundle(extracted, extractedPath), os.ErrNotExist)
+	assert.NoError(os.MkdirAll(extractedPath, 0755))
+	assert.NoError(checkSimulatorBundle(extracted, extractedPath))
+}
+
+func Test_StartSimulatorRejectsRuntimeVersion(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name:      "ws",
+		CreatedAt: time.Now(),
+		Versions:  []model.Version{{ID: "v1", Type: model.VersionTypeRuntime, KubeconfigPath: "workspaces/ws/v1/kubeconfig.yaml"}},
+	}))
+	// no docker client, a runtime version must be rejected before docker is used
+	s := &Server{store: st}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/v1/start", nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+	assert.Contains(rec.Body.String(), "no simulator")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/api/workspaces/ws/versions/v2/start", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
+
+// fakeStarter plays a stopped simulator container that gets a new host port on every start
+type fakeStarter struct {
+	t       *testing.T
+	st      *jsonstore.JSONStore
+	port    int
+	started bool
+	fail    error
+}
+
+func (f *fakeStarter) StartContainer(containerID string) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	// the version must no longer claim to be ready once the apiserver boots
+	ws, err := f.st.GetWorkspace("ws")
+	require.NoError(f.t, err)
+	require.False(f.t, ws.Versions[0].Ready)
+	f.port++
+	f.started = true
+	return nil
+}
+
+func (f *fakeStarter) QueryExposedMapping(instanceName string) (string, string, error) {
+	if !f.started {
+		return "", "", errors.New("container is not running")
+	}
+	return "localhost", fmt.Sprint(f.port), nil
+}
+
+func Test_RestartSimulator(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	assert.NoError(st.CreateWorkspace(model.Workspace{
+		Name:      "ws",
+		CreatedAt: time.Now(),
+		Versions:  []model.Version{{ID: "v1", Type: model.VersionTypeSupportBundle, Ready: true}},
+	}))
+	s := &Server{store: st, events: events.NewBus()}
+	sub := s.events.Subscribe()
+	defer sub.Close()
+	starter := &fakeStarter{t: t, st: st, port: 32768}
+
+	ready := func() bool {
+		ws, err := st.GetWorkspace("ws")
+		assert.NoError(err)
+		return ws.Versions[0].Ready
+	}
+
+	// the simulator was stopped while ready and is started again
+	assert.NoError(s.restartSimulator(starter, "ws", "v1", "ws-v1", "abc"))
+	assert.False(ready())
+	e := <-sub.Events()
+	assert.Equal(events.SimulatorKubeconfigChanged, e.Type)
+	assert.Equal("v1", e.VersionID)
+	assert.Equal("localhost:32769", e.Payload)
+
+	// monitorReadyState marks it ready once the bundle is loaded
+	s.markVersionReady("ws", "v1")
+	assert.True(ready())
+	assert.Equal(events.VersionReady, (<-sub.Events()).Type)
+
+	// stopped and started again, on another port
+	starter.started = false
+	assert.NoError(s.restartSimulator(starter, "ws", "v1", "ws-v1", "abc"))
+	assert.False(ready())
+	assert.Equal("localhost:32770", (<-sub.Events()).Payload)
+
+	starter.started = false
+	starter.fail = errors.New("port is already allocated")
+	assert.ErrorContains(s.restartSimulator(starter, "ws", "v1", "ws-v1", "abc"), "port is already allocated")
+	assert.Empty(sub.Events())
+}
+
+func Test_SimulatorContainer(t *testing.T) {
+	assert := require.New(t)
+	assert.Nil(simulatorContainer(nil))
+
+	started := time.Date(2024, 11, 18, 10, 0, 0, 0, time.UTC)
+	running := simulatorContainer(&docker.ContainerStatus{ID: "abc", State: "running", StartedAt: started, Image: "ws-v1:latest", APIServerPort: 32768})
+	assert.Equal(&SimulatorContainer{ID: "abc", State: "running", StartedAt: &started, Image: "ws-v1:latest", APIServerPort: 32768}, running)
+
+	// a failed start shows why right away
+	finished := started.Add(time.Minute)
+	exited := simulatorContainer(&docker.ContainerStatus{ID: "abc", State: "exited", StartedAt: started, FinishedAt: finished, Logs: []string{"panic: no such file"}})
+	assert.NotNil(exited.ExitCode)
+	assert.Zero(*exited.ExitCode)
+	assert.Equal(&finished, exited.FinishedAt)
+	assert.Equal([]string{"panic: no such file"}, exited.Logs)
+	assert.Nil(exited.ImageCreatedAt)
+}
+
+func Test_SimulatorBuild(t *testing.T) {
+	assert := require.New(t)
+	assert.Nil(simulatorBuild(model.Version{}, nil, "", nil))
+
+	created := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	version := model.Version{ID: "v1", BaseImageDigest: "rancher/support-bundle-kit@sha256:d1g3st", BaseImageCreated: &created}
+	labels := map[string]string{"org.opencontainers.image.revision": "f00ba4"}
+	build := simulatorBuild(version, &docker.ContainerStatus{ID: "abc", State: "running", ImageLabels: labels}, "v0.0.42-dev", nil)
+	assert.Equal(&SimulatorBuild{
+		Version:          "v0.0.42-dev",
+		BaseImage:        "rancher/support-bundle-kit:master-head",
+		BaseImageDigest:  "rancher/support-bundle-kit@sha256:d1g3st",
+		BaseImageCreated: &created,
+		Labels:           labels,
+	}, build)
+
+	// older builds without the version command still show what the image tells
+	build = simulatorBuild(model.Version{}, &docker.ContainerStatus{ID: "abc", State: "running", ImageLabels: labels}, "", errors.New("unknown command \"version\""))
+	assert.Equal("unknown command \"version\"", build.VersionError)
+	assert.Equal(labels, build.Labels)
+}
+
+func Test_ContainerExtras(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{
+		ContainerLabels: map[string]string{"case": "01234", "team": "storage"},
+		ContainerEnv:    map[string]string{"TZ": "UTC"},
+	}
+
+	extras, err := containerExtras(ws, map[string]string{"team": "network"}, nil)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"case": "01234", "team": "network"}, extras.Labels, "expected the request to override the workspace")
+	assert.Equal(map[string]string{"TZ": "UTC"}, extras.Env)
+	assert.Equal(map[string]string{"case": "01234", "team": "storage"}, ws.ContainerLabels, "expected the workspace to be left unchanged")
+
+	_, err = containerExtras(ws, map[string]string{"sim-cli-managed": "ws-v2"}, nil)
+	assert.EqualError(err, "label sim-cli-managed is reserved")
+}
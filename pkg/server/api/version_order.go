@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// orderedVersions returns versions in the order they are shown and compared in: the order set with
+// PUT .../versions/order, then versions uploaded since by the time their bundle was collected. Versions
+// without a collection time, like imported paths, use their upload time instead.
+func orderedVersions(versions []model.Version) []model.Version {
+	ordered := append([]model.Version{}, versions...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if (a.SortIndex == 0) != (b.SortIndex == 0) {
+			return a.SortIndex != 0
+		}
+		if a.SortIndex != b.SortIndex {
+			return a.SortIndex < b.SortIndex
+		}
+		return versionTime(a).Before(versionTime(b))
+	})
+	return ordered
+}
+
+// versionTime is when the state a version shows was current
+func versionTime(v model.Version) time.Time {
+	if v.CollectedAt != nil {
+		return *v.CollectedAt
+	}
+	return v.CreatedAt
+}
+
+// reorderVersions sets the sort index of every version to its place in versionIDs, which must list
+// each version of the workspace exactly once
+func reorderVersions(ws *model.Workspace, versionIDs []string) error {
+	if len(versionIDs) != len(ws.Versions) {
+		return fmt.Errorf("the order lists %d versions, the workspace has %d", len(versionIDs), len(ws.Versions))
+	}
+	index := make(map[string]int, len(versionIDs))
+	for i, id := range versionIDs {
+		if _, ok := index[id]; ok {
+			return fmt.Errorf("version %s is listed more than once", id)
+		}
+		index[id] = i + 1
+	}
+	for _, v := range ws.Versions {
+		if _, ok := index[v.ID]; !ok {
+			return fmt.Errorf("version %s isn't listed", v.ID)
+		}
+	}
+
+	for i := range ws.Versions {
+		ws.Versions[i].SortIndex = index[ws.Versions[i].ID]
+	}
+	ws.Versions = orderedVersions(ws.Versions)
+	return nil
+}
+
+// handleSetVersionOrder sets the order versions are shown and compared in. The body lists the IDs of
+// every version of the workspace in their new order, a list that doesn't match them is rejected.
+// versionOrderRequest is the body of a version reorder
+type versionOrderRequest struct {
+	VersionIDs []string `json:"versionIDs"`
+}
+
+func (s *Server) handleSetVersionOrder(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req versionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	// Copied as the workspace shares its versions with the store
+	ws.Versions = append([]model.Version{}, ws.Versions...)
+	if err := reorderVersions(ws, req.VersionIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.events.PublishBy(s.requestUser(r), events.WorkspaceUpdated, name, "", ws)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ws.Versions)
+}
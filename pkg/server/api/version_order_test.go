@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func idsOf(versions []model.Version) []string {
+	ids := make([]string, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+func Test_OrderedVersions(t *testing.T) {
+	assert := require.New(t)
+	now := time.Now()
+	// v3 was restored from the trash, it was uploaded before v2
+	versions := []model.Version{
+		{ID: "v1", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "v2", CreatedAt: now.Add(-time.Hour)},
+		{ID: "v3", CreatedAt: now.Add(-2 * time.Hour)},
+	}
+	assert.Equal([]string{"v1", "v3", "v2"}, idsOf(orderedVersions(versions)))
+	assert.Equal("v2", versions[1].ID, "the versions are copied")
+
+	// a bundle collected before v1 but uploaded last sorts first
+	collected := now.Add(-4 * time.Hour)
+	withOlder := append(append([]model.Version{}, versions...), model.Version{ID: "v0", CreatedAt: now, CollectedAt: &collected})
+	assert.Equal([]string{"v0", "v1", "v3", "v2"}, idsOf(orderedVersions(withOlder)))
+
+	// versions uploaded after reordering follow the reordered ones
+	versions[0].SortIndex, versions[1].SortIndex, versions[2].SortIndex = 2, 1, 3
+	versions = append(versions, model.Version{ID: "v4", CreatedAt: now})
+	assert.Equal([]string{"v2", "v1", "v3", "v4"}, idsOf(orderedVersions(versions)))
+}
+
+func Test_ReorderVersions(t *testing.T) {
+	assert := require.New(t)
+	ws := &model.Workspace{Versions: []model.Version{{ID: "v1"}, {ID: "v2"}, {ID: "v3"}}}
+
+	for _, c := range []struct {
+		order []string
+		err   string
+	}{
+		{[]string{"v1", "v2"}, "the order lists 2 versions, the workspace has 3"},
+		{[]string{"v1", "v2", "v2"}, "version v2 is listed more than once"},
+		{[]string{"v1", "v2", "v4"}, "version v3 isn't listed"},
+	} {
+		assert.EqualError(reorderVersions(ws, c.order), c.err, strings.Join(c.order, ","))
+	}
+	assert.Zero(ws.Versions[0].SortIndex, "a rejected order changes nothing")
+
+	assert.NoError(reorderVersions(ws, []string{"v3", "v1", "v2"}))
+	assert.Equal([]string{"v3", "v1", "v2"}, idsOf(ws.Versions))
+	assert.Equal(1, ws.Versions[0].SortIndex)
+}
+
+func Test_SetVersionOrder(t *testing.T) {
+	assert := require.New(t)
+	st, err := jsonstore.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	assert.NoError(err)
+	now := time.Now()
+	assert.NoError(st.CreateWorkspace(model.Workspace{Name: "ws", CreatedAt: now, Versions: []model.Version{
+		{ID: "v1", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "v2", CreatedAt: now.Add(-time.Hour)},
+	}}))
+	s := &Server{store: st, events: events.NewBus()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/workspaces/{name}/versions/order", s.handleSetVersionOrder)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("PUT", "/api/workspaces/ws/versions/order", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := put(`{"versionIDs":["v2"]}`)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = put(`{"versionIDs":["v2","v1"]}`)
+	assert.Equal(http.StatusOK, rec.Code)
+	var versions []model.Version
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&versions))
+	assert.Equal([]string{"v2", "v1"}, idsOf(versions))
+
+	ws, err := st.GetWorkspace("ws")
+	assert.NoError(err)
+	assert.Equal([]string{"v2", "v1"}, idsOf(orderedVersions(ws.Versions)))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("PUT", "/api/workspaces/missing/versions/order", strings.NewReader(`{"versionIDs":[]}`)))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// BackupTarget is where Harvester stores VM backups, the credentials of the setting are left out
+type BackupTarget struct {
+	Type         string `json:"type,omitempty"` // s3 or nfs, only known for the setting
+	Endpoint     string `json:"endpoint"`
+	BucketName   string `json:"bucketName,omitempty"`
+	BucketRegion string `json:"bucketRegion,omitempty"`
+}
+
+// VMVolumeBackup is a volume of a VM backup with the volume snapshot and Longhorn backup behind it
+type VMVolumeBackup struct {
+	VolumeName string `json:"volumeName"`
+	ClaimName  string `json:"claimName,omitempty"`
+	Ready      bool   `json:"ready"`
+	Error      string `json:"error,omitempty"`
+	// VolumeSnapshot and VolumeSnapshotContent are the CSI objects Harvester created for the volume
+	VolumeSnapshot        string `json:"volumeSnapshot,omitempty"`
+	VolumeSnapshotContent string `json:"volumeSnapshotContent,omitempty"`
+	// LonghornBackup is only set for backups, snapshots stay on the cluster
+	LonghornBackup      string `json:"longhornBackup,omitempty"`
+	LonghornBackupState string `json:"longhornBackupState,omitempty"`
+	LonghornBackupURL   string `json:"longhornBackupURL,omitempty"`
+}
+
+// VMBackupSummary is a virtualmachinebackups.harvesterhci.io object
+type VMBackupSummary struct {
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace"`
+	Type      string        `json:"type"`  // backup or snapshot
+	State     string        `json:"state"` // Ready, InProgress or Error
+	CreatedAt string        `json:"createdAt,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Target    *BackupTarget `json:"target,omitempty"`
+	// TargetMismatch is set for backups stored on another target than the current backup-target setting,
+	// they can't be restored until the setting points at their target again
+	TargetMismatch bool             `json:"targetMismatch"`
+	Volumes        []VMVolumeBackup `json:"volumes"`
+	Problems       []string         `json:"problems"`
+}
+
+// VMRestoreSummary is a virtualmachinerestores.harvesterhci.io object
+type VMRestoreSummary struct {
+	Name            string   `json:"name"`
+	Namespace       string   `json:"namespace"`
+	BackupName      string   `json:"backupName"`
+	BackupNamespace string   `json:"backupNamespace"`
+	NewVM           bool     `json:"newVM"`
+	Complete        bool     `json:"complete"`
+	CreatedAt       string   `json:"createdAt,omitempty"`
+	Problems        []string `json:"problems"`
+}
+
+// VMBackupInventory are the backups taken of a VM and the restores into it
+type VMBackupInventory struct {
+	Namespace string             `json:"namespace"`
+	VMName    string             `json:"vmName"`
+	Backups   []VMBackupSummary  `json:"backups"`
+	Restores  []VMRestoreSummary `json:"restores"`
+}
+
+type VMBackupsResult struct {
+	// Harvester is false for bundles of other clusters, they have no VM backups
+	Harvester bool   `json:"harvester"`
+	Message   string `json:"message,omitempty"`
+	// BackupTarget is the current backup-target setting, nil when it isn't set
+	BackupTarget *BackupTarget       `json:"backupTarget,omitempty"`
+	VMs          []VMBackupInventory `json:"vms"`
+	Error        string              `json:"error,omitempty"`
+}
+
+type objectMeta struct {
+	Name              string `yaml:"name"`
+	Namespace         string `yaml:"namespace"`
+	CreationTimestamp string `yaml:"creationTimestamp"`
+}
+
+type objectError struct {
+	Message string `yaml:"message"`
+}
+
+type vmBackupList struct {
+	Items []struct {
+		Metadata objectMeta `yaml:"metadata"`
+		Spec     struct {
+			Type   string `yaml:"type"`
+			Source struct {
+				Name string `yaml:"name"`
+			} `yaml:"source"`
+		} `yaml:"spec"`
+		Status struct {
+			ReadyToUse   *bool        `yaml:"readyToUse"`
+			Error        *objectError `yaml:"error"`
+			BackupTarget *struct {
+				Endpoint     string `yaml:"endpoint"`
+				BucketName   string `yaml:"bucketName"`
+				BucketRegion string `yaml:"bucketRegion"`
+			} `yaml:"backupTarget"`
+			VolumeBackups []struct {
+				Name                  string `yaml:"name"`
+				VolumeName            string `yaml:"volumeName"`
+				LonghornBackupName    string `yaml:"longhornBackupName"`
+				PersistentVolumeClaim struct {
+					Metadata objectMeta `yaml:"metadata"`
+				} `yaml:"persistentVolumeClaim"`
+				ReadyToUse *bool        `yaml:"readyToUse"`
+				Error      *objectError `yaml:"error"`
+			} `yaml:"volumeBackups"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type vmRestoreList struct {
+	Items []struct {
+		Metadata objectMeta `yaml:"metadata"`
+		Spec     struct {
+			Target struct {
+				Name string `yaml:"name"`
+			} `yaml:"target"`
+			VirtualMachineBackupName      string `yaml:"virtualMachineBackupName"`
+			VirtualMachineBackupNamespace string `yaml:"virtualMachineBackupNamespace"`
+			NewVM                         bool   `yaml:"newVM"`
+		} `yaml:"spec"`
+		Status struct {
+			Complete   *bool `yaml:"complete"`
+			Conditions []struct {
+				Type    string `yaml:"type"`
+				Status  string `yaml:"status"`
+				Message string `yaml:"message"`
+			} `yaml:"conditions"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type volumeSnapshotList struct {
+	Items []struct {
+		Metadata objectMeta `yaml:"metadata"`
+		Status   struct {
+			ReadyToUse                     *bool        `yaml:"readyToUse"`
+			BoundVolumeSnapshotContentName string       `yaml:"boundVolumeSnapshotContentName"`
+			Error                          *objectError `yaml:"error"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type volumeSnapshotContentList struct {
+	Items []struct {
+		Metadata objectMeta `yaml:"metadata"`
+		Status   struct {
+			ReadyToUse     *bool        `yaml:"readyToUse"`
+			SnapshotHandle string       `yaml:"snapshotHandle"`
+			Error          *objectError `yaml:"error"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type longhornBackupList struct {
+	Items []struct {
+		Metadata objectMeta `yaml:"metadata"`
+		Status   struct {
+			State string `yaml:"state"`
+			URL   string `yaml:"url"`
+			Error string `yaml:"error"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+// vmBackupObjects are the objects the backup inventory is joined from
+type vmBackupObjects struct {
+	// namespace the backups and restores were fetched from, empty for every namespace
+	namespace string
+	// target is the current backup-target setting, nil when it isn't set
+	target          *BackupTarget
+	backups         vmBackupList
+	restores        vmRestoreList
+	snapshots       volumeSnapshotList
+	contents        volumeSnapshotContentList
+	longhornBackups longhornBackupList
+}
+
+func (s *Server) handleGetVMBackups(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	namespace := r.URL.Query().Get("namespace")
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	writeResult := func(result VMBackupsResult) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+
+	exec, err := s.GetExecutor(name, versionID)
+	if err != nil {
+		writeResult(VMBackupsResult{VMs: []VMBackupInventory{}, Error: fmt.Sprintf("Failed to get executor: %v", err)})
+		return
+	}
+
+	objects, err := fetchVMBackupObjects(r.Context(), exec, namespace)
+	if err != nil {
+		writeResult(VMBackupsResult{VMs: []VMBackupInventory{}, Error: err.Error()})
+		return
+	}
+	if objects == nil {
+		writeResult(VMBackupsResult{VMs: []VMBackupInventory{}, Message: "Not a Harvester cluster, it has no VM backups"})
+		return
+	}
+	writeResult(resolveVMBackups(objects))
+}
+
+// fetchVMBackupObjects gets the VM backups and restores of namespace, or of every namespace when it
+// is empty, with the snapshots and Longhorn backups they refer to. It returns nil for clusters
+// without Harvester settings.
+func fetchVMBackupObjects(ctx context.Context, exec executor.Executor, namespace string) (*vmBackupObjects, error) {
+	var settings settingList
+	stderr, err := utils.DecodeKubectlYAML(ctx, exec, &settings, "get", "settings.harvesterhci.io", "-o", "yaml")
+	if strings.Contains(stderr, "the server doesn't have a resource type") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %v %s", err, strings.TrimSpace(stderr))
+	}
+
+	objects := &vmBackupObjects{namespace: namespace}
+	for _, item := range settings.Items {
+		if item.Metadata.Name != "backup-target" {
+			continue
+		}
+		objects.target, err = parseBackupTarget(item.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scope := []string{"-A"}
+	if namespace != "" {
+		scope = []string{"-n", namespace}
+	}
+	lists := []struct {
+		out  interface{}
+		args []string
+	}{
+		{&objects.backups, append([]string{"get", "virtualmachinebackups.harvesterhci.io"}, scope...)},
+		{&objects.restores, append([]string{"get", "virtualmachinerestores.harvesterhci.io"}, scope...)},
+		{&objects.snapshots, append([]string{"get", "volumesnapshots.snapshot.storage.k8s.io"}, scope...)},
+		{&objects.contents, []string{"get", "volumesnapshotcontents.snapshot.storage.k8s.io"}},
+		{&objects.longhornBackups, []string{"get", "backups.longhorn.io", "-A"}},
+	}
+	for _, list := range lists {
+		if err := decodeKubectlList(ctx, exec, list.out, append(list.args, "-o", "yaml")...); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", list.args[1], err)
+		}
+	}
+	return objects, nil
+}
+
+// parseBackupTarget reads the value of the backup-target setting, nil when no target is set
+func parseBackupTarget(value string) (*BackupTarget, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var target BackupTarget
+	if err := json.Unmarshal([]byte(value), &target); err != nil {
+		return nil, fmt.Errorf("failed to parse backup-target setting: %w", err)
+	}
+	if target.Endpoint == "" {
+		return nil, nil
+	}
+	return &target, nil
+}
+
+// sameBackupTarget reports whether a backup was stored on the target of the setting
+func sameBackupTarget(backup, setting *BackupTarget) bool {
+	if setting == nil {
+		return false
+	}
+	return strings.TrimSuffix(backup.Endpoint, "/") == strings.TrimSuffix(setting.Endpoint, "/") &&
+		backup.BucketName == setting.BucketName &&
+		backup.BucketRegion == setting.BucketRegion
+}
+
+// resolveVMBackups groups the backups and restores by VM and follows every volume backup to its volume
+// snapshot, snapshot content and Longhorn backup
+func resolveVMBackups(objects *vmBackupObjects) VMBackupsResult {
+	result := VMBackupsResult{Harvester: true, BackupTarget: objects.target, VMs: []VMBackupInventory{}}
+
+	inventories := map[string]*VMBackupInventory{}
+	inventory := func(namespace, vmName string) *VMBackupInventory {
+		key := namespace + "/" + vmName
+		if inventories[key] == nil {
+			inventories[key] = &VMBackupInventory{
+				Namespace: namespace,
+				VMName:    vmName,
+				Backups:   []VMBackupSummary{},
+				Restores:  []VMRestoreSummary{},
+			}
+		}
+		return inventories[key]
+	}
+
+	for _, item := range objects.backups.Items {
+		backup := VMBackupSummary{
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Type:      item.Spec.Type,
+			CreatedAt: item.Metadata.CreationTimestamp,
+			Volumes:   []VMVolumeBackup{},
+			Problems:  []string{},
+		}
+		// Backups taken before snapshots were added have no type
+		if backup.Type == "" {
+			backup.Type = "backup"
+		}
+		switch {
+		case item.Status.Error != nil && item.Status.Error.Message != "":
+			backup.State = "Error"
+			backup.Error = item.Status.Error.Message
+			backup.Problems = append(backup.Problems, fmt.Sprintf("%s %s failed: %s", backup.Type, backup.Name, backup.Error))
+		case item.Status.ReadyToUse != nil && *item.Status.ReadyToUse:
+			backup.State = "Ready"
+		default:
+			backup.State = "InProgress"
+			backup.Problems = append(backup.Problems, fmt.Sprintf("%s %s isn't complete", backup.Type, backup.Name))
+		}
+
+		if target := item.Status.BackupTarget; target != nil && target.Endpoint != "" {
+			backup.Target = &BackupTarget{Endpoint: target.Endpoint, BucketName: target.BucketName, BucketRegion: target.BucketRegion}
+		}
+		if backup.Type == "backup" && backup.Target != nil && !sameBackupTarget(backup.Target, objects.target) {
+			backup.TargetMismatch = true
+			backup.Problems = append(backup.Problems, fmt.Sprintf("backup %s is on target %s, not on the current backup target", backup.Name, backup.Target.Endpoint))
+		}
+
+		for _, vb := range item.Status.VolumeBackups {
+			volume := VMVolumeBackup{
+				VolumeName:     vb.VolumeName,
+				ClaimName:      vb.PersistentVolumeClaim.Metadata.Name,
+				Ready:          vb.ReadyToUse != nil && *vb.ReadyToUse,
+				VolumeSnapshot: vb.Name,
+				LonghornBackup: vb.LonghornBackupName,
+			}
+			if vb.Error != nil {
+				volume.Error = vb.Error.Message
+			}
+			objects.followVolumeBackup(&backup, &volume)
+			backup.Volumes = append(backup.Volumes, volume)
+		}
+		inv := inventory(item.Metadata.Namespace, item.Spec.Source.Name)
+		inv.Backups = append(inv.Backups, backup)
+	}
+
+	for _, item := range objects.restores.Items {
+		restore := VMRestoreSummary{
+			Name:            item.Metadata.Name,
+			Namespace:       item.Metadata.Namespace,
+			BackupName:      item.Spec.VirtualMachineBackupName,
+			BackupNamespace: item.Spec.VirtualMachineBackupNamespace,
+			NewVM:           item.Spec.NewVM,
+			Complete:        item.Status.Complete != nil && *item.Status.Complete,
+			CreatedAt:       item.Metadata.CreationTimestamp,
+			Problems:        []string{},
+		}
+		if restore.BackupNamespace == "" {
+			restore.BackupNamespace = restore.Namespace
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Failure" && condition.Status == "True" {
+				restore.Problems = append(restore.Problems, fmt.Sprintf("restore %s failed: %s", restore.Name, condition.Message))
+			}
+		}
+		if !restore.Complete && len(restore.Problems) == 0 {
+			restore.Problems = append(restore.Problems, fmt.Sprintf("restore %s isn't complete", restore.Name))
+		}
+		// Backups of other namespaces weren't fetched
+		if (objects.namespace == "" || objects.namespace == restore.BackupNamespace) && !objects.hasBackup(restore.BackupNamespace, restore.BackupName) {
+			restore.Problems = append(restore.Problems, fmt.Sprintf("restore %s is from backup %s/%s which doesn't exist", restore.Name, restore.BackupNamespace, restore.BackupName))
+		}
+		inv := inventory(item.Metadata.Namespace, item.Spec.Target.Name)
+		inv.Restores = append(inv.Restores, restore)
+	}
+
+	for _, inv := range inventories {
+		sort.Slice(inv.Backups, func(i, j int) bool { return inv.Backups[i].CreatedAt < inv.Backups[j].CreatedAt })
+		sort.Slice(inv.Restores, func(i, j int) bool { return inv.Restores[i].CreatedAt < inv.Restores[j].CreatedAt })
+		result.VMs = append(result.VMs, *inv)
+	}
+	sort.Slice(result.VMs, func(i, j int) bool {
+		a, b := result.VMs[i], result.VMs[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.VMName < b.VMName
+	})
+	return result
+}
+
+// followVolumeBackup fills in the volume snapshot, its content and the Longhorn backup of a volume
+func (o *vmBackupObjects) followVolumeBackup(backup *VMBackupSummary, volume *VMVolumeBackup) {
+	if volume.Error != "" {
+		backup.Problems = append(backup.Problems, fmt.Sprintf("volume %s failed: %s", volume.VolumeName, volume.Error))
+	}
+
+	found := false
+	for _, snapshot := range o.snapshots.Items {
+		if snapshot.Metadata.Namespace != backup.Namespace || snapshot.Metadata.Name != volume.VolumeSnapshot {
+			continue
+		}
+		found = true
+		volume.VolumeSnapshotContent = snapshot.Status.BoundVolumeSnapshotContentName
+		if snapshot.Status.Error != nil && snapshot.Status.Error.Message != "" {
+			backup.Problems = append(backup.Problems, fmt.Sprintf("volume snapshot %s failed: %s", volume.VolumeSnapshot, snapshot.Status.Error.Message))
+		}
+	}
+	// Harvester removes the snapshots of backups that are complete, their data is on the backup target
+	if !found && backup.Type == "snapshot" && volume.VolumeSnapshot != "" {
+		backup.Problems = append(backup.Problems, fmt.Sprintf("volume snapshot %s doesn't exist", volume.VolumeSnapshot))
+	}
+
+	for _, content := range o.contents.Items {
+		if volume.VolumeSnapshotContent == "" || content.Metadata.Name != volume.VolumeSnapshotContent {
+			continue
+		}
+		// Longhorn backups are referred to as bak://<volume>/<backup>
+		if volume.LonghornBackup == "" && strings.HasPrefix(content.Status.SnapshotHandle, "bak://") {
+			volume.LonghornBackup = content.Status.SnapshotHandle[strings.LastIndex(content.Status.SnapshotHandle, "/")+1:]
+		}
+		if content.Status.Error != nil && content.Status.Error.Message != "" {
+			backup.Problems = append(backup.Problems, fmt.Sprintf("volume snapshot content %s failed: %s", content.Metadata.Name, content.Status.Error.Message))
+		}
+	}
+
+	if volume.LonghornBackup == "" || backup.Type != "backup" {
+		return
+	}
+	for _, lb := range o.longhornBackups.Items {
+		if lb.Metadata.Name != volume.LonghornBackup {
+			continue
+		}
+		volume.LonghornBackupState = lb.Status.State
+		volume.LonghornBackupURL = lb.Status.URL
+		if lb.Status.Error != "" {
+			backup.Problems = append(backup.Problems, fmt.Sprintf("Longhorn backup %s failed: %s", lb.Metadata.Name, lb.Status.Error))
+		} else if lb.Status.State != "Completed" {
+			backup.Problems = append(backup.Problems, fmt.Sprintf("Longhorn backup %s is %s", lb.Metadata.Name, orUnknown(lb.Status.State)))
+		}
+		return
+	}
+	// Longhorn only lists the backups of the current target
+	backup.Problems = append(backup.Problems, fmt.Sprintf("Longhorn backup %s doesn't exist", volume.LonghornBackup))
+}
+
+func (o *vmBackupObjects) hasBackup(namespace, name string) bool {
+	for _, item := range o.backups.Items {
+		if item.Metadata.Namespace == namespace && item.Metadata.Name == name {
+			return true
+		}
+	}
+	return false
+}
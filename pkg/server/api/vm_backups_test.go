@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The backup target moved from the old bucket to the new one after vm1-backup-old was taken
+const s3BackupSettings = `items:
+- metadata:
+    name: backup-target
+  default: ""
+  value: '{"type":"s3","endpoint":"https://s3.us-west-2.amazonaws.com","accessKeyId":"[REDACTED]","secretAccessKey":"[REDACTED]","bucketName":"harvester-backups","bucketRegion":"us-west-2"}'
+- metadata:
+    name: overcommit-config
+  default: '{"cpu":1600,"memory":150,"storage":200}'
+`
+
+const s3VMBackups = `items:
+- metadata:
+    name: vm1-backup-old
+    namespace: default
+    creationTimestamp: "2024-11-01T08:00:00Z"
+  spec:
+    type: backup
+    source:
+      name: vm1
+  status:
+    readyToUse: true
+    backupTarget:
+      endpoint: https://s3.us-west-2.amazonaws.com
+      bucketName: harvester-backups-old
+      bucketRegion: us-west-2
+    volumeBackups:
+    - name: default-vm1-disk-0-old
+      volumeName: disk-0
+      longhornBackupName: backup-0a1b
+      persistentVolumeClaim:
+        metadata:
+          name: vm1-disk-0-abcde
+      readyToUse: true
+- metadata:
+    name: vm1-backup-new
+    namespace: default
+    creationTimestamp: "2024-11-18T08:00:00Z"
+  spec:
+    type: backup
+    source:
+      name: vm1
+  status:
+    readyToUse: false
+    backupTarget:
+      endpoint: https://s3.us-west-2.amazonaws.com/
+      bucketName: harvester-backups
+      bucketRegion: us-west-2
+    volumeBackups:
+    - name: default-vm1-disk-0-new
+      volumeName: disk-0
+      persistentVolumeClaim:
+        metadata:
+          name: vm1-disk-0-abcde
+      readyToUse: false
+- metadata:
+    name: vm2-snapshot
+    namespace: default
+    creationTimestamp: "2024-11-10T08:00:00Z"
+  spec:
+    type: snapshot
+    source:
+      name: vm2
+  status:
+    readyToUse: false
+    error:
+      message: 'VolumeSnapshot default-vm2-disk-0 failed: snapshot class not found'
+    volumeBackups:
+    - name: default-vm2-disk-0
+      volumeName: disk-0
+      persistentVolumeClaim:
+        metadata:
+          name: vm2-disk-0-xyz
+`
+
+const s3VMRestores = `items:
+- metadata:
+    name: restore-vm1
+    namespace: default
+    creationTimestamp: "2024-11-02T08:00:00Z"
+  spec:
+    target:
+      name: vm1
+    virtualMachineBackupName: vm1-backup-old
+    virtualMachineBackupNamespace: default
+  status:
+    complete: true
+- metadata:
+    name: restore-vm3
+    namespace: default
+    creationTimestamp: "2024-11-19T08:00:00Z"
+  spec:
+    target:
+      name: vm3
+    virtualMachineBackupName: vm1-backup-deleted
+    newVM: true
+  status:
+    complete: false
+    conditions:
+    - type: Failure
+      status: "True"
+      message: backup target is not reachable
+`
+
+const s3VolumeSnapshots = `items:
+- metadata:
+    name: default-vm1-disk-0-new
+    namespace: default
+  status:
+    readyToUse: false
+    boundVolumeSnapshotContentName: snapcontent-new
+`
+
+const s3VolumeSnapshotContents = `items:
+- metadata:
+    name: snapcontent-new
+  status:
+    readyToUse: false
+    snapshotHandle: bak://pvc-1111/backup-9f8e
+`
+
+const s3LonghornBackups = `items:
+- metadata:
+    name: backup-9f8e
+    namespace: longhorn-system
+  status:
+    state: InProgress
+    url: s3://harvester-backups@us-west-2/?backup=backup-9f8e&volume=pvc-1111
+`
+
+func s3BackupExecutor() *scriptedExecutor {
+	return &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"settings.harvesterhci.io":                       {stdout: s3BackupSettings},
+		"virtualmachinebackups.harvesterhci.io":          {stdout: s3VMBackups},
+		"virtualmachinerestores.harvesterhci.io":         {stdout: s3VMRestores},
+		"volumesnapshots.snapshot.storage.k8s.io":        {stdout: s3VolumeSnapshots},
+		"volumesnapshotcontents.snapshot.storage.k8s.io": {stdout: s3VolumeSnapshotContents},
+		"backups.longhorn.io":                            {stdout: s3LonghornBackups},
+	}}
+}
+
+func Test_ResolveVMBackups(t *testing.T) {
+	assert := require.New(t)
+	objects, err := fetchVMBackupObjects(context.Background(), s3BackupExecutor(), "")
+	assert.NoError(err)
+
+	result := resolveVMBackups(objects)
+	assert.True(result.Harvester)
+	assert.Equal(&BackupTarget{Type: "s3", Endpoint: "https://s3.us-west-2.amazonaws.com", BucketName: "harvester-backups", BucketRegion: "us-west-2"}, result.BackupTarget)
+	assert.Len(result.VMs, 3)
+
+	vm1 := result.VMs[0]
+	assert.Equal("vm1", vm1.VMName)
+	assert.Len(vm1.Backups, 2)
+
+	// taken before the target moved, Longhorn no longer lists its backup
+	old := vm1.Backups[0]
+	assert.Equal("vm1-backup-old", old.Name)
+	assert.Equal("Ready", old.State)
+	assert.True(old.TargetMismatch)
+	assert.Equal([]string{
+		"backup vm1-backup-old is on target https://s3.us-west-2.amazonaws.com, not on the current backup target",
+		"Longhorn backup backup-0a1b doesn't exist",
+	}, old.Problems)
+
+	current := vm1.Backups[1]
+	assert.Equal("InProgress", current.State)
+	assert.False(current.TargetMismatch, "a trailing slash is the same endpoint")
+	assert.Equal([]VMVolumeBackup{{
+		VolumeName:            "disk-0",
+		ClaimName:             "vm1-disk-0-abcde",
+		VolumeSnapshot:        "default-vm1-disk-0-new",
+		VolumeSnapshotContent: "snapcontent-new",
+		LonghornBackup:        "backup-9f8e",
+		LonghornBackupState:   "InProgress",
+		LonghornBackupURL:     "s3://harvester-backups@us-west-2/?backup=backup-9f8e&volume=pvc-1111",
+	}}, current.Volumes)
+	assert.Equal([]string{
+		"backup vm1-backup-new isn't complete",
+		"Longhorn backup backup-9f8e is InProgress",
+	}, current.Problems)
+	assert.Equal([]VMRestoreSummary{{
+		Name:            "restore-vm1",
+		Namespace:       "default",
+		BackupName:      "vm1-backup-old",
+		BackupNamespace: "default",
+		Complete:        true,
+		CreatedAt:       "2024-11-02T08:00:00Z",
+		Problems:        []string{},
+	}}, vm1.Restores)
+
+	// snapshots stay on the cluster, the target doesn't apply
+	vm2 := result.VMs[1]
+	snapshot := vm2.Backups[0]
+	assert.Equal("Error", snapshot.State)
+	assert.False(snapshot.TargetMismatch)
+	assert.Equal([]string{
+		"snapshot vm2-snapshot failed: VolumeSnapshot default-vm2-disk-0 failed: snapshot class not found",
+		"volume snapshot default-vm2-disk-0 doesn't exist",
+	}, snapshot.Problems)
+
+	// restored into a new VM from a backup that was deleted since
+	vm3 := result.VMs[2]
+	assert.Empty(vm3.Backups)
+	assert.Equal([]string{
+		"restore restore-vm3 failed: backup target is not reachable",
+		"restore restore-vm3 is from backup default/vm1-backup-deleted which doesn't exist",
+	}, vm3.Restores[0].Problems)
+}
+
+func Test_ResolveVMBackupsInNamespace(t *testing.T) {
+	assert := require.New(t)
+	exec := s3BackupExecutor()
+	exec.outputs["virtualmachinebackups.harvesterhci.io"] = kubectlOutput{stdout: "items: []\n"}
+	exec.outputs["virtualmachinerestores.harvesterhci.io"] = kubectlOutput{stdout: `items:
+- metadata:
+    name: restore-vm1
+    namespace: team-a
+  spec:
+    target:
+      name: vm1
+    virtualMachineBackupName: vm1-backup
+    virtualMachineBackupNamespace: default
+  status:
+    complete: true
+`}
+	objects, err := fetchVMBackupObjects(context.Background(), exec, "team-a")
+	assert.NoError(err)
+
+	// the backup is in a namespace that wasn't fetched
+	result := resolveVMBackups(objects)
+	assert.Empty(result.VMs[0].Restores[0].Problems)
+}
+
+func Test_FetchVMBackupObjectsWithoutHarvester(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"settings.harvesterhci.io": {stderr: `error: the server doesn't have a resource type "settings"`},
+	}}
+	objects, err := fetchVMBackupObjects(context.Background(), exec, "")
+	assert.NoError(err)
+	assert.Nil(objects)
+
+	// backups of a cluster without a target all point elsewhere
+	exec = s3BackupExecutor()
+	exec.outputs["settings.harvesterhci.io"] = kubectlOutput{stdout: "items:\n- metadata:\n    name: backup-target\n  value: ''\n"}
+	objects, err = fetchVMBackupObjects(context.Background(), exec, "")
+	assert.NoError(err)
+	result := resolveVMBackups(objects)
+	assert.Nil(result.BackupTarget)
+	assert.True(result.VMs[0].Backups[1].TargetMismatch)
+}
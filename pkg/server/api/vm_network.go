@@ -0,0 +1,368 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// NetworkAttachment is a NetworkAttachmentDefinition with the parts of its CNI config that matter
+// when looking for a VM's network
+type NetworkAttachment struct {
+	Namespace      string `json:"namespace"`
+	Name           string `json:"name"`
+	Type           string `json:"type"` // CNI plugin, e.g. bridge or kube-ovn
+	Bridge         string `json:"bridge,omitempty"`
+	VLAN           int    `json:"vlan,omitempty"`
+	ClusterNetwork string `json:"clusterNetwork,omitempty"`
+	IPAM           string `json:"ipam,omitempty"`     // IPAM plugin, e.g. whereabouts
+	Provider       string `json:"provider,omitempty"` // kube-ovn provider
+	CIDR           string `json:"cidr,omitempty"`
+	Gateway        string `json:"gateway,omitempty"`
+}
+
+// VMInterface is an interface of a VM and the network it is attached to
+type VMInterface struct {
+	Name       string             `json:"name"`
+	Network    string             `json:"network"` // "pod" or the namespace/name of a multus network
+	Binding    string             `json:"binding,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	MAC        string             `json:"mac,omitempty"`
+	IPs        []string           `json:"ips"`
+	Attachment *NetworkAttachment `json:"attachment,omitempty"`
+	Problems   []string           `json:"problems"`
+}
+
+type VMNetworkResult struct {
+	VMName string `json:"vmName"`
+	// Node is where the VM runs, empty when it isn't running
+	Node string `json:"node,omitempty"`
+	// NodeNetwork are the network labels and annotations of the node, e.g. its uplinks and kube-ovn addresses
+	NodeNetwork map[string]string `json:"nodeNetwork,omitempty"`
+	Interfaces  []VMInterface     `json:"interfaces"`
+	Error       string            `json:"error,omitempty"`
+}
+
+type vmNetworkSpec struct {
+	Spec struct {
+		Template struct {
+			Spec struct {
+				Domain struct {
+					Devices struct {
+						Interfaces []map[string]interface{} `yaml:"interfaces"`
+					} `yaml:"devices"`
+				} `yaml:"domain"`
+				Networks []struct {
+					Name   string    `yaml:"name"`
+					Pod    *struct{} `yaml:"pod"`
+					Multus *struct {
+						NetworkName string `yaml:"networkName"`
+					} `yaml:"multus"`
+				} `yaml:"networks"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+type vmiStatus struct {
+	Status struct {
+		NodeName   string `yaml:"nodeName"`
+		Interfaces []struct {
+			Name        string   `yaml:"name"`
+			MAC         string   `yaml:"mac"`
+			IPAddress   string   `yaml:"ipAddress"`
+			IPAddresses []string `yaml:"ipAddresses"`
+		} `yaml:"interfaces"`
+	} `yaml:"status"`
+}
+
+type nadList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `yaml:"name"`
+			Namespace   string            `yaml:"namespace"`
+			Labels      map[string]string `yaml:"labels"`
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Config string `yaml:"config"`
+		} `yaml:"spec"`
+	} `yaml:"items"`
+}
+
+type annotatedList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `yaml:"name"`
+			Labels      map[string]string `yaml:"labels"`
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+	} `yaml:"items"`
+}
+
+// vmNetworkObjects are the objects the network of a VM is resolved from, the VMI, launcher pods
+// and node are missing for stopped VMs
+type vmNetworkObjects struct {
+	namespace string
+	vm        vmNetworkSpec
+	vmi       *vmiStatus
+	nads      nadList
+	pods      annotatedList
+	nodes     annotatedList
+}
+
+// networkStatus is an entry of the k8s.v1.cni.cncf.io/network-status annotation multus sets on pods
+type networkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	MAC       string   `json:"mac"`
+}
+
+func (s *Server) handleGetVMNetwork(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+	namespace := r.URL.Query().Get("namespace")
+	vmName := r.URL.Query().Get("vmName")
+	if namespace == "" || vmName == "" {
+		http.Error(w, "namespace and vmName are required", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	writeResult := func(result VMNetworkResult) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+
+	exec, err := s.GetExecutor(name, versionID)
+	if err != nil {
+		writeResult(VMNetworkResult{VMName: vmName, Error: fmt.Sprintf("Failed to get executor: %v", err)})
+		return
+	}
+
+	objects, err := fetchVMNetworkObjects(r.Context(), exec, namespace, vmName)
+	if err != nil {
+		writeResult(VMNetworkResult{VMName: vmName, Error: err.Error()})
+		return
+	}
+	result := resolveVMNetwork(objects)
+	result.VMName = vmName
+	writeResult(result)
+}
+
+func fetchVMNetworkObjects(ctx context.Context, exec executor.Executor, namespace, vmName string) (*vmNetworkObjects, error) {
+	objects := &vmNetworkObjects{namespace: namespace}
+
+	stderr, err := utils.DecodeKubectlYAML(ctx, exec, &objects.vm, "get", "virtualmachines.kubevirt.io", vmName, "-n", namespace, "-o", "yaml")
+	if err != nil || stderr != "" {
+		return nil, fmt.Errorf("VirtualMachine '%s' not found in namespace '%s': %s", vmName, namespace, strings.TrimSpace(stderr))
+	}
+
+	// Stopped VMs have no instance, their interfaces are still listed from the VM spec
+	var vmi vmiStatus
+	stderr, err = utils.DecodeKubectlYAML(ctx, exec, &vmi, "get", "virtualmachineinstances.kubevirt.io", vmName, "-n", namespace, "-o", "yaml")
+	switch {
+	case err == nil && stderr == "":
+		objects.vmi = &vmi
+	case !strings.Contains(stderr, "NotFound"):
+		return nil, fmt.Errorf("failed to get VirtualMachineInstance: %v %s", err, strings.TrimSpace(stderr))
+	}
+
+	if err := decodeKubectlList(ctx, exec, &objects.nads, "get", "network-attachment-definitions.k8s.cni.cncf.io", "-A", "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get network attachment definitions: %w", err)
+	}
+	if err := decodeKubectlList(ctx, exec, &objects.pods, "get", "pods", "-n", namespace, "-l", "harvesterhci.io/vmName="+vmName, "-o", "yaml"); err != nil {
+		return nil, fmt.Errorf("failed to get pods: %w", err)
+	}
+	if objects.vmi != nil && objects.vmi.Status.NodeName != "" {
+		if err := decodeKubectlList(ctx, exec, &objects.nodes, "get", "nodes", "--field-selector", "metadata.name="+objects.vmi.Status.NodeName, "-o", "yaml"); err != nil {
+			return nil, fmt.Errorf("failed to get node: %w", err)
+		}
+	}
+	return objects, nil
+}
+
+// resolveVMNetwork joins the interfaces of the VM spec with their networks, the addresses reported
+// by the VMI and the network status annotations of the launcher pods
+func resolveVMNetwork(objects *vmNetworkObjects) VMNetworkResult {
+	result := VMNetworkResult{Interfaces: []VMInterface{}}
+
+	// Launcher pods of earlier runs or migrations are ignored, only the newest one is running the VM
+	var podAnnotations map[string]string
+	for _, pod := range objects.pods.Items {
+		if podAnnotations == nil || pod.Metadata.Annotations["k8s.v1.cni.cncf.io/network-status"] != "" {
+			podAnnotations = pod.Metadata.Annotations
+		}
+	}
+	var statuses []networkStatus
+	if raw := podAnnotations["k8s.v1.cni.cncf.io/network-status"]; raw != "" {
+		_ = json.Unmarshal([]byte(raw), &statuses)
+	}
+
+	interfaces := map[string]map[string]interface{}{}
+	for _, iface := range objects.vm.Spec.Template.Spec.Domain.Devices.Interfaces {
+		name, _ := iface["name"].(string)
+		interfaces[name] = iface
+	}
+
+	for _, network := range objects.vm.Spec.Template.Spec.Networks {
+		iface := VMInterface{Name: network.Name, IPs: []string{}, Problems: []string{}}
+		if spec, ok := interfaces[network.Name]; ok {
+			iface.Model, _ = spec["model"].(string)
+			iface.MAC, _ = spec["macAddress"].(string)
+			for _, binding := range []string{"bridge", "masquerade", "sriov", "slirp", "passt"} {
+				if _, ok := spec[binding]; ok {
+					iface.Binding = binding
+				}
+			}
+		}
+
+		switch {
+		case network.Multus != nil:
+			iface.Network = qualifyNetworkName(network.Multus.NetworkName, objects.namespace)
+			iface.Attachment = objects.findAttachment(iface.Network)
+			if iface.Attachment == nil {
+				iface.Problems = append(iface.Problems, fmt.Sprintf("network attachment definition %s doesn't exist", iface.Network))
+			}
+		default:
+			iface.Network = "pod"
+		}
+
+		if objects.vmi != nil {
+			for _, status := range objects.vmi.Status.Interfaces {
+				if status.Name != network.Name {
+					continue
+				}
+				if status.MAC != "" {
+					iface.MAC = status.MAC
+				}
+				iface.IPs = appendIPs(iface.IPs, status.IPAddress)
+				iface.IPs = appendIPs(iface.IPs, status.IPAddresses...)
+			}
+		}
+		for _, status := range statuses {
+			if iface.Network != "pod" && qualifyNetworkName(status.Name, objects.namespace) == iface.Network {
+				iface.IPs = appendIPs(iface.IPs, status.IPs...)
+			}
+		}
+		// kube-ovn records the addresses it allocated for a provider on the launcher pod
+		if iface.Attachment != nil && iface.Attachment.Provider != "" {
+			iface.IPs = appendIPs(iface.IPs, strings.Split(podAnnotations[iface.Attachment.Provider+".kubernetes.io/ip_address"], ",")...)
+		}
+		result.Interfaces = append(result.Interfaces, iface)
+	}
+
+	if objects.vmi != nil {
+		result.Node = objects.vmi.Status.NodeName
+	}
+	for _, node := range objects.nodes.Items {
+		if node.Metadata.Name != result.Node {
+			continue
+		}
+		result.NodeNetwork = map[string]string{}
+		for _, values := range []map[string]string{node.Metadata.Labels, node.Metadata.Annotations} {
+			for key, value := range values {
+				if isNodeNetworkKey(key) {
+					result.NodeNetwork[key] = value
+				}
+			}
+		}
+	}
+	return result
+}
+
+// findAttachment returns the network attachment definition of a namespace/name network
+func (o *vmNetworkObjects) findAttachment(network string) *NetworkAttachment {
+	for _, nad := range o.nads.Items {
+		if nad.Metadata.Namespace+"/"+nad.Metadata.Name != network {
+			continue
+		}
+		attachment := &NetworkAttachment{
+			Namespace:      nad.Metadata.Namespace,
+			Name:           nad.Metadata.Name,
+			ClusterNetwork: nad.Metadata.Labels["network.harvesterhci.io/clusternetwork"],
+		}
+		var config struct {
+			Type     string `json:"type"`
+			Bridge   string `json:"bridge"`
+			VLAN     int    `json:"vlan"`
+			Provider string `json:"provider"`
+			IPAM     struct {
+				Type    string `json:"type"`
+				Range   string `json:"range"`
+				Subnet  string `json:"subnet"`
+				Gateway string `json:"gateway"`
+			} `json:"ipam"`
+		}
+		// A config that doesn't parse still identifies the attachment
+		if err := json.Unmarshal([]byte(nad.Spec.Config), &config); err == nil {
+			attachment.Type = config.Type
+			attachment.Bridge = config.Bridge
+			attachment.VLAN = config.VLAN
+			attachment.Provider = config.Provider
+			attachment.IPAM = config.IPAM.Type
+			attachment.CIDR = config.IPAM.Range
+			if attachment.CIDR == "" {
+				attachment.CIDR = config.IPAM.Subnet
+			}
+			attachment.Gateway = config.IPAM.Gateway
+		}
+		// Harvester keeps the route of VLAN networks in an annotation
+		var route struct {
+			CIDR    string `json:"cidr"`
+			Gateway string `json:"gateway"`
+		}
+		if raw := nad.Metadata.Annotations["network.harvesterhci.io/route"]; raw != "" && json.Unmarshal([]byte(raw), &route) == nil {
+			if attachment.CIDR == "" {
+				attachment.CIDR = route.CIDR
+			}
+			if attachment.Gateway == "" {
+				attachment.Gateway = route.Gateway
+			}
+		}
+		return attachment
+	}
+	return nil
+}
+
+// qualifyNetworkName adds the namespace of the VM to multus network names without one
+func qualifyNetworkName(network, namespace string) string {
+	if strings.Contains(network, "/") {
+		return network
+	}
+	return namespace + "/" + network
+}
+
+// isNodeNetworkKey reports whether a node label or annotation describes its networking
+func isNodeNetworkKey(key string) bool {
+	return strings.HasPrefix(key, "network.harvesterhci.io/") || strings.HasPrefix(key, "ovn.kubernetes.io/")
+}
+
+// appendIPs adds the non-empty addresses not in ips yet, keeping them sorted
+func appendIPs(ips []string, add ...string) []string {
+	for _, ip := range add {
+		ip = strings.TrimSpace(ip)
+		if ip != "" && !slices.Contains(ips, ip) {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
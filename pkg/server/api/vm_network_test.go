@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const networkVM = `apiVersion: kubevirt.io/v1
+kind: VirtualMachine
+metadata:
+  name: vm1
+  namespace: default
+spec:
+  template:
+    spec:
+      domain:
+        devices:
+          interfaces:
+          - name: default
+            model: virtio
+            masquerade: {}
+          - name: vlan100
+            model: virtio
+            bridge: {}
+            macAddress: 52:54:00:aa:bb:cc
+      networks:
+      - name: default
+        pod: {}
+      - name: vlan100
+        multus:
+          networkName: vlan100
+`
+
+const networkVMI = `apiVersion: kubevirt.io/v1
+kind: VirtualMachineInstance
+metadata:
+  name: vm1
+  namespace: default
+status:
+  nodeName: node-1
+  interfaces:
+  - name: default
+    mac: 52:54:00:11:22:33
+    ipAddress: 10.52.0.20
+    ipAddresses:
+    - 10.52.0.20
+  - name: vlan100
+    mac: 52:54:00:aa:bb:cc
+    ipAddress: 172.16.0.15
+`
+
+const vlanNADs = `items:
+- metadata:
+    name: vlan100
+    namespace: default
+    labels:
+      network.harvesterhci.io/clusternetwork: data
+      network.harvesterhci.io/type: L2VlanNetwork
+    annotations:
+      network.harvesterhci.io/route: '{"mode":"auto","cidr":"172.16.0.0/24","gateway":"172.16.0.1","connectivity":"true"}'
+  spec:
+    config: '{"cniVersion":"0.3.1","name":"vlan100","type":"bridge","bridge":"data-br","promiscMode":true,"vlan":100,"ipam":{}}'
+`
+
+const vlanPods = `items:
+- metadata:
+    name: virt-launcher-vm1-abcde
+    annotations:
+      k8s.v1.cni.cncf.io/network-status: '[{"name":"k8s-pod-network","interface":"eth0","ips":["10.52.0.20"],"default":true},{"name":"default/vlan100","interface":"net1","mac":"52:54:00:aa:bb:cc","ips":["172.16.0.16"]}]'
+`
+
+const vlanNodes = `items:
+- metadata:
+    name: node-1
+    labels:
+      kubernetes.io/hostname: node-1
+      network.harvesterhci.io/data: "true"
+    annotations:
+      network.harvesterhci.io/matched-nodes: node-1
+      rke2.io/hostname: node-1
+`
+
+func Test_ResolveVMNetworkVLAN(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"virtualmachines.kubevirt.io":                    {stdout: networkVM},
+		"virtualmachineinstances.kubevirt.io":            {stdout: networkVMI},
+		"network-attachment-definitions.k8s.cni.cncf.io": {stdout: vlanNADs},
+		"pods":  {stdout: vlanPods},
+		"nodes": {stdout: vlanNodes},
+	}}
+	objects, err := fetchVMNetworkObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+
+	result := resolveVMNetwork(objects)
+	assert.Equal("node-1", result.Node)
+	assert.Equal(map[string]string{
+		"network.harvesterhci.io/data":          "true",
+		"network.harvesterhci.io/matched-nodes": "node-1",
+	}, result.NodeNetwork)
+	assert.Len(result.Interfaces, 2)
+
+	assert.Equal(VMInterface{
+		Name:     "default",
+		Network:  "pod",
+		Binding:  "masquerade",
+		Model:    "virtio",
+		MAC:      "52:54:00:11:22:33",
+		IPs:      []string{"10.52.0.20"},
+		Problems: []string{},
+	}, result.Interfaces[0])
+
+	vlan := result.Interfaces[1]
+	assert.Equal("default/vlan100", vlan.Network)
+	assert.Equal("bridge", vlan.Binding)
+	assert.Equal("52:54:00:aa:bb:cc", vlan.MAC)
+	// addresses of the guest agent and multus are merged
+	assert.Equal([]string{"172.16.0.15", "172.16.0.16"}, vlan.IPs)
+	assert.Equal(&NetworkAttachment{
+		Namespace:      "default",
+		Name:           "vlan100",
+		Type:           "bridge",
+		Bridge:         "data-br",
+		VLAN:           100,
+		ClusterNetwork: "data",
+		CIDR:           "172.16.0.0/24",
+		Gateway:        "172.16.0.1",
+	}, vlan.Attachment)
+	assert.Empty(vlan.Problems)
+}
+
+const ovnNADs = `items:
+- metadata:
+    name: vlan100
+    namespace: default
+  spec:
+    config: '{"cniVersion":"0.3.0","type":"kube-ovn","server_socket":"/run/openvswitch/kube-ovn-daemon.sock","provider":"vlan100.default.ovn"}'
+`
+
+const ovnPods = `items:
+- metadata:
+    name: virt-launcher-vm1-abcde
+    annotations:
+      ovn.kubernetes.io/ip_address: 10.16.0.9
+      vlan100.default.ovn.kubernetes.io/ip_address: 192.168.10.5
+      vlan100.default.ovn.kubernetes.io/logical_switch: vlan100-subnet
+`
+
+const ovnNodes = `items:
+- metadata:
+    name: node-1
+    annotations:
+      ovn.kubernetes.io/ip_address: 100.64.0.2
+      ovn.kubernetes.io/gateway: 100.64.0.1
+      ovn.kubernetes.io/cidr: 100.64.0.0/16
+`
+
+func Test_ResolveVMNetworkKubeOVN(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"virtualmachines.kubevirt.io":                    {stdout: networkVM},
+		"virtualmachineinstances.kubevirt.io":            {stdout: "status:\n  nodeName: node-1\n"},
+		"network-attachment-definitions.k8s.cni.cncf.io": {stdout: ovnNADs},
+		"pods":  {stdout: ovnPods},
+		"nodes": {stdout: ovnNodes},
+	}}
+	objects, err := fetchVMNetworkObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+
+	result := resolveVMNetwork(objects)
+	assert.Equal(map[string]string{
+		"ovn.kubernetes.io/ip_address": "100.64.0.2",
+		"ovn.kubernetes.io/gateway":    "100.64.0.1",
+		"ovn.kubernetes.io/cidr":       "100.64.0.0/16",
+	}, result.NodeNetwork)
+
+	vlan := result.Interfaces[1]
+	assert.Equal("kube-ovn", vlan.Attachment.Type)
+	assert.Equal("vlan100.default.ovn", vlan.Attachment.Provider)
+	assert.Equal([]string{"192.168.10.5"}, vlan.IPs)
+}
+
+// flatNADs is a network of the bridge every node shared before cluster networks existed
+const flatNADs = `items:
+- metadata:
+    name: vlan1
+    namespace: default
+    labels:
+      networks.harvesterhci.io/type: L2VlanNetwork
+  spec:
+    config: '{"cniVersion":"0.3.1","name":"vlan1","type":"bridge","bridge":"harvester-br0","promiscMode":true,"vlan":1,"ipam":{}}'
+`
+
+func Test_ResolveVMNetworkFlat(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"virtualmachines.kubevirt.io":                    {stdout: networkVM},
+		"virtualmachineinstances.kubevirt.io":            {stderr: `Error from server (NotFound): virtualmachineinstances.kubevirt.io "vm1" not found`},
+		"network-attachment-definitions.k8s.cni.cncf.io": {stdout: flatNADs},
+		"pods": {stdout: "items: []\n"},
+	}}
+	objects, err := fetchVMNetworkObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+
+	// a stopped VM still lists its interfaces, the network name doesn't match any attachment
+	result := resolveVMNetwork(objects)
+	assert.Empty(result.Node)
+	assert.Nil(result.NodeNetwork)
+	assert.Len(result.Interfaces, 2)
+	vlan := result.Interfaces[1]
+	assert.Equal("52:54:00:aa:bb:cc", vlan.MAC)
+	assert.Empty(vlan.IPs)
+	assert.Nil(vlan.Attachment)
+	assert.Equal([]string{"network attachment definition default/vlan100 doesn't exist"}, vlan.Problems)
+
+	objects.vm.Spec.Template.Spec.Networks[1].Multus.NetworkName = "default/vlan1"
+	attachment := resolveVMNetwork(objects).Interfaces[1].Attachment
+	assert.Equal("harvester-br0", attachment.Bridge)
+	assert.Equal(1, attachment.VLAN)
+	assert.Empty(attachment.ClusterNetwork)
+
+	exec.outputs["network-attachment-definitions.k8s.cni.cncf.io"] = kubectlOutput{stderr: `error: the server doesn't have a resource type "network-attachment-definitions"`}
+	objects, err = fetchVMNetworkObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+	assert.Len(resolveVMNetwork(objects).Interfaces, 2)
+}
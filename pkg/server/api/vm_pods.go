@@ -15,14 +15,17 @@ import (
 type PodInfo struct {
 	Name         string `json:"name"`
 	CreationTime string `json:"creationTime"`
+	// CreationTimeLocal is CreationTime in the time zone of the workspace, if it has one
+	CreationTimeLocal string `json:"creationTimeLocal,omitempty"`
 }
 
 type MigrationInfo struct {
-	Name         string `json:"name"`
-	CreationTime string `json:"creationTime"`
-	SourcePod    string `json:"sourcePod"`
-	TargetPod    string `json:"targetPod"`
-	Yaml         string `json:"yaml"`
+	Name              string `json:"name"`
+	CreationTime      string `json:"creationTime"`
+	CreationTimeLocal string `json:"creationTimeLocal,omitempty"`
+	SourcePod         string `json:"sourcePod"`
+	TargetPod         string `json:"targetPod"`
+	Yaml              string `json:"yaml"`
 }
 
 type VirtualMachinePodsResult struct {
@@ -58,13 +61,22 @@ type MigrationList struct {
 	} `yaml:"items"`
 }
 
+// vmPodsRequest is the body of a vm-pods query
+type vmPodsRequest struct {
+	VersionID string `json:"versionID"`
+	Namespace string `json:"namespace"`
+	VMName    string `json:"vmName"`
+}
+
 func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	var req struct {
-		VersionID string `json:"versionID"`
-		Namespace string `json:"namespace"`
-		VMName    string `json:"vmName"`
+	format, err := parseExportFormat(r, formatCSV)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	var req vmPodsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -99,11 +111,15 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if VM exists
-	_, stderr, err := utils.ExecKubectl(exec, "get", "virtualmachine", req.VMName, "-n", req.Namespace, "-o", "yaml")
-	if err != nil || stderr != "" {
+	out, err := utils.ExecKubectl(exec, "get", "virtualmachine", req.VMName, "-n", req.Namespace, "-o", "yaml")
+	if status, err := utils.KubectlStatus(out, err); err != nil {
+		message := fmt.Sprintf("Failed to get VirtualMachine: %v", err)
+		if status == utils.KubectlNotFound {
+			message = fmt.Sprintf("VirtualMachine '%s' not found in namespace '%s'", req.VMName, req.Namespace)
+		}
 		result := VirtualMachinePodsResult{
 			VMName: req.VMName,
-			Error:  fmt.Sprintf("VirtualMachine '%s' not found in namespace '%s'", req.VMName, req.Namespace),
+			Error:  message,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
@@ -113,8 +129,9 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 	// Get all pods in namespace with label selector for this VM (including terminated pods)
 	// KubeVirt uses labels like kubevirt.io/vm=<vm-name>
 	// kubectl get pods returns all pods by default, including Completed/Terminated ones
-	podsYAML, stderr, err := utils.ExecKubectl(exec, "get", "pods", "-n", req.Namespace, "-l", fmt.Sprintf("harvesterhci.io/vmName=%s", req.VMName), "-o", "yaml")
-	if err != nil {
+	// A selector matching nothing isn't an error, kubectl prints an empty list
+	out, err = utils.ExecKubectl(exec, "get", "pods", "-n", req.Namespace, "-l", fmt.Sprintf("harvesterhci.io/vmName=%s", req.VMName), "-o", "yaml")
+	if _, err := utils.KubectlStatus(out, err); err != nil {
 		result := VirtualMachinePodsResult{
 			VMName: req.VMName,
 			Error:  fmt.Sprintf("Failed to get pods for VM: %v", err),
@@ -124,18 +141,8 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if stderr != "" {
-		result := VirtualMachinePodsResult{
-			VMName: req.VMName,
-			Error:  fmt.Sprintf("Failed to list pods: %s", stderr),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
-		return
-	}
-
 	var podList PodList
-	if err := yaml.Unmarshal([]byte(podsYAML), &podList); err != nil {
+	if err := yaml.Unmarshal([]byte(out.Stdout), &podList); err != nil {
 		result := VirtualMachinePodsResult{
 			VMName: req.VMName,
 			Error:  fmt.Sprintf("Failed to parse pods: %v", err),
@@ -146,29 +153,30 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract pod info
+	loc := workspaceLocation(ws)
 	pods := make([]PodInfo, 0)
 	for _, pod := range podList.Items {
 		if pod.Metadata.Name != "" {
 			pods = append(pods, PodInfo{
-				Name:         pod.Metadata.Name,
-				CreationTime: pod.Metadata.CreationTimestamp,
+				Name:              pod.Metadata.Name,
+				CreationTime:      pod.Metadata.CreationTimestamp,
+				CreationTimeLocal: localTimestamp(pod.Metadata.CreationTimestamp, loc),
 			})
 		}
 	}
 
 	// If no pods found with label selector, try matching by prefix (including terminated pods)
 	if len(pods) == 0 {
-		allPodsYAML, _, err := utils.ExecKubectl(exec, "get", "pods", "-n", req.Namespace, "-o", "yaml")
-		if err == nil {
-			var allPodList PodList
-			if err := yaml.Unmarshal([]byte(allPodsYAML), &allPodList); err == nil {
-				for _, pod := range allPodList.Items {
-					if strings.HasPrefix(pod.Metadata.Name, req.VMName+"-") {
-						pods = append(pods, PodInfo{
-							Name:         pod.Metadata.Name,
-							CreationTime: pod.Metadata.CreationTimestamp,
-						})
-					}
+		// Every pod of the namespace, decoded while streamed
+		var allPodList PodList
+		if _, err := utils.DecodeKubectlYAML(r.Context(), exec, &allPodList, "get", "pods", "-n", req.Namespace, "-o", "yaml"); err == nil {
+			for _, pod := range allPodList.Items {
+				if strings.HasPrefix(pod.Metadata.Name, req.VMName+"-") {
+					pods = append(pods, PodInfo{
+						Name:              pod.Metadata.Name,
+						CreationTime:      pod.Metadata.CreationTimestamp,
+						CreationTimeLocal: localTimestamp(pod.Metadata.CreationTimestamp, loc),
+					})
 				}
 			}
 		}
@@ -185,23 +193,24 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Get VirtualMachineInstanceMigrations for this VM
-	migrationsYAML, _, err := utils.ExecKubectl(exec, "get", "virtualmachineinstancemigrations", "-n", req.Namespace, "-l", fmt.Sprintf("kubevirt.io/vmi-name=%s", req.VMName), "-o", "yaml")
+	out, err = utils.ExecKubectl(exec, "get", "virtualmachineinstancemigrations", "-n", req.Namespace, "-l", fmt.Sprintf("kubevirt.io/vmi-name=%s", req.VMName), "-o", "yaml")
 	migrations := make([]MigrationInfo, 0)
 
-	if err == nil && migrationsYAML != "" {
+	if status, _ := utils.KubectlStatus(out, err); status == utils.KubectlFound && out.Stdout != "" {
 		var migrationList MigrationList
-		if err := yaml.Unmarshal([]byte(migrationsYAML), &migrationList); err == nil {
+		if err := yaml.Unmarshal([]byte(out.Stdout), &migrationList); err == nil {
 			for _, mig := range migrationList.Items {
 				if mig.Metadata.Name != "" {
 					// Get full YAML for this migration
-					migYAML, _, err := utils.ExecKubectl(exec, "get", "virtualmachineinstancemigration", mig.Metadata.Name, "-n", req.Namespace, "-o", "yaml")
-					if err == nil {
+					migYAML, err := utils.ExecKubectl(exec, "get", "virtualmachineinstancemigration", mig.Metadata.Name, "-n", req.Namespace, "-o", "yaml")
+					if status, _ := utils.KubectlStatus(migYAML, err); status == utils.KubectlFound {
 						migrations = append(migrations, MigrationInfo{
-							Name:         mig.Metadata.Name,
-							CreationTime: mig.Metadata.CreationTimestamp,
-							SourcePod:    mig.Status.MigrationState.SourcePod,
-							TargetPod:    mig.Status.MigrationState.TargetPod,
-							Yaml:         migYAML,
+							Name:              mig.Metadata.Name,
+							CreationTime:      mig.Metadata.CreationTimestamp,
+							CreationTimeLocal: localTimestamp(mig.Metadata.CreationTimestamp, loc),
+							SourcePod:         mig.Status.MigrationState.SourcePod,
+							TargetPod:         mig.Status.MigrationState.TargetPod,
+							Yaml:              migYAML.Stdout,
 						})
 					}
 				}
@@ -225,6 +234,28 @@ func (s *Server) handleGetVMPods(w http.ResponseWriter, r *http.Request) {
 		Migrations: migrations,
 	}
 
+	if format == formatCSV {
+		filename := exportFilename(".csv", name, req.VersionID, req.Namespace, req.VMName, "pods")
+		if err := writeCSV(w, filename, vmPodsCSVHeader, vmPodsCSVRows(result)); err != nil {
+			fmt.Printf("Failed to write VM pods CSV: %v\n", err)
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+var vmPodsCSVHeader = []string{"kind", "name", "creationTime", "sourcePod", "targetPod", "creationTimeLocal"}
+
+// vmPodsCSVRows flattens the pods and migrations of a VM into one row each
+func vmPodsCSVRows(result VirtualMachinePodsResult) [][]string {
+	rows := make([][]string, 0, len(result.Pods)+len(result.Migrations))
+	for _, pod := range result.Pods {
+		rows = append(rows, []string{"pod", pod.Name, pod.CreationTime, "", "", pod.CreationTimeLocal})
+	}
+	for _, mig := range result.Migrations {
+		rows = append(rows, []string{"migration", mig.Name, mig.CreationTime, mig.SourcePod, mig.TargetPod, mig.CreationTimeLocal})
+	}
+	return rows
+}
@@ -0,0 +1,457 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+)
+
+// StorageHop is an object of the chain from a VM volume to the node its data is attached to
+type StorageHop struct {
+	Kind         string `json:"kind"` // PersistentVolumeClaim, PersistentVolume, LonghornVolume or VolumeAttachment
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	Status       string `json:"status"`
+	Size         string `json:"size,omitempty"`
+	StorageClass string `json:"storageClass,omitempty"`
+	Node         string `json:"node,omitempty"`
+}
+
+// VMVolumeStorage is a volume of a VM and the storage behind it
+type VMVolumeStorage struct {
+	Name     string `json:"name"`
+	DiskType string `json:"diskType,omitempty"` // disk, cdrom, lun, empty for volumes without a disk
+	Source   string `json:"source"`             // persistentVolumeClaim, dataVolume, containerDisk, cloudInitNoCloud...
+	// ImageID is the VM image a volume was created from, for image-backed volumes
+	ImageID string       `json:"imageID,omitempty"`
+	Chain   []StorageHop `json:"chain"`
+	// Problems are the broken links of the chain, e.g. a pending claim or an attachment to a deleted node
+	Problems []string `json:"problems"`
+}
+
+type VMStorageResult struct {
+	VMName  string            `json:"vmName"`
+	Volumes []VMVolumeStorage `json:"volumes"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type vmSpec struct {
+	Metadata struct {
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Template struct {
+			Spec struct {
+				Domain struct {
+					Devices struct {
+						Disks []map[string]interface{} `yaml:"disks"`
+					} `yaml:"devices"`
+				} `yaml:"domain"`
+				Volumes []map[string]interface{} `yaml:"volumes"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+type pvcList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `yaml:"name"`
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+		Spec struct {
+			VolumeName       string `yaml:"volumeName"`
+			StorageClassName string `yaml:"storageClassName"`
+			Resources        struct {
+				Requests map[string]string `yaml:"requests"`
+			} `yaml:"resources"`
+		} `yaml:"spec"`
+		Status struct {
+			Phase    string            `yaml:"phase"`
+			Capacity map[string]string `yaml:"capacity"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type pvList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Capacity         map[string]string `yaml:"capacity"`
+			StorageClassName string            `yaml:"storageClassName"`
+			CSI              struct {
+				Driver       string `yaml:"driver"`
+				VolumeHandle string `yaml:"volumeHandle"`
+			} `yaml:"csi"`
+		} `yaml:"spec"`
+		Status struct {
+			Phase string `yaml:"phase"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type longhornVolumeList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Spec struct {
+			Size string `yaml:"size"`
+		} `yaml:"spec"`
+		Status struct {
+			State         string `yaml:"state"`
+			Robustness    string `yaml:"robustness"`
+			CurrentNodeID string `yaml:"currentNodeID"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+type volumeAttachmentList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `yaml:"name"`
+		} `yaml:"metadata"`
+		Spec struct {
+			NodeName string `yaml:"nodeName"`
+			Source   struct {
+				PersistentVolumeName string `yaml:"persistentVolumeName"`
+			} `yaml:"source"`
+		} `yaml:"spec"`
+		Status struct {
+			Attached    bool `yaml:"attached"`
+			AttachError *struct {
+				Message string `yaml:"message"`
+			} `yaml:"attachError"`
+		} `yaml:"status"`
+	} `yaml:"items"`
+}
+
+// vmStorageObjects are the objects the storage chain of a VM is resolved from
+type vmStorageObjects struct {
+	vm                vmSpec
+	pvcs              pvcList
+	pvs               pvList
+	longhornVolumes   longhornVolumeList
+	volumeAttachments volumeAttachmentList
+	nodes             NodeList
+}
+
+// vmStorageRequest is the body of a vm-storage query
+type vmStorageRequest struct {
+	Namespace string `json:"namespace"`
+	VMName    string `json:"vmName"`
+}
+
+func (s *Server) handleGetVMStorage(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	versionID := r.PathValue("versionID")
+
+	var req vmStorageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" || req.VMName == "" {
+		http.Error(w, "namespace and vmName are required", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !HasVersionInWorkspace(ws, versionID) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	writeResult := func(result VMStorageResult) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+
+	exec, err := s.GetExecutor(name, versionID)
+	if err != nil {
+		writeResult(VMStorageResult{VMName: req.VMName, Error: fmt.Sprintf("Failed to get executor: %v", err)})
+		return
+	}
+
+	objects, err := fetchVMStorageObjects(r.Context(), exec, req.Namespace, req.VMName)
+	if err != nil {
+		writeResult(VMStorageResult{VMName: req.VMName, Error: err.Error()})
+		return
+	}
+	result := resolveVMStorage(objects)
+	result.VMName = req.VMName
+	writeResult(result)
+}
+
+// fetchVMStorageObjects gets the VM and the storage objects its volumes may point to. Clusters
+// without Longhorn have no Longhorn volumes, the chain then ends at the persistent volume.
+func fetchVMStorageObjects(ctx context.Context, exec executor.Executor, namespace, vmName string) (*vmStorageObjects, error) {
+	objects := &vmStorageObjects{}
+
+	stderr, err := utils.DecodeKubectlYAML(ctx, exec, &objects.vm, "get", "virtualmachines.kubevirt.io", vmName, "-n", namespace, "-o", "yaml")
+	if err != nil || stderr != "" {
+		return nil, fmt.Errorf("VirtualMachine '%s' not found in namespace '%s': %s", vmName, namespace, strings.TrimSpace(stderr))
+	}
+
+	lists := []struct {
+		out  interface{}
+		args []string
+	}{
+		{&objects.pvcs, []string{"get", "persistentvolumeclaims", "-n", namespace, "-o", "yaml"}},
+		{&objects.pvs, []string{"get", "persistentvolumes", "-o", "yaml"}},
+		{&objects.longhornVolumes, []string{"get", "volumes.longhorn.io", "-A", "-o", "yaml"}},
+		{&objects.volumeAttachments, []string{"get", "volumeattachments.storage.k8s.io", "-o", "yaml"}},
+		{&objects.nodes, []string{"get", "nodes", "-o", "yaml"}},
+	}
+	for _, list := range lists {
+		if err := decodeKubectlList(ctx, exec, list.out, list.args...); err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", list.args[1], err)
+		}
+	}
+	return objects, nil
+}
+
+// volumeClaimTemplate is an entry of the harvesterhci.io/volumeClaimTemplates annotation, which
+// Harvester creates the claims of new VMs from
+type volumeClaimTemplate struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		StorageClassName string `json:"storageClassName"`
+		Resources        struct {
+			Requests map[string]string `json:"requests"`
+		} `json:"resources"`
+	} `json:"spec"`
+}
+
+// resolveVMStorage follows every volume of the VM to its claim, persistent volume, Longhorn volume
+// and volume attachments
+func resolveVMStorage(objects *vmStorageObjects) VMStorageResult {
+	result := VMStorageResult{Volumes: []VMVolumeStorage{}}
+
+	var templates []volumeClaimTemplate
+	if raw := objects.vm.Metadata.Annotations["harvesterhci.io/volumeClaimTemplates"]; raw != "" {
+		// A broken annotation only loses the image of the volumes
+		_ = json.Unmarshal([]byte(raw), &templates)
+	}
+
+	diskTypes := map[string]string{}
+	for _, disk := range objects.vm.Spec.Template.Spec.Domain.Devices.Disks {
+		diskName, _ := disk["name"].(string)
+		for _, diskType := range []string{"disk", "cdrom", "lun"} {
+			if _, ok := disk[diskType]; ok {
+				diskTypes[diskName] = diskType
+			}
+		}
+	}
+
+	volumeNames := map[string]bool{}
+	for _, volume := range objects.vm.Spec.Template.Spec.Volumes {
+		volumeName, _ := volume["name"].(string)
+		volumeNames[volumeName] = true
+		storage := VMVolumeStorage{
+			Name:     volumeName,
+			DiskType: diskTypes[volumeName],
+			Chain:    []StorageHop{},
+			Problems: []string{},
+		}
+
+		claimName := ""
+		for key, value := range volume {
+			if key == "name" {
+				continue
+			}
+			storage.Source = key
+			source, _ := value.(map[string]interface{})
+			switch key {
+			case "persistentVolumeClaim":
+				claimName, _ = source["claimName"].(string)
+			case "dataVolume":
+				claimName, _ = source["name"].(string)
+			}
+		}
+		if claimName != "" {
+			for _, template := range templates {
+				if template.Metadata.Name == claimName {
+					storage.ImageID = template.Metadata.Annotations["harvesterhci.io/imageId"]
+				}
+			}
+			objects.followClaim(&storage, claimName, templates)
+		}
+		result.Volumes = append(result.Volumes, storage)
+	}
+
+	// Ejected CD-ROMs keep their disk without a volume
+	for _, disk := range objects.vm.Spec.Template.Spec.Domain.Devices.Disks {
+		diskName, _ := disk["name"].(string)
+		if !volumeNames[diskName] {
+			result.Volumes = append(result.Volumes, VMVolumeStorage{
+				Name:     diskName,
+				DiskType: diskTypes[diskName],
+				Chain:    []StorageHop{},
+				Problems: []string{},
+			})
+		}
+	}
+	return result
+}
+
+// followClaim appends the hops from the claim to the nodes its volume is attached to
+func (o *vmStorageObjects) followClaim(storage *VMVolumeStorage, claimName string, templates []volumeClaimTemplate) {
+	found := false
+	volumeName := ""
+	for _, pvc := range o.pvcs.Items {
+		if pvc.Metadata.Name != claimName {
+			continue
+		}
+		found = true
+		size := pvc.Status.Capacity["storage"]
+		if size == "" {
+			size = pvc.Spec.Resources.Requests["storage"]
+		}
+		if storage.ImageID == "" {
+			storage.ImageID = pvc.Metadata.Annotations["harvesterhci.io/imageId"]
+		}
+		storage.Chain = append(storage.Chain, StorageHop{
+			Kind:         "PersistentVolumeClaim",
+			Name:         claimName,
+			Status:       pvc.Status.Phase,
+			Size:         size,
+			StorageClass: pvc.Spec.StorageClassName,
+		})
+		if pvc.Status.Phase != "Bound" {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("claim %s is %s", claimName, orUnknown(pvc.Status.Phase)))
+		}
+		volumeName = pvc.Spec.VolumeName
+	}
+	if !found {
+		hop := StorageHop{Kind: "PersistentVolumeClaim", Name: claimName, Status: "Missing"}
+		for _, template := range templates {
+			if template.Metadata.Name == claimName {
+				hop.Size = template.Spec.Resources.Requests["storage"]
+				hop.StorageClass = template.Spec.StorageClassName
+			}
+		}
+		storage.Chain = append(storage.Chain, hop)
+		storage.Problems = append(storage.Problems, fmt.Sprintf("claim %s doesn't exist", claimName))
+		return
+	}
+	if volumeName == "" {
+		return
+	}
+
+	found = false
+	longhornName := ""
+	for _, pv := range o.pvs.Items {
+		if pv.Metadata.Name != volumeName {
+			continue
+		}
+		found = true
+		storage.Chain = append(storage.Chain, StorageHop{
+			Kind:         "PersistentVolume",
+			Name:         volumeName,
+			Status:       pv.Status.Phase,
+			Size:         pv.Spec.Capacity["storage"],
+			StorageClass: pv.Spec.StorageClassName,
+		})
+		if pv.Status.Phase != "Bound" {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("volume %s is %s", volumeName, orUnknown(pv.Status.Phase)))
+		}
+		if pv.Spec.CSI.Driver == "driver.longhorn.io" {
+			longhornName = pv.Spec.CSI.VolumeHandle
+		}
+	}
+	if !found {
+		storage.Chain = append(storage.Chain, StorageHop{Kind: "PersistentVolume", Name: volumeName, Status: "Missing"})
+		storage.Problems = append(storage.Problems, fmt.Sprintf("claim %s is bound to volume %s which doesn't exist", claimName, volumeName))
+		return
+	}
+
+	if longhornName != "" {
+		o.followLonghornVolume(storage, longhornName)
+	}
+
+	for _, attachment := range o.volumeAttachments.Items {
+		if attachment.Spec.Source.PersistentVolumeName != volumeName {
+			continue
+		}
+		status := "Detached"
+		if attachment.Status.Attached {
+			status = "Attached"
+		}
+		storage.Chain = append(storage.Chain, StorageHop{
+			Kind:   "VolumeAttachment",
+			Name:   attachment.Metadata.Name,
+			Status: status,
+			Node:   attachment.Spec.NodeName,
+		})
+		if !o.hasNode(attachment.Spec.NodeName) {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("attachment %s is to node %s which doesn't exist", attachment.Metadata.Name, attachment.Spec.NodeName))
+		}
+		if attachment.Status.AttachError != nil && attachment.Status.AttachError.Message != "" {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("attachment %s failed: %s", attachment.Metadata.Name, attachment.Status.AttachError.Message))
+		}
+	}
+}
+
+func (o *vmStorageObjects) followLonghornVolume(storage *VMVolumeStorage, name string) {
+	for _, volume := range o.longhornVolumes.Items {
+		if volume.Metadata.Name != name {
+			continue
+		}
+		status := volume.Status.State
+		if volume.Status.Robustness != "" && volume.Status.Robustness != "unknown" {
+			status += "/" + volume.Status.Robustness
+		}
+		storage.Chain = append(storage.Chain, StorageHop{
+			Kind:      "LonghornVolume",
+			Name:      name,
+			Namespace: volume.Metadata.Namespace,
+			Status:    status,
+			Size:      volume.Spec.Size,
+			Node:      volume.Status.CurrentNodeID,
+		})
+		if volume.Status.Robustness == "faulted" || volume.Status.Robustness == "degraded" {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("Longhorn volume %s is %s", name, volume.Status.Robustness))
+		}
+		if volume.Status.CurrentNodeID != "" && !o.hasNode(volume.Status.CurrentNodeID) {
+			storage.Problems = append(storage.Problems, fmt.Sprintf("Longhorn volume %s is attached to node %s which doesn't exist", name, volume.Status.CurrentNodeID))
+		}
+		return
+	}
+	// Only reported when Longhorn is there, the list is empty on clusters without it
+	if len(o.longhornVolumes.Items) > 0 {
+		storage.Chain = append(storage.Chain, StorageHop{Kind: "LonghornVolume", Name: name, Status: "Missing"})
+		storage.Problems = append(storage.Problems, fmt.Sprintf("Longhorn volume %s doesn't exist", name))
+	}
+}
+
+func (o *vmStorageObjects) hasNode(name string) bool {
+	for _, node := range o.nodes.Items {
+		if node.Metadata.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func orUnknown(phase string) string {
+	if phase == "" {
+		return "in an unknown phase"
+	}
+	return phase
+}
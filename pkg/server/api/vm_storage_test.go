@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const storageVM = `apiVersion: kubevirt.io/v1
+kind: VirtualMachine
+metadata:
+  name: vm1
+  namespace: default
+  annotations:
+    harvesterhci.io/volumeClaimTemplates: '[{"metadata":{"name":"vm1-disk-0-abcde","annotations":{"harvesterhci.io/imageId":"default/image-ubuntu"}},"spec":{"storageClassName":"longhorn-image-ubuntu","resources":{"requests":{"storage":"10Gi"}}}},{"metadata":{"name":"vm1-disk-2-fghij"},"spec":{"storageClassName":"harvester-longhorn","resources":{"requests":{"storage":"5Gi"}}}}]'
+spec:
+  template:
+    spec:
+      domain:
+        devices:
+          disks:
+          - name: disk-0
+            disk:
+              bus: virtio
+          - name: disk-1
+            disk:
+              bus: virtio
+          - name: disk-2
+            disk:
+              bus: virtio
+          - name: cdrom-0
+            cdrom:
+              bus: sata
+          - name: cloudinitdisk
+            disk:
+              bus: virtio
+      volumes:
+      - name: disk-0
+        persistentVolumeClaim:
+          claimName: vm1-disk-0-abcde
+      - name: disk-1
+        persistentVolumeClaim:
+          claimName: vm1-disk-1-pending
+      - name: disk-2
+        persistentVolumeClaim:
+          claimName: vm1-disk-2-fghij
+      - name: cloudinitdisk
+        cloudInitNoCloud:
+          userData: "#cloud-config"
+`
+
+const storagePVCs = `items:
+- metadata:
+    name: vm1-disk-0-abcde
+  spec:
+    volumeName: pvc-1111
+    storageClassName: longhorn-image-ubuntu
+  status:
+    phase: Bound
+    capacity:
+      storage: 10Gi
+- metadata:
+    name: vm1-disk-1-pending
+  spec:
+    storageClassName: harvester-longhorn
+    resources:
+      requests:
+        storage: 20Gi
+  status:
+    phase: Pending
+`
+
+const storagePVs = `items:
+- metadata:
+    name: pvc-1111
+  spec:
+    capacity:
+      storage: 10Gi
+    storageClassName: longhorn-image-ubuntu
+    csi:
+      driver: driver.longhorn.io
+      volumeHandle: pvc-1111
+  status:
+    phase: Bound
+`
+
+const storageLonghornVolumes = `items:
+- metadata:
+    name: pvc-1111
+    namespace: longhorn-system
+  spec:
+    size: "10737418240"
+  status:
+    state: attached
+    robustness: degraded
+    currentNodeID: node-2
+`
+
+const storageAttachments = `items:
+- metadata:
+    name: csi-abc
+  spec:
+    attacher: driver.longhorn.io
+    nodeName: node-2
+    source:
+      persistentVolumeName: pvc-1111
+  status:
+    attached: true
+`
+
+func Test_ResolveVMStorage(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"virtualmachines.kubevirt.io":      {stdout: storageVM},
+		"persistentvolumeclaims":           {stdout: storagePVCs},
+		"persistentvolumes":                {stdout: storagePVs},
+		"volumes.longhorn.io":              {stdout: storageLonghornVolumes},
+		"volumeattachments.storage.k8s.io": {stdout: storageAttachments},
+		"nodes":                            {stdout: "items:\n- metadata:\n    name: node-1\n"},
+	}}
+	objects, err := fetchVMStorageObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+
+	result := resolveVMStorage(objects)
+	assert.Len(result.Volumes, 5)
+
+	disk := result.Volumes[0]
+	assert.Equal("disk", disk.DiskType)
+	assert.Equal("persistentVolumeClaim", disk.Source)
+	assert.Equal("default/image-ubuntu", disk.ImageID)
+	assert.Equal([]StorageHop{
+		{Kind: "PersistentVolumeClaim", Name: "vm1-disk-0-abcde", Status: "Bound", Size: "10Gi", StorageClass: "longhorn-image-ubuntu"},
+		{Kind: "PersistentVolume", Name: "pvc-1111", Status: "Bound", Size: "10Gi", StorageClass: "longhorn-image-ubuntu"},
+		{Kind: "LonghornVolume", Name: "pvc-1111", Namespace: "longhorn-system", Status: "attached/degraded", Size: "10737418240", Node: "node-2"},
+		{Kind: "VolumeAttachment", Name: "csi-abc", Status: "Attached", Node: "node-2"},
+	}, disk.Chain)
+	assert.Equal([]string{
+		"Longhorn volume pvc-1111 is degraded",
+		"Longhorn volume pvc-1111 is attached to node node-2 which doesn't exist",
+		"attachment csi-abc is to node node-2 which doesn't exist",
+	}, disk.Problems)
+
+	pending := result.Volumes[1]
+	assert.Len(pending.Chain, 1)
+	assert.Equal("20Gi", pending.Chain[0].Size)
+	assert.Equal([]string{"claim vm1-disk-1-pending is Pending"}, pending.Problems)
+
+	// the claim of a template that was never created
+	missing := result.Volumes[2]
+	assert.Equal(StorageHop{Kind: "PersistentVolumeClaim", Name: "vm1-disk-2-fghij", Status: "Missing", Size: "5Gi", StorageClass: "harvester-longhorn"}, missing.Chain[0])
+	assert.Len(missing.Problems, 1)
+
+	cloudInit := result.Volumes[3]
+	assert.Equal("cloudInitNoCloud", cloudInit.Source)
+	assert.Empty(cloudInit.Chain)
+	assert.Empty(cloudInit.Problems)
+
+	// an ejected CD-ROM has a disk but no volume
+	cdrom := result.Volumes[4]
+	assert.Equal(VMVolumeStorage{Name: "cdrom-0", DiskType: "cdrom", Chain: []StorageHop{}, Problems: []string{}}, cdrom)
+}
+
+func Test_ResolveVMStorageWithoutLonghorn(t *testing.T) {
+	assert := require.New(t)
+	exec := &scriptedExecutor{outputs: map[string]kubectlOutput{
+		"virtualmachines.kubevirt.io": {stdout: storageVM},
+		"persistentvolumeclaims":      {stdout: storagePVCs},
+		"persistentvolumes":           {stdout: storagePVs},
+		"volumes.longhorn.io":         {stderr: `error: the server doesn't have a resource type "volumes"`},
+		"nodes":                       {stdout: "items:\n- metadata:\n    name: node-2\n"},
+	}}
+	objects, err := fetchVMStorageObjects(context.Background(), exec, "default", "vm1")
+	assert.NoError(err)
+
+	disk := resolveVMStorage(objects).Volumes[0]
+	assert.Len(disk.Chain, 2)
+	assert.Empty(disk.Problems)
+
+	exec.outputs["virtualmachines.kubevirt.io"] = kubectlOutput{stderr: `Error from server (NotFound): virtualmachines.kubevirt.io "vm1" not found`}
+	_, err = fetchVMStorageObjects(context.Background(), exec, "default", "vm1")
+	assert.ErrorContains(err, "VirtualMachine 'vm1' not found in namespace 'default'")
+}
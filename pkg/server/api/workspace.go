@@ -1,40 +1,132 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/Yu-Jack/sim-gui/pkg/docker"
 	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
 	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
+	"gopkg.in/yaml.v3"
 )
 
 func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
-	workspaces, err := s.store.ListWorkspaces()
+	params, err := parseListParams(r, workspaceSortFields...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	workspaces, err := s.listWorkspaces(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	workspaces = paginate(w, workspaces, params, workspaceLess(params))
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(workspaces)
 }
 
-func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name string `json:"name"`
+// workspaceSortFields are the fields workspace lists are sorted by, see workspaceLess
+var workspaceSortFields = []string{"name", "createdAt"}
+
+// workspaceLess returns how the sort field of params orders workspaces
+func workspaceLess(params listParams) func(a, b model.Workspace) bool {
+	if params.Sort == "createdAt" {
+		return func(a, b model.Workspace) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	}
+	return func(a, b model.Workspace) bool { return a.Name < b.Name }
+}
+
+// listWorkspaces returns the workspaces matching the filters of a list request with their versions ordered
+func (s *Server) listWorkspaces(r *http.Request) ([]model.Workspace, error) {
+	workspaces, err := s.store.ListWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	if r.URL.Query().Has("createdBy") {
+		workspaces = workspacesCreatedBy(workspaces, r.URL.Query().Get("createdBy"))
+	}
+	// mine=true keeps the workspaces of the user of the request
+	if r.URL.Query().Get("mine") == "true" {
+		workspaces = workspacesCreatedBy(workspaces, s.requestUser(r))
+	}
+	for i := range workspaces {
+		workspaces[i].Versions = orderedVersions(workspaces[i].Versions)
+		if !s.seesSecrets(r) {
+			workspaces[i] = redactWorkspace(workspaces[i])
+		}
+	}
+	return workspaces, nil
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	params, err := parseListParams(r, "id", "name", "createdAt", "collectedAt", "lastStartedAt", "lastAccessedAt")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
+
+	// Versions are kept in their set order unless asked otherwise
+	var less func(a, b model.Version) bool
+	switch params.Sort {
+	case "id":
+		less = func(a, b model.Version) bool { return versionNumber(a.ID) < versionNumber(b.ID) }
+	case "name":
+		less = func(a, b model.Version) bool { return a.Name < b.Name }
+	case "createdAt":
+		less = func(a, b model.Version) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case "collectedAt":
+		less = func(a, b model.Version) bool { return versionTime(a).Before(versionTime(b)) }
+	case "lastStartedAt":
+		less = func(a, b model.Version) bool { return timeBefore(a.LastStartedAt, b.LastStartedAt) }
+	case "lastAccessedAt":
+		less = func(a, b model.Version) bool { return timeBefore(a.LastAccessedAt, b.LastAccessedAt) }
+	}
+	versions := paginate(w, orderedVersions(ws.Versions), params, less)
+	if !s.seesSecrets(r) {
+		versions = redactVersions(versions)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// workspaceNameRequest is the body of a workspace creation or rename
+type workspaceNameRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var req workspaceNameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if strings.TrimSpace(req.Name) == "" {
-		http.Error(w, "Workspace name cannot be empty", http.StatusBadRequest)
+	if err := validateWorkspaceName(s.profile, req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -42,28 +134,126 @@ func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		DisplayName: req.Name,
 		CreatedAt:   time.Now(),
+		CreatedBy:   s.requestUser(r),
 		Versions:    []model.Version{},
 	}
 
-	if err := s.store.CreateWorkspace(ws); err != nil {
-		if os.IsExist(err) {
-			http.Error(w, "Workspace already exists", http.StatusConflict)
-			return
+	if err := s.createWorkspace(ws); err != nil {
+		switch {
+		case errors.Is(err, errWorkspaceDeleting), errors.Is(err, errWorkspaceFilesLeft):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, os.ErrExist):
+			http.Error(w, "Workspace already exists, names are compared regardless of case", http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.events.PublishBy(ws.CreatedBy, events.WorkspaceCreated, ws.Name, "", ws)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(ws)
 }
 
+// validateWorkspaceName checks a name for a new workspace. The name is a directory in the data directory,
+// simulators of its versions are named after it by docker.InstanceName, which shortens names docker
+// doesn't accept.
+func validateWorkspaceName(profile, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("Workspace name cannot be empty")
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return errors.New("Workspace name cannot be . or .. or contain slashes or control characters")
+	}
+	if err := docker.ValidateInstanceName(profileInstanceName(profile, name, "v1")); err != nil {
+		return fmt.Errorf("Workspace name can't be used for simulators: %w", err)
+	}
+	return nil
+}
+
+var (
+	errWorkspaceDeleting  = fmt.Errorf("the workspace is still being deleted, create it again once it's gone: %w", os.ErrExist)
+	errWorkspaceFilesLeft = fmt.Errorf("files of a deleted workspace are left in its directory, see GET /api/consistency: %w", os.ErrExist)
+)
+
+// createWorkspace reserves the directory of a new workspace before adding it to the store, so that of
+// concurrent creations of a name only one gets to use the directory. A name being deleted can't be
+// created until its files are removed.
+func (s *Server) createWorkspace(ws model.Workspace) error {
+	s.workspaceLock.Lock()
+	defer s.workspaceLock.Unlock()
+	dir, err := s.reserveWorkspaceDir(ws.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateWorkspace(ws); err != nil {
+		// The directory was created above and is still empty
+		os.Remove(dir)
+		return err
+	}
+	return nil
+}
+
+// reserveWorkspaceDir creates the empty directory of a workspace about to be added to the store and
+// returns it, workspaceLock must be held until the workspace is added
+func (s *Server) reserveWorkspaceDir(name string) (string, error) {
+	if s.deleting[store.NameKey(name)] {
+		return "", errWorkspaceDeleting
+	}
+
+	dir := filepath.Join(s.dataDir, "workspaces", name)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	err := os.Mkdir(dir, 0755)
+	if os.IsExist(err) {
+		workspaces, listErr := s.store.ListWorkspaces()
+		if listErr != nil {
+			return "", listErr
+		}
+		for _, existing := range workspaces {
+			if store.NameKey(existing.Name) == store.NameKey(name) {
+				return "", os.ErrExist
+			}
+		}
+		// An empty directory is left by a delete that failed after removing the files
+		if os.Remove(dir) != nil {
+			return "", errWorkspaceFilesLeft
+		}
+		err = os.Mkdir(dir, 0755)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	return dir, nil
+}
+
+// beginDelete marks a workspace as being deleted until endDelete, it returns false when it already is
+func (s *Server) beginDelete(name string) bool {
+	s.workspaceLock.Lock()
+	defer s.workspaceLock.Unlock()
+	if s.deleting == nil {
+		s.deleting = make(map[string]bool)
+	}
+	key := store.NameKey(name)
+	if s.deleting[key] {
+		return false
+	}
+	s.deleting[key] = true
+	return true
+}
+
+func (s *Server) endDelete(name string) {
+	s.workspaceLock.Lock()
+	defer s.workspaceLock.Unlock()
+	delete(s.deleting, store.NameKey(name))
+}
+
 func (s *Server) handleRenameWorkspace(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	var req struct {
-		Name string `json:"name"`
-	}
+	var req workspaceNameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -86,6 +276,7 @@ func (s *Server) handleRenameWorkspace(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.events.PublishBy(s.requestUser(r), events.WorkspaceUpdated, name, "", ws)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -97,7 +288,19 @@ func (s *Server) handleGetWorkspace(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	json.NewEncoder(w).Encode(ws)
+	ws.Versions = orderedVersions(ws.Versions)
+	if !s.seesSecrets(r) {
+		redacted := redactWorkspace(*ws)
+		ws = &redacted
+	}
+	switch fields := r.URL.Query().Get("fields"); fields {
+	case "":
+		json.NewEncoder(w).Encode(ws)
+	case "summary":
+		json.NewEncoder(w).Encode(summarizeWorkspace(*ws))
+	default:
+		http.Error(w, fmt.Sprintf("invalid fields %q, expected summary", fields), http.StatusBadRequest)
+	}
 }
 
 func (s *Server) handleCleanAllWorkspaceImages(w http.ResponseWriter, r *http.Request) {
@@ -110,29 +313,13 @@ func (s *Server) handleCleanAllWorkspaceImages(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Clean all versions and collect results
-	var results []CleanVersionResult
+	// Clean all versions and collect results, dryRun=true only lists what would be removed
+	report := CleanReport{DryRun: r.URL.Query().Get("dryRun") == "true", Versions: []CleanVersionResult{}}
 	for _, version := range ws.Versions {
-		instanceName := fmt.Sprintf("%s-%s", name, version.ID)
-		err := s.cleaner.CleanInstance(instanceName)
-		if err == nil {
-			// Reset ready state after successful clean
-			err = s.ResetVersionReadyState(name, version.ID)
-		}
-		results = append(results, CleanVersionResult{
-			VersionID: version.ID,
-			Error:     err,
-		})
-	}
-
-	errors := FormatCleanResults(results)
-
-	if len(errors) > 0 {
-		http.Error(w, fmt.Sprintf("Some operations failed: %v", strings.Join(errors, "; ")), http.StatusInternalServerError)
-		return
+		report.add(s.cleanVersion(name, version.ID, report.DryRun))
 	}
 
-	w.WriteHeader(http.StatusOK)
+	writeCleanReport(w, report)
 }
 
 func (s *Server) handleCleanAllImages(w http.ResponseWriter, r *http.Request) {
@@ -143,42 +330,42 @@ func (s *Server) handleCleanAllImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Clean all versions across all workspaces
-	var results []CleanVersionResult
+	// Clean all versions across all workspaces, dryRun=true only lists what would be removed
+	report := CleanReport{DryRun: r.URL.Query().Get("dryRun") == "true", Versions: []CleanVersionResult{}}
 	for _, ws := range workspaces {
 		for _, version := range ws.Versions {
-			instanceName := fmt.Sprintf("%s-%s", ws.Name, version.ID)
-			err := s.cleaner.CleanInstance(instanceName)
-			if err == nil {
-				// Reset ready state after successful clean
-				err = s.ResetVersionReadyState(ws.Name, version.ID)
-			}
-			results = append(results, CleanVersionResult{
-				VersionID: fmt.Sprintf("%s/%s", ws.Name, version.ID),
-				Error:     err,
-			})
+			report.add(s.cleanVersion(ws.Name, version.ID, report.DryRun))
 		}
 	}
 
-	errors := FormatCleanResults(results)
-
-	if len(errors) > 0 {
-		http.Error(w, fmt.Sprintf("Some operations failed: %v", strings.Join(errors, "; ")), http.StatusInternalServerError)
-		return
-	}
+	writeCleanReport(w, report)
+}
 
-	w.WriteHeader(http.StatusOK)
+// resourceHistoryRequest is the body of a resource-history query
+type resourceHistoryRequest struct {
+	Resource  string   `json:"resource"`
+	Resources []string `json:"resources"`
+	Selector  string   `json:"selector"`
 }
 
 func (s *Server) handleGetResourceHistory(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	var req struct {
-		Resource string `json:"resource"`
+	format, err := parseExportFormat(r, formatYAMLArchive)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	// Resources gets several resources at once, the results are then keyed by resource and version
+	var req resourceHistoryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.Resource != "" && len(req.Resources) > 0 {
+		http.Error(w, "resource and resources can't be combined", http.StatusBadRequest)
+		return
+	}
 
 	ws, err := s.store.GetWorkspace(name)
 	if err != nil {
@@ -186,32 +373,127 @@ func (s *Server) handleGetResourceHistory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	type VersionResult struct {
-		VersionID string `json:"versionID"`
-		Content   string `json:"content"`
-		Error     string `json:"error,omitempty"`
-		Status    string `json:"status"` // "found", "not_found", "stopped", "error"
+	resources := []string{req.Resource}
+	if len(req.Resources) > 0 {
+		resources = uniqueResources(req.Resources)
 	}
+	results := s.resourceHistories(ws, resources, req.Selector, nil)
 
-	var results []VersionResult
+	if format == formatYAMLArchive {
+		// One YAML file per resource and version it was found in
+		var files []archiveFile
+		for _, resource := range resources {
+			for _, result := range results[resource] {
+				if result.Status == "found" {
+					files = append(files, archiveFile{
+						Name:    exportFilename(".yaml", name, result.VersionID, resource),
+						Content: result.Content,
+					})
+				}
+			}
+		}
+		archiveName := exportFilename(".zip", name, req.Resource)
+		if len(req.Resources) > 0 {
+			archiveName = exportFilename(".zip", name, "resources")
+		}
+		if err := writeYAMLArchive(w, archiveName, files); err != nil {
+			fmt.Printf("Failed to write resource history archive: %v\n", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(req.Resources) == 0 {
+		json.NewEncoder(w).Encode(presentResources(ws, results[req.Resource]))
+		return
+	}
+	byVersion := make(map[string]map[string]ResourceHistoryResult, len(results))
+	for resource, history := range results {
+		byVersion[resource] = make(map[string]ResourceHistoryResult, len(history))
+		for _, result := range presentResources(ws, history) {
+			byVersion[resource][result.VersionID] = result
+		}
+	}
+	json.NewEncoder(w).Encode(byVersion)
+}
+
+// uniqueResources drops empty and repeated resources, keeping the order of the others
+func uniqueResources(resources []string) []string {
+	seen := make(map[string]bool, len(resources))
+	unique := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		if resource != "" && !seen[resource] {
+			seen[resource] = true
+			unique = append(unique, resource)
+		}
+	}
+	return unique
+}
+
+// ResourceHistoryResult is the state of a resource in a single version
+type ResourceHistoryResult struct {
+	VersionID string `json:"versionID"`
+	Content   string `json:"content"`
+	Error     string `json:"error,omitempty"`
+	Status    string `json:"status"` // "found", "not_found", "stopped", "error", "missing"
+	// SnapshotAt is set when the simulator is stopped and the result is from the snapshot captured then
+	SnapshotAt *time.Time `json:"snapshotAt,omitempty"`
+	// Source is "bundle-file" when the simulator is stopped and the result is from the extracted bundle
+	Source string `json:"source,omitempty"`
+}
+
+// resourceHistory gets a resource as YAML from every version of the workspace, or only from
+// versionIDs when given. Requested versions that don't exist are reported as missing.
+func (s *Server) resourceHistory(ws *model.Workspace, resource, selector string, versionIDs []string) []ResourceHistoryResult {
+	return s.resourceHistories(ws, []string{resource}, selector, versionIDs)[resource]
+}
+
+// resourceHistories is resourceHistory for several resources, keyed by resource. The container of
+// each version is checked once and resources of the same type are got with a single kubectl call.
+func (s *Server) resourceHistories(ws *model.Workspace, resources []string, selector string, versionIDs []string) map[string][]ResourceHistoryResult {
+	results := make(map[string][]ResourceHistoryResult, len(resources))
+	addAll := func(result ResourceHistoryResult) {
+		for _, resource := range resources {
+			results[resource] = append(results[resource], result)
+		}
+	}
+
+	for _, v := range orderedVersions(ws.Versions) {
+		if len(versionIDs) > 0 && !slices.Contains(versionIDs, v.ID) {
+			continue
+		}
 
-	for _, v := range ws.Versions {
 		if v.Type != model.VersionTypeRuntime {
-			instanceName := fmt.Sprintf("%s-%s", name, v.ID)
+			instanceName := v.InstanceName(ws.Name)
 			containers, err := s.docker.FindRunningContainer(instanceName)
 			if err != nil || len(containers) == 0 {
-				results = append(results, VersionResult{
-					VersionID: v.ID,
-					Status:    "stopped",
-					Error:     "Container not running",
-				})
+				snap, hasSnapshot := s.stoppedSnapshot(ws.Name, v)
+				// The extracted bundle answers what no snapshot does
+				files := s.stoppedBundleFiles(ws.Name, v)
+				for _, resource := range resources {
+					result := ResourceHistoryResult{Status: "stopped", Error: "Container not running"}
+					if hasSnapshot {
+						if captured, ok := snap.result(resource, selector, ws.DefaultNamespace); ok {
+							result = captured
+						} else {
+							result.Error = fmt.Sprintf("Container not running and the snapshot captured at %s doesn't hold the resource", snap.CapturedAt.Format(time.RFC3339))
+						}
+					}
+					if result.Status == "stopped" {
+						if fromFile, ok := files.result(resource, selector, ws.DefaultNamespace); ok {
+							result = fromFile
+						}
+					}
+					result.VersionID = v.ID
+					results[resource] = append(results[resource], result)
+				}
 				continue
 			}
 		}
 
-		exec, err := s.GetExecutor(name, v.ID)
+		exec, err := s.GetExecutor(ws.Name, v.ID)
 		if err != nil {
-			results = append(results, VersionResult{
+			addAll(ResourceHistoryResult{
 				VersionID: v.ID,
 				Status:    "error",
 				Error:     err.Error(),
@@ -219,48 +501,195 @@ func (s *Server) handleGetResourceHistory(w http.ResponseWriter, r *http.Request
 			continue
 		}
 
-		// Execute kubectl get <resource> -o yaml
-		// Support format: namespace/type/name or type/name
-		parts := strings.Split(req.Resource, "/")
-		var args []string
-		if len(parts) == 3 {
-			namespace := parts[0]
-			resourceType := parts[1]
-			resourceName := parts[2]
-			args = []string{"get", resourceType, resourceName, "-n", namespace, "-o", "yaml"}
-		} else {
-			args = []string{"get", req.Resource, "-o", "yaml"}
+		found := getResources(exec, resources, selector, ws.DefaultNamespace)
+		for _, resource := range resources {
+			result := found[resource]
+			result.VersionID = v.ID
+			results[resource] = append(results[resource], result)
 		}
+	}
 
-		stdout, stderr, err := utils.ExecKubectl(exec, args...)
-
-		if err != nil {
-			results = append(results, VersionResult{
-				VersionID: v.ID,
-				Status:    "error",
-				Error:     err.Error(),
+	for _, id := range versionIDs {
+		if !HasVersionInWorkspace(ws, id) {
+			addAll(ResourceHistoryResult{
+				VersionID: id,
+				Status:    "missing",
+				Error:     "Version no longer exists",
 			})
+		}
+	}
+
+	return results
+}
+
+// resourceBatch holds the named resources of one type and namespace, kubectl gets them in one call
+type resourceBatch struct {
+	namespace    string
+	resourceType string
+	names        []string
+	// resources are the requested resources, in the order of names
+	resources []string
+}
+
+// getResources gets resources as YAML from a version, results are keyed by resource and have no
+// VersionID. Named resources of the same type are batched, the others are got one by one.
+func getResources(exec executor.Executor, resources []string, selector, defaultNamespace string) map[string]ResourceHistoryResult {
+	results := make(map[string]ResourceHistoryResult, len(resources))
+	var batches []*resourceBatch
+	byType := make(map[string]*resourceBatch)
+	for _, resource := range resources {
+		namespace, resourceType, name, ok := splitResource(resource, defaultNamespace)
+		// kubectl doesn't take names together with a selector
+		if !ok || selector != "" {
+			results[resource] = getResource(exec, resourceHistoryArgs(resource, selector, defaultNamespace))
 			continue
 		}
+		key := namespace + "/" + resourceType
+		batch, ok := byType[key]
+		if !ok {
+			batch = &resourceBatch{namespace: namespace, resourceType: resourceType}
+			byType[key] = batch
+			batches = append(batches, batch)
+		}
+		batch.names = append(batch.names, name)
+		batch.resources = append(batch.resources, resource)
+	}
 
-		if stderr != "" {
-			results = append(results, VersionResult{
-				VersionID: v.ID,
-				Status:    "not_found",
-				Error:     stderr,
-			})
+	for _, batch := range batches {
+		// A single resource is printed as is rather than as a list
+		if len(batch.names) == 1 {
+			resource := batch.resources[0]
+			results[resource] = getResource(exec, resourceHistoryArgs(resource, "", defaultNamespace))
 			continue
 		}
+		for resource, result := range getResourceBatch(exec, batch) {
+			results[resource] = result
+		}
+	}
+	return results
+}
+
+// splitResource splits namespace/type/name and type/name, resources of the latter form are in
+// defaultNamespace. ok is false for resources without a name.
+func splitResource(resource, defaultNamespace string) (namespace, resourceType, name string, ok bool) {
+	parts := strings.Split(resource, "/")
+	switch {
+	case len(parts) == 3 && parts[2] != "":
+		return parts[0], parts[1], parts[2], true
+	case len(parts) == 2 && parts[1] != "":
+		return defaultNamespace, parts[0], parts[1], true
+	}
+	return "", "", "", false
+}
 
-		results = append(results, VersionResult{
-			VersionID: v.ID,
-			Status:    "found",
-			Content:   stdout,
-		})
+// getResource runs kubectl with args, a NotFound error from the server is reported as the resource
+// not being found
+func getResource(exec executor.Executor, args []string) ResourceHistoryResult {
+	out, err := utils.ExecKubectl(exec, args...)
+	status, err := utils.KubectlStatus(out, err)
+	if err != nil {
+		return ResourceHistoryResult{Status: status, Error: err.Error()}
 	}
+	return ResourceHistoryResult{Status: status, Content: out.Stdout}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+// getResourceBatch gets the resources of a batch with one kubectl call. kubectl prints the ones it
+// found as a list and an error line for each missing one, so a missing resource doesn't hide the others.
+func getResourceBatch(exec executor.Executor, batch *resourceBatch) map[string]ResourceHistoryResult {
+	args := append([]string{"get", batch.resourceType}, batch.names...)
+	if batch.namespace != "" {
+		args = append(args, "-n", batch.namespace)
+	}
+	args = append(args, "-o", "yaml")
+	out, err := utils.ExecKubectl(exec, args...)
+	status, err := utils.KubectlStatus(out, err)
+	items, parseErr := splitKubectlList(out.Stdout)
+
+	results := make(map[string]ResourceHistoryResult, len(batch.resources))
+	for i, name := range batch.names {
+		var result ResourceHistoryResult
+		if content, ok := items[name]; ok {
+			result = ResourceHistoryResult{Status: utils.KubectlFound, Content: content}
+		} else if parseErr != nil {
+			result = ResourceHistoryResult{Status: utils.KubectlError, Error: fmt.Sprintf("failed to parse output: %v", parseErr)}
+		} else if status == utils.KubectlError {
+			result = ResourceHistoryResult{Status: status, Error: err.Error()}
+		} else if line := stderrLineOf(out.Stderr, name); line != "" {
+			result = ResourceHistoryResult{Status: utils.KubectlNotFound, Error: line}
+		} else {
+			result = ResourceHistoryResult{Status: utils.KubectlNotFound, Error: fmt.Sprintf("%s %q not found", batch.resourceType, name)}
+		}
+		results[batch.resources[i]] = result
+	}
+	return results
+}
+
+// splitKubectlList returns the YAML of each item of a list printed by kubectl, keyed by name
+func splitKubectlList(stdout string) (map[string]string, error) {
+	items := make(map[string]string)
+	if strings.TrimSpace(stdout) == "" {
+		return items, nil
+	}
+	var list struct {
+		Items []yaml.Node `yaml:"items"`
+	}
+	if err := yaml.Unmarshal([]byte(stdout), &list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		var item struct {
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := list.Items[i].Decode(&item); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&list.Items[i]); err != nil {
+			return nil, err
+		}
+		enc.Close()
+		items[item.Metadata.Name] = buf.String()
+	}
+	return items, nil
+}
+
+// stderrLineOf returns the line of kubectl's stderr about the resource name, e.g.
+// Error from server (NotFound): pods "name" not found
+func stderrLineOf(stderr, name string) string {
+	quoted := fmt.Sprintf("%q", name)
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.Contains(line, quoted) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// resourceHistoryArgs builds the kubectl arguments to get a resource as YAML
+// Support format: namespace/type/name or type/name
+func resourceHistoryArgs(resource, selector, defaultNamespace string) []string {
+	parts := strings.Split(resource, "/")
+	var args []string
+	if len(parts) == 3 {
+		namespace := parts[0]
+		resourceType := parts[1]
+		resourceName := parts[2]
+		args = []string{"get", resourceType, resourceName, "-n", namespace, "-o", "yaml"}
+	} else {
+		args = []string{"get", resource, "-o", "yaml"}
+		// kubectl ignores the namespace for cluster scoped resources
+		if defaultNamespace != "" {
+			args = append(args, "-n", defaultNamespace)
+		}
+	}
+	if selector != "" {
+		args = append(args, "-l", selector)
+	}
+	return args
 }
 
 func (s *Server) handleGetNamespaces(w http.ResponseWriter, r *http.Request) {
@@ -274,6 +703,13 @@ func (s *Server) handleGetNamespaces(w http.ResponseWriter, r *http.Request) {
 
 	var exec executor.Executor
 	if versionID != "" {
+		// A stopped simulator answers from its snapshot
+		if version, ok := findVersion(ws, versionID); ok {
+			if snap, ok := s.stoppedSnapshot(name, version); ok {
+				writeSnapshotAnswer(w, snap, snap.Namespaces)
+				return
+			}
+		}
 		var err error
 		exec, err = s.GetExecutor(name, versionID)
 		if err != nil {
@@ -282,20 +718,24 @@ func (s *Server) handleGetNamespaces(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		var err error
-		exec, err = utils.FindLatestAvailableExecutor(name, ws, s.docker)
+		exec, err = utils.FindLatestAvailableExecutor(name, ws, s.docker, s.dataDir, s.kubectl.Path)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			if snap, ok := s.latestStoppedSnapshot(ws); ok {
+				writeSnapshotAnswer(w, snap, snap.Namespaces)
+				return
+			}
+			s.writeNoExecutor(w, r, ws, err)
 			return
 		}
 	}
 
-	stdout, _, err := utils.ExecKubectl(exec, "get", "namespaces", "-o", "jsonpath={.items[*].metadata.name}")
-	if err != nil {
+	out, err := utils.ExecKubectl(exec, "get", "namespaces", "-o", "jsonpath={.items[*].metadata.name}")
+	if _, err = utils.KubectlStatus(out, err); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	namespaces := strings.Split(strings.TrimSpace(stdout), " ")
+	namespaces := strings.Split(strings.TrimSpace(out.Stdout), " ")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(namespaces)
 }
@@ -308,19 +748,23 @@ func (s *Server) handleGetResourceTypes(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	exec, err := utils.FindLatestAvailableExecutor(name, ws, s.docker)
+	exec, err := utils.FindLatestAvailableExecutor(name, ws, s.docker, s.dataDir, s.kubectl.Path)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		if snap, ok := s.latestStoppedSnapshot(ws); ok {
+			writeSnapshotAnswer(w, snap, snap.ResourceTypes)
+			return
+		}
+		s.writeNoExecutor(w, r, ws, err)
 		return
 	}
 
-	stdout, _, err := utils.ExecKubectl(exec, "api-resources", "--verbs=list", "-o", "name")
-	if err != nil {
+	out, err := utils.ExecKubectl(exec, "api-resources", "--verbs=list", "-o", "name")
+	if _, err = utils.KubectlStatus(out, err); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	resources := strings.Split(strings.TrimSpace(stdout), "\n")
+	resources := strings.Split(strings.TrimSpace(out.Stdout), "\n")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resources)
 }
@@ -331,9 +775,11 @@ func (s *Server) handleGetResources(w http.ResponseWriter, r *http.Request) {
 	resourceType := r.URL.Query().Get("resourceType")
 	keyword := r.URL.Query().Get("keyword")
 	versionID := r.URL.Query().Get("version")
+	mode := r.URL.Query().Get("mode")
 
-	if namespace == "" || resourceType == "" {
-		http.Error(w, "namespace and resourceType are required", http.StatusBadRequest)
+	params, err := parseListParams(r, "name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -343,91 +789,128 @@ func (s *Server) handleGetResources(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resourceMap := make(map[string]bool)
+	if namespace == "" {
+		namespace = ws.DefaultNamespace
+	}
+	if namespace == "" || resourceType == "" {
+		http.Error(w, "namespace and resourceType are required", http.StatusBadRequest)
+		return
+	}
+
+	switch mode {
+	case "", "name":
+	case "content":
+		s.searchResourcesContent(w, r, ws, namespace, resourceType, keyword, versionID, params)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("invalid mode %q, expected name or content", mode), http.StatusBadRequest)
+		return
+	}
+
+	collected := s.collectResourceNames(s.docker, func(versionID string) (executor.Executor, error) {
+		return s.GetExecutor(name, versionID)
+	}, ws, namespace, resourceType, versionID)
+
+	// Without autostart nothing running lists no resources
+	if collected.available == 0 && versionID == "" && r.URL.Query().Get("autostart") == "true" {
+		s.writeNoExecutor(w, r, ws, fmt.Errorf("no running simulator or runtime cluster found"))
+		return
+	}
+
+	filtered := make([]string, 0)
+	for res := range collected.names {
+		if keyword == "" || strings.Contains(res, keyword) {
+			filtered = append(filtered, res)
+		}
+	}
+	sort.Strings(filtered)
+	filtered = paginate(w, filtered, params, nil)
+
+	if collected.snapshotAt != nil {
+		w.Header().Set(snapshotHeader, collected.snapshotAt.Format(time.RFC3339))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("include") == "versions" {
+		json.NewEncoder(w).Encode(ResourceList{Names: filtered, VersionsQueried: collected.queried, VersionsSkipped: collected.skipped})
+		return
+	}
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// searchResourcesContent answers handleGetResources with mode=content, the YAML of the resources of
+// every running version is searched for keyword
+func (s *Server) searchResourcesContent(w http.ResponseWriter, r *http.Request, ws *model.Workspace, namespace, resourceType, keyword, versionID string, params listParams) {
+	// Dumping several types, or all of them, would be too slow to do for a search
+	if strings.Contains(resourceType, ",") || resourceType == "all" {
+		http.Error(w, "mode=content searches a single resource type per request", http.StatusBadRequest)
+		return
+	}
+	if keyword == "" {
+		http.Error(w, "mode=content requires a keyword", http.StatusBadRequest)
+		return
+	}
 
+	matches := make([]contentMatch, 0)
 	for _, v := range ws.Versions {
 		if versionID != "" && v.ID != versionID {
 			continue
 		}
 
 		if v.Type != model.VersionTypeRuntime {
-			instanceName := fmt.Sprintf("%s-%s", name, v.ID)
+			instanceName := v.InstanceName(ws.Name)
 			containers, err := s.docker.FindRunningContainer(instanceName)
-			if err != nil || len(containers) == 0 {
+			// A simulator still loading its bundle doesn't answer yet
+			if err != nil || len(containers) == 0 || !v.Ready {
 				continue
 			}
 		}
 
-		exec, err := s.GetExecutor(name, v.ID)
+		exec, err := s.GetExecutor(ws.Name, v.ID)
 		if err != nil {
 			continue
 		}
 
-		stdout, _, err := utils.ExecKubectl(exec, "get", resourceType, "-n", namespace, "-o", "jsonpath={.items[*].metadata.name}")
+		found, err := searchResourceContent(r.Context(), exec, namespace, resourceType, keyword, v.ID)
 		if err != nil {
+			fmt.Printf("Content search of %s in %s/%s failed: %v\n", resourceType, ws.Name, v.ID, err)
 			continue
 		}
-
-		resources := strings.Split(strings.TrimSpace(stdout), " ")
-		for _, res := range resources {
-			if res != "" {
-				resourceMap[res] = true
-			}
-		}
+		matches = append(matches, found...)
 	}
 
-	filtered := make([]string, 0)
-	for res := range resourceMap {
-		if keyword == "" || strings.Contains(res, keyword) {
-			filtered = append(filtered, res)
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
 		}
-	}
-	sort.Strings(filtered)
+		return versionNumber(matches[i].VersionID) < versionNumber(matches[j].VersionID)
+	})
+	matches = paginate(w, matches, params, nil)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(filtered)
+	json.NewEncoder(w).Encode(matches)
 }
 
 func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	// Marked before the lookup, a concurrent delete could otherwise remove a workspace created since
+	if !s.beginDelete(name) {
+		http.Error(w, "Workspace is already being deleted", http.StatusConflict)
+		return
+	}
 	ws, err := s.store.GetWorkspace(name)
 	if err != nil {
+		s.endDelete(name)
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-
-	// Cleanup all versions
-	for _, v := range ws.Versions {
-		instanceName := fmt.Sprintf("%s-%s", name, v.ID)
-
-		// Remove container
-		if err := s.docker.RemoveContainer(instanceName); err != nil {
-			fmt.Printf("Failed to remove container %s: %v\n", instanceName, err)
-		}
-
-		// Remove images
-		_ = s.docker.RemoveImages(instanceName)
-
-		// Cleanup code-server directory
-		codeServerContainer := "sim-cli-code-server"
-		targetDir := fmt.Sprintf("/home/coder/project/%s-%s", name, v.ID)
-		if _, _, err := s.docker.ExecContainer(codeServerContainer, []string{"rm", "-rf", targetDir}, nil); err != nil {
-			fmt.Printf("Failed to cleanup code-server directory: %v\n", err)
-		}
-	}
-
-	// Remove workspace directory
-	workspacePath := fmt.Sprintf("%s/workspaces/%s", s.dataDir, name)
-	if err := os.RemoveAll(workspacePath); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to remove workspace files: %v", err), http.StatusInternalServerError)
+	if s.refuseCaseCollision(w, name) {
+		s.endDelete(name)
 		return
 	}
 
-	// Delete from store
-	if err := s.store.DeleteWorkspace(name); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
+	// Removing the simulators takes a while, the job goes on when the client disconnects
+	job := s.startDeleteJob(*ws, r.URL.Query().Get("permanent") == "true")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
 }
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+)
+
+// deleteParallelism bounds the versions of a workspace whose simulators are removed at once
+const deleteParallelism = 4
+
+// deleteDrainTimeout is how long a delete waits for the cancelled requests of its workspace to return
+// before it removes the simulators and files anyway
+const deleteDrainTimeout = 30 * time.Second
+
+// readableWhileDeleting are the routes of a workspace still answered while it is deleted, they neither
+// change it nor run anything in its simulators. The delete itself is refused by beginDelete instead.
+var readableWhileDeleting = map[string]bool{
+	"GET /api/workspaces/{name}":          true,
+	"GET /api/workspaces/{name}/versions": true,
+	"GET /api/workspaces/{name}/activity": true,
+	"DELETE /api/workspaces/{name}":       true,
+}
+
+// workspaceRequest is a request to a workspace in flight, cancelled when the workspace is deleted
+type workspaceRequest struct {
+	cancel context.CancelFunc
+	// done is closed once the handler returned
+	done chan struct{}
+}
+
+// refuseDeleting answers 409 to the requests of a workspace being deleted. Requests that got through
+// before the delete are cancelled by it and waited for, so nothing is started for a workspace whose
+// simulators are being removed.
+func (s *Server) refuseDeleting(rt route, next http.HandlerFunc) http.HandlerFunc {
+	if !strings.HasPrefix(rt.Path, "/api/workspaces/{name}") || readableWhileDeleting[rt.Method+" "+rt.Path] {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, leave, ok := s.enterWorkspace(r.Context(), r.PathValue("name"))
+		if !ok {
+			http.Error(w, "Workspace is being deleted", http.StatusConflict)
+			return
+		}
+		defer leave()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// enterWorkspace registers a request to the workspace name until leave is called, ctx is cancelled when
+// the workspace is deleted. It returns false while the workspace is deleted or was left marked by a
+// delete that didn't finish.
+func (s *Server) enterWorkspace(parent context.Context, name string) (ctx context.Context, leave func(), ok bool) {
+	s.workspaceLock.Lock()
+	defer s.workspaceLock.Unlock()
+	key := store.NameKey(name)
+	if s.deleting[key] {
+		return nil, nil, false
+	}
+	if ws, err := s.store.GetWorkspace(name); err == nil && ws.State == model.WorkspaceStateDeleting {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	request := &workspaceRequest{cancel: cancel, done: make(chan struct{})}
+	if s.workspaceRequests == nil {
+		s.workspaceRequests = make(map[string]map[*workspaceRequest]bool)
+	}
+	if s.workspaceRequests[key] == nil {
+		s.workspaceRequests[key] = make(map[*workspaceRequest]bool)
+	}
+	s.workspaceRequests[key][request] = true
+
+	leave = func() {
+		s.workspaceLock.Lock()
+		defer s.workspaceLock.Unlock()
+		delete(s.workspaceRequests[key], request)
+		if len(s.workspaceRequests[key]) == 0 {
+			delete(s.workspaceRequests, key)
+		}
+		cancel()
+		close(request.done)
+	}
+	return ctx, leave, true
+}
+
+// drainRequests cancels the requests in flight to a workspace marked by beginDelete and waits up to
+// deleteDrainTimeout for them to return
+func (s *Server) drainRequests(name string) {
+	s.workspaceLock.Lock()
+	var pending []*workspaceRequest
+	for request := range s.workspaceRequests[store.NameKey(name)] {
+		request.cancel()
+		pending = append(pending, request)
+	}
+	s.workspaceLock.Unlock()
+
+	timeout := time.NewTimer(deleteDrainTimeout)
+	defer timeout.Stop()
+	for _, request := range pending {
+		select {
+		case <-request.done:
+		case <-timeout.C:
+			log.Printf("Deleting workspace %s while %d requests to it are still running", name, len(pending))
+			return
+		}
+	}
+}
+
+// deleteWorkspace removes the simulators of a workspace and moves it to the trash, or removes its files
+// for good with permanent. The workspace is marked in the store first, a server stop or failure during
+// the cleanup leaves the mark for the consistency repair. The versions are cleaned deleteParallelism at
+// a time, progress is called once each is done. Cancelling ctx stops before the versions not started on
+// yet and keeps the workspace. beginDelete must be held.
+func (s *Server) deleteWorkspace(ctx context.Context, ws model.Workspace, permanent bool, progress func(instanceName string, results []CleanupResult)) error {
+	if ws.State != model.WorkspaceStateDeleting {
+		marked := ws
+		marked.State = model.WorkspaceStateDeleting
+		if err := s.store.UpdateWorkspace(marked); err != nil {
+			return fmt.Errorf("Failed to mark the workspace as deleted: %w", err)
+		}
+	}
+	// The trash keeps the workspace as it was before the delete
+	ws.State = ""
+
+	// The files go with the workspace directory below, the simulators and code-server copies are cleaned
+	// per version and whatever is left of them only reported
+	var (
+		lock    sync.Mutex
+		cleaned = make(map[string]bool)
+		wg      sync.WaitGroup
+	)
+	versions := make(chan model.Version)
+	for i := 0; i < min(deleteParallelism, len(ws.Versions)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range versions {
+				instanceName := v.InstanceName(ws.Name)
+				s.forgetAccess(instanceName)
+				results := []CleanupResult{s.cleanCodeServer(ws.Name, v)}
+				if v.Type != model.VersionTypeRuntime {
+					results = append(results, s.cleanRuntime(instanceName, false))
+				}
+				logCleanupFailures(instanceName, results)
+				lock.Lock()
+				cleaned[v.ID] = true
+				lock.Unlock()
+				if progress != nil {
+					progress(instanceName, results)
+				}
+			}
+		}()
+	}
+	for _, v := range ws.Versions {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case versions <- v:
+		case <-ctx.Done():
+		}
+	}
+	close(versions)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, s.cancelDelete(ws.Name, cleaned))
+	}
+
+	if !permanent {
+		// Kept in the trash with its files and bundles until restored or purged
+		if err := s.trashWorkspace(ws); err != nil {
+			return fmt.Errorf("Failed to move workspace to the trash: %w", err)
+		}
+		return nil
+	}
+
+	// Remove workspace directory
+	if err := os.RemoveAll(filepath.Join(s.dataDir, "workspaces", ws.Name)); err != nil {
+		return fmt.Errorf("Failed to remove workspace files: %w", err)
+	}
+	return s.store.DeleteWorkspace(ws.Name)
+}
+
+// cancelDelete clears the mark of a workspace whose delete was cancelled. The versions in cleaned lost
+// their simulators, they are no longer ready and build them again on the next start.
+func (s *Server) cancelDelete(name string, cleaned map[string]bool) error {
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		return err
+	}
+	ws.State = ""
+	for i := range ws.Versions {
+		if cleaned[ws.Versions[i].ID] {
+			ws.Versions[i].Ready = false
+		}
+	}
+	if err := s.store.UpdateWorkspace(*ws); err != nil {
+		return fmt.Errorf("Failed to keep the workspace of the cancelled delete: %w", err)
+	}
+	return nil
+}
+
+// finishDelete moves a workspace left marked by an interrupted delete to the trash
+func (s *Server) finishDelete(name string) error {
+	if !s.beginDelete(name) {
+		return errWorkspaceDeleting
+	}
+	defer s.endDelete(name)
+	ws, err := s.store.GetWorkspace(name)
+	if err != nil {
+		return err
+	}
+	if ws.State != model.WorkspaceStateDeleting {
+		return nil
+	}
+	return s.deleteWorkspace(context.Background(), *ws, false, nil)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StartDuringDelete(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	mux := http.NewServeMux()
+	entered := make(chan struct{})
+	cancelled := make(chan struct{})
+	started := 0
+	start := func(w http.ResponseWriter, r *http.Request) {
+		started++
+		if started == 1 {
+			// A start that got through before the delete runs until the delete cancels it
+			close(entered)
+			<-r.Context().Done()
+			close(cancelled)
+		}
+	}
+	for _, rt := range []route{
+		{Method: "POST", Path: "/api/workspaces/{name}/versions/{versionID}/start", handler: start},
+		{Method: "GET", Path: "/api/workspaces/{name}", handler: func(w http.ResponseWriter, r *http.Request) {}},
+	} {
+		mux.HandleFunc(rt.Method+" "+rt.Path, s.refuseDeleting(rt, rt.handler))
+	}
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("POST", "/api/workspaces/ws/versions/v1/start") }()
+	<-entered
+
+	assert.True(s.beginDelete("ws"))
+	drained := make(chan struct{})
+	go func() {
+		s.drainRequests("ws")
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delete didn't wait for the start to return")
+	}
+	<-cancelled
+	assert.Equal(http.StatusOK, <-done)
+
+	// Starts during the delete are refused, the workspace can still be read
+	assert.Equal(http.StatusConflict, serve("POST", "/api/workspaces/WS/versions/v1/start"))
+	assert.Equal(http.StatusOK, serve("GET", "/api/workspaces/ws"))
+	s.endDelete("ws")
+	assert.Equal(http.StatusOK, serve("POST", "/api/workspaces/ws/versions/v1/start"))
+	assert.Empty(s.workspaceRequests)
+
+	// A delete cut short leaves the mark, requests are refused until the repair finished it
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	ws.State = model.WorkspaceStateDeleting
+	assert.NoError(s.store.UpdateWorkspace(*ws))
+	assert.Equal(http.StatusConflict, serve("POST", "/api/workspaces/ws/versions/v1/start"))
+}
+
+func Test_FinishInterruptedDelete(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newFilesServer(t)
+	ws, err := s.store.GetWorkspace("ws")
+	assert.NoError(err)
+	ws.State = model.WorkspaceStateDeleting
+	assert.NoError(s.store.UpdateWorkspace(*ws))
+
+	issues, err := s.CheckConsistency()
+	assert.NoError(err)
+	assert.Equal([]ConsistencyIssue{{Type: IssueDeleteInterrupted, Workspace: "ws", Message: "marked as being deleted but no delete is running"}}, issues)
+
+	results, err := s.RepairConsistency(RepairMark)
+	assert.NoError(err)
+	assert.Len(results, 1)
+	assert.Empty(results[0].Error)
+	_, err = s.store.GetWorkspace("ws")
+	assert.Error(err)
+
+	// The trash keeps the workspace as it was before the delete
+	entries, err := s.listTrash()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Empty(entries[0].WorkspaceRecord.State)
+	issues, err = s.CheckConsistency()
+	assert.NoError(err)
+	assert.Empty(issues)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/events"
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	"github.com/stretchr/testify/require"
+)
+
+func newWorkspaceServer(t *testing.T) (*Server, *http.ServeMux) {
+	dataDir := t.TempDir()
+	st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+	require.NoError(t, err)
+	s := &Server{store: st, events: events.NewBus(), dataDir: dataDir}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/workspaces", s.handleCreateWorkspace)
+	mux.HandleFunc("DELETE /api/workspaces/{name}", s.handleDeleteWorkspace)
+	mux.HandleFunc("POST /api/trash/{id}/restore", s.handleRestoreTrash)
+	return s, mux
+}
+
+func serve(mux *http.ServeMux, method, path, body string) int {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec.Code
+}
+
+func Test_CreateWorkspaceRace(t *testing.T) {
+	assert := require.New(t)
+	s, mux := newWorkspaceServer(t)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 20)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := "ws"
+			if i%2 == 1 {
+				name = "WS"
+			}
+			codes[i] = serve(mux, "POST", "/api/workspaces", `{"name":"`+name+`"}`)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			assert.Equal(http.StatusConflict, code)
+		}
+	}
+	assert.Equal(1, created)
+	workspaces, err := s.store.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(workspaces, 1)
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, "workspaces"))
+	assert.NoError(err)
+	assert.Len(entries, 1, "expected the directories of the losers to be removed")
+	assert.Equal(workspaces[0].Name, entries[0].Name())
+}
+
+func Test_CreateDeleteWorkspaceRace(t *testing.T) {
+	assert := require.New(t)
+	s, mux := newWorkspaceServer(t)
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		codes  = make(map[string]map[int]int)
+		record = func(method string, code int) {
+			lock.Lock()
+			defer lock.Unlock()
+			if codes[method] == nil {
+				codes[method] = make(map[int]int)
+			}
+			codes[method][code]++
+		}
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				record("POST", serve(mux, "POST", "/api/workspaces", `{"name":"ws"}`))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				record("DELETE", serve(mux, "DELETE", "/api/workspaces/ws?permanent=true", ""))
+			}
+		}()
+		// deleted to the trash and restored, the restore creates the workspace again
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				record("DELETE", serve(mux, "DELETE", "/api/workspaces/ws", ""))
+				entries, err := s.listTrash()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, entry := range entries {
+					record("RESTORE", serve(mux, "POST", "/api/trash/"+entry.ID+"/restore", ""))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for code := range codes["POST"] {
+		assert.Contains([]int{http.StatusCreated, http.StatusConflict}, code)
+	}
+	for code := range codes["DELETE"] {
+		assert.Contains([]int{http.StatusAccepted, http.StatusNotFound, http.StatusConflict}, code)
+	}
+	for code := range codes["RESTORE"] {
+		assert.Contains([]int{http.StatusOK, http.StatusNotFound, http.StatusConflict}, code)
+	}
+	assert.Positive(codes["POST"][http.StatusCreated])
+	waitDeleteJobs(t, s)
+	_, err := s.store.GetWorkspace("ws")
+	_, statErr := os.Stat(filepath.Join(s.dataDir, "workspaces", "ws"))
+	assert.Equal(err == nil, statErr == nil, "expected the directory to exist exactly when the workspace does")
+	assert.Empty(s.deleting)
+}
+
+func Test_CreateWorkspaceWhileDeleting(t *testing.T) {
+	assert := require.New(t)
+	s, _ := newWorkspaceServer(t)
+	ws := model.Workspace{Name: "ws", CreatedAt: time.Now()}
+
+	assert.True(s.beginDelete("WS"))
+	assert.False(s.beginDelete("ws"))
+	assert.ErrorIs(s.createWorkspace(ws), errWorkspaceDeleting)
+	s.endDelete("WS")
+
+	dir := filepath.Join(s.dataDir, "workspaces", "ws")
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "v1"), 0755))
+	assert.ErrorIs(s.createWorkspace(ws), errWorkspaceFilesLeft)
+	_, err := s.store.GetWorkspace("ws")
+	assert.Error(err)
+
+	// An empty directory left behind is taken over
+	assert.NoError(os.Remove(filepath.Join(dir, "v1")))
+	assert.NoError(s.createWorkspace(ws))
+	assert.ErrorIs(s.createWorkspace(model.Workspace{Name: "Ws"}), os.ErrExist)
+	assert.DirExists(dir)
+}
+
+func Test_CreateWorkspaceNames(t *testing.T) {
+	assert := require.New(t)
+	_, mux := newWorkspaceServer(t)
+
+	for _, name := range []string{"", "  ", ".", "..", "a/b", `a\\b`, "a\\tb"} {
+		assert.Equal(http.StatusBadRequest, serve(mux, "POST", "/api/workspaces", `{"name":"`+name+`"}`), name)
+	}
+	// Simulators of names docker doesn't accept get shortened instance names
+	for _, name := range []string{"customer-escalation-2024-prod-cluster-east-with-a-very-long-suffix", "my workspace", ".hidden"} {
+		assert.Equal(http.StatusCreated, serve(mux, "POST", "/api/workspaces", `{"name":"`+name+`"}`), name)
+	}
+}
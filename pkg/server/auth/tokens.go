@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileName is the tokens file in the data directory, the server requires tokens when it exists
+const FileName = "tokens.json"
+
+// Role is what the holder of a token may do
+type Role string
+
+const (
+	// RoleAdmin may do everything, including changes outside a single workspace
+	RoleAdmin Role = "admin"
+	// RoleEditor may create workspaces and change the ones it owns
+	RoleEditor Role = "editor"
+	// RoleViewer may only read
+	RoleViewer Role = "viewer"
+)
+
+// Identity is who a token was issued to
+type Identity struct {
+	User string `json:"user"`
+	Role Role   `json:"role"`
+	// Workspaces are changed by the identity as their owner besides those it created
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+// Owns reports whether the identity may change the workspace name created by createdBy
+func (i Identity) Owns(name, createdBy string) bool {
+	if createdBy != "" && createdBy == i.User {
+		return true
+	}
+	for _, ws := range i.Workspaces {
+		if ws == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenEntry is a token of the tokens file
+type tokenEntry struct {
+	Token string `json:"token"`
+	Identity
+}
+
+// tokensFile is the content of the tokens file, e.g.
+//
+//	{"tokens": [{"token": "s3cr3t", "user": "alice", "role": "editor", "workspaces": ["case-1234"]}]}
+type tokensFile struct {
+	Tokens []tokenEntry `json:"tokens"`
+}
+
+// Tokens maps the bearer tokens of a tokens file to the identities they were issued to
+type Tokens struct {
+	path string
+
+	lock sync.RWMutex
+	// identities are keyed by the SHA-256 of the token, so looking one up takes the same time for any token
+	identities map[[sha256.Size]byte]Identity
+}
+
+// Load reads the tokens file at path
+func Load(path string) (*Tokens, error) {
+	t := &Tokens{path: path}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Path returns the tokens file
+func (t *Tokens) Path() string {
+	return t.path
+}
+
+// Reload reads the tokens file again, e.g. on SIGHUP. The tokens loaded before are kept when it fails.
+func (t *Tokens) Reload() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+	var file tokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", t.path, err)
+	}
+
+	identities := make(map[[sha256.Size]byte]Identity, len(file.Tokens))
+	for i, entry := range file.Tokens {
+		if entry.Token == "" || entry.User == "" {
+			return fmt.Errorf("token %d of %s needs a token and a user", i+1, t.path)
+		}
+		switch entry.Role {
+		case RoleAdmin, RoleEditor, RoleViewer:
+		default:
+			return fmt.Errorf("token of %s in %s has unknown role %q, use admin, editor or viewer", entry.User, t.path, entry.Role)
+		}
+		key := sha256.Sum256([]byte(entry.Token))
+		if _, ok := identities[key]; ok {
+			return fmt.Errorf("token of %s in %s is given twice", entry.User, t.path)
+		}
+		identities[key] = entry.Identity
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.identities = identities
+	return nil
+}
+
+// Lookup returns the identity a token was issued to
+func (t *Tokens) Lookup(token string) (Identity, bool) {
+	if token == "" {
+		return Identity{}, false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	identity, ok := t.identities[sha256.Sum256([]byte(token))]
+	return identity, ok
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the identity a request was authorized for
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity set by WithIdentity
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Tokens(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), FileName)
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [
+		{"token": "a", "user": "alice", "role": "admin"},
+		{"token": "e", "user": "eve", "role": "editor", "workspaces": ["shared"]}
+	]}`), 0600))
+
+	tokens, err := Load(path)
+	assert.NoError(err)
+	identity, ok := tokens.Lookup("e")
+	assert.True(ok)
+	assert.Equal(Identity{User: "eve", Role: RoleEditor, Workspaces: []string{"shared"}}, identity)
+	_, ok = tokens.Lookup("x")
+	assert.False(ok)
+	_, ok = tokens.Lookup("")
+	assert.False(ok)
+
+	// invalid files are refused and the tokens loaded before kept
+	for _, content := range []string{
+		`{"tokens": [`,
+		`{"tokens": [{"token": "a", "role": "admin"}]}`,
+		`{"tokens": [{"token": "a", "user": "alice", "role": "root"}]}`,
+		`{"tokens": [{"token": "a", "user": "alice", "role": "admin"}, {"token": "a", "user": "bob", "role": "viewer"}]}`,
+	} {
+		assert.NoError(os.WriteFile(path, []byte(content), 0600))
+		assert.Error(tokens.Reload(), content)
+		_, ok = tokens.Lookup("a")
+		assert.True(ok, content)
+	}
+
+	// a reload drops revoked tokens
+	assert.NoError(os.WriteFile(path, []byte(`{"tokens": [{"token": "v", "user": "victor", "role": "viewer"}]}`), 0600))
+	assert.NoError(tokens.Reload())
+	_, ok = tokens.Lookup("a")
+	assert.False(ok)
+	identity, ok = tokens.Lookup("v")
+	assert.True(ok)
+	assert.Equal(RoleViewer, identity.Role)
+
+	_, err = Load(filepath.Join(t.TempDir(), FileName))
+	assert.True(os.IsNotExist(err))
+}
+
+func Test_IdentityOwns(t *testing.T) {
+	assert := require.New(t)
+	eve := Identity{User: "eve", Role: RoleEditor, Workspaces: []string{"shared"}}
+	assert.True(eve.Owns("mine", "eve"))
+	assert.True(eve.Owns("shared", "alice"))
+	assert.False(eve.Owns("other", "alice"))
+	assert.False(Identity{Role: RoleEditor}.Owns("legacy", ""), "workspaces created without users are owned by nobody")
+
+	_, ok := FromContext(context.Background())
+	assert.False(ok)
+	identity, ok := FromContext(WithIdentity(context.Background(), eve))
+	assert.True(ok)
+	assert.Equal("eve", identity.User)
+}
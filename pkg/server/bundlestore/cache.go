@@ -0,0 +1,194 @@
+package bundlestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache keeps local copies of remote bundles in a directory, evicting the least recently used
+// ones once their total size passes maxBytes. The file a Fetch returned for a key is never
+// removed before the next Fetch of another key, builds open it right away.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	size    int64
+	// downloads serializes fetches of the same key
+	downloads map[string]*sync.Mutex
+}
+
+type cacheEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// NewCache opens the cache in dir, picking up the bundles left by earlier runs
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		entries:   map[string]*cacheEntry{},
+		downloads: map[string]*sync.Mutex{},
+	}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			c.entries[d.Name()] = &cacheEntry{
+				path:     filepath.Join(dir, d.Name(), f.Name()),
+				size:     info.Size(),
+				lastUsed: info.ModTime(),
+			}
+			c.size += info.Size()
+		}
+	}
+	c.evict("")
+	return c, nil
+}
+
+// entryID names the directory of a key, the base name of the key is kept for the file
+func entryID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// Get returns the cached copy of key, calling download to fill it when missing
+func (c *Cache) Get(key string, download func(w io.Writer) error) (string, error) {
+	id := entryID(key)
+
+	c.lock.Lock()
+	mu, ok := c.downloads[id]
+	if !ok {
+		mu = &sync.Mutex{}
+		c.downloads[id] = mu
+	}
+	c.lock.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p, ok := c.touch(id); ok {
+		return p, nil
+	}
+
+	dest := filepath.Join(c.dir, id, path.Base(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := download(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("failed to move downloaded bundle into the cache: %w", err)
+	}
+
+	c.lock.Lock()
+	c.entries[id] = &cacheEntry{path: dest, size: info.Size(), lastUsed: time.Now()}
+	c.size += info.Size()
+	c.lock.Unlock()
+	c.evict(id)
+	return dest, nil
+}
+
+// touch marks the entry as used and returns its path when it's still on disk
+func (c *Cache) touch(id string) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	if _, err := os.Stat(entry.path); err != nil {
+		delete(c.entries, id)
+		c.size -= entry.size
+		return "", false
+	}
+	entry.lastUsed = time.Now()
+	_ = os.Chtimes(entry.path, entry.lastUsed, entry.lastUsed)
+	return entry.path, true
+}
+
+// Remove drops the cached copy of key
+func (c *Cache) Remove(key string) {
+	id := entryID(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.entries[id]; ok {
+		delete(c.entries, id)
+		c.size -= entry.size
+	}
+	_ = os.RemoveAll(filepath.Join(c.dir, id))
+}
+
+// evict removes the least recently used entries until the cache fits, keep is never removed
+func (c *Cache) evict(keep string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.maxBytes <= 0 || c.size <= c.maxBytes {
+		return
+	}
+	ids := make([]string, 0, len(c.entries))
+	for id := range c.entries {
+		if id != keep {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return c.entries[ids[i]].lastUsed.Before(c.entries[ids[j]].lastUsed)
+	})
+	for _, id := range ids {
+		if c.size <= c.maxBytes {
+			return
+		}
+		c.size -= c.entries[id].size
+		delete(c.entries, id)
+		if err := os.RemoveAll(filepath.Join(c.dir, id)); err != nil {
+			fmt.Printf("Failed to evict cached bundle %s: %v\n", id, err)
+		}
+	}
+}
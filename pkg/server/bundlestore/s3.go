@@ -0,0 +1,405 @@
+package bundlestore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPartSize is the size of the parts of multipart uploads, bundles up to it are sent in one PUT
+const defaultPartSize = 64 << 20
+
+// maxCopySize is the largest object S3 copies with a single request
+const maxCopySize = 5 << 30
+
+// S3Options configures an S3 store, any S3-compatible service like MinIO works
+type S3Options struct {
+	Endpoint  string // e.g. https://s3.amazonaws.com or http://minio:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // Prepended to every key
+	CacheDir  string // Where fetched bundles are kept for builds
+	CacheSize int64  // Bytes of bundles kept in CacheDir, 0 means no limit
+}
+
+// S3 keeps bundles in a bucket and downloads them into a local cache when a build needs them.
+// Buckets are addressed path-style, which every S3-compatible service supports.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+	partSize  int64
+	// maxCopySize is the largest object copied with a single request, larger ones are copied in parts
+	maxCopySize int64
+	client      *http.Client
+	cache       *Cache
+}
+
+// NewS3 connects to the bucket, failing when it can't be reached with the given credentials
+func NewS3(ctx context.Context, opts S3Options) (*S3, error) {
+	s, err := newS3(opts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodHead, "", nil, nil, nil, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach bucket %s: %w", opts.Bucket, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to reach bucket %s: %s", opts.Bucket, resp.Status)
+	}
+	return s, nil
+}
+
+func newS3(opts S3Options) (*S3, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint and bucket are required")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	cache, err := NewCache(opts.CacheDir, opts.CacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle cache: %w", err)
+	}
+
+	return &S3{
+		endpoint:    endpoint,
+		bucket:      opts.Bucket,
+		region:      opts.Region,
+		accessKey:   opts.AccessKey,
+		secretKey:   opts.SecretKey,
+		prefix:      opts.Prefix,
+		partSize:    defaultPartSize,
+		maxCopySize: maxCopySize,
+		client:      &http.Client{},
+		cache:       cache,
+	}, nil
+}
+
+// objectURL returns the URL of the object stored under key, or of the bucket when key is empty
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + s.prefix + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	if query != nil {
+		u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	}
+	return &u
+}
+
+// do sends a signed request, size is the length of body or -1 when there's none
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	signV4(req, s.accessKey, s.secretKey, s.region, payloadHash, time.Now())
+	return s.client.Do(req)
+}
+
+// s3Error reads the error of a failed request, S3 answers with an XML document naming the error code
+func s3Error(op string, resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("%s: %s: %s", op, body.Code, body.Message)
+	}
+	return fmt.Errorf("%s: %s", op, resp.Status)
+}
+
+func (s *S3) Put(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() <= s.partSize {
+		err = s.putObject(ctx, key, f, info.Size())
+	} else {
+		err = s.putMultipart(ctx, key, f, info.Size())
+	}
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(path)
+}
+
+func (s *S3) putObject(ctx context.Context, key string, body io.Reader, size int64) error {
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, header, body, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("failed to upload "+key, resp)
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads the file in parts of partSize, so bundles larger than a single PUT allows work
+func (s *S3) putMultipart(ctx context.Context, key string, f *os.File, size int64) error {
+	uploadID, err := s.startMultipart(ctx, key)
+	if err != nil {
+		return err
+	}
+	parts, err := s.uploadParts(ctx, key, uploadID, f, size)
+	if err != nil {
+		s.abortMultipart(key, uploadID)
+		return err
+	}
+	return s.completeMultipart(ctx, key, uploadID, parts)
+}
+
+// startMultipart starts a multipart upload to key and returns its ID
+func (s *S3) startMultipart(ctx context.Context, key string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, -1)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", s3Error("failed to start upload of "+key, resp)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&initiated); err != nil {
+		return "", fmt.Errorf("failed to start upload of %s: %w", key, err)
+	}
+	return initiated.UploadID, nil
+}
+
+// abortMultipart frees the parts already stored, they'd otherwise be billed until a lifecycle rule cleans them
+func (s *S3) abortMultipart(key, uploadID string) {
+	if resp, err := s.do(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, -1); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (s *S3) completeMultipart(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, strings.NewReader(string(body)), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResult("failed to complete upload of "+key, resp)
+}
+
+// checkResult checks the response of requests that can fail after a 200, the error is then in the body
+func checkResult(op string, resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(op, resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("%s: %s: %s", op, result.Code, result.Message)
+	}
+	return nil
+}
+
+func (s *S3) uploadParts(ctx context.Context, key, uploadID string, f *os.File, size int64) ([]completedPart, error) {
+	var parts []completedPart
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+s.partSize, number+1 {
+		length := min(s.partSize, size-offset)
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, nil, io.NewSectionReader(f, offset, length), length)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(fmt.Sprintf("failed to upload part %d of %s", number, key), resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+	return parts, nil
+}
+
+func (s *S3) Fetch(ctx context.Context, key string) (string, error) {
+	return s.cache.Get(key, func(w io.Writer) error {
+		resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil, -1)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			return s3Error("failed to download "+key, resp)
+		}
+		_, err = io.Copy(w, resp.Body)
+		return err
+	})
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil, -1)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check %s: %s", key, resp.Status)
+	}
+}
+
+// Rename copies the object on the server side and deletes the original, S3 has no move
+func (s *S3) Rename(ctx context.Context, from, to string) error {
+	if from == to {
+		return nil
+	}
+	resp, err := s.do(ctx, http.MethodHead, from, nil, nil, nil, -1)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to check %s: %s", from, resp.Status)
+	}
+
+	header := http.Header{"X-Amz-Copy-Source": {uriEncode("/"+s.bucket+"/"+s.prefix+from, false)}}
+	if resp.ContentLength <= s.maxCopySize {
+		resp, err := s.do(ctx, http.MethodPut, to, nil, header, nil, -1)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if err := checkResult(fmt.Sprintf("failed to copy %s to %s", from, to), resp); err != nil {
+			return err
+		}
+	} else if err := s.copyMultipart(ctx, to, header, resp.ContentLength); err != nil {
+		return err
+	}
+	return s.Delete(ctx, from)
+}
+
+// copyMultipart copies an object larger than a single copy allows in parts of maxCopySize
+func (s *S3) copyMultipart(ctx context.Context, key string, source http.Header, size int64) error {
+	uploadID, err := s.startMultipart(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var parts []completedPart
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+s.maxCopySize, number+1 {
+		header := source.Clone()
+		header.Set("X-Amz-Copy-Source-Range", fmt.Sprintf("bytes=%d-%d", offset, min(offset+s.maxCopySize, size)-1))
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, header, nil, -1)
+		if err != nil {
+			s.abortMultipart(key, uploadID)
+			return err
+		}
+		var result struct {
+			ETag string `xml:"ETag"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		} else {
+			err = s3Error(fmt.Sprintf("failed to copy part %d of %s", number, key), resp)
+		}
+		resp.Body.Close()
+		if err != nil {
+			s.abortMultipart(key, uploadID)
+			return err
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: result.ETag})
+	}
+	return s.completeMultipart(ctx, key, uploadID, parts)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	s.cache.Remove(key)
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil, -1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("failed to delete "+key, resp)
+	}
+	return nil
+}
+
+// createBucket creates the bucket, used by tests against a fresh MinIO
+func (s *S3) createBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodPut, "", nil, nil, nil, -1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("failed to create bucket "+s.bucket, resp)
+	}
+	return nil
+}
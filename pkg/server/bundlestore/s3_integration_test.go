@@ -0,0 +1,96 @@
+//go:build integration
+
+package bundlestore
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startMinio runs a MinIO container with docker and returns its endpoint
+func startMinio(t *testing.T) string {
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::9000",
+		"-e", "MINIO_ROOT_USER=minioadmin", "-e", "MINIO_ROOT_PASSWORD=minioadmin",
+		"minio/minio", "server", "/data").Output()
+	require.NoError(t, err, "docker is needed to run the S3 integration tests")
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() { _ = exec.Command("docker", "rm", "-f", id).Run() })
+
+	out, err = exec.Command("docker", "port", id, "9000").Output()
+	require.NoError(t, err)
+	endpoint := "http://" + strings.TrimSpace(strings.Split(string(out), "\n")[0])
+
+	for i := 0; i < 60; i++ {
+		if resp, err := http.Get(endpoint + "/minio/health/ready"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return endpoint
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatal("MinIO didn't become ready")
+	return ""
+}
+
+func Test_S3Minio(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	endpoint := startMinio(t)
+
+	opts := S3Options{
+		Endpoint:  endpoint,
+		Bucket:    "bundles",
+		AccessKey: "minioadmin",
+		SecretKey: "minioadmin",
+		Prefix:    "sim/",
+		CacheDir:  t.TempDir(),
+	}
+	_, err := NewS3(ctx, opts)
+	assert.Error(err, "the bucket doesn't exist yet")
+
+	created, err := newS3(opts)
+	assert.NoError(err)
+	assert.NoError(created.createBucket(ctx))
+	s, err := NewS3(ctx, opts)
+	assert.NoError(err)
+
+	// spans two parts of the smallest size S3 accepts
+	s.partSize = 5 << 20
+	content := bytes.Repeat([]byte("bundle"), 2<<20)
+	key := "workspaces/my ws/v1/bundle.zip"
+	assert.NoError(s.Put(ctx, key, writeBundle(t, content)))
+
+	exists, err := s.Exists(ctx, key)
+	assert.NoError(err)
+	assert.True(exists)
+	fetched, err := s.Fetch(ctx, key)
+	assert.NoError(err)
+	data, err := os.ReadFile(fetched)
+	assert.NoError(err)
+	assert.True(bytes.Equal(content, data))
+
+	small := "workspaces/ws/v2/bundle.zip"
+	assert.NoError(s.Put(ctx, small, writeBundle(t, []byte("zip"))))
+	s.cache.Remove(small)
+	fetched, err = s.Fetch(ctx, small)
+	assert.NoError(err)
+	data, err = os.ReadFile(fetched)
+	assert.NoError(err)
+	assert.Equal("zip", string(data))
+
+	assert.NoError(s.Delete(ctx, key))
+	exists, err = s.Exists(ctx, key)
+	assert.NoError(err)
+	assert.False(exists)
+	_, err = s.Fetch(ctx, key)
+	assert.ErrorIs(err, ErrNotFound)
+}
@@ -0,0 +1,232 @@
+package bundlestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves a single bucket from memory and rejects requests with a wrong signature
+type fakeS3 struct {
+	t       *testing.T
+	bucket  string
+	lock    sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	gets    int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{t: t, bucket: "bundles", objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// Sign a copy the way the client should have
+	signed := r.Clone(context.Background())
+	date, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	signV4(signed, "access", "secret", "us-east-1", r.Header.Get("X-Amz-Content-Sha256"), date)
+	if signed.Header.Get("Authorization") != r.Header.Get("Authorization") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>wrong signature</Message></Error>")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		var number int
+		fmt.Sscan(query.Get("partNumber"), &number)
+		data, _ := io.ReadAll(r.Body)
+		if source, ok := f.copySource(r); ok {
+			var from, to int
+			fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &from, &to)
+			data = f.objects[source][from : to+1]
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"etag-%d"</ETag></CopyPartResult>`, number)
+		} else {
+			w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+		}
+		f.uploads[query.Get("uploadId")][number] = data
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for i, part := range complete.Parts {
+			require.Equal(f.t, i+1, part.PartNumber)
+			require.Equal(f.t, fmt.Sprintf(`"etag-%d"`, part.PartNumber), part.ETag)
+			data = append(data, f.uploads[query.Get("uploadId")][part.PartNumber]...)
+		}
+		delete(f.uploads, query.Get("uploadId"))
+		f.objects[key] = data
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if source, ok := f.copySource(r); ok {
+			data, ok = f.objects[source]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+		}
+		f.objects[key] = data
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == http.MethodGet {
+			f.gets++
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// copySource returns the key a copy request copies from
+func (f *fakeS3) copySource(r *http.Request) (string, bool) {
+	source := r.Header.Get("X-Amz-Copy-Source")
+	if source == "" {
+		return "", false
+	}
+	source, err := url.PathUnescape(source)
+	require.NoError(f.t, err)
+	return strings.TrimPrefix(source, "/"+f.bucket+"/"), true
+}
+
+func newTestS3(t *testing.T, endpoint, secret string) (*S3, error) {
+	return NewS3(context.Background(), S3Options{
+		Endpoint:  endpoint,
+		Bucket:    "bundles",
+		AccessKey: "access",
+		SecretKey: secret,
+		Prefix:    "sim/",
+		CacheDir:  t.TempDir(),
+	})
+}
+
+func writeBundle(t *testing.T, content []byte) string {
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	return path
+}
+
+func Test_S3(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	fake, srv := newFakeS3(t)
+
+	_, err := newTestS3(t, srv.URL, "wrong")
+	assert.ErrorContains(err, "failed to reach bucket")
+
+	s, err := newTestS3(t, srv.URL, "secret")
+	assert.NoError(err)
+
+	key := "workspaces/my ws/v1/bundle.zip"
+	path := writeBundle(t, []byte("zip"))
+	assert.NoError(s.Put(ctx, key, path))
+	assert.Equal([]byte("zip"), fake.objects["sim/"+key])
+	assert.NoFileExists(path, "uploaded bundles are removed from the data directory")
+
+	exists, err := s.Exists(ctx, key)
+	assert.NoError(err)
+	assert.True(exists)
+
+	fetched, err := s.Fetch(ctx, key)
+	assert.NoError(err)
+	content, err := os.ReadFile(fetched)
+	assert.NoError(err)
+	assert.Equal("zip", string(content))
+	_, err = s.Fetch(ctx, key)
+	assert.NoError(err)
+	assert.Equal(1, fake.gets, "the second fetch is served from the cache")
+
+	assert.NoError(s.Delete(ctx, key))
+	assert.NoError(s.Delete(ctx, key))
+	assert.NoFileExists(fetched)
+	exists, err = s.Exists(ctx, key)
+	assert.NoError(err)
+	assert.False(exists)
+	_, err = s.Fetch(ctx, key)
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func Test_S3MultipartUpload(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	fake, srv := newFakeS3(t)
+	s, err := newTestS3(t, srv.URL, "secret")
+	assert.NoError(err)
+	s.partSize = 4
+
+	content := []byte("0123456789")
+	assert.NoError(s.Put(ctx, "workspaces/ws/v1/bundle.zip", writeBundle(t, content)))
+	assert.True(bytes.Equal(content, fake.objects["sim/workspaces/ws/v1/bundle.zip"]))
+	assert.Empty(fake.uploads)
+}
+
+func Test_S3Rename(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	fake, srv := newFakeS3(t)
+	s, err := newTestS3(t, srv.URL, "secret")
+	assert.NoError(err)
+
+	assert.NoError(s.Put(ctx, "workspaces/ws/v1/bundle.zip", writeBundle(t, []byte("zip"))))
+	assert.NoError(s.Rename(ctx, "workspaces/ws/v1/bundle.zip", ".trash/t1/bundles/v1/bundle.zip"))
+	assert.Equal(map[string][]byte{"sim/.trash/t1/bundles/v1/bundle.zip": []byte("zip")}, fake.objects)
+
+	// objects larger than a single copy allows are copied in parts
+	s.maxCopySize = 4
+	content := []byte("0123456789")
+	assert.NoError(s.Put(ctx, "workspaces/ws/v2/bundle.zip", writeBundle(t, content)))
+	assert.NoError(s.Rename(ctx, "workspaces/ws/v2/bundle.zip", "workspaces/ws/v3/bundle.zip"))
+	assert.Equal(content, fake.objects["sim/workspaces/ws/v3/bundle.zip"])
+	assert.NotContains(fake.objects, "sim/workspaces/ws/v2/bundle.zip")
+	assert.Empty(fake.uploads)
+
+	assert.ErrorIs(s.Rename(ctx, "workspaces/ws/v9/bundle.zip", "workspaces/ws/v10/bundle.zip"), ErrNotFound)
+}
@@ -0,0 +1,99 @@
+package bundlestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Fetch when no object is stored under a key
+var ErrNotFound = errors.New("bundle not found")
+
+// Store keeps the original bundle archives of versions. Keys are the paths the archives had
+// relative to the data directory, e.g. workspaces/demo/v1/bundle.zip, so the keys of existing
+// installs keep working with the local store.
+type Store interface {
+	// Put stores the file at path under key. Remote stores upload it and remove the local file.
+	Put(ctx context.Context, key, path string) error
+	// Fetch returns the path of a local copy of the object stored under key
+	Fetch(ctx context.Context, key string) (string, error)
+	// Exists reports whether an object is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the object stored under key, a missing object isn't an error
+	Delete(ctx context.Context, key string) error
+	// Rename moves the object stored under from to the key to, ErrNotFound is returned when there's none
+	Rename(ctx context.Context, from, to string) error
+}
+
+// Local keeps bundles where they were uploaded to, in the data directory
+type Local struct {
+	dataDir string
+}
+
+func NewLocal(dataDir string) *Local {
+	return &Local{dataDir: dataDir}
+}
+
+// path resolves a key, keys written before paths were stored relative are absolute paths
+func (l *Local) path(key string) string {
+	if filepath.IsAbs(key) {
+		return key
+	}
+	return filepath.Join(l.dataDir, filepath.FromSlash(key))
+}
+
+func (l *Local) Put(ctx context.Context, key, path string) error {
+	dest := l.path(key)
+	if dest == path {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, dest)
+}
+
+func (l *Local) Fetch(ctx context.Context, key string) (string, error) {
+	path := l.path(key)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(l.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	// Bundles outside the data directory weren't stored by the server and are left alone
+	if filepath.IsAbs(key) {
+		return nil
+	}
+	if err := os.Remove(l.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Rename(ctx context.Context, from, to string) error {
+	src, dest := l.path(from), l.path(to)
+	if src == dest {
+		return nil
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dest)
+}
@@ -0,0 +1,142 @@
+package bundlestore
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Local(t *testing.T) {
+	assert := require.New(t)
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	l := NewLocal(dataDir)
+
+	key := "workspaces/ws/v1/bundle.zip"
+	path := filepath.Join(dataDir, filepath.FromSlash(key))
+	assert.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(os.WriteFile(path, []byte("zip"), 0644))
+
+	// the bundle is already where the key points to, it stays there
+	assert.NoError(l.Put(ctx, key, path))
+	fetched, err := l.Fetch(ctx, key)
+	assert.NoError(err)
+	assert.Equal(path, fetched)
+	exists, err := l.Exists(ctx, key)
+	assert.NoError(err)
+	assert.True(exists)
+
+	assert.NoError(l.Delete(ctx, key))
+	assert.NoError(l.Delete(ctx, key))
+	_, err = l.Fetch(ctx, key)
+	assert.ErrorIs(err, ErrNotFound)
+	exists, err = l.Exists(ctx, key)
+	assert.NoError(err)
+	assert.False(exists)
+
+	assert.NoError(os.MkdirAll(filepath.Join(dataDir, "workspaces", "ws", "v2"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(dataDir, "workspaces", "ws", "v2", "bundle.zip"), []byte("zip"), 0644))
+	assert.NoError(l.Rename(ctx, "workspaces/ws/v2/bundle.zip", ".trash/t1/bundles/v2/bundle.zip"))
+	assert.FileExists(filepath.Join(dataDir, ".trash", "t1", "bundles", "v2", "bundle.zip"))
+	assert.ErrorIs(l.Rename(ctx, "workspaces/ws/v2/bundle.zip", "workspaces/ws/v3/bundle.zip"), ErrNotFound)
+
+	// bundles of old releases were stored with absolute paths, they are read but never deleted
+	legacy := filepath.Join(t.TempDir(), "bundle.zip")
+	assert.NoError(os.WriteFile(legacy, []byte("zip"), 0644))
+	fetched, err = l.Fetch(ctx, legacy)
+	assert.NoError(err)
+	assert.Equal(legacy, fetched)
+	assert.NoError(l.Delete(ctx, legacy))
+	assert.FileExists(legacy)
+}
+
+func download(content string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	}
+}
+
+func Test_CacheEvictsLeastRecentlyUsed(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	c, err := NewCache(dir, 10)
+	assert.NoError(err)
+
+	a, err := c.Get("ws/v1/bundle.zip", download("aaaa"))
+	assert.NoError(err)
+	assert.Equal("bundle.zip", filepath.Base(a))
+	b, err := c.Get("ws/v2/bundle.zip", download("bbbb"))
+	assert.NoError(err)
+
+	// a cached key is not downloaded again
+	again, err := c.Get("ws/v1/bundle.zip", func(w io.Writer) error {
+		t.Fatal("downloaded a cached bundle")
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(a, again)
+
+	// v2 was used last before v3 pushed the cache over its size
+	_, err = c.Get("ws/v3/bundle.zip", download("cccc"))
+	assert.NoError(err)
+	assert.FileExists(a)
+	assert.NoFileExists(b)
+
+	// a download larger than the cache is kept until the next one
+	big, err := c.Get("ws/v4/bundle.zip", download(strings.Repeat("d", 20)))
+	assert.NoError(err)
+	content, err := os.ReadFile(big)
+	assert.NoError(err)
+	assert.Len(content, 20)
+	assert.NoFileExists(a)
+
+	c.Remove("ws/v4/bundle.zip")
+	assert.NoFileExists(big)
+}
+
+func Test_CacheKeepsEntriesAcrossRestarts(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	c, err := NewCache(dir, 0)
+	assert.NoError(err)
+	old, err := c.Get("ws/v1/bundle.zip", download("aaaa"))
+	assert.NoError(err)
+	assert.NoError(os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+	recent, err := c.Get("ws/v2/bundle.zip", download("bbbb"))
+	assert.NoError(err)
+
+	// reopened with a smaller size, the older bundle goes
+	c, err = NewCache(dir, 6)
+	assert.NoError(err)
+	assert.NoFileExists(old)
+	path, err := c.Get("ws/v2/bundle.zip", download("changed"))
+	assert.NoError(err)
+	assert.Equal(recent, path)
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("bbbb", string(content))
+}
+
+func Test_CacheFailedDownloadLeavesNothing(t *testing.T) {
+	assert := require.New(t)
+	c, err := NewCache(t.TempDir(), 0)
+	assert.NoError(err)
+
+	_, err = c.Get("ws/v1/bundle.zip", func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return ErrNotFound
+	})
+	assert.ErrorIs(err, ErrNotFound)
+	path, err := c.Get("ws/v1/bundle.zip", download("full"))
+	assert.NoError(err)
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("full", string(content))
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/api"
+)
+
+// corsMethods are the methods probed against the mux to find what a route accepts
+var corsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// enableCors adds CORS headers to API responses and answers their preflights. The methods a route
+// accepts are read from the method patterns registered on mux, so preflights and requests for unknown
+// routes get a 404 and unsupported methods a 405 instead of a blanket 200. Requests outside /api
+// under basePath, or under that of a profile, are passed to next untouched.
+func enableCors(mux *http.ServeMux, basePath string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(trimProfilePath(r.URL.Path, basePath), basePath+"/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := allowedMethods(mux, r)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+
+		if len(allowed) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions {
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if requested != "" && !containsMethod(allowed, requested) {
+				w.Header().Set("Allow", strings.Join(allowed, ", "))
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowed, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+api.ProfileHeader)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !containsMethod(allowed, r.Method) {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trimProfilePath removes the path prefix of a profile from a path under basePath, see api.ProfilesPath
+func trimProfilePath(path, basePath string) string {
+	rest, ok := strings.CutPrefix(path, basePath+api.ProfilesPath)
+	if !ok {
+		return path
+	}
+	if _, after, found := strings.Cut(rest, "/"); found {
+		return basePath + "/" + after
+	}
+	return path
+}
+
+// allowedMethods returns the methods registered on mux for the path of r. Only patterns with a method
+// count, so catch-all handlers like the UI one don't make every API path look valid.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range corsMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); strings.HasPrefix(pattern, method+" ") {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// containsMethod reports whether method is in allowed, HEAD is accepted wherever GET is
+func containsMethod(allowed []string, method string) bool {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	for _, m := range allowed {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func corsMux(t *testing.T) (*http.ServeMux, http.Handler) {
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	mux.HandleFunc("GET /api/workspaces", ok)
+	mux.HandleFunc("POST /api/workspaces", ok)
+	mux.HandleFunc("GET /api/workspaces/{name}/versions/{versionID}/kubeconfig", ok)
+	require.NoError(t, registerUIHandler(mux, fstest.MapFS{"index.html": {Data: []byte("<html>sim-gui</html>")}}, ""))
+	return mux, enableCors(mux, "", mux)
+}
+
+func preflight(handler http.Handler, path, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", method)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_CorsPreflightKnownRoute(t *testing.T) {
+	assert := require.New(t)
+	_, handler := corsMux(t)
+
+	rec := preflight(handler, "/api/workspaces", "POST")
+	assert.Equal(http.StatusNoContent, rec.Code)
+	assert.Equal("GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal("*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(rec.Header().Values("Vary"), "Origin")
+
+	rec = preflight(handler, "/api/workspaces", "DELETE")
+	assert.Equal(http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal("GET, POST", rec.Header().Get("Allow"))
+}
+
+func Test_CorsUnknownAPIRoute(t *testing.T) {
+	assert := require.New(t)
+	_, handler := corsMux(t)
+
+	rec := preflight(handler, "/api/unknown", "GET")
+	assert.Equal(http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/unknown", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
+
+func Test_CorsUnsupportedMethod(t *testing.T) {
+	assert := require.New(t)
+	_, handler := corsMux(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/workspaces", nil))
+	assert.Equal(http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal("GET, POST", rec.Header().Get("Allow"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("HEAD", "/api/workspaces", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+}
+
+func Test_CorsDownload(t *testing.T) {
+	assert := require.New(t)
+	_, handler := corsMux(t)
+
+	req := httptest.NewRequest("GET", "/api/workspaces/demo/versions/v1/kubeconfig", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("ok", rec.Body.String())
+	assert.Contains(rec.Header().Values("Vary"), "Origin")
+	assert.Empty(rec.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func Test_CorsSkipsUI(t *testing.T) {
+	assert := require.New(t)
+	_, handler := corsMux(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/workspaces/demo", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Empty(rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(rec.Header().Get("Vary"))
+}
+
+func Test_CorsProfileRoute(t *testing.T) {
+	assert := require.New(t)
+	mux, handler := corsMux(t)
+	mux.HandleFunc("GET /profiles/lab/api/workspaces", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+
+	rec := preflight(handler, "/profiles/lab/api/workspaces", "GET")
+	assert.Equal(http.StatusNoContent, rec.Code)
+	assert.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "X-Profile")
+
+	// unknown API paths of a profile aren't answered by the UI
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/profiles/lab/api/unknown", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Type identifies what changed, the payload of each type is documented next to it
+type Type string
+
+const (
+	// WorkspaceCreated carries the created model.Workspace
+	WorkspaceCreated Type = "workspace.created"
+	// WorkspaceUpdated carries the renamed model.Workspace
+	WorkspaceUpdated Type = "workspace.updated"
+	// WorkspaceDeleted has no payload
+	WorkspaceDeleted Type = "workspace.deleted"
+	// VersionUploaded carries the uploaded model.Version
+	VersionUploaded Type = "version.uploaded"
+	// VersionDeleted has no payload
+	VersionDeleted Type = "version.deleted"
+	// VersionReplaced carries the model.Version whose bundle was replaced
+	VersionReplaced Type = "version.replaced"
+	// VersionRestored carries the model.Version put back from the trash, its ID can differ from the deleted one
+	VersionRestored Type = "version.restored"
+	// VersionExpiring carries the time.Time the version will be deleted at by the retention policy
+	VersionExpiring Type = "version.expiring"
+	// VersionReady has no payload, it is sent when a simulator finished loading the bundle
+	VersionReady Type = "version.ready"
+	// SimulatorStarting has no payload, it is sent before the image of a simulator is built
+	SimulatorStarting Type = "simulator.starting"
+	// SimulatorImageBuilt has no payload, it is sent once the image of a simulator is built
+	SimulatorImageBuilt Type = "simulator.image-built"
+	// SimulatorProgress carries the simulator.Progress of a loading simulator, at most once per second
+	SimulatorProgress Type = "simulator.progress"
+	// SimulatorStarted has no payload
+	SimulatorStarted Type = "simulator.started"
+	// SimulatorKubeconfigChanged carries the new host:port of the apiserver of a restarted simulator,
+	// kubeconfigs downloaded before point at the old one
+	SimulatorKubeconfigChanged Type = "simulator.kubeconfig-changed"
+	// SimulatorReset has no payload, it is sent once a simulator restarted to load its bundle again
+	SimulatorReset Type = "simulator.reset"
+	// SimulatorStopped has no payload
+	SimulatorStopped Type = "simulator.stopped"
+	// JobUpdated carries the jobs.Job of a background job when it starts, changes and finishes
+	JobUpdated Type = "job.updated"
+	// EventsDropped has no payload, it tells a subscriber that it fell behind and missed events,
+	// so it should refetch the state it cares about
+	EventsDropped Type = "events.dropped"
+)
+
+// subscriberBuffer bounds the events queued for a single subscriber
+const subscriberBuffer = 64
+
+// Event is a state change published on the Bus
+type Event struct {
+	Type      Type        `json:"type"`
+	Workspace string      `json:"workspace,omitempty"`
+	VersionID string      `json:"versionID,omitempty"`
+	Actor     string      `json:"actor,omitempty"` // Who made the change, empty for changes made by the server
+	Time      time.Time   `json:"time"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+// Bus fans out events to every subscriber. Publishing never blocks, events for a subscriber
+// whose buffer is full are dropped and the subscriber is flagged instead.
+type Bus struct {
+	lock        sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events from a Bus until it is closed
+type Subscription struct {
+	bus     *Bus
+	events  chan Event
+	dropped atomic.Bool
+	once    sync.Once
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to all subscribers
+func (b *Bus) Publish(eventType Type, workspace, versionID string, payload interface{}) {
+	b.PublishBy("", eventType, workspace, versionID, payload)
+}
+
+// PublishBy sends an event made by actor to all subscribers
+func (b *Bus) PublishBy(actor string, eventType Type, workspace, versionID string, payload interface{}) {
+	event := Event{
+		Type:      eventType,
+		Workspace: workspace,
+		VersionID: versionID,
+		Actor:     actor,
+		Time:      time.Now(),
+		Payload:   payload,
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped.Store(true)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber, it must be closed once the caller stops reading
+func (b *Bus) Subscribe() *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		events: make(chan Event, subscriberBuffer),
+	}
+	b.lock.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.lock.Unlock()
+	return sub
+}
+
+// Events returns the channel events are delivered on, it is closed when the subscription is closed
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// TakeDropped reports whether events were dropped since the last call
+func (s *Subscription) TakeDropped() bool {
+	return s.dropped.Swap(false)
+}
+
+// Close unregisters the subscription, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.lock.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.lock.Unlock()
+		close(s.events)
+	})
+}
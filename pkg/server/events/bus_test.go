@@ -0,0 +1,93 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BusDropsWhenSubscriberIsFull(t *testing.T) {
+	assert := require.New(t)
+	bus := NewBus()
+	sub := bus.Subscribe()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		bus.Publish(VersionUploaded, "ws", "v1", nil)
+	}
+	assert.Len(sub.Events(), subscriberBuffer)
+	assert.True(sub.TakeDropped())
+	assert.False(sub.TakeDropped())
+
+	sub.Close()
+	sub.Close()
+	assert.Empty(bus.subscribers)
+
+	// publishing without subscribers doesn't block
+	bus.Publish(WorkspaceDeleted, "ws", "", nil)
+}
+
+func Test_ServeHTTPStreamsEvents(t *testing.T) {
+	assert := require.New(t)
+	bus := NewBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/events", nil).WithContext(ctx)
+	reader, pw := io.Pipe()
+	writer := &pipeRecorder{ResponseRecorder: httptest.NewRecorder(), pw: pw}
+	done := make(chan struct{})
+	go func() {
+		bus.ServeHTTP(writer, req)
+		close(done)
+	}()
+
+	// wait for the subscription before publishing
+	assert.Eventually(func() bool {
+		bus.lock.RLock()
+		defer bus.lock.RUnlock()
+		return len(bus.subscribers) == 1
+	}, time.Second, 10*time.Millisecond)
+	bus.Publish(SimulatorStarted, "ws", "v1", nil)
+
+	scanner := bufio.NewScanner(reader)
+	assert.True(scanner.Scan())
+	assert.Equal("event: simulator.started", scanner.Text())
+	assert.True(scanner.Scan())
+	assert.True(strings.HasPrefix(scanner.Text(), "data: "))
+	assert.Contains(scanner.Text(), `"workspace":"ws","versionID":"v1"`)
+
+	cancel()
+	go io.Copy(io.Discard, reader)
+	<-done
+	assert.Equal("text/event-stream", writer.Header().Get("Content-Type"))
+	assert.Empty(bus.subscribers)
+}
+
+// pipeRecorder passes the streamed body through a pipe so the test can read it while it is written
+type pipeRecorder struct {
+	*httptest.ResponseRecorder
+	pw *io.PipeWriter
+}
+
+func (p *pipeRecorder) Write(b []byte) (int, error) {
+	return p.pw.Write(b)
+}
+
+func (p *pipeRecorder) Flush() {}
+
+func Test_PublishBy(t *testing.T) {
+	assert := require.New(t)
+	bus := NewBus()
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	bus.PublishBy("alice", VersionUploaded, "ws", "v1", nil)
+	bus.Publish(SimulatorStarted, "ws", "v1", nil)
+	assert.Equal("alice", (<-sub.Events()).Actor)
+	assert.Empty((<-sub.Events()).Actor)
+}
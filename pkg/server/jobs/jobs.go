@@ -0,0 +1,324 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long finished jobs are listed unless configured otherwise
+const DefaultRetention = time.Hour
+
+// Status is the state of a Job
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	// StatusCancelled jobs stopped once Cancel was called
+	StatusCancelled Status = "cancelled"
+	// StatusInterrupted jobs were running when the server stopped
+	StatusInterrupted Status = "interrupted"
+)
+
+var (
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when a job that already finished is cancelled
+	ErrFinished = errors.New("the job already finished")
+)
+
+// Progress counts the items of a job done so far
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Job is work run in the background by the server
+type Job struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"` // e.g. workspace-delete
+	Workspace string `json:"workspace,omitempty"`
+	VersionID string `json:"versionID,omitempty"`
+	Status    Status `json:"status"`
+	// Phase is what the job is doing, set by the job itself
+	Phase    string    `json:"phase,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+	// Result is what the job reports besides its status, its type depends on the kind
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Filter selects the jobs of List, empty fields match every job
+type Filter struct {
+	Workspace string
+	Kind      string
+}
+
+// Handle is given to the function of a job to report on it
+type Handle struct {
+	ctx     context.Context
+	manager *Manager
+	job     *job
+}
+
+// Context is cancelled when the job is cancelled
+func (h *Handle) Context() context.Context {
+	return h.ctx
+}
+
+// Update changes the phase, progress or result of the job through fn
+func (h *Handle) Update(fn func(job *Job)) {
+	h.manager.update(h.job, func(j *Job) {
+		fn(j)
+		// Set by the manager only
+		j.Status, j.FinishedAt = StatusRunning, nil
+	})
+}
+
+// SetPhase sets what the job is doing
+func (h *Handle) SetPhase(phase string) {
+	h.Update(func(job *Job) { job.Phase = phase })
+}
+
+// SetProgress sets how many of the items of the job are done
+func (h *Handle) SetProgress(done, total int) {
+	h.Update(func(job *Job) { job.Progress = &Progress{Done: done, Total: total} })
+}
+
+// job is a Job with the cancel of its context, guarded by the lock of the Manager
+type job struct {
+	Job
+	cancel context.CancelFunc
+}
+
+// snapshot copies the job for a caller, the lock of the Manager must be held. Result isn't copied,
+// jobs replace it rather than change it.
+func (j *job) snapshot() Job {
+	snapshot := j.Job
+	if j.Progress != nil {
+		progress := *j.Progress
+		snapshot.Progress = &progress
+	}
+	return snapshot
+}
+
+// Manager runs jobs and keeps them until retention passed after they finished. The jobs are written to
+// a JSON file on every start and finish, so the jobs a server stop cut short are reported as
+// interrupted by the next run. Progress isn't written, it is only kept in memory.
+type Manager struct {
+	path      string
+	retention time.Duration
+	// now is replaced by tests
+	now func() time.Time
+
+	lock sync.Mutex
+	jobs map[string]*job
+	// onChange is called with a copy of a job whenever it changed, see OnChange
+	onChange func(Job)
+}
+
+// NewManager returns a manager keeping its jobs in the file at path, empty keeps them in memory only.
+// Jobs the file lists as running are marked interrupted. retention of 0 keeps DefaultRetention.
+func NewManager(path string, retention time.Duration) (*Manager, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	m := &Manager{path: path, retention: retention, now: time.Now, jobs: make(map[string]*job)}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved []Job
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	now := m.now().UTC()
+	for _, j := range saved {
+		if j.Status == StatusRunning {
+			j.Status = StatusInterrupted
+			j.Error = "the server stopped before the job finished"
+			j.FinishedAt = &now
+		}
+		m.jobs[j.ID] = &job{Job: j}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.prune()
+	return m, m.save()
+}
+
+// OnChange sets the function called with a copy of a job whenever it changed, e.g. to announce it. It
+// is called with the lock held so the changes arrive in order, it must not block or use the manager.
+func (m *Manager) OnChange(fn func(Job)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.onChange = fn
+}
+
+// Submit runs fn in the background as a job of kind and returns it. The job fails with the error fn
+// returns, or is cancelled when fn returns after Cancel was called.
+func (m *Manager) Submit(kind, workspace, versionID string, fn func(h *Handle) error) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job: Job{
+			ID:        newID(),
+			Kind:      kind,
+			Workspace: workspace,
+			VersionID: versionID,
+			Status:    StatusRunning,
+			StartedAt: m.now().UTC(),
+		},
+		cancel: cancel,
+	}
+
+	m.lock.Lock()
+	m.prune()
+	m.jobs[j.ID] = j
+	m.saveOrLog()
+	started := j.snapshot()
+	m.changed(started)
+	m.lock.Unlock()
+
+	go func() {
+		defer cancel()
+		err := fn(&Handle{ctx: ctx, manager: m, job: j})
+		m.update(j, func(job *Job) {
+			finished := m.now().UTC()
+			job.FinishedAt = &finished
+			switch {
+			case err == nil:
+				job.Status = StatusSucceeded
+			case ctx.Err() != nil && errors.Is(err, context.Canceled):
+				job.Status = StatusCancelled
+			default:
+				job.Status = StatusFailed
+				job.Error = err.Error()
+			}
+		})
+	}()
+	return started
+}
+
+// Get returns a copy of a job
+func (m *Manager) Get(id string) (Job, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot(), true
+}
+
+// List returns the jobs matching filter, newest first
+func (m *Manager) List(filter Filter) []Job {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.prune()
+	list := []Job{}
+	for _, j := range m.jobs {
+		if (filter.Workspace == "" || j.Workspace == filter.Workspace) && (filter.Kind == "" || j.Kind == filter.Kind) {
+			list = append(list, j.snapshot())
+		}
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].StartedAt.After(list[k].StartedAt) })
+	return list
+}
+
+// Cancel cancels the context of a running job, it finishes once its function returns
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if j.Status != StatusRunning {
+		return j.snapshot(), ErrFinished
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// update changes a job, saves it when it finished and calls onChange
+func (m *Manager) update(j *job, fn func(job *Job)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fn(&j.Job)
+	if j.Status != StatusRunning {
+		m.saveOrLog()
+	}
+	m.changed(j.snapshot())
+}
+
+// changed calls onChange, the lock must be held
+func (m *Manager) changed(job Job) {
+	if m.onChange != nil {
+		m.onChange(job)
+	}
+}
+
+// prune forgets the jobs that finished more than retention ago, the lock must be held
+func (m *Manager) prune() {
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && m.now().Sub(*j.FinishedAt) > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// saveOrLog saves the jobs, a failure only loses the interrupted state of the jobs on the next run
+func (m *Manager) saveOrLog() {
+	if err := m.save(); err != nil {
+		// The jobs in memory are still right
+		log.Printf("Failed to save the jobs to %s: %v", m.path, err)
+	}
+}
+
+// save writes the jobs to the file, the lock must be held
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j.Job)
+	}
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// newID returns a random job ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
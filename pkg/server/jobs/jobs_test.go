@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// wait waits for a job to finish and returns it
+func wait(t *testing.T, m *Manager, id string) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		job, _ = m.Get(id)
+		return job.Status != StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func Test_JobStatuses(t *testing.T) {
+	assert := require.New(t)
+	m, err := NewManager("", 0)
+	assert.NoError(err)
+	var changes []Job
+	m.OnChange(func(job Job) { changes = append(changes, job) })
+
+	release := make(chan struct{})
+	job := m.Submit("build", "ws", "v1", func(h *Handle) error {
+		h.SetPhase("building")
+		h.SetProgress(1, 2)
+		<-release
+		return nil
+	})
+	assert.Equal(StatusRunning, job.Status)
+	assert.Equal("v1", job.VersionID)
+	close(release)
+	done := wait(t, m, job.ID)
+	assert.Equal(StatusSucceeded, done.Status)
+	assert.Equal("building", done.Phase)
+	assert.Equal(&Progress{Done: 1, Total: 2}, done.Progress)
+	assert.NotNil(done.FinishedAt)
+	assert.Len(changes, 4)
+	assert.Equal(done, changes[3])
+
+	failed := wait(t, m, m.Submit("build", "ws", "v2", func(h *Handle) error {
+		return errors.New("no space left on device")
+	}).ID)
+	assert.Equal(StatusFailed, failed.Status)
+	assert.Equal("no space left on device", failed.Error)
+
+	cancelled := m.Submit("delete", "ws", "", func(h *Handle) error {
+		<-h.Context().Done()
+		return h.Context().Err()
+	})
+	_, err = m.Cancel(cancelled.ID)
+	assert.NoError(err)
+	assert.Equal(StatusCancelled, wait(t, m, cancelled.ID).Status)
+	_, err = m.Cancel(cancelled.ID)
+	assert.ErrorIs(err, ErrFinished)
+	_, err = m.Cancel("unknown")
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func Test_ListJobs(t *testing.T) {
+	assert := require.New(t)
+	m, err := NewManager("", time.Hour)
+	assert.NoError(err)
+	now := time.Date(2024, 11, 18, 4, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	first := wait(t, m, m.Submit("build", "ws", "v1", func(h *Handle) error { return nil }).ID)
+	now = now.Add(time.Minute)
+	second := wait(t, m, m.Submit("delete", "ws", "", func(h *Handle) error { return nil }).ID)
+	now = now.Add(time.Minute)
+	other := wait(t, m, m.Submit("build", "other", "v1", func(h *Handle) error { return nil }).ID)
+
+	assert.Equal([]Job{other, second, first}, m.List(Filter{}))
+	assert.Equal([]Job{second, first}, m.List(Filter{Workspace: "ws"}))
+	assert.Equal([]Job{other, first}, m.List(Filter{Kind: "build"}))
+	assert.Empty(m.List(Filter{Workspace: "ws", Kind: "reset"}))
+
+	// Finished jobs are kept for the retention only
+	now = first.FinishedAt.Add(time.Hour + time.Second)
+	assert.Equal([]Job{other, second}, m.List(Filter{}))
+	_, ok := m.Get(first.ID)
+	assert.False(ok)
+}
+
+func Test_RunningJobsInterruptedByRestart(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	m, err := NewManager(path, 0)
+	assert.NoError(err)
+
+	done := wait(t, m, m.Submit("build", "ws", "v1", func(h *Handle) error { return nil }).ID)
+	// Finishes after the manager is replaced
+	stop := make(chan struct{})
+	running := m.Submit("delete", "ws", "", func(h *Handle) error {
+		<-stop
+		return nil
+	})
+	// Finished before the temporary directory is removed
+	t.Cleanup(func() {
+		close(stop)
+		wait(t, m, running.ID)
+	})
+
+	restarted, err := NewManager(path, 0)
+	assert.NoError(err)
+	kept, ok := restarted.Get(done.ID)
+	assert.True(ok)
+	assert.Equal(StatusSucceeded, kept.Status)
+	interrupted, ok := restarted.Get(running.ID)
+	assert.True(ok)
+	assert.Equal(StatusInterrupted, interrupted.Status)
+	assert.NotEmpty(interrupted.Error)
+	assert.NotNil(interrupted.FinishedAt)
+	_, err = restarted.Cancel(running.ID)
+	assert.ErrorIs(err, ErrFinished)
+}
@@ -1,99 +1,503 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"embed"
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Yu-Jack/sim-gui/pkg/server/api"
+	"github.com/Yu-Jack/sim-gui/pkg/server/auth"
+	"github.com/Yu-Jack/sim-gui/pkg/server/bundlestore"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
 	jsonstore "github.com/Yu-Jack/sim-gui/pkg/server/store/json"
+	memstore "github.com/Yu-Jack/sim-gui/pkg/server/store/memory"
+	"github.com/Yu-Jack/sim-gui/pkg/server/utils"
 	"github.com/Yu-Jack/sim-gui/pkg/updater"
 )
 
 //go:embed all:static
 var content embed.FS
 
-func Run(addr string, dataDir string, dev bool) error {
-	store, err := jsonstore.NewJSONStore(dataDir + "/data.json")
+// Options holds the configuration of the diagnostic UI server
+type Options struct {
+	Addr    string
+	DataDir string
+	// Profiles serves a data directory per profile instead of DataDir, the first one is the default
+	Profiles []Profile
+	Dev      bool
+	// DevServerURL is the frontend dev server that non-API requests are proxied to in dev mode
+	DevServerURL string
+	// BasePath is the path prefix the UI and API are served under when a reverse proxy mounts the server
+	// there, e.g. /tools/sim-gui, empty serves them at the root
+	BasePath string
+	// ExternalURL is the URL clients reach the server at, absolute links are built from it instead of
+	// the requests when set
+	ExternalURL string
+	// TrustForwardedHeaders builds absolute links from the X-Forwarded-Host and X-Forwarded-Proto
+	// headers of requests and limits clients by X-Forwarded-For, only set it behind a reverse proxy
+	TrustForwardedHeaders bool
 
+	// UpdateCheckInterval is the interval between update checks, 0 only checks once at startup
+	UpdateCheckInterval time.Duration
+	// DisableUpdateCheck skips creating the update checker entirely
+	DisableUpdateCheck bool
+	// GitHubToken is sent with update check requests to raise the GitHub API rate limit
+	GitHubToken string
+	// KubectlPath is the kubectl binary used for runtime versions, empty looks it up in PATH
+	KubectlPath string
+	// MaxUploadSize is the largest version upload request accepted in bytes, 0 disables the limit
+	MaxUploadSize int64
+	// UploadBufferSize is the memory in bytes used per upload to stream files to disk
+	UploadBufferSize int
+	// MaxExtractSize, MaxExtractFiles and MaxExtractRatio bound the bytes, the files and the compression
+	// ratio of the files extracted from a bundle, 0 disables the limit
+	MaxExtractSize  int64
+	MaxExtractFiles int
+	MaxExtractRatio int64
+	// ActivityMaxEntries is the number of activity feed entries kept per workspace
+	ActivityMaxEntries int
+	// RetentionDays is how long versions are kept in workspaces without their own retention, 0 keeps them forever
+	RetentionDays int
+	// TrashDays is how long deleted workspaces and versions are kept in the trash, 0 keeps them until restored
+	TrashDays int
+	// MaxConcurrentStarts is how many simulators start at once, 0 doesn't limit them
+	MaxConcurrentStarts int
+	// RateLimit is how many requests per second a client may make to the expensive endpoints, with bursts
+	// of up to RateLimitBurst, 0 doesn't limit them
+	RateLimit      float64
+	RateLimitBurst int
+	// UserHeader is the request header a trusted proxy sets to the authenticated user, empty records no users
+	UserHeader string
+	// ImportRoot confines the server paths versions can be imported from, empty refuses imports from a path
+	ImportRoot string
+	// Store is where workspaces are kept, "json" keeps them in data.json of the data directory and "memory"
+	// until the server stops
+	Store string
+	// StoreWriteDelay is how long updates of the json store are kept in memory to be written together, 0
+	// writes each one right away
+	StoreWriteDelay time.Duration
+	// BundleStore is where bundle archives are kept, "local" keeps them in the data directory and "s3" in a bucket
+	BundleStore string
+	// S3 configures the bucket of the s3 bundle store, an empty CacheDir uses bundle-cache in the data directory
+	S3 bundlestore.S3Options
+}
+
+// Profile is a data directory served along with others, requests select it by its name
+type Profile struct {
+	Name    string
+	DataDir string
+}
+
+// ParseProfile parses a profile given as name=path
+func ParseProfile(value string) (Profile, error) {
+	name, dataDir, ok := strings.Cut(value, "=")
+	if !ok || dataDir == "" {
+		return Profile{}, fmt.Errorf("invalid profile %q, use name=path", value)
+	}
+	if err := api.ValidateProfileName(name); err != nil {
+		return Profile{}, err
+	}
+	return Profile{Name: name, DataDir: dataDir}, nil
+}
+
+func Run(opts Options) error {
+	basePath, err := cleanBasePath(opts.BasePath)
 	if err != nil {
 		return err
 	}
+	if err := checkProfiles(opts.Profiles); err != nil {
+		return err
+	}
 
-	// Initialize update checker with 1 hour interval
-	upd := updater.NewUpdater("Yu-Jack", "sim-gui", "main", 1*time.Hour)
-	upd.Start()
-	log.Println("Update checker started (checks every 1 hour)")
+	var upd *updater.Updater
+	if opts.DisableUpdateCheck {
+		log.Println("Update checker disabled")
+	} else {
+		upd = updater.NewUpdater("Yu-Jack", "sim-gui", "main", opts.UpdateCheckInterval)
+		upd.SetToken(opts.GitHubToken)
+		upd.Start()
+		if opts.UpdateCheckInterval > 0 {
+			log.Printf("Update checker started (checks every %s)", opts.UpdateCheckInterval)
+		} else {
+			log.Println("Update checker started (periodic checks disabled)")
+		}
+	}
 
-	srv, err := api.NewServer(store, dataDir, upd)
-	if err != nil {
+	mux := http.NewServeMux()
+	var srv *api.Server
+	if len(opts.Profiles) == 0 {
+		var closeStore func() error
+		srv, closeStore, err = newServer(opts, opts.DataDir, basePath, upd, nil, "")
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+		srv.RegisterRoutes(mux)
+	} else {
+		profiles := api.NewProfiles()
+		for _, profile := range opts.Profiles {
+			profileSrv, closeStore, err := newServer(opts, profile.DataDir, basePath, upd, profiles, profile.Name)
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+			if srv == nil {
+				srv = profileSrv
+			}
+			log.Printf("Serving profile %s from %s", profile.Name, profile.DataDir)
+		}
+		profiles.RegisterRoutes(mux)
+	}
+
+	if opts.Dev {
+		if err := registerDevProxy(mux, opts.DevServerURL, basePath); err != nil {
+			return err
+		}
+		srv.RegisterDocs(mux)
+		log.Printf("Dev mode enabled, proxying UI requests to %s, API docs at %s/api/docs", opts.DevServerURL, basePath)
+	} else {
+		assetsFS, err := fs.Sub(content, "static")
+		if err != nil {
+			return err
+		}
+		if err := registerUIHandler(mux, assetsFS, basePath); err != nil {
+			return err
+		}
+	}
+
+	// Stopping the server returns from Run so the stores write the updates they hold back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	httpServer := &http.Server{Addr: opts.Addr, Handler: enableCors(mux, basePath, enableGzip(mux))}
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Server listening on http://localhost%s%s/", opts.Addr, basePath)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
 		return err
 	}
-	mux := http.NewServeMux()
-	srv.RegisterRoutes(mux)
+	return nil
+}
 
-	if !dev {
-		if err := registerUIHandler(mux); err != nil {
+// checkProfiles checks that profiles have different names and data directories
+func checkProfiles(profiles []Profile) error {
+	names := make(map[string]bool)
+	dataDirs := make(map[string]string)
+	for _, profile := range profiles {
+		if names[profile.Name] {
+			return fmt.Errorf("profile %q is configured twice", profile.Name)
+		}
+		names[profile.Name] = true
+		dataDir, err := filepath.Abs(profile.DataDir)
+		if err != nil {
 			return err
 		}
+		if other, ok := dataDirs[dataDir]; ok {
+			return fmt.Errorf("profiles %s and %s use the same data directory %s", other, profile.Name, dataDir)
+		}
+		dataDirs[dataDir] = profile.Name
 	}
+	return nil
+}
 
-	log.Printf("Server listening on http://localhost%s", addr)
-	return http.ListenAndServe(addr, enableCors(mux))
+// newServer creates the API server of a data directory, added to profiles as name unless profiles is
+// nil. The returned function closes its store.
+func newServer(opts Options, dataDir, basePath string, upd *updater.Updater, profiles *api.Profiles, name string) (*api.Server, func() error, error) {
+	// Version paths are stored relative to the data directory, resolve it once so they don't depend on the working directory
+	dataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if profiles != nil {
+		// Profiles may share a bucket and a bundle cache directory, their bundles are kept apart by name
+		opts.S3.Prefix += name + "/"
+		if opts.S3.CacheDir != "" {
+			opts.S3.CacheDir = filepath.Join(opts.S3.CacheDir, name)
+		}
+	}
+	st, closeStore, err := newStore(opts, dataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv, err := configureServer(opts, st, dataDir, basePath, upd, profiles, name)
+	if err != nil {
+		closeStore()
+		return nil, nil, err
+	}
+	return srv, closeStore, nil
 }
 
-func registerUIHandler(mux *http.ServeMux) error {
-	// Serve UI
-	assetsFS, err := fs.Sub(content, "static")
+// configureServer creates the API server of a store and applies the options to it
+func configureServer(opts Options, st store.Storage, dataDir, basePath string, upd *updater.Updater, profiles *api.Profiles, name string) (*api.Server, error) {
+	bundles, err := newBundleStore(opts, dataDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fileServer := http.FileServer(http.FS(assetsFS))
+	srv, err := api.NewServer(st, dataDir, opts.KubectlPath, upd)
+	if err != nil {
+		return nil, err
+	}
+	if profiles != nil {
+		if err := profiles.Add(name, srv); err != nil {
+			return nil, err
+		}
+	}
+	srv.SetBundleStore(bundles)
+	srv.SetUploadLimits(api.UploadLimits{
+		MaxRequestSize: opts.MaxUploadSize,
+		BufferSize:     opts.UploadBufferSize,
+		Extract: utils.ExtractLimits{
+			MaxBytes: opts.MaxExtractSize,
+			MaxFiles: opts.MaxExtractFiles,
+			MaxRatio: opts.MaxExtractRatio,
+		},
+	})
+	srv.SetActivityMaxEntries(opts.ActivityMaxEntries)
+	srv.SetRetentionDays(opts.RetentionDays)
+	srv.SetTrashDays(opts.TrashDays)
+	srv.SetMaxConcurrentStarts(opts.MaxConcurrentStarts)
+	srv.SetRateLimit(api.RateLimit{Rate: opts.RateLimit, Burst: opts.RateLimitBurst})
+	srv.SetUserHeader(opts.UserHeader)
+	if err := srv.SetExternalURL(opts.ExternalURL); err != nil {
+		return nil, err
+	}
+	srv.SetTrustForwardedHeaders(opts.TrustForwardedHeaders)
+	tokens, err := loadTokens(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if tokens != nil {
+		srv.SetTokens(tokens)
+	}
+	if err := srv.SetImportRoot(opts.ImportRoot); err != nil {
+		return nil, err
+	}
+	srv.SetBasePath(basePath)
+	srv.Start()
+	return srv, nil
+}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api") {
+// loadTokens reads the tokens file of the data directory and reloads it on SIGHUP, without the file
+// tokens aren't required and nil is returned
+func loadTokens(dataDir string) (*auth.Tokens, error) {
+	tokens, err := auth.Load(filepath.Join(dataDir, auth.FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Requiring the tokens of %s with API requests", tokens.Path())
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := tokens.Reload(); err != nil {
+				log.Printf("Failed to reload tokens, keeping the previous ones: %v", err)
+				continue
+			}
+			log.Printf("Reloaded the tokens of %s", tokens.Path())
+		}
+	}()
+	return tokens, nil
+}
+
+// newStore opens the workspace store selected by opts.Store, the returned function closes it
+func newStore(opts Options, dataDir string) (store.Storage, func() error, error) {
+	switch opts.Store {
+	case "", "json":
+		st, err := jsonstore.NewJSONStore(filepath.Join(dataDir, "data.json"))
+		if err != nil {
+			return nil, nil, err
+		}
+		st.SetWriteDelay(opts.StoreWriteDelay)
+		return st, st.Close, nil
+	case "memory":
+		log.Println("Keeping workspaces in memory, they are lost when the server stops")
+		return memstore.NewMemoryStore(), func() error { return nil }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown store %q, use json or memory", opts.Store)
+	}
+}
+
+// newBundleStore creates the bundle store selected by opts.BundleStore
+func newBundleStore(opts Options, dataDir string) (bundlestore.Store, error) {
+	switch opts.BundleStore {
+	case "", "local":
+		return bundlestore.NewLocal(dataDir), nil
+	case "s3":
+		s3opts := opts.S3
+		if s3opts.CacheDir == "" {
+			s3opts.CacheDir = filepath.Join(dataDir, "bundle-cache")
+		}
+		bundles, err := bundlestore.NewS3(context.Background(), s3opts)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Storing bundles in bucket %s at %s", s3opts.Bucket, s3opts.Endpoint)
+		return bundles, nil
+	default:
+		return nil, fmt.Errorf("unknown bundle store %q, use local or s3", opts.BundleStore)
+	}
+}
+
+// basePathRegexp matches a cleaned base path, its segments end up in index.html unescaped
+var basePathRegexp = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// cleanBasePath normalizes a base path to start with a slash and have no trailing one, the root is empty
+func cleanBasePath(basePath string) (string, error) {
+	basePath = strings.TrimSpace(basePath)
+	if basePath == "" {
+		return "", nil
+	}
+	basePath = path.Clean("/" + basePath)
+	if basePath == "/" {
+		return "", nil
+	}
+	if !basePathRegexp.MatchString(basePath) {
+		return "", fmt.Errorf("invalid base path %q, use letters, digits and . _ ~ - between slashes", basePath)
+	}
+	return basePath, nil
+}
+
+// registerUIHandler serves the built UI from assetsFS under basePath, falling back to index.html for SPA routes.
+// Hashed assets are cached forever while index.html is revalidated with its ETag on every load.
+func registerUIHandler(mux *http.ServeMux, assetsFS fs.FS, basePath string) error {
+	etags, err := assetETags(assetsFS)
+	if err != nil {
+		return err
+	}
+	// Missing when the server is built without the UI, requests get a 404 from the file server then
+	index, err := fs.ReadFile(assetsFS, "index.html")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if index != nil {
+		index = injectBasePath(index, basePath)
+		sum := sha256.Sum256(index)
+		etags["index.html"] = fmt.Sprintf(`"%x"`, sum[:8])
+	}
+	// The file server looks the assets up without the base path
+	fileServer := http.StripPrefix(basePath, http.FileServer(http.FS(assetsFS)))
+
+	if basePath != "" {
+		// Reached without the proxy, e.g. while setting it up
+		mux.Handle("GET /{$}", http.RedirectHandler(basePath+"/", http.StatusFound))
+	}
+	mux.HandleFunc(basePath+"/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(trimProfilePath(r.URL.Path, basePath), basePath+"/api") {
 			http.NotFound(w, r)
 			return
 		}
 
 		// Check if the file exists in the assets
-		path := strings.TrimPrefix(r.URL.Path, "/")
+		path := strings.TrimPrefix(r.URL.Path, basePath+"/")
 		if path == "" {
 			path = "index.html"
 		}
 
-		_, err := assetsFS.Open(path)
-		if os.IsNotExist(err) {
+		if _, err := fs.Stat(assetsFS, path); os.IsNotExist(err) {
 			// Serve index.html for SPA routing
-			r.URL.Path = "/"
-			fileServer.ServeHTTP(w, r)
-			return
+			path = "index.html"
+		}
+
+		if etag, ok := etags[path]; ok {
+			w.Header().Set("ETag", etag)
+		}
+		if hashedAssetRegexp.MatchString(path) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
 		}
 
+		if path == "index.html" && index != nil {
+			http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(index))
+			return
+		}
 		fileServer.ServeHTTP(w, r)
 	})
 
 	return nil
 }
 
-func enableCors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow all origins for development
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// injectBasePath tells the UI where it is served: a <base> element resolves the relative asset URLs of the
+// build, and window.__SIM_GUI_BASE_PATH__ prefixes its routes and API calls. An index.html without a
+// <head> is returned as is.
+func injectBasePath(index []byte, basePath string) []byte {
+	head := bytes.Index(index, []byte("<head>"))
+	if head < 0 {
+		return index
+	}
+	at := head + len("<head>")
+	tags := fmt.Sprintf(`<base href="%s/"><script>window.__SIM_GUI_BASE_PATH__ = %q</script>`, basePath, basePath)
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	injected := make([]byte, 0, len(index)+len(tags))
+	injected = append(injected, index[:at]...)
+	injected = append(injected, tags...)
+	return append(injected, index[at:]...)
+}
+
+// hashedAssetRegexp matches build outputs with a content hash in their name, e.g. assets/index-B3x9_kQa.js
+var hashedAssetRegexp = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8,}\.[a-z0-9]+$`)
+
+// assetETags computes a strong ETag from the content of every embedded file
+func assetETags(assetsFS fs.FS) (map[string]string, error) {
+	etags := make(map[string]string)
+	err := fs.WalkDir(assetsFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(assetsFS, path)
+		if err != nil {
+			return err
 		}
+		sum := sha256.Sum256(data)
+		etags[path] = fmt.Sprintf(`"%x"`, sum[:8])
+		return nil
+	})
+	return etags, err
+}
 
-		next.ServeHTTP(w, r)
+// registerDevProxy forwards every non-API request to the frontend dev server so hot-reload works
+// when the UI is opened through the backend. Requests keep the base path, the dev server has to serve
+// the UI under it too, e.g. vite --base /tools/sim-gui/.
+func registerDevProxy(mux *http.ServeMux, devServerURL, basePath string) error {
+	target, err := url.Parse(devServerURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return fmt.Errorf("invalid dev server URL %q", devServerURL)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	mux.HandleFunc(basePath+"/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(trimProfilePath(r.URL.Path, basePath), basePath+"/api") {
+			http.NotFound(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
 	})
+
+	return nil
 }
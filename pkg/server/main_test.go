@@ -0,0 +1,148 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/api"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, mux *http.ServeMux, path string) *http.Response {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec.Result()
+}
+
+func Test_DevModeProxiesUI(t *testing.T) {
+	assert := require.New(t)
+	devServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vite " + r.URL.Path))
+	}))
+	defer devServer.Close()
+
+	mux := http.NewServeMux()
+	assert.NoError(registerDevProxy(mux, devServer.URL, ""))
+
+	resp := get(t, mux, "/")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("vite /", string(body))
+
+	resp = get(t, mux, "/src/main.tsx")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal("vite /src/main.tsx", string(body))
+
+	resp = get(t, mux, "/api/unknown")
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	assert.Error(registerDevProxy(http.NewServeMux(), "localhost:5173", ""))
+}
+
+func Test_ProdModeServesIndex(t *testing.T) {
+	assert := require.New(t)
+	assets := fstest.MapFS{
+		"index.html":    {Data: []byte("<html>sim-gui</html>")},
+		"assets/app.js": {Data: []byte("console.log(1)")},
+	}
+
+	mux := http.NewServeMux()
+	assert.NoError(registerUIHandler(mux, assets, ""))
+
+	resp := get(t, mux, "/")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("<html>sim-gui</html>", string(body))
+
+	resp = get(t, mux, "/assets/app.js")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal("console.log(1)", string(body))
+
+	// unknown paths fall back to index.html for SPA routing
+	resp = get(t, mux, "/workspaces/demo")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal("<html>sim-gui</html>", string(body))
+}
+
+func Test_BasePath(t *testing.T) {
+	assert := require.New(t)
+	assets := fstest.MapFS{
+		"index.html":    {Data: []byte("<html><head><title>sim-gui</title></head></html>")},
+		"assets/app.js": {Data: []byte("console.log(1)")},
+	}
+	srv := &api.Server{}
+	srv.SetBasePath("/tools/sim-gui")
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+	assert.NoError(registerUIHandler(mux, assets, "/tools/sim-gui"))
+	handler := enableCors(mux, "/tools/sim-gui", mux)
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec := serve("/tools/sim-gui/assets/app.js")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("console.log(1)", rec.Body.String())
+
+	// deep links get index.html telling the UI where it is served
+	rec = serve("/tools/sim-gui/workspaces/demo")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(`<html><head><base href="/tools/sim-gui/"><script>window.__SIM_GUI_BASE_PATH__ = "/tools/sim-gui"</script><title>sim-gui</title></head></html>`, rec.Body.String())
+	assert.Equal(rec.Body.String(), serve("/tools/sim-gui/").Body.String())
+
+	// the API answers under the base path only
+	rec = serve("/tools/sim-gui/api/openapi.json")
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal("*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(http.StatusNotFound, serve("/tools/sim-gui/api/unknown").Code)
+	assert.Equal(http.StatusNotFound, serve("/api/openapi.json").Code)
+	assert.Equal(http.StatusNotFound, serve("/assets/app.js").Code)
+
+	// redirects keep the base path
+	rec = serve("/tools/sim-gui")
+	assert.Equal("/tools/sim-gui/", rec.Header().Get("Location"))
+	rec = serve("/")
+	assert.Equal(http.StatusFound, rec.Code)
+	assert.Equal("/tools/sim-gui/", rec.Header().Get("Location"))
+}
+
+func Test_CleanBasePath(t *testing.T) {
+	assert := require.New(t)
+	for input, expected := range map[string]string{
+		"":                "",
+		"/":               "",
+		"/tools/sim-gui":  "/tools/sim-gui",
+		"/tools/sim-gui/": "/tools/sim-gui",
+		"tools//sim-gui":  "/tools/sim-gui",
+		" /sim_gui.v2 ":   "/sim_gui.v2",
+	} {
+		basePath, err := cleanBasePath(input)
+		assert.NoError(err, input)
+		assert.Equal(expected, basePath, input)
+	}
+
+	for _, input := range []string{"/tools/sim gui", `/"><script>`, "/tools?x=1"} {
+		_, err := cleanBasePath(input)
+		assert.Error(err, input)
+	}
+}
+
+func Test_ParseProfile(t *testing.T) {
+	assert := require.New(t)
+	profile, err := ParseProfile("customers=/mnt/customers=a")
+	assert.NoError(err)
+	assert.Equal(Profile{Name: "customers", DataDir: "/mnt/customers=a"}, profile)
+
+	for _, input := range []string{"customers", "customers=", "=/mnt/data", "Customers=/mnt/data"} {
+		_, err := ParseProfile(input)
+		assert.Error(err, input)
+	}
+	assert.Error(checkProfiles([]Profile{{Name: "a", DataDir: "/data"}, {Name: "a", DataDir: "/other"}}))
+	assert.Error(checkProfiles([]Profile{{Name: "a", DataDir: "/data"}, {Name: "b", DataDir: "/data/"}}))
+	assert.NoError(checkProfiles([]Profile{{Name: "a", DataDir: "/data/a"}, {Name: "b", DataDir: "/data/b"}}))
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GzipLargeJSON(t *testing.T) {
+	assert := require.New(t)
+	items := make([]string, 5000)
+	for i := range items {
+		items[i] = "apiVersion: v1\nkind: Pod\nmetadata:\n  name: virt-launcher\n"
+	}
+	handler := enableGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}))
+
+	plain := httptest.NewRecorder()
+	handler.ServeHTTP(plain, httptest.NewRequest("GET", "/api/workspaces/demo/resource-history", nil))
+	assert.Empty(plain.Header().Get("Content-Encoding"))
+
+	req := httptest.NewRequest("GET", "/api/workspaces/demo/resource-history", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal("gzip", rec.Header().Get("Content-Encoding"))
+	assert.Less(rec.Body.Len()*10, plain.Body.Len(), "expected at least a 10x smaller response")
+
+	gz, err := gzip.NewReader(rec.Body)
+	assert.NoError(err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(err)
+	assert.Equal(plain.Body.String(), string(body))
+}
+
+func Test_GzipSkipsDownloads(t *testing.T) {
+	assert := require.New(t)
+	handler := enableGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/kubeconfig":
+			w.Header().Set("Content-Disposition", `attachment; filename="demo-v1.kubeconfig"`)
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		w.Write([]byte(strings.Repeat("data", 1000)))
+	}))
+
+	for _, path := range []string{"/kubeconfig", "/events"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Empty(rec.Header().Get("Content-Encoding"), path)
+		assert.Equal(4000, rec.Body.Len(), path)
+	}
+}
+
+func Test_StaticAssetCaching(t *testing.T) {
+	assert := require.New(t)
+	assets := fstest.MapFS{
+		"index.html":               {Data: []byte("<html>sim-gui</html>")},
+		"assets/index-B3x9_kQa.js": {Data: []byte("console.log(1)")},
+	}
+	mux := http.NewServeMux()
+	assert.NoError(registerUIHandler(mux, assets, ""))
+
+	resp := get(t, mux, "/assets/index-B3x9_kQa.js")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("public, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(etag)
+
+	req := httptest.NewRequest("GET", "/assets/index-B3x9_kQa.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusNotModified, rec.Code)
+	assert.Empty(rec.Body.String())
+
+	resp = get(t, mux, "/workspaces/demo")
+	assert.Equal("no-cache", resp.Header.Get("Cache-Control"))
+	indexETag := resp.Header.Get("ETag")
+	assert.NotEmpty(indexETag)
+	assert.NotEqual(etag, indexETag)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", indexETag)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(http.StatusNotModified, rec.Code)
+}
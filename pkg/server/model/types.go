@@ -6,7 +6,63 @@ type Workspace struct {
 	Name        string    `json:"name"`
 	DisplayName string    `json:"displayName"`
 	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   string    `json:"createdBy,omitempty"` // User that created the workspace, empty unless the server is given a user header
 	Versions    []Version `json:"versions"`
+	// State is set while the workspace is being deleted, a server stop during the delete leaves it for
+	// the consistency repair to finish
+	State WorkspaceState `json:"state,omitempty"`
+
+	DefaultNamespace string     `json:"defaultNamespace,omitempty"` // Used by resource queries that don't specify a namespace
+	Bookmarks        []Bookmark `json:"bookmarks,omitempty"`
+
+	SavedQueries []SavedQuery `json:"savedQueries,omitempty"`
+
+	// RetentionDays is how long versions are kept after their upload, 0 uses the server default
+	// and a negative value keeps them forever
+	RetentionDays int `json:"retentionDays,omitempty"`
+
+	Preferences Preferences `json:"preferences"`
+
+	// ContainerLabels and ContainerEnv are added to the simulator and code-server containers started for
+	// the workspace, start requests can add to and override them
+	ContainerLabels map[string]string `json:"containerLabels,omitempty"`
+	ContainerEnv    map[string]string `json:"containerEnv,omitempty"`
+}
+
+// WorkspaceState is what is being done to a workspace, empty when nothing is
+type WorkspaceState string
+
+// WorkspaceStateDeleting marks a workspace whose simulators and files are being removed
+const WorkspaceStateDeleting WorkspaceState = "deleting"
+
+// Preferences change how the data of a workspace is presented in responses, never the stored data.
+// The default namespace is DefaultNamespace of the workspace.
+type Preferences struct {
+	// TimeZone is the IANA name of the zone timestamps are rendered in next to their raw value,
+	// empty renders none
+	TimeZone string `json:"timeZone,omitempty"`
+	// OutputFormat is how resources are printed in resource-history results, yaml (the default) or json
+	OutputFormat string `json:"outputFormat,omitempty"`
+}
+
+// Bookmark is a resource queried often in a workspace, it is kept even when the resource no longer exists
+type Bookmark struct {
+	Namespace    string `json:"namespace,omitempty"` // Empty for cluster scoped resources
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+	Label        string `json:"label,omitempty"`
+}
+
+// SavedQuery is a resource-history query kept to be re-run across versions
+type SavedQuery struct {
+	ID         string     `json:"id"` // e.g., q1, q2
+	Name       string     `json:"name"`
+	Resource   string     `json:"resource"`             // Same format as resource-history: namespace/type/name, type/name or type
+	Selector   string     `json:"selector,omitempty"`   // Label selector
+	VersionIDs []string   `json:"versionIDs,omitempty"` // Versions to run against, empty runs against all of them
+	Diff       bool       `json:"diff"`                 // Whether the UI compares the results instead of listing them
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastRunAt  *time.Time `json:"lastRunAt,omitempty"`
 }
 
 type VersionType string
@@ -17,13 +73,70 @@ const (
 )
 
 type Version struct {
-	ID                string      `json:"id"`   // e.g., v1, v2
-	Name              string      `json:"name"` // User provided name or filename
-	Type              VersionType `json:"type"` // "support-bundle" or "runtime"
-	CreatedAt         time.Time   `json:"createdAt"`
-	Path              string      `json:"path"`           // Path to the extracted data
-	BundlePath        string      `json:"bundlePath"`     // Path to the original zip file
-	KubeconfigPath    string      `json:"kubeconfigPath"` // Path to the kubeconfig file
-	SupportBundleName string      `json:"supportBundleName"`
-	Ready             bool        `json:"ready"`
+	ID                string            `json:"id"`   // e.g., v1, v2
+	Name              string            `json:"name"` // User provided name or filename
+	Type              VersionType       `json:"type"` // "support-bundle" or "runtime"
+	CreatedAt         time.Time         `json:"createdAt"`
+	Path              string            `json:"path"`                       // Path to the extracted data, relative to the data directory
+	BundlePath        string            `json:"bundlePath"`                 // Bundle store key of the original zip file, its path relative to the data directory
+	ExtractedOnly     bool              `json:"extractedOnly,omitempty"`    // Uploaded as an extracted directory, there is no BundlePath
+	ExtractedRemoved  bool              `json:"extractedRemoved,omitempty"` // The extracted directory was removed to free disk, it is extracted from BundlePath again on start
+	KubeconfigPath    string            `json:"kubeconfigPath"`             // Path to the kubeconfig file, relative to the data directory
+	SupportBundleName string            `json:"supportBundleName"`
+	ImportedFrom      string            `json:"importedFrom,omitempty"` // Path on the server the version was imported from instead of uploaded
+	SortIndex         int               `json:"sortIndex,omitempty"`    // Place set by reordering the versions, 0 for versions uploaded since, which follow by CreatedAt
+	CreatedBy         string            `json:"createdBy,omitempty"`    // User that uploaded or imported the version, empty unless the server is given a user header
+	Ready             bool              `json:"ready"`
+	Broken            bool              `json:"broken,omitempty"`      // Set by the consistency repair when files of the version are missing
+	BuildError        string            `json:"buildError,omitempty"`  // Error of the last image build, cleared by a successful build
+	Building          bool              `json:"building,omitempty"`    // Set while the image is built, still set on startup when the server stopped during the build
+	Interrupted       string            `json:"interrupted,omitempty"` // Why the last image build didn't finish, cleared by the next build
+	LastStartedAt     *time.Time        `json:"lastStartedAt,omitempty"`
+	LastAccessedAt    *time.Time        `json:"lastAccessedAt,omitempty"`    // Updated by kubectl-backed queries and kubeconfig downloads
+	Pinned            bool              `json:"pinned,omitempty"`            // Exempt from the retention policy of the workspace
+	CodeServerProject string            `json:"codeServerProject,omitempty"` // Directory copied into the project root of the shared code-server container
+	ReplacedAt        *time.Time        `json:"replacedAt,omitempty"`        // When the bundle was last replaced, the previous one is in the trash
+	ImageStale        bool              `json:"imageStale,omitempty"`        // The image was built from a replaced bundle, the next start builds it again
+	BaseImageDigest   string            `json:"baseImageDigest,omitempty"`   // support-bundle-kit image the last successful build was based on
+	BaseImageCreated  *time.Time        `json:"baseImageCreated,omitempty"`  // When that support-bundle-kit image was built
+	Network           string            `json:"network,omitempty"`           // Docker network the simulator is started on, the default bridge network when empty
+	Ports             []uint16          `json:"ports,omitempty"`             // Container ports published besides the apiserver
+	StartLabels       map[string]string `json:"startLabels,omitempty"`       // Container labels of the last start request, added to those of the workspace by the next starts
+	StartEnv          map[string]string `json:"startEnv,omitempty"`          // Container environment of the last start request, like StartLabels
+	Instance          string            `json:"instance,omitempty"`          // Name of the simulator container and image tag, see InstanceName
+	LastError         *LastError        `json:"lastError,omitempty"`         // Last failure of an operation run in the background, cleared once its stage succeeds
+
+	// Read from the support bundle on upload, empty when the bundle doesn't carry them
+	HarvesterVersion     string     `json:"harvesterVersion,omitempty"`
+	KubernetesVersion    string     `json:"kubernetesVersion,omitempty"`
+	NodeCount            int        `json:"nodeCount,omitempty"`
+	ClusterName          string     `json:"clusterName,omitempty"`
+	CollectedAt          *time.Time `json:"collectedAt,omitempty"`
+	CollectedAtEstimated bool       `json:"collectedAtEstimated,omitempty"` // CollectedAt is the newest resource creationTimestamp in the bundle
+}
+
+// ErrorStage is the operation a LastError happened in
+type ErrorStage string
+
+const (
+	ErrorStageBuild   ErrorStage = "build"   // Building the simulator image
+	ErrorStageStart   ErrorStage = "start"   // Creating or starting the simulator container
+	ErrorStageReady   ErrorStage = "ready"   // Loading the bundle, the container stopped or its logs couldn't be followed
+	ErrorStageCleanup ErrorStage = "cleanup" // Removing the simulator container and images
+)
+
+// LastError is a failure of a version that happened without a request waiting for it
+type LastError struct {
+	Message string     `json:"message"`
+	Stage   ErrorStage `json:"stage"`
+	Time    time.Time  `json:"time"`
+}
+
+// InstanceName returns the name of the container and image tag of the simulator of the version in
+// workspaceName. Versions created before the name was stored use "<workspace>-<version ID>".
+func (v Version) InstanceName(workspaceName string) string {
+	if v.Instance != "" {
+		return v.Instance
+	}
+	return workspaceName + "-" + v.ID
 }
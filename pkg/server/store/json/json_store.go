@@ -2,20 +2,45 @@ package jsonstore
 
 import (
 	"encoding/json"
-
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
 )
 
 type JSONStore struct {
 	filePath string
 	mu       sync.RWMutex
 	data     map[string]model.Workspace
+	// keys counts the workspaces by store.NameKey of their name
+	keys map[string]int
+	// lock is held while the store is open so a single process uses the file
+	lock *os.File
+	// modTime and size are those of the file when it was last read or written, a change means it was
+	// edited by hand and is read again before the next write
+	modTime time.Time
+	size    int64
+
+	// writeDelay is how long updates are kept in memory to be written together, 0 writes each one
+	writeDelay time.Duration
+	// flushTimer is set while updates wait to be written
+	flushTimer *time.Timer
+	// pending are the workspaces updated since the last write, applied again over the file when it was
+	// edited meanwhile
+	pending map[string]model.Workspace
+	// writes counts the writes of the file
+	writes int
 }
 
+// NewJSONStore opens the store kept in the file at path. The file is locked until Close, opening it
+// again, even from another process, fails with an *InUseError.
 func NewJSONStore(path string) (*JSONStore, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
@@ -23,14 +48,26 @@ func NewJSONStore(path string) (*JSONStore, error) {
 		return nil, err
 	}
 
+	lock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &JSONStore{
 		filePath: path,
 		data:     make(map[string]model.Workspace),
+		keys:     make(map[string]int),
+		lock:     lock,
 	}
 
 	// Load existing data if file exists
 	if _, err := os.Stat(path); err == nil {
 		if err := s.load(); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if err := s.migratePaths(); err != nil {
+			s.Close()
 			return nil, err
 		}
 	}
@@ -38,35 +75,229 @@ func NewJSONStore(path string) (*JSONStore, error) {
 	return s, nil
 }
 
+// SetWriteDelay makes UpdateWorkspace keep updates in memory for up to delay and write them to the file
+// together, which coalesces the frequent small updates of background monitors into one write. Reads
+// always see the updates. Creating and deleting workspaces still write right away, as does Flush.
+func (s *JSONStore) SetWriteDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeDelay = delay
+}
+
+// Flush writes the updates waiting for the write delay
+func (s *JSONStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flushTimer == nil {
+		return nil
+	}
+	return s.flushLocked()
+}
+
+// flushLocked writes pending updates over the current content of the file, a failed write is tried
+// again after the write delay
+func (s *JSONStore) flushLocked() error {
+	err := s.refresh()
+	if err == nil {
+		err = s.save()
+	}
+	if err != nil {
+		s.scheduleFlush()
+		return err
+	}
+	return nil
+}
+
+// scheduleFlush writes the pending updates once the write delay passed, unless a write is scheduled already
+func (s *JSONStore) scheduleFlush() {
+	if s.flushTimer != nil {
+		return
+	}
+	s.flushTimer = time.AfterFunc(s.writeDelay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.flushTimer = nil
+		if err := s.flushLocked(); err != nil {
+			log.Printf("Failed to write %s, trying again in %s: %v", s.filePath, s.writeDelay, err)
+		}
+	})
+}
+
+// Close writes pending updates and releases the lock on the file, the store must not be used afterwards
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	var flushErr error
+	if s.flushTimer != nil {
+		if flushErr = s.refresh(); flushErr == nil {
+			flushErr = s.save()
+		}
+		if flushErr != nil {
+			s.flushTimer.Stop()
+			s.flushTimer = nil
+		}
+	}
+	s.mu.Unlock()
+	return errors.Join(flushErr, unlockFile(s.lock))
+}
+
 func (s *JSONStore) load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.read()
+}
 
+// read replaces the data with the content of the file
+func (s *JSONStore) read() error {
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		return err
+	}
 	file, err := os.ReadFile(s.filePath)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(file, &s.data)
+	data := make(map[string]model.Workspace)
+	if err := json.Unmarshal(file, &data); err != nil {
+		return err
+	}
+	s.data = data
+	s.keys = store.NameKeys(data)
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// refresh reads the file again when it changed since it was last read or written, so edits made
+// while the server runs aren't overwritten by the next write. A removed file keeps the current data.
+// Updates waiting to be written replace the workspaces read, those removed from the file stay removed.
+func (s *JSONStore) refresh() error {
+	info, err := os.Stat(s.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	log.Printf("%s changed on disk, reloading it before writing", s.filePath)
+	if err := s.read(); err != nil {
+		return err
+	}
+	for name, ws := range s.pending {
+		if _, ok := s.data[name]; ok {
+			s.data[name] = ws
+		} else {
+			delete(s.pending, name)
+		}
+	}
+	return nil
+}
+
+// migratePaths rewrites version paths stored by older releases, which were prefixed with the data
+// directory at the time, into paths relative to the data directory
+func (s *JSONStore) migratePaths() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	migrated := 0
+	for name, ws := range s.data {
+		for i := range ws.Versions {
+			v := &ws.Versions[i]
+			for _, path := range []*string{&v.Path, &v.BundlePath, &v.KubeconfigPath} {
+				if rel, ok := relativeVersionPath(*path, name, v.ID); ok {
+					*path = rel
+					migrated++
+				}
+			}
+		}
+		s.data[name] = ws
+	}
+
+	if migrated == 0 {
+		return nil
+	}
+	log.Printf("Migrated %d version paths to be relative to the data directory", migrated)
+	return s.save()
+}
+
+// relativeVersionPath strips everything before the workspaces/<workspace>/<versionID> part of a path,
+// which is where the old data directory prefix ends regardless of where it was located
+func relativeVersionPath(path, workspace, versionID string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+
+	path = filepath.ToSlash(path)
+	marker := fmt.Sprintf("workspaces/%s/%s/", workspace, versionID)
+	if strings.HasPrefix(path, marker) {
+		return "", false
+	}
+
+	idx := strings.LastIndex(path, "/"+marker)
+	if idx == -1 {
+		return "", false
+	}
+	return filepath.FromSlash(path[idx+1:]), true
 }
 
+// save writes the data to a temporary file renamed over the store file, so a crash or a concurrent
+// reader never sees a partly written file. Updates waiting for the write delay are written with it.
 func (s *JSONStore) save() error {
 	data, err := json.MarshalIndent(s.data, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(s.filePath, data, 0644)
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return err
+	}
+	s.writes++
+	s.pending = nil
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if info, err := os.Stat(s.filePath); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
 }
 
 func (s *JSONStore) CreateWorkspace(ws model.Workspace) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.data[ws.Name]; exists {
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	key := store.NameKey(ws.Name)
+	if s.keys[key] > 0 {
 		return os.ErrExist
 	}
 	s.data[ws.Name] = ws
-	return s.save()
+	s.keys[key]++
+	if err := s.save(); err != nil {
+		delete(s.data, ws.Name)
+		s.keys[key]--
+		return err
+	}
+	return nil
 }
 
 func (s *JSONStore) ListWorkspaces() ([]model.Workspace, error) {
@@ -80,6 +311,15 @@ func (s *JSONStore) ListWorkspaces() ([]model.Workspace, error) {
 	return list, nil
 }
 
+// SearchVersions scans the workspaces, they are all in memory
+func (s *JSONStore) SearchVersions(query store.SearchQuery) (store.SearchResult, error) {
+	workspaces, err := s.ListWorkspaces()
+	if err != nil {
+		return store.SearchResult{}, err
+	}
+	return store.Search(workspaces, query), nil
+}
+
 func (s *JSONStore) GetWorkspace(name string) (*model.Workspace, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -94,19 +334,46 @@ func (s *JSONStore) GetWorkspace(name string) (*model.Workspace, error) {
 func (s *JSONStore) UpdateWorkspace(ws model.Workspace) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.data[ws.Name]; !exists {
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	previous, exists := s.data[ws.Name]
+	if !exists {
 		return os.ErrNotExist
 	}
 	s.data[ws.Name] = ws
-	return s.save()
+	if s.writeDelay > 0 {
+		if s.pending == nil {
+			s.pending = make(map[string]model.Workspace)
+		}
+		s.pending[ws.Name] = ws
+		s.scheduleFlush()
+		return nil
+	}
+	if err := s.save(); err != nil {
+		// Keep memory in line with the file, callers roll back their own changes on error
+		s.data[ws.Name] = previous
+		return err
+	}
+	return nil
 }
 
 func (s *JSONStore) DeleteWorkspace(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.data[name]; !exists {
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	previous, exists := s.data[name]
+	if !exists {
 		return os.ErrNotExist
 	}
 	delete(s.data, name)
-	return s.save()
+	s.keys[store.NameKey(name)]--
+	if err := s.save(); err != nil {
+		s.data[name] = previous
+		s.keys[store.NameKey(name)]++
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,319 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store/storetest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		s, err := NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func Test_RelocatedDataDir(t *testing.T) {
+	assert := require.New(t)
+	root := t.TempDir()
+	oldDir := filepath.Join(root, "old")
+	bundlePath := filepath.Join(oldDir, "workspaces", "demo", "v1", "bundle.zip")
+	assert.NoError(os.MkdirAll(filepath.Dir(bundlePath), 0755))
+	assert.NoError(os.WriteFile(bundlePath, []byte("bundle"), 0644))
+
+	// data.json as written by older releases, with absolute paths under the old data directory
+	data, err := json.Marshal(map[string]model.Workspace{
+		"demo": {
+			Name: "demo",
+			Versions: []model.Version{
+				{ID: "v1", Type: model.VersionTypeSupportBundle, BundlePath: bundlePath},
+				{ID: "v2", Type: model.VersionTypeRuntime, KubeconfigPath: "workspaces/demo/v2/admin.kubeconfig"},
+			},
+		},
+	})
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(filepath.Join(oldDir, "data.json"), data, 0644))
+
+	old, err := NewJSONStore(filepath.Join(oldDir, "data.json"))
+	assert.NoError(err)
+	assert.NoError(old.Close())
+
+	newDir := filepath.Join(root, "new")
+	assert.NoError(os.Rename(oldDir, newDir))
+
+	s, err := NewJSONStore(filepath.Join(newDir, "data.json"))
+	assert.NoError(err)
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal(filepath.Join("workspaces", "demo", "v1", "bundle.zip"), ws.Versions[0].BundlePath)
+	assert.Equal("workspaces/demo/v2/admin.kubeconfig", ws.Versions[1].KubeconfigPath)
+
+	content, err := os.ReadFile(filepath.Join(newDir, ws.Versions[0].BundlePath))
+	assert.NoError(err)
+	assert.Equal("bundle", string(content))
+}
+
+func Test_RelativeVersionPath(t *testing.T) {
+	assert := require.New(t)
+
+	rel, ok := relativeVersionPath("/data/workspaces/demo/v1/bundle.zip", "demo", "v1")
+	assert.True(ok)
+	assert.Equal(filepath.Join("workspaces", "demo", "v1", "bundle.zip"), rel)
+
+	rel, ok = relativeVersionPath("data/workspaces/demo/v1/extracted", "demo", "v1")
+	assert.True(ok)
+	assert.Equal(filepath.Join("workspaces", "demo", "v1", "extracted"), rel)
+
+	_, ok = relativeVersionPath("workspaces/demo/v1/bundle.zip", "demo", "v1")
+	assert.False(ok)
+	_, ok = relativeVersionPath("/elsewhere/bundle.zip", "demo", "v1")
+	assert.False(ok)
+	_, ok = relativeVersionPath("", "demo", "v1")
+	assert.False(ok)
+}
+
+func Test_FailedSaveKeepsPreviousState(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+
+	// a directory in place of data.json makes every save fail
+	assert.NoError(os.Remove(path))
+	assert.NoError(os.Mkdir(path, 0755))
+
+	assert.Error(s.UpdateWorkspace(model.Workspace{Name: "demo", Versions: []model.Version{{ID: "v1"}}}))
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Empty(ws.Versions)
+
+	assert.Error(s.CreateWorkspace(model.Workspace{Name: "other"}))
+	_, err = s.GetWorkspace("other")
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	assert.Error(s.DeleteWorkspace("demo"))
+	_, err = s.GetWorkspace("demo")
+	assert.NoError(err)
+}
+
+func Test_SecondStoreOnSamePathFails(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+
+	_, err = NewJSONStore(path)
+	var inUse *InUseError
+	assert.ErrorAs(err, &inUse)
+	assert.Equal(os.Getpid(), inUse.PID)
+	assert.EqualError(err, "data dir "+filepath.Dir(path)+" in use by PID "+strconv.Itoa(os.Getpid()))
+
+	// the lock is released on close
+	assert.NoError(s.Close())
+	s, err = NewJSONStore(path)
+	assert.NoError(err)
+	assert.NoError(s.Close())
+}
+
+func Test_ExternalEditIsReloadedBeforeWrite(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	defer s.Close()
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+
+	// data.json edited by hand while the store is open
+	data, err := json.Marshal(map[string]model.Workspace{
+		"demo":   {Name: "demo", DisplayName: "Edited"},
+		"manual": {Name: "manual"},
+	})
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path, data, 0644))
+	// coarse filesystem timestamps could hide the edit if the size matched
+	assert.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "other"}))
+
+	reopened := map[string]model.Workspace{}
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(content, &reopened))
+	assert.Len(reopened, 3)
+	assert.Equal("Edited", reopened["demo"].DisplayName)
+
+	ws, err := s.GetWorkspace("manual")
+	assert.NoError(err)
+	assert.Equal("manual", ws.Name)
+}
+
+func Test_WriteDelayCoalescesUpdates(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	s.SetWriteDelay(time.Hour)
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		assert.NoError(s.CreateWorkspace(model.Workspace{Name: "ws-" + strconv.Itoa(i), Versions: []model.Version{{ID: "v1"}}}))
+	}
+	created := s.writes
+
+	// the ready monitors of n simulators marking their versions at once
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			ws, err := s.GetWorkspace(name)
+			if err == nil {
+				ws.Versions = []model.Version{{ID: "v1", Ready: true}}
+				err = s.UpdateWorkspace(*ws)
+			}
+			assert.NoError(err)
+		}("ws-" + strconv.Itoa(i))
+	}
+	wg.Wait()
+	assert.Equal(created, s.writes, "expected the updates to wait for the write delay")
+
+	// reads see the updates before they are written
+	ws, err := s.GetWorkspace("ws-0")
+	assert.NoError(err)
+	assert.True(ws.Versions[0].Ready)
+
+	assert.NoError(s.Close())
+	assert.Equal(created+1, s.writes)
+
+	s, err = NewJSONStore(path)
+	assert.NoError(err)
+	defer s.Close()
+	list, err := s.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(list, n)
+	for _, ws := range list {
+		assert.True(ws.Versions[0].Ready, ws.Name)
+	}
+}
+
+func Test_WriteDelayFlushesInBackground(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	defer s.Close()
+	s.SetWriteDelay(10 * time.Millisecond)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "demo", DisplayName: "First"}))
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "demo", DisplayName: "Second"}))
+	assert.Eventually(func() bool {
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), `"Second"`)
+	}, time.Second, 5*time.Millisecond)
+
+	s.mu.RLock()
+	writes := s.writes
+	s.mu.RUnlock()
+	assert.Equal(2, writes, "expected one write for the creation and one for both updates")
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(err)
+	for _, entry := range entries {
+		assert.NotContains(entry.Name(), ".tmp", entry.Name())
+	}
+}
+
+func Test_ExternalEditDuringWriteDelay(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	defer s.Close()
+	s.SetWriteDelay(time.Hour)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "edited"}))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "removed"}))
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "demo", DisplayName: "Pending"}))
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "removed", DisplayName: "Pending"}))
+
+	// data.json edited by hand while the update waits to be written
+	data, err := json.Marshal(map[string]model.Workspace{
+		"demo":   {Name: "demo", DisplayName: "Overwritten"},
+		"edited": {Name: "edited", DisplayName: "Edited"},
+		"manual": {Name: "manual"},
+	})
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path, data, 0644))
+	assert.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+
+	assert.NoError(s.Flush())
+	reopened := map[string]model.Workspace{}
+	content, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(content, &reopened))
+	assert.Len(reopened, 3, "expected the workspace removed by hand to stay removed")
+	assert.Equal("Pending", reopened["demo"].DisplayName, "expected the pending update over the edit")
+	assert.Equal("Edited", reopened["edited"].DisplayName)
+	assert.Contains(reopened, "manual")
+
+	// the timer of the write delay reads the edits too
+	s.SetWriteDelay(200 * time.Millisecond)
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "demo", DisplayName: "Later"}))
+	data, err = json.Marshal(map[string]model.Workspace{
+		"demo":   {Name: "demo"},
+		"edited": {Name: "edited", DisplayName: "Edited again"},
+	})
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path, data, 0644))
+	assert.NoError(os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	assert.Eventually(func() bool {
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), `"Later"`)
+	}, 2*time.Second, 5*time.Millisecond)
+	content, err = os.ReadFile(path)
+	assert.NoError(err)
+	assert.Contains(string(content), `"Edited again"`)
+	_, err = s.GetWorkspace("manual")
+	assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func Test_CaseCollidingNamesFromFile(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "data.json")
+	// written by an older release, which told names apart by case
+	data, err := json.Marshal(map[string]model.Workspace{
+		"Demo": {Name: "Demo"},
+		"demo": {Name: "demo"},
+	})
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path, data, 0644))
+
+	s, err := NewJSONStore(path)
+	assert.NoError(err)
+	defer s.Close()
+	list, err := s.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(list, 2, "expected colliding workspaces to be kept")
+
+	assert.ErrorIs(s.CreateWorkspace(model.Workspace{Name: "DEMO"}), os.ErrExist)
+	// the key stays taken until both are gone
+	assert.NoError(s.DeleteWorkspace("Demo"))
+	assert.ErrorIs(s.CreateWorkspace(model.Workspace{Name: "DEMO"}), os.ErrExist)
+	assert.NoError(s.DeleteWorkspace("demo"))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "DEMO"}))
+}
@@ -0,0 +1,66 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InUseError is returned when the store file is already locked by another store
+type InUseError struct {
+	Dir string
+	PID int // 0 when the owner couldn't be read from the lock file
+}
+
+func (e *InUseError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("data dir %s in use by another process", e.Dir)
+	}
+	return fmt.Sprintf("data dir %s in use by PID %d", e.Dir, e.PID)
+}
+
+// lockFile takes an exclusive advisory lock on a file next to path and writes the PID of this process
+// into it. The lock is released by the kernel when the process exits, so it never goes stale.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tryLock(f); err != nil {
+		defer f.Close()
+		if errors.Is(err, errLocked) {
+			content, _ := io.ReadAll(f)
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+			return nil, &InUseError{Dir: filepath.Dir(path), PID: pid}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", f.Name(), err)
+	}
+
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		unlockFile(f)
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile releases a lock taken by lockFile, the lock file is kept as removing it could race with
+// another process locking it
+func unlockFile(f *os.File) error {
+	if f == nil {
+		return nil
+	}
+	if err := unlock(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package jsonstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// errLocked is returned by tryLock when another process holds the lock
+var errLocked = syscall.EWOULDBLOCK
+
+// tryLock takes an exclusive advisory lock on f without waiting for it
+func tryLock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// unlock releases the lock taken by tryLock
+func unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
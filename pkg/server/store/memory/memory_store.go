@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"os"
+	"sync"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+)
+
+// MemoryStore keeps workspaces in memory only, they are lost when the process exits. It behaves like
+// the JSON store otherwise, e.g. for tests and demos that shouldn't leave anything behind.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string]model.Workspace
+	// keys counts the workspaces by store.NameKey of their name
+	keys map[string]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string]model.Workspace),
+		keys: make(map[string]int),
+	}
+}
+
+func (s *MemoryStore) CreateWorkspace(ws model.Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := store.NameKey(ws.Name)
+	if s.keys[key] > 0 {
+		return os.ErrExist
+	}
+	s.data[ws.Name] = ws
+	s.keys[key]++
+	return nil
+}
+
+func (s *MemoryStore) ListWorkspaces() ([]model.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]model.Workspace, 0, len(s.data))
+	for _, ws := range s.data {
+		list = append(list, ws)
+	}
+	return list, nil
+}
+
+// SearchVersions scans the workspaces, they are all in memory
+func (s *MemoryStore) SearchVersions(query store.SearchQuery) (store.SearchResult, error) {
+	workspaces, err := s.ListWorkspaces()
+	if err != nil {
+		return store.SearchResult{}, err
+	}
+	return store.Search(workspaces, query), nil
+}
+
+func (s *MemoryStore) GetWorkspace(name string) (*model.Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ws, exists := s.data[name]
+	if !exists {
+		return nil, os.ErrNotExist
+	}
+	return &ws, nil
+}
+
+func (s *MemoryStore) UpdateWorkspace(ws model.Workspace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.data[ws.Name]; !exists {
+		return os.ErrNotExist
+	}
+	s.data[ws.Name] = ws
+	return nil
+}
+
+func (s *MemoryStore) DeleteWorkspace(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.data[name]; !exists {
+		return os.ErrNotExist
+	}
+	delete(s.data, name)
+	s.keys[store.NameKey(name)]--
+	return nil
+}
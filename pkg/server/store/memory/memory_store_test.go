@@ -0,0 +1,14 @@
+package memstore
+
+import (
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store/storetest"
+)
+
+func Test_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Storage {
+		return NewMemoryStore()
+	})
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
+
+// SearchQuery selects versions by the metadata of their bundle. Every set field has to match, an empty
+// query matches every version.
+type SearchQuery struct {
+	// Text is matched case-insensitively against the name and display name of the workspace and the
+	// name, bundle name, cluster name, Harvester and Kubernetes versions of the version
+	Text string
+	// HarvesterVersion matches the version itself and its patch releases, v1.3 matches v1.3.2
+	HarvesterVersion string
+	// ClusterName matches the cluster name case-insensitively
+	ClusterName string
+	// CollectedAfter and CollectedBefore bound when the bundle was collected, versions without a
+	// collection time don't match either
+	CollectedAfter  *time.Time
+	CollectedBefore *time.Time
+	// Limit caps the versions returned, 0 returns them all
+	Limit int
+}
+
+// SearchResult are the versions a SearchQuery matched grouped by workspace, Total counts them all
+// even when Limit left some out
+type SearchResult struct {
+	Workspaces []WorkspaceMatch `json:"workspaces"`
+	Total      int              `json:"total"`
+}
+
+// WorkspaceMatch is a workspace with the versions of it that matched
+type WorkspaceMatch struct {
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+	Versions    []model.Version `json:"versions"`
+}
+
+// Search scans workspaces for the versions matching query, workspaces ordered by name and versions in
+// the order they are kept. Stores without an index of their own search their workspaces with it.
+func Search(workspaces []model.Workspace, query SearchQuery) SearchResult {
+	sorted := make([]model.Workspace, len(workspaces))
+	copy(sorted, workspaces)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	text := strings.ToLower(strings.TrimSpace(query.Text))
+	result := SearchResult{Workspaces: []WorkspaceMatch{}}
+	returned := 0
+	for _, ws := range sorted {
+		// A workspace matching the text matches with all its versions
+		wsMatches := text == "" || containsFold(text, ws.Name, ws.DisplayName)
+		var match *WorkspaceMatch
+		for _, v := range ws.Versions {
+			if !wsMatches && !containsFold(text, v.Name, v.SupportBundleName, v.ClusterName, v.HarvesterVersion, v.KubernetesVersion) {
+				continue
+			}
+			if !query.matchesFilters(v) {
+				continue
+			}
+			result.Total++
+			if query.Limit > 0 && returned >= query.Limit {
+				continue
+			}
+			if match == nil {
+				result.Workspaces = append(result.Workspaces, WorkspaceMatch{Name: ws.Name, DisplayName: ws.DisplayName})
+				match = &result.Workspaces[len(result.Workspaces)-1]
+			}
+			match.Versions = append(match.Versions, v)
+			returned++
+		}
+	}
+	return result
+}
+
+// matchesFilters reports whether v matches the fields of the query besides Text
+func (q SearchQuery) matchesFilters(v model.Version) bool {
+	if q.HarvesterVersion != "" && !matchesRelease(q.HarvesterVersion, v.HarvesterVersion) {
+		return false
+	}
+	if q.ClusterName != "" && !strings.EqualFold(q.ClusterName, v.ClusterName) {
+		return false
+	}
+	if q.CollectedAfter != nil && (v.CollectedAt == nil || v.CollectedAt.Before(*q.CollectedAfter)) {
+		return false
+	}
+	if q.CollectedBefore != nil && (v.CollectedAt == nil || v.CollectedAt.After(*q.CollectedBefore)) {
+		return false
+	}
+	return true
+}
+
+// matchesRelease reports whether version is release or one of its patch releases and pre-releases,
+// comparing case-insensitively so that v1.3 matches v1.3.2 and v1.3-rc1 but not v1.30
+func matchesRelease(release, version string) bool {
+	release, version = strings.ToLower(release), strings.ToLower(version)
+	rest, ok := strings.CutPrefix(version, release)
+	return ok && (rest == "" || rest[0] == '.' || rest[0] == '-')
+}
+
+// containsFold reports whether one of values contains the lower case text, ignoring case
+func containsFold(text string, values ...string) bool {
+	for _, value := range values {
+		if value != "" && strings.Contains(strings.ToLower(value), text) {
+			return true
+		}
+	}
+	return false
+}
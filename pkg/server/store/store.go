@@ -1,6 +1,10 @@
 package store
 
-import "github.com/Yu-Jack/sim-gui/pkg/server/model"
+import (
+	"strings"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+)
 
 type Storage interface {
 	CreateWorkspace(workspace model.Workspace) error
@@ -8,4 +12,23 @@ type Storage interface {
 	GetWorkspace(name string) (*model.Workspace, error)
 	UpdateWorkspace(workspace model.Workspace) error
 	DeleteWorkspace(name string) error
+	// SearchVersions returns the versions of every workspace matching query
+	SearchVersions(query SearchQuery) (SearchResult, error)
+}
+
+// NameKey returns the key workspace names are told apart by. Workspaces are kept in directories named
+// after them, which are the same directory on case-insensitive filesystems when the names only differ
+// in case, so CreateWorkspace fails with os.ErrExist for a name whose key is taken.
+func NameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// NameKeys counts the workspaces of data by the key of their name, a count above one is a collision
+// of names kept by older releases
+func NameKeys(data map[string]model.Workspace) map[string]int {
+	keys := make(map[string]int, len(data))
+	for name := range data {
+		keys[NameKey(name)]++
+	}
+	return keys
 }
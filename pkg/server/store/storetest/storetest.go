@@ -0,0 +1,233 @@
+// Package storetest is the conformance suite of store.Storage, every backend runs it in its own tests
+package storetest
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"github.com/Yu-Jack/sim-gui/pkg/server/store"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs every check of the suite against a store returned by newStore, which must return an empty
+// store each call. A failing check is told apart by the line it failed at.
+func Run(t *testing.T, newStore func(t *testing.T) store.Storage) {
+	for _, check := range []func(t *testing.T, s store.Storage){
+		testCreateAndGet,
+		testCreateExisting,
+		testCreateDifferentCase,
+		testList,
+		testUpdate,
+		testDelete,
+		testMissingWorkspace,
+		testReturnedWorkspaceIsCopy,
+		testConcurrentUpdates,
+		testSearchVersions,
+	} {
+		check(t, newStore(t))
+	}
+}
+
+func testCreateAndGet(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	created := time.Date(2024, 11, 18, 8, 0, 0, 0, time.UTC)
+	assert.NoError(s.CreateWorkspace(model.Workspace{
+		Name:        "demo",
+		DisplayName: "Demo",
+		CreatedAt:   created,
+		Versions:    []model.Version{{ID: "v1", Name: "bundle.zip", CreatedAt: created}},
+	}))
+
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal("Demo", ws.DisplayName)
+	assert.True(created.Equal(ws.CreatedAt))
+	assert.Len(ws.Versions, 1)
+	assert.Equal("bundle.zip", ws.Versions[0].Name)
+}
+
+func testCreateExisting(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo", DisplayName: "First"}))
+
+	err := s.CreateWorkspace(model.Workspace{Name: "demo", DisplayName: "Second"})
+	assert.ErrorIs(err, os.ErrExist)
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal("First", ws.DisplayName)
+}
+
+func testCreateDifferentCase(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "Demo"}))
+
+	err := s.CreateWorkspace(model.Workspace{Name: "demo"})
+	assert.ErrorIs(err, os.ErrExist, "expected names differing in case to share a directory")
+	_, err = s.GetWorkspace("demo")
+	assert.ErrorIs(err, os.ErrNotExist)
+
+	// the name is free again once the workspace is deleted
+	assert.NoError(s.DeleteWorkspace("Demo"))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+}
+
+func testList(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	workspaces, err := s.ListWorkspaces()
+	assert.NoError(err)
+	assert.NotNil(workspaces, "an empty store lists no workspaces rather than nil")
+	assert.Empty(workspaces)
+
+	for _, name := range []string{"b", "a", "c"} {
+		assert.NoError(s.CreateWorkspace(model.Workspace{Name: name}))
+	}
+	workspaces, err = s.ListWorkspaces()
+	assert.NoError(err)
+	names := make([]string, 0, len(workspaces))
+	for _, ws := range workspaces {
+		names = append(names, ws.Name)
+	}
+	sort.Strings(names)
+	assert.Equal([]string{"a", "b", "c"}, names)
+}
+
+func testUpdate(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+
+	assert.NoError(s.UpdateWorkspace(model.Workspace{Name: "demo", DisplayName: "Renamed", Versions: []model.Version{{ID: "v1"}}}))
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal("Renamed", ws.DisplayName)
+	assert.Len(ws.Versions, 1)
+}
+
+func testDelete(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "other"}))
+
+	assert.NoError(s.DeleteWorkspace("demo"))
+	_, err := s.GetWorkspace("demo")
+	assert.ErrorIs(err, os.ErrNotExist)
+	workspaces, err := s.ListWorkspaces()
+	assert.NoError(err)
+	assert.Len(workspaces, 1)
+
+	// the name can be used again
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo"}))
+}
+
+func testMissingWorkspace(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	_, err := s.GetWorkspace("missing")
+	assert.ErrorIs(err, os.ErrNotExist)
+	assert.ErrorIs(s.UpdateWorkspace(model.Workspace{Name: "missing"}), os.ErrNotExist)
+	assert.ErrorIs(s.DeleteWorkspace("missing"), os.ErrNotExist)
+
+	// updating doesn't create the workspace
+	_, err = s.GetWorkspace("missing")
+	assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func testReturnedWorkspaceIsCopy(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "demo", DisplayName: "Demo"}))
+
+	// changes only take effect through UpdateWorkspace
+	ws, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	ws.DisplayName = "Changed"
+	ws.Versions = append(ws.Versions, model.Version{ID: "v1"})
+
+	stored, err := s.GetWorkspace("demo")
+	assert.NoError(err)
+	assert.Equal("Demo", stored.DisplayName)
+	assert.Empty(stored.Versions)
+}
+
+func testConcurrentUpdates(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	names := []string{"a", "b", "c", "d"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := s.CreateWorkspace(model.Workspace{Name: name}); err != nil {
+				t.Error(err)
+				return
+			}
+			for i := 0; i < 10; i++ {
+				if err := s.UpdateWorkspace(model.Workspace{Name: name, DisplayName: name}); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.ListWorkspaces(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		ws, err := s.GetWorkspace(name)
+		assert.NoError(err)
+		assert.Equal(name, ws.DisplayName)
+	}
+}
+
+func testSearchVersions(t *testing.T, s store.Storage) {
+	assert := require.New(t)
+	collected := time.Date(2024, 11, 18, 8, 0, 0, 0, time.UTC)
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "case-1234", DisplayName: "Upgrade failure", Versions: []model.Version{
+		{ID: "v1", ClusterName: "cluster-foo", HarvesterVersion: "v1.3.2", CollectedAt: &collected},
+		{ID: "v2", ClusterName: "cluster-bar", HarvesterVersion: "v1.30.0"},
+	}}))
+	assert.NoError(s.CreateWorkspace(model.Workspace{Name: "case-5678", Versions: []model.Version{
+		{ID: "v1", Name: "Cluster-Foo.zip", HarvesterVersion: "v1.4.0"},
+		{ID: "v2", ClusterName: "other", HarvesterVersion: "v1.3.0"},
+	}}))
+
+	ids := func(result store.SearchResult) []string {
+		var found []string
+		for _, ws := range result.Workspaces {
+			for _, v := range ws.Versions {
+				found = append(found, ws.Name+"/"+v.ID)
+			}
+		}
+		return found
+	}
+
+	// the text is matched ignoring case, the workspace name matches all its versions
+	result, err := s.SearchVersions(store.SearchQuery{Text: "CLUSTER-foo"})
+	assert.NoError(err)
+	assert.Equal([]string{"case-1234/v1", "case-5678/v1"}, ids(result))
+	assert.Equal("Upgrade failure", result.Workspaces[0].DisplayName)
+	result, err = s.SearchVersions(store.SearchQuery{Text: "upgrade"})
+	assert.NoError(err)
+	assert.Equal([]string{"case-1234/v1", "case-1234/v2"}, ids(result))
+
+	// v1.3 matches its patch releases only
+	result, err = s.SearchVersions(store.SearchQuery{HarvesterVersion: "v1.3"})
+	assert.NoError(err)
+	assert.Equal([]string{"case-1234/v1", "case-5678/v2"}, ids(result))
+	result, err = s.SearchVersions(store.SearchQuery{Text: "case", HarvesterVersion: "v1.3", CollectedAfter: &collected})
+	assert.NoError(err)
+	assert.Equal([]string{"case-1234/v1"}, ids(result))
+
+	// the total counts the versions left out by the limit
+	result, err = s.SearchVersions(store.SearchQuery{Text: "case", Limit: 3})
+	assert.NoError(err)
+	assert.Equal(4, result.Total)
+	assert.Equal([]string{"case-1234/v1", "case-1234/v2", "case-5678/v1"}, ids(result))
+
+	result, err = s.SearchVersions(store.SearchQuery{Text: "missing"})
+	assert.NoError(err)
+	assert.NotNil(result.Workspaces)
+	assert.Zero(result.Total)
+}
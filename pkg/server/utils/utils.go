@@ -1,19 +1,109 @@
 package utils
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/Yu-Jack/sim-gui/pkg/docker"
 	"github.com/Yu-Jack/sim-gui/pkg/executor"
 	"github.com/Yu-Jack/sim-gui/pkg/server/model"
+	"gopkg.in/yaml.v3"
 )
 
-func Unzip(src, dest string) error {
+// ratioMinBytes is the size from which a file is checked against MaxRatio, small files compress unevenly
+const ratioMinBytes = 1 << 20
+
+// ExtractLimits bound what extracting a bundle writes, so a zip bomb or a corrupt bundle can't fill the
+// disk. Zero values don't limit.
+type ExtractLimits struct {
+	// MaxBytes is the total size of the extracted files
+	MaxBytes int64
+	// MaxFiles is the number of extracted files
+	MaxFiles int
+	// MaxRatio is how many times its compressed size a file of at least 1 MiB may be
+	MaxRatio int64
+}
+
+// DefaultExtractLimits fit the largest support bundles with room to spare
+var DefaultExtractLimits = ExtractLimits{
+	MaxBytes: 100 << 30,
+	MaxFiles: 1000000,
+	MaxRatio: 1000,
+}
+
+// ExtractLimitError is returned when an extraction exceeds its limits or an entry of a zip doesn't
+// match its header
+type ExtractLimitError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ExtractLimitError) Error() string {
+	return fmt.Sprintf("extracting %s %s", e.Name, e.Reason)
+}
+
+// CheckRatio fails when size bytes stored in compressed bytes expand more than MaxRatio allows
+func (l ExtractLimits) CheckRatio(name string, size, compressed int64) error {
+	if l.MaxRatio <= 0 || size < ratioMinBytes {
+		return nil
+	}
+	if compressed <= 0 || size/compressed > l.MaxRatio {
+		return &ExtractLimitError{Name: name, Reason: fmt.Sprintf("expands %d bytes from %d, above the ratio limit of %d", size, compressed, l.MaxRatio)}
+	}
+	return nil
+}
+
+// ExtractCounter adds up what an extraction writes and fails once it is over its limits, the nested
+// archives of a bundle share one
+type ExtractCounter struct {
+	Limits ExtractLimits
+	Bytes  int64
+	Files  int
+}
+
+// Add counts files and bytes written for name
+func (c *ExtractCounter) Add(name string, files int, bytes int64) error {
+	c.Files += files
+	c.Bytes += bytes
+	if c.Limits.MaxFiles > 0 && c.Files > c.Limits.MaxFiles {
+		return &ExtractLimitError{Name: name, Reason: fmt.Sprintf("exceeds the limit of %d files", c.Limits.MaxFiles)}
+	}
+	if c.Limits.MaxBytes > 0 && c.Bytes > c.Limits.MaxBytes {
+		return &ExtractLimitError{Name: name, Reason: fmt.Sprintf("exceeds the limit of %d extracted bytes", c.Limits.MaxBytes)}
+	}
+	return nil
+}
+
+// zipEntrySize is the size the header of a zip entry declares, capped so one more byte can be read
+func zipEntrySize(f *zip.File) int64 {
+	if f.UncompressedSize64 >= math.MaxInt64 {
+		return math.MaxInt64 - 1
+	}
+	return int64(f.UncompressedSize64)
+}
+
+// addZipEntry counts a file of a zip by its header
+func (c *ExtractCounter) addZipEntry(f *zip.File) error {
+	size := zipEntrySize(f)
+	if err := c.Limits.CheckRatio(f.Name, size, int64(f.CompressedSize64)); err != nil {
+		return err
+	}
+	return c.Add(f.Name, 1, size)
+}
+
+// CheckZip checks the headers of a zip against what is left of the limits of c, without extracting it.
+// c isn't changed, the files are counted once they are written.
+func CheckZip(src string, c ExtractCounter) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return err
@@ -21,6 +111,53 @@ func Unzip(src, dest string) error {
 	defer r.Close()
 
 	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := c.addZipEntry(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Unzip(src, dest string, limits ExtractLimits) error {
+	return UnzipWithProgress(src, dest, limits, nil)
+}
+
+// UnzipWithProgress is Unzip calling progress, when not nil, after each entry with the number of
+// entries extracted and in the archive. Entries are checked against limits by their headers before
+// they are written, and never written past the size their header declares. dest is removed when the
+// extraction fails, it should be a directory of its own.
+func UnzipWithProgress(src, dest string, limits ExtractLimits, progress func(done, total int)) error {
+	err := unzip(src, dest, &ExtractCounter{Limits: limits}, nil, progress)
+	if err != nil {
+		os.RemoveAll(dest)
+	}
+	return err
+}
+
+// UnzipNested extracts a zip nested in a bundle into dest, counting its files with the counter of the
+// bundle. The metadata macOS adds to archives is skipped. dest is kept when the extraction fails, it
+// holds the rest of the bundle.
+func UnzipNested(src, dest string, counter *ExtractCounter) error {
+	return unzip(src, dest, counter, isArchiveJunk, nil)
+}
+
+func unzip(src, dest string, counter *ExtractCounter, skip func(name string) bool, progress func(done, total int)) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for i, f := range r.File {
+		if progress != nil && i > 0 {
+			progress(i, len(r.File))
+		}
+		if skip != nil && skip(f.Name) {
+			continue
+		}
 		fpath := filepath.Join(dest, f.Name)
 
 		// Check for ZipSlip
@@ -32,6 +169,9 @@ func Unzip(src, dest string) error {
 			os.MkdirAll(fpath, os.ModePerm)
 			continue
 		}
+		if err := counter.addZipEntry(f); err != nil {
+			return err
+		}
 
 		if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
 			return err
@@ -48,26 +188,184 @@ func Unzip(src, dest string) error {
 			return err
 		}
 
-		_, err = io.Copy(outFile, rc)
+		// Headers can lie, the reader fails with ErrFormat once an entry is larger than declared and
+		// the limit keeps the file at the declared size regardless
+		declared := zipEntrySize(f)
+		_, err = io.Copy(outFile, io.LimitReader(rc, declared+1))
 
 		outFile.Close()
 		rc.Close()
 
+		if errors.Is(err, zip.ErrFormat) {
+			return &ExtractLimitError{Name: f.Name, Reason: fmt.Sprintf("doesn't match its header, which declares %d bytes and a checksum", declared)}
+		}
 		if err != nil {
 			return err
 		}
 	}
+	if progress != nil {
+		progress(len(r.File), len(r.File))
+	}
 	return nil
 }
 
-func FindLatestAvailableExecutor(name string, ws *model.Workspace, dockerCli *docker.Client) (executor.Executor, error) {
+// isArchiveJunk tells the metadata macOS adds to archives, which nested archives are extracted without
+func isArchiveJunk(name string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == "__MACOSX" || strings.HasPrefix(part, "._") {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader reads the decompressed stream of an archive and fails once it expands the compressed
+// bytes read so far beyond the ratio limit, so a bomb is stopped while it is written
+type ratioReader struct {
+	r          io.Reader
+	name       string
+	limits     ExtractLimits
+	compressed func() int64
+	n          int64
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if ratioErr := r.limits.CheckRatio(r.name, r.n, r.compressed()); ratioErr != nil {
+		return n, ratioErr
+	}
+	return n, err
+}
+
+// Untar extracts an uncompressed tar stream into dest within limits. Only directories and regular files
+// are extracted, each file is counted by its header before it is written.
+func Untar(r io.Reader, dest string, limits ExtractLimits) error {
+	return untar(r, dest, &ExtractCounter{Limits: limits}, nil)
+}
+
+// UntarNested extracts a tar nested in a bundle into dest, counting its files with the counter of the
+// bundle. Archives ending with .gz or .tgz are decompressed with gzip and those ending with .xz or .txz
+// with the xz command, they fail once they expand beyond the ratio limit. The metadata macOS adds to
+// archives is skipped.
+func UntarNested(src, dest string, counter *ExtractCounter) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	name := filepath.Base(src)
+
+	switch {
+	case strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz"):
+		compressed := &countingReader{r: f}
+		gz, err := gzip.NewReader(compressed)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		stream := &ratioReader{r: gz, name: name, limits: counter.Limits, compressed: func() int64 { return compressed.n }}
+		return untar(stream, dest, counter, isArchiveJunk)
+	case strings.HasSuffix(name, ".xz") || strings.HasSuffix(name, ".txz"):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("xz", "-dc")
+		cmd.Stdin = f
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		// How much of the archive xz consumed isn't known, the ratio is checked against all of it
+		stream := &ratioReader{r: out, name: name, limits: counter.Limits, compressed: info.Size}
+		untarErr := untar(stream, dest, counter, isArchiveJunk)
+		if untarErr != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return untarErr
+		}
+		// The padding after the end of the tar is left, xz fails writing it to a closed pipe otherwise
+		io.Copy(io.Discard, out)
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("xz failed: %v, output: %s", err, stderr.String())
+		}
+		return nil
+	}
+	return untar(f, dest, counter, isArchiveJunk)
+}
+
+func untar(r io.Reader, dest string, counter *ExtractCounter, skip func(name string) bool) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if skip != nil && skip(hdr.Name) {
+			continue
+		}
+
+		fpath := filepath.Join(dest, hdr.Name)
+
+		// Check for TarSlip, the archive root itself is allowed
+		if fpath != filepath.Clean(dest) && !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := counter.Add(hdr.Name, 1, hdr.Size); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+				return err
+			}
+			outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(outFile, tr)
+			outFile.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func FindLatestAvailableExecutor(name string, ws *model.Workspace, dockerCli *docker.Client, dataDir, kubectlPath string) (executor.Executor, error) {
 	for i := len(ws.Versions) - 1; i >= 0; i-- {
 		v := ws.Versions[i]
 		if v.Type == model.VersionTypeRuntime {
-			return executor.NewRuntimeExecutor(v.KubeconfigPath), nil
+			return executor.NewRuntimeExecutor(ResolveDataPath(dataDir, v.KubeconfigPath), kubectlPath), nil
 		}
 
-		iname := fmt.Sprintf("%s-%s", name, v.ID)
+		iname := v.InstanceName(name)
 		containers, err := dockerCli.FindRunningContainer(iname)
 		if err == nil && len(containers) > 0 {
 			return executor.NewContainerExecutor(dockerCli, iname), nil
@@ -76,8 +374,71 @@ func FindLatestAvailableExecutor(name string, ws *model.Workspace, dockerCli *do
 	return nil, fmt.Errorf("no running simulator or runtime cluster found")
 }
 
-func ExecKubectl(exec executor.Executor, args ...string) (string, string, error) {
+// ResolveDataPath turns a path stored in the model, which is relative to the data directory, into a usable path.
+// Absolute paths written before paths were stored relative are returned unchanged.
+func ResolveDataPath(dataDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dataDir, path)
+}
+
+// kubectlEnv points kubectl in simulator containers to the simulated cluster
+var kubectlEnv = []string{"KUBECONFIG=/root/.sim/admin.kubeconfig"}
+
+// ExecKubectl runs kubectl, the error is only set when kubectl couldn't be run. Use KubectlStatus to
+// tell a missing resource from other failures.
+func ExecKubectl(exec executor.Executor, args ...string) (executor.ExecResult, error) {
+	cmd := append([]string{"kubectl"}, args...)
+	return executor.Run(exec, cmd, kubectlEnv)
+}
+
+// Outcomes of a kubectl command, see KubectlStatus
+const (
+	KubectlFound    = "found"
+	KubectlNotFound = "not_found"
+	KubectlError    = "error"
+)
+
+// KubectlStatus classifies the result of ExecKubectl. kubectl exits with 1 for every error, a resource
+// missing on the server is told apart by the NotFound in stderr. The error is set unless the command
+// succeeded, it is stderr for missing resources.
+func KubectlStatus(result executor.ExecResult, err error) (string, error) {
+	switch {
+	case err != nil:
+		return KubectlError, err
+	case result.ExitCode == 0:
+		return KubectlFound, nil
+	case result.ExitCode == 1 && strings.Contains(result.Stderr, "NotFound"):
+		return KubectlNotFound, errors.New(strings.TrimSpace(result.Stderr))
+	}
+	return KubectlError, &executor.ExitError{ExitCode: result.ExitCode, Stderr: result.Stderr}
+}
+
+// ExecKubectlStream runs kubectl and returns its stdout while it is produced
+func ExecKubectlStream(ctx context.Context, exec executor.Executor, args ...string) (executor.Stream, error) {
 	cmd := append([]string{"kubectl"}, args...)
-	env := []string{"KUBECONFIG=/root/.sim/admin.kubeconfig"}
-	return exec.Exec(cmd, env)
+	return exec.ExecStream(ctx, cmd, kubectlEnv)
+}
+
+// DecodeKubectlYAML runs kubectl with YAML output and decodes stdout into out while it is read,
+// so large lists are never held in memory as a string. Empty output leaves out untouched.
+func DecodeKubectlYAML(ctx context.Context, exec executor.Executor, out interface{}, args ...string) (string, error) {
+	stream, err := ExecKubectlStream(ctx, exec, args...)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	decodeErr := yaml.NewDecoder(stream).Decode(out)
+	// Drain the rest so the command can exit
+	_, _ = io.Copy(io.Discard, stream)
+	stderr, err := stream.Wait()
+	if err != nil {
+		return stderr, err
+	}
+	if decodeErr != nil && decodeErr != io.EOF {
+		return stderr, fmt.Errorf("failed to parse output: %w", decodeErr)
+	}
+	return stderr, nil
 }
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yu-Jack/sim-gui/pkg/executor"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KubectlStatus(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		name    string
+		result  executor.ExecResult
+		err     error
+		status  string
+		message string
+	}{
+		{
+			name:   "found",
+			result: executor.ExecResult{Stdout: "kind: Pod\n"},
+			status: KubectlFound,
+		},
+		{
+			name:   "found with a warning",
+			result: executor.ExecResult{Stdout: "kind: Pod\n", Stderr: "Warning: v1 ComponentStatus is deprecated\n"},
+			status: KubectlFound,
+		},
+		{
+			name:    "not found",
+			result:  executor.ExecResult{Stderr: "Error from server (NotFound): pods \"p1\" not found\n", ExitCode: 1},
+			status:  KubectlNotFound,
+			message: `Error from server (NotFound): pods "p1" not found`,
+		},
+		{
+			name:    "forbidden",
+			result:  executor.ExecResult{Stderr: "Error from server (Forbidden): pods \"p1\" is forbidden", ExitCode: 1},
+			status:  KubectlError,
+			message: `command failed with exit code 1: Error from server (Forbidden): pods "p1" is forbidden`,
+		},
+		{
+			name:    "unknown resource type",
+			result:  executor.ExecResult{Stderr: `error: the server doesn't have a resource type "vms"`, ExitCode: 1},
+			status:  KubectlError,
+			message: `command failed with exit code 1: error: the server doesn't have a resource type "vms"`,
+		},
+		{
+			name:    "not found with another exit code",
+			result:  executor.ExecResult{Stderr: "NotFound", ExitCode: 126},
+			status:  KubectlError,
+			message: "command failed with exit code 126: NotFound",
+		},
+		{
+			name:    "kubectl not run",
+			err:     errors.New("failed to create exec configuration: no such container"),
+			status:  KubectlError,
+			message: "failed to create exec configuration: no such container",
+		},
+	}
+
+	for _, tt := range tests {
+		status, err := KubectlStatus(tt.result, tt.err)
+		assert.Equal(tt.status, status, tt.name)
+		if tt.message == "" {
+			assert.NoError(err, tt.name)
+		} else {
+			assert.EqualError(err, tt.message, tt.name)
+		}
+	}
+}
+
+// writeZip writes a zip of the files to path, deflated in name order
+func writeZip(t *testing.T, path string, files map[string][]byte) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func Test_UnzipLimits(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+
+	small := map[string][]byte{"a.log": bytes.Repeat([]byte("a"), 600), "b.log": bytes.Repeat([]byte("b"), 600), "c.log": []byte("c")}
+	writeZip(t, filepath.Join(dir, "small.zip"), small)
+	writeZip(t, filepath.Join(dir, "bomb.zip"), map[string][]byte{"a.log": make([]byte, 4<<20)})
+
+	// An entry whose header declares fewer bytes than it holds
+	f, err := os.Create(filepath.Join(dir, "lying.zip"))
+	assert.NoError(err)
+	zw := zip.NewWriter(f)
+	content := bytes.Repeat([]byte("x"), 100)
+	w, err := zw.CreateRaw(&zip.FileHeader{Name: "a.log", Method: zip.Store, CRC32: crc32.ChecksumIEEE(content), CompressedSize64: 100, UncompressedSize64: 10})
+	assert.NoError(err)
+	_, err = w.Write(content)
+	assert.NoError(err)
+	assert.NoError(zw.Close())
+	assert.NoError(f.Close())
+
+	tests := []struct {
+		archive string
+		limits  ExtractLimits
+		reason  string
+	}{
+		{archive: "small.zip", limits: ExtractLimits{MaxBytes: 1000}, reason: "exceeds the limit of 1000 extracted bytes"},
+		{archive: "small.zip", limits: ExtractLimits{MaxFiles: 2}, reason: "exceeds the limit of 2 files"},
+		{archive: "bomb.zip", limits: ExtractLimits{MaxRatio: 100}, reason: "above the ratio limit of 100"},
+		{archive: "lying.zip", reason: "doesn't match its header"},
+	}
+	for _, tt := range tests {
+		dest := filepath.Join(dir, "extracted")
+		err := Unzip(filepath.Join(dir, tt.archive), dest, tt.limits)
+		var limitErr *ExtractLimitError
+		assert.True(errors.As(err, &limitErr), "expected %s to exceed %+v, got %v", tt.archive, tt.limits, err)
+		assert.Contains(limitErr.Reason, tt.reason)
+		_, err = os.Stat(dest)
+		assert.True(os.IsNotExist(err), "expected the partial output of %s removed", tt.archive)
+	}
+
+	// Small files aren't held to the ratio
+	dest := filepath.Join(dir, "extracted")
+	assert.NoError(Unzip(filepath.Join(dir, "small.zip"), dest, ExtractLimits{MaxBytes: 1201, MaxFiles: 3, MaxRatio: 1}))
+	assert.NoError(Unzip(filepath.Join(dir, "small.zip"), filepath.Join(dir, "default"), DefaultExtractLimits))
+	data, err := os.ReadFile(filepath.Join(dest, "b.log"))
+	assert.NoError(err)
+	assert.Equal(small["b.log"], data)
+}
+
+func Test_CheckZip(t *testing.T) {
+	assert := require.New(t)
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	writeZip(t, path, map[string][]byte{"a.log": make([]byte, 100), "b.log": make([]byte, 100)})
+
+	// Counted against what earlier archives of the extraction used
+	counter := ExtractCounter{Limits: ExtractLimits{MaxBytes: 1000, MaxFiles: 10}, Bytes: 850, Files: 2}
+	assert.NoError(CheckZip(path, ExtractCounter{Limits: counter.Limits}))
+	var limitErr *ExtractLimitError
+	assert.True(errors.As(CheckZip(path, counter), &limitErr))
+	assert.Equal("b.log", limitErr.Name)
+	assert.Equal(int64(850), counter.Bytes, "expected the counter left unchanged")
+}
+
+// tarOf returns an uncompressed tar of the files in name order, see writeZip
+func tarOf(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"__MACOSX/._a.log", "a.log", "b.log", "c.log"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func Test_UntarLimits(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	small := tarOf(t, map[string][]byte{"a.log": bytes.Repeat([]byte("a"), 600), "b.log": bytes.Repeat([]byte("b"), 600)})
+
+	// A file over the limits isn't written
+	dest := filepath.Join(dir, "upload")
+	err := Untar(bytes.NewReader(small), dest, ExtractLimits{MaxBytes: 1000})
+	var limitErr *ExtractLimitError
+	assert.True(errors.As(err, &limitErr), "expected the byte limit exceeded, got %v", err)
+	assert.Equal("b.log", limitErr.Name)
+	assert.FileExists(filepath.Join(dest, "a.log"))
+	assert.NoFileExists(filepath.Join(dest, "b.log"))
+
+	// The files of nested archives are counted together, without the metadata of macOS
+	nested := filepath.Join(dir, "node1.tar")
+	assert.NoError(os.WriteFile(nested, tarOf(t, map[string][]byte{"__MACOSX/._a.log": []byte("x"), "a.log": []byte("a")}), 0644))
+	counter := ExtractCounter{Limits: ExtractLimits{MaxFiles: 2}, Files: 1}
+	assert.NoError(UntarNested(nested, filepath.Join(dir, "nested"), &counter))
+	assert.Equal(2, counter.Files)
+	assert.NoDirExists(filepath.Join(dir, "nested", "__MACOSX"))
+	assert.True(errors.As(UntarNested(nested, filepath.Join(dir, "nested"), &counter), &limitErr))
+
+	// A gzip bomb is stopped while it is written, long before its declared size
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	_, err = gz.Write(tarOf(t, map[string][]byte{"a.log": make([]byte, 64<<20)}))
+	assert.NoError(err)
+	assert.NoError(gz.Close())
+	bombPath := filepath.Join(dir, "bomb.tar.gz")
+	assert.NoError(os.WriteFile(bombPath, bomb.Bytes(), 0644))
+	err = UntarNested(bombPath, filepath.Join(dir, "bomb"), &ExtractCounter{Limits: ExtractLimits{MaxRatio: 100}})
+	assert.True(errors.As(err, &limitErr), "expected the ratio limit exceeded, got %v", err)
+	assert.Contains(limitErr.Reason, "above the ratio limit of 100")
+	info, err := os.Stat(filepath.Join(dir, "bomb", "a.log"))
+	assert.NoError(err)
+	assert.Less(info.Size(), int64(8<<20))
+
+	if _, err := exec.LookPath("xz"); err != nil {
+		t.Skip("xz isn't installed")
+	}
+	xzPath := filepath.Join(dir, "node2.tar.xz")
+	cmd := exec.Command("xz", "-c")
+	cmd.Stdin = bytes.NewReader(small)
+	compressed, err := cmd.Output()
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(xzPath, compressed, 0644))
+	assert.NoError(UntarNested(xzPath, filepath.Join(dir, "xz"), &ExtractCounter{Limits: DefaultExtractLimits}))
+	data, err := os.ReadFile(filepath.Join(dir, "xz", "b.log"))
+	assert.NoError(err)
+	assert.Equal(bytes.Repeat([]byte("b"), 600), data)
+}
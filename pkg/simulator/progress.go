@@ -0,0 +1,144 @@
+package simulator
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadyMessage is logged by support-bundle-kit once every object of the bundle is loaded
+const ReadyMessage = "All resources loaded successfully"
+
+// Progress describes how far a simulator got loading its support bundle
+type Progress struct {
+	TypesLoaded   int `json:"typesLoaded"`
+	TypesTotal    int `json:"typesTotal,omitempty"` // 0 when the simulator didn't log how many types there are
+	ObjectsLoaded int `json:"objectsLoaded"`
+	// Percent is only set when the total is known, it stays below 100 until the simulator is ready
+	Percent     *int      `json:"percent,omitempty"`
+	LastMessage string    `json:"lastMessage,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	Ready       bool      `json:"ready"`
+}
+
+var (
+	// logrus text format: time="..." level=info msg="..."
+	textMessageRegexp = regexp.MustCompile(`msg="((?:[^"\\]|\\.)*)"`)
+
+	// "found 42 resource types to load", "loading 42 object files"
+	totalRegexp = regexp.MustCompile(`(?i)(\d+)\s+(?:resource|object)\s+(?:types|kinds|files)`)
+	// "loaded 12 objects of type pods", "created 3 nodes", "applied 5 items for kind settings.harvesterhci.io"
+	countFirstRegexp = regexp.MustCompile(`(?i)\b(?:loaded|created|applied)\s+(\d+)\s+(?:(?:objects?|resources?|items?)\s+)?(?:(?:of|for)\s+)?(?:(?:type|kind)\s+)?([A-Za-z][\w./-]*)`)
+	// "loaded pods: 12", "applied settings.harvesterhci.io (3 objects)"
+	typeFirstRegexp = regexp.MustCompile(`(?i)\b(?:loaded|created|applied)\s+(?:(?:type|kind)\s+)?([A-Za-z][\w./-]*)(?::|\s+\()\s*(\d+)`)
+)
+
+// parseLine returns the log message of a line, the whole line is used when it isn't in a known logrus format
+func parseLine(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var entry struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err == nil && entry.Msg != "" {
+			return entry.Msg
+		}
+	}
+	if m := textMessageRegexp.FindStringSubmatch(line); m != nil {
+		if msg, err := strconv.Unquote(`"` + m[1] + `"`); err == nil {
+			return msg
+		}
+		return m[1]
+	}
+	return line
+}
+
+// Tracker follows the logs of a single simulator run. It is safe for concurrent use.
+type Tracker struct {
+	lock     sync.Mutex
+	progress Progress
+	types    map[string]struct{}
+}
+
+func NewTracker(startedAt time.Time) *Tracker {
+	t := &Tracker{}
+	t.Reset(startedAt)
+	return t
+}
+
+// Reset forgets everything parsed so far, used when the container starts again
+func (t *Tracker) Reset(startedAt time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.progress = Progress{StartedAt: startedAt}
+	t.types = make(map[string]struct{})
+}
+
+// Feed parses a log line and reports whether the progress changed. Lines that aren't
+// recognized are ignored so changes of the log format only make the progress less detailed.
+func (t *Tracker) Feed(line string) bool {
+	msg := parseLine(line)
+	if msg == "" {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch {
+	case strings.Contains(msg, ReadyMessage):
+		t.progress.Ready = true
+	case totalRegexp.MatchString(msg):
+		total, _ := strconv.Atoi(totalRegexp.FindStringSubmatch(msg)[1])
+		t.progress.TypesTotal = total
+	case countFirstRegexp.MatchString(msg):
+		m := countFirstRegexp.FindStringSubmatch(msg)
+		count, _ := strconv.Atoi(m[1])
+		t.addType(m[2], count)
+	case typeFirstRegexp.MatchString(msg):
+		m := typeFirstRegexp.FindStringSubmatch(msg)
+		count, _ := strconv.Atoi(m[2])
+		t.addType(m[1], count)
+	default:
+		return false
+	}
+
+	t.progress.LastMessage = msg
+	t.updatePercent()
+	return true
+}
+
+func (t *Tracker) addType(resourceType string, count int) {
+	resourceType = strings.TrimRight(resourceType, ".:,")
+	if _, ok := t.types[resourceType]; !ok {
+		t.types[resourceType] = struct{}{}
+		t.progress.TypesLoaded++
+	}
+	t.progress.ObjectsLoaded += count
+}
+
+func (t *Tracker) updatePercent() {
+	switch {
+	case t.progress.Ready:
+		percent := 100
+		t.progress.Percent = &percent
+	case t.progress.TypesTotal > 0:
+		percent := min(t.progress.TypesLoaded*100/t.progress.TypesTotal, 99)
+		t.progress.Percent = &percent
+	}
+}
+
+// Progress returns a copy of the current progress
+func (t *Tracker) Progress() Progress {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	progress := t.progress
+	if progress.Percent != nil {
+		percent := *progress.Percent
+		progress.Percent = &percent
+	}
+	return progress
+}
@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"bufio"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func feedFile(t *testing.T, tracker *Tracker, path string) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tracker.Feed(scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+}
+
+func Test_TrackerTextFormat(t *testing.T) {
+	assert := require.New(t)
+	startedAt := time.Date(2024, 3, 4, 8, 12, 0, 0, time.UTC)
+	tracker := NewTracker(startedAt)
+	feedFile(t, tracker, "testdata/text-format.log")
+
+	progress := tracker.Progress()
+	assert.Equal(4, progress.TypesTotal)
+	// pods is logged twice but counted once
+	assert.Equal(4, progress.TypesLoaded)
+	assert.Equal(67, progress.ObjectsLoaded)
+	assert.True(progress.Ready)
+	assert.Equal(100, *progress.Percent)
+	assert.Equal(ReadyMessage, progress.LastMessage)
+	assert.Equal(startedAt, progress.StartedAt)
+}
+
+func Test_TrackerJSONFormat(t *testing.T) {
+	assert := require.New(t)
+	tracker := NewTracker(time.Now())
+	feedFile(t, tracker, "testdata/json-format.log")
+
+	progress := tracker.Progress()
+	assert.Equal(3, progress.TypesTotal)
+	assert.Equal(2, progress.TypesLoaded)
+	assert.Equal(7, progress.ObjectsLoaded)
+	assert.False(progress.Ready)
+	assert.Equal(66, *progress.Percent)
+	assert.Equal("applied virtualmachines.kubevirt.io (2 objects)", progress.LastMessage)
+}
+
+func Test_TrackerIgnoresUnknownLinesAndResets(t *testing.T) {
+	assert := require.New(t)
+	tracker := NewTracker(time.Now())
+
+	assert.False(tracker.Feed(`time="2024-03-04T08:12:01Z" level=info msg="Creating embedded etcd server"`))
+	assert.False(tracker.Feed("\x01\x00\x00\x00garbage"))
+	assert.Nil(tracker.Progress().Percent)
+
+	// without a total there is no percentage, but loaded types are still counted
+	assert.True(tracker.Feed("loaded 2 objects of type nodes"))
+	assert.Equal(1, tracker.Progress().TypesLoaded)
+	assert.Nil(tracker.Progress().Percent)
+
+	restartedAt := time.Now()
+	tracker.Reset(restartedAt)
+	assert.Equal(Progress{StartedAt: restartedAt}, tracker.Progress())
+}
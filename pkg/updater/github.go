@@ -0,0 +1,128 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitError is returned when GitHub rejects a request because the API rate limit was exceeded
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub API rate limit exceeded until %s", e.Reset.Format(time.RFC3339))
+}
+
+type GitHubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+type GitHubRelease struct {
+	TagName string `json:"tag_name"`
+	Name    string `json:"name"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+type GitHubComparison struct {
+	Status  string         `json:"status"`
+	Commits []GitHubCommit `json:"commits"`
+}
+
+// getLatestCommit fetches the latest commit from GitHub API
+func (u *Updater) getLatestCommit() (string, error) {
+	var commit GitHubCommit
+	if err := u.getJSON(fmt.Sprintf("/repos/%s/%s/commits/%s", u.owner, u.repo, u.branch), &commit); err != nil {
+		return "", fmt.Errorf("failed to fetch commit: %w", err)
+	}
+	return commit.SHA, nil
+}
+
+// getLatestRelease fetches the latest published release from GitHub API
+func (u *Updater) getLatestRelease() (*GitHubRelease, error) {
+	var release GitHubRelease
+	if err := u.getJSON(fmt.Sprintf("/repos/%s/%s/releases/latest", u.owner, u.repo), &release); err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	return &release, nil
+}
+
+// getComparison fetches the commits between two refs (tags or commit SHAs) from GitHub API
+func (u *Updater) getComparison(base, head string) (*GitHubComparison, error) {
+	var comparison GitHubComparison
+	if err := u.getJSON(fmt.Sprintf("/repos/%s/%s/compare/%s...%s", u.owner, u.repo, base, head), &comparison); err != nil {
+		return nil, fmt.Errorf("failed to compare %s...%s: %w", base, head, err)
+	}
+	return &comparison, nil
+}
+
+// getJSON performs a GET against the GitHub API and decodes the JSON response into out
+func (u *Updater) getJSON(path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(u.ctx, "GET", u.apiURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set User-Agent to avoid GitHub API restrictions
+	req.Header.Set("User-Agent", "sim-gui-updater")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if rateLimitErr := parseRateLimit(resp); rateLimitErr != nil {
+			return rateLimitErr
+		}
+		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// parseRateLimit detects GitHub's primary (X-RateLimit-*) and secondary (Retry-After) rate limit
+// responses and returns when requests may be retried, or nil if resp is not a rate limit response
+func parseRateLimit(resp *http.Response) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return &RateLimitError{Reset: time.Unix(reset, 0)}
+		}
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return &RateLimitError{Reset: time.Now().Add(time.Duration(seconds) * time.Second)}
+	}
+
+	// Some rate limited responses carry no hint about when to retry
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{Reset: time.Now().Add(time.Minute)}
+	}
+	return nil
+}
+
+// newTransport returns an HTTP transport honoring the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return transport
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var releaseVersionRegexp = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// isReleaseVersion reports whether version looks like a semantic version tag (e.g. v1.2.3 or v1.2.3-rc1)
+func isReleaseVersion(version string) bool {
+	return releaseVersionRegexp.MatchString(version)
+}
+
+// compareVersions compares two semantic versions and returns 1 if a > b, -1 if a < b and 0 if they are equal.
+// A pre-release is considered older than the release it precedes.
+func compareVersions(a, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			if aNum > bNum {
+				return 1
+			}
+			return -1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre > bPre:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -2,7 +2,7 @@ package updater
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,53 +10,95 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Yu-Jack/sim-gui/pkg/version"
+)
+
+const (
+	defaultAPIURL = "https://api.github.com"
+	// minBackoff and maxBackoff bound the wait between periodic checks after consecutive failures
+	minBackoff = 5 * time.Minute
+	maxBackoff = 24 * time.Hour
 )
 
 type UpdateStatus struct {
 	UpdateAvailable bool      `json:"updateAvailable"`
 	CurrentCommit   string    `json:"currentCommit"`
 	LatestCommit    string    `json:"latestCommit"`
+	CurrentVersion  string    `json:"currentVersion"`
+	LatestVersion   string    `json:"latestVersion"`
+	ReleaseNotes    string    `json:"releaseNotes,omitempty"`
+	ReleaseURL      string    `json:"releaseURL,omitempty"`
+	Changes         []Change  `json:"changes,omitempty"`
 	LastChecked     time.Time `json:"lastChecked"`
 	Message         string    `json:"message"`
+	// RateLimitedUntil is set while GitHub is refusing requests, the other fields hold the last known-good result
+	RateLimitedUntil *time.Time `json:"rateLimitedUntil,omitempty"`
 }
 
-type Updater struct {
-	owner      string
-	repo       string
-	branch     string
-	interval   time.Duration
-	status     UpdateStatus
-	statusLock sync.RWMutex
-	ctx        context.Context
-	cancel     context.CancelFunc
+// Change is a single commit between the running build and the latest one
+type Change struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
 }
 
-type GitHubCommit struct {
-	SHA    string `json:"sha"`
-	Commit struct {
-		Message string `json:"message"`
-	} `json:"commit"`
+type Updater struct {
+	owner          string
+	repo           string
+	branch         string
+	interval       time.Duration
+	apiURL         string
+	client         *http.Client
+	token          string
+	currentVersion string
+	currentCommit  func() (string, error)
+	status         UpdateStatus
+	statusLock     sync.RWMutex
+	checkLock      sync.Mutex
+	// the fields below are guarded by checkLock
+	lastGood         UpdateStatus
+	failures         int
+	backoffUntil     time.Time
+	rateLimitedUntil time.Time
+	ctx              context.Context
+	cancel           context.CancelFunc
 }
 
+// NewUpdater creates an Updater for the given repository. Release builds (see pkg/version) are compared
+// against GitHub releases, anything else (e.g. "dev") is treated as a development build and compared by
+// commit against branch.
 func NewUpdater(owner, repo, branch string, interval time.Duration) *Updater {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Updater{
-		owner:    owner,
-		repo:     repo,
-		branch:   branch,
-		interval: interval,
-		ctx:      ctx,
-		cancel:   cancel,
+		owner:          owner,
+		repo:           repo,
+		branch:         branch,
+		interval:       interval,
+		apiURL:         defaultAPIURL,
+		currentVersion: version.Version,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: newTransport(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
 		status: UpdateStatus{
 			UpdateAvailable: false,
 		},
+		currentCommit: getCurrentCommit,
 	}
 }
 
+// SetToken sets the GitHub API token sent with every request, raising the API rate limit.
+// It must be called before Start.
+func (u *Updater) SetToken(token string) {
+	u.token = token
+}
+
 // Start begins checking for updates at the specified interval
 func (u *Updater) Start() {
 	// Do an initial check
-	u.checkForUpdates()
+	u.checkForUpdates(false)
 
 	// If interval is 0, don't schedule periodic checks
 	if u.interval == 0 {
@@ -71,7 +113,7 @@ func (u *Updater) Start() {
 		for {
 			select {
 			case <-ticker.C:
-				u.checkForUpdates()
+				u.checkForUpdates(false)
 			case <-u.ctx.Done():
 				return
 			}
@@ -91,94 +133,202 @@ func (u *Updater) GetStatus() UpdateStatus {
 	return u.status
 }
 
-// checkForUpdates checks for new commits on GitHub
-func (u *Updater) checkForUpdates() {
-	currentCommit, err := u.getCurrentCommit()
+// CheckNow runs an update check immediately and returns the resulting status.
+// It is safe to call while the periodic checker is running.
+func (u *Updater) CheckNow() UpdateStatus {
+	return u.checkForUpdates(true)
+}
+
+// checkForUpdates compares the running build against GitHub. Release builds are compared
+// against the latest release tag, dev builds fall back to comparing commits on the branch.
+// Periodic checks are skipped while backing off after failures, manual checks only wait
+// for a GitHub rate limit to reset.
+func (u *Updater) checkForUpdates(manual bool) UpdateStatus {
+	// Serialize checks so a manual check and the ticker don't race each other
+	u.checkLock.Lock()
+	defer u.checkLock.Unlock()
+
+	now := time.Now()
+	if now.Before(u.rateLimitedUntil) || (!manual && now.Before(u.backoffUntil)) {
+		return u.GetStatus()
+	}
+
+	var status UpdateStatus
+	var err error
+	if isReleaseVersion(u.currentVersion) {
+		status, err = u.checkLatestRelease()
+	} else {
+		status, err = u.checkLatestCommit()
+	}
+
+	if err == nil {
+		if u.failures > 0 {
+			log.Printf("Update check recovered after %d failed attempts", u.failures)
+		}
+		u.failures = 0
+		u.backoffUntil = time.Time{}
+		u.lastGood = status
+		return u.updateStatus(status)
+	}
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		// Being rate limited says nothing about available updates, keep the last known-good result
+		reset := rateLimitErr.Reset
+		u.rateLimitedUntil = reset
+		log.Printf("GitHub API rate limit reached, skipping update checks until %s", reset.Format(time.RFC3339))
+		status = u.lastGood
+		status.CurrentVersion = u.currentVersion
+		status.Message = fmt.Sprintf("GitHub API rate limited until %s", reset.Format(time.RFC3339))
+		status.RateLimitedUntil = &reset
+		return u.updateStatus(status)
+	}
+
+	u.failures++
+	backoff := u.nextBackoff()
+	u.backoffUntil = now.Add(backoff)
+	if u.failures == 1 {
+		log.Printf("Update check failed: %v", err)
+	} else {
+		log.Printf("Update check failed %d times in a row, backing off for %s: %v", u.failures, backoff, err)
+	}
+	return u.updateStatus(status)
+}
+
+// nextBackoff doubles the wait after each consecutive failure, starting from the check interval
+func (u *Updater) nextBackoff() time.Duration {
+	backoff := u.interval
+	if backoff <= 0 || backoff > maxBackoff {
+		backoff = minBackoff
+	}
+	for i := 1; i < u.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// checkLatestRelease compares the embedded version against the latest GitHub release
+func (u *Updater) checkLatestRelease() (UpdateStatus, error) {
+	release, err := u.getLatestRelease()
 	if err != nil {
-		log.Printf("Failed to get current commit: %v", err)
-		u.updateStatus(UpdateStatus{
+		return UpdateStatus{
 			UpdateAvailable: false,
+			CurrentVersion:  u.currentVersion,
+			Message:         fmt.Sprintf("Failed to check for updates: %v", err),
+			LastChecked:     time.Now(),
+		}, err
+	}
+
+	status := UpdateStatus{
+		UpdateAvailable: compareVersions(release.TagName, u.currentVersion) > 0,
+		CurrentVersion:  u.currentVersion,
+		LatestVersion:   release.TagName,
+		Message:         "You are running the latest version",
+		LastChecked:     time.Now(),
+	}
+
+	if status.UpdateAvailable {
+		status.Message = fmt.Sprintf("A new release %s is available!", release.TagName)
+		status.ReleaseNotes = release.Body
+		status.ReleaseURL = release.HTMLURL
+		status.Changes = u.getChanges(u.currentVersion, release.TagName)
+		log.Printf("Update available: current=%s, latest=%s", u.currentVersion, release.TagName)
+	}
+
+	return status, nil
+}
+
+// checkLatestCommit compares the local git checkout against the head of the tracked branch
+func (u *Updater) checkLatestCommit() (UpdateStatus, error) {
+	currentCommit, err := u.currentCommit()
+	if err != nil {
+		return UpdateStatus{
+			UpdateAvailable: false,
+			CurrentVersion:  u.currentVersion,
 			Message:         fmt.Sprintf("Failed to get current commit: %v", err),
 			LastChecked:     time.Now(),
-		})
-		return
+		}, err
 	}
 
 	latestCommit, err := u.getLatestCommit()
 	if err != nil {
-		log.Printf("Failed to get latest commit from GitHub: %v", err)
-		u.updateStatus(UpdateStatus{
+		return UpdateStatus{
 			UpdateAvailable: false,
 			CurrentCommit:   currentCommit,
+			CurrentVersion:  u.currentVersion,
 			Message:         fmt.Sprintf("Failed to check for updates: %v", err),
 			LastChecked:     time.Now(),
-		})
-		return
+		}, err
 	}
 
-	updateAvailable := currentCommit != latestCommit
-	message := "You are running the latest version"
-	if updateAvailable {
-		message = "A new update is available! Run 'git pull' to update."
-		log.Printf("Update available: current=%s, latest=%s", currentCommit[:7], latestCommit[:7])
-	}
-
-	u.updateStatus(UpdateStatus{
-		UpdateAvailable: updateAvailable,
+	status := UpdateStatus{
+		UpdateAvailable: currentCommit != latestCommit,
 		CurrentCommit:   currentCommit,
 		LatestCommit:    latestCommit,
-		Message:         message,
+		CurrentVersion:  u.currentVersion,
+		Message:         "You are running the latest version",
 		LastChecked:     time.Now(),
-	})
-}
-
-// getCurrentCommit gets the current git commit hash
-func (u *Updater) getCurrentCommit() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current commit: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
-}
-
-// getLatestCommit fetches the latest commit from GitHub API
-func (u *Updater) getLatestCommit() (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", u.owner, u.repo, u.branch)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	req, err := http.NewRequestWithContext(u.ctx, "GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	if status.UpdateAvailable {
+		status.Message = "A new update is available! Run 'git pull' to update."
+		status.Changes = u.getChanges(currentCommit, latestCommit)
+		log.Printf("Update available: current=%s, latest=%s", shortSHA(currentCommit), shortSHA(latestCommit))
 	}
 
-	// Set User-Agent to avoid GitHub API restrictions
-	req.Header.Set("User-Agent", "sim-gui-updater")
+	return status, nil
+}
 
-	resp, err := client.Do(req)
+// getChanges lists the commits between base and head, a failure only means no changelog is shown
+func (u *Updater) getChanges(base, head string) []Change {
+	comparison, err := u.getComparison(base, head)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch commit: %w", err)
+		log.Printf("Failed to get changes between %s and %s: %v", base, head, err)
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	changes := make([]Change, 0, len(comparison.Commits))
+	for _, c := range comparison.Commits {
+		// Only keep the subject line of the commit message
+		message, _, _ := strings.Cut(c.Commit.Message, "\n")
+		changes = append(changes, Change{
+			SHA:     c.SHA,
+			Message: message,
+		})
 	}
+	return changes
+}
 
-	var commit GitHubCommit
-	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+// getCurrentCommit returns the commit the binary was built from, falling back to
+// the current git commit hash for builds without embedded build information
+func getCurrentCommit() (string, error) {
+	if version.GitCommit != "" {
+		return version.GitCommit, nil
 	}
 
-	return commit.SHA, nil
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current commit: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
 }
 
-// updateStatus updates the internal status
-func (u *Updater) updateStatus(status UpdateStatus) {
+// updateStatus updates the internal status and returns it
+func (u *Updater) updateStatus(status UpdateStatus) UpdateStatus {
 	u.statusLock.Lock()
 	defer u.statusLock.Unlock()
 	u.status = status
+	return status
+}
+
+// shortSHA truncates a commit hash for logging
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
 }
@@ -0,0 +1,237 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	currentSHA = "1111111111111111111111111111111111111111"
+	latestSHA  = "2222222222222222222222222222222222222222"
+)
+
+// githubStub is a fake GitHub API serving a fixed head commit on main and a fixed latest release
+type githubStub struct {
+	latestCommit  string
+	latestRelease string
+	hits          int32
+}
+
+func (g *githubStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&g.hits, 1)
+	switch r.URL.Path {
+	case "/repos/Yu-Jack/sim-gui/commits/main":
+		fmt.Fprintf(w, `{"sha": %q, "commit": {"message": "test"}}`, g.latestCommit)
+	case "/repos/Yu-Jack/sim-gui/releases/latest":
+		fmt.Fprintf(w, `{"tag_name": %q, "body": "## Fixes\n- fixed things", "html_url": "https://github.com/Yu-Jack/sim-gui/releases/%s"}`, g.latestRelease, g.latestRelease)
+	case "/repos/Yu-Jack/sim-gui/compare/v1.0.0...v1.1.0", "/repos/Yu-Jack/sim-gui/compare/" + currentSHA + "..." + latestSHA:
+		fmt.Fprint(w, `{"status": "ahead", "commits": [
+			{"sha": "aaaa", "commit": {"message": "Add feature\n\nLonger description"}},
+			{"sha": "bbbb", "commit": {"message": "Fix bug"}}
+		]}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestUpdater(t *testing.T, currentVersion string, stub *githubStub) *Updater {
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	u := NewUpdater("Yu-Jack", "sim-gui", "main", 0)
+	u.currentVersion = currentVersion
+	u.apiURL = srv.URL
+	u.currentCommit = func() (string, error) {
+		return currentSHA, nil
+	}
+	t.Cleanup(u.Stop)
+	return u
+}
+
+func Test_CheckNowCommitMode(t *testing.T) {
+	assert := require.New(t)
+	u := newTestUpdater(t, "dev", &githubStub{latestCommit: latestSHA})
+
+	status := u.CheckNow()
+	assert.True(status.UpdateAvailable)
+	assert.Equal(currentSHA, status.CurrentCommit)
+	assert.Equal(latestSHA, status.LatestCommit)
+	assert.Equal("dev", status.CurrentVersion)
+	assert.Equal([]Change{{SHA: "aaaa", Message: "Add feature"}, {SHA: "bbbb", Message: "Fix bug"}}, status.Changes)
+	assert.False(status.LastChecked.IsZero())
+	assert.Equal(status, u.GetStatus(), "expected CheckNow to update the cached status")
+}
+
+func Test_CheckNowCommitModeUpToDate(t *testing.T) {
+	assert := require.New(t)
+	u := newTestUpdater(t, "dev", &githubStub{latestCommit: currentSHA})
+
+	status := u.CheckNow()
+	assert.False(status.UpdateAvailable)
+	assert.Empty(status.Changes)
+	assert.Equal("You are running the latest version", status.Message)
+}
+
+func Test_CheckNowReleaseMode(t *testing.T) {
+	assert := require.New(t)
+	u := newTestUpdater(t, "v1.0.0", &githubStub{latestRelease: "v1.1.0"})
+	u.currentCommit = func() (string, error) {
+		return "", fmt.Errorf("not a git checkout")
+	}
+
+	status := u.CheckNow()
+	assert.True(status.UpdateAvailable)
+	assert.Equal("v1.0.0", status.CurrentVersion)
+	assert.Equal("v1.1.0", status.LatestVersion)
+	assert.Equal("## Fixes\n- fixed things", status.ReleaseNotes)
+	assert.Equal("https://github.com/Yu-Jack/sim-gui/releases/v1.1.0", status.ReleaseURL)
+	assert.Len(status.Changes, 2)
+	assert.Empty(status.CurrentCommit, "expected release mode to not depend on git")
+}
+
+func Test_CheckNowReleaseModeUpToDate(t *testing.T) {
+	assert := require.New(t)
+	u := newTestUpdater(t, "v1.1.0", &githubStub{latestRelease: "v1.1.0"})
+
+	status := u.CheckNow()
+	assert.False(status.UpdateAvailable)
+	assert.Equal("v1.1.0", status.LatestVersion)
+	assert.Empty(status.ReleaseNotes)
+}
+
+func Test_CheckNowAPIError(t *testing.T) {
+	assert := require.New(t)
+	u := newTestUpdater(t, "dev", &githubStub{latestCommit: latestSHA})
+	u.branch = "missing"
+
+	status := u.CheckNow()
+	assert.False(status.UpdateAvailable)
+	assert.Equal(currentSHA, status.CurrentCommit)
+	assert.Contains(status.Message, "404")
+}
+
+func Test_CheckNowConcurrent(t *testing.T) {
+	assert := require.New(t)
+	stub := &githubStub{latestRelease: "v1.0.0"}
+	u := newTestUpdater(t, "v1.0.0", stub)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.CheckNow()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(10), atomic.LoadInt32(&stub.hits))
+}
+
+func Test_CompareVersions(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal(1, compareVersions("v1.1.0", "v1.0.0"))
+	assert.Equal(1, compareVersions("v1.10.0", "v1.9.3"))
+	assert.Equal(-1, compareVersions("1.0.0", "v2.0.0"))
+	assert.Equal(0, compareVersions("v1.2.3", "1.2.3"))
+	assert.Equal(1, compareVersions("v1.2.3", "v1.2.3-rc1"))
+	assert.Equal(-1, compareVersions("v1.2.3-rc1", "v1.2.3-rc2"))
+	assert.True(isReleaseVersion("v1.2.3"))
+	assert.True(isReleaseVersion("v1.2.3-rc1"))
+	assert.False(isReleaseVersion("dev"))
+	assert.False(isReleaseVersion(""))
+}
+
+func Test_TokenHeader(t *testing.T) {
+	assert := require.New(t)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"tag_name": "v1.0.0"}`)
+	}))
+	defer srv.Close()
+
+	u := NewUpdater("Yu-Jack", "sim-gui", "main", 0)
+	u.currentVersion = "v1.0.0"
+	defer u.Stop()
+	u.apiURL = srv.URL
+	u.SetToken("secret")
+
+	u.CheckNow()
+	assert.Equal("Bearer secret", auth)
+}
+
+func Test_RateLimitKeepsLastKnownGood(t *testing.T) {
+	assert := require.New(t)
+	var hits int32
+	var limited atomic.Bool
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if limited.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"tag_name": "v1.1.0"}`)
+	}))
+	defer srv.Close()
+
+	u := NewUpdater("Yu-Jack", "sim-gui", "main", 0)
+	u.currentVersion = "v1.1.0"
+	defer u.Stop()
+	u.apiURL = srv.URL
+
+	good := u.CheckNow()
+	assert.Equal("v1.1.0", good.LatestVersion)
+
+	limited.Store(true)
+	status := u.CheckNow()
+	assert.Equal("v1.1.0", status.LatestVersion, "expected the last known-good result to be kept")
+	assert.Contains(status.Message, "rate limited until")
+	assert.NotNil(status.RateLimitedUntil)
+	assert.True(reset.Equal(*status.RateLimitedUntil))
+
+	// no further requests are made until the limit resets
+	u.CheckNow()
+	assert.Equal(int32(2), atomic.LoadInt32(&hits))
+}
+
+func Test_BackoffAfterFailures(t *testing.T) {
+	assert := require.New(t)
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	u := NewUpdater("Yu-Jack", "sim-gui", "main", time.Hour)
+	u.currentVersion = "v1.0.0"
+	defer u.Stop()
+	u.apiURL = srv.URL
+
+	status := u.checkForUpdates(false)
+	assert.Contains(status.Message, "500")
+	assert.Equal(1, u.failures)
+
+	// periodic checks are skipped while backing off
+	u.checkForUpdates(false)
+	assert.Equal(int32(1), atomic.LoadInt32(&hits))
+
+	// manual checks still go through and extend the backoff
+	u.CheckNow()
+	assert.Equal(int32(2), atomic.LoadInt32(&hits))
+	assert.Equal(2*time.Hour, u.nextBackoff())
+
+	u.failures = 10
+	assert.Equal(maxBackoff, u.nextBackoff())
+}
@@ -0,0 +1,24 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Get(t *testing.T) {
+	assert := require.New(t)
+	Version = "v1.2.3"
+	GitCommit = "abcdef"
+	defer func() {
+		Version = "dev"
+		GitCommit = ""
+	}()
+
+	info := Get()
+	assert.Equal("v1.2.3", info.Version)
+	assert.Equal("abcdef", info.GitCommit)
+	assert.Equal(runtime.Version(), info.GoVersion)
+	assert.Equal(runtime.GOOS+"/"+runtime.GOARCH, info.Platform)
+}
@@ -2,10 +2,11 @@ import { BrowserRouter, Routes, Route } from 'react-router-dom';
 import { Layout } from './components/Layout';
 import { WorkspaceList } from './pages/WorkspaceList';
 import { WorkspaceDetail } from './pages/WorkspaceDetail';
+import { basePath } from './api/client';
 
 function App() {
   return (
-    <BrowserRouter>
+    <BrowserRouter basename={basePath || '/'}>
       <Routes>
         <Route path="/" element={<Layout />}>
           <Route index element={<WorkspaceList />} />
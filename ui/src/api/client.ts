@@ -1,12 +1,108 @@
 import axios from 'axios';
-import type { Workspace, UpdateStatus } from '../types';
+import type { Workspace, UpdateStatus, Bookmark, SavedQuery, SimulatorStatus, VersionDetail, ActivityEntry, AutoImportResult, Version, TrashEntry, TrashRestoreResult, CleanReport, CleanupResult, Job, DeleteResult, PortMapping, StartParameters, SearchResult, ResourceList, ProfileSummary, PullProgress } from '../types';
+
+declare global {
+  interface Window {
+    __SIM_GUI_BASE_PATH__?: string;
+  }
+}
+
+// Path prefix the UI and API are served under behind a reverse proxy, empty at the root. The server puts
+// it in index.html, the dev server knows it from its --base.
+export const basePath = (
+  window.__SIM_GUI_BASE_PATH__ ?? (import.meta.env.BASE_URL.startsWith('/') ? import.meta.env.BASE_URL : '')
+).replace(/\/$/, '');
 
 const client = axios.create({
-  baseURL: 'http://localhost:8080/api',
+  baseURL: `${basePath}/api`,
+});
+
+// Token sent to servers with a tokens file, asked for when a request is refused with 401
+const tokenKey = 'sim-gui-token';
+let askingForToken = false;
+
+// Profile selected on servers with several data directories, empty uses the default one
+const profileKey = 'sim-gui-profile';
+
+export const getProfile = () => localStorage.getItem(profileKey) ?? '';
+
+// Switches to another profile, the pages are loaded again from its data directory
+export const setProfile = (profile: string) => {
+  if (profile) {
+    localStorage.setItem(profileKey, profile);
+  } else {
+    localStorage.removeItem(profileKey);
+  }
+  window.location.assign(`${basePath}/`);
+};
+
+client.interceptors.request.use((config) => {
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  const profile = getProfile();
+  if (profile) {
+    config.headers['X-Profile'] = profile;
+  }
+  return config;
+});
+
+client.interceptors.response.use(undefined, (error) => {
+  if (error.response?.status === 401 && !askingForToken) {
+    askingForToken = true;
+    const token = window.prompt('This server requires a token');
+    if (token?.trim()) {
+      localStorage.setItem(tokenKey, token.trim());
+      window.location.reload();
+    }
+    askingForToken = false;
+  }
+  return Promise.reject(error);
 });
 
-export const getWorkspaces = async () => {
-  const response = await client.get<Workspace[]>('/workspaces');
+// Adds the token and the profile to URLs the browser opens itself, downloads and event streams can't
+// send headers
+const withToken = (url: string) => {
+  const params = new URLSearchParams();
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    params.set('access_token', token);
+  }
+  const profile = getProfile();
+  if (profile) {
+    params.set('profile', profile);
+  }
+  const query = params.toString();
+  return query ? `${url}${url.includes('?') ? '&' : '?'}${query}` : url;
+};
+
+// mine keeps the workspaces created by the user of the token or user header
+export const getWorkspaces = async (createdBy?: string, mine = false) => {
+  const params: Record<string, string> = {};
+  if (createdBy !== undefined) {
+    params.createdBy = createdBy;
+  }
+  if (mine) {
+    params.mine = 'true';
+  }
+  const response = await client.get<Workspace[]>('/workspaces', { params });
+  return response.data;
+};
+
+export interface SearchQuery {
+  q?: string;
+  harvesterVersion?: string; // v1.3 matches its patch releases, e.g. v1.3.2
+  clusterName?: string;
+  collectedAfter?: string; // RFC 3339
+  collectedBefore?: string;
+  limit?: number;
+}
+
+// Searches the versions of every workspace by the metadata of their bundle, total counts the versions
+// past limit as well
+export const searchVersions = async (query: SearchQuery) => {
+  const response = await client.get<SearchResult>('/search', { params: query });
   return response.data;
 };
 
@@ -19,8 +115,28 @@ export const renameWorkspace = async (oldName: string, newName: string) => {
   await client.put(`/workspaces/${oldName}`, { name: newName });
 };
 
-export const deleteWorkspace = async (name: string) => {
-  await client.delete(`/workspaces/${name}`);
+// Deleted workspaces are kept in the trash unless permanent is set
+// Starts deleting a workspace in the background, follow the returned job with getJob
+export const deleteWorkspace = async (name: string, permanent = false) => {
+  const response = await client.delete<Job<DeleteResult>>(`/workspaces/${name}`, { params: permanent ? { permanent: true } : undefined });
+  return response.data;
+};
+
+// Lists the running and recently finished jobs, optionally of one workspace or kind
+export const listJobs = async (filter: { workspace?: string; kind?: string } = {}) => {
+  const response = await client.get<Job[]>('/jobs', { params: filter });
+  return response.data;
+};
+
+export const getJob = async (id: string) => {
+  const response = await client.get<Job>(`/jobs/${id}`);
+  return response.data;
+};
+
+// Stops a job at the next point it can, e.g. a delete before the versions it didn't start on
+export const cancelJob = async (id: string) => {
+  const response = await client.post<Job>(`/jobs/${id}/cancel`);
+  return response.data;
 };
 
 export const getWorkspace = async (name: string) => {
@@ -28,9 +144,49 @@ export const getWorkspace = async (name: string) => {
   return response.data;
 };
 
-export const uploadVersion = async (workspaceName: string, files: File | File[]) => {
+export const getActivity = async (workspaceName: string, params?: { since?: string; limit?: number; offset?: number }) => {
+  const response = await client.get<ActivityEntry[]>(`/workspaces/${workspaceName}/activity`, { params });
+  return response.data;
+};
+
+export const setDefaultNamespace = async (workspaceName: string, namespace: string) => {
+  await client.put(`/workspaces/${workspaceName}/default-namespace`, { namespace });
+};
+
+export const updateWorkspacePreferences = async (
+  workspaceName: string,
+  preferences: { timeZone?: string; outputFormat?: string; defaultNamespace?: string },
+) => {
+  const response = await client.patch<Workspace>(`/workspaces/${workspaceName}`, preferences);
+  return response.data;
+};
+
+// 0 uses the server default, a negative value keeps versions forever
+export const setRetention = async (workspaceName: string, retentionDays: number) => {
+  await client.put(`/workspaces/${workspaceName}/retention`, { retentionDays });
+};
+
+export const pinVersion = async (workspaceName: string, versionID: string, pinned: boolean) => {
+  await client.put(`/workspaces/${workspaceName}/versions/${versionID}/pin`, { pinned });
+};
+
+export const addBookmark = async (workspaceName: string, bookmark: Bookmark) => {
+  const response = await client.post<Bookmark>(`/workspaces/${workspaceName}/bookmarks`, bookmark);
+  return response.data;
+};
+
+export const deleteBookmark = async (workspaceName: string, bookmark: Bookmark) => {
+  await client.delete(`/workspaces/${workspaceName}/bookmarks`, { data: bookmark });
+};
+
+// layout 'extracted' uploads a single uncompressed tar of an already extracted bundle
+export const uploadVersion = async (workspaceName: string, files: File | File[], layout?: 'extracted') => {
   const formData = new FormData();
   const fileList = Array.isArray(files) ? files : [files];
+  if (layout) {
+    // Must precede the file, the server unpacks the tar while it is received
+    formData.append('layout', layout);
+  }
   
   fileList.forEach(file => {
     formData.append('file', file);
@@ -43,52 +199,272 @@ export const uploadVersion = async (workspaceName: string, files: File | File[])
   });
 };
 
-export const startSimulator = async (workspaceName: string, versionID: string) => {
-  await client.post(`/workspaces/${workspaceName}/versions/${versionID}/start`);
+// Replaces the bundle of a version keeping its ID and name, the previous bundle goes to the trash
+export const replaceVersionBundle = async (workspaceName: string, versionID: string, files: File | File[], layout?: 'extracted') => {
+  const formData = new FormData();
+  const fileList = Array.isArray(files) ? files : [files];
+  if (layout) {
+    formData.append('layout', layout);
+  }
+  fileList.forEach(file => {
+    formData.append('file', file);
+  });
+
+  const response = await client.put<Version>(`/workspaces/${workspaceName}/versions/${versionID}/bundle`, formData, {
+    headers: {
+      'Content-Type': 'multipart/form-data',
+    },
+  });
+  return response.data;
+};
+
+// Creates a workspace named after the bundle contents, dryRun only returns the name it would get
+export const autoImport = async (files: File | File[], dryRun = false) => {
+  const formData = new FormData();
+  const fileList = Array.isArray(files) ? files : [files];
+  fileList.forEach(file => {
+    formData.append('file', file);
+  });
+
+  const response = await client.post<AutoImportResult>('/workspaces/auto-import', formData, {
+    headers: {
+      'Content-Type': 'multipart/form-data',
+    },
+    params: dryRun ? { dryRun: true } : undefined,
+  });
+  return response.data;
+};
+
+// Imports a bundle already on the server disk, move removes the source once imported
+export const importVersionFromPath = async (workspaceName: string, path: string, move = false) => {
+  const response = await client.post<Version>(`/workspaces/${workspaceName}/versions/from-path`, { path, move });
+  return response.data;
+};
+
+// Returns a warning when the simulator image barely fit in the disk space of docker. The network,
+// ports, labels and environment are remembered for the next starts of the version, '' is the default
+// bridge network.
+export const startSimulator = async (
+  workspaceName: string,
+  versionID: string,
+  network?: string,
+  extras?: { labels?: Record<string, string>; env?: Record<string, string>; ports?: number[] },
+) => {
+  const body = network === undefined && extras === undefined ? undefined : { network, ...extras };
+  const response = await client.post<{ warning?: string } | string>(
+    `/workspaces/${workspaceName}/versions/${versionID}/start`,
+    body,
+  );
+  return typeof response.data === 'object' ? response.data.warning : undefined;
+};
+
+// Changes the start parameters of a version without starting it, left out ones are kept and empty
+// ones cleared
+export const updateStartParameters = async (
+  workspaceName: string,
+  versionID: string,
+  params: Partial<StartParameters>,
+) => {
+  const response = await client.patch<Version>(`/workspaces/${workspaceName}/versions/${versionID}`, params);
+  return response.data;
 };
 
 export const stopSimulator = async (workspaceName: string, versionID: string) => {
   await client.post(`/workspaces/${workspaceName}/versions/${versionID}/stop`);
 };
 
+// Restarts a running simulator so it loads its bundle again, dropping the changes made through kubectl
+export const resetSimulator = async (workspaceName: string, versionID: string) => {
+  await client.post(`/workspaces/${workspaceName}/versions/${versionID}/reset`);
+};
+
+export interface SnapshotInfo {
+  id: number;
+  capturedAt: string;
+  resources: number;
+}
+
+// Captures what the running simulator answers so the resource endpoints can answer once it is stopped
+export const captureSnapshot = async (workspaceName: string, versionID: string, resources?: string[]) => {
+  const response = await client.post<SnapshotInfo>(
+    `/workspaces/${workspaceName}/versions/${versionID}/snapshot`,
+    resources ? { resources } : undefined,
+  );
+  return response.data;
+};
+
+export const getSnapshots = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<SnapshotInfo[]>(`/workspaces/${workspaceName}/versions/${versionID}/snapshots`);
+  return response.data;
+};
+
+// tail returns only the last lines of the log, the whole log is served with Range support
+export const getBuildLog = async (workspaceName: string, versionID: string, tail?: number) => {
+  const response = await client.get<string>(`/workspaces/${workspaceName}/versions/${versionID}/build-log`, {
+    responseType: 'text',
+    params: tail ? { tail } : undefined,
+  });
+  return response.data;
+};
+
+export const getVersion = async (workspaceName: string, versionID: string, include?: 'usage') => {
+  const response = await client.get<VersionDetail>(`/workspaces/${workspaceName}/versions/${versionID}`, {
+    params: include ? { include } : undefined,
+  });
+  return response.data;
+};
+
 export const getSimulatorStatus = async (workspaceName: string, versionID: string) => {
-  const response = await client.get<{ running: boolean; ready: boolean }>(`/workspaces/${workspaceName}/versions/${versionID}/status`);
+  const response = await client.get<SimulatorStatus>(`/workspaces/${workspaceName}/versions/${versionID}/status`);
+  return response.data;
+};
+
+// docker inspect of the simulator container and its image for debugging, admins only when tokens are required
+export const inspectSimulator = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<Record<string, unknown>>(`/workspaces/${workspaceName}/versions/${versionID}/inspect`);
+  return response.data;
+};
+
+// The mappings are empty unless the simulator runs
+export const getSimulatorPorts = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<{ ports: number[]; mappings: PortMapping[] }>(
+    `/workspaces/${workspaceName}/versions/${versionID}/ports`,
+  );
   return response.data;
 };
 
 export const getKubeconfigUrl = (workspaceName: string, versionID: string) => {
-  return `/api/workspaces/${workspaceName}/versions/${versionID}/kubeconfig`;
+  return withToken(`${basePath}/api/workspaces/${workspaceName}/versions/${versionID}/kubeconfig`);
+};
+
+// Shell script that downloads the kubeconfig and opens a shell using it, e.g. for curl ... | sh
+export const getConnectScriptUrl = (workspaceName: string, versionID: string) => {
+  return withToken(`${basePath}/api/workspaces/${workspaceName}/versions/${versionID}/connect-script`);
 };
 
 export const getWorkspaceKubeconfigUrl = (workspaceName: string) => {
-  return `/api/workspaces/${workspaceName}/kubeconfig`;
+  return withToken(`${basePath}/api/workspaces/${workspaceName}/kubeconfig`);
 };
 
-export const deleteVersion = async (workspaceName: string, versionID: string) => {
-  await client.delete(`/workspaces/${workspaceName}/versions/${versionID}`);
+export const getWorkspaceKubeconfigsZipUrl = (workspaceName: string) => {
+  return withToken(`${basePath}/api/workspaces/${workspaceName}/kubeconfigs.zip`);
 };
 
-export const cleanVersionImage = async (workspaceName: string, versionID: string) => {
-  await client.post(`/workspaces/${workspaceName}/versions/${versionID}/clean-image`);
+// Resolves with a result per scope once the version is deleted, those with an error left something behind
+export const deleteVersion = async (workspaceName: string, versionID: string, permanent = false) => {
+  const response = await client.delete<CleanupResult[]>(`/workspaces/${workspaceName}/versions/${versionID}`, {
+    params: permanent ? { permanent: true } : undefined,
+  });
+  return response.data;
+};
+
+export const listTrash = async () => {
+  const response = await client.get<TrashEntry[]>('/trash');
+  return response.data;
+};
+
+export const restoreTrash = async (id: string) => {
+  const response = await client.post<TrashRestoreResult>(`/trash/${id}/restore`);
+  return response.data;
+};
+
+export const setVersionOrder = async (workspaceName: string, versionIDs: string[]) => {
+  const response = await client.put<Version[]>(`/workspaces/${workspaceName}/versions/order`, { versionIDs });
+  return response.data;
+};
+
+export const cleanVersionImage = async (workspaceName: string, versionID: string, extracted?: boolean, dryRun?: boolean) => {
+  const response = await client.post<CleanReport>(`/workspaces/${workspaceName}/versions/${versionID}/clean-image`, undefined, {
+    params: { ...(extracted ? { extracted: true } : {}), ...(dryRun ? { dryRun: true } : {}) },
+  });
+  return response.data;
+};
+
+export const cleanVersionExtracted = async (workspaceName: string, versionID: string) => {
+  await client.post(`/workspaces/${workspaceName}/versions/${versionID}/clean-extracted`);
+};
+
+export interface FileIndexStats {
+  files: number;
+  buildSeconds: number;
+}
+
+export const reindexVersion = async (workspaceName: string, versionID: string) => {
+  const response = await client.post<FileIndexStats>(`/workspaces/${workspaceName}/versions/${versionID}/reindex`);
+  return response.data;
 };
 
-export const cleanAllWorkspaceImages = async (workspaceName: string) => {
-  await client.post(`/workspaces/${workspaceName}/clean-all`);
+export const cleanAllWorkspaceImages = async (workspaceName: string, dryRun?: boolean) => {
+  const response = await client.post<CleanReport>(`/workspaces/${workspaceName}/clean-all`, undefined, {
+    params: dryRun ? { dryRun: true } : undefined,
+  });
+  return response.data;
 };
 
-export const cleanAllImages = async () => {
-  await client.post('/clean-all');
+export const cleanAllImages = async (dryRun?: boolean) => {
+  const response = await client.post<CleanReport>('/clean-all', undefined, {
+    params: dryRun ? { dryRun: true } : undefined,
+  });
+  return response.data;
 };
 
 export interface ResourceHistoryResult {
   versionID: string;
   content: string;
   error?: string;
-  status: 'found' | 'not_found' | 'stopped' | 'error';
+  status: 'found' | 'not_found' | 'stopped' | 'error' | 'missing';
+  snapshotAt?: string; // The simulator is stopped, the result is from the snapshot captured then
+}
+
+export const getResourceHistory = async (workspaceName: string, resource: string, selector?: string) => {
+  const response = await client.post<ResourceHistoryResult[]>(`/workspaces/${workspaceName}/resource-history`, { resource, selector });
+  return response.data;
+};
+
+// getResourceHistories gets several resources at once, results are keyed by resource and then by version
+export const getResourceHistories = async (workspaceName: string, resources: string[], selector?: string) => {
+  const response = await client.post<Record<string, Record<string, ResourceHistoryResult>>>(`/workspaces/${workspaceName}/resource-history`, { resources, selector });
+  return response.data;
+};
+
+export type SavedQueryInput = Pick<SavedQuery, 'name' | 'resource' | 'selector' | 'versionIDs' | 'diff'>;
+
+export const getSavedQueries = async (workspaceName: string) => {
+  const response = await client.get<SavedQuery[]>(`/workspaces/${workspaceName}/saved-queries`);
+  return response.data;
+};
+
+export const createSavedQuery = async (workspaceName: string, query: SavedQueryInput) => {
+  const response = await client.post<SavedQuery>(`/workspaces/${workspaceName}/saved-queries`, query);
+  return response.data;
+};
+
+export const updateSavedQuery = async (workspaceName: string, id: string, query: SavedQueryInput) => {
+  const response = await client.put<SavedQuery>(`/workspaces/${workspaceName}/saved-queries/${id}`, query);
+  return response.data;
+};
+
+export const deleteSavedQuery = async (workspaceName: string, id: string) => {
+  await client.delete(`/workspaces/${workspaceName}/saved-queries/${id}`);
+};
+
+export const runSavedQuery = async (workspaceName: string, id: string) => {
+  const response = await client.post<ResourceHistoryResult[]>(`/workspaces/${workspaceName}/saved-queries/${id}/run`);
+  return response.data;
+};
+
+// Answer of the browse endpoints with autostart=true when nothing was running, sent with 202 and Retry-After
+export interface AutostartResponse {
+  versionID: string;
+  message: string;
+  queuePosition?: number;
 }
 
-export const getResourceHistory = async (workspaceName: string, resource: string) => {
-  const response = await client.post<ResourceHistoryResult[]>(`/workspaces/${workspaceName}/resource-history`, { resource });
+// Namespaces of the running versions, with autostart the most recent bundle is started when none runs
+export const getNamespacesOrAutostart = async (workspaceName: string) => {
+  const response = await client.get<string[] | AutostartResponse>(`/workspaces/${workspaceName}/namespaces`, {
+    params: { autostart: 'true' }
+  });
   return response.data;
 };
 
@@ -111,8 +487,332 @@ export const getResources = async (workspaceName: string, namespace: string, res
   return response.data;
 };
 
+// Like getResources, telling which versions answered and why the others were skipped
+export const getResourceList = async (workspaceName: string, namespace: string, resourceType: string, keyword: string) => {
+  const response = await client.get<ResourceList>(`/workspaces/${workspaceName}/resources`, {
+    params: { namespace, resourceType, keyword, include: 'versions' }
+  });
+  return response.data;
+};
+
+export interface ContentMatch {
+  name: string;
+  versionID: string;
+  snippet: string;
+}
+
+// Searches the YAML of a single resource type for keyword, e.g. an IP address or a UID
+export const searchResourceContent = async (workspaceName: string, namespace: string, resourceType: string, keyword: string, versionID?: string) => {
+  const response = await client.get<ContentMatch[]>(`/workspaces/${workspaceName}/resources`, {
+    params: { namespace, resourceType, keyword, version: versionID, mode: 'content' }
+  });
+  return response.data;
+};
+
+export interface SettingSummary {
+  name: string;
+  value: string;
+  default: string;
+  customized: boolean;
+}
+
+export interface AddonSummary {
+  namespace: string;
+  name: string;
+  enabled: boolean;
+  version: string;
+  status?: string;
+}
+
+export interface SettingsSummaryResult {
+  versionID: string;
+  summary?: {
+    settings: SettingSummary[];
+    addons: AddonSummary[];
+    kubevirtVersion?: string;
+    longhornVersion?: string;
+  };
+  error?: string;
+  status: 'found' | 'stopped' | 'error' | 'missing';
+}
+
+export const getSettingsSummary = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<SettingsSummaryResult>(`/workspaces/${workspaceName}/versions/${versionID}/settings-summary`);
+  return response.data;
+};
+
+// Summaries of several versions, e.g. before and after an upgrade, to compare them
+export const compareSettingsSummaries = async (workspaceName: string, versionIDs?: string[]) => {
+  const response = await client.get<SettingsSummaryResult[]>(`/workspaces/${workspaceName}/settings-summary`, {
+    params: { versions: versionIDs?.join(',') }
+  });
+  return response.data;
+};
+
+export interface NodeMetrics {
+  name: string;
+  cpuMillicores?: number; // Only known from the metrics API
+  memoryBytes?: number;
+  cpuPercent?: number; // Of the allocatable resources, or of the capacity with Prometheus
+  memoryPercent?: number;
+}
+
+export interface PodMetrics {
+  namespace: string;
+  name: string;
+  cpuMillicores: number;
+  memoryBytes: number;
+}
+
+export interface MetricsSummaryResult {
+  versionID: string;
+  summary?: {
+    format: 'metrics-api' | 'prometheus';
+    sampledAt?: string;
+    nodes: NodeMetrics[];
+    topCPUPods: PodMetrics[];
+    topMemoryPods: PodMetrics[];
+  };
+  error?: string;
+  status: 'found' | 'none' | 'unavailable' | 'error' | 'missing';
+}
+
+export interface MetricsComparison {
+  results: MetricsSummaryResult[];
+  nodes: { name: string; versions: Record<string, NodeMetrics> }[];
+}
+
+// Node and pod consumption when the bundle was collected, read from the bundle so the simulator needn't run
+export const getMetricsSummary = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<MetricsSummaryResult>(`/workspaces/${workspaceName}/versions/${versionID}/metrics-summary`);
+  return response.data;
+};
+
+export const compareMetricsSummaries = async (workspaceName: string, versionID: string, otherVersionIDs: string[]) => {
+  const response = await client.get<MetricsComparison>(`/workspaces/${workspaceName}/versions/${versionID}/metrics-summary`, {
+    params: { compare: otherVersionIDs.join(',') }
+  });
+  return response.data;
+};
+
+export interface HelmRelease {
+  namespace: string;
+  name: string;
+  revision: number;
+  status?: string;
+  chart?: string;
+  chartVersion?: string;
+  appVersion?: string;
+  lastDeployed?: string;
+  secret: string;
+  error?: string; // The release secret couldn't be decoded
+}
+
+export interface HelmReleasesResult {
+  versionID: string;
+  releases?: HelmRelease[];
+  error?: string;
+  status: 'found' | 'stopped' | 'error' | 'missing';
+}
+
+export const getHelmReleases = async (workspaceName: string, versionID: string) => {
+  const response = await client.get<HelmReleasesResult>(`/workspaces/${workspaceName}/versions/${versionID}/helm-releases`);
+  return response.data;
+};
+
+// Releases of several versions, with diff only those that differ between them
+export const compareHelmReleases = async (workspaceName: string, versionIDs?: string[], diff?: boolean) => {
+  const response = await client.get<HelmReleasesResult[]>(`/workspaces/${workspaceName}/helm-releases`, {
+    params: { versions: versionIDs?.join(','), diff: diff ? 'true' : undefined }
+  });
+  return response.data;
+};
+
+export interface RBACGrant {
+  bindingKind: 'RoleBinding' | 'ClusterRoleBinding';
+  binding: string;
+  bindingNamespace?: string;
+  roleKind: 'Role' | 'ClusterRole';
+  role: string;
+}
+
+export interface RBACCheckResult {
+  subjects?: { kind: string; name: string; namespace?: string; grants: RBACGrant[] }[];
+  serviceAccount?: string;
+  allowed: boolean;
+  grants?: RBACGrant[];
+  error?: string;
+}
+
+export interface RBACCheckRequest {
+  verb: string;
+  resource: string; // e.g. pods, pods/log or deployments.apps
+  resourceName?: string;
+  namespace?: string;
+  serviceAccount?: string;
+}
+
+// Who may perform a request, or whether serviceAccount may, evaluated against the roles and bindings of the version
+export const checkRBAC = async (workspaceName: string, versionID: string, req: RBACCheckRequest) => {
+  const response = await client.post<RBACCheckResult>(`/workspaces/${workspaceName}/versions/${versionID}/rbac-check`, req);
+  return response.data;
+};
+
+export interface StorageHop {
+  kind: 'PersistentVolumeClaim' | 'PersistentVolume' | 'LonghornVolume' | 'VolumeAttachment';
+  name: string;
+  namespace?: string;
+  status: string;
+  size?: string;
+  storageClass?: string;
+  node?: string;
+}
+
+export interface VMStorageResult {
+  vmName: string;
+  volumes: {
+    name: string;
+    diskType?: string;
+    source: string;
+    imageID?: string;
+    chain: StorageHop[];
+    problems: string[];
+  }[];
+  error?: string;
+}
+
+// Follows every volume of a VM to its claim, persistent volume, Longhorn volume and attachments
+export const getVMStorage = async (workspaceName: string, versionID: string, namespace: string, vmName: string) => {
+  const response = await client.post<VMStorageResult>(`/workspaces/${workspaceName}/versions/${versionID}/vm-storage`, { namespace, vmName });
+  return response.data;
+};
+
+export interface NetworkAttachment {
+  namespace: string;
+  name: string;
+  type: string;
+  bridge?: string;
+  vlan?: number;
+  clusterNetwork?: string;
+  ipam?: string;
+  provider?: string;
+  cidr?: string;
+  gateway?: string;
+}
+
+export interface VMNetworkResult {
+  vmName: string;
+  node?: string;
+  nodeNetwork?: Record<string, string>;
+  interfaces: {
+    name: string;
+    network: string;
+    binding?: string;
+    model?: string;
+    mac?: string;
+    ips: string[];
+    attachment?: NetworkAttachment;
+    problems: string[];
+  }[];
+  error?: string;
+}
+
+// Lists the interfaces of a VM with their network attachment, addresses and the node's uplinks
+export const getVMNetwork = async (workspaceName: string, versionID: string, namespace: string, vmName: string) => {
+  const response = await client.get<VMNetworkResult>(`/workspaces/${workspaceName}/versions/${versionID}/vm-network`, { params: { namespace, vmName } });
+  return response.data;
+};
+
+export interface BackupTarget {
+  type?: string;
+  endpoint: string;
+  bucketName?: string;
+  bucketRegion?: string;
+}
+
+export interface VMBackupsResult {
+  harvester: boolean; // False for bundles of other clusters, message says why the result is empty
+  message?: string;
+  backupTarget?: BackupTarget;
+  vms: {
+    namespace: string;
+    vmName: string;
+    backups: {
+      name: string;
+      namespace: string;
+      type: 'backup' | 'snapshot';
+      state: 'Ready' | 'InProgress' | 'Error';
+      createdAt?: string;
+      error?: string;
+      target?: BackupTarget;
+      targetMismatch: boolean; // Stored on another target than the current backup-target setting
+      volumes: {
+        volumeName: string;
+        claimName?: string;
+        ready: boolean;
+        error?: string;
+        volumeSnapshot?: string;
+        volumeSnapshotContent?: string;
+        longhornBackup?: string;
+        longhornBackupState?: string;
+        longhornBackupURL?: string;
+      }[];
+      problems: string[];
+    }[];
+    restores: {
+      name: string;
+      namespace: string;
+      backupName: string;
+      backupNamespace: string;
+      newVM: boolean;
+      complete: boolean;
+      createdAt?: string;
+      problems: string[];
+    }[];
+  }[];
+  error?: string;
+}
+
+// Lists the VM backups and snapshots per VM with their volume snapshots, Longhorn backups and restores
+export const getVMBackups = async (workspaceName: string, versionID: string, namespace?: string) => {
+  const response = await client.get<VMBackupsResult>(`/workspaces/${workspaceName}/versions/${versionID}/vm-backups`, { params: { namespace } });
+  return response.data;
+};
+
+// url proxies code-server through the server and carries the token, directUrl is the port it is published on
 export const startCodeServer = async (workspaceName: string, versionID: string) => {
-  const response = await client.post<{ url: string }>(`/workspaces/${workspaceName}/versions/${versionID}/code-server`);
+  const response = await client.post<{ url: string; directUrl: string }>(`/workspaces/${workspaceName}/versions/${versionID}/code-server`);
+  return { ...response.data, url: withToken(response.data.url) };
+};
+
+// A directory in the project root of the code-server container, orphaned when no version owns it
+export interface CodeServerProject {
+  name: string;
+  workspace?: string;
+  versionId?: string;
+  orphaned: boolean;
+}
+
+export const listCodeServerProjects = async () => {
+  const response = await client.get<CodeServerProject[]>('/code-server/projects');
+  return response.data;
+};
+
+// Removes the orphaned project directories, or all of them with all
+export const purgeCodeServerProjects = async (all = false) => {
+  const response = await client.delete<{ removed: string[] }>('/code-server/projects', { params: all ? { all: true } : undefined });
+  return response.data;
+};
+
+// Lists the profiles of the server, empty when it serves a single data directory
+export const listProfiles = async () => {
+  const response = await client.get<ProfileSummary[]>('/profiles');
+  return response.data;
+};
+
+// Image pulls since the server started, e.g. of the simulator base image
+export const getPullStatus = async () => {
+  const response = await client.get<PullProgress[]>('/pull-status');
   return response.data;
 };
 
@@ -121,26 +821,48 @@ export const getUpdateStatus = async () => {
   return response.data;
 };
 
+export interface Taint {
+  key: string;
+  value?: string;
+  effect: 'NoSchedule' | 'PreferNoSchedule' | 'NoExecute';
+}
+
 export interface NodeCompatibilityResult {
   nodeName: string;
   matches: boolean;
   missingLabels: Array<{ key: string; value: string }>;
+  untoleratedTaints?: Taint[];
+  changed?: boolean; // the what-if changes flipped the verdict
+  baselineMatches?: boolean; // set with what-if changes
 }
 
 export interface NodeToNodeCompatibility {
   sourceNode: string;
   targetNode: string;
   missingLabels: Array<{ key: string; value: string }>;
+  changed?: boolean;
+  baselineMissingLabels?: Array<{ key: string; value: string }>;
+}
+
+// A hypothetical node change a live migration check is evaluated with, the cluster isn't changed
+export interface NodeChange {
+  node: string;
+  addLabels?: Record<string, string>;
+  removeLabels?: string[];
+  addTaints?: Taint[];
+  removeTaints?: Array<{ key: string; effect?: Taint['effect'] }>;
 }
 
 export interface PodInfo {
   name: string;
   creationTime: string;
+  creationTimeLocal?: string; // Set when the workspace has a time zone
 }
 
 export interface MigrationInfo {
   name: string;
   creationTime: string;
+  creationTimeLocal?: string;
   sourcePod: string;
   targetPod: string;
   yaml: string;
@@ -158,6 +880,7 @@ export interface LiveMigrationCheckResult {
   nodeSelector?: Record<string, string>;
   nodeResults: NodeCompatibilityResult[];
   nodeToNodeCompatibilities?: NodeToNodeCompatibility[];
+  whatIf?: NodeChange[];
   error?: string;
 }
 
@@ -170,11 +893,35 @@ export const getVirtualMachinePods = async (workspaceName: string, versionID: st
   return response.data;
 };
 
-export const checkLiveMigration = async (workspaceName: string, versionID: string, namespace: string, podName: string) => {
+export const checkLiveMigration = async (workspaceName: string, versionID: string, namespace: string, podName: string, whatIf?: NodeChange[]) => {
   const response = await client.post<LiveMigrationCheckResult>(`/workspaces/${workspaceName}/live-migration-check`, { 
     versionID,
     namespace, 
-    podName 
+    podName,
+    whatIf
   });
   return response.data;
 };
+
+export interface ServerEvent {
+  type: string;
+  workspace?: string;
+  versionID?: string;
+  time: string;
+  payload?: unknown;
+}
+
+// subscribeEvents streams state changes from the server, call the returned function to unsubscribe
+export const subscribeEvents = (onEvent: (event: ServerEvent) => void) => {
+  const source = new EventSource(withToken(`${client.defaults.baseURL}/events`));
+  const types = [
+    'workspace.created', 'workspace.updated', 'workspace.deleted',
+    'version.uploaded', 'version.replaced', 'version.deleted', 'version.restored', 'version.expiring', 'version.ready',
+    'simulator.starting', 'simulator.image-built', 'simulator.progress', 'simulator.started', 'simulator.kubeconfig-changed', 'simulator.reset', 'simulator.stopped',
+    'job.updated', 'events.dropped',
+  ];
+  types.forEach((type) => {
+    source.addEventListener(type, (e) => onEvent(JSON.parse((e as MessageEvent).data)));
+  });
+  return () => source.close();
+};
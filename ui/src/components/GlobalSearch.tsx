@@ -0,0 +1,71 @@
+import React, { useEffect, useState } from 'react';
+import { Link } from 'react-router-dom';
+import { searchVersions } from '../api/client';
+import type { SearchResult } from '../types';
+
+// Searches the versions of every workspace by cluster name, Harvester version and names as the user types
+export const GlobalSearch: React.FC = () => {
+  const [query, setQuery] = useState('');
+  const [result, setResult] = useState<SearchResult | null>(null);
+
+  useEffect(() => {
+    const q = query.trim();
+    if (q === '') {
+      setResult(null);
+      return;
+    }
+    let cancelled = false;
+    const timer = setTimeout(() => {
+      searchVersions({ q, limit: 20 })
+        .then((found) => !cancelled && setResult(found))
+        .catch(() => !cancelled && setResult(null));
+    }, 300);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [query]);
+
+  const close = () => {
+    setQuery('');
+    setResult(null);
+  };
+
+  return (
+    <div className="relative flex items-center">
+      <input
+        type="search"
+        value={query}
+        onChange={(e) => setQuery(e.target.value)}
+        placeholder="Search bundles"
+        className="w-64 rounded-md border border-gray-300 px-3 py-1.5 text-sm focus:border-blue-500 focus:outline-none"
+      />
+      {result && (
+        <div className="absolute right-0 top-full z-10 mt-1 w-96 max-h-96 overflow-y-auto rounded-md bg-white shadow-lg ring-1 ring-black ring-opacity-5">
+          {result.total === 0 && <div className="px-4 py-2 text-sm text-gray-500">No versions found</div>}
+          {result.workspaces.map((ws) => (
+            <div key={ws.name} className="border-b last:border-b-0">
+              <Link
+                to={`/workspaces/${encodeURIComponent(ws.name)}`}
+                onClick={close}
+                className="block px-4 py-2 text-sm font-medium text-gray-900 hover:bg-gray-50"
+              >
+                {ws.displayName || ws.name}
+              </Link>
+              {ws.versions.map((v) => (
+                <div key={v.id} className="px-6 pb-1 text-xs text-gray-600">
+                  {v.id} {v.name}
+                  {v.clusterName && ` · ${v.clusterName}`}
+                  {v.harvesterVersion && ` · ${v.harvesterVersion}`}
+                </div>
+              ))}
+            </div>
+          ))}
+          {result.total > result.workspaces.reduce((n, ws) => n + ws.versions.length, 0) && (
+            <div className="px-4 py-2 text-xs text-gray-500">{result.total} versions found, refine the search to see the rest</div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -1,6 +1,8 @@
 import React from 'react';
 import { Link, Outlet } from 'react-router-dom';
 import { UpdateNotification } from './UpdateNotification';
+import { GlobalSearch } from './GlobalSearch';
+import { ProfileSelector } from './ProfileSelector';
 
 export const Layout: React.FC = () => {
   return (
@@ -20,6 +22,10 @@ export const Layout: React.FC = () => {
                 </span>
               </Link>
             </div>
+            <div className="flex items-center gap-4">
+              <ProfileSelector />
+              <GlobalSearch />
+            </div>
           </div>
         </div>
       </header>
@@ -0,0 +1,43 @@
+import React, { useEffect, useState } from 'react';
+import { getProfile, listProfiles, setProfile } from '../api/client';
+import type { ProfileSummary } from '../types';
+
+// Switches between the data directories of a server started with --profile, hidden on other servers
+export const ProfileSelector: React.FC = () => {
+  const [profiles, setProfiles] = useState<ProfileSummary[]>([]);
+
+  useEffect(() => {
+    listProfiles()
+      .then(setProfiles)
+      .catch((error) => {
+        // The server answers 404 for a profile removed from it since it was selected, the default one is used instead
+        if (error.response?.status === 404 && getProfile()) {
+          setProfile('');
+          return;
+        }
+        console.error('Failed to list profiles:', error);
+      });
+  }, []);
+
+  if (profiles.length === 0) {
+    return null;
+  }
+
+  const selected = getProfile() || profiles.find((profile) => profile.default)?.name || '';
+  return (
+    <label className="flex items-center gap-2 text-sm text-gray-600">
+      Profile
+      <select
+        value={selected}
+        onChange={(e) => setProfile(e.target.value)}
+        className="rounded-md border border-gray-300 bg-white px-3 py-1.5 text-sm focus:border-blue-500 focus:outline-none"
+      >
+        {profiles.map((profile) => (
+          <option key={profile.name} value={profile.name}>
+            {profile.name}
+          </option>
+        ))}
+      </select>
+    </label>
+  );
+};
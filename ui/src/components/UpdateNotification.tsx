@@ -57,9 +57,28 @@ export const UpdateNotification: React.FC = () => {
             <p className="text-sm text-blue-700">
               {updateStatus.message}
               <span className="ml-2 text-xs text-blue-600">
-                (Current: {updateStatus.currentCommit?.slice(0, 7)}, Latest: {updateStatus.latestCommit?.slice(0, 7)})
+                {updateStatus.latestVersion
+                  ? `(Current: ${updateStatus.currentVersion}, Latest: ${updateStatus.latestVersion})`
+                  : `(Current: ${updateStatus.currentCommit?.slice(0, 7)}, Latest: ${updateStatus.latestCommit?.slice(0, 7)})`}
               </span>
+              {updateStatus.releaseURL && (
+                <a
+                  href={updateStatus.releaseURL}
+                  target="_blank"
+                  rel="noopener noreferrer"
+                  className="ml-2 text-xs font-medium text-blue-700 underline"
+                >
+                  Release notes
+                </a>
+              )}
             </p>
+            {updateStatus.changes && updateStatus.changes.length > 0 && (
+              <ul className="mt-1 list-disc list-inside text-xs text-blue-600">
+                {updateStatus.changes.slice(0, 5).map((change) => (
+                  <li key={change.sha}>{change.message}</li>
+                ))}
+              </ul>
+            )}
           </div>
         </div>
         <div className="flex items-center space-x-2">
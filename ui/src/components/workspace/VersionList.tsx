@@ -1,13 +1,13 @@
 import React, { useState, useRef, useEffect } from 'react';
-import { FileArchive, Play, Square, Download, Trash2, Circle, Loader2, Eraser, ChevronDown, Copy } from 'lucide-react';
-import { getKubeconfigUrl, startSimulator, stopSimulator, deleteVersion, cleanVersionImage } from '../../api/client';
+import { FileArchive, Play, Square, Download, Trash2, Circle, Loader2, Eraser, ChevronDown, Copy, RotateCcw } from 'lucide-react';
+import { getKubeconfigUrl, startSimulator, stopSimulator, resetSimulator, deleteVersion, cleanVersionImage } from '../../api/client';
 import type { Workspace } from '../../types';
 import { useToast } from '../../contexts/ToastContext';
 import { ConfirmDialog } from '../ConfirmDialog';
 
 interface VersionListProps {
   workspace: Workspace;
-  statuses: Record<string, { running: boolean; ready: boolean }>;
+  statuses: Record<string, { running: boolean; ready: boolean; baseImagePull?: { message: string } }>;
   onRefresh: () => void;
 }
 
@@ -19,7 +19,7 @@ export const VersionList: React.FC<VersionListProps> = ({
   const [loading, setLoading] = useState<Record<string, string | null>>({}); // versionID -> action ('start', 'stop', 'delete')
   const [openCopyMenu, setOpenCopyMenu] = useState<string | null>(null); // versionID of open menu
   const copyMenuRefs = useRef<Record<string, HTMLDivElement | null>>({});
-  const { showSuccess, showError } = useToast();
+  const { showSuccess, showError, showInfo } = useToast();
   const [confirmDialog, setConfirmDialog] = useState<{
     isOpen: boolean;
     title: string;
@@ -36,11 +36,16 @@ export const VersionList: React.FC<VersionListProps> = ({
   const handleStart = async (versionID: string) => {
     setLoading(prev => ({ ...prev, [versionID]: 'start' }));
     try {
-      await startSimulator(workspace.name, versionID);
+      const warning = await startSimulator(workspace.name, versionID);
+      if (warning) {
+        showInfo(warning);
+      }
       onRefresh();
     } catch (error) {
       console.error('Failed to start simulator', error);
-      showError('Failed to start simulator');
+      // Explains e.g. that the bundle doesn't fit in the disk space of docker
+      const message = (error as { response?: { data?: string } })?.response?.data;
+      showError(typeof message === 'string' && message ? message : 'Failed to start simulator');
     } finally {
       setLoading(prev => ({ ...prev, [versionID]: null }));
     }
@@ -59,6 +64,30 @@ export const VersionList: React.FC<VersionListProps> = ({
     }
   };
 
+  const handleReset = async (versionID: string) => {
+    setConfirmDialog({
+      isOpen: true,
+      title: 'Reset Simulator',
+      message: 'Are you sure you want to reset this simulator? Changes made through kubectl are dropped and the bundle is loaded again, kubeconfigs downloaded before stop working.',
+      variant: 'warning',
+      onConfirm: async () => {
+        setConfirmDialog({ ...confirmDialog, isOpen: false });
+        setLoading(prev => ({ ...prev, [versionID]: 'reset' }));
+        try {
+          await resetSimulator(workspace.name, versionID);
+          onRefresh();
+        } catch (error) {
+          console.error('Failed to reset simulator', error);
+          // Explains e.g. that kubectl commands still run on the simulator
+          const message = (error as { response?: { data?: string } })?.response?.data;
+          showError(typeof message === 'string' && message ? message : 'Failed to reset simulator');
+        } finally {
+          setLoading(prev => ({ ...prev, [versionID]: null }));
+        }
+      },
+    });
+  };
+
   const handleDelete = async (versionID: string) => {
     setConfirmDialog({
       isOpen: true,
@@ -107,7 +136,7 @@ export const VersionList: React.FC<VersionListProps> = ({
 
   const handleCopyK9sCommand = (versionID: string) => {
     const kubeconfigPath = `/tmp/sim-${workspace.name}-${versionID}.kubeconfig`;
-    const cmd = `curl -s -o ${kubeconfigPath} http://localhost:8080${getKubeconfigUrl(workspace.name, versionID)} && k9s --kubeconfig ${kubeconfigPath}`;
+    const cmd = `curl -s -o ${kubeconfigPath} ${window.location.origin}${getKubeconfigUrl(workspace.name, versionID)} && k9s --kubeconfig ${kubeconfigPath}`;
     navigator.clipboard.writeText(cmd);
     showSuccess('Copied k9s command to clipboard!');
     setOpenCopyMenu(null);
@@ -115,7 +144,7 @@ export const VersionList: React.FC<VersionListProps> = ({
 
   const handleCopyExportCommand = (versionID: string) => {
     const kubeconfigPath = `/tmp/sim-${workspace.name}-${versionID}.kubeconfig`;
-    const cmd = `curl -s -o ${kubeconfigPath} http://localhost:8080${getKubeconfigUrl(workspace.name, versionID)} && export KUBECONFIG=${kubeconfigPath}`;
+    const cmd = `curl -s -o ${kubeconfigPath} ${window.location.origin}${getKubeconfigUrl(workspace.name, versionID)} && export KUBECONFIG=${kubeconfigPath}`;
     navigator.clipboard.writeText(cmd);
     showSuccess('Copied export command to clipboard!');
     setOpenCopyMenu(null);
@@ -163,6 +192,12 @@ export const VersionList: React.FC<VersionListProps> = ({
                         {isReady ? 'Ready' : 'Initializing...'}
                       </span>
                     )}
+                    {!isRunning && statuses[version.id]?.baseImagePull && (
+                      <span className="ml-2 inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-yellow-100 text-yellow-800">
+                        <Loader2 className="w-3 h-3 mr-1 animate-spin" />
+                        {statuses[version.id]?.baseImagePull?.message}
+                      </span>
+                    )}
                   </div>
                   <div className="ml-2 flex-shrink-0 flex">
                     <p className="px-2 inline-flex text-xs leading-5 font-semibold rounded-full bg-green-100 text-green-800">
@@ -173,10 +208,16 @@ export const VersionList: React.FC<VersionListProps> = ({
                 <div className="mt-2 sm:flex sm:justify-between">
                   <div className="sm:flex">
                     <p className="flex items-center text-sm text-gray-500">
+                      {version.clusterName && <span className="font-medium text-gray-700 mr-2">{version.clusterName}</span>}
                       {version.supportBundleName}
                     </p>
                   </div>
                   <div className="mt-2 flex items-center text-sm text-gray-500 sm:mt-0 gap-2">
+                    {version.collectedAt && (
+                      <p title={version.collectedAtEstimated ? 'Estimated from the newest resource in the bundle' : undefined}>
+                        Collected {version.collectedAtEstimated && '~'}{new Date(version.collectedAt).toLocaleString()}
+                      </p>
+                    )}
                     <p>
                       Uploaded {new Date(version.createdAt).toLocaleDateString()}
                     </p>
@@ -190,16 +231,32 @@ export const VersionList: React.FC<VersionListProps> = ({
                     </button>
                   </div>
                 </div>
+                {version.lastError && (
+                  <p className="mt-2 text-xs text-red-600 whitespace-pre-wrap" title={new Date(version.lastError.time).toLocaleString()}>
+                    {version.lastError.stage} failed: {version.lastError.message}
+                  </p>
+                )}
                 <div className="mt-4 flex items-center space-x-4">
                   {isRunning ? (
-                    <button
-                      onClick={() => handleStop(version.id)}
-                      disabled={!!isLoading}
-                      className="inline-flex items-center px-3 py-1 border border-transparent text-xs font-medium rounded-md text-white bg-red-600 hover:bg-red-700 disabled:opacity-50 disabled:cursor-not-allowed"
-                    >
-                      {isLoading === 'stop' ? <Loader2 className="h-4 w-4 mr-1 animate-spin" /> : <Square className="h-4 w-4 mr-1 fill-current" />}
-                      Stop Simulator
-                    </button>
+                    <>
+                      <button
+                        onClick={() => handleStop(version.id)}
+                        disabled={!!isLoading}
+                        className="inline-flex items-center px-3 py-1 border border-transparent text-xs font-medium rounded-md text-white bg-red-600 hover:bg-red-700 disabled:opacity-50 disabled:cursor-not-allowed"
+                      >
+                        {isLoading === 'stop' ? <Loader2 className="h-4 w-4 mr-1 animate-spin" /> : <Square className="h-4 w-4 mr-1 fill-current" />}
+                        Stop Simulator
+                      </button>
+                      <button
+                        onClick={() => handleReset(version.id)}
+                        disabled={!!isLoading || !isReady}
+                        title="Load the bundle again, dropping the changes made through kubectl"
+                        className="inline-flex items-center px-3 py-1 border border-gray-300 text-xs font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 disabled:opacity-50 disabled:cursor-not-allowed"
+                      >
+                        {isLoading === 'reset' ? <Loader2 className="h-4 w-4 mr-1 animate-spin" /> : <RotateCcw className="h-4 w-4 mr-1" />}
+                        Reset
+                      </button>
+                    </>
                   ) : (
                     <button
                       onClick={() => handleStart(version.id)}
@@ -1,7 +1,7 @@
 import React, { useEffect, useState, useCallback, useRef } from 'react';
 import { useParams } from 'react-router-dom';
 import { Upload, List, Search, Pencil, Folder, Trash2, Loader2, Download, Copy, ChevronDown, GitBranch } from 'lucide-react';
-import { getWorkspace, getSimulatorStatus, renameWorkspace, cleanAllWorkspaceImages, getWorkspaceKubeconfigUrl } from '../api/client';
+import { getWorkspace, getVersion, renameWorkspace, cleanAllWorkspaceImages, getWorkspaceKubeconfigUrl, getWorkspaceKubeconfigsZipUrl } from '../api/client';
 import type { Workspace } from '../types';
 import { UploadArea } from '../components/workspace/UploadArea';
 import { getWorkspaceDisplayName, getWorkspaceEditableName } from '../utils/workspace';
@@ -17,7 +17,7 @@ type Tab = 'upload' | 'versions' | 'search' | 'explorer' | 'migration';
 export const WorkspaceDetail: React.FC = () => {
   const { name } = useParams<{ name: string }>();
   const [workspace, setWorkspace] = useState<Workspace | null>(null);
-  const [statuses, setStatuses] = useState<Record<string, { running: boolean; ready: boolean }>>({});
+  const [statuses, setStatuses] = useState<Record<string, { running: boolean; ready: boolean; baseImagePull?: { message: string } }>>({});
   const [activeTab, setActiveTab] = useState<Tab>('versions');
 
   const [isRenaming, setIsRenaming] = useState(false);
@@ -52,11 +52,11 @@ export const WorkspaceDetail: React.FC = () => {
 
   const loadStatuses = useCallback(async () => {
     if (!name || !workspace) return;
-    const newStatuses: Record<string, { running: boolean; ready: boolean }> = {};
+    const newStatuses: Record<string, { running: boolean; ready: boolean; baseImagePull?: { message: string } }> = {};
     for (const version of workspace.versions) {
       try {
-        const status = await getSimulatorStatus(name, version.id);
-        newStatuses[version.id] = status;
+        const detail = await getVersion(name, version.id);
+        newStatuses[version.id] = detail.status;
       } catch (error) {
         console.error(`Failed to load status for ${version.id}`, error);
       }
@@ -93,8 +93,13 @@ export const WorkspaceDetail: React.FC = () => {
         setConfirmDialog({ ...confirmDialog, isOpen: false });
         setIsCleaning(true);
         try {
-          await cleanAllWorkspaceImages(name);
-          showSuccess('All containers and images cleaned successfully!');
+          const report = await cleanAllWorkspaceImages(name);
+          if (report.failed > 0) {
+            const failed = report.versions.filter((v) => v.status === 'failed').map((v) => v.versionID);
+            showError(`Failed to clean ${failed.join(', ')}, the other versions were cleaned`);
+          } else {
+            showSuccess('All containers and images cleaned successfully!');
+          }
           await loadWorkspace();
           await loadStatuses();
         } catch (error) {
@@ -126,7 +131,7 @@ export const WorkspaceDetail: React.FC = () => {
 
   const handleCopyK9sCommand = () => {
     if (!name) return;
-    const command = `curl -s -o /tmp/sim-${name}.kubeconfig http://localhost:8080${getWorkspaceKubeconfigUrl(name)} && k9s --kubeconfig /tmp/sim-${name}.kubeconfig`;
+    const command = `curl -s -o /tmp/sim-${name}.kubeconfig ${window.location.origin}${getWorkspaceKubeconfigUrl(name)} && k9s --kubeconfig /tmp/sim-${name}.kubeconfig`;
     navigator.clipboard.writeText(command);
     showSuccess('Copied k9s command to clipboard!');
     setShowCopyMenu(false);
@@ -134,7 +139,7 @@ export const WorkspaceDetail: React.FC = () => {
 
   const handleCopyExportCommand = () => {
     if (!name) return;
-    const command = `curl -s -o /tmp/sim-${name}.kubeconfig http://localhost:8080${getWorkspaceKubeconfigUrl(name)} && export KUBECONFIG=/tmp/sim-${name}.kubeconfig`;
+    const command = `curl -s -o /tmp/sim-${name}.kubeconfig ${window.location.origin}${getWorkspaceKubeconfigUrl(name)} && export KUBECONFIG=/tmp/sim-${name}.kubeconfig`;
     navigator.clipboard.writeText(command);
     showSuccess('Copied export command to clipboard!');
     setShowCopyMenu(false);
@@ -227,6 +232,16 @@ export const WorkspaceDetail: React.FC = () => {
                     <Download className="h-4 w-4 mr-2" />
                     Download Kubeconfig
                   </a>
+                  <a
+                    href={getWorkspaceKubeconfigsZipUrl(name)}
+                    download={`${name}-kubeconfigs.zip`}
+                    className="w-full text-left px-4 py-2 text-sm text-gray-700 hover:bg-gray-100 flex items-center"
+                    role="menuitem"
+                    onClick={() => setShowCopyMenu(false)}
+                  >
+                    <Download className="h-4 w-4 mr-2" />
+                    Download Kubeconfigs (zip)
+                  </a>
                 </div>
               </div>
             )}
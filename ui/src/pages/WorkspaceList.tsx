@@ -1,8 +1,8 @@
 import React, { useEffect, useState, useCallback, useMemo } from 'react';
 import { Link } from 'react-router-dom';
 import { AxiosError } from 'axios';
-import { Plus, Folder, Pencil, Trash, Loader2, Trash2, Search, ArrowUpDown, Circle } from 'lucide-react';
-import { getWorkspaces, createWorkspace, renameWorkspace, deleteWorkspace, cleanAllImages, getSimulatorStatus } from '../api/client';
+import { Plus, Folder, Pencil, Trash, Loader2, Trash2, Search, ArrowUpDown, Circle, User } from 'lucide-react';
+import { getWorkspaces, createWorkspace, renameWorkspace, deleteWorkspace, getJob, cleanAllImages, getSimulatorStatus } from '../api/client';
 import type { Workspace } from '../types';
 import { getWorkspaceDisplayName, getWorkspaceEditableName } from '../utils/workspace';
 import { useToast } from '../contexts/ToastContext';
@@ -22,6 +22,7 @@ export const WorkspaceList: React.FC = () => {
   const [isCleaningAll, setIsCleaningAll] = useState(false);
   const [searchQuery, setSearchQuery] = useState('');
   const [sortOrder, setSortOrder] = useState<'asc' | 'desc'>('desc');
+  const [onlyMine, setOnlyMine] = useState(false);
   const [workspaceStatuses, setWorkspaceStatuses] = useState<Record<string, Record<string, { running: boolean; ready: boolean }>>>({});
   const { showSuccess, showError } = useToast();
   const [confirmDialog, setConfirmDialog] = useState<{
@@ -39,13 +40,13 @@ export const WorkspaceList: React.FC = () => {
 
   const loadWorkspaces = useCallback(async () => {
     try {
-      const data = await getWorkspaces();
+      const data = await getWorkspaces(undefined, onlyMine);
       console.log('Loaded workspaces:', data);
       setWorkspaces(data || []);
     } catch (error) {
       console.error('Failed to load workspaces', error);
     }
-  }, []);
+  }, [onlyMine]);
 
   const loadStatuses = useCallback(async () => {
     const newStatuses: Record<string, Record<string, { running: boolean; ready: boolean }>> = {};
@@ -160,14 +161,22 @@ export const WorkspaceList: React.FC = () => {
     setConfirmDialog({
       isOpen: true,
       title: 'Delete Workspace',
-      message: 'Are you sure you want to delete this workspace? This action cannot be undone.',
+      message: 'Are you sure you want to delete this workspace? It is kept in the trash and can be restored from there.',
       variant: 'danger',
       onConfirm: async () => {
         setConfirmDialog({ ...confirmDialog, isOpen: false });
         setDeletingWorkspace(name);
         try {
-          await deleteWorkspace(name);
-          showSuccess('Workspace deleted successfully');
+          let job = await deleteWorkspace(name);
+          await loadWorkspaces();
+          while (job.status === 'running') {
+            await new Promise((resolve) => setTimeout(resolve, 1000));
+            job = await getJob(job.id);
+          }
+          if (job.status === 'failed') {
+            throw new Error(job.error);
+          }
+          showSuccess(job.status === 'cancelled' ? 'Workspace delete cancelled' : 'Workspace deleted successfully');
           await loadWorkspaces();
         } catch (error) {
           console.error('Failed to delete workspace', error);
@@ -189,8 +198,13 @@ export const WorkspaceList: React.FC = () => {
         setConfirmDialog({ ...confirmDialog, isOpen: false });
         setIsCleaningAll(true);
         try {
-          await cleanAllImages();
-          showSuccess('All containers and images cleaned successfully!');
+          const report = await cleanAllImages();
+          if (report.failed > 0) {
+            const failed = report.versions.filter((v) => v.status === 'failed').map((v) => `${v.workspace}/${v.versionID}`);
+            showError(`Failed to clean ${failed.join(', ')}, the other versions were cleaned`);
+          } else {
+            showSuccess('All containers and images cleaned successfully!');
+          }
           await loadWorkspaces();
         } catch (error) {
           console.error('Failed to clean all images', error);
@@ -262,6 +276,16 @@ export const WorkspaceList: React.FC = () => {
             </div>
           </div>
 
+          {/* Only the workspaces created by the current user */}
+          <button
+            onClick={() => setOnlyMine(!onlyMine)}
+            className={`inline-flex items-center px-4 py-2 border shadow-sm text-sm font-medium rounded-md focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500 ${onlyMine ? 'border-indigo-500 text-indigo-700 bg-indigo-50' : 'border-gray-300 text-gray-700 bg-white hover:bg-gray-50'}`}
+            title="Show only the workspaces you created"
+          >
+            <User className="h-4 w-4" />
+            <span className="ml-2">Mine</span>
+          </button>
+
           {/* Sort Order Toggle */}
           <button
             onClick={() => setSortOrder(sortOrder === 'asc' ? 'desc' : 'asc')}
@@ -405,6 +429,9 @@ export const WorkspaceList: React.FC = () => {
                     <span className="text-gray-500">
                       Created {new Date(ws.createdAt).toLocaleDateString()}
                     </span>
+                    {ws.state === 'deleting' && (
+                      <span className="ml-2 text-red-600 font-medium">Deleting</span>
+                    )}
                   </div>
                 </div>
               </div>
@@ -4,19 +4,304 @@ export interface Version {
   createdAt: string;
   path: string;
   supportBundleName: string;
+  importedFrom?: string;
+  createdBy?: string; // Set when the server is given a user header
+  sortIndex?: number; // Set by reordering the versions
+  extractedOnly?: boolean;
+  extractedRemoved?: boolean; // Extracted again from the bundle on the next start
+  buildError?: string;
+  interrupted?: string; // The image build was cut short by a server stop, start the simulator again
+  lastStartedAt?: string;
+  lastAccessedAt?: string;
+  harvesterVersion?: string;
+  kubernetesVersion?: string;
+  nodeCount?: number;
+  clusterName?: string;
+  collectedAt?: string;
+  collectedAtEstimated?: boolean; // collectedAt is the newest resource creationTimestamp in the bundle
+  pinned?: boolean;
+  codeServerProject?: string; // Directory in the project root of the shared code-server container
+  replacedAt?: string; // The previous bundle is in the trash
+  imageStale?: boolean; // The next start builds the image again from the replaced bundle
+  baseImageDigest?: string; // support-bundle-kit image the last successful build was based on
+  baseImageCreated?: string;
+  network?: string; // Docker network the simulator is started on, the default bridge network when unset
+  ports?: number[]; // Container ports published besides the apiserver
+  startLabels?: Record<string, string>; // Container labels of the last start request
+  startEnv?: Record<string, string>; // Container environment of the last start request
+  lastError?: LastError; // Cleared once the operation of its stage succeeds
+}
+
+// A failure of a version that happened in the background, e.g. while the simulator loads the bundle
+export interface LastError {
+  message: string;
+  stage: 'build' | 'start' | 'ready' | 'cleanup';
+  time: string;
+}
+
+export interface Bookmark {
+  namespace?: string;
+  resourceType: string;
+  name: string;
+  label?: string;
 }
 
 export interface Workspace {
   name: string;
   displayName?: string;
   createdAt: string;
+  createdBy?: string; // Set when the server is given a user header
   versions: Version[];
+  defaultNamespace?: string;
+  bookmarks?: Bookmark[];
+  savedQueries?: SavedQuery[];
+  retentionDays?: number;
+  preferences?: WorkspacePreferences;
+  containerLabels?: Record<string, string>; // Added to the containers started for the workspace
+  containerEnv?: Record<string, string>;
+  state?: 'deleting'; // Set while the workspace is deleted, changes to it are refused
+}
+
+// Presentation only, the default namespace is defaultNamespace of the workspace
+export interface WorkspacePreferences {
+  timeZone?: string; // IANA name, timestamps are also rendered in it as *Local fields
+  outputFormat?: 'yaml' | 'json'; // Format of resource-history content, yaml by default
+}
+
+export interface SavedQuery {
+  id: string;
+  name: string;
+  resource: string;
+  selector?: string;
+  versionIDs?: string[];
+  diff: boolean;
+  createdAt: string;
+  lastRunAt?: string;
+}
+
+export interface UpdateChange {
+  sha: string;
+  message: string;
 }
 
 export interface UpdateStatus {
   updateAvailable: boolean;
   currentCommit: string;
   latestCommit: string;
+  currentVersion: string;
+  latestVersion: string;
+  releaseNotes?: string;
+  releaseURL?: string;
+  changes?: UpdateChange[];
   lastChecked: string;
   message: string;
 }
+
+export interface SimulatorProgress {
+  typesLoaded: number;
+  typesTotal?: number;
+  objectsLoaded: number;
+  percent?: number;
+  lastMessage?: string;
+  startedAt: string;
+  ready: boolean;
+}
+
+// Where a port of a simulator is reached, host is the IP of the container on other networks than the
+// default bridge network
+export interface PortMapping {
+  containerPort: number;
+  protocol: string;
+  host: string;
+  hostPort: number;
+}
+
+export interface SimulatorContainer {
+  id: string;
+  state: string; // Docker state, e.g. created, running, exited
+  startedAt?: string;
+  image: string;
+  imageCreatedAt?: string;
+  apiServerPort?: number;
+  labels?: Record<string, string>;
+  ports?: PortMapping[]; // Set while the container runs
+  finishedAt?: string; // Set with exitCode and logs once the container exited
+  exitCode?: number;
+  logs?: string[];
+}
+
+export interface SimulatorStatus {
+  running: boolean;
+  ready: boolean;
+  progress?: SimulatorProgress;
+  lastStartedAt?: string;
+  container?: SimulatorContainer;
+  extraction?: { files: number; totalFiles: number }; // Set while the removed extracted bundle is extracted again
+  queuePosition?: number; // Set while the start waits for other simulators to finish starting
+  baseImagePull?: BaseImagePull; // Set while the start waits for the base image to be pulled
+  build?: SimulatorBuild;
+  startParameters?: StartParameters; // Unset for runtime versions
+  lastError?: LastError;
+}
+
+export interface LayerProgress {
+  id: string;
+  status: string; // Last status reported by docker, e.g. Downloading or Pull complete
+  downloaded: number;
+  total: number; // 0 until docker reports the size, and for layers that already exist
+}
+
+export interface PullProgress {
+  image: string;
+  state: 'pulling' | 'done' | 'failed';
+  layers: LayerProgress[];
+  downloaded: number;
+  total: number;
+  percent: number;
+  etaSeconds?: number;
+  attempt: number;
+  startedAt: string;
+  finishedAt?: string;
+  error?: string; // Why the last attempt failed
+}
+
+export interface BaseImagePull extends PullProgress {
+  message: string; // e.g. "waiting for base image pull (42%)"
+}
+
+// What the next start creates the container with unless the start request changes it
+export interface StartParameters {
+  network: string;
+  ports: number[];
+  labels: Record<string, string>;
+  env: Record<string, string>;
+}
+
+export interface VersionDetail extends Version {
+  status: SimulatorStatus;
+  usage?: { dirBytes: number; imageBytes: number }; // Set with include=usage
+}
+
+export interface SimulatorBuild {
+  version?: string; // Reported by support-bundle-kit while the container runs
+  versionError?: string;
+  baseImage: string;
+  baseImageDigest?: string;
+  baseImageCreated?: string;
+  labels?: Record<string, string>;
+}
+
+export interface AutoImportResult {
+  workspace: string;
+  versionID: string;
+  dryRun?: boolean;
+}
+
+export interface TrashEntry {
+  id: string;
+  workspace: string;
+  versionID?: string; // Empty when the whole workspace was deleted
+  deletedAt: string;
+  purgeAt?: string; // Unset when the trash is kept until restored
+  workspaceRecord?: Workspace;
+  versions: Version[];
+  replaced?: boolean; // The bundle of versionID was replaced, the version still exists
+}
+
+export interface TrashRestoreResult {
+  workspace: string;
+  versions: { id: string; originalID: string }[];
+}
+
+// A container or image of a simulator, removed and error are set once the clean ran
+export interface CleanItem {
+  id: string;
+  name: string;
+  running?: boolean;
+  size?: number;
+  removed: boolean;
+  error?: string;
+}
+
+// What cleaning one scope of a version removed, deleting a version returns one per scope
+export interface CleanupResult {
+  scope: 'runtime' | 'files' | 'codeServer';
+  removed: string[];
+  plan?: { instance: string; containers: CleanItem[]; images: CleanItem[]; reclaimableBytes: number }; // runtime only
+  error?: string;
+}
+
+// Work the server runs in the background, e.g. a workspace delete or a simulator loading its bundle
+export interface Job<Result = unknown> {
+  id: string;
+  kind: 'workspace-delete' | 'simulator-ready' | string;
+  workspace?: string;
+  versionID?: string;
+  status: 'running' | 'succeeded' | 'failed' | 'cancelled' | 'interrupted'; // interrupted by a server restart
+  phase?: string;
+  progress?: { done: number; total: number };
+  result?: Result;
+  error?: string;
+  startedAt: string;
+  finishedAt?: string;
+}
+
+// The result of a workspace-delete job, its progress counts the versions cleaned
+export interface DeleteResult {
+  leftovers?: Record<string, CleanupResult[]>; // Failed cleanups per instance, the workspace is deleted anyway
+}
+
+export interface CleanVersionResult {
+  workspace: string;
+  versionID: string;
+  status: 'cleaned' | 'failed' | 'planned';
+  instance: string;
+  containers: CleanItem[];
+  images: CleanItem[];
+  reclaimableBytes: number;
+  codeServer?: CleanupResult; // unset on a dry run
+  error?: string;
+}
+
+// Returned by the clean endpoints, with dryRun nothing was removed
+export interface CleanReport {
+  dryRun: boolean;
+  versions: CleanVersionResult[];
+  reclaimableBytes: number;
+  failed: number;
+}
+
+export interface ActivityEntry {
+  type: string;
+  actor?: string;
+  versionID?: string;
+  summary: string;
+  time: string;
+  localTime?: string; // time in the time zone of the workspace
+}
+
+// The versions a search matched grouped by workspace
+export interface SearchResult {
+  workspaces: { name: string; displayName: string; versions: Version[] }[];
+  total: number;
+}
+
+// Why a version didn't answer a query across versions, a simulator still loading is not_ready
+export interface VersionSkip {
+  versionID: string;
+  reason: 'not_running' | 'not_ready' | 'exec_error';
+  error?: string;
+}
+
+// GET /resources with include=versions, a resource missing from names can exist in a skipped version
+export interface ResourceList {
+  names: string[];
+  versionsQueried: string[];
+  versionsSkipped: VersionSkip[];
+}
+
+// A data directory of a server started with --profile, the default one serves requests selecting none
+export interface ProfileSummary {
+  name: string;
+  default: boolean;
+}
@@ -4,6 +4,8 @@ import react from '@vitejs/plugin-react'
 // https://vite.dev/config/
 export default defineConfig({
   plugins: [react()],
+  // Assets are resolved against the <base> the server adds to index.html, so the build works under any --base-path
+  base: './',
   server: {
     proxy: {
       '/api': {